@@ -0,0 +1,469 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/config"
+	"github.com/jaochai/ugc/internal/database"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/worker"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// cliTimeout bounds a single operator command.
+const cliTimeout = 30 * time.Second
+
+const cliUsage = `Usage: ugc [-mode api|worker|all]            run the service
+       ugc job get <id> [--json]               show a job and its next task
+       ugc job requeue <id> [--stage=STEP] [--json]
+                                               enqueue a job's task; a failed job is reset
+                                               to STEP first (default: where it failed)
+       ugc job fail <id> --reason=TEXT [--json]
+                                               mark a running job failed
+       ugc migrate status [--json]             list migrations and whether they are applied
+
+STEP is one of: analyze_concept, generate_music, select_song, generate_image, process_video
+`
+
+// errUsage marks invalid command-line arguments; the usage is printed with it.
+var errUsage = errors.New("invalid arguments")
+
+// runCommand runs an operator subcommand with the service's configuration and
+// returns the process exit code.
+func runCommand(args []string) int {
+	var run func(ctx context.Context, env *cliEnv, args []string) error
+	switch strings.Join(args[:min(len(args), 2)], " ") {
+	case "job get":
+		run = runJobGet
+	case "job requeue":
+		run = runJobRequeue
+	case "job fail":
+		run = runJobFail
+	case "migrate status":
+		run = runMigrateStatus
+	default:
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cliTimeout)
+	defer cancel()
+
+	env, err := newCLIEnv(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer env.Close()
+
+	if err := run(ctx, env, args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(os.Stderr, cliUsage)
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// cliEnv holds the dependencies of the operator commands. Unlike newComponents it
+// does not run migrations, so `migrate status` reports the database as it is.
+type cliEnv struct {
+	db          *database.DB
+	jobRepo     repository.JobRepository
+	jobService  service.JobService
+	asynqClient *asynq.Client
+	inspector   worker.QueueInspector
+	logger      *zap.Logger
+	out         io.Writer
+}
+
+// newCLIEnv loads the configuration and connects to the database and Redis.
+func newCLIEnv(ctx context.Context) (*cliEnv, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Only warnings reach stderr; stdout is kept for command output
+	zapConfig := zap.NewDevelopmentConfig()
+	zapConfig.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
+	logger, err := zapConfig.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup logger: %w", err)
+	}
+
+	env := &cliEnv{logger: logger, out: os.Stdout}
+	if env.db, err = connectDatabase(ctx, cfg, logger); err != nil {
+		return nil, err
+	}
+
+	redisOpt, err := asynq.ParseRedisURI(cfg.Redis.URL)
+	if err != nil {
+		env.Close()
+		return nil, fmt.Errorf("failed to parse redis URL: %w", err)
+	}
+	env.asynqClient = asynq.NewClient(redisOpt)
+	if env.inspector, err = worker.NewQueueInspector(cfg.Redis.URL); err != nil {
+		env.Close()
+		return nil, fmt.Errorf("failed to create queue inspector: %w", err)
+	}
+
+	// Failing a job notifies the user's webhooks like any other failure
+	env.jobRepo = repository.NewJobRepository(env.db)
+	userRepo := repository.NewUserRepository(env.db)
+	outbox := worker.NewOutbox(env.asynqClient, repository.NewPendingTaskRepository(env.db), env.jobRepo, logger)
+	notifier := worker.NewJobNotifier(env.jobRepo, userRepo, repository.NewUserWebhookRepository(env.db), outbox, logger)
+	env.jobService = service.NewJobService(env.jobRepo, repository.NewOrganizationRepository(env.db), repository.NewUserSpendRepository(env.db), notifier, cfg.Pipeline.MaxConceptLength, logger)
+
+	return env, nil
+}
+
+// Close releases the clients and the database pool.
+func (e *cliEnv) Close() {
+	if e.inspector != nil {
+		e.inspector.Close()
+	}
+	if e.asynqClient != nil {
+		e.asynqClient.Close()
+	}
+	if e.db != nil {
+		e.db.Close()
+	}
+	e.logger.Sync()
+}
+
+// parseJobArgs parses a job command's flags and its single job ID argument.
+// Flags may come before or after the ID.
+func parseJobArgs(fs *flag.FlagSet, args []string) (uuid.UUID, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if fs.NArg() == 0 {
+		return uuid.Nil, fmt.Errorf("%w: missing job ID", errUsage)
+	}
+	idArg := fs.Arg(0)
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if fs.NArg() > 0 {
+		return uuid.Nil, fmt.Errorf("%w: unexpected argument %q", errUsage, fs.Arg(0))
+	}
+
+	id, err := uuid.Parse(idArg)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: invalid job ID %q", errUsage, idArg)
+	}
+	return id, nil
+}
+
+// nextTaskInfo describes the task that moves a running job on.
+type nextTaskInfo struct {
+	Type   string `json:"type"`
+	TaskID string `json:"task_id"`
+	Queued bool   `json:"queued"`
+}
+
+// jobGetOutput is the JSON output of `job get`.
+type jobGetOutput struct {
+	Job       *models.Job   `json:"job"`
+	RetryFrom *string       `json:"retry_from,omitempty"`
+	NextTask  *nextTaskInfo `json:"next_task,omitempty"`
+}
+
+// runJobGet prints a job and, for running jobs, whether its next task is queued.
+func runJobGet(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("job get", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	id, err := parseJobArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	job, err := env.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	output := jobGetOutput{Job: job, RetryFrom: job.RetryFrom}
+	if point, ok := tasks.ResumePointFor(job); ok && !job.IsTerminal() {
+		queued, err := env.inspector.TaskExists(ctx, point.TaskIDs...)
+		if err != nil {
+			return err
+		}
+		output.NextTask = &nextTaskInfo{Type: point.TaskType, TaskID: point.TaskIDs[0], Queued: queued}
+	}
+
+	if *asJSON {
+		return env.printJSON(output)
+	}
+
+	rows := [][2]string{
+		{"ID", job.ID.String()},
+		{"User", job.UserID.String()},
+		{"Status", job.Status},
+		{"Concept", truncate(job.Concept, 60)},
+		{"Created", formatTime(&job.CreatedAt)},
+		{"Updated", formatTime(&job.UpdatedAt)},
+		{"Suno task", deref(job.SunoTaskID)},
+		{"Nano task", deref(job.NanoTaskID)},
+		{"Video key", deref(job.VideoKey)},
+		{"Error", deref(job.ErrorMessage)},
+		{"Error code", deref(job.ErrorCode)},
+		{"Retry from", deref(job.RetryFrom)},
+		{"Cancelled", formatTime(job.CancelledAt)},
+		{"Deleted", formatTime(job.DeletedAt)},
+	}
+	if output.NextTask != nil {
+		state := "MISSING"
+		if output.NextTask.Queued {
+			state = "queued"
+		}
+		rows = append(rows, [2]string{"Next task", fmt.Sprintf("%s (%s, %s)", output.NextTask.Type, output.NextTask.TaskID, state)})
+	}
+	return env.printRows(rows)
+}
+
+// jobRequeueOutput is the JSON output of `job requeue`.
+type jobRequeueOutput struct {
+	JobID         uuid.UUID `json:"job_id"`
+	Reset         bool      `json:"reset"`
+	TaskType      string    `json:"task_type"`
+	TaskID        string    `json:"task_id"`
+	AlreadyQueued bool      `json:"already_queued"`
+	TraceID       string    `json:"trace_id"`
+}
+
+// runJobRequeue enqueues a job's task with the TaskID the pipeline uses, so it
+// cannot double-enqueue. A failed job is first reset for retry, as POST /jobs/:id/retry
+// does; a running job gets its next task, which --stage may name but not change.
+func runJobRequeue(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("job requeue", flag.ContinueOnError)
+	stage := fs.String("stage", "", "step to run (default: where a failed job failed, or a running job's next task)")
+	asJSON := fs.Bool("json", false, "print JSON")
+	id, err := parseJobArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *stage != "" && models.RetryStatus(*stage) == "" {
+		return fmt.Errorf("%w: unknown stage %q", errUsage, *stage)
+	}
+
+	job, err := env.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	switch {
+	case job.CancelledAt != nil:
+		return errors.New("job was cancelled")
+	case job.IsDeleted():
+		return errors.New("job is deleted")
+	case job.Status == models.StatusCompleted:
+		return errors.New("job is completed")
+	}
+
+	output := jobRequeueOutput{JobID: id, TraceID: "cli-" + uuid.NewString()}
+
+	var task *asynq.Task
+	switch {
+	case job.Status == models.StatusFailed:
+		step := *stage
+		if step == "" {
+			step = job.RetryStep()
+		}
+		if err := env.jobRepo.ResetForRetry(ctx, id, step, "", ""); err != nil {
+			return fmt.Errorf("failed to reset job for retry: %w", err)
+		}
+		output.Reset = true
+		task, err = worker.NewRetryTask(step, id, output.TraceID)
+	case *stage != "":
+		// A running job only reruns the step it is at: its finished tasks no longer
+		// hold their TaskIDs, so any other step would call the providers again or
+		// fail a healthy job
+		point, _ := tasks.ResumePointFor(job)
+		task, err = worker.NewRetryTask(*stage, id, output.TraceID)
+		if err == nil && task.Type() != point.TaskType {
+			return fmt.Errorf("job is %s and continues with %s; --stage=%s only applies to failed jobs or to that step",
+				job.Status, point.TaskType, *stage)
+		}
+	default:
+		point, _ := tasks.ResumePointFor(job)
+		task, err = point.Task(id, output.TraceID)
+		output.TaskID = point.TaskIDs[0]
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+	output.TaskType = task.Type()
+	if output.TaskID == "" {
+		output.TaskID = tasks.DedupTaskID(task.Type(), id)
+	}
+
+	// If this fails after a reset, the job reconciler enqueues the task later
+	if _, err := env.asynqClient.EnqueueContext(ctx, task); err != nil {
+		if !errors.Is(err, asynq.ErrTaskIDConflict) {
+			return fmt.Errorf("failed to enqueue task: %w", err)
+		}
+		// The task is still queued; it picks up the job as it is now
+		output.AlreadyQueued = true
+	}
+
+	if *asJSON {
+		return env.printJSON(output)
+	}
+	state := "enqueued"
+	if output.AlreadyQueued {
+		state = "already queued"
+	}
+	return env.printRows([][2]string{
+		{"Job", id.String()},
+		{"Reset for retry", fmt.Sprint(output.Reset)},
+		{"Task", output.TaskType},
+		{"Task ID", output.TaskID},
+		{"Result", state},
+		{"Trace ID", output.TraceID},
+	})
+}
+
+// jobFailOutput is the JSON output of `job fail`.
+type jobFailOutput struct {
+	JobID  uuid.UUID `json:"job_id"`
+	Status string    `json:"status"`
+	Reason string    `json:"reason"`
+}
+
+// runJobFail marks a running job failed through the job service, so the user's
+// webhooks are notified as for any other failure.
+func runJobFail(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("job fail", flag.ContinueOnError)
+	reason := fs.String("reason", "", "error message shown to the user")
+	asJSON := fs.Bool("json", false, "print JSON")
+	id, err := parseJobArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(*reason) == "" {
+		return fmt.Errorf("%w: --reason is required", errUsage)
+	}
+
+	job, err := env.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.IsTerminal() {
+		return fmt.Errorf("job is already %s", job.Status)
+	}
+
+	if err := env.jobService.MarkFailure(ctx, id, models.JobFailure{Message: *reason}); err != nil {
+		return err
+	}
+
+	output := jobFailOutput{JobID: id, Status: models.StatusFailed, Reason: *reason}
+	if *asJSON {
+		return env.printJSON(output)
+	}
+	return env.printRows([][2]string{
+		{"Job", id.String()},
+		{"Status", output.Status},
+		{"Reason", output.Reason},
+	})
+}
+
+// runMigrateStatus lists the embedded migrations and whether each is applied.
+func runMigrateStatus(ctx context.Context, env *cliEnv, args []string) error {
+	fs := flag.NewFlagSet("migrate status", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	statuses, err := database.NewMigrator(env.db, env.logger).Status(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get migration status: %w", err)
+	}
+
+	if *asJSON {
+		return env.printJSON(statuses)
+	}
+
+	w := tabwriter.NewWriter(env.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tAPPLIED")
+	pending := 0
+	for _, status := range statuses {
+		applied := "yes"
+		if !status.Applied {
+			applied = "no"
+			pending++
+		}
+		fmt.Fprintf(w, "%s\t%s\n", status.Name, applied)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(env.out, "\n%d of %d applied, %d pending\n", len(statuses)-pending, len(statuses), pending)
+	return err
+}
+
+// printJSON writes v as indented JSON.
+func (e *cliEnv) printJSON(v any) error {
+	encoder := json.NewEncoder(e.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printRows writes label/value pairs as an aligned table, skipping empty values.
+func (e *cliEnv) printRows(rows [][2]string) error {
+	w := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		if row[1] != "" {
+			fmt.Fprintf(w, "%s:\t%s\n", row[0], row[1])
+		}
+	}
+	return w.Flush()
+}
+
+// deref returns the value of s, or "" when nil.
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// formatTime formats t in UTC, or returns "" when nil.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// requeueJobRepo holds one job and records the steps it is reset for retry from.
+type requeueJobRepo struct {
+	repository.JobRepository
+	job    models.Job
+	resets []string
+}
+
+func (r *requeueJobRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	if id != r.job.ID {
+		return nil, repository.ErrJobNotFound
+	}
+	job := r.job
+	return &job, nil
+}
+
+func (r *requeueJobRepo) ResetForRetry(ctx context.Context, id uuid.UUID, step, openRouterKeySource, kieKeySource string) error {
+	r.resets = append(r.resets, step)
+	return nil
+}
+
+// TestJobRequeueStage checks that --stage reruns a step of a running job only
+// when it is the step the job is at, so a requeue cannot repeat a paid call.
+func TestJobRequeueStage(t *testing.T) {
+	sunoTaskID := "suno-task-1"
+	prompt := &models.SongPrompt{Prompt: "[Verse]\nแสงไฟ"}
+
+	tests := []struct {
+		name      string
+		job       models.Job
+		stage     string
+		wantErr   string // Empty when the task is enqueued
+		wantType  string
+		wantReset bool
+	}{
+		{name: "music already generating", job: models.Job{Status: models.StatusGeneratingMusic, SunoTaskID: &sunoTaskID},
+			stage: models.RetryFromMusic, wantErr: "continues with " + tasks.TypePollMusicStatus},
+		{name: "earlier step", job: models.Job{Status: models.StatusSelectingSong},
+			stage: models.RetryFromAnalyze, wantErr: "continues with " + tasks.TypeSelectSong},
+		{name: "later step", job: models.Job{Status: models.StatusAnalyzing},
+			stage: models.RetryFromImage, wantErr: "continues with " + tasks.TypeAnalyzeConcept},
+		{name: "music not started", job: models.Job{Status: models.StatusGeneratingMusic},
+			stage: models.RetryFromMusic, wantType: tasks.TypeGenerateMusic},
+		{name: "analyzed", job: models.Job{Status: models.StatusAnalyzing, SongPrompt: prompt},
+			stage: models.RetryFromMusic, wantType: tasks.TypeGenerateMusic},
+		{name: "running job without a stage", job: models.Job{Status: models.StatusSelectingSong},
+			wantType: tasks.TypeSelectSong},
+		{name: "failed job from any step", job: models.Job{Status: models.StatusFailed},
+			stage: models.RetryFromMusic, wantType: tasks.TypeGenerateMusic, wantReset: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, redisURL := testutil.NewAsynqClient(t)
+			opt, err := asynq.ParseRedisURI(redisURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inspector := asynq.NewInspector(opt)
+			t.Cleanup(func() { inspector.Close() })
+
+			repo := &requeueJobRepo{job: tt.job}
+			repo.job.ID = uuid.New()
+			var out bytes.Buffer
+			env := &cliEnv{jobRepo: repo, asynqClient: client, out: &out}
+
+			args := []string{repo.job.ID.String()}
+			if tt.stage != "" {
+				args = append([]string{"--stage=" + tt.stage}, args...)
+			}
+			err = runJobRequeue(context.Background(), env, args)
+
+			queued, _ := inspector.ListPendingTasks("default")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("runJobRequeue() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(queued) != 0 || len(repo.resets) != 0 {
+					t.Errorf("rejected requeue enqueued %d tasks and reset %v", len(queued), repo.resets)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("runJobRequeue() error = %v", err)
+			}
+			if len(queued) != 1 || queued[0].Type != tt.wantType || queued[0].ID != tasks.DedupTaskID(tt.wantType, repo.job.ID) {
+				t.Errorf("queued %+v, want one deduplicated %s task", queued, tt.wantType)
+			}
+			if (len(repo.resets) > 0) != tt.wantReset {
+				t.Errorf("reset for retry %v, want reset %v", repo.resets, tt.wantReset)
+			}
+		})
+	}
+}
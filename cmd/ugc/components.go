@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/config"
+	"github.com/jaochai/ugc/internal/database"
+	"github.com/jaochai/ugc/internal/email"
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/external/youtube"
+	"github.com/jaochai/ugc/internal/ffmpeg"
+	"github.com/jaochai/ugc/internal/handler"
+	"github.com/jaochai/ugc/internal/metrics"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/security"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/worker"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// components holds the dependencies shared by the API server and the worker.
+type components struct {
+	db *database.DB
+
+	userRepo          repository.UserRepository
+	jobRepo           repository.JobRepository
+	systemPromptRepo  repository.SystemPromptRepository
+	pendingTaskRepo   repository.PendingTaskRepository
+	passwordResetRepo repository.PasswordResetRepository
+	refreshTokenRepo  repository.RefreshTokenRepository
+	userWebhookRepo   repository.UserWebhookRepository
+	userSpendRepo     repository.UserSpendRepository
+	orgRepo           repository.OrganizationRepository
+
+	r2Client        *r2.Client
+	youtubeClient   *youtube.Client
+	cryptoService   service.CryptoService
+	authService     service.AuthService
+	jobService      service.JobService
+	templateService service.JobTemplateService
+	keyService      service.ProviderKeyService
+	orgService      service.OrganizationService
+	settingsService service.RuntimeSettingsService
+	ffmpegProcessor *ffmpeg.Processor
+	asynqClient     *asynq.Client
+	queueInspector  worker.QueueInspector
+	redisClient     *redis.Client
+	workerRegistry  worker.InstanceRegistry // nil without Redis
+	metrics         *metrics.Metrics
+	outbox          *worker.Outbox
+	mailer          email.Mailer
+	jobNotifier     *worker.JobNotifier
+}
+
+// newComponents connects to the database, runs migrations and creates the
+// clients, repositories and services used by every run mode.
+func newComponents(ctx context.Context, cfg *config.Config, logger *zap.Logger) (*components, error) {
+	c := &components{}
+
+	// Connect to database
+	db, err := connectDatabase(ctx, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	c.db = db
+	logger.Info("connected to database")
+
+	// Run migrations
+	if err := database.RunMigrations(ctx, db); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+	logger.Info("database migrations completed")
+
+	// Create repositories
+	c.userRepo = repository.NewUserRepository(db)
+	c.jobRepo = repository.NewJobRepository(db)
+	c.userSpendRepo = repository.NewUserSpendRepository(db)
+	c.systemPromptRepo = repository.NewCachedSystemPromptRepository(
+		repository.NewSystemPromptRepository(db), cfg.Pipeline.SystemPromptCacheTTL)
+	c.pendingTaskRepo = repository.NewPendingTaskRepository(db)
+	c.passwordResetRepo = repository.NewPasswordResetRepository(db)
+	c.userWebhookRepo = repository.NewUserWebhookRepository(db)
+	c.refreshTokenRepo = repository.NewRefreshTokenRepository(db)
+	c.orgRepo = repository.NewOrganizationRepository(db)
+
+	// Note: OpenRouter/KIE clients are now created per-user in worker tasks
+	// using encrypted API keys from the database
+
+	// Create R2 client (optional - skip if not configured)
+	if cfg.R2.AccountID != "" {
+		r2Client, err := r2.NewClient(ctx, r2.Config{
+			AccountID:       cfg.R2.AccountID,
+			AccessKeyID:     cfg.R2.AccessKeyID,
+			SecretAccessKey: cfg.R2.SecretAccessKey,
+			BucketName:      cfg.R2.BucketName,
+			PublicURL:       cfg.R2.PublicURL,
+		})
+		if err != nil {
+			logger.Warn("failed to create R2 client - video uploads will be disabled", zap.Error(err))
+		} else {
+			c.r2Client = r2Client
+			logger.Info("R2 client initialized")
+		}
+	} else {
+		logger.Warn("R2 not configured - video uploads will be disabled")
+	}
+
+	// Create YouTube client (optional - skip if not configured)
+	if cfg.YouTube.ClientID != "" && cfg.YouTube.ClientSecret != "" {
+		c.youtubeClient = youtube.NewClient(cfg.YouTube.ClientID, cfg.YouTube.ClientSecret, cfg.YouTube.RedirectURI, logger)
+		logger.Info("YouTube client initialized")
+	} else {
+		logger.Warn("YouTube not configured - YouTube uploads will be disabled")
+	}
+
+	// Create crypto service (required for API keys encryption)
+	c.cryptoService, err = service.NewCryptoService(cfg.Crypto.EncryptionKey, cfg.Crypto.LegacyKeys...)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to create crypto service: %w", err)
+	}
+	logger.Info("crypto service initialized")
+
+	// Create mailer; without SMTP_HOST emails are only logged
+	c.mailer = email.New(email.SMTPConfig{
+		Host:     cfg.SMTP.Host,
+		Port:     cfg.SMTP.Port,
+		Username: cfg.SMTP.Username,
+		Password: cfg.SMTP.Password,
+		From:     cfg.SMTP.From,
+	}, logger)
+
+	// Create services
+	c.templateService = service.NewJobTemplateService(repository.NewJobTemplateRepository(db), logger)
+	c.settingsService = service.NewRuntimeSettingsService(repository.NewCachedRuntimeSettingRepository(
+		repository.NewRuntimeSettingRepository(db), repository.DefaultRuntimeSettingsCacheTTL), logger)
+	c.keyService = service.NewProviderKeyService(repository.NewPlatformQuotaRepository(db), c.orgRepo, service.ProviderKeyConfig{
+		AllowPlatformOpenRouterKey: cfg.OpenRouter.AllowPlatformKey,
+		PlatformDailyJobs:          cfg.OpenRouter.PlatformDailyJobs,
+		AllowPlatformKIEKey:        cfg.KIE.AllowPlatformKey,
+		PlatformMonthlyKIECredits:  cfg.KIE.PlatformMonthlyCredits,
+		JobKIECredits:              cfg.KIE.MusicCreditCost + cfg.KIE.ImageCreditCost,
+	}, logger)
+
+	// Create FFmpeg processor
+	c.ffmpegProcessor = ffmpeg.NewProcessor(cfg.Worker.FFmpegMaxConcurrent, logger)
+
+	// Create Asynq client
+	redisOpt, err := asynq.ParseRedisURI(cfg.Redis.URL)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to parse redis URL: %w", err)
+	}
+	c.asynqClient = asynq.NewClient(redisOpt)
+	logger.Info("asynq client initialized")
+
+	// Create queue inspector for the admin queue and task endpoints
+	c.queueInspector, err = worker.NewQueueInspector(cfg.Redis.URL)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to create queue inspector: %w", err)
+	}
+
+	// Create Redis client for rate limiting and health checks (optional - may be nil if Redis URL is empty)
+	if cfg.Redis.URL != "" {
+		opt, err := redis.ParseURL(cfg.Redis.URL)
+		if err != nil {
+			logger.Warn("failed to parse redis URL for rate limiting, rate limiting will be disabled",
+				zap.Error(err),
+			)
+		} else {
+			c.redisClient = redis.NewClient(opt)
+			logger.Info("redis client initialized for rate limiting")
+		}
+	}
+	if c.redisClient != nil {
+		c.workerRegistry = worker.NewRedisInstanceRegistry(c.redisClient, "ugc")
+	}
+
+	// Failed logins are counted in Redis; without it the lockout is disabled
+	var loginLimiter service.LoginLimiter
+	if c.redisClient != nil {
+		loginLimiter = service.NewRedisLoginLimiter(c.redisClient, "ugc", service.LoginLockoutConfig{
+			MaxFailures: cfg.Auth.MaxLoginFailures,
+			Window:      cfg.Auth.LoginFailureWindow,
+			Duration:    cfg.Auth.LockoutDuration,
+		})
+	}
+	c.orgService = service.NewOrganizationService(c.orgRepo, c.cryptoService, c.mailer, cfg.FrontendURL, logger)
+	c.authService = service.NewAuthService(c.userRepo, c.passwordResetRepo, c.refreshTokenRepo, c.mailer, loginLimiter, service.AuthConfig{
+		JWTSecret:      cfg.JWT.Secret,
+		AccessExpiry:   cfg.JWT.AccessExpiry,
+		RefreshExpiry:  cfg.JWT.RefreshExpiry,
+		FrontendURL:    cfg.FrontendURL,
+		EmailBlocklist: security.NewEmailDomainBlocklist(cfg.Auth.DisposableEmailDomains),
+	}, logger)
+
+	// Create Prometheus metrics (optional)
+	if cfg.Metrics.Enabled {
+		c.metrics = metrics.New(prometheus.NewRegistry())
+		logger.Info("metrics enabled", zap.Bool("basic_auth", cfg.Metrics.Username != ""))
+	}
+
+	// Create task outbox; API handlers write to it and the worker drains it
+	c.outbox = worker.NewOutbox(c.asynqClient, c.pendingTaskRepo, c.jobRepo, logger)
+
+	// Job service and worker notify user webhooks through the outbox when jobs finish
+	c.jobNotifier = worker.NewJobNotifier(c.jobRepo, c.userRepo, c.userWebhookRepo, c.outbox, logger)
+	c.jobService = service.NewJobService(c.jobRepo, c.orgRepo, c.userSpendRepo, c.jobNotifier, cfg.Pipeline.MaxConceptLength, logger)
+
+	return c, nil
+}
+
+// connectDatabase opens the database pool configured in cfg.
+func connectDatabase(ctx context.Context, cfg *config.Config, logger *zap.Logger) (*database.DB, error) {
+	poolConfig := database.DefaultPoolConfig()
+	poolConfig.MaxConns = int32(cfg.Database.MaxConns)
+	poolConfig.MinConns = int32(cfg.Database.MinConns)
+	poolConfig.MaxConnLifetime = cfg.Database.MaxConnLifetime
+	poolConfig.AcquireTimeout = cfg.Database.AcquireTimeout
+	poolConfig.SlowQueryThreshold = cfg.Database.SlowQueryThreshold
+	db, err := database.NewWithConfig(ctx, cfg.Database.URL, poolConfig, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return db, nil
+}
+
+// Close releases the clients and the database pool. It is safe to call on a
+// partially initialized components value.
+func (c *components) Close() {
+	if c.redisClient != nil {
+		c.redisClient.Close()
+	}
+	if c.asynqClient != nil {
+		c.asynqClient.Close()
+	}
+	if c.queueInspector != nil {
+		c.queueInspector.Close()
+	}
+	if c.db != nil {
+		c.db.Close()
+	}
+}
+
+// newWorker creates the asynq worker wired to the shared components.
+func newWorker(cfg *config.Config, c *components, instance *worker.Instance, logger *zap.Logger) (*worker.Worker, error) {
+	// Task logs carry the instance ID so they can be matched to GET /admin/workers
+	logger = logger.With(zap.String("worker_id", instance.ID))
+
+	return worker.NewWorker(cfg.Redis.URL, cfg.Worker.Concurrency, newTaskDependencies(cfg, c, instance.ID, logger), logger)
+}
+
+// newTaskDependencies wires the task handlers to the shared components. workerID
+// is recorded with the stage timings of the tasks they run.
+func newTaskDependencies(cfg *config.Config, c *components, workerID string, logger *zap.Logger) *tasks.Dependencies {
+	workerDeps := &tasks.Dependencies{
+		JobRepo:           c.jobRepo,
+		UserRepo:          c.userRepo,
+		SystemPromptRepo:  c.systemPromptRepo,
+		CryptoService:     c.cryptoService,
+		R2Client:          c.r2Client,
+		FFmpegProcessor:   c.ffmpegProcessor,
+		YouTubeClient:     c.youtubeClient,
+		AsynqClient:       c.asynqClient,
+		Logger:            logger,
+		WorkerID:          workerID,
+		WebhookBaseURL:    cfg.Webhook.BaseURL,
+		WebhookSecret:     cfg.Webhook.Secret,
+		KIEBaseURL:        cfg.KIE.BaseURL,
+		OpenRouterBaseURL: cfg.OpenRouter.BaseURL,
+		ImageCandidates:   cfg.Pipeline.ImageCandidates,
+		VideoPreset:       cfg.Pipeline.VideoPreset,
+		Metrics:           c.metrics,
+		UserWebhookRepo:   c.userWebhookRepo,
+		JobNotifier:       c.jobNotifier,
+		Mailer:            c.mailer,
+
+		PlatformOpenRouterKey: platformOpenRouterKey(cfg),
+		PlatformKIEKey:        platformKIEKey(cfg),
+		VideoSegmentThreshold: cfg.Worker.VideoSegmentThreshold,
+		VideoSegmentLength:    cfg.Worker.VideoSegmentLength,
+		VideoWorkDir:          cfg.Worker.VideoWorkDir,
+		VideoMaxAudioBytes:    cfg.Worker.VideoMaxAudioBytes,
+		VideoMaxImageBytes:    cfg.Worker.VideoMaxImageBytes,
+		SpendRepo:             c.userSpendRepo,
+		OrganizationRepo:      c.orgRepo,
+		MusicCreditCost:       cfg.KIE.MusicCreditCost,
+		ImageCreditCost:       cfg.KIE.ImageCreditCost,
+
+		// Deferred webhook callbacks are re-applied with the same logic as the HTTP handler
+		WebhookReprocessor: handler.NewWebhookProcessor(c.jobRepo, repository.NewWebhookEventRepository(c.db), c.jobService,
+			c.asynqClient, c.outbox, security.NewURLValidator(cfg.Webhook.AllowedHosts), cfg.Pipeline.SunoCompleteGrace, logger),
+		AudioURLValidator: security.NewURLValidator(cfg.Webhook.AllowedHosts),
+	}
+	// Provider errors feed GET /api/v1/status
+	if c.redisClient != nil {
+		workerDeps.ProviderStats = service.NewRedisProviderStats(c.redisClient, "ugc")
+	}
+
+	return workerDeps
+}
+
+// platformOpenRouterKey returns the OpenRouter key used for users without their
+// own, or "" when the platform key fallback is off.
+func platformOpenRouterKey(cfg *config.Config) string {
+	if !cfg.OpenRouter.AllowPlatformKey {
+		return ""
+	}
+	return cfg.OpenRouter.APIKey
+}
+
+// platformKIEKey returns the KIE key used for users without their own, or ""
+// when the platform key fallback is off.
+func platformKIEKey(cfg *config.Config) string {
+	if !cfg.KIE.AllowPlatformKey {
+		return ""
+	}
+	return cfg.KIE.APIKey
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -19,8 +21,8 @@ import (
 	"github.com/jaochai/ugc/internal/database"
 	"github.com/jaochai/ugc/internal/external/r2"
 	"github.com/jaochai/ugc/internal/external/youtube"
-	"github.com/jaochai/ugc/internal/ffmpeg"
 	"github.com/jaochai/ugc/internal/handler"
+	"github.com/jaochai/ugc/internal/metrics"
 	"github.com/jaochai/ugc/internal/middleware"
 	"github.com/jaochai/ugc/internal/repository"
 	"github.com/jaochai/ugc/internal/security"
@@ -28,13 +30,69 @@ import (
 	"github.com/jaochai/ugc/internal/worker"
 )
 
+// version identifies the build in worker registrations; set with -ldflags "-X main.version=...".
+var version = "dev"
+
+// jobStatusMetricsInterval is how often the jobs-by-status gauge is refreshed.
+const jobStatusMetricsInterval = 30 * time.Second
+
+// outboxDrainInterval is how often tasks stored in the outbox are retried.
+const outboxDrainInterval = 5 * time.Second
+
+// jobReconcileInterval is how often jobs that lost their next task are re-enqueued.
+const jobReconcileInterval = time.Minute
+
+// webhookEventCleanupInterval is how often captured webhook callbacks past retention are deleted.
+const webhookEventCleanupInterval = time.Hour
+
+// r2CleanupInterval is how often R2 is scanned for assets of deleted and long-failed jobs.
+const r2CleanupInterval = 6 * time.Hour
+
+// deletedJobPurgeInterval is how often jobs past their restore window are purged.
+const deletedJobPurgeInterval = time.Hour
+
+// deletedUserSweepInterval is how often accounts whose data cleanup did not finish are re-enqueued.
+const deletedUserSweepInterval = 30 * time.Minute
+
+// jobScheduleInterval is how often due job schedules are turned into jobs.
+const jobScheduleInterval = time.Minute
+
+// shareRequestsPerMinute is how many public share lookups one client IP may make per minute.
+const shareRequestsPerMinute = 30
+
+// concurrentExportsPerUser is how many job zip exports one user may download at once.
+const concurrentExportsPerUser = 2
+
+// Regenerates the committed docs/swagger.json from the handler annotations; served at /api/v1/openapi.json.
+//go:generate go run github.com/swaggo/swag/cmd/swag@v1.16.4 init --dir ../.. --generalInfo cmd/ugc/main.go --output ../../docs --outputTypes json --parseInternal
+
+// @title UGC Music Video API
+// @version 1.0
+// @description Turns a song concept into a music video: Suno music, a generated or uploaded cover image and an FFmpeg render, optionally uploaded to YouTube.
+// @BasePath /api/v1
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description "Bearer " followed by the access token from /auth/login
 func main() {
+	mode := flag.String("mode", "", "components to run: api, worker or all (overrides SERVER_MODE)")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, cliUsage) }
+	flag.Parse()
+
+	// Operator subcommands, e.g. `ugc job get <id>`
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
 		os.Exit(1)
 	}
+	if *mode != "" {
+		cfg.Server.Mode = strings.ToLower(strings.TrimSpace(*mode))
+	}
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
@@ -48,180 +106,162 @@ func main() {
 		fmt.Fprintf(os.Stderr, "failed to setup logger: %v\n", err)
 		os.Exit(1)
 	}
-	defer logger.Sync()
 
 	logger.Info("starting UGC service",
 		zap.String("env", cfg.Server.Env),
+		zap.String("mode", cfg.Server.Mode),
 		zap.String("port", cfg.Server.Port),
 	)
 
-	// Create context for setup
-	ctx := context.Background()
+	// Shut down gracefully on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	err = run(ctx, cfg, logger)
+	stop()
+	if err != nil {
+		logger.Error("service stopped with error", zap.Error(err))
+		_ = logger.Sync()
+		os.Exit(1)
+	}
+	_ = logger.Sync()
+}
 
-	// Connect to database
-	db, err := database.New(ctx, cfg.Database.URL)
+// run starts the components cfg enables and blocks until ctx is done or one of
+// them fails, then shuts them down. The HTTP listener only opens once migrations
+// have run and, when this process runs the worker, the worker is fetching tasks,
+// so no job is accepted that nothing will pick up; /health/ready answers 503
+// until then and again once shutdown begins. A failure to start or a worker that
+// loses Redis for good is returned rather than only logged, so the process exits
+// and its supervisor restarts it.
+func run(ctx context.Context, cfg *config.Config, logger *zap.Logger) error {
+	// Cancelled on shutdown to stop background loops
+	ctx, cancelBackground := context.WithCancel(ctx)
+	defer cancelBackground()
+
+	// Connects to the database and runs migrations
+	deps, err := newComponents(ctx, cfg, logger)
 	if err != nil {
-		logger.Fatal("failed to connect to database", zap.Error(err))
+		return fmt.Errorf("failed to initialize dependencies: %w", err)
 	}
-	defer db.Close()
-	logger.Info("connected to database")
+	defer deps.Close()
 
-	// Run migrations
-	if err := database.RunMigrations(ctx, db); err != nil {
-		logger.Fatal("failed to run migrations", zap.Error(err))
+	proc := &process{
+		drainTimeout: cfg.Worker.DrainTimeout,
+		startup:      &handler.StartupState{},
+		logger:       logger,
 	}
-	logger.Info("database migrations completed")
-
-	// Create repositories
-	userRepo := repository.NewUserRepository(db)
-	jobRepo := repository.NewJobRepository(db)
-	systemPromptRepo := repository.NewSystemPromptRepository(db)
-
-	// Note: OpenRouter/KIE clients are now created per-user in worker tasks
-	// using encrypted API keys from the database
-
-	// Create R2 client (optional - skip if not configured)
-	var r2Client *r2.Client
-	if cfg.R2.AccountID != "" {
-		r2Client, err = r2.NewClient(ctx, r2.Config{
-			AccountID:       cfg.R2.AccountID,
-			AccessKeyID:     cfg.R2.AccessKeyID,
-			SecretAccessKey: cfg.R2.SecretAccessKey,
-			BucketName:      cfg.R2.BucketName,
-			PublicURL:       cfg.R2.PublicURL,
-		})
-		if err != nil {
-			logger.Warn("failed to create R2 client - video uploads will be disabled", zap.Error(err))
-		} else {
-			logger.Info("R2 client initialized")
+
+	if cfg.RunsAPI() {
+		// Refresh the jobs-by-status gauge served on /metrics
+		if deps.metrics != nil {
+			go deps.metrics.RunJobStatusCollector(ctx, deps.jobRepo, jobStatusMetricsInterval, logger)
 		}
-	} else {
-		logger.Warn("R2 not configured - video uploads will be disabled")
-	}
 
-	// Create YouTube client (optional - skip if not configured)
-	var youtubeClient *youtube.Client
-	if cfg.YouTube.ClientID != "" && cfg.YouTube.ClientSecret != "" {
-		youtubeClient = youtube.NewClient(cfg.YouTube.ClientID, cfg.YouTube.ClientSecret, cfg.YouTube.RedirectURI, logger)
-		logger.Info("YouTube client initialized")
-	} else {
-		logger.Warn("YouTube not configured - YouTube uploads will be disabled")
-	}
+		// POST /jobs?sync=true runs the task handlers inside the request; config refuses it in production
+		var syncRunner *worker.SyncRunner
+		if cfg.Pipeline.SyncJobsEnabled {
+			logger.Warn("synchronous jobs enabled: POST /api/v1/jobs?sync=true runs the pipeline inline")
+			syncRunner = worker.NewSyncRunner(newTaskDependencies(cfg, deps, "api-sync", logger), logger)
+		}
 
-	// Create crypto service (required for API keys encryption)
-	cryptoService, err := service.NewCryptoService(cfg.Crypto.EncryptionKey)
-	if err != nil {
-		logger.Fatal("failed to create crypto service", zap.Error(err))
+		router := setupRouter(cfg, deps.db, deps.authService, deps.jobService, deps.templateService, deps.keyService, deps.orgService, deps.jobRepo, deps.userRepo, deps.systemPromptRepo, deps.cryptoService, deps.r2Client, deps.youtubeClient, deps.asynqClient, deps.queueInspector, deps.workerRegistry, deps.outbox, deps.redisClient, deps.metrics, syncRunner, deps.settingsService, proc.startup, logger)
+		proc.server = newHTTPServer(cfg.Server.Port, router)
 	}
-	logger.Info("crypto service initialized")
-
-	// Create services
-	authService := service.NewAuthService(userRepo, cfg.JWT.Secret, cfg.JWT.Expiry, logger)
-	jobService := service.NewJobService(jobRepo, logger)
 
-	// Create FFmpeg processor
-	ffmpegProcessor := ffmpeg.NewProcessor(logger)
+	if cfg.RunsWorker() {
+		// Probe FFmpeg's encoders once so presets needing libx265 fall back where it is missing
+		if err := deps.ffmpegProcessor.DetectEncoders(ctx); err != nil {
+			logger.Warn("failed to detect ffmpeg encoders, only libx264 presets are available", zap.Error(err))
+		}
 
-	// Create Asynq client
-	redisOpt, err := asynq.ParseRedisURI(cfg.Redis.URL)
-	if err != nil {
-		logger.Fatal("failed to parse redis URL", zap.Error(err))
-	}
-	asynqClient := asynq.NewClient(redisOpt)
-	defer asynqClient.Close()
-	logger.Info("asynq client initialized")
-
-	// Create Redis client for rate limiting (optional - may be nil if Redis URL is empty)
-	var redisClient *redis.Client
-	if cfg.Redis.URL != "" {
-		opt, err := redis.ParseURL(cfg.Redis.URL)
+		instance := worker.NewInstance(version)
+		asynqWorker, err := newWorker(cfg, deps, instance, logger)
 		if err != nil {
-			logger.Warn("failed to parse redis URL for rate limiting, rate limiting will be disabled",
-				zap.Error(err),
-			)
-		} else {
-			redisClient = redis.NewClient(opt)
-			defer redisClient.Close()
-			logger.Info("redis client initialized for rate limiting")
+			return fmt.Errorf("failed to create worker: %w", err)
+		}
+		proc.worker = asynqWorker
+
+		var heartbeat *worker.Heartbeat
+		proc.workerStarted = func(ctx context.Context) {
+			// Drain the task outbox and re-enqueue the next task of jobs that lost it,
+			// e.g. to a crash between insert and enqueue or a Redis flush
+			go deps.outbox.Run(ctx, outboxDrainInterval)
+			reconciler := worker.NewJobReconciler(deps.jobRepo, deps.asynqClient, deps.queueInspector, logger)
+			go reconciler.Run(ctx, jobReconcileInterval)
+			eventCleaner := worker.NewWebhookEventCleaner(repository.NewWebhookEventRepository(deps.db), cfg.Webhook.CaptureRetention, logger)
+			go eventCleaner.Run(ctx, webhookEventCleanupInterval)
+			if cfg.R2.CleanupEnabled && deps.r2Client != nil {
+				assetCleaner := worker.NewR2AssetCleaner(deps.r2Client, deps.jobRepo, worker.R2CleanerConfig{
+					FailedRetention: cfg.R2.CleanupFailedAfter,
+					MaxDeletes:      cfg.R2.CleanupMaxDeletes,
+					DryRun:          cfg.R2.CleanupDryRun,
+					MaxMissingRatio: cfg.R2.CleanupMaxMissingRatio,
+				}, deps.metrics, logger)
+				go assetCleaner.Run(ctx, r2CleanupInterval)
+			}
+			purger := worker.NewDeletedJobPurger(deps.jobRepo, deps.r2Client, logger)
+			go purger.Run(ctx, deletedJobPurgeInterval)
+			userSweeper := worker.NewDeletedUserSweeper(deps.userRepo, deps.outbox, logger)
+			go userSweeper.Run(ctx, deletedUserSweepInterval)
+			scheduler := worker.NewJobScheduler(repository.NewJobScheduleRepository(deps.db), deps.userRepo, deps.templateService,
+				deps.jobService, deps.keyService, deps.settingsService, deps.outbox, deps.redisClient, logger)
+			go scheduler.Run(ctx, jobScheduleInterval)
+
+			// Register the instance for GET /admin/workers; skipped without Redis
+			if deps.workerRegistry != nil {
+				heartbeat = worker.NewHeartbeat(deps.workerRegistry, instance, logger)
+				go heartbeat.Run(ctx, worker.HeartbeatInterval)
+			}
+		}
+		proc.stopped = func(ctx context.Context) {
+			if heartbeat != nil {
+				heartbeat.Deregister(ctx)
+			}
 		}
-	}
 
-	// Create worker dependencies
-	workerDeps := worker.Dependencies{
-		JobRepo:          jobRepo,
-		UserRepo:         userRepo,
-		SystemPromptRepo: systemPromptRepo,
-		CryptoService:    cryptoService,
-		R2Client:         r2Client,
-		FFmpegProcessor:  ffmpegProcessor,
-		YouTubeClient:    youtubeClient,
-		AsynqClient:      asynqClient,
-		Logger:           logger,
-		WebhookBaseURL:   cfg.Webhook.BaseURL,
-		WebhookSecret:    cfg.Webhook.Secret,
-		KIEBaseURL:       cfg.KIE.BaseURL,
+		// Worker-only processes expose just the liveness probe
+		if proc.server == nil {
+			proc.server = newHTTPServer(cfg.Server.WorkerHealthPort, setupWorkerHealthRouter(cfg, deps, logger))
+		}
 	}
 
-	// Create worker
-	asynqWorker, err := worker.NewWorker(cfg.Redis.URL, workerDeps, logger)
-	if err != nil {
-		logger.Fatal("failed to create worker", zap.Error(err))
-	}
+	runErr := proc.run(ctx)
 
-	// Setup Gin router
-	router := setupRouter(cfg, authService, jobService, jobRepo, userRepo, systemPromptRepo, cryptoService, youtubeClient, asynqClient, redisClient, logger)
+	// Close database connection
+	deps.db.Close()
+	logger.Info("database connection closed")
+
+	logger.Info("server shutdown complete")
+	return runErr
+}
 
-	// Create HTTP server
-	srv := &http.Server{
-		Addr:         ":" + cfg.Server.Port,
-		Handler:      router,
+// newHTTPServer creates the HTTP server listening on port.
+func newHTTPServer(port string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         ":" + port,
+		Handler:      handler,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+}
 
-	// Start worker in goroutine
-	go func() {
-		logger.Info("starting asynq worker")
-		if err := asynqWorker.Start(); err != nil {
-			logger.Error("worker error", zap.Error(err))
-		}
-	}()
-
-	// Start HTTP server in goroutine
-	go func() {
-		logger.Info("starting HTTP server", zap.String("addr", srv.Addr))
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatal("failed to start HTTP server", zap.Error(err))
-		}
-	}()
-
-	// Handle graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	logger.Info("shutting down server...")
-
-	// Create shutdown context with timeout
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Shutdown HTTP server
-	if err := srv.Shutdown(shutdownCtx); err != nil {
-		logger.Error("HTTP server shutdown error", zap.Error(err))
+// setupWorkerHealthRouter creates the router served in worker mode: only the liveness probe.
+func setupWorkerHealthRouter(cfg *config.Config, deps *components, logger *zap.Logger) *gin.Engine {
+	if cfg.IsProduction() {
+		gin.SetMode(gin.ReleaseMode)
 	}
-	logger.Info("HTTP server stopped")
 
-	// Shutdown worker
-	asynqWorker.Shutdown()
-	logger.Info("worker stopped")
+	router := gin.New()
+	router.Use(gin.Recovery())
 
-	// Close database connection
-	db.Close()
-	logger.Info("database connection closed")
+	healthHandler := handler.NewHealthHandler(deps.db, deps.redisClient, deps.r2Client, handler.HealthConfig{
+		RedisOptional:    cfg.Health.RedisOptional,
+		R2Optional:       cfg.Health.R2Optional,
+		DBMaxAcquireWait: cfg.Health.DBMaxAcquireWait,
+	}, logger)
+	router.GET("/health", healthHandler.Live)
 
-	logger.Info("server shutdown complete")
+	return router
 }
 
 // setupLogger creates a zap logger configured based on environment.
@@ -245,15 +285,27 @@ func setupLogger(cfg *config.Config) (*zap.Logger, error) {
 // setupRouter creates and configures the Gin router with all routes and middleware.
 func setupRouter(
 	cfg *config.Config,
+	db *database.DB,
 	authService service.AuthService,
 	jobService service.JobService,
+	templateService service.JobTemplateService,
+	keyService service.ProviderKeyService,
+	orgService service.OrganizationService,
 	jobRepo repository.JobRepository,
 	userRepo repository.UserRepository,
 	systemPromptRepo repository.SystemPromptRepository,
 	cryptoService service.CryptoService,
+	r2Client *r2.Client,
 	youtubeClient *youtube.Client,
 	asynqClient *asynq.Client,
+	queueInspector worker.QueueInspector,
+	workerRegistry worker.InstanceRegistry,
+	outbox *worker.Outbox,
 	redisClient *redis.Client,
+	appMetrics *metrics.Metrics,
+	syncRunner *worker.SyncRunner,
+	settingsService service.RuntimeSettingsService,
+	startup *handler.StartupState,
 	logger *zap.Logger,
 ) *gin.Engine {
 	// Set Gin mode based on environment
@@ -263,9 +315,18 @@ func setupRouter(
 
 	router := gin.New()
 
+	// ClientIP feeds the per-IP rate limiters, so only trust forwarding headers from known proxies
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Fatal("Invalid TRUSTED_PROXIES", zap.Error(err))
+	}
+
 	// Add middleware
 	router.Use(gin.Recovery())
+	router.Use(middleware.RequestIDMiddleware())
 	router.Use(ginLogger(logger))
+	if appMetrics != nil {
+		router.Use(middleware.MetricsMiddleware(appMetrics))
+	}
 
 	// CORS middleware
 	var corsConfig middleware.CORSConfig
@@ -278,34 +339,168 @@ func setupRouter(
 	}
 	router.Use(middleware.CORSMiddleware(corsConfig))
 
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "healthy",
-			"service": "ugc",
-		})
-	})
+	// Health endpoints: /health (liveness) and /health/ready (dependency checks)
+	healthHandler := handler.NewHealthHandler(db, redisClient, r2Client, handler.HealthConfig{
+		RedisOptional:    cfg.Health.RedisOptional,
+		R2Optional:       cfg.Health.R2Optional,
+		DBMaxAcquireWait: cfg.Health.DBMaxAcquireWait,
+		RequireFFmpeg:    cfg.RunsWorker(),
+		Startup:          startup,
+	}, logger)
+	healthHandler.RegisterRoutes(router)
+
+	// Prometheus metrics endpoint (optional basic auth)
+	if appMetrics != nil && !cfg.ServesMetrics() {
+		logger.Warn("metrics endpoint not served in production without METRICS_USERNAME and METRICS_PASSWORD")
+	} else if appMetrics != nil {
+		metricsHandlers := []gin.HandlerFunc{}
+		if cfg.Metrics.Username != "" {
+			metricsHandlers = append(metricsHandlers, gin.BasicAuth(gin.Accounts{
+				cfg.Metrics.Username: cfg.Metrics.Password,
+			}))
+		}
+		metricsHandlers = append(metricsHandlers, gin.WrapH(appMetrics.Handler()))
+		router.GET("/metrics", metricsHandlers...)
+	}
 
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
+		// Bodies are capped per route group; the cover image upload is the only route taking more than a JSON body
+		api := v1.Group("", middleware.BodyLimitMiddleware(middleware.BodyLimitConfig{
+			MaxBytes:     cfg.Server.MaxBodyBytes,
+			MaxJSONDepth: cfg.Server.MaxJSONDepth,
+			Overrides: map[string]int64{
+				"/api/v1/jobs/:id/image": r2.MaxUserImageSize + 64*1024,
+			},
+		}))
+		webhooks := v1.Group("", middleware.BodyLimitMiddleware(middleware.BodyLimitConfig{
+			MaxBytes:     cfg.Server.WebhookMaxBodyBytes,
+			MaxJSONDepth: cfg.Server.MaxJSONDepth,
+		}))
+
+		// Generated API spec and Swagger UI
+		if cfg.Server.DocsEnabled {
+			handler.NewDocsHandler(cfg.Server.DocsSpecPath, logger).RegisterRoutes(v1)
+		}
+
+		// KIE credit balances are shared by the auth and job handlers
+		creditService := service.NewKIECreditService(cryptoService, redisClient, service.KIECreditsConfig{
+			BaseURL:      cfg.KIE.BaseURL,
+			LowThreshold: cfg.KIE.CreditsLowThreshold,
+			CacheTTL:     cfg.KIE.CreditsCacheTTL,
+		}, logger)
+
+		// Security-relevant actions of the auth, organization and admin handlers
+		auditService := service.NewAuditService(repository.NewAuditLogRepository(db), logger)
+
 		// Auth routes
-		authHandler := handler.NewAuthHandler(authService, userRepo, systemPromptRepo, cryptoService, youtubeClient, cfg.FrontendURL, logger)
-		authHandler.RegisterRoutes(v1)
+		authHandler := handler.NewAuthHandler(authService, userRepo, systemPromptRepo, cryptoService, service.NewAPIKeyValidator(cfg.OpenRouter.BaseURL, cfg.KIE.BaseURL, logger), creditService, security.NewCaptchaVerifier(cfg.Auth.TurnstileSecret), youtubeClient, asynqClient, outbox, auditService, settingsService, cfg.FrontendURL, logger)
+		// Public auth routes are limited per IP against credential stuffing
+		var authRateLimitMiddleware gin.HandlerFunc
+		if redisClient != nil {
+			authRateLimitMiddleware = middleware.RateLimitMiddleware(middleware.RateLimitConfig{
+				RedisClient: redisClient,
+				RPS:         cfg.Auth.RateLimitRPS,
+				Burst:       cfg.Auth.RateLimitBurst,
+				KeyPrefix:   "ugc",
+				Logger:      logger,
+				Scope:       "auth",
+			})
+		}
+		authHandler.RegisterRoutes(api, authRateLimitMiddleware)
 
 		// Job routes (protected)
 		authMiddleware := middleware.AuthMiddleware(authService, logger)
-		jobHandler := handler.NewJobHandler(jobService, userRepo, cryptoService, asynqClient, logger)
-		jobHandler.RegisterRoutes(v1, authMiddleware)
+		moderator := service.NewContentModerator(cfg.Pipeline.ConceptModeration, logger)
+		queuePositions := worker.NewQueuePositionEstimator(queueInspector, jobRepo, cfg.Worker.Concurrency, logger)
+		jobHandler := handler.NewJobHandler(jobService, templateService, userRepo, keyService, creditService, moderator, asynqClient, outbox, r2Client, queuePositions, syncRunner, settingsService, cfg.Pipeline.MaxConceptLength, logger)
+		// Bulk create fans out into many pipelines, so it is limited per user
+		var bulkRateLimitMiddleware gin.HandlerFunc
+		if redisClient != nil {
+			bulkRateLimitMiddleware = middleware.RateLimitMiddleware(middleware.RateLimitConfig{
+				RedisClient: redisClient,
+				Burst:       cfg.Pipeline.BulkJobsPerMinute,
+				KeyPrefix:   "ugc",
+				Logger:      logger,
+				Scope:       "jobs-bulk",
+				Window:      time.Minute,
+				KeyFunc: func(c *gin.Context) string {
+					userID, _ := middleware.GetUserIDFromContext(c)
+					return userID.String()
+				},
+			})
+		}
+		jobHandler.RegisterRoutes(api, authMiddleware, bulkRateLimitMiddleware)
+
+		// Zip exports stream every artifact, so concurrent downloads are capped per user
+		var exportLimiter service.ExportLimiter
+		if redisClient != nil {
+			exportLimiter = service.NewRedisExportLimiter(redisClient, "ugc", concurrentExportsPerUser)
+		}
+		exportHandler := handler.NewExportHandler(jobService, r2Client, exportLimiter, logger)
+		exportHandler.RegisterRoutes(api, authMiddleware)
+
+		// Job templates (protected)
+		templateHandler := handler.NewTemplateHandler(templateService, logger)
+		templateHandler.RegisterRoutes(api, authMiddleware)
+
+		// Public share links (unauthenticated, rate limited per IP)
+		var shareRateLimitMiddleware gin.HandlerFunc
+		if redisClient != nil {
+			shareRateLimitMiddleware = middleware.RateLimitMiddleware(middleware.RateLimitConfig{
+				RedisClient: redisClient,
+				Burst:       shareRequestsPerMinute,
+				KeyPrefix:   "ugc",
+				Logger:      logger,
+				Scope:       "share",
+				Window:      time.Minute,
+			})
+		}
+		shareHandler := handler.NewShareHandler(jobService, r2Client, logger)
+		shareHandler.RegisterRoutes(api, shareRateLimitMiddleware)
+
+		// Public status page (unauthenticated, cached)
+		var providerStats service.ProviderStats
+		if redisClient != nil {
+			providerStats = service.NewRedisProviderStats(redisClient, "ugc")
+		}
+		statusAggregator := worker.NewStatusAggregator(jobRepo, queueInspector, providerStats, cfg.Worker.Concurrency, logger)
+		handler.NewStatusHandler(statusAggregator, settingsService, logger).RegisterRoutes(api)
+
+		// Job schedules (protected)
+		scheduleService := service.NewJobScheduleService(repository.NewJobScheduleRepository(db), templateService, logger)
+		scheduleHandler := handler.NewScheduleHandler(scheduleService, moderator, cfg.Pipeline.MaxConceptLength, logger)
+		scheduleHandler.RegisterRoutes(api, authMiddleware)
+
+		// Outbound user webhooks (protected)
+		userWebhookService := service.NewUserWebhookService(repository.NewUserWebhookRepository(db), cryptoService, logger)
+		userWebhookHandler := handler.NewUserWebhookHandler(userWebhookService, logger)
+		userWebhookHandler.RegisterRoutes(api, authMiddleware)
+
+		// Organizations (protected)
+		orgHandler := handler.NewOrganizationHandler(orgService, auditService, logger)
+		orgHandler.RegisterRoutes(api, authMiddleware)
+
+		// Model catalogue (protected)
+		modelHandler := handler.NewModelHandler(userRepo, cryptoService, redisClient, logger)
+		modelHandler.RegisterRoutes(api, authMiddleware)
 
 		// Admin routes (protected + admin only)
 		adminMiddleware := middleware.AdminMiddleware(logger)
-		adminHandler := handler.NewAdminHandler(systemPromptRepo, logger)
-		adminHandler.RegisterRoutes(v1, authMiddleware, adminMiddleware)
+		webhookEventRepo := repository.NewWebhookEventRepository(db)
+		webhookSecretUsage := middleware.NewWebhookSecretUsage(cfg.Webhook.PreviousSecret != "")
+		adminHandler := handler.NewAdminHandler(systemPromptRepo, userRepo, jobRepo, webhookEventRepo, repository.NewUserSpendRepository(db), asynqClient, queueInspector, workerRegistry, webhookSecretUsage, auditService, settingsService, logger)
+		adminHandler.RegisterRoutes(api, authMiddleware, adminMiddleware)
 
 		// Webhook routes (with rate limiting and token-based auth for external services)
 		urlValidator := security.NewURLValidator(cfg.Webhook.AllowedHosts)
-		webhookHandler := handler.NewWebhookHandler(jobRepo, jobService, asynqClient, urlValidator, logger)
+		webhookProcessor := handler.NewWebhookProcessor(jobRepo, webhookEventRepo, jobService, asynqClient, outbox, urlValidator, cfg.Pipeline.SunoCompleteGrace, logger)
+		// Raw callback capture is a debugging aid and stays off unless explicitly enabled
+		if cfg.Webhook.CaptureEnabled {
+			logger.Warn("webhook capture enabled: raw callback bodies are stored in webhook_events")
+		}
+		webhookHandler := handler.NewWebhookHandler(webhookProcessor, repository.NewProcessedWebhookRepository(db), webhookEventRepo, cfg.Webhook.CaptureEnabled, asynqClient, appMetrics, logger)
 
 		// Rate limiting middleware (optional - depends on Redis availability)
 		var rateLimitMiddleware gin.HandlerFunc
@@ -320,13 +515,21 @@ func setupRouter(
 		}
 
 		// Webhook authentication middleware
+		// WEBHOOK_SECRET_PREVIOUS keeps callback URLs issued before a rotation valid
+		if cfg.Webhook.PreviousSecret != "" {
+			logger.Info("webhook secret rotation in progress: the previous secret is still accepted, see GET /api/v1/admin/webhook-secret for its usage")
+		}
 		webhookAuthMiddleware := middleware.WebhookAuthMiddleware(middleware.WebhookAuthConfig{
-			Secret:      cfg.Webhook.Secret,
-			Environment: cfg.Server.Env,
-			Logger:      logger,
+			Secret:         cfg.Webhook.Secret,
+			PreviousSecret: cfg.Webhook.PreviousSecret,
+			Environment:    cfg.Server.Env,
+			Logger:         logger,
+			Usage:          webhookSecretUsage,
+			// Callback URLs now carry per-job tokens; ones issued with the raw secret expire at the configured cutoff
+			LegacyTokensUntil: cfg.Webhook.LegacyTokensUntil,
 		})
 
-		webhookHandler.RegisterRoutes(v1, rateLimitMiddleware, webhookAuthMiddleware)
+		webhookHandler.RegisterRoutes(webhooks, rateLimitMiddleware, webhookAuthMiddleware)
 	}
 
 	return router
@@ -336,8 +539,9 @@ func setupRouter(
 func ginLogger(logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
-		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
+		// Route parameters and query values can carry secrets (webhook tokens, OAuth codes)
+		path := middleware.SanitizedPath(c)
+		query := middleware.SanitizedQuery(c)
 
 		c.Next()
 
@@ -353,6 +557,7 @@ func ginLogger(logger *zap.Logger) gin.HandlerFunc {
 			zap.Duration("latency", latency),
 			zap.String("ip", c.ClientIP()),
 			zap.String("user-agent", c.Request.UserAgent()),
+			zap.String("request_id", middleware.GetRequestID(c)),
 		}
 
 		if len(c.Errors) > 0 {
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/handler"
+)
+
+// shutdownTimeout bounds the HTTP server shutdown.
+const shutdownTimeout = 30 * time.Second
+
+// taskWorker is the part of worker.Worker a process starts and stops.
+type taskWorker interface {
+	Start(ctx context.Context) error
+	Failed() <-chan error
+	Drain(timeout time.Duration)
+}
+
+// process runs the long-running parts of the service in order: the worker, when
+// the process runs one, starts first, then the HTTP listener opens and startup
+// turns ready. ctx ending, the listener failing or the worker failing shuts both
+// down, and startup stops being ready as soon as shutdown begins.
+type process struct {
+	server       *http.Server
+	worker       taskWorker // nil when the process does not run the worker
+	drainTimeout time.Duration
+	startup      *handler.StartupState
+	logger       *zap.Logger
+
+	// listen opens the server's listener; nil listens on TCP at server.Addr.
+	listen func(addr string) (net.Listener, error)
+	// workerStarted starts the loops that run next to the worker, e.g. the outbox drain.
+	workerStarted func(ctx context.Context)
+	// stopped runs last during shutdown, e.g. to deregister the worker heartbeat.
+	stopped func(ctx context.Context)
+}
+
+// run blocks until ctx is done or a component fails, then shuts everything down.
+// A worker that cannot start is returned before the listener opens, so no job is
+// accepted that nothing will pick up.
+func (p *process) run(ctx context.Context) error {
+	// Cancelled on shutdown to stop the worker's background loops
+	ctx, cancelBackground := context.WithCancel(ctx)
+	defer cancelBackground()
+
+	if p.worker != nil {
+		p.logger.Info("starting asynq worker")
+		if err := p.worker.Start(ctx); err != nil {
+			return fmt.Errorf("failed to start worker: %w", err)
+		}
+		if p.workerStarted != nil {
+			p.workerStarted(ctx)
+		}
+	}
+
+	listen := p.listen
+	if listen == nil {
+		listen = func(addr string) (net.Listener, error) {
+			return net.Listen("tcp", addr)
+		}
+	}
+
+	var runErr error
+	listener, err := listen(p.server.Addr)
+	if err != nil {
+		runErr = fmt.Errorf("HTTP server failed: %w", err)
+	} else {
+		runErr = p.serve(ctx, listener)
+	}
+
+	p.logger.Info("shutting down server...")
+	p.startup.SetReady(false)
+	cancelBackground()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if listener != nil {
+		if err := p.server.Shutdown(shutdownCtx); err != nil {
+			p.logger.Error("HTTP server shutdown error", zap.Error(err))
+		}
+		p.logger.Info("HTTP server stopped")
+	}
+
+	// Shutdown worker once in-flight tasks finish or the drain timeout passes
+	if p.worker != nil {
+		p.worker.Drain(p.drainTimeout)
+		p.logger.Info("worker stopped")
+	}
+	if p.stopped != nil {
+		p.stopped(shutdownCtx)
+	}
+
+	return runErr
+}
+
+// serve serves HTTP on listener until ctx is done, the server fails or the
+// worker fails.
+func (p *process) serve(ctx context.Context, listener net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		p.logger.Info("starting HTTP server", zap.String("addr", listener.Addr().String()))
+		if err := p.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+	p.startup.SetReady(true)
+
+	// A nil channel never receives, so API-only processes wait on the others
+	var workerFailed <-chan error
+	if p.worker != nil {
+		workerFailed = p.worker.Failed()
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-serveErr:
+		return fmt.Errorf("HTTP server failed: %w", err)
+	case err := <-workerFailed:
+		return fmt.Errorf("worker failed: %w", err)
+	}
+}
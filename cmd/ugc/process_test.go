@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/handler"
+)
+
+// fakeWorker is a taskWorker whose Start blocks until release is closed, when set.
+type fakeWorker struct {
+	startErr error
+	release  chan struct{}
+	failed   chan error
+
+	mu      sync.Mutex
+	drained bool
+}
+
+func newFakeWorker() *fakeWorker {
+	return &fakeWorker{failed: make(chan error, 1)}
+}
+
+func (w *fakeWorker) Start(ctx context.Context) error {
+	if w.release != nil {
+		<-w.release
+	}
+	return w.startErr
+}
+
+func (w *fakeWorker) Failed() <-chan error {
+	return w.failed
+}
+
+func (w *fakeWorker) Drain(timeout time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.drained = true
+}
+
+func (w *fakeWorker) wasDrained() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.drained
+}
+
+// newTestProcess returns a process listening on a loopback port. The address
+// is sent on listening once the listener is open.
+func newTestProcess(w taskWorker) (*process, chan string) {
+	listening := make(chan string, 1)
+	proc := &process{
+		server:  newHTTPServer("0", http.NotFoundHandler()),
+		startup: &handler.StartupState{},
+		logger:  zap.NewNop(),
+		listen: func(addr string) (net.Listener, error) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err == nil {
+				listening <- listener.Addr().String()
+			}
+			return listener, err
+		},
+		worker: w,
+	}
+	return proc, listening
+}
+
+// runAsync runs proc in the background and returns its result channel.
+func runAsync(ctx context.Context, proc *process) chan error {
+	result := make(chan error, 1)
+	go func() { result <- proc.run(ctx) }()
+	return result
+}
+
+func TestProcessWorkerStartFailureExitsBeforeListening(t *testing.T) {
+	w := newFakeWorker()
+	w.startErr = errors.New("failed to reach redis: connection refused")
+	proc, listening := newTestProcess(w)
+
+	err := proc.run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "failed to start worker") {
+		t.Fatalf("run = %v, want a worker start error", err)
+	}
+	select {
+	case addr := <-listening:
+		t.Errorf("listener opened on %s although the worker failed to start", addr)
+	default:
+	}
+	if proc.startup.Ready() {
+		t.Error("startup is ready although the worker failed to start")
+	}
+}
+
+func TestProcessReadyOnlyAfterWorkerStarts(t *testing.T) {
+	w := newFakeWorker()
+	w.release = make(chan struct{})
+	proc, listening := newTestProcess(w)
+	var backgroundStarted bool
+	proc.workerStarted = func(ctx context.Context) { backgroundStarted = true }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := runAsync(ctx, proc)
+
+	// The worker is still starting: nothing listens and readiness fails
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case addr := <-listening:
+		t.Fatalf("listener opened on %s before the worker started", addr)
+	default:
+	}
+	if proc.startup.Ready() {
+		t.Fatal("startup is ready before the worker started")
+	}
+
+	close(w.release)
+	select {
+	case <-listening:
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener did not open after the worker started")
+	}
+	waitFor(t, proc.startup.Ready, "startup to turn ready")
+	if !backgroundStarted {
+		t.Error("the worker's background loops did not start")
+	}
+
+	cancel()
+	if err := <-result; err != nil {
+		t.Errorf("run = %v after ctx was cancelled, want nil", err)
+	}
+	if proc.startup.Ready() {
+		t.Error("startup still ready after shutdown")
+	}
+	if !w.wasDrained() {
+		t.Error("worker was not drained on shutdown")
+	}
+}
+
+func TestProcessWorkerCrashShutsDown(t *testing.T) {
+	w := newFakeWorker()
+	proc, listening := newTestProcess(w)
+	var stopped bool
+	proc.stopped = func(ctx context.Context) { stopped = true }
+
+	result := runAsync(context.Background(), proc)
+	var addr string
+	select {
+	case addr = <-listening:
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener did not open")
+	}
+	waitFor(t, proc.startup.Ready, "startup to turn ready")
+
+	w.failed <- errors.New("redis unreachable for 4 health checks")
+	select {
+	case err := <-result:
+		if err == nil || !strings.Contains(err.Error(), "worker failed") {
+			t.Errorf("run = %v, want a worker failure", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not return after the worker failed")
+	}
+
+	if proc.startup.Ready() {
+		t.Error("startup still ready after the worker failed")
+	}
+	if !w.wasDrained() {
+		t.Error("worker was not drained")
+	}
+	if !stopped {
+		t.Error("stopped hook did not run")
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Error("HTTP server still accepts connections after the worker failed")
+	}
+}
+
+func TestProcessAPIOnlyServesUntilCancelled(t *testing.T) {
+	proc, listening := newTestProcess(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := runAsync(ctx, proc)
+	select {
+	case <-listening:
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener did not open")
+	}
+	waitFor(t, proc.startup.Ready, "startup to turn ready")
+
+	cancel()
+	if err := <-result; err != nil {
+		t.Errorf("run = %v, want nil", err)
+	}
+}
+
+// waitFor polls cond until it holds or a few seconds pass.
+func waitFor(t *testing.T, cond func() bool, what string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
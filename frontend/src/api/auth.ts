@@ -30,9 +30,10 @@ export async function register(input: RegisterInput): Promise<User> {
   return response.data.data
 }
 
-export async function refresh(): Promise<RefreshResponse> {
+export async function refresh(refreshToken: string): Promise<RefreshResponse> {
   const response = await api.post<ApiResponse<RefreshResponse>>(
-    '/api/v1/auth/refresh'
+    '/api/v1/auth/refresh',
+    { refresh_token: refreshToken }
   )
   if (!response.data.success || !response.data.data) {
     throw new Error(response.data.error?.message || 'Token refresh failed')
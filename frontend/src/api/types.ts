@@ -22,11 +22,15 @@ export interface RegisterInput {
 
 export interface LoginResponse {
   token: string
+  refresh_token: string
+  expires_at: string
   user: User
 }
 
 export interface RefreshResponse {
   token: string
+  refresh_token: string
+  expires_at: string
 }
 
 // Job types
@@ -91,11 +95,15 @@ export interface PaginationMeta {
   per_page: number
   total: number
   total_pages: number
+  has_next: boolean
+  has_prev: boolean
 }
 
 export interface ApiError {
   code: number
+  error_code: string
   message: string
+  details?: Record<string, string>
 }
 
 export interface ApiResponse<T> {
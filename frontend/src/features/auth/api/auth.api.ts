@@ -1,8 +1,11 @@
 import { api } from '@/lib/axios'
+import { useAuthStore } from '@/stores/auth.store'
 import type { User, ApiResponse } from '@/types'
 
 export interface LoginResponse {
   token: string
+  refresh_token: string
+  expires_at: string
   user: User
 }
 
@@ -50,7 +53,12 @@ export const authApi = {
     return response.data.data
   },
 
+  // Revokes the refresh token; the short-lived access token simply expires
   logout: async (): Promise<void> => {
     localStorage.removeItem('auth_token')
+    const refreshToken = useAuthStore.getState().refreshToken
+    if (refreshToken) {
+      await api.post('/api/v1/auth/logout', { refresh_token: refreshToken }).catch(() => undefined)
+    }
   },
 }
@@ -26,7 +26,7 @@ export function useAuth() {
   const loginMutation = useMutation({
     mutationFn: (data: LoginRequest) => authApi.login(data),
     onSuccess: (response) => {
-      storeLogin(response.user, response.token)
+      storeLogin(response.user, response.token, response.refresh_token)
       queryClient.invalidateQueries({ queryKey: ['user'] })
       // Redirect to intended destination or home
       navigate(from, { replace: true })
@@ -3,6 +3,7 @@ import { LayoutDashboard, Briefcase, LogOut, User, ChevronDown, Settings } from
 import { useState, useRef, useEffect } from 'react'
 import { Button } from '@/components/ui'
 import { useAuthStore } from '@/stores/auth.store'
+import { authApi } from '@/features/auth/api/auth.api'
 import { cn } from '@/lib/utils'
 
 interface NavLinkProps {
@@ -78,7 +79,7 @@ function UserDropdown() {
           <button
             onClick={() => {
               setIsOpen(false)
-              logout()
+              void authApi.logout().finally(logout)
             }}
             className="flex items-center gap-2 px-4 py-2 text-sm text-red-600 hover:bg-red-50 w-full"
           >
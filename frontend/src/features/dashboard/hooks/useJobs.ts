@@ -130,7 +130,7 @@ export function useCancelJobMutation() {
 
   return useMutation({
     mutationFn: async (id: string): Promise<void> => {
-      const response = await api.delete<ApiResponse<null>>(`/api/v1/jobs/${id}`)
+      const response = await api.post<ApiResponse<null>>(`/api/v1/jobs/${id}/cancel`)
 
       if (!response.data.success) {
         throw new Error(response.data.error?.message || 'Failed to cancel job')
@@ -52,7 +52,7 @@ async function createJob(data: CreateJobRequest): Promise<Job> {
 }
 
 async function cancelJob(id: string): Promise<void> {
-  const response = await api.delete<ApiResponse<null>>(`/api/v1/jobs/${id}`)
+  const response = await api.post<ApiResponse<null>>(`/api/v1/jobs/${id}/cancel`)
 
   if (!response.data.success) {
     throw new Error(response.data.error?.message || 'Failed to cancel job')
@@ -253,7 +253,7 @@ export default function JobListPage() {
                     variant="outline"
                     size="sm"
                     onClick={() => setPage((p) => Math.max(1, p - 1))}
-                    disabled={page === 1}
+                    disabled={!data.meta.has_prev}
                   >
                     ก่อนหน้า
                   </Button>
@@ -261,7 +261,7 @@ export default function JobListPage() {
                     variant="outline"
                     size="sm"
                     onClick={() => setPage((p) => Math.min(data.meta!.total_pages, p + 1))}
-                    disabled={page === data.meta.total_pages}
+                    disabled={!data.meta.has_next}
                   >
                     ถัดไป
                   </Button>
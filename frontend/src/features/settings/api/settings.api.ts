@@ -8,6 +8,7 @@ export interface UpdateProfileInput {
 
 export interface TestConnectionResponse {
   success: boolean
+  retryable: boolean
   message: string
 }
 
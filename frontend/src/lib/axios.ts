@@ -1,4 +1,4 @@
-import axios from 'axios'
+import axios, { type InternalAxiosRequestConfig } from 'axios'
 import { useAuthStore } from '@/stores/auth.store'
 
 const API_BASE_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080'
@@ -24,20 +24,69 @@ api.interceptors.request.use(
   }
 )
 
+interface RefreshResponse {
+  success: boolean
+  data?: { token: string; refresh_token: string }
+}
+
+// Concurrent 401s share one refresh, since each refresh token can be used only once
+let refreshing: Promise<string> | null = null
+
+function refreshAccessToken(): Promise<string> {
+  if (!refreshing) {
+    refreshing = (async () => {
+      const refreshToken = useAuthStore.getState().refreshToken
+      if (!refreshToken) {
+        throw new Error('No refresh token')
+      }
+      // Plain axios, so a failed refresh does not re-enter this interceptor
+      const response = await axios.post<RefreshResponse>(
+        `${API_BASE_URL}/api/v1/auth/refresh`,
+        { refresh_token: refreshToken },
+        { headers: { 'Content-Type': 'application/json' } }
+      )
+      if (!response.data.success || !response.data.data) {
+        throw new Error('Token refresh failed')
+      }
+      useAuthStore.getState().setTokens(response.data.data.token, response.data.data.refresh_token)
+      return response.data.data.token
+    })().finally(() => {
+      refreshing = null
+    })
+  }
+  return refreshing
+}
+
 // Response interceptor for handling errors
 let isRedirecting = false
 
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
+  async (error) => {
     if (error.response?.status === 401) {
-      const url = error.config?.url || ''
-      const isAuthEndpoint = url.includes('/auth/login') || url.includes('/auth/register')
+      const config = error.config as (InternalAxiosRequestConfig & { _retried?: boolean }) | undefined
+      const url = config?.url || ''
+      const isAuthEndpoint =
+        url.includes('/auth/login') || url.includes('/auth/register') || url.includes('/auth/refresh')
+
+      if (!isAuthEndpoint) {
+        // The access token expired: renew it with the refresh token and retry once
+        if (config && !config._retried && useAuthStore.getState().refreshToken) {
+          config._retried = true
+          try {
+            const token = await refreshAccessToken()
+            config.headers.Authorization = `Bearer ${token}`
+            return api(config)
+          } catch {
+            // Fall through to the login page
+          }
+        }
 
-      if (!isAuthEndpoint && !isRedirecting) {
-        isRedirecting = true
-        useAuthStore.getState().logout()
-        window.location.href = '/login'
+        if (!isRedirecting) {
+          isRedirecting = true
+          useAuthStore.getState().logout()
+          window.location.href = '/login'
+        }
       }
     }
     return Promise.reject(error)
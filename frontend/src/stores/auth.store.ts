@@ -5,11 +5,13 @@ import type { User } from '@/types'
 interface AuthState {
   user: User | null
   token: string | null
+  refreshToken: string | null
   isAuthenticated: boolean
   _hasHydrated: boolean
   setUser: (user: User | null) => void
   setToken: (token: string | null) => void
-  login: (user: User, token: string) => void
+  setTokens: (token: string, refreshToken: string) => void
+  login: (user: User, token: string, refreshToken: string) => void
   logout: () => void
   isAdmin: () => boolean
 }
@@ -19,15 +21,17 @@ export const useAuthStore = create<AuthState>()(
     (set, get) => ({
       user: null,
       token: null,
+      refreshToken: null,
       isAuthenticated: false,
       _hasHydrated: false,
       setUser: (user) => set({ user, isAuthenticated: !!user }),
       setToken: (token) => set({ token }),
-      login: (user, token) => {
-        set({ user, token, isAuthenticated: true })
+      setTokens: (token, refreshToken) => set({ token, refreshToken }),
+      login: (user, token, refreshToken) => {
+        set({ user, token, refreshToken, isAuthenticated: true })
       },
       logout: () => {
-        set({ user: null, token: null, isAuthenticated: false })
+        set({ user: null, token: null, refreshToken: null, isAuthenticated: false })
       },
       isAdmin: () => get().user?.role === 'admin',
     }),
@@ -36,6 +40,7 @@ export const useAuthStore = create<AuthState>()(
       partialize: (state) => ({
         user: state.user,
         token: state.token,
+        refreshToken: state.refreshToken,
         isAuthenticated: state.isAuthenticated,
       }),
     }
@@ -23,6 +23,8 @@ export interface PaginationMeta {
   per_page: number
   total: number
   total_pages: number
+  has_next: boolean
+  has_prev: boolean
 }
 
 export interface PaginatedResponse<T> {
@@ -35,6 +37,7 @@ export interface PaginatedResponse<T> {
 
 export interface ApiError {
   code: number
+  error_code: string
   message: string
   details?: Record<string, string>
 }
@@ -4,17 +4,24 @@ package agents
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/jaochai/ugc/internal/external/openrouter"
+	"github.com/jaochai/ugc/internal/models"
 	"go.uber.org/zap"
 )
 
 // JSONOutputInstructions is a common prompt suffix for requesting JSON output.
 const JSONOutputInstructions = "Respond with valid JSON only. No markdown, no explanation."
 
+// ConciseOutputInstructions is appended to the system prompt when retrying a response
+// that was cut off at the output limit.
+const ConciseOutputInstructions = "Your previous answer was cut off at the output limit. Keep every field as short as the instructions allow so the complete JSON fits."
+
 // Agent defines the interface for all agents.
 type Agent interface {
 	Execute(ctx context.Context, input interface{}) (interface{}, error)
@@ -22,13 +29,14 @@ type Agent interface {
 
 // BaseAgent provides common functionality for LLM-based agents.
 type BaseAgent struct {
-	llmClient *openrouter.Client
+	llmClient openrouter.ChatClient
 	model     string
+	params    models.GenerationParams
 	logger    *zap.Logger
 }
 
 // NewBaseAgent creates a new BaseAgent instance.
-func NewBaseAgent(llmClient *openrouter.Client, model string, logger *zap.Logger) *BaseAgent {
+func NewBaseAgent(llmClient openrouter.ChatClient, model string, logger *zap.Logger) *BaseAgent {
 	return &BaseAgent{
 		llmClient: llmClient,
 		model:     model,
@@ -37,7 +45,7 @@ func NewBaseAgent(llmClient *openrouter.Client, model string, logger *zap.Logger
 }
 
 // LLMClient returns the OpenRouter client.
-func (b *BaseAgent) LLMClient() *openrouter.Client {
+func (b *BaseAgent) LLMClient() openrouter.ChatClient {
 	return b.llmClient
 }
 
@@ -46,6 +54,17 @@ func (b *BaseAgent) Model() string {
 	return b.model
 }
 
+// SetGenerationParams sets the temperature and max_tokens of the agent's requests.
+// Unset fields use the provider's defaults.
+func (b *BaseAgent) SetGenerationParams(params models.GenerationParams) {
+	b.params = params
+}
+
+// GenerationParams returns the parameters set by SetGenerationParams.
+func (b *BaseAgent) GenerationParams() models.GenerationParams {
+	return b.params
+}
+
 // Logger returns the logger instance.
 func (b *BaseAgent) Logger() *zap.Logger {
 	return b.logger
@@ -59,7 +78,15 @@ func (b *BaseAgent) Chat(ctx context.Context, systemPrompt string, userPrompt st
 		zap.Int("user_prompt_len", len(userPrompt)),
 	)
 
-	response, err := b.llmClient.ChatWithModel(ctx, b.model, systemPrompt, userPrompt)
+	response, err := b.llmClient.Complete(ctx, openrouter.ChatRequest{
+		Model: b.model,
+		Messages: []openrouter.Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: userPrompt},
+		},
+		Temperature: b.params.Temperature,
+		MaxTokens:   b.params.MaxTokens,
+	})
 	if err != nil {
 		b.logger.Error("chat request failed", zap.Error(err))
 		return "", fmt.Errorf("chat request failed: %w", err)
@@ -76,6 +103,10 @@ func (b *BaseAgent) ChatJSON(ctx context.Context, systemPrompt string, userPromp
 	fullSystemPrompt := systemPrompt + "\n\n" + JSONOutputInstructions
 
 	response, err := b.Chat(ctx, fullSystemPrompt, userPrompt)
+	var truncated *openrouter.TruncatedError
+	if errors.As(err, &truncated) {
+		response, err = b.retryTruncated(ctx, fullSystemPrompt, userPrompt)
+	}
 	if err != nil {
 		return err
 	}
@@ -91,6 +122,28 @@ func (b *BaseAgent) ChatJSON(ctx context.Context, systemPrompt string, userPromp
 	return nil
 }
 
+// retryTruncated repeats a request whose output hit its limit, once: with double
+// the max_tokens (at most MaxMaxTokens), or with an instruction to keep the output
+// short when the limit is already at the cap.
+func (b *BaseAgent) retryTruncated(ctx context.Context, systemPrompt string, userPrompt string) (string, error) {
+	original := b.params
+	defer func() { b.params = original }()
+
+	if original.MaxTokens == nil || *original.MaxTokens < MaxMaxTokens {
+		maxTokens := MaxMaxTokens
+		if original.MaxTokens != nil {
+			maxTokens = min(*original.MaxTokens*2, MaxMaxTokens)
+		}
+		b.params.MaxTokens = &maxTokens
+		b.logger.Warn("chat response truncated, retrying with higher max_tokens", zap.Int("max_tokens", maxTokens))
+	} else {
+		systemPrompt += "\n\n" + ConciseOutputInstructions
+		b.logger.Warn("chat response truncated at the max_tokens cap, retrying with a shorter output request")
+	}
+
+	return b.Chat(ctx, systemPrompt, userPrompt)
+}
+
 // ParseJSONFromResponse extracts and parses JSON from an LLM response.
 // It supports both raw JSON and JSON wrapped in markdown code blocks.
 func (b *BaseAgent) ParseJSONFromResponse(response string, result interface{}) error {
@@ -146,9 +199,10 @@ func isJSONLike(s string) bool {
 }
 
 // truncateString truncates a string to maxLen characters, adding "..." if truncated.
+// It counts runes so multi-byte (e.g. Thai) text is never cut mid-character.
 func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	if utf8.RuneCountInString(s) <= maxLen {
 		return s
 	}
-	return s[:maxLen] + "..."
+	return string([]rune(s)[:maxLen]) + "..."
 }
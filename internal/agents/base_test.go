@@ -0,0 +1,26 @@
+package agents
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateStringThai(t *testing.T) {
+	tests := []struct {
+		s      string
+		maxLen int
+		want   string
+	}{
+		{s: "น้ำค้าง", maxLen: 7, want: "น้ำค้าง"},
+		// Cut by characters, so never inside a three-byte Thai character
+		{s: "น้ำค้างยามเช้า", maxLen: 7, want: "น้ำค้าง..."},
+		{s: "น้ำค้าง", maxLen: 1, want: "น..."},
+		{s: "city pop", maxLen: 4, want: "city..."},
+	}
+	for _, tt := range tests {
+		got := truncateString(tt.s, tt.maxLen)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("truncateString(%q, %d) = %q, want %q", tt.s, tt.maxLen, got, tt.want)
+		}
+	}
+}
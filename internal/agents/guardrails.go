@@ -0,0 +1,50 @@
+package agents
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxCustomPromptLength is the maximum allowed length for a user's custom system prompt.
+const MaxCustomPromptLength = 10000
+
+// ErrPromptNotAllowed is returned when a custom prompt contains disallowed content.
+var ErrPromptNotAllowed = errors.New("prompt contains disallowed content")
+
+// bannedPromptSubstrings are lowercase fragments that custom prompts may not contain.
+// Agents never receive secrets, but a prompt asking the LLM to echo credentials or
+// environment details is an exfiltration attempt and is rejected outright.
+var bannedPromptSubstrings = []string{
+	"api key",
+	"api_key",
+	"apikey",
+	"authorization header",
+	"bearer ",
+	"access token",
+	"refresh token",
+	"secret key",
+	"password",
+	"environment variable",
+	"env var",
+	"getenv",
+	"ignore previous instructions",
+	"ignore all previous",
+}
+
+// ValidateCustomPrompt checks a user's custom system prompt against the length limit
+// and the banned-substring list.
+func ValidateCustomPrompt(prompt string) error {
+	if len(prompt) > MaxCustomPromptLength {
+		return fmt.Errorf("prompt must be %d characters or less", MaxCustomPromptLength)
+	}
+
+	lower := strings.ToLower(prompt)
+	for _, banned := range bannedPromptSubstrings {
+		if strings.Contains(lower, banned) {
+			return fmt.Errorf("%w: %q", ErrPromptNotAllowed, strings.TrimSpace(banned))
+		}
+	}
+
+	return nil
+}
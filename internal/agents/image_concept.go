@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/jaochai/ugc/internal/external/kie"
 	"github.com/jaochai/ugc/internal/external/openrouter"
 	"go.uber.org/zap"
 )
@@ -23,17 +24,28 @@ type ImageConceptInput struct {
 	SongTitle       string // title of the song
 	SongStyle       string // music style used
 	Lyrics          string // optional, if available
+	// AvoidNamedEntities asks for a prompt without real people, brands or logos,
+	// after the image model rejected the previous prompt under its content policy.
+	AvoidNamedEntities bool
 }
 
+// Fallbacks used when the model omits or invents an aspect ratio or resolution.
+const (
+	DefaultImageAspectRatio = kie.AspectRatio16x9
+	DefaultImageResolution  = kie.Resolution1K
+)
+
 // ImageConceptOutput contains the generated image prompt data.
-// Note: AspectRatio and Resolution are NOT included - these are API-specific values
-// that should be configured in the application, not by the LLM.
+// AspectRatio and Resolution are suggestions from the model; Generate replaces
+// values KIE does not support with DefaultImageAspectRatio and DefaultImageResolution.
 type ImageConceptOutput struct {
-	Prompt string `json:"prompt"`
+	Prompt      string `json:"prompt"`
+	AspectRatio string `json:"aspect_ratio"`
+	Resolution  string `json:"resolution"`
 }
 
 // NewImageConceptAgent creates a new ImageConceptAgent.
-func NewImageConceptAgent(llmClient *openrouter.Client, model string, logger *zap.Logger) *ImageConceptAgent {
+func NewImageConceptAgent(llmClient openrouter.ChatClient, model string, logger *zap.Logger) *ImageConceptAgent {
 	return &ImageConceptAgent{
 		BaseAgent:    NewBaseAgent(llmClient, model, logger),
 		customPrompt: nil,
@@ -41,7 +53,7 @@ func NewImageConceptAgent(llmClient *openrouter.Client, model string, logger *za
 }
 
 // NewImageConceptAgentWithPrompt creates a new ImageConceptAgent with a custom system prompt.
-func NewImageConceptAgentWithPrompt(llmClient *openrouter.Client, model string, logger *zap.Logger, customPrompt *string) *ImageConceptAgent {
+func NewImageConceptAgentWithPrompt(llmClient openrouter.ChatClient, model string, logger *zap.Logger, customPrompt *string) *ImageConceptAgent {
 	return &ImageConceptAgent{
 		BaseAgent:    NewBaseAgent(llmClient, model, logger),
 		customPrompt: customPrompt,
@@ -79,14 +91,43 @@ func (a *ImageConceptAgent) Generate(ctx context.Context, input ImageConceptInpu
 		return nil, fmt.Errorf("empty prompt in response")
 	}
 
+	a.normalizeOutput(&output)
+
 	a.Logger().Info("image concept generated successfully",
 		zap.String("song_title", input.SongTitle),
 		zap.Int("prompt_length", len(output.Prompt)),
+		zap.String("aspect_ratio", output.AspectRatio),
+		zap.String("resolution", output.Resolution),
 	)
 
 	return &output, nil
 }
 
+// normalizeOutput replaces unsupported aspect ratio and resolution values with the defaults.
+func (a *ImageConceptAgent) normalizeOutput(output *ImageConceptOutput) {
+	aspectRatio := strings.TrimSpace(output.AspectRatio)
+	if !kie.IsValidAspectRatio(aspectRatio) {
+		if aspectRatio != "" {
+			a.Logger().Warn("unsupported aspect ratio from model, using default",
+				zap.String("aspect_ratio", output.AspectRatio),
+			)
+		}
+		aspectRatio = DefaultImageAspectRatio
+	}
+	output.AspectRatio = aspectRatio
+
+	resolution := strings.ToUpper(strings.TrimSpace(output.Resolution))
+	if !kie.IsValidResolution(resolution) {
+		if resolution != "" {
+			a.Logger().Warn("unsupported resolution from model, using default",
+				zap.String("resolution", output.Resolution),
+			)
+		}
+		resolution = DefaultImageResolution
+	}
+	output.Resolution = resolution
+}
+
 // buildUserPrompt creates the user prompt from the input.
 func (a *ImageConceptAgent) buildUserPrompt(input ImageConceptInput) string {
 	var sb strings.Builder
@@ -103,5 +144,11 @@ func (a *ImageConceptAgent) buildUserPrompt(input ImageConceptInput) string {
 
 	sb.WriteString("\nGenerate a visually compelling image prompt that captures the essence of this song.")
 
+	if input.AvoidNamedEntities {
+		sb.WriteString("\n\nThe image model rejected the previous prompt under its content policy. " +
+			"Do not name or depict real people (including celebrities and artists named in the lyrics), " +
+			"brands, trademarks, logos or copyrighted characters; describe generic subjects instead.")
+	}
+
 	return sb.String()
 }
@@ -0,0 +1,167 @@
+// Package agents provides AI agents for content generation.
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jaochai/ugc/internal/external/openrouter"
+	"go.uber.org/zap"
+)
+
+// ImageCandidate represents an image candidate from NanoBanana.
+type ImageCandidate struct {
+	TaskID   string `json:"task_id"`
+	ImageURL string `json:"image_url"`
+}
+
+// ImageSelectorInput is the input for the image selector agent.
+type ImageSelectorInput struct {
+	OriginalConcept string           `json:"original_concept"`
+	SongTitle       string           `json:"song_title"`
+	ImagePrompt     string           `json:"image_prompt"`
+	Images          []ImageCandidate `json:"images"`
+}
+
+// ImageSelectorOutput is the output from the image selector agent.
+type ImageSelectorOutput struct {
+	SelectedTaskID string `json:"selectedTaskId"`
+	Reasoning      string `json:"reasoning"`
+}
+
+// ImageSelectorAgent selects the best image from candidates based on the concept and song title.
+type ImageSelectorAgent struct {
+	*BaseAgent
+	customPrompt *string
+}
+
+// NewImageSelectorAgent creates a new ImageSelectorAgent.
+func NewImageSelectorAgent(llmClient openrouter.ChatClient, model string, logger *zap.Logger) *ImageSelectorAgent {
+	return &ImageSelectorAgent{
+		BaseAgent:    NewBaseAgent(llmClient, model, logger),
+		customPrompt: nil,
+	}
+}
+
+// NewImageSelectorAgentWithPrompt creates a new ImageSelectorAgent with a custom system prompt.
+func NewImageSelectorAgentWithPrompt(llmClient openrouter.ChatClient, model string, logger *zap.Logger, customPrompt *string) *ImageSelectorAgent {
+	return &ImageSelectorAgent{
+		BaseAgent:    NewBaseAgent(llmClient, model, logger),
+		customPrompt: customPrompt,
+	}
+}
+
+// getSystemPrompt returns the system prompt for the image selector agent.
+func (a *ImageSelectorAgent) getSystemPrompt() string {
+	if a.customPrompt != nil && *a.customPrompt != "" {
+		return *a.customPrompt
+	}
+	return DefaultImageSelectorPrompt
+}
+
+// Select chooses the best image from the candidates based on the concept and song title.
+func (a *ImageSelectorAgent) Select(ctx context.Context, input ImageSelectorInput) (*ImageSelectorOutput, error) {
+	if len(input.Images) == 0 {
+		return nil, fmt.Errorf("no image candidates provided")
+	}
+
+	// If only one image, return it directly
+	if len(input.Images) == 1 {
+		a.Logger().Info("only one image candidate, selecting it automatically",
+			zap.String("task_id", input.Images[0].TaskID),
+		)
+		return &ImageSelectorOutput{
+			SelectedTaskID: input.Images[0].TaskID,
+			Reasoning:      "Only one image candidate available, selected automatically.",
+		}, nil
+	}
+
+	userPrompt := a.buildUserPrompt(input)
+
+	a.Logger().Debug("sending image selection request to LLM",
+		zap.String("song_title", input.SongTitle),
+		zap.Int("candidate_count", len(input.Images)),
+	)
+
+	response, err := a.Chat(ctx, a.getSystemPrompt(), userPrompt)
+	if err != nil {
+		a.Logger().Error("failed to call LLM for image selection",
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to call LLM: %w", err)
+	}
+
+	output, err := a.parseResponse(response)
+	if err != nil {
+		a.Logger().Error("failed to parse LLM response",
+			zap.Error(err),
+			zap.String("response", truncateString(response, 500)),
+		)
+		return nil, fmt.Errorf("failed to parse LLM response: %w", err)
+	}
+
+	// Validate selected task ID exists in candidates
+	if !a.isValidTaskID(output.SelectedTaskID, input.Images) {
+		a.Logger().Error("LLM selected invalid image task ID",
+			zap.String("selected_task_id", output.SelectedTaskID),
+		)
+		return nil, fmt.Errorf("selected image task ID %q not found in candidates", output.SelectedTaskID)
+	}
+
+	a.Logger().Info("image selected successfully",
+		zap.String("selected_task_id", output.SelectedTaskID),
+		zap.String("reasoning", output.Reasoning),
+	)
+
+	return output, nil
+}
+
+// buildUserPrompt creates the user prompt with image candidates.
+func (a *ImageSelectorAgent) buildUserPrompt(input ImageSelectorInput) string {
+	var sb strings.Builder
+
+	sb.WriteString("Original concept: ")
+	sb.WriteString(input.OriginalConcept)
+	if input.SongTitle != "" {
+		sb.WriteString("\nSong title: ")
+		sb.WriteString(input.SongTitle)
+	}
+	if input.ImagePrompt != "" {
+		sb.WriteString("\nImage prompt: ")
+		sb.WriteString(input.ImagePrompt)
+	}
+	sb.WriteString("\n\nImage candidates:\n")
+
+	for _, image := range input.Images {
+		sb.WriteString(fmt.Sprintf("- Task ID: %s, URL: %s\n", image.TaskID, image.ImageURL))
+	}
+
+	sb.WriteString("\nSelect the best image and explain your reasoning.")
+
+	return sb.String()
+}
+
+// parseResponse parses the LLM response into ImageSelectorOutput.
+func (a *ImageSelectorAgent) parseResponse(response string) (*ImageSelectorOutput, error) {
+	var output ImageSelectorOutput
+	if err := a.ParseJSONFromResponse(response, &output); err != nil {
+		return nil, err
+	}
+
+	if output.SelectedTaskID == "" {
+		return nil, fmt.Errorf("selectedTaskId is empty in response")
+	}
+
+	return &output, nil
+}
+
+// isValidTaskID checks if the given task ID exists in the image candidates.
+func (a *ImageSelectorAgent) isValidTaskID(taskID string, images []ImageCandidate) bool {
+	for _, image := range images {
+		if image.TaskID == taskID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package agents
+
+import (
+	"fmt"
+
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// Generation parameter ranges accepted for user overrides.
+const (
+	MinTemperature = 0.0
+	MaxTemperature = 2.0
+	MinMaxTokens   = 1
+	MaxMaxTokens   = 8000
+)
+
+// DefaultGenerationParams returns the temperature and max_tokens an agent uses
+// when the user has not overridden them. Concepts are creative and need room for
+// full lyrics; selectors should pick consistently with a short answer.
+func DefaultGenerationParams(promptType string) models.GenerationParams {
+	switch promptType {
+	case models.PromptTypeSongConcept:
+		return newGenerationParams(0.8, 4000)
+	case models.PromptTypeSongSelector, models.PromptTypeImageSelector:
+		return newGenerationParams(0.2, 500)
+	case models.PromptTypeImageConcept:
+		return newGenerationParams(0.7, 800)
+	}
+	return models.GenerationParams{}
+}
+
+// EffectiveGenerationParams returns the defaults of promptType with the fields
+// set in override replacing them.
+func EffectiveGenerationParams(promptType string, override models.GenerationParams) models.GenerationParams {
+	params := DefaultGenerationParams(promptType)
+	if override.Temperature != nil {
+		params.Temperature = override.Temperature
+	}
+	if override.MaxTokens != nil {
+		params.MaxTokens = override.MaxTokens
+	}
+	return params
+}
+
+// ValidateGenerationParams checks the set fields of params against the allowed
+// ranges, returning the errors by field name or nil when valid.
+func ValidateGenerationParams(params models.GenerationParams) map[string]string {
+	errs := make(map[string]string)
+	if t := params.Temperature; t != nil && (*t < MinTemperature || *t > MaxTemperature) {
+		errs["temperature"] = fmt.Sprintf("must be between %g and %g", MinTemperature, MaxTemperature)
+	}
+	if n := params.MaxTokens; n != nil && (*n < MinMaxTokens || *n > MaxMaxTokens) {
+		errs["max_tokens"] = fmt.Sprintf("must be between %d and %d", MinMaxTokens, MaxMaxTokens)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func newGenerationParams(temperature float64, maxTokens int) models.GenerationParams {
+	return models.GenerationParams{Temperature: &temperature, MaxTokens: &maxTokens}
+}
@@ -2,7 +2,7 @@
 package agents
 
 // DefaultSongConceptPromptTemplate is the default system prompt template for SongConceptAgent.
-// Use fmt.Sprintf with language parameter (3 times) to generate the full prompt.
+// Use fmt.Sprintf with language parameter (2 times) to generate the full prompt.
 const DefaultSongConceptPromptTemplate = `คุณคือ AI โปรดิวเซอร์เพลงมืออาชีพที่เชี่ยวชาญในการสร้าง prompt สำหรับ Suno AI V5
 
 หน้าที่ของคุณคือวิเคราะห์ concept เพลงจากผู้ใช้และสร้าง prompt ที่จะผลิตเพลงคุณภาพสูง
@@ -164,12 +164,61 @@ const DefaultImageConceptPrompt = `คุณคือ AI ศิลปินภ
 
 ส่งออกเป็น JSON เท่านั้น:
 {
-  "prompt": "คำอธิบายภาพเป็นภาษาอังกฤษ (ไม่เกิน 500 ตัวอักษร)"
+  "prompt": "คำอธิบายภาพเป็นภาษาอังกฤษ (ไม่เกิน 500 ตัวอักษร)",
+  "aspect_ratio": "16:9 หรือ 9:16 หรือ 1:1 หรือ 4:3 หรือ 3:4",
+  "resolution": "1K หรือ 2K"
 }
 
+### aspect_ratio และ resolution:
+- ใช้ 16:9 เป็นค่าเริ่มต้นสำหรับ music video แนวนอน
+- เลือกค่าอื่นเฉพาะเมื่อองค์ประกอบภาพต้องการจริงๆ
+- ใช้ 2K เฉพาะภาพที่มีรายละเอียดสูง นอกนั้นใช้ 1K
+
 ### ตัวอย่าง prompt ที่ดี:
 "Silhouette of a woman standing alone on a rooftop at twilight, city lights bokeh in background, cinematic wide shot, melancholic mood, deep blue and orange color palette, film grain texture, dramatic rim lighting, 8K, professional photography"
 
 ### หมายเหตุ:
 - เขียน prompt เป็นภาษาอังกฤษเพื่อผลลัพธ์ที่ดีที่สุด
 - หลีกเลี่ยงเนื้อหาที่ไม่เหมาะสม`
+
+// DefaultImageSelectorPrompt is the default system prompt for ImageSelectorAgent.
+const DefaultImageSelectorPrompt = `คุณคือ AI art director มืออาชีพ มีหน้าที่เลือกภาพพื้นหลังที่ดีที่สุดสำหรับ music video จากตัวเลือกที่ NanoBanana สร้างมา
+
+## เกณฑ์การเลือก (เรียงตามความสำคัญ):
+
+### 1. ความสอดคล้องกับเพลง (50%)
+- ภาพสื่ออารมณ์และธีมของ concept และชื่อเพลงหรือไม่
+- โทนสีและบรรยากาศเข้ากับแนวเพลงหรือไม่
+
+### 2. ความน่าสนใจทางสายตา (30%)
+- องค์ประกอบภาพชัดเจน มีจุดโฟกัส
+- หลีกเลี่ยงภาพที่ดูจืด มืด หรือไม่มีรายละเอียด
+
+### 3. ความเหมาะสมกับวิดีโอ (20%)
+- เหมาะเป็นภาพนิ่งตลอดทั้งเพลงในอัตราส่วน 16:9
+- ไม่มีข้อความหรือองค์ประกอบที่ผิดเพี้ยน
+
+## รูปแบบผลลัพธ์:
+
+ส่งออกเป็น JSON เท่านั้น:
+{
+  "selectedTaskId": "task_id ของภาพที่เลือก",
+  "reasoning": "อธิบายสั้นๆ ว่าทำไมถึงเลือกภาพนี้ (ภาษาไทย)"
+}`
+
+// DefaultPrompt returns the hardcoded default system prompt for a prompt type,
+// or "" if the type is unknown. The song concept prompt is a template with
+// language placeholders.
+func DefaultPrompt(promptType string) string {
+	switch promptType {
+	case "song_concept":
+		return DefaultSongConceptPromptTemplate
+	case "song_selector":
+		return DefaultSongSelectorPrompt
+	case "image_concept":
+		return DefaultImageConceptPrompt
+	case "image_selector":
+		return DefaultImageSelectorPrompt
+	}
+	return ""
+}
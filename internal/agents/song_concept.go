@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/jaochai/ugc/internal/external/kie"
 	"github.com/jaochai/ugc/internal/external/openrouter"
 	"github.com/jaochai/ugc/internal/models"
 	"go.uber.org/zap"
@@ -12,13 +13,14 @@ import (
 
 // SongConceptInput represents the input for song concept analysis.
 type SongConceptInput struct {
-	Concept  string // User's song idea/concept
-	Language string // Language for lyrics (default: "Thai")
+	Concept   string // User's song idea/concept
+	Language  string // Language for lyrics (default: "Thai")
+	SunoModel string // Suno model the prompt is for; its length limits apply (default: kie.DefaultModel)
 }
 
 // SongConceptOutput represents the output from song concept analysis.
 // Note: Model is NOT included - LLM doesn't have knowledge about Suno API versions.
-// Model is hardcoded to "V5" in ToSongPrompt().
+// The Suno model is chosen by the job or user and passed to ToSongPrompt().
 type SongConceptOutput struct {
 	Prompt       string `json:"prompt"`       // Lyrics/description for Suno
 	Style        string `json:"style"`        // Music style (e.g., "pop ballad", "rock", "EDM")
@@ -27,15 +29,15 @@ type SongConceptOutput struct {
 	Instrumental bool   `json:"instrumental"` // Whether the song should be instrumental
 }
 
-// ToSongPrompt converts SongConceptOutput to models.SongPrompt.
-// Model is hardcoded to "V5" - LLM doesn't have knowledge about Suno API versions.
-func (o *SongConceptOutput) ToSongPrompt() *models.SongPrompt {
+// ToSongPrompt converts SongConceptOutput to models.SongPrompt for the given
+// Suno model; unsupported or empty models fall back to kie.DefaultModel.
+func (o *SongConceptOutput) ToSongPrompt(sunoModel string) *models.SongPrompt {
 	return &models.SongPrompt{
 		Prompt:       o.Prompt,
 		Style:        o.Style,
 		Title:        o.Title,
 		TitleEn:      o.TitleEn,
-		Model:        "V5", // Hardcoded - LLM cannot reliably select Suno model versions
+		Model:        kie.ResolveModel(sunoModel),
 		Instrumental: o.Instrumental,
 	}
 }
@@ -47,7 +49,7 @@ type SongConceptAgent struct {
 }
 
 // NewSongConceptAgent creates a new SongConceptAgent instance.
-func NewSongConceptAgent(llmClient *openrouter.Client, model string, logger *zap.Logger) *SongConceptAgent {
+func NewSongConceptAgent(llmClient openrouter.ChatClient, model string, logger *zap.Logger) *SongConceptAgent {
 	return &SongConceptAgent{
 		BaseAgent:    NewBaseAgent(llmClient, model, logger),
 		customPrompt: nil,
@@ -55,7 +57,7 @@ func NewSongConceptAgent(llmClient *openrouter.Client, model string, logger *zap
 }
 
 // NewSongConceptAgentWithPrompt creates a new SongConceptAgent with a custom system prompt.
-func NewSongConceptAgentWithPrompt(llmClient *openrouter.Client, model string, logger *zap.Logger, customPrompt *string) *SongConceptAgent {
+func NewSongConceptAgentWithPrompt(llmClient openrouter.ChatClient, model string, logger *zap.Logger, customPrompt *string) *SongConceptAgent {
 	return &SongConceptAgent{
 		BaseAgent:    NewBaseAgent(llmClient, model, logger),
 		customPrompt: customPrompt,
@@ -70,7 +72,7 @@ func (a *SongConceptAgent) systemPrompt(language string) string {
 		return fmt.Sprintf(*a.customPrompt, language, language, language)
 	}
 	// Use default prompt template
-	return fmt.Sprintf(DefaultSongConceptPromptTemplate, language, language, language)
+	return fmt.Sprintf(DefaultSongConceptPromptTemplate, language, language)
 }
 
 // Analyze processes a song concept and generates an optimized Suno prompt.
@@ -100,7 +102,7 @@ func (a *SongConceptAgent) Analyze(ctx context.Context, input SongConceptInput)
 	}
 
 	// Validate the output
-	if err := a.validateOutput(&output); err != nil {
+	if err := a.validateOutput(&output, kie.ResolveModel(input.SunoModel)); err != nil {
 		a.Logger().Error("invalid output from LLM",
 			zap.Error(err),
 		)
@@ -116,20 +118,19 @@ func (a *SongConceptAgent) Analyze(ctx context.Context, input SongConceptInput)
 	return &output, nil
 }
 
-// validateOutput validates the SongConceptOutput.
-func (a *SongConceptAgent) validateOutput(output *SongConceptOutput) error {
+// validateOutput validates the SongConceptOutput against the limits of sunoModel.
+func (a *SongConceptAgent) validateOutput(output *SongConceptOutput, sunoModel string) error {
 	if output.Prompt == "" {
 		return fmt.Errorf("prompt is required")
 	}
-	// Suno V5 supports up to 5000 characters for prompt
-	if len(output.Prompt) > 5000 {
-		return fmt.Errorf("prompt exceeds 5000 character limit")
-	}
 	if output.Style == "" {
 		return fmt.Errorf("style is required")
 	}
 	if output.Title == "" {
 		return fmt.Errorf("title is required")
 	}
+	if err := kie.LimitsFor(sunoModel).Validate(output.Prompt, output.Style, output.Title); err != nil {
+		return fmt.Errorf("%s: %w", sunoModel, err)
+	}
 	return nil
 }
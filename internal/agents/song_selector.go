@@ -24,6 +24,9 @@ type SongCandidate struct {
 type SongSelectorInput struct {
 	OriginalConcept string          `json:"original_concept"`
 	Songs           []SongCandidate `json:"songs"`
+	// MaxDurationSeconds is the job's video length cap; 0 is unlimited. Songs
+	// within it are preferred, longer ones are trimmed.
+	MaxDurationSeconds float64 `json:"max_duration_seconds,omitempty"`
 }
 
 // SongSelectorOutput is the output from the song selector agent.
@@ -39,7 +42,7 @@ type SongSelectorAgent struct {
 }
 
 // NewSongSelectorAgent creates a new SongSelectorAgent.
-func NewSongSelectorAgent(llmClient *openrouter.Client, model string, logger *zap.Logger) *SongSelectorAgent {
+func NewSongSelectorAgent(llmClient openrouter.ChatClient, model string, logger *zap.Logger) *SongSelectorAgent {
 	return &SongSelectorAgent{
 		BaseAgent:    NewBaseAgent(llmClient, model, logger),
 		customPrompt: nil,
@@ -47,7 +50,7 @@ func NewSongSelectorAgent(llmClient *openrouter.Client, model string, logger *za
 }
 
 // NewSongSelectorAgentWithPrompt creates a new SongSelectorAgent with a custom system prompt.
-func NewSongSelectorAgentWithPrompt(llmClient *openrouter.Client, model string, logger *zap.Logger, customPrompt *string) *SongSelectorAgent {
+func NewSongSelectorAgentWithPrompt(llmClient openrouter.ChatClient, model string, logger *zap.Logger, customPrompt *string) *SongSelectorAgent {
 	return &SongSelectorAgent{
 		BaseAgent:    NewBaseAgent(llmClient, model, logger),
 		customPrompt: customPrompt,
@@ -68,6 +71,13 @@ func (a *SongSelectorAgent) Select(ctx context.Context, input SongSelectorInput)
 		return nil, fmt.Errorf("no song candidates provided")
 	}
 
+	// Longer songs would be trimmed, so choose among those within the cap if any are
+	reasoning := "Only one song candidate available, selected automatically."
+	if within := songsWithin(input.Songs, input.MaxDurationSeconds); len(within) > 0 && len(within) < len(input.Songs) {
+		input.Songs = within
+		reasoning = fmt.Sprintf("Only song within the %.0f-second limit, selected automatically.", input.MaxDurationSeconds)
+	}
+
 	// If only one song, return it directly
 	if len(input.Songs) == 1 {
 		a.Logger().Info("only one song candidate, selecting it automatically",
@@ -76,7 +86,7 @@ func (a *SongSelectorAgent) Select(ctx context.Context, input SongSelectorInput)
 		)
 		return &SongSelectorOutput{
 			SelectedSongID: input.Songs[0].ID,
-			Reasoning:      "Only one song candidate available, selected automatically.",
+			Reasoning:      reasoning,
 		}, nil
 	}
 
@@ -84,12 +94,12 @@ func (a *SongSelectorAgent) Select(ctx context.Context, input SongSelectorInput)
 	userPrompt := a.buildUserPrompt(input)
 
 	a.Logger().Debug("sending song selection request to LLM",
-		zap.String("concept", input.OriginalConcept),
+		zap.String("concept", truncateString(input.OriginalConcept, 100)),
 		zap.Int("candidate_count", len(input.Songs)),
 	)
 
 	// Call LLM
-	response, err := a.LLMClient().ChatWithModel(ctx, a.Model(), a.getSystemPrompt(), userPrompt)
+	response, err := a.Chat(ctx, a.getSystemPrompt(), userPrompt)
 	if err != nil {
 		a.Logger().Error("failed to call LLM for song selection",
 			zap.Error(err),
@@ -136,11 +146,31 @@ func (a *SongSelectorAgent) buildUserPrompt(input SongSelectorInput) string {
 			song.ID, song.Title, song.Duration))
 	}
 
+	if input.MaxDurationSeconds > 0 {
+		sb.WriteString(fmt.Sprintf("\nMaximum duration: %.0f seconds. A longer song is trimmed to it with a fade-out, so prefer a song that is shorter.\n",
+			input.MaxDurationSeconds))
+	}
+
 	sb.WriteString("\nSelect the best song and explain your reasoning.")
 
 	return sb.String()
 }
 
+// songsWithin returns the songs no longer than maxSeconds, or all songs when
+// maxSeconds is 0.
+func songsWithin(songs []SongCandidate, maxSeconds float64) []SongCandidate {
+	if maxSeconds <= 0 {
+		return songs
+	}
+	within := make([]SongCandidate, 0, len(songs))
+	for _, song := range songs {
+		if song.Duration <= maxSeconds {
+			within = append(within, song)
+		}
+	}
+	return within
+}
+
 // parseResponse parses the LLM response into SongSelectorOutput.
 func (a *SongSelectorAgent) parseResponse(response string) (*SongSelectorOutput, error) {
 	// Clean up response - remove markdown code blocks if present
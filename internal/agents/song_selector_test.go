@@ -0,0 +1,130 @@
+package agents
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// TestSongSelectorPrefersSongsWithinMaxDuration checks which candidates reach
+// the LLM under a duration cap, and that the cap is stated in the prompt.
+func TestSongSelectorPrefersSongsWithinMaxDuration(t *testing.T) {
+	songs := []SongCandidate{
+		{ID: "short", Title: "แสงไฟ", Duration: 95},
+		{ID: "at-cap", Title: "แสงไฟ", Duration: 120},
+		{ID: "long", Title: "แสงไฟ", Duration: 372},
+	}
+
+	tests := []struct {
+		name        string
+		maxSeconds  float64
+		reply       string   // LLM reply; empty when the LLM must not be asked
+		wantID      string   // Selected song
+		wantOffered []string // Candidates listed in the prompt
+		wantCapLine bool
+	}{
+		{
+			name:        "no cap offers every song",
+			reply:       `{"selectedSongId": "long", "reasoning": "best chorus"}`,
+			wantID:      "long",
+			wantOffered: []string{"short", "at-cap", "long"},
+		},
+		{
+			name:        "cap offers only the songs within it",
+			maxSeconds:  120,
+			reply:       `{"selectedSongId": "at-cap", "reasoning": "fits"}`,
+			wantID:      "at-cap",
+			wantOffered: []string{"short", "at-cap"},
+			wantCapLine: true,
+		},
+		{
+			name:       "one song within the cap is selected without the LLM",
+			maxSeconds: 100,
+			wantID:     "short",
+		},
+		{
+			name:        "no song within the cap offers every song",
+			maxSeconds:  60,
+			reply:       `{"selectedSongId": "short", "reasoning": "least trimmed"}`,
+			wantID:      "short",
+			wantOffered: []string{"short", "at-cap", "long"},
+			wantCapLine: true,
+		},
+		{
+			name:        "every song within the cap",
+			maxSeconds:  600,
+			reply:       `{"selectedSongId": "long", "reasoning": "best chorus"}`,
+			wantID:      "long",
+			wantOffered: []string{"short", "at-cap", "long"},
+			wantCapLine: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat := testutil.NewFakeChatClient()
+			if tt.reply != "" {
+				chat = testutil.NewFakeChatClient(tt.reply)
+			}
+			agent := NewSongSelectorAgent(chat, "test/model", zap.NewNop())
+
+			output, err := agent.Select(context.Background(), SongSelectorInput{
+				OriginalConcept:    "เพลงรักในเมืองหลวง",
+				Songs:              songs,
+				MaxDurationSeconds: tt.maxSeconds,
+			})
+			if err != nil {
+				t.Fatalf("Select: %v", err)
+			}
+			if output.SelectedSongID != tt.wantID {
+				t.Errorf("selected %s, want %s", output.SelectedSongID, tt.wantID)
+			}
+
+			if tt.reply == "" {
+				if len(chat.Requests) != 0 {
+					t.Errorf("the LLM was asked %d times, want none", len(chat.Requests))
+				}
+				return
+			}
+			if len(chat.Requests) != 1 {
+				t.Fatalf("the LLM was asked %d times, want once", len(chat.Requests))
+			}
+			messages := chat.Requests[0].Messages
+			prompt := messages[len(messages)-1].Content
+			for _, song := range songs {
+				offered := strings.Contains(prompt, "ID: "+song.ID+",")
+				if want := slices.Contains(tt.wantOffered, song.ID); offered != want {
+					t.Errorf("song %s offered = %v, want %v; prompt:\n%s", song.ID, offered, want, prompt)
+				}
+			}
+			if hasCap := strings.Contains(prompt, "Maximum duration:"); hasCap != tt.wantCapLine {
+				t.Errorf("prompt states the cap = %v, want %v; prompt:\n%s", hasCap, tt.wantCapLine, prompt)
+			}
+		})
+	}
+}
+
+// TestSongSelectorRejectsSongOverMaxDuration checks that the LLM cannot pick a
+// song the cap excluded from its candidates.
+func TestSongSelectorRejectsSongOverMaxDuration(t *testing.T) {
+	chat := testutil.NewFakeChatClient(`{"selectedSongId": "long", "reasoning": "best chorus"}`)
+	agent := NewSongSelectorAgent(chat, "test/model", zap.NewNop())
+
+	_, err := agent.Select(context.Background(), SongSelectorInput{
+		OriginalConcept: "เพลงรักในเมืองหลวง",
+		Songs: []SongCandidate{
+			{ID: "short", Duration: 95},
+			{ID: "mid", Duration: 110},
+			{ID: "long", Duration: 372},
+		},
+		MaxDurationSeconds: 120,
+	})
+	if err == nil || !strings.Contains(err.Error(), `"long" not found`) {
+		t.Errorf("Select error = %v, want the excluded song rejected", err)
+	}
+}
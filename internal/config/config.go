@@ -1,7 +1,12 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -14,6 +19,7 @@ type Config struct {
 	Database    DatabaseConfig
 	Redis       RedisConfig
 	JWT         JWTConfig
+	Auth        AuthConfig
 	R2          R2Config
 	KIE         KIEConfig
 	OpenRouter  OpenRouterConfig
@@ -21,6 +27,11 @@ type Config struct {
 	CORS        CORSConfig
 	Crypto      CryptoConfig
 	YouTube     YouTubeConfig
+	Pipeline    PipelineConfig
+	Worker      WorkerConfig
+	Metrics     MetricsConfig
+	Health      HealthConfig
+	SMTP        SMTPConfig
 	FrontendURL string // Frontend base URL for OAuth redirects (e.g. https://www.thinkclip.xyz)
 }
 
@@ -29,15 +40,37 @@ type CORSConfig struct {
 	Origins []string // Comma-separated list of allowed origins
 }
 
+// Run modes select which components a process starts.
+const (
+	ModeAPI    = "api"    // HTTP API only
+	ModeWorker = "worker" // Asynq worker only
+	ModeAll    = "all"    // API and worker in one process
+)
+
 // ServerConfig holds server-related configuration.
 type ServerConfig struct {
-	Port string
-	Env  string // development, staging, production
+	Port             string
+	Env              string // development, staging, production
+	Mode             string // api, worker or all
+	WorkerHealthPort string // Port for /health in worker mode
+	DocsEnabled      bool   // Serve /api/v1/docs and /api/v1/openapi.json; defaults to off in production
+	DocsSpecPath     string // Spec generated by go generate ./cmd/ugc
+
+	MaxBodyBytes        int64 // Largest request body accepted by the JSON API routes
+	WebhookMaxBodyBytes int64 // Largest callback body accepted by the webhook routes
+	MaxJSONDepth        int   // Deepest nesting of objects and arrays accepted in JSON bodies
+
+	TrustedProxies []string // CIDRs or IPs whose X-Forwarded-For is honoured; empty trusts none
 }
 
 // DatabaseConfig holds database-related configuration.
 type DatabaseConfig struct {
-	URL string // Neon PostgreSQL connection string
+	URL                string        // Neon PostgreSQL connection string
+	MaxConns           int           // Maximum pool size
+	MinConns           int           // Connections kept open when idle (0-MaxConns)
+	MaxConnLifetime    time.Duration // Connections are recycled after this long
+	AcquireTimeout     time.Duration // Longest wait for a free connection for any statement
+	SlowQueryThreshold time.Duration // Queries running at least this long are logged; 0 disables
 }
 
 // RedisConfig holds Redis-related configuration for Asynq.
@@ -47,8 +80,21 @@ type RedisConfig struct {
 
 // JWTConfig holds JWT-related configuration.
 type JWTConfig struct {
-	Secret string
-	Expiry time.Duration
+	Secret        string
+	AccessExpiry  time.Duration // Lifetime of access tokens (JWT)
+	RefreshExpiry time.Duration // Lifetime of opaque refresh tokens
+}
+
+// AuthConfig holds brute-force protection for the authentication endpoints.
+type AuthConfig struct {
+	RateLimitRPS       int           // Rate limit requests per second per IP on public auth routes
+	RateLimitBurst     int           // Rate limit burst size
+	MaxLoginFailures   int           // Consecutive failed logins that lock an email address
+	LoginFailureWindow time.Duration // Failed logins older than this are forgotten
+	LockoutDuration    time.Duration // How long a locked address stays locked
+
+	TurnstileSecret        string   // Cloudflare Turnstile secret; registration requires a captcha when set
+	DisposableEmailDomains []string // Email domains (and their subdomains) rejected at registration
 }
 
 // R2Config holds Cloudflare R2-related configuration.
@@ -58,31 +104,66 @@ type R2Config struct {
 	SecretAccessKey string
 	BucketName      string
 	PublicURL       string
+
+	CleanupEnabled     bool          // Periodically delete assets of deleted jobs and long-failed jobs
+	CleanupDryRun      bool          // Log the objects the cleanup would delete instead of deleting them
+	CleanupFailedAfter time.Duration // How long the assets of failed jobs are kept
+	CleanupMaxDeletes  int           // Objects deleted per cleanup pass at most
+	// CleanupMaxMissingRatio aborts a cleanup pass when more of the listed jobs are missing from the database
+	CleanupMaxMissingRatio float64
 }
 
 // KIEConfig holds KIE API configuration.
 type KIEConfig struct {
 	APIKey  string
 	BaseURL string
+
+	// Job creation logs a warning below CreditsLowThreshold and is rejected at zero
+	CreditsLowThreshold int
+	CreditsCacheTTL     time.Duration // How long a user's balance is cached
+
+	// Estimated credits per generation task, recorded as user spend
+	MusicCreditCost int
+	ImageCreditCost int
+
+	// AllowPlatformKey runs jobs of users without their own key on APIKey, capped
+	// at PlatformMonthlyCredits estimated credits per user per calendar month
+	AllowPlatformKey       bool
+	PlatformMonthlyCredits int
 }
 
 // OpenRouterConfig holds OpenRouter API configuration.
 type OpenRouterConfig struct {
-	APIKey string
+	APIKey  string
+	BaseURL string // Chat API base URL; empty uses openrouter.ai (set to point workers at a fake server)
+
+	// AllowPlatformKey runs jobs of users without their own key on APIKey,
+	// capped at PlatformDailyJobs jobs per user per 24 hours
+	AllowPlatformKey  bool
+	PlatformDailyJobs int
 }
 
 // WebhookConfig holds webhook-related configuration.
 type WebhookConfig struct {
 	BaseURL        string
-	Secret         string        // Secret token for webhook authentication
-	RateLimitRPS   int           // Rate limit requests per second
-	RateLimitBurst int           // Rate limit burst size
-	AllowedHosts   []string      // Allowed hosts for URL validation (SSRF prevention)
+	Secret         string // Secret token for webhook authentication
+	PreviousSecret string // Secret replaced by the last rotation, still accepted for in-flight callbacks
+	// LegacyTokensUntil is when callback URLs carrying the raw secret, issued
+	// before per-job tokens, stop being accepted. A fixed time, so restarts do not
+	// extend it; zero rejects them.
+	LegacyTokensUntil time.Time
+	RateLimitRPS      int      // Rate limit requests per second
+	RateLimitBurst    int      // Rate limit burst size
+	AllowedHosts      []string // Allowed hosts for URL validation (SSRF prevention)
+
+	CaptureEnabled   bool          // Store raw callbacks in webhook_events for debugging
+	CaptureRetention time.Duration // How long captured callbacks are kept
 }
 
 // CryptoConfig holds encryption-related configuration.
 type CryptoConfig struct {
-	EncryptionKey string // Base64-encoded 32-byte key for AES-256
+	EncryptionKey string   // Base64-encoded 32-byte key for AES-256
+	LegacyKeys    []string // Previous keys, still accepted for decryption while rotating
 }
 
 // YouTubeConfig holds YouTube API configuration (optional).
@@ -92,6 +173,57 @@ type YouTubeConfig struct {
 	RedirectURI  string
 }
 
+// PipelineConfig holds defaults for the generation pipeline.
+type PipelineConfig struct {
+	ImageCandidates      int           // Default number of image candidates per job (1-3)
+	SystemPromptCacheTTL time.Duration // How long system prompts are cached in memory
+	SunoCompleteGrace    time.Duration // How long to wait for Suno's "complete" callback after "first"
+	ConceptModeration    string        // off, log or enforce; checks concepts before a job starts
+	BulkJobsPerMinute    int           // Bulk job create requests allowed per user per minute
+	MaxConceptLength     int           // Longest job concept accepted, in characters
+	VideoPreset          string        // Encoding preset for jobs that do not pick one: standard, high, small or h265
+	SyncJobsEnabled      bool          // Allow POST /jobs?sync=true to run the pipeline inline; never in production
+}
+
+// WorkerConfig holds Asynq worker and FFmpeg resource limits.
+type WorkerConfig struct {
+	Concurrency         int           // Maximum number of tasks processed at once (1-100)
+	FFmpegMaxConcurrent int           // Maximum number of simultaneous FFmpeg encodes (1-Concurrency)
+	DrainTimeout        time.Duration // How long shutdown waits for in-flight tasks before cancelling them
+	// Videos longer than VideoSegmentThreshold are rendered in VideoSegmentLength
+	// pieces kept in VideoWorkDir, so a retried render resumes; 0 disables.
+	VideoSegmentThreshold time.Duration
+	VideoSegmentLength    time.Duration
+	VideoWorkDir          string // Parent of the per-job render directories; defaults to {tmp}/ugc-render
+
+	VideoMaxAudioBytes int64 // Largest song audio downloaded for a render
+	VideoMaxImageBytes int64 // Largest cover image downloaded for a render
+}
+
+// MetricsConfig holds Prometheus /metrics endpoint configuration.
+type MetricsConfig struct {
+	Enabled  bool
+	Username string // Basic auth username; auth is disabled when empty
+	Password string
+}
+
+// HealthConfig controls which dependencies the readiness probe treats as optional.
+type HealthConfig struct {
+	RedisOptional    bool          // Readiness passes when Redis is missing or down
+	R2Optional       bool          // Readiness passes when R2 is missing or down
+	DBMaxAcquireWait time.Duration // Readiness fails when the average pool acquire wait exceeds this; 0 disables
+}
+
+// SMTPConfig holds outgoing mail configuration (optional). Emails are only logged
+// when Host is empty.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string // Sender, e.g. "UGC <noreply@example.com>"
+}
+
 // Load reads configuration from environment variables and .env file.
 func Load() (*Config, error) {
 	viper.SetConfigFile(".env")
@@ -107,67 +239,296 @@ func Load() (*Config, error) {
 	// Set defaults
 	viper.SetDefault("SERVER_PORT", "8080")
 	viper.SetDefault("SERVER_ENV", "development")
-	viper.SetDefault("JWT_EXPIRY", "24h")
+	viper.SetDefault("SERVER_MODE", ModeAll)
+	viper.SetDefault("DB_MAX_CONNS", 25)
+	viper.SetDefault("DB_MIN_CONNS", 5)
+	viper.SetDefault("DB_MAX_CONN_LIFETIME", "1h")
+	viper.SetDefault("DB_ACQUIRE_TIMEOUT", "5s")
+	viper.SetDefault("DB_SLOW_QUERY_THRESHOLD", "500ms")
+	viper.SetDefault("HEALTH_DB_MAX_ACQUIRE_WAIT", "1s")
+	viper.SetDefault("WORKER_HEALTH_PORT", "8081")
+	viper.SetDefault("API_DOCS_SPEC_PATH", "docs/swagger.json")
+	viper.SetDefault("MAX_REQUEST_BODY_BYTES", 64<<10)
+	viper.SetDefault("WEBHOOK_MAX_BODY_BYTES", 256<<10)
+	viper.SetDefault("MAX_JSON_DEPTH", 32)
+	viper.SetDefault("REFRESH_EXPIRY", "720h")
 	viper.SetDefault("WEBHOOK_RATE_LIMIT_RPS", 10)
 	viper.SetDefault("WEBHOOK_RATE_LIMIT_BURST", 20)
+	viper.SetDefault("AUTH_RATE_LIMIT_RPS", 5)
+	viper.SetDefault("AUTH_RATE_LIMIT_BURST", 10)
+	viper.SetDefault("LOGIN_MAX_FAILURES", 5)
+	viper.SetDefault("LOGIN_FAILURE_WINDOW", "15m")
+	viper.SetDefault("LOGIN_LOCKOUT_DURATION", "15m")
+	viper.SetDefault("WEBHOOK_CAPTURE_ENABLED", false)
+	viper.SetDefault("WEBHOOK_CAPTURE_RETENTION", "168h")
+	viper.SetDefault("IMAGE_CANDIDATES", 1)
+	viper.SetDefault("SYSTEM_PROMPT_CACHE_TTL", "5m")
+	viper.SetDefault("KIE_CREDITS_LOW_THRESHOLD", 50)
+	viper.SetDefault("KIE_MUSIC_CREDIT_COST", 12)
+	viper.SetDefault("KIE_IMAGE_CREDIT_COST", 18)
+	viper.SetDefault("ALLOW_PLATFORM_OPENROUTER_KEY", false)
+	viper.SetDefault("ALLOW_PLATFORM_KIE_KEY", false)
+	viper.SetDefault("PLATFORM_KIE_MONTHLY_CREDITS", 1000)
+	viper.SetDefault("PLATFORM_OPENROUTER_DAILY_JOBS", 5)
+	viper.SetDefault("KIE_CREDITS_CACHE_TTL", "5m")
+	viper.SetDefault("SUNO_COMPLETE_GRACE", "90s")
+	viper.SetDefault("CONCEPT_MODERATION", "off")
+	viper.SetDefault("BULK_JOBS_PER_MINUTE", 2)
+	viper.SetDefault("MAX_CONCEPT_LENGTH", 2000)
+	viper.SetDefault("VIDEO_PRESET", "standard")
+	viper.SetDefault("R2_CLEANUP_ENABLED", false)
+	viper.SetDefault("R2_CLEANUP_DRY_RUN", true)
+	viper.SetDefault("R2_CLEANUP_FAILED_DAYS", 30)
+	viper.SetDefault("R2_CLEANUP_MAX_DELETES", 500)
+	viper.SetDefault("R2_CLEANUP_MAX_MISSING_RATIO", 0.5)
+	viper.SetDefault("WORKER_CONCURRENCY", 10)
+	viper.SetDefault("FFMPEG_MAX_CONCURRENT", 2)
+	viper.SetDefault("WORKER_DRAIN_TIMEOUT", "2m")
+	viper.SetDefault("VIDEO_SEGMENT_THRESHOLD", "6m")
+	viper.SetDefault("VIDEO_SEGMENT_LENGTH", "2m")
+	viper.SetDefault("VIDEO_MAX_AUDIO_BYTES", 200<<20)
+	viper.SetDefault("VIDEO_MAX_IMAGE_BYTES", 20<<20)
+	viper.SetDefault("METRICS_ENABLED", true)
+	viper.SetDefault("SMTP_PORT", 587)
 	viper.SetDefault("WEBHOOK_ALLOWED_HOSTS", "suno.ai,suno.com,audiopipe.suno.ai,cdn1.suno.ai,cdn2.suno.ai,kie.ai,cdn.kie.ai,storage.kie.ai,musicfile.kie.ai,s3.amazonaws.com,s3.us-east-1.amazonaws.com,s3.us-west-2.amazonaws.com,nanobananastorage.blob.core.windows.net,aiquickdraw.com")
 
-	// Parse JWT expiry duration
-	jwtExpiry, err := time.ParseDuration(viper.GetString("JWT_EXPIRY"))
+	// Parse token expiry durations; JWT_EXPIRY is the legacy name of ACCESS_EXPIRY
+	accessExpiryValue := viper.GetString("ACCESS_EXPIRY")
+	if accessExpiryValue == "" {
+		accessExpiryValue = viper.GetString("JWT_EXPIRY")
+	}
+	accessExpiry, err := time.ParseDuration(accessExpiryValue)
+	if err != nil || accessExpiry <= 0 {
+		accessExpiry = 15 * time.Minute
+	}
+
+	refreshExpiry, err := time.ParseDuration(viper.GetString("REFRESH_EXPIRY"))
+	if err != nil || refreshExpiry <= 0 {
+		refreshExpiry = 30 * 24 * time.Hour
+	}
+
+	// Parse login lockout durations
+	loginFailureWindow, err := time.ParseDuration(viper.GetString("LOGIN_FAILURE_WINDOW"))
+	if err != nil || loginFailureWindow <= 0 {
+		loginFailureWindow = 15 * time.Minute
+	}
+
+	lockoutDuration, err := time.ParseDuration(viper.GetString("LOGIN_LOCKOUT_DURATION"))
+	if err != nil || lockoutDuration <= 0 {
+		lockoutDuration = 15 * time.Minute
+	}
+
+	// Parse system prompt cache TTL
+	promptCacheTTL, err := time.ParseDuration(viper.GetString("SYSTEM_PROMPT_CACHE_TTL"))
+	if err != nil || promptCacheTTL <= 0 {
+		promptCacheTTL = 5 * time.Minute
+	}
+
+	// Parse database pool durations; invalid values are reported by Validate
+	dbMaxConnLifetime, _ := time.ParseDuration(viper.GetString("DB_MAX_CONN_LIFETIME"))
+	dbAcquireTimeout, _ := time.ParseDuration(viper.GetString("DB_ACQUIRE_TIMEOUT"))
+	dbSlowQueryThreshold, err := time.ParseDuration(viper.GetString("DB_SLOW_QUERY_THRESHOLD"))
 	if err != nil {
-		jwtExpiry = 24 * time.Hour
+		dbSlowQueryThreshold = -1
+	}
+	healthDBMaxAcquireWait, err := time.ParseDuration(viper.GetString("HEALTH_DB_MAX_ACQUIRE_WAIT"))
+	if err != nil || healthDBMaxAcquireWait < 0 {
+		healthDBMaxAcquireWait = time.Second
+	}
+
+	// Parse Suno complete callback grace period
+	sunoCompleteGrace, err := time.ParseDuration(viper.GetString("SUNO_COMPLETE_GRACE"))
+	if err != nil || sunoCompleteGrace <= 0 {
+		sunoCompleteGrace = 90 * time.Second
+	}
+
+	// Parse worker drain timeout; zero skips waiting for in-flight tasks
+	workerDrainTimeout, err := time.ParseDuration(viper.GetString("WORKER_DRAIN_TIMEOUT"))
+	if err != nil || workerDrainTimeout < 0 {
+		workerDrainTimeout = 2 * time.Minute
+	}
+
+	// Parse segmented rendering durations; an invalid threshold disables segmenting
+	videoSegmentThreshold, err := time.ParseDuration(viper.GetString("VIDEO_SEGMENT_THRESHOLD"))
+	if err != nil || videoSegmentThreshold < 0 {
+		videoSegmentThreshold = 0
+	}
+	videoSegmentLength, err := time.ParseDuration(viper.GetString("VIDEO_SEGMENT_LENGTH"))
+	if err != nil || videoSegmentLength <= 0 {
+		videoSegmentLength = 2 * time.Minute
+	}
+	videoWorkDir := strings.TrimSpace(viper.GetString("VIDEO_WORK_DIR"))
+	if videoWorkDir == "" {
+		videoWorkDir = filepath.Join(os.TempDir(), "ugc-render")
+	}
+
+	// Parse KIE credits cache TTL
+	kieCreditsCacheTTL, err := time.ParseDuration(viper.GetString("KIE_CREDITS_CACHE_TTL"))
+	if err != nil || kieCreditsCacheTTL <= 0 {
+		kieCreditsCacheTTL = 5 * time.Minute
+	}
+
+	// Parse the legacy webhook token cutoff
+	var legacyTokensUntil time.Time
+	if raw := strings.TrimSpace(viper.GetString("WEBHOOK_LEGACY_TOKENS_UNTIL")); raw != "" {
+		legacyTokensUntil, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("WEBHOOK_LEGACY_TOKENS_UNTIL must be an RFC3339 time, e.g. 2026-01-31T00:00:00Z: %w", err)
+		}
+	}
+
+	// Parse webhook capture retention
+	captureRetention, err := time.ParseDuration(viper.GetString("WEBHOOK_CAPTURE_RETENTION"))
+	if err != nil || captureRetention <= 0 {
+		captureRetention = 7 * 24 * time.Hour
 	}
 
 	cfg := &Config{
 		Server: ServerConfig{
-			Port: viper.GetString("SERVER_PORT"),
-			Env:  viper.GetString("SERVER_ENV"),
+			Port:             viper.GetString("SERVER_PORT"),
+			Env:              viper.GetString("SERVER_ENV"),
+			Mode:             strings.ToLower(strings.TrimSpace(viper.GetString("SERVER_MODE"))),
+			WorkerHealthPort: viper.GetString("WORKER_HEALTH_PORT"),
+			DocsSpecPath:     viper.GetString("API_DOCS_SPEC_PATH"),
+
+			MaxBodyBytes:        viper.GetInt64("MAX_REQUEST_BODY_BYTES"),
+			WebhookMaxBodyBytes: viper.GetInt64("WEBHOOK_MAX_BODY_BYTES"),
+			MaxJSONDepth:        viper.GetInt("MAX_JSON_DEPTH"),
+
+			TrustedProxies: parseCommaSeparated(viper.GetString("TRUSTED_PROXIES")),
 		},
 		Database: DatabaseConfig{
-			URL: viper.GetString("DATABASE_URL"),
+			URL:                viper.GetString("DATABASE_URL"),
+			MaxConns:           viper.GetInt("DB_MAX_CONNS"),
+			MinConns:           viper.GetInt("DB_MIN_CONNS"),
+			MaxConnLifetime:    dbMaxConnLifetime,
+			AcquireTimeout:     dbAcquireTimeout,
+			SlowQueryThreshold: dbSlowQueryThreshold,
 		},
 		Redis: RedisConfig{
 			URL: viper.GetString("REDIS_URL"),
 		},
 		JWT: JWTConfig{
-			Secret: viper.GetString("JWT_SECRET"),
-			Expiry: jwtExpiry,
+			Secret:        viper.GetString("JWT_SECRET"),
+			AccessExpiry:  accessExpiry,
+			RefreshExpiry: refreshExpiry,
+		},
+		Auth: AuthConfig{
+			RateLimitRPS:       viper.GetInt("AUTH_RATE_LIMIT_RPS"),
+			RateLimitBurst:     viper.GetInt("AUTH_RATE_LIMIT_BURST"),
+			MaxLoginFailures:   viper.GetInt("LOGIN_MAX_FAILURES"),
+			LoginFailureWindow: loginFailureWindow,
+			LockoutDuration:    lockoutDuration,
+
+			TurnstileSecret:        viper.GetString("TURNSTILE_SECRET_KEY"),
+			DisposableEmailDomains: parseCommaSeparated(viper.GetString("DISPOSABLE_EMAIL_DOMAINS")),
 		},
 		R2: R2Config{
 			AccountID:       viper.GetString("R2_ACCOUNT_ID"),
 			AccessKeyID:     viper.GetString("R2_ACCESS_KEY_ID"),
 			SecretAccessKey: viper.GetString("R2_SECRET_ACCESS_KEY"),
 			BucketName:      viper.GetString("R2_BUCKET_NAME"),
-			PublicURL:       viper.GetString("R2_PUBLIC_URL"),
+			PublicURL:       strings.TrimRight(viper.GetString("R2_PUBLIC_URL"), "/"),
+
+			CleanupEnabled:     viper.GetBool("R2_CLEANUP_ENABLED"),
+			CleanupDryRun:      viper.GetBool("R2_CLEANUP_DRY_RUN"),
+			CleanupFailedAfter: time.Duration(viper.GetInt("R2_CLEANUP_FAILED_DAYS")) * 24 * time.Hour,
+			CleanupMaxDeletes:  viper.GetInt("R2_CLEANUP_MAX_DELETES"),
+
+			CleanupMaxMissingRatio: viper.GetFloat64("R2_CLEANUP_MAX_MISSING_RATIO"),
 		},
 		KIE: KIEConfig{
 			APIKey:  viper.GetString("KIE_API_KEY"),
 			BaseURL: viper.GetString("KIE_BASE_URL"),
+
+			CreditsLowThreshold: viper.GetInt("KIE_CREDITS_LOW_THRESHOLD"),
+			CreditsCacheTTL:     kieCreditsCacheTTL,
+
+			MusicCreditCost: viper.GetInt("KIE_MUSIC_CREDIT_COST"),
+			ImageCreditCost: viper.GetInt("KIE_IMAGE_CREDIT_COST"),
+
+			AllowPlatformKey:       viper.GetBool("ALLOW_PLATFORM_KIE_KEY"),
+			PlatformMonthlyCredits: viper.GetInt("PLATFORM_KIE_MONTHLY_CREDITS"),
 		},
 		OpenRouter: OpenRouterConfig{
-			APIKey: viper.GetString("OPENROUTER_API_KEY"),
+			APIKey:  viper.GetString("OPENROUTER_API_KEY"),
+			BaseURL: viper.GetString("OPENROUTER_BASE_URL"),
+
+			AllowPlatformKey:  viper.GetBool("ALLOW_PLATFORM_OPENROUTER_KEY"),
+			PlatformDailyJobs: viper.GetInt("PLATFORM_OPENROUTER_DAILY_JOBS"),
 		},
 		Webhook: WebhookConfig{
-			BaseURL:        viper.GetString("WEBHOOK_BASE_URL"),
-			Secret:         viper.GetString("WEBHOOK_SECRET"),
-			RateLimitRPS:   viper.GetInt("WEBHOOK_RATE_LIMIT_RPS"),
-			RateLimitBurst: viper.GetInt("WEBHOOK_RATE_LIMIT_BURST"),
-			AllowedHosts:   parseCommaSeparated(viper.GetString("WEBHOOK_ALLOWED_HOSTS")),
+			BaseURL:           strings.TrimRight(viper.GetString("WEBHOOK_BASE_URL"), "/"),
+			Secret:            viper.GetString("WEBHOOK_SECRET"),
+			PreviousSecret:    viper.GetString("WEBHOOK_SECRET_PREVIOUS"),
+			LegacyTokensUntil: legacyTokensUntil,
+			RateLimitRPS:      viper.GetInt("WEBHOOK_RATE_LIMIT_RPS"),
+			RateLimitBurst:    viper.GetInt("WEBHOOK_RATE_LIMIT_BURST"),
+			AllowedHosts:      parseCommaSeparated(viper.GetString("WEBHOOK_ALLOWED_HOSTS")),
+
+			CaptureEnabled:   viper.GetBool("WEBHOOK_CAPTURE_ENABLED"),
+			CaptureRetention: captureRetention,
 		},
 		CORS: CORSConfig{
 			Origins: parseCORSOrigins(viper.GetString("CORS_ORIGINS")),
 		},
 		Crypto: CryptoConfig{
 			EncryptionKey: viper.GetString("ENCRYPTION_KEY"),
+			LegacyKeys:    parseCommaSeparated(viper.GetString("ENCRYPTION_KEYS_LEGACY")),
 		},
 		YouTube: YouTubeConfig{
 			ClientID:     viper.GetString("YOUTUBE_CLIENT_ID"),
 			ClientSecret: viper.GetString("YOUTUBE_CLIENT_SECRET"),
 			RedirectURI:  viper.GetString("YOUTUBE_REDIRECT_URI"),
 		},
+		Pipeline: PipelineConfig{
+			ImageCandidates:      viper.GetInt("IMAGE_CANDIDATES"),
+			SystemPromptCacheTTL: promptCacheTTL,
+			SunoCompleteGrace:    sunoCompleteGrace,
+			ConceptModeration:    strings.ToLower(strings.TrimSpace(viper.GetString("CONCEPT_MODERATION"))),
+			BulkJobsPerMinute:    viper.GetInt("BULK_JOBS_PER_MINUTE"),
+			MaxConceptLength:     viper.GetInt("MAX_CONCEPT_LENGTH"),
+			VideoPreset:          strings.ToLower(strings.TrimSpace(viper.GetString("VIDEO_PRESET"))),
+			SyncJobsEnabled:      viper.GetBool("SYNC_JOBS_ENABLED"),
+		},
+		Worker: WorkerConfig{
+			Concurrency:         viper.GetInt("WORKER_CONCURRENCY"),
+			FFmpegMaxConcurrent: viper.GetInt("FFMPEG_MAX_CONCURRENT"),
+			DrainTimeout:        workerDrainTimeout,
+
+			VideoSegmentThreshold: videoSegmentThreshold,
+			VideoSegmentLength:    videoSegmentLength,
+			VideoWorkDir:          videoWorkDir,
+
+			VideoMaxAudioBytes: viper.GetInt64("VIDEO_MAX_AUDIO_BYTES"),
+			VideoMaxImageBytes: viper.GetInt64("VIDEO_MAX_IMAGE_BYTES"),
+		},
+		Health: HealthConfig{
+			RedisOptional:    viper.GetBool("HEALTH_REDIS_OPTIONAL"),
+			R2Optional:       viper.GetBool("HEALTH_R2_OPTIONAL"),
+			DBMaxAcquireWait: healthDBMaxAcquireWait,
+		},
+		Metrics: MetricsConfig{
+			Enabled:  viper.GetBool("METRICS_ENABLED"),
+			Username: viper.GetString("METRICS_USERNAME"),
+			Password: viper.GetString("METRICS_PASSWORD"),
+		},
+		SMTP: SMTPConfig{
+			Host:     viper.GetString("SMTP_HOST"),
+			Port:     viper.GetInt("SMTP_PORT"),
+			Username: viper.GetString("SMTP_USERNAME"),
+			Password: viper.GetString("SMTP_PASSWORD"),
+			From:     viper.GetString("SMTP_FROM"),
+		},
 		FrontendURL: strings.TrimRight(viper.GetString("FRONTEND_URL"), "/"),
 	}
 
+	// API docs are public, so production only serves them when explicitly enabled
+	cfg.Server.DocsEnabled = !cfg.IsProduction()
+	if viper.IsSet("API_DOCS_ENABLED") {
+		cfg.Server.DocsEnabled = viper.GetBool("API_DOCS_ENABLED")
+	}
+
 	return cfg, nil
 }
 
@@ -176,6 +537,20 @@ func parseCORSOrigins(originsStr string) []string {
 	return parseCommaSeparated(originsStr)
 }
 
+// isValidEncryptionKey returns true if key is a base64-encoded 32-byte AES-256 key.
+func isValidEncryptionKey(key string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(decoded) == 32
+}
+
+// isValidProxy returns true if proxy is an IP address or CIDR range.
+func isValidProxy(proxy string) bool {
+	if _, _, err := net.ParseCIDR(proxy); err == nil {
+		return true
+	}
+	return net.ParseIP(proxy) != nil
+}
+
 // parseCommaSeparated parses comma-separated string into a slice.
 func parseCommaSeparated(str string) []string {
 	if str == "" {
@@ -210,6 +585,167 @@ func (c *Config) Validate() error {
 	}
 	if c.Crypto.EncryptionKey == "" {
 		errs = append(errs, "ENCRYPTION_KEY is required")
+	} else if !isValidEncryptionKey(c.Crypto.EncryptionKey) {
+		errs = append(errs, "ENCRYPTION_KEY must be a base64-encoded 32-byte key")
+	}
+	for i, key := range c.Crypto.LegacyKeys {
+		if !isValidEncryptionKey(key) {
+			errs = append(errs, fmt.Sprintf("ENCRYPTION_KEYS_LEGACY entry %d must be a base64-encoded 32-byte key", i+1))
+		}
+	}
+
+	switch c.Server.Mode {
+	case ModeAPI, ModeWorker, ModeAll:
+	default:
+		errs = append(errs, "SERVER_MODE must be one of api, worker, all")
+	}
+
+	if c.Server.MaxBodyBytes < 1<<10 {
+		errs = append(errs, "MAX_REQUEST_BODY_BYTES must be at least 1024")
+	}
+	if c.Server.WebhookMaxBodyBytes < 1<<10 {
+		errs = append(errs, "WEBHOOK_MAX_BODY_BYTES must be at least 1024")
+	}
+	if c.Server.MaxJSONDepth < 1 {
+		errs = append(errs, "MAX_JSON_DEPTH must be at least 1")
+	}
+
+	if c.Pipeline.ImageCandidates < 1 || c.Pipeline.ImageCandidates > 3 {
+		errs = append(errs, "IMAGE_CANDIDATES must be between 1 and 3")
+	}
+
+	switch c.Pipeline.ConceptModeration {
+	case "off", "log", "enforce":
+	default:
+		errs = append(errs, "CONCEPT_MODERATION must be off, log or enforce")
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if !isValidProxy(proxy) {
+			errs = append(errs, fmt.Sprintf("TRUSTED_PROXIES entry %q is not an IP or CIDR", proxy))
+		}
+	}
+	if c.Auth.RateLimitRPS < 1 {
+		errs = append(errs, "AUTH_RATE_LIMIT_RPS must be at least 1")
+	}
+	if c.Auth.RateLimitBurst < 1 {
+		errs = append(errs, "AUTH_RATE_LIMIT_BURST must be at least 1")
+	}
+	if c.Webhook.RateLimitRPS < 1 || c.Webhook.RateLimitBurst < 1 {
+		errs = append(errs, "WEBHOOK_RATE_LIMIT_RPS and WEBHOOK_RATE_LIMIT_BURST must be at least 1")
+	}
+
+	if c.Auth.MaxLoginFailures < 1 {
+		errs = append(errs, "LOGIN_MAX_FAILURES must be at least 1")
+	}
+
+	if c.OpenRouter.AllowPlatformKey {
+		if c.OpenRouter.APIKey == "" {
+			errs = append(errs, "OPENROUTER_API_KEY is required when ALLOW_PLATFORM_OPENROUTER_KEY is on")
+		}
+		if c.OpenRouter.PlatformDailyJobs < 1 {
+			errs = append(errs, "PLATFORM_OPENROUTER_DAILY_JOBS must be at least 1")
+		}
+	}
+
+	if c.KIE.MusicCreditCost < 0 || c.KIE.ImageCreditCost < 0 {
+		errs = append(errs, "KIE_MUSIC_CREDIT_COST and KIE_IMAGE_CREDIT_COST must not be negative")
+	}
+	if c.KIE.AllowPlatformKey {
+		if c.KIE.APIKey == "" {
+			errs = append(errs, "KIE_API_KEY is required when ALLOW_PLATFORM_KIE_KEY is on")
+		}
+		if c.KIE.PlatformMonthlyCredits < 1 {
+			errs = append(errs, "PLATFORM_KIE_MONTHLY_CREDITS must be at least 1")
+		}
+	}
+
+	if c.Pipeline.BulkJobsPerMinute < 1 {
+		errs = append(errs, "BULK_JOBS_PER_MINUTE must be at least 1")
+	}
+
+	if c.Pipeline.MaxConceptLength < 5 {
+		errs = append(errs, "MAX_CONCEPT_LENGTH must be at least 5")
+	}
+
+	if c.R2.CleanupEnabled {
+		if c.R2.CleanupFailedAfter < 24*time.Hour {
+			errs = append(errs, "R2_CLEANUP_FAILED_DAYS must be at least 1")
+		}
+		if c.R2.CleanupMaxDeletes < 1 {
+			errs = append(errs, "R2_CLEANUP_MAX_DELETES must be at least 1")
+		}
+		if c.R2.CleanupMaxMissingRatio <= 0 || c.R2.CleanupMaxMissingRatio > 1 {
+			errs = append(errs, "R2_CLEANUP_MAX_MISSING_RATIO must be greater than 0 and at most 1")
+		}
+	}
+
+	switch c.Pipeline.VideoPreset {
+	case "standard", "high", "small", "h265":
+	default:
+		errs = append(errs, "VIDEO_PRESET must be standard, high, small or h265")
+	}
+
+	if c.Database.MaxConns < 1 || c.Database.MaxConns > 1000 {
+		errs = append(errs, "DB_MAX_CONNS must be between 1 and 1000")
+	}
+
+	if c.Database.MinConns < 0 || c.Database.MinConns > c.Database.MaxConns {
+		errs = append(errs, "DB_MIN_CONNS must be between 0 and DB_MAX_CONNS")
+	}
+
+	if c.Database.MaxConnLifetime <= 0 {
+		errs = append(errs, "DB_MAX_CONN_LIFETIME must be a positive duration")
+	}
+
+	if c.Database.AcquireTimeout <= 0 {
+		errs = append(errs, "DB_ACQUIRE_TIMEOUT must be a positive duration")
+	}
+
+	if c.Database.SlowQueryThreshold < 0 {
+		errs = append(errs, "DB_SLOW_QUERY_THRESHOLD must be a duration (0 disables slow query logging)")
+	}
+
+	if c.Worker.Concurrency < 1 || c.Worker.Concurrency > 100 {
+		errs = append(errs, "WORKER_CONCURRENCY must be between 1 and 100")
+	}
+
+	if c.Worker.FFmpegMaxConcurrent < 1 || c.Worker.FFmpegMaxConcurrent > c.Worker.Concurrency {
+		errs = append(errs, "FFMPEG_MAX_CONCURRENT must be between 1 and WORKER_CONCURRENCY")
+	}
+	if c.Worker.VideoSegmentThreshold > 0 && c.Worker.VideoSegmentLength < 30*time.Second {
+		errs = append(errs, "VIDEO_SEGMENT_LENGTH must be at least 30s")
+	}
+	if c.Worker.VideoMaxAudioBytes < 1<<20 {
+		errs = append(errs, "VIDEO_MAX_AUDIO_BYTES must be at least 1048576")
+	}
+	if c.Worker.VideoMaxImageBytes < 1<<10 {
+		errs = append(errs, "VIDEO_MAX_IMAGE_BYTES must be at least 1024")
+	}
+
+	if c.Metrics.Username != "" && c.Metrics.Password == "" {
+		errs = append(errs, "METRICS_PASSWORD is required when METRICS_USERNAME is set")
+	}
+
+	if c.SMTP.Host != "" {
+		if c.SMTP.From == "" {
+			errs = append(errs, "SMTP_FROM is required when SMTP_HOST is set")
+		}
+		if c.SMTP.Port < 1 || c.SMTP.Port > 65535 {
+			errs = append(errs, "SMTP_PORT must be between 1 and 65535")
+		}
+	}
+
+	// Base URLs are joined with paths; a malformed one breaks every link built from it,
+	// and KIE silently drops callbacks it cannot deliver
+	for _, base := range []struct{ name, value string }{
+		{"WEBHOOK_BASE_URL", c.Webhook.BaseURL},
+		{"R2_PUBLIC_URL", c.R2.PublicURL},
+		{"FRONTEND_URL", c.FrontendURL},
+	} {
+		if msg := validateBaseURL(base.value, c.IsDevelopment()); msg != "" {
+			errs = append(errs, base.name+" "+msg)
+		}
 	}
 
 	// Webhook secret is required in production/staging
@@ -218,6 +754,17 @@ func (c *Config) Validate() error {
 			errs = append(errs, "WEBHOOK_SECRET is required in production/staging")
 		}
 	}
+	// Synchronous runs hold a request open for the whole pipeline; a debugging aid only
+	if c.Pipeline.SyncJobsEnabled && c.IsProduction() {
+		errs = append(errs, "SYNC_JOBS_ENABLED cannot be used in production")
+	}
+	if c.Webhook.PreviousSecret != "" {
+		if c.Webhook.Secret == "" {
+			errs = append(errs, "WEBHOOK_SECRET_PREVIOUS requires WEBHOOK_SECRET")
+		} else if c.Webhook.PreviousSecret == c.Webhook.Secret {
+			errs = append(errs, "WEBHOOK_SECRET_PREVIOUS must differ from WEBHOOK_SECRET")
+		}
+	}
 
 	if len(errs) > 0 {
 		return fmt.Errorf("config validation failed:\n  - %s", strings.Join(errs, "\n  - "))
@@ -225,6 +772,54 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// maxBaseURLLength bounds the configured base URLs; callback URLs add about 110
+// characters for the path, token and job ID.
+const maxBaseURLLength = 200
+
+// validateBaseURL checks an optional base URL: absolute, https (http is allowed in
+// development), without credentials, query or fragment. Load already trims trailing
+// slashes. Returns a message describing the problem, or "" if the URL is valid or empty.
+func validateBaseURL(value string, allowHTTP bool) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) > maxBaseURLLength {
+		return fmt.Sprintf("must be at most %d characters", maxBaseURLLength)
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return "must be an absolute URL such as https://example.com"
+	}
+	switch {
+	case u.Scheme == "http" && !allowHTTP:
+		return "must use https (http is only allowed in development)"
+	case u.Scheme != "https" && u.Scheme != "http":
+		return "must use https"
+	case u.User != nil:
+		return "must not contain credentials"
+	case u.RawQuery != "" || u.ForceQuery || u.Fragment != "":
+		return "must not have a query or fragment"
+	}
+	return ""
+}
+
+// RunsAPI returns true if this process serves the HTTP API.
+func (c *Config) RunsAPI() bool {
+	return c.Server.Mode == ModeAPI || c.Server.Mode == ModeAll
+}
+
+// ServesMetrics returns true if /metrics is registered. It is served on the
+// public port, so production leaves it unregistered without basic auth.
+func (c *Config) ServesMetrics() bool {
+	return c.Metrics.Enabled && (c.Metrics.Username != "" || !c.IsProduction())
+}
+
+// RunsWorker returns true if this process runs the Asynq worker.
+func (c *Config) RunsWorker() bool {
+	return c.Server.Mode == ModeWorker || c.Server.Mode == ModeAll
+}
+
 // IsDevelopment returns true if the environment is development.
 func (c *Config) IsDevelopment() bool {
 	return c.Server.Env == "development"
@@ -3,15 +3,24 @@ package database
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
 )
 
 // DB wraps a pgxpool.Pool to provide database operations.
 type DB struct {
-	pool *pgxpool.Pool
+	pool    *pgxpool.Pool
+	queries *Pool
+
+	now      func() time.Time   // Replaceable clock
+	readStat func() acquireStat // Replaceable pool counters
+
+	statMu      sync.Mutex
+	statSamples []acquireSample // Pool counters seen by CheckAcquireWait, oldest first
 }
 
 // PoolConfig holds configuration for the connection pool.
@@ -20,25 +29,36 @@ type PoolConfig struct {
 	MinConns        int32
 	MaxConnLifetime time.Duration
 	MaxConnIdleTime time.Duration
+	// AcquireTimeout bounds the wait for a free connection of every statement,
+	// transaction and health check; zero waits until the context is done.
+	AcquireTimeout time.Duration
+	// SlowQueryThreshold logs queries running at least this long; zero disables it.
+	SlowQueryThreshold time.Duration
+	// Tracer, when set, traces every statement instead of the slow query log,
+	// e.g. to count the statements a test makes.
+	Tracer pgx.QueryTracer
 }
 
 // DefaultPoolConfig returns sensible default pool configuration.
 func DefaultPoolConfig() PoolConfig {
 	return PoolConfig{
-		MaxConns:        25,
-		MinConns:        5,
-		MaxConnLifetime: time.Hour,
-		MaxConnIdleTime: 30 * time.Minute,
+		MaxConns:           25,
+		MinConns:           5,
+		MaxConnLifetime:    time.Hour,
+		MaxConnIdleTime:    30 * time.Minute,
+		AcquireTimeout:     5 * time.Second,
+		SlowQueryThreshold: 500 * time.Millisecond,
 	}
 }
 
 // New creates a new DB instance with the given database URL and default pool configuration.
 func New(ctx context.Context, databaseURL string) (*DB, error) {
-	return NewWithConfig(ctx, databaseURL, DefaultPoolConfig())
+	return NewWithConfig(ctx, databaseURL, DefaultPoolConfig(), zap.NewNop())
 }
 
 // NewWithConfig creates a new DB instance with custom pool configuration.
-func NewWithConfig(ctx context.Context, databaseURL string, cfg PoolConfig) (*DB, error) {
+// Slow queries are logged to logger.
+func NewWithConfig(ctx context.Context, databaseURL string, cfg PoolConfig, logger *zap.Logger) (*DB, error) {
 	poolConfig, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse database URL: %w", err)
@@ -49,6 +69,12 @@ func NewWithConfig(ctx context.Context, databaseURL string, cfg PoolConfig) (*DB
 	poolConfig.MinConns = cfg.MinConns
 	poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
 	poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	switch {
+	case cfg.Tracer != nil:
+		poolConfig.ConnConfig.Tracer = cfg.Tracer
+	case cfg.SlowQueryThreshold > 0:
+		poolConfig.ConnConfig.Tracer = newSlowQueryTracer(cfg.SlowQueryThreshold, logger)
+	}
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
@@ -61,7 +87,14 @@ func NewWithConfig(ctx context.Context, databaseURL string, cfg PoolConfig) (*DB
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{pool: pool}, nil
+	db := &DB{
+		pool:    pool,
+		queries: &Pool{pool: pool, acquireTimeout: cfg.AcquireTimeout},
+		now:     time.Now,
+	}
+	db.readStat = db.poolStat
+	db.statSamples = []acquireSample{{at: db.now()}}
+	return db, nil
 }
 
 // Close closes the database connection pool.
@@ -71,24 +104,93 @@ func (db *DB) Close() {
 	}
 }
 
-// Pool returns the underlying pgxpool.Pool.
-func (db *DB) Pool() *pgxpool.Pool {
-	return db.pool
+// Pool returns the pool repositories run their statements on.
+func (db *DB) Pool() *Pool {
+	return db.queries
 }
 
 // Health checks the database connection health.
 func (db *DB) Health(ctx context.Context) error {
-	if err := db.pool.Ping(ctx); err != nil {
+	conn, err := db.queries.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("database health check failed: %w", err)
+	}
+	defer conn.Release()
+
+	if err := conn.Ping(ctx); err != nil {
 		return fmt.Errorf("database health check failed: %w", err)
 	}
 	return nil
 }
 
+// acquireWaitWindow is how far back CheckAcquireWait averages acquire waits, so
+// the result does not depend on how often, or by how many probes, it is called.
+const acquireWaitWindow = time.Minute
+
+// acquireStat holds the cumulative pool counters used to compute acquire waits.
+type acquireStat struct {
+	count    int64
+	duration time.Duration
+	acquired int32 // Connections in use
+	max      int32
+}
+
+// acquireSample is an acquireStat read at a point in time.
+type acquireSample struct {
+	at time.Time
+	acquireStat
+}
+
+// poolStat reads the counters of the pool.
+func (db *DB) poolStat() acquireStat {
+	stat := db.pool.Stat()
+	return acquireStat{
+		count:    stat.AcquireCount(),
+		duration: stat.AcquireDuration(),
+		acquired: stat.AcquiredConns(),
+		max:      stat.MaxConns(),
+	}
+}
+
+// CheckAcquireWait returns an error when the average time spent waiting for a
+// connection over the last acquireWaitWindow exceeds maxWait, which means the
+// pool is exhausted. Until the pool is that old, it averages since pool creation.
+func (db *DB) CheckAcquireWait(_ context.Context, maxWait time.Duration) error {
+	now := db.now()
+	current := db.readStat()
+
+	db.statMu.Lock()
+	db.statSamples = append(db.statSamples, acquireSample{at: now, acquireStat: current})
+	// Keep the newest sample at least a window old as the baseline
+	windowStart := now.Add(-acquireWaitWindow)
+	for len(db.statSamples) > 1 && !db.statSamples[1].at.After(windowStart) {
+		db.statSamples = db.statSamples[1:]
+	}
+	baseline := db.statSamples[0].acquireStat
+	db.statMu.Unlock()
+
+	acquired := current.count - baseline.count
+	if acquired <= 0 {
+		return nil
+	}
+	if avg := (current.duration - baseline.duration) / time.Duration(acquired); avg > maxWait {
+		return fmt.Errorf("average connection acquire wait %s exceeds %s (%d/%d connections in use)",
+			avg.Round(time.Millisecond), maxWait, current.acquired, current.max)
+	}
+	return nil
+}
+
+// begin acquires a connection and starts a transaction on it. The connection
+// is released when the transaction ends.
+func (db *DB) begin(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	return db.queries.BeginTx(ctx, opts)
+}
+
 // WithTx executes the given function within a database transaction.
 // If the function returns an error, the transaction is rolled back.
 // Otherwise, the transaction is committed.
 func (db *DB) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
-	tx, err := db.pool.Begin(ctx)
+	tx, err := db.begin(ctx, pgx.TxOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -116,7 +218,7 @@ func (db *DB) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
 
 // WithTxOptions executes the given function within a database transaction with custom options.
 func (db *DB) WithTxOptions(ctx context.Context, opts pgx.TxOptions, fn func(tx pgx.Tx) error) error {
-	tx, err := db.pool.BeginTx(ctx, opts)
+	tx, err := db.begin(ctx, opts)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestCheckAcquireWaitAveragesOverWindow checks that the acquire wait is averaged
+// over the last window, not since the previous call, so frequent probes do not
+// hide a slow pool and a past spike ages out.
+func TestCheckAcquireWaitAveragesOverWindow(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	stat := acquireStat{max: 10}
+	db := &DB{
+		now:         func() time.Time { return now },
+		readStat:    func() acquireStat { return stat },
+		statSamples: []acquireSample{{at: start}},
+	}
+	check := func() error { return db.CheckAcquireWait(context.Background(), 100*time.Millisecond) }
+
+	// 10 acquires waiting 500ms each
+	now = start.Add(10 * time.Second)
+	stat.count, stat.duration = 10, 5*time.Second
+	if err := check(); err == nil {
+		t.Fatal("expected an error for a 500ms average wait")
+	}
+
+	// A probe right after sees no new acquires but still averages the window
+	now = now.Add(time.Second)
+	if err := check(); err == nil {
+		t.Fatal("expected the slow acquires to count until they leave the window")
+	}
+
+	// Many fast acquires later, the slow ones have aged out
+	now = start.Add(2 * time.Minute)
+	stat.count, stat.duration = 1010, 5*time.Second+time.Second
+	if err := check(); err != nil {
+		t.Fatalf("expected no error once the slow acquires left the window, got %v", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if err := check(); err != nil {
+		t.Fatalf("expected no error without acquires, got %v", err)
+	}
+}
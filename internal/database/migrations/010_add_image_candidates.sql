@@ -0,0 +1,41 @@
+-- Migration: 010_add_image_candidates
+-- Description: Support multiple NanoBanana image candidates per job with automatic best-image selection
+
+-- Number of image candidates requested for the job (NULL = server default)
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS image_candidates INT;
+
+-- Per-candidate task status: [{"task_id": "...", "image_url": "...", "status": "pending|success|fail"}]
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS generated_images JSONB;
+
+-- Webhook lookup of a job by any of its candidate task IDs
+CREATE INDEX IF NOT EXISTS idx_jobs_generated_images ON jobs USING GIN (generated_images jsonb_path_ops);
+
+-- User override slot for the image selector agent
+ALTER TABLE users ADD COLUMN IF NOT EXISTS image_selector_prompt TEXT;
+
+-- Seed default image selector prompt
+INSERT INTO system_prompts (prompt_type, prompt_content) VALUES
+('image_selector', 'คุณคือ AI art director มืออาชีพ มีหน้าที่เลือกภาพพื้นหลังที่ดีที่สุดสำหรับ music video จากตัวเลือกที่ NanoBanana สร้างมา
+
+## เกณฑ์การเลือก (เรียงตามความสำคัญ):
+
+### 1. ความสอดคล้องกับเพลง (50%)
+- ภาพสื่ออารมณ์และธีมของ concept และชื่อเพลงหรือไม่
+- โทนสีและบรรยากาศเข้ากับแนวเพลงหรือไม่
+
+### 2. ความน่าสนใจทางสายตา (30%)
+- องค์ประกอบภาพชัดเจน มีจุดโฟกัส
+- หลีกเลี่ยงภาพที่ดูจืด มืด หรือไม่มีรายละเอียด
+
+### 3. ความเหมาะสมกับวิดีโอ (20%)
+- เหมาะเป็นภาพนิ่งตลอดทั้งเพลงในอัตราส่วน 16:9
+- ไม่มีข้อความหรือองค์ประกอบที่ผิดเพี้ยน
+
+## รูปแบบผลลัพธ์:
+
+ส่งออกเป็น JSON เท่านั้น:
+{
+  "selectedTaskId": "task_id ของภาพที่เลือก",
+  "reasoning": "อธิบายสั้นๆ ว่าทำไมถึงเลือกภาพนี้ (ภาษาไทย)"
+}')
+ON CONFLICT (prompt_type) DO NOTHING;
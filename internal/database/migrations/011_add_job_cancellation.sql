@@ -0,0 +1,4 @@
+-- Migration: 011_add_job_cancellation
+-- Description: Add cancelled_at flag so task handlers can stop work for cancelled jobs
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ;
@@ -0,0 +1,4 @@
+-- Migration: 012_add_jobs_list_index
+-- Description: Composite index for filtered and sorted job listings per user
+
+CREATE INDEX IF NOT EXISTS idx_jobs_user_status_created_at ON jobs(user_id, status, created_at DESC);
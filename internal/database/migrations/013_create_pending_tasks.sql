@@ -0,0 +1,17 @@
+-- Migration: 013_create_pending_tasks
+-- Description: Outbox for tasks that could not be enqueued (e.g. Redis unavailable)
+
+CREATE TABLE IF NOT EXISTS pending_tasks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    task_type VARCHAR(100) NOT NULL,
+    task_id VARCHAR(255),
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_tasks_next_attempt_at ON pending_tasks(next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_pending_tasks_job_id ON pending_tasks(job_id);
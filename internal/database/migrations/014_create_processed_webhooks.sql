@@ -0,0 +1,12 @@
+-- Migration: 014_create_processed_webhooks
+-- Description: Record handled webhook callbacks so replayed payloads are acknowledged without side effects
+
+CREATE TABLE IF NOT EXISTS processed_webhooks (
+    source VARCHAR(20) NOT NULL,
+    task_id VARCHAR(256) NOT NULL,
+    callback_type VARCHAR(50) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT processed_webhooks_unique UNIQUE (source, task_id, callback_type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_webhooks_created_at ON processed_webhooks(created_at);
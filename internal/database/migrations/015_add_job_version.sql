@@ -0,0 +1,4 @@
+-- Migration: 015_add_job_version
+-- Description: Version column for optimistic locking of full-row job updates
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
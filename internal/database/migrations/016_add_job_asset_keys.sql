@@ -0,0 +1,6 @@
+-- Migration: 016_add_job_asset_keys
+-- Description: Store R2 object keys for job assets; URLs are generated at read time
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS video_key TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS audio_key TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS image_key TEXT;
@@ -0,0 +1,25 @@
+-- Migration: 017_add_image_aspect_ratio
+-- Description: Per-job image aspect ratio override; image_concept prompt requests aspect_ratio and resolution
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS aspect_ratio VARCHAR(10);
+
+-- Only prompts still containing the default output block are updated; customized prompts are left alone
+UPDATE system_prompts
+SET prompt_content = replace(prompt_content, '{
+  "prompt": "คำอธิบายภาพเป็นภาษาอังกฤษ (ไม่เกิน 500 ตัวอักษร)"
+}', '{
+  "prompt": "คำอธิบายภาพเป็นภาษาอังกฤษ (ไม่เกิน 500 ตัวอักษร)",
+  "aspect_ratio": "16:9 หรือ 9:16 หรือ 1:1 หรือ 4:3 หรือ 3:4",
+  "resolution": "1K หรือ 2K"
+}
+
+### aspect_ratio และ resolution:
+- ใช้ 16:9 เป็นค่าเริ่มต้นสำหรับ music video แนวนอน
+- เลือกค่าอื่นเฉพาะเมื่อองค์ประกอบภาพต้องการจริงๆ
+- ใช้ 2K เฉพาะภาพที่มีรายละเอียดสูง นอกนั้นใช้ 1K'),
+    updated_at = NOW()
+WHERE prompt_type = 'image_concept'
+  AND position('"aspect_ratio"' in prompt_content) = 0
+  AND position('{
+  "prompt": "คำอธิบายภาพเป็นภาษาอังกฤษ (ไม่เกิน 500 ตัวอักษร)"
+}' in prompt_content) > 0;
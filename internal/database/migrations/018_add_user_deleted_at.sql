@@ -0,0 +1,4 @@
+-- Migration: 018_add_user_deleted_at
+-- Description: Soft-delete marker for accounts pending data cleanup
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
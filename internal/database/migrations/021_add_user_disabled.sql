@@ -0,0 +1,5 @@
+-- Migration: 021_add_user_disabled
+-- Description: Let admins disable accounts; disabled users cannot log in or use existing tokens
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) DEFAULT 'user' NOT NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,9 @@
+-- Migration: 022_add_agent_models
+-- Description: Per-agent LLM overrides on users and the model each agent actually used on jobs
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS song_concept_model VARCHAR(100),
+ADD COLUMN IF NOT EXISTS song_selector_model VARCHAR(100),
+ADD COLUMN IF NOT EXISTS image_concept_model VARCHAR(100);
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS agent_models JSONB;
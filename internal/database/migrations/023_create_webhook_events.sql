@@ -0,0 +1,17 @@
+-- Migration: 023_create_webhook_events
+-- Description: Store raw webhook callbacks for debugging when WEBHOOK_CAPTURE_ENABLED is set
+
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source VARCHAR(20) NOT NULL,
+    job_id UUID,
+    status_code INTEGER NOT NULL,
+    headers JSONB NOT NULL DEFAULT '{}'::jsonb,
+    body TEXT NOT NULL DEFAULT '',
+    body_truncated BOOLEAN NOT NULL DEFAULT FALSE,
+    parse_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_events_job_id ON webhook_events(job_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_created_at ON webhook_events(created_at);
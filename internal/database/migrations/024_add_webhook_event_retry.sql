@@ -0,0 +1,5 @@
+-- Migration: 024_add_webhook_event_retry
+-- Description: Keep callbacks that failed transiently in webhook_events so the worker can re-apply them
+
+ALTER TABLE webhook_events ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'capture';
+ALTER TABLE webhook_events ADD COLUMN IF NOT EXISTS processed_at TIMESTAMPTZ;
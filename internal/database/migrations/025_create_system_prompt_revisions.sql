@@ -0,0 +1,13 @@
+-- Migration: 025_create_system_prompt_revisions
+-- Description: Keep the previous content of a system prompt on every update so admins can roll back
+
+CREATE TABLE IF NOT EXISTS system_prompt_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    prompt_type VARCHAR(50) NOT NULL,
+    prompt_content TEXT NOT NULL,
+    authored_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    replaced_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_system_prompt_revisions_type ON system_prompt_revisions(prompt_type, created_at DESC);
@@ -0,0 +1,19 @@
+-- Migration: 026_create_job_templates
+-- Description: User-scoped presets of job settings and per-job agent prompt overrides
+
+CREATE TABLE IF NOT EXISTS job_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    llm_model VARCHAR(100),
+    image_candidates INTEGER,
+    aspect_ratio VARCHAR(10),
+    prompt_overrides JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_templates_user_id ON job_templates(user_id, created_at DESC);
+
+-- Prompt overrides copied from a template when the job was created
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS prompt_overrides JSONB;
@@ -0,0 +1,24 @@
+-- Migration: 027_create_job_schedules
+-- Description: Recurring job creation (daily or weekly at a local time)
+
+CREATE TABLE IF NOT EXISTS job_schedules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    concept TEXT NOT NULL,
+    template_id UUID REFERENCES job_templates(id) ON DELETE SET NULL,
+    frequency VARCHAR(10) NOT NULL,
+    time_of_day VARCHAR(5) NOT NULL,
+    weekday SMALLINT,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    disabled_reason TEXT,
+    last_run_at TIMESTAMPTZ,
+    last_job_id UUID REFERENCES jobs(id) ON DELETE SET NULL,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT job_schedules_frequency_check CHECK (frequency IN ('daily', 'weekly'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_schedules_user_id ON job_schedules(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_job_schedules_due ON job_schedules(next_run_at) WHERE enabled;
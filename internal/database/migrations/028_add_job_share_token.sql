@@ -0,0 +1,8 @@
+-- Migration: 028_add_job_share_token
+-- Description: Opt-in public share links for finished jobs
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS share_token VARCHAR(64),
+ADD COLUMN IF NOT EXISTS shared_at TIMESTAMPTZ;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_share_token ON jobs(share_token) WHERE share_token IS NOT NULL;
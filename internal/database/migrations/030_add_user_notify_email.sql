@@ -0,0 +1,4 @@
+-- Migration: 030_add_user_notify_email
+-- Description: Let users opt in to an email when their jobs complete or fail
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notify_email BOOLEAN NOT NULL DEFAULT FALSE;
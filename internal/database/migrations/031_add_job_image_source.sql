@@ -0,0 +1,6 @@
+-- Migration: 031_add_job_image_source
+-- Description: Let users supply a job's cover image instead of generating one
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS image_source VARCHAR(20),
+ADD COLUMN IF NOT EXISTS source_image_url TEXT;
@@ -0,0 +1,5 @@
+-- Migration: 032_add_job_video_options
+-- Description: Per-job audio normalization and fade-out settings for the video encode
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS video_options JSONB;
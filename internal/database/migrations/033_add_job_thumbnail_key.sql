@@ -0,0 +1,5 @@
+-- Migration: 033_add_job_thumbnail_key
+-- Description: Store the R2 key of each completed video's thumbnail
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS thumbnail_key TEXT;
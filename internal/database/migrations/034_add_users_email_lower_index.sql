@@ -0,0 +1,4 @@
+-- Migration: 034_add_users_email_lower_index
+-- Description: Index lowercased emails for case-insensitive login lookups
+
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email));
@@ -0,0 +1,9 @@
+-- Migration: 035_add_job_openrouter_key_source
+-- Description: Record whether a job's LLM calls use the user's or the platform's OpenRouter key
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS openrouter_key_source VARCHAR(20) NOT NULL DEFAULT 'user';
+
+-- Daily platform key quota counts a user's recent platform-key jobs
+CREATE INDEX IF NOT EXISTS idx_jobs_platform_key_user_created
+    ON jobs (user_id, created_at)
+    WHERE openrouter_key_source = 'platform';
@@ -0,0 +1,17 @@
+-- Migration: 036_create_user_spend
+-- Description: Track estimated KIE credit spend per generation and record which KIE key a job uses
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS kie_key_source VARCHAR(20) NOT NULL DEFAULT 'user';
+
+CREATE TABLE IF NOT EXISTS user_spend (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    job_id UUID REFERENCES jobs(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL,
+    key_source VARCHAR(20) NOT NULL,
+    credits INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Monthly totals are summed per user over a time range
+CREATE INDEX IF NOT EXISTS idx_user_spend_user_created ON user_spend (user_id, created_at);
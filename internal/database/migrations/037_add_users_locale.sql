@@ -0,0 +1,4 @@
+-- Migration: 037_add_users_locale
+-- Description: Store the user's preferred language for API error messages
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(10);
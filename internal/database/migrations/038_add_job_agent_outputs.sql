@@ -0,0 +1,4 @@
+-- Migration: 038_add_job_agent_outputs
+-- Description: Keep each agent's reasoning and output summary on the job, keyed by agent type
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS agent_outputs JSONB;
@@ -0,0 +1,4 @@
+-- Migration: 039_add_job_stage_timings
+-- Description: Record when each pipeline stage of a job started and completed, keyed by stage
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS stage_timings JSONB;
@@ -0,0 +1,5 @@
+-- Migration: 040_add_suno_model
+-- Description: Let jobs choose their Suno model, with a per-user default; NULL uses V5
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS suno_model VARCHAR(20);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS default_suno_model VARCHAR(20);
@@ -0,0 +1,5 @@
+-- Migration: 041_add_job_failure_details
+-- Description: Classify job failures with an error code and record the step a retry restarts from
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS error_code VARCHAR(50);
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS retry_from VARCHAR(30);
@@ -0,0 +1,4 @@
+-- Migration: 042_add_job_video_metadata
+-- Description: Store the encoding preset, codec, bitrate and size of each job's rendered video
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS video_metadata JSONB;
@@ -0,0 +1,13 @@
+-- Migration: 043_add_job_tags_and_search
+-- Description: Add free-form job tags and full-text search on the concept
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_jobs_tags ON jobs USING GIN (tags);
+
+-- The simple configuration only lowercases and splits on non-word characters, with
+-- no language-specific stemming, so Thai and English concepts are indexed alike
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS concept_tsv tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', concept)) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_concept_tsv ON jobs USING GIN (concept_tsv);
@@ -0,0 +1,7 @@
+-- Migration: 044_add_job_soft_delete
+-- Description: Soft delete jobs so users can restore them within the restore window
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Only deleted jobs are indexed; the purge scans them by deletion time
+CREATE INDEX IF NOT EXISTS idx_jobs_deleted_at ON jobs (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,4 @@
+-- Migration: 047_add_user_agent_params
+-- Description: Per-agent temperature and max_tokens overrides, keyed by prompt type
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS agent_params JSONB NOT NULL DEFAULT '{}'::jsonb;
@@ -0,0 +1,8 @@
+-- Migration: 048_add_user_key_flags
+-- Description: Whether each provider API key is set, so presence checks need not decrypt the keys
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS has_openrouter_key BOOLEAN
+        GENERATED ALWAYS AS (COALESCE(openrouter_api_key, '') <> '') STORED,
+    ADD COLUMN IF NOT EXISTS has_kie_key BOOLEAN
+        GENERATED ALWAYS AS (COALESCE(kie_api_key, '') <> '') STORED;
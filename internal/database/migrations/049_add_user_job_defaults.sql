@@ -0,0 +1,4 @@
+-- Migration: 049_add_user_job_defaults
+-- Description: Per-user defaults for job settings a request leaves unset
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS job_defaults JSONB NOT NULL DEFAULT '{}'::jsonb;
@@ -0,0 +1,4 @@
+-- Migration: 050_add_job_image_sanitize_attempts
+-- Description: Count image generations restarted with a sanitized prompt after a content policy refusal
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS image_sanitize_attempts INT NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+-- Migration: 051_add_job_tracks
+-- Description: Let jobs keep every Suno track in R2, not just the one used for the video
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS keep_all_tracks BOOLEAN NOT NULL DEFAULT false;
+
+-- Tracks copied to R2 at upload: [{id, title, duration, key, primary, reasoning}]
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS tracks JSONB;
@@ -0,0 +1,4 @@
+-- Migration: 052_add_job_max_duration
+-- Description: Optional cap on a job's video length; longer tracks are trimmed
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS max_duration_seconds INT;
@@ -0,0 +1,7 @@
+-- Migration: 053_add_job_callback_mode
+-- Description: Per-job choice between provider callbacks and polling (auto follows WEBHOOK_BASE_URL)
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS callback_mode TEXT NOT NULL DEFAULT 'auto';
+
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS chk_jobs_callback_mode;
+ALTER TABLE jobs ADD CONSTRAINT chk_jobs_callback_mode CHECK (callback_mode IN ('auto', 'webhook', 'poll'));
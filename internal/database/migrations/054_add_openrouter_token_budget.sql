@@ -0,0 +1,11 @@
+-- Migration: 054_add_openrouter_token_budget
+-- Description: Record OpenRouter tokens per LLM call in user_spend and add an optional monthly token budget per user
+
+ALTER TABLE user_spend ADD COLUMN IF NOT EXISTS tokens INTEGER NOT NULL DEFAULT 0;
+
+-- NULL means no budget
+ALTER TABLE users ADD COLUMN IF NOT EXISTS openrouter_monthly_token_limit BIGINT;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_users_openrouter_monthly_token_limit;
+ALTER TABLE users ADD CONSTRAINT chk_users_openrouter_monthly_token_limit
+    CHECK (openrouter_monthly_token_limit IS NULL OR openrouter_monthly_token_limit > 0);
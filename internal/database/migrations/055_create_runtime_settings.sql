@@ -0,0 +1,9 @@
+-- Migration: 055_create_runtime_settings
+-- Description: Key/value settings admins change at runtime (job intake pause, maintenance banner)
+
+CREATE TABLE IF NOT EXISTS runtime_settings (
+    key VARCHAR(100) PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,22 @@
+-- Migration: 056_add_invitation_tokens
+-- Description: Invitations are accepted with an emailed, expiring token instead of on login, and can be revoked
+
+ALTER TABLE organization_invitations ADD COLUMN IF NOT EXISTS token_hash TEXT;
+ALTER TABLE organization_invitations ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE organization_invitations ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMPTZ;
+
+-- Invitations created before tokens existed cannot be accepted; revoke them so
+-- owners can invite the same addresses again
+UPDATE organization_invitations
+SET revoked_at = NOW()
+WHERE token_hash IS NULL AND accepted_at IS NULL AND revoked_at IS NULL;
+
+DROP INDEX IF EXISTS idx_organization_invitations_pending;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_invitations_pending
+    ON organization_invitations (org_id, LOWER(email)) WHERE accepted_at IS NULL AND revoked_at IS NULL;
+
+-- Nothing looks invitations up by email anymore
+DROP INDEX IF EXISTS idx_organization_invitations_email;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_invitations_token_hash
+    ON organization_invitations (token_hash) WHERE token_hash IS NOT NULL;
@@ -0,0 +1,7 @@
+-- Migration: 057_add_pending_task_options
+-- Description: Keep the enqueue options of outbox tasks so they are re-applied when the task is drained
+
+ALTER TABLE pending_tasks ADD COLUMN IF NOT EXISTS process_at TIMESTAMPTZ;
+ALTER TABLE pending_tasks ADD COLUMN IF NOT EXISTS queue VARCHAR(100);
+ALTER TABLE pending_tasks ADD COLUMN IF NOT EXISTS max_retry INT;
+ALTER TABLE pending_tasks ADD COLUMN IF NOT EXISTS timeout_seconds INT;
@@ -0,0 +1,28 @@
+-- Migration: 058_create_platform_quota_reservations
+-- Description: Reserve platform key quota atomically when jobs are created
+
+-- One row per user, locked while their platform quota is checked and reserved so
+-- concurrent job creations cannot both pass the check
+CREATE TABLE IF NOT EXISTS platform_quota_locks (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Platform key usage reserved for new jobs: OpenRouter jobs count against the
+-- daily job quota, estimated KIE credits against the monthly credit quota until
+-- the recorded spend passes them
+CREATE TABLE IF NOT EXISTS platform_quota_reservations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    openrouter_jobs INT NOT NULL DEFAULT 0,
+    kie_credits INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_platform_quota_reservations_user_created
+    ON platform_quota_reservations (user_id, created_at);
+
+-- The daily job quota used to count jobs; carry the last day's over
+INSERT INTO platform_quota_reservations (user_id, openrouter_jobs, created_at)
+SELECT user_id, 1, created_at
+FROM jobs
+WHERE openrouter_key_source = 'platform' AND created_at > NOW() - INTERVAL '24 hours';
@@ -0,0 +1,8 @@
+-- Migration: 059_add_pending_task_user_id
+-- Description: Let the outbox hold user-level tasks (e.g. deleting an account's data), which have no job
+
+ALTER TABLE pending_tasks ALTER COLUMN job_id DROP NOT NULL;
+ALTER TABLE pending_tasks ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE pending_tasks DROP CONSTRAINT IF EXISTS chk_pending_tasks_owner;
+ALTER TABLE pending_tasks ADD CONSTRAINT chk_pending_tasks_owner CHECK (job_id IS NOT NULL OR user_id IS NOT NULL);
@@ -0,0 +1,7 @@
+-- Migration: 060_add_user_spend_task_id
+-- Description: Record the KIE spend of a generation task once, however often it is recorded
+
+ALTER TABLE user_spend ADD COLUMN IF NOT EXISTS provider_task_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_spend_provider_task_id
+    ON user_spend (provider_task_id) WHERE provider_task_id IS NOT NULL;
@@ -10,6 +10,8 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
 )
 
 //go:embed migrations/*.sql
@@ -71,9 +73,37 @@ func (m *Migrator) Migrate(ctx context.Context) error {
 		m.logger.Info("migration applied successfully", zap.String("name", migration.Name))
 	}
 
+	if err := m.checkJobStatuses(ctx); err != nil {
+		return fmt.Errorf("failed to check job statuses: %w", err)
+	}
+
 	return nil
 }
 
+// maxUnknownStatusJobs caps how many jobs with an unknown status are logged.
+const maxUnknownStatusJobs = 100
+
+// checkJobStatuses logs jobs whose status is not one the application knows. The
+// repository refuses to move such jobs anywhere, so they need manual repair.
+func (m *Migrator) checkJobStatuses(ctx context.Context) error {
+	rows, err := m.db.Pool().Query(ctx,
+		`SELECT id::text, status FROM jobs WHERE NOT (status = ANY($1)) ORDER BY created_at LIMIT $2`,
+		models.AllStatuses, maxUnknownStatusJobs)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, status string
+		if err := rows.Scan(&id, &status); err != nil {
+			return err
+		}
+		m.logger.Warn("job has unknown status", zap.String("job_id", id), zap.String("status", status))
+	}
+	return rows.Err()
+}
+
 // Migration represents a single migration file
 type Migration struct {
 	Name    string
@@ -221,6 +251,6 @@ func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
 
 // MigrationStatus represents the status of a single migration
 type MigrationStatus struct {
-	Name    string
-	Applied bool
+	Name    string `json:"name"`
+	Applied bool   `json:"applied"`
 }
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Pool runs statements on connections from a pgxpool.Pool, waiting at most the
+// acquire timeout for a free connection. The timeout bounds only that wait: the
+// statement itself runs under the caller's context. Repositories reach the
+// database through Pool, so an exhausted pool fails requests quickly instead of
+// queueing them until their contexts end.
+type Pool struct {
+	pool           *pgxpool.Pool
+	acquireTimeout time.Duration // Zero waits until the context is done
+}
+
+// Exec acquires a connection and executes sql on it.
+func (p *Pool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer conn.Release()
+
+	return conn.Exec(ctx, sql, args...)
+}
+
+// Query acquires a connection and runs sql on it. The connection is released
+// when the rows are closed or fully read.
+func (p *Pool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return &releasingRows{Rows: rows, conn: conn}, nil
+}
+
+// QueryRow acquires a connection and runs sql on it. The connection is released
+// by Scan; an acquire error is returned by Scan.
+func (p *Pool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return errRow{err: err}
+	}
+	return &releasingRow{row: conn.QueryRow(ctx, sql, args...), conn: conn}
+}
+
+// BeginTx acquires a connection and starts a transaction on it. The connection
+// is released when the transaction ends.
+func (p *Pool) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return &releasingTx{Tx: tx, conn: conn}, nil
+}
+
+// acquire gets a connection from the pool, waiting at most the acquire timeout.
+func (p *Pool) acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	if p.acquireTimeout <= 0 {
+		return p.pool.Acquire(ctx)
+	}
+
+	acquireCtx, cancel := context.WithTimeout(ctx, p.acquireTimeout)
+	defer cancel()
+
+	conn, err := p.pool.Acquire(acquireCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection within %s: %w", p.acquireTimeout, err)
+	}
+	return conn, nil
+}
+
+// releasingRows releases its pool connection once closed or fully read.
+type releasingRows struct {
+	pgx.Rows
+	conn     *pgxpool.Conn
+	released bool
+}
+
+func (r *releasingRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.Close()
+	return false
+}
+
+func (r *releasingRows) Close() {
+	r.Rows.Close()
+	if !r.released {
+		r.released = true
+		r.conn.Release()
+	}
+}
+
+// releasingRow releases its pool connection once scanned.
+type releasingRow struct {
+	row  pgx.Row
+	conn *pgxpool.Conn
+}
+
+func (r *releasingRow) Scan(dest ...any) error {
+	defer r.conn.Release()
+	return r.row.Scan(dest...)
+}
+
+// errRow is a pgx.Row for a query that could not run.
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(dest ...any) error {
+	return r.err
+}
+
+// releasingTx releases its pool connection once committed or rolled back.
+type releasingTx struct {
+	pgx.Tx
+	conn     *pgxpool.Conn
+	released bool
+}
+
+func (t *releasingTx) Commit(ctx context.Context) error {
+	err := t.Tx.Commit(ctx)
+	t.release()
+	return err
+}
+
+func (t *releasingTx) Rollback(ctx context.Context) error {
+	err := t.Tx.Rollback(ctx)
+	t.release()
+	return err
+}
+
+func (t *releasingTx) release() {
+	if !t.released {
+		t.released = true
+		t.conn.Release()
+	}
+}
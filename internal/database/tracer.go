@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// maxLoggedStatementLength caps the SQL text logged for a slow query.
+const maxLoggedStatementLength = 300
+
+// slowQueryStartKey is the context key holding a query's start time.
+type slowQueryStartKey struct{}
+
+// slowQueryTracer is a pgx.QueryTracer that logs queries taking at least
+// threshold. Only the statement text and duration are logged: arguments may
+// hold user data such as JSONB job payloads.
+type slowQueryTracer struct {
+	threshold time.Duration
+	logger    *zap.Logger
+	now       func() time.Time // Replaceable clock
+}
+
+// newSlowQueryTracer creates a tracer logging queries slower than threshold.
+func newSlowQueryTracer(threshold time.Duration, logger *zap.Logger) *slowQueryTracer {
+	return &slowQueryTracer{
+		threshold: threshold,
+		logger:    logger.Named("db"),
+		now:       time.Now,
+	}
+}
+
+// TraceQueryStart records the query start time and statement in the context.
+func (t *slowQueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, slowQueryStartKey{}, slowQueryStart{at: t.now(), sql: data.SQL})
+}
+
+// TraceQueryEnd logs the query if it ran for at least the threshold.
+func (t *slowQueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(slowQueryStartKey{}).(slowQueryStart)
+	if !ok {
+		return
+	}
+
+	elapsed := t.now().Sub(start.at)
+	if elapsed < t.threshold {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("statement", statementName(start.sql)),
+		zap.Duration("duration", elapsed),
+		zap.String("command", data.CommandTag.String()),
+	}
+	if data.Err != nil {
+		fields = append(fields, zap.Error(data.Err))
+	}
+	t.logger.Warn("slow query", fields...)
+}
+
+type slowQueryStart struct {
+	at  time.Time
+	sql string
+}
+
+// statementName returns the SQL text on one line with runs of whitespace
+// collapsed, truncated to maxLoggedStatementLength.
+func statementName(sql string) string {
+	name := strings.Join(strings.Fields(sql), " ")
+	if len(name) > maxLoggedStatementLength {
+		name = name[:maxLoggedStatementLength] + "..."
+	}
+	return name
+}
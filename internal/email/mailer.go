@@ -0,0 +1,35 @@
+// Package email provides outgoing email delivery.
+package email
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/pkg/logsanitize"
+)
+
+// Mailer sends email messages.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, htmlBody string) error
+}
+
+// logMailer logs messages instead of sending them. Used when no mail transport is configured.
+type logMailer struct {
+	logger *zap.Logger
+}
+
+// NewLogMailer creates a Mailer that only logs outgoing messages.
+func NewLogMailer(logger *zap.Logger) Mailer {
+	return &logMailer{logger: logger.Named("mailer")}
+}
+
+// Send logs the subject and masked recipient. The body is never logged since it
+// may contain links with tokens.
+func (m *logMailer) Send(ctx context.Context, to, subject, htmlBody string) error {
+	m.logger.Info("email not sent: no mail transport configured",
+		zap.String("to", logsanitize.Email(to)),
+		zap.String("subject", subject),
+	)
+	return nil
+}
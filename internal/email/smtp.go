@@ -0,0 +1,184 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/pkg/logsanitize"
+)
+
+// smtpTimeout bounds a whole SMTP conversation when ctx has no earlier deadline.
+const smtpTimeout = 30 * time.Second
+
+// smtpsPort is the implicit-TLS submission port; other ports upgrade with STARTTLS.
+const smtpsPort = 465
+
+// SMTPConfig holds the SMTP server settings.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // Auth is skipped when empty
+	Password string
+	From     string // Sender address, e.g. "UGC <noreply@example.com>"
+}
+
+// smtpMailer sends messages through an SMTP server.
+type smtpMailer struct {
+	cfg    SMTPConfig
+	logger *zap.Logger
+}
+
+// NewSMTPMailer creates a Mailer that sends messages through cfg's server.
+func NewSMTPMailer(cfg SMTPConfig, logger *zap.Logger) Mailer {
+	return &smtpMailer{cfg: cfg, logger: logger.Named("mailer")}
+}
+
+// New returns an SMTP mailer when cfg.Host is set, otherwise a mailer that only logs.
+func New(cfg SMTPConfig, logger *zap.Logger) Mailer {
+	if cfg.Host == "" {
+		return NewLogMailer(logger)
+	}
+	return NewSMTPMailer(cfg, logger)
+}
+
+// Send delivers an HTML message to a single recipient.
+func (m *smtpMailer) Send(ctx context.Context, to, subject, htmlBody string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return errors.New("email recipient and subject must not contain line breaks")
+	}
+
+	msg, err := m.buildMessage(to, subject, htmlBody)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, smtpTimeout)
+		defer cancel()
+	}
+
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if m.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate with SMTP server: %w", err)
+		}
+	}
+
+	if err := client.Mail(envelopeAddress(m.cfg.From)); err != nil {
+		return fmt.Errorf("failed to set SMTP sender: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("failed to set SMTP recipient: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start SMTP data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to write SMTP data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	if err := client.Quit(); err != nil {
+		m.logger.Debug("SMTP quit failed", zap.Error(err))
+	}
+
+	m.logger.Info("email sent", zap.String("to", logsanitize.Email(to)), zap.String("subject", subject))
+	return nil
+}
+
+// dial connects to the server, using implicit TLS on port 465 and STARTTLS
+// elsewhere when the server offers it.
+func (m *smtpMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: m.cfg.Host, MinVersion: tls.VersionTLS12}
+
+	var conn net.Conn
+	var err error
+	if m.cfg.Port == smtpsPort {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create SMTP client: %w", err)
+	}
+
+	if m.cfg.Port != smtpsPort {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, fmt.Errorf("failed to start TLS: %w", err)
+			}
+		}
+	}
+
+	return client, nil
+}
+
+// buildMessage renders the headers and quoted-printable HTML body.
+func (m *smtpMailer) buildMessage(to, subject, htmlBody string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.cfg.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", uuid.NewString(), m.cfg.Host)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(htmlBody)); err != nil {
+		return nil, fmt.Errorf("failed to encode email body: %w", err)
+	}
+	if err := qp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode email body: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// envelopeAddress extracts the bare address from a From header value such as
+// "UGC <noreply@example.com>".
+func envelopeAddress(from string) string {
+	if start := strings.LastIndex(from, "<"); start >= 0 {
+		if end := strings.LastIndex(from, ">"); end > start {
+			return from[start+1 : end]
+		}
+	}
+	return strings.TrimSpace(from)
+}
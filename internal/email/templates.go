@@ -0,0 +1,60 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+)
+
+// JobNotification is the data for a job completion or failure email.
+type JobNotification struct {
+	Title        string  // Song title, or "Your video" when unknown
+	Completed    bool    // false for failed jobs
+	Duration     float64 // seconds; 0 when unknown
+	DownloadURL  string  // Fresh video URL; empty for failed jobs
+	ErrorMessage string  // Failure reason; empty for completed jobs
+}
+
+var jobNotificationTemplate = template.Must(template.New("job_notification").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; line-height: 1.5;">
+{{if .Completed}}
+<p>Your music video <strong>{{.Title}}</strong> is ready.</p>
+{{if .DurationText}}<p>Duration: {{.DurationText}}</p>{{end}}
+{{if .DownloadURL}}<p><a href="{{.DownloadURL}}">Download your video</a></p>
+<p style="color: #666; font-size: 12px;">This link expires; open the app for a new one.</p>{{end}}
+{{else}}
+<p>Your music video <strong>{{.Title}}</strong> could not be generated.</p>
+{{if .ErrorMessage}}<p>Reason: {{.ErrorMessage}}</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// RenderJobNotification renders the subject and HTML body of a job notification email.
+func RenderJobNotification(n JobNotification) (subject, htmlBody string, err error) {
+	if n.Title == "" {
+		n.Title = "Your video"
+	}
+
+	data := struct {
+		JobNotification
+		DurationText string
+	}{JobNotification: n}
+	if n.Duration > 0 {
+		seconds := int(n.Duration + 0.5)
+		data.DurationText = fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+	}
+
+	var buf bytes.Buffer
+	if err := jobNotificationTemplate.Execute(&buf, data); err != nil {
+		return "", "", fmt.Errorf("failed to render job notification email: %w", err)
+	}
+
+	if n.Completed {
+		subject = fmt.Sprintf("Your video %q is ready", n.Title)
+	} else {
+		subject = fmt.Sprintf("Your video %q failed", n.Title)
+	}
+	return subject, buf.String(), nil
+}
@@ -0,0 +1,87 @@
+package kie
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxCreditsBodySize limits the credits response read into memory.
+const maxCreditsBodySize = 1024 // 1KB
+
+// AccountClient represents a client for KIE account endpoints.
+type AccountClient struct {
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// creditsResponse represents the response from the credits endpoint.
+type creditsResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data int    `json:"data"`
+}
+
+// NewAccountClient creates a new AccountClient with the given API key and base URL.
+func NewAccountClient(apiKey, baseURL string) *AccountClient {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &AccountClient{
+		apiKey:  apiKey,
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// GetCredits returns the remaining credit balance of the account.
+// Non-200 responses are returned as *APIError so callers can branch on the status
+// (401 invalid key, 402 insufficient credits, 455 service unavailable, ...).
+// https://docs.kie.ai/common-api/get-account-credits
+func (c *AccountClient) GetCredits(ctx context.Context) (int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v1/chat/credit", nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxCreditsBodySize))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, &APIError{
+			StatusCode: resp.StatusCode,
+			Message:    string(respBody),
+		}
+	}
+
+	var creditsResp creditsResponse
+	if err := json.Unmarshal(respBody, &creditsResp); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if creditsResp.Code != 200 {
+		return 0, &APIError{
+			StatusCode: creditsResp.Code,
+			Message:    creditsResp.Msg,
+		}
+	}
+
+	return creditsResp.Data, nil
+}
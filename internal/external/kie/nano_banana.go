@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -22,6 +23,10 @@ const (
 	AspectRatio4x3  = "4:3"
 	AspectRatio3x4  = "3:4"
 
+	// Resolutions
+	Resolution1K = "1K"
+	Resolution2K = "2K"
+
 	// Output formats
 	FormatPNG  = "png"
 	FormatJPG  = "jpg"
@@ -40,6 +45,17 @@ const (
 	StateFail       = "fail"
 )
 
+// ImageClient is the NanoBanana API used by the image generation tasks.
+// *NanoBananaClient implements it; tests can pass a fake instead.
+type ImageClient interface {
+	CreateTask(ctx context.Context, req CreateTaskRequest) (string, error)
+	GetTask(ctx context.Context, taskId string) (*TaskStatusResponse, error)
+	WaitForCompletion(ctx context.Context, taskId string, timeout time.Duration) (*TaskStatusResponse, error)
+	GetImageUrl(status *TaskStatusResponse) (string, error)
+}
+
+var _ ImageClient = (*NanoBananaClient)(nil)
+
 // NanoBananaClient is the client for KIE NanoBanana Pro API
 type NanoBananaClient struct {
 	apiKey     string
@@ -52,9 +68,24 @@ type NanoBananaClient struct {
 type NanoInput struct {
 	Prompt       string `json:"prompt"`
 	ImageSize    string `json:"image_size"`
+	Resolution   string `json:"resolution,omitempty"`
 	OutputFormat string `json:"output_format"`
 }
 
+// IsValidAspectRatio reports whether ratio is an image size supported by NanoBanana.
+func IsValidAspectRatio(ratio string) bool {
+	switch ratio {
+	case AspectRatio16x9, AspectRatio9x16, AspectRatio1x1, AspectRatio4x3, AspectRatio3x4:
+		return true
+	}
+	return false
+}
+
+// IsValidResolution reports whether resolution is supported by NanoBanana.
+func IsValidResolution(resolution string) bool {
+	return resolution == Resolution1K || resolution == Resolution2K
+}
+
 // CreateTaskRequest represents the request body for creating a task
 type CreateTaskRequest struct {
 	Model       string    `json:"model"`
@@ -70,6 +101,27 @@ type CreateTaskResponse struct {
 	} `json:"data"`
 }
 
+// contentPolicyMarkers are lowercase fragments of the failCode or failMsg KIE
+// reports when the image model refuses a prompt under its content policy.
+var contentPolicyMarkers = []string{"policy", "flagged", "safety", "sensitive", "prohibited", "moderation", "nsfw"}
+
+// ContentPolicyMessage is the job error shown to users when the image model keeps
+// refusing the cover prompt after it was sanitized.
+const ContentPolicyMessage = "The image model refused this song's cover image under its content policy, even without names of people or brands. Please rephrase the concept or upload your own cover image."
+
+// IsContentPolicyFailure reports whether a failed NanoBanana task was refused
+// for its prompt's content, e.g. a celebrity or brand name, rather than failing
+// for a reason a rephrased prompt cannot fix.
+func IsContentPolicyFailure(failCode, failMsg string) bool {
+	text := strings.ToLower(failCode + " " + failMsg)
+	for _, marker := range contentPolicyMarkers {
+		if strings.Contains(text, marker) {
+			return true
+		}
+	}
+	return false
+}
+
 // TaskStatusResponse represents the response from getting task status
 // https://docs.kie.ai/market/common/get-task-detail#response-format
 type TaskStatusResponse struct {
@@ -104,6 +156,12 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("KIE API error (status %d): %s", e.StatusCode, e.Message)
 }
 
+// HTTPStatusCode returns the status of the error: the HTTP status, or the code
+// in the response body when KIE answered 200 with an error code.
+func (e *APIError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
 // NewNanoBananaClient creates a new NanoBanana Pro API client
 func NewNanoBananaClient(apiKey, baseURL string) *NanoBananaClient {
 	if baseURL == "" {
@@ -205,7 +263,7 @@ func (c *NanoBananaClient) GetTask(ctx context.Context, taskId string) (*TaskSta
 	}
 
 	if statusResp.Code != 200 {
-		return nil, fmt.Errorf("API returned error code %d: %s", statusResp.Code, statusResp.Message)
+		return nil, &APIError{StatusCode: statusResp.Code, Message: statusResp.Message}
 	}
 
 	return &statusResp, nil
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // Suno model constants
@@ -20,6 +23,68 @@ const (
 	ModelV5       = "V5"
 )
 
+// DefaultModel is the Suno model used when neither the job nor the user picks one.
+const DefaultModel = ModelV5
+
+// ModelLimits are the custom mode length limits of a Suno model, in characters.
+type ModelLimits struct {
+	MaxPromptLength int
+	MaxStyleLength  int
+	MaxTitleLength  int
+}
+
+// modelLimits holds the limits of each supported model (per KIE API docs).
+// V4_5 and later accept longer lyrics and style descriptions than V3_5 and V4.
+var modelLimits = map[string]ModelLimits{
+	ModelV3_5:     {MaxPromptLength: 3000, MaxStyleLength: 200, MaxTitleLength: 80},
+	ModelV4:       {MaxPromptLength: 3000, MaxStyleLength: 200, MaxTitleLength: 80},
+	ModelV4_5:     {MaxPromptLength: 5000, MaxStyleLength: 1000, MaxTitleLength: 100},
+	ModelV4_5Plus: {MaxPromptLength: 5000, MaxStyleLength: 1000, MaxTitleLength: 100},
+	ModelV5:       {MaxPromptLength: 5000, MaxStyleLength: 1000, MaxTitleLength: 100},
+}
+
+// Models lists the supported Suno models, oldest first.
+var Models = []string{ModelV3_5, ModelV4, ModelV4_5, ModelV4_5Plus, ModelV5}
+
+// IsValidModel reports whether model is a supported Suno model.
+func IsValidModel(model string) bool {
+	_, ok := modelLimits[model]
+	return ok
+}
+
+// ResolveModel returns the first supported model among candidates, or DefaultModel.
+func ResolveModel(candidates ...string) string {
+	for _, model := range candidates {
+		if IsValidModel(model) {
+			return model
+		}
+	}
+	return DefaultModel
+}
+
+// LimitsFor returns the limits of model; unknown models get DefaultModel's limits.
+func LimitsFor(model string) ModelLimits {
+	if limits, ok := modelLimits[model]; ok {
+		return limits
+	}
+	return modelLimits[DefaultModel]
+}
+
+// Validate checks the prompt, style and title lengths against the limits.
+// Lengths are counted in characters, so Thai lyrics are measured as Suno counts them.
+func (l ModelLimits) Validate(prompt, style, title string) error {
+	if n := utf8.RuneCountInString(prompt); n > l.MaxPromptLength {
+		return fmt.Errorf("prompt exceeds %d character limit (got %d)", l.MaxPromptLength, n)
+	}
+	if n := utf8.RuneCountInString(style); n > l.MaxStyleLength {
+		return fmt.Errorf("style exceeds %d character limit (got %d)", l.MaxStyleLength, n)
+	}
+	if n := utf8.RuneCountInString(title); n > l.MaxTitleLength {
+		return fmt.Errorf("title exceeds %d character limit (got %d)", l.MaxTitleLength, n)
+	}
+	return nil
+}
+
 // Suno task status constants (per KIE API docs)
 // https://docs.kie.ai/suno-api/quickstart#status-codes-&-task-states
 const (
@@ -33,6 +98,29 @@ const (
 	StatusSensitiveWordError  = "SENSITIVE_WORD_ERROR"
 )
 
+// ErrSensitiveContent is returned when Suno rejects a prompt for containing restricted words.
+// Retrying the same prompt fails the same way.
+var ErrSensitiveContent = errors.New("content filtered due to sensitive words")
+
+// SensitiveContentMessage is the job error shown to users when Suno rejects a prompt.
+const SensitiveContentMessage = "Suno rejected this song because the concept or its lyrics contain restricted words. Please rephrase the concept and create a new job."
+
+// IsSensitiveWordError reports whether a Suno error message describes a sensitive word rejection.
+func IsSensitiveWordError(msg string) bool {
+	lower := strings.ToLower(msg)
+	return strings.Contains(lower, "sensitive")
+}
+
+// MusicClient is the Suno API used by the music generation tasks. *SunoClient
+// implements it; tests can pass a fake instead.
+type MusicClient interface {
+	Generate(ctx context.Context, req GenerateRequest) (string, error)
+	GetTask(ctx context.Context, taskId string) (*TaskResponse, error)
+	WaitForCompletion(ctx context.Context, taskId string, timeout time.Duration) (*TaskResponse, error)
+}
+
+var _ MusicClient = (*SunoClient)(nil)
+
 // SunoClient represents a client for the KIE Suno API
 type SunoClient struct {
 	apiKey     string
@@ -53,7 +141,8 @@ type GenerateRequest struct {
 
 // GenerateResponse represents the response from the generate endpoint
 type GenerateResponse struct {
-	Code int `json:"code"`
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
 	Data struct {
 		TaskId string `json:"taskId"`
 	} `json:"data"`
@@ -126,7 +215,7 @@ func (c *SunoClient) Generate(ctx context.Context, req GenerateRequest) (string,
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
+		return "", &APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
 	}
 
 	var generateResp GenerateResponse
@@ -135,7 +224,10 @@ func (c *SunoClient) Generate(ctx context.Context, req GenerateRequest) (string,
 	}
 
 	if generateResp.Code != 200 {
-		return "", fmt.Errorf("API returned error code %d", generateResp.Code)
+		if IsSensitiveWordError(generateResp.Msg) {
+			return "", fmt.Errorf("%w: %s", ErrSensitiveContent, generateResp.Msg)
+		}
+		return "", &APIError{StatusCode: generateResp.Code, Message: generateResp.Msg}
 	}
 
 	return generateResp.Data.TaskId, nil
@@ -165,7 +257,7 @@ func (c *SunoClient) GetTask(ctx context.Context, taskId string) (*TaskResponse,
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
 	}
 
 	var taskResp TaskResponse
@@ -174,7 +266,7 @@ func (c *SunoClient) GetTask(ctx context.Context, taskId string) (*TaskResponse,
 	}
 
 	if taskResp.Code != 200 {
-		return nil, fmt.Errorf("API returned error code %d: %s", taskResp.Code, taskResp.Msg)
+		return nil, &APIError{StatusCode: taskResp.Code, Message: taskResp.Msg}
 	}
 
 	return &taskResp, nil
@@ -215,7 +307,7 @@ func (c *SunoClient) WaitForCompletion(ctx context.Context, taskId string, timeo
 			case StatusCallbackException:
 				return taskResp, fmt.Errorf("callback exception: %s", taskResp.Data.ErrorMessage)
 			case StatusSensitiveWordError:
-				return taskResp, fmt.Errorf("content filtered due to sensitive words: %s", taskResp.Data.ErrorMessage)
+				return taskResp, fmt.Errorf("%w: %s", ErrSensitiveContent, taskResp.Data.ErrorMessage)
 			case StatusPending:
 				// Continue polling
 				continue
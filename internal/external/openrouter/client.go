@@ -5,9 +5,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -70,6 +72,92 @@ type APIError struct {
 	} `json:"error"`
 }
 
+// StatusError is returned for a non-200 response, carrying its HTTP status.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// HTTPStatusCode returns the HTTP status of the response.
+func (e *StatusError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+// newStatusError describes a non-200 response, using the API error in body when it has one.
+func newStatusError(statusCode int, body []byte) *StatusError {
+	var apiErr APIError
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		return &StatusError{StatusCode: statusCode, Message: fmt.Sprintf("request failed with status %d: %s", statusCode, string(body))}
+	}
+	return &StatusError{StatusCode: statusCode, Message: fmt.Sprintf("API error: %s (type: %s, code: %s)",
+		apiErr.Error.Message, apiErr.Error.Type, apiErr.Error.Code)}
+}
+
+// ErrUnauthorized is returned when OpenRouter rejects the API key.
+var ErrUnauthorized = errors.New("openrouter: invalid API key")
+
+// FinishReasonLength is the finish reason of a completion cut off at its output limit.
+const FinishReasonLength = "length"
+
+// TruncatedError is returned by Complete when the model stopped at its output
+// limit, so the content is incomplete.
+type TruncatedError struct {
+	Model     string
+	MaxTokens *int   // max_tokens of the request; nil when the model's default applied
+	Content   string // The partial output
+}
+
+func (e *TruncatedError) Error() string {
+	if e.MaxTokens != nil {
+		return fmt.Sprintf("openrouter: %s output truncated at max_tokens %d", e.Model, *e.MaxTokens)
+	}
+	return fmt.Sprintf("openrouter: %s output truncated at its output limit", e.Model)
+}
+
+// ModelPricing represents the USD price per token of a model, as decimal strings.
+type ModelPricing struct {
+	Prompt     string `json:"prompt"`
+	Completion string `json:"completion"`
+}
+
+// ModelArchitecture describes the input and output modalities of a model.
+type ModelArchitecture struct {
+	Modality         string   `json:"modality"` // e.g., "text+image->text"
+	InputModalities  []string `json:"input_modalities"`
+	OutputModalities []string `json:"output_modalities"`
+}
+
+// Model represents a model listed by the models endpoint.
+type Model struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	ContextLength int               `json:"context_length"`
+	Pricing       ModelPricing      `json:"pricing"`
+	Architecture  ModelArchitecture `json:"architecture"`
+}
+
+// IsChatCapable returns true if the model produces text output.
+func (m *Model) IsChatCapable() bool {
+	if len(m.Architecture.OutputModalities) > 0 {
+		for _, modality := range m.Architecture.OutputModalities {
+			if modality == "text" {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.HasSuffix(m.Architecture.Modality, "->text")
+}
+
+// ModelsResponse represents a response from the models endpoint.
+type ModelsResponse struct {
+	Data []Model `json:"data"`
+}
+
 // ClientOption is a function that configures a Client.
 type ClientOption func(*Client)
 
@@ -94,6 +182,16 @@ func WithTimeout(timeout time.Duration) ClientOption {
 	}
 }
 
+// ChatClient is the chat API used by the agents. *Client implements it; tests can
+// pass a fake instead.
+type ChatClient interface {
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	Complete(ctx context.Context, req ChatRequest) (string, error)
+	ChatWithModel(ctx context.Context, model string, systemPrompt string, userPrompt string) (string, error)
+}
+
+var _ ChatClient = (*Client)(nil)
+
 // NewClient creates a new OpenRouter API client.
 func NewClient(apiKey string, opts ...ClientOption) *Client {
 	c := &Client{
@@ -140,12 +238,7 @@ func (c *Client) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, erro
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		var apiErr APIError
-		if err := json.Unmarshal(respBody, &apiErr); err != nil {
-			return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
-		}
-		return nil, fmt.Errorf("API error: %s (type: %s, code: %s)",
-			apiErr.Error.Message, apiErr.Error.Type, apiErr.Error.Code)
+		return nil, newStatusError(resp.StatusCode, respBody)
 	}
 
 	var chatResp ChatResponse
@@ -156,27 +249,85 @@ func (c *Client) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, erro
 	return &chatResp, nil
 }
 
+// Complete sends a chat request and returns the content of the first choice. It
+// returns a *TruncatedError when the model hit its output limit.
+func (c *Client) Complete(ctx context.Context, req ChatRequest) (string, error) {
+	resp, err := c.Chat(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	return CompletionContent(req, resp)
+}
+
+// CompletionContent returns the content of the first choice of resp, the response
+// to req, or a *TruncatedError when the model hit its output limit. Wrappers of a
+// ChatClient use it to implement Complete on top of Chat.
+func CompletionContent(req ChatRequest, resp *ChatResponse) (string, error) {
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no choices returned in response")
+	}
+
+	choice := resp.Choices[0]
+	if choice.FinishReason == FinishReasonLength {
+		return "", &TruncatedError{Model: req.Model, MaxTokens: req.MaxTokens, Content: choice.Message.Content}
+	}
+
+	return choice.Message.Content, nil
+}
+
 // ChatWithModel is a convenience method that sends a chat request with a system and user prompt
-// and returns only the content string from the response.
+// and returns only the content string from the response, or a *TruncatedError.
 func (c *Client) ChatWithModel(ctx context.Context, model string, systemPrompt string, userPrompt string) (string, error) {
+	return c.Complete(ctx, PromptRequest(model, systemPrompt, userPrompt))
+}
+
+// PromptRequest returns the request ChatWithModel sends: a system and a user prompt for model.
+func PromptRequest(model string, systemPrompt string, userPrompt string) ChatRequest {
 	messages := []Message{
 		{Role: "system", Content: systemPrompt},
 		{Role: "user", Content: userPrompt},
 	}
 
-	req := ChatRequest{
+	return ChatRequest{
 		Model:    model,
 		Messages: messages,
 	}
+}
 
-	resp, err := c.Chat(ctx, req)
+// ListModels returns the models available to the API key.
+func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
+	url := fmt.Sprintf("%s/models", c.baseURL)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no choices returned in response")
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(resp.StatusCode, respBody)
+	}
+
+	var modelsResp ModelsResponse
+	if err := json.Unmarshal(respBody, &modelsResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	return modelsResp.Data, nil
 }
@@ -0,0 +1,95 @@
+package r2
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Job asset types stored in R2.
+const (
+	AssetVideo     = "video"
+	AssetAudio     = "audio"
+	AssetImage     = "image"
+	AssetThumbnail = "thumbnail"
+)
+
+// jobAsset describes where a job asset is stored and how it is served.
+type jobAsset struct {
+	prefix      string
+	extension   string
+	contentType string
+}
+
+// jobAssets maps asset types to their storage layout: {prefix}/{job_id}.{extension}
+var jobAssets = map[string]jobAsset{
+	AssetVideo:     {prefix: "videos", extension: "mp4", contentType: "video/mp4"},
+	AssetAudio:     {prefix: "audio", extension: "mp3", contentType: "audio/mpeg"},
+	AssetImage:     {prefix: "images", extension: "png", contentType: "image/png"},
+	AssetThumbnail: {prefix: "thumbnails", extension: "jpg", contentType: "image/jpeg"},
+}
+
+// JobAssetKey returns the object key for a job asset, e.g. videos/{job_id}.mp4.
+func JobAssetKey(asset string, jobID string) (string, error) {
+	a, ok := jobAssets[asset]
+	if !ok {
+		return "", fmt.Errorf("r2: unknown asset type %q", asset)
+	}
+	return fmt.Sprintf("%s/%s.%s", a.prefix, jobID, a.extension), nil
+}
+
+// JobAssetExtension returns the file extension (without dot) for a job asset type.
+func JobAssetExtension(asset string) string {
+	return jobAssets[asset].extension
+}
+
+// JobAssetContentType returns the MIME type for a job asset type.
+func JobAssetContentType(asset string) string {
+	return jobAssets[asset].contentType
+}
+
+// JobAssetPrefix returns the key prefix of a job asset type, e.g. "videos/".
+func JobAssetPrefix(asset string) string {
+	return jobAssets[asset].prefix + "/"
+}
+
+// TrackKey returns the object key of a kept Suno track of a job, e.g.
+// audio/{job_id}/{track_id}.mp3.
+func TrackKey(jobID string, trackID string) string {
+	a := jobAssets[AssetAudio]
+	return fmt.Sprintf("%s%s.%s", TrackPrefix(jobID), trackID, a.extension)
+}
+
+// TrackPrefix returns the key prefix of a job's kept tracks, e.g. "audio/{job_id}/".
+func TrackPrefix(jobID string) string {
+	return JobAssetPrefix(AssetAudio) + jobID + "/"
+}
+
+// JobIDFromKey returns the job ID a job asset, kept track or user image key
+// belongs to, e.g. the {job_id} of videos/{job_id}.mp4, audio/{job_id}/{track_id}.mp3
+// or uploads/{job_id}/cover.png. ok is false for keys that follow no such layout.
+func JobIDFromKey(key string) (jobID string, ok bool) {
+	if rest, found := strings.CutPrefix(key, userImagePrefix); found {
+		jobID, _, ok = strings.Cut(rest, "/")
+		return jobID, ok && jobID != ""
+	}
+	for _, asset := range JobAssetTypes() {
+		a := jobAssets[asset]
+		rest, found := strings.CutPrefix(key, a.prefix+"/")
+		if !found {
+			continue
+		}
+		if asset == AssetAudio {
+			if id, track, isTrack := strings.Cut(rest, "/"); isTrack {
+				return id, id != "" && strings.HasSuffix(track, "."+a.extension) && !strings.Contains(track, "/")
+			}
+		}
+		jobID, found = strings.CutSuffix(rest, "."+a.extension)
+		return jobID, found && jobID != "" && !strings.Contains(jobID, "/")
+	}
+	return "", false
+}
+
+// JobAssetTypes returns all known job asset types.
+func JobAssetTypes() []string {
+	return []string{AssetVideo, AssetAudio, AssetImage, AssetThumbnail}
+}
@@ -0,0 +1,168 @@
+package r2
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Multipart upload settings. Files above MultipartThreshold are sent in
+// MultipartPartSize parts, so a network error costs one part rather than the
+// whole file; each part is retried by the SDK's default retryer.
+const (
+	MultipartThreshold = 100 << 20
+	MultipartPartSize  = 16 << 20
+
+	// multipartProgressParts is how many parts are uploaded between progress reports.
+	multipartProgressParts = 8
+
+	// abortTimeout bounds the abort of a failed upload, which runs even when ctx is done.
+	abortTimeout = 30 * time.Second
+)
+
+// UploadProgress reports how far a multipart upload has got.
+type UploadProgress struct {
+	Key        string
+	PartsDone  int
+	TotalParts int
+	BytesDone  int64
+	TotalBytes int64
+}
+
+// ProgressFunc receives multipart upload progress. It is called from the
+// uploading goroutine, so it must not block.
+type ProgressFunc func(UploadProgress)
+
+// UploadFile uploads the file at path, using a multipart upload when it is larger
+// than MultipartThreshold. progress is optional and only called for multipart uploads.
+func (c *Client) UploadFile(ctx context.Context, key string, path string, contentType string, progress ProgressFunc) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("r2: failed to open %q: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("r2: failed to stat %q: %w", path, err)
+	}
+
+	if info.Size() <= MultipartThreshold {
+		return c.Upload(ctx, key, file, contentType)
+	}
+	return c.UploadLarge(ctx, key, file, info.Size(), contentType, progress)
+}
+
+// UploadLarge uploads size bytes from body as a multipart upload of
+// MultipartPartSize parts, sent in order. On any error the upload is aborted so
+// R2 does not keep the uploaded parts.
+func (c *Client) UploadLarge(ctx context.Context, key string, body io.Reader, size int64, contentType string, progress ProgressFunc) error {
+	created, err := c.s3Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(c.bucketName),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("r2: failed to start multipart upload of %q: %w", key, err)
+	}
+	uploadID := created.UploadId
+
+	parts, err := c.uploadParts(ctx, key, uploadID, body, size, progress)
+	if err == nil {
+		_, err = c.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(c.bucketName),
+			Key:             aws.String(key),
+			UploadId:        uploadID,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+		})
+		if err != nil {
+			err = fmt.Errorf("r2: failed to complete multipart upload of %q: %w", key, err)
+		}
+	}
+	if err != nil {
+		if abortErr := c.abortMultipartUpload(ctx, key, uploadID); abortErr != nil {
+			return errors.Join(err, abortErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// uploadParts reads body in MultipartPartSize parts and uploads them in order,
+// returning the completed parts for CompleteMultipartUpload.
+func (c *Client) uploadParts(ctx context.Context, key string, uploadID *string, body io.Reader, size int64, progress ProgressFunc) ([]types.CompletedPart, error) {
+	totalParts := int((size + MultipartPartSize - 1) / MultipartPartSize)
+	parts := make([]types.CompletedPart, 0, totalParts)
+	buf := make([]byte, MultipartPartSize)
+	var done int64
+
+	for partNumber := int32(1); ; partNumber++ {
+		n, err := io.ReadFull(body, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("r2: failed to read part %d of %q: %w", partNumber, key, err)
+		}
+
+		// A bytes reader is seekable, so the SDK can retry the part
+		uploaded, uploadErr := c.s3Client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(c.bucketName),
+			Key:           aws.String(key),
+			UploadId:      uploadID,
+			PartNumber:    aws.Int32(partNumber),
+			Body:          bytes.NewReader(buf[:n]),
+			ContentLength: aws.Int64(int64(n)),
+		})
+		if uploadErr != nil {
+			return nil, fmt.Errorf("r2: failed to upload part %d of %q: %w", partNumber, key, uploadErr)
+		}
+		parts = append(parts, types.CompletedPart{ETag: uploaded.ETag, PartNumber: aws.Int32(partNumber)})
+		done += int64(n)
+
+		if progress != nil && (len(parts)%multipartProgressParts == 0 || len(parts) == totalParts) {
+			progress(UploadProgress{
+				Key:        key,
+				PartsDone:  len(parts),
+				TotalParts: totalParts,
+				BytesDone:  done,
+				TotalBytes: size,
+			})
+		}
+
+		// A short read is the last part
+		if err == io.ErrUnexpectedEOF {
+			break
+		}
+	}
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("r2: no data to upload for %q", key)
+	}
+	return parts, nil
+}
+
+// abortMultipartUpload discards the parts of a failed upload. It runs on a fresh
+// context so a cancelled task still cleans up.
+func (c *Client) abortMultipartUpload(ctx context.Context, key string, uploadID *string) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+	defer cancel()
+
+	_, err := c.s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(c.bucketName),
+		Key:      aws.String(key),
+		UploadId: uploadID,
+	})
+	if err != nil {
+		return fmt.Errorf("r2: failed to abort multipart upload of %q: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package r2_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// newFakeR2Client returns a client of a fresh fake R2 server.
+func newFakeR2Client(t *testing.T) (*r2.Client, *testutil.FakeR2) {
+	t.Helper()
+
+	fake := testutil.NewFakeR2(t)
+	client, err := r2.NewClient(context.Background(), fake.Config())
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client, fake
+}
+
+// randomBytes returns n bytes that differ from part to part, so a reordered
+// part changes the assembled object.
+func randomBytes(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(int64(n))).Read(data)
+	return data
+}
+
+func TestUploadLarge(t *testing.T) {
+	client, fake := newFakeR2Client(t)
+	// Two full parts and a short last part
+	data := randomBytes(2*r2.MultipartPartSize + 1234)
+
+	var progress []r2.UploadProgress
+	err := client.UploadLarge(context.Background(), "videos/job.mp4", bytes.NewReader(data), int64(len(data)), "video/mp4",
+		func(p r2.UploadProgress) { progress = append(progress, p) })
+	if err != nil {
+		t.Fatalf("UploadLarge() error = %v", err)
+	}
+
+	stored, ok := fake.Object("videos/job.mp4")
+	if !ok || !bytes.Equal(stored, data) {
+		t.Errorf("stored %d bytes, want the %d uploaded bytes in order", len(stored), len(data))
+	}
+
+	uploads := fake.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("%d multipart uploads, want 1", len(uploads))
+	}
+	if u := uploads[0]; !slices.Equal(u.PartNumbers, []int{1, 2, 3}) || !u.Completed || u.Aborted {
+		t.Errorf("upload = %+v, want parts 1, 2 and 3 sent in order and completed", u)
+	}
+
+	// Reported every multipartProgressParts parts and after the last one
+	want := []r2.UploadProgress{{Key: "videos/job.mp4", PartsDone: 3, TotalParts: 3, BytesDone: int64(len(data)), TotalBytes: int64(len(data))}}
+	if !slices.Equal(progress, want) {
+		t.Errorf("progress = %+v, want %+v", progress, want)
+	}
+}
+
+func TestUploadLargeAbortsOnFailure(t *testing.T) {
+	data := randomBytes(3*r2.MultipartPartSize + 1)
+
+	tests := []struct {
+		name      string
+		failPart  int
+		cancelAt  int // Bytes read before the context is cancelled; 0 to never cancel
+		wantParts []int
+	}{
+		{name: "failed part", failPart: 2, wantParts: []int{1, 2}},
+		{name: "failed last part", failPart: 4, wantParts: []int{1, 2, 3, 4}},
+		{name: "cancelled", cancelAt: r2.MultipartPartSize + 1, wantParts: []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, fake := newFakeR2Client(t)
+			fake.FailPart = tt.failPart
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var body io.Reader = bytes.NewReader(data)
+			if tt.cancelAt > 0 {
+				body = &cancellingReader{r: body, after: tt.cancelAt, cancel: cancel}
+			}
+
+			err := client.UploadLarge(ctx, "videos/job.mp4", body, int64(len(data)), "video/mp4", nil)
+			if err == nil {
+				t.Fatal("UploadLarge() error = nil, want the upload to fail")
+			}
+			if tt.cancelAt > 0 && !errors.Is(err, context.Canceled) {
+				t.Errorf("UploadLarge() error = %v, want context.Canceled", err)
+			}
+
+			uploads := fake.Uploads()
+			if len(uploads) != 1 {
+				t.Fatalf("%d multipart uploads, want 1", len(uploads))
+			}
+			if u := uploads[0]; !slices.Equal(u.PartNumbers, tt.wantParts) || u.Completed || !u.Aborted {
+				t.Errorf("upload = %+v, want parts %v sent and the upload aborted", u, tt.wantParts)
+			}
+			if _, ok := fake.Object("videos/job.mp4"); ok {
+				t.Error("a failed upload stored an object")
+			}
+		})
+	}
+}
+
+// cancellingReader calls cancel once more than after bytes have been read.
+type cancellingReader struct {
+	r      io.Reader
+	after  int
+	read   int
+	cancel context.CancelFunc
+}
+
+func (c *cancellingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += n
+	if c.read >= c.after {
+		c.cancel()
+	}
+	return n, err
+}
+
+func TestUploadFileSmallUsesSinglePut(t *testing.T) {
+	client, fake := newFakeR2Client(t)
+	data := []byte(strings.Repeat("ID3", 1000))
+	path := filepath.Join(t.TempDir(), "song.mp3")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	progressCalled := false
+	if err := client.UploadFile(context.Background(), "audio/job.mp3", path, "audio/mpeg",
+		func(r2.UploadProgress) { progressCalled = true }); err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+
+	if stored, ok := fake.Object("audio/job.mp3"); !ok || !bytes.Equal(stored, data) {
+		t.Errorf("stored %d bytes, want the file", len(stored))
+	}
+	if uploads := fake.Uploads(); len(uploads) != 0 || progressCalled {
+		t.Errorf("a file under the threshold used %d multipart uploads", len(uploads))
+	}
+}
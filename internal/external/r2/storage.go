@@ -34,6 +34,10 @@ type Config struct {
 	// PublicURL is the optional public URL for the bucket (e.g., custom domain or r2.dev URL)
 	// If set, GetPublicURL will return URLs using this base URL
 	PublicURL string
+
+	// Endpoint overrides the R2 endpoint derived from AccountID, e.g. with a local
+	// S3-compatible server in tests. Buckets are then addressed by path.
+	Endpoint string
 }
 
 // Client is a Cloudflare R2 storage client.
@@ -61,6 +65,9 @@ func NewClient(ctx context.Context, cfg Config) (*Client, error) {
 
 	// R2 endpoint format: https://{account_id}.r2.cloudflarestorage.com
 	endpoint := fmt.Sprintf("https://%s.r2.cloudflarestorage.com", cfg.AccountID)
+	if cfg.Endpoint != "" {
+		endpoint = cfg.Endpoint
+	}
 
 	// Create credentials provider
 	creds := credentials.NewStaticCredentialsProvider(
@@ -74,6 +81,7 @@ func NewClient(ctx context.Context, cfg Config) (*Client, error) {
 		BaseEndpoint: aws.String(endpoint),
 		Credentials:  creds,
 		Region:       "auto", // R2 uses "auto" as region
+		UsePathStyle: cfg.Endpoint != "",
 	})
 
 	// Normalize public URL (remove trailing slash if present)
@@ -148,6 +156,23 @@ func (c *Client) GetPresignedURL(ctx context.Context, key string, expiry time.Du
 	return presignedReq.URL, nil
 }
 
+// GetPresignedDownloadURL generates a presigned URL that makes the browser save the object
+// under the given filename (via the response-content-disposition override).
+func (c *Client) GetPresignedDownloadURL(ctx context.Context, key string, expiry time.Duration, contentDisposition string) (string, error) {
+	input := &s3.GetObjectInput{
+		Bucket:                     aws.String(c.bucketName),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String(contentDisposition),
+	}
+
+	presignedReq, err := c.presigner.PresignGetObject(ctx, input, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", fmt.Errorf("r2: failed to generate presigned download URL for %q: %w", key, err)
+	}
+
+	return presignedReq.URL, nil
+}
+
 // GetPublicURL returns the public URL for an object.
 // Returns an empty string if publicURL is not configured.
 func (c *Client) GetPublicURL(key string) string {
@@ -161,6 +186,18 @@ func (c *Client) GetPublicURL(key string) string {
 	return fmt.Sprintf("%s/%s", c.publicURL, key)
 }
 
+// AssetURLExpiry is the lifetime of presigned URLs generated by AssetURL.
+const AssetURLExpiry = 24 * time.Hour
+
+// AssetURL returns a URL clients can fetch key from: the public URL when configured,
+// otherwise a fresh presigned URL valid for AssetURLExpiry.
+func (c *Client) AssetURL(ctx context.Context, key string) (string, error) {
+	if publicURL := c.GetPublicURL(key); publicURL != "" {
+		return publicURL, nil
+	}
+	return c.GetPresignedURL(ctx, key, AssetURLExpiry)
+}
+
 // Delete removes an object from R2 storage.
 func (c *Client) Delete(ctx context.Context, key string) error {
 	input := &s3.DeleteObjectInput{
@@ -176,6 +213,84 @@ func (c *Client) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// Object describes a stored object returned by List.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// List returns every object whose key starts with prefix, paging through the
+// bucket listing 1000 keys at a time.
+func (c *Client) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	paginator := s3.NewListObjectsV2Paginator(c.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(c.bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("r2: failed to list objects under %q: %w", prefix, err)
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, Object{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
+
+// ListJobObjectKeys returns every object key a job may own: JobObjectKeys plus
+// its kept tracks, which are listed since their names vary.
+func (c *Client) ListJobObjectKeys(ctx context.Context, jobID string) ([]string, error) {
+	tracks, err := c.List(ctx, TrackPrefix(jobID))
+	if err != nil {
+		return nil, err
+	}
+	keys := JobObjectKeys(jobID)
+	for _, obj := range tracks {
+		keys = append(keys, obj.Key)
+	}
+	return keys, nil
+}
+
+// maxDeleteKeys is the most keys one DeleteObjects request accepts.
+const maxDeleteKeys = 1000
+
+// DeleteMany removes the objects in batches of up to 1000 keys. Missing keys are
+// not an error. It returns how many keys were deleted, which is less than
+// len(keys) when some deletes failed.
+func (c *Client) DeleteMany(ctx context.Context, keys []string) (int, error) {
+	deleted := 0
+	for start := 0; start < len(keys); start += maxDeleteKeys {
+		batch := keys[start:min(start+maxDeleteKeys, len(keys))]
+		objects := make([]types.ObjectIdentifier, len(batch))
+		for i, key := range batch {
+			objects[i] = types.ObjectIdentifier{Key: aws.String(key)}
+		}
+
+		output, err := c.s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(c.bucketName),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("r2: failed to delete %d objects: %w", len(batch), err)
+		}
+		// Quiet mode only reports the keys that failed
+		deleted += len(batch) - len(output.Errors)
+		if len(output.Errors) > 0 {
+			first := output.Errors[0]
+			return deleted, fmt.Errorf("r2: failed to delete %d of %d objects, first %q: %s",
+				len(output.Errors), len(batch), aws.ToString(first.Key), aws.ToString(first.Message))
+		}
+	}
+	return deleted, nil
+}
+
 // Exists checks if an object exists in R2 storage.
 func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
 	input := &s3.HeadObjectInput{
@@ -209,6 +324,37 @@ func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
 	return true, nil
 }
 
+// ErrObjectNotFound is returned by Open when the key does not exist.
+var ErrObjectNotFound = errors.New("r2: object not found")
+
+// Open returns a reader that streams the object's content; the caller must close
+// it. Missing objects return ErrObjectNotFound.
+func (c *Client) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	output, err := c.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) || isNotFoundError(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("r2: failed to get object %q: %w", key, err)
+	}
+	return output.Body, nil
+}
+
+// HeadBucket checks that the bucket is reachable with the configured credentials.
+func (c *Client) HeadBucket(ctx context.Context) error {
+	_, err := c.s3Client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(c.bucketName),
+	})
+	if err != nil {
+		return fmt.Errorf("r2: failed to head bucket %q: %w", c.bucketName, err)
+	}
+	return nil
+}
+
 // isNotFoundError checks if the error indicates the object was not found.
 // This is a fallback for error patterns not covered by AWS SDK error types.
 func isNotFoundError(err error) bool {
@@ -0,0 +1,74 @@
+package r2
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// MaxUserImageSize is the largest cover image a user can supply (10MB).
+const MaxUserImageSize = 10 << 20
+
+// ErrUnsupportedImage is returned when a user image is not a PNG, JPEG or WebP file.
+var ErrUnsupportedImage = errors.New("image must be a PNG, JPEG or WebP file")
+
+// userImageType describes an accepted user image format.
+type userImageType struct {
+	extension   string
+	contentType string
+}
+
+// userImageTypes lists the accepted user image formats, in the order they are sniffed.
+var userImageTypes = []userImageType{
+	{extension: "png", contentType: "image/png"},
+	{extension: "jpg", contentType: "image/jpeg"},
+	{extension: "webp", contentType: "image/webp"},
+}
+
+// UserImageSniffLength is how many leading bytes SniffUserImage needs.
+const UserImageSniffLength = 12
+
+// SniffUserImage detects a user image's format from its magic bytes, returning the
+// file extension and MIME type. The client-supplied content type is never trusted.
+func SniffUserImage(head []byte) (extension, contentType string, err error) {
+	var t userImageType
+	switch {
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		t = userImageTypes[0]
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		t = userImageTypes[1]
+	case len(head) >= 12 && bytes.Equal(head[0:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP")):
+		t = userImageTypes[2]
+	default:
+		return "", "", ErrUnsupportedImage
+	}
+	return t.extension, t.contentType, nil
+}
+
+// userImagePrefix is the key prefix of user-supplied cover images.
+const userImagePrefix = "uploads/"
+
+// UserImagePrefix returns the key prefix of user-supplied cover images.
+func UserImagePrefix() string {
+	return userImagePrefix
+}
+
+// UserImageKey returns the object key of a job's user-supplied cover image,
+// e.g. uploads/{job_id}/cover.png.
+func UserImageKey(jobID string, extension string) string {
+	return fmt.Sprintf("%s%s/cover.%s", userImagePrefix, jobID, extension)
+}
+
+// JobObjectKeys returns every object key a job may own: its generated assets and
+// any user-supplied cover image. Used to clean up deleted jobs.
+func JobObjectKeys(jobID string) []string {
+	keys := make([]string, 0, len(jobAssets)+len(userImageTypes))
+	for _, asset := range JobAssetTypes() {
+		key, _ := JobAssetKey(asset, jobID)
+		keys = append(keys, key)
+	}
+	for _, t := range userImageTypes {
+		keys = append(keys, UserImageKey(jobID, t.extension))
+	}
+	return keys
+}
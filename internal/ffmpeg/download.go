@@ -0,0 +1,169 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// Default download size limits, used when CreateMusicVideoInput leaves them zero.
+const (
+	DefaultMaxAudioBytes int64 = 200 << 20
+	DefaultMaxImageBytes int64 = 20 << 20
+)
+
+// sniffLength is how many leading bytes of a download are checked and reported.
+const sniffLength = 32
+
+// ErrUnexpectedContent is matched by errors.Is for an UnexpectedContentError.
+var ErrUnexpectedContent = errors.New("ffmpeg: downloaded file is not the expected media type")
+
+// ErrDownloadTooLarge is returned when a download exceeds its size limit.
+var ErrDownloadTooLarge = errors.New("ffmpeg: download exceeds size limit")
+
+// UnexpectedContentError is returned when a downloaded file's header does not
+// match any accepted format of its kind, e.g. a CDN serving an HTML error page
+// with status 200. Retrying the same URL returns the same content.
+type UnexpectedContentError struct {
+	Kind        string // "audio" or "image"
+	ContentType string // Content-Type header of the response
+	Head        string // The first bytes of the file, hex encoded
+}
+
+func (e *UnexpectedContentError) Error() string {
+	return fmt.Sprintf("ffmpeg: downloaded %s is not a supported format (content-type %q, first bytes %s)",
+		e.Kind, e.ContentType, e.Head)
+}
+
+func (e *UnexpectedContentError) Unwrap() error {
+	return ErrUnexpectedContent
+}
+
+// mediaKind describes what a download must contain.
+type mediaKind struct {
+	name   string
+	accept string                 // Accept header of the request
+	sniff  func(head []byte) bool // Reports whether the header matches an accepted format
+}
+
+var (
+	audioMedia = mediaKind{
+		name:   "audio",
+		accept: "audio/mpeg, audio/mp4, audio/ogg;q=0.9, audio/*;q=0.8",
+		sniff:  isAudioHeader,
+	}
+	imageMedia = mediaKind{
+		name:   "image",
+		accept: "image/png, image/jpeg, image/webp;q=0.9, image/*;q=0.8",
+		sniff:  isImageHeader,
+	}
+)
+
+// isAudioHeader reports whether head starts like an MP3 (ID3 tag or MPEG frame
+// sync), M4A (ISO base media ftyp box) or Ogg file.
+func isAudioHeader(head []byte) bool {
+	switch {
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return true
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return true
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		return true
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return true
+	}
+	return false
+}
+
+// isImageHeader reports whether head starts like a PNG, JPEG or WebP file.
+func isImageHeader(head []byte) bool {
+	switch {
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return true
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return true
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return true
+	}
+	return false
+}
+
+// downloadClient downloads render inputs. Downloads can take minutes, so the
+// request context bounds them instead of a client timeout.
+var downloadClient = &http.Client{}
+
+// downloadFile streams a file of kind from url to destPath, reading at most
+// maxBytes, and checks its header before the file is used.
+func downloadFile(ctx context.Context, url, destPath string, kind mediaKind, maxBytes int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", kind.accept)
+
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("download failed with status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxBytes {
+		return fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrDownloadTooLarge, kind.name, resp.ContentLength, maxBytes)
+	}
+
+	file, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	// Read one byte past the limit to tell a file of exactly maxBytes from a larger one
+	written, err := io.Copy(file, io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	if written > maxBytes {
+		return fmt.Errorf("%w: %s is larger than %d bytes", ErrDownloadTooLarge, kind.name, maxBytes)
+	}
+	if written == 0 {
+		return fmt.Errorf("downloaded file is empty")
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read downloaded file: %w", err)
+	}
+	if !kind.sniff(head[:n]) {
+		return &UnexpectedContentError{
+			Kind:        kind.name,
+			ContentType: resp.Header.Get("Content-Type"),
+			Head:        hex.EncodeToString(head[:n]),
+		}
+	}
+
+	return nil
+}
+
+// downloadOnce downloads url to destPath unless a previous render left it there.
+// The download goes to a partial file first, so an interrupted or rejected one is
+// not reused.
+func downloadOnce(ctx context.Context, url, destPath string, kind mediaKind, maxBytes int64) error {
+	if fileComplete(destPath) {
+		return nil
+	}
+	partial := destPath + ".partial"
+	if err := downloadFile(ctx, url, partial, kind, maxBytes); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, destPath)
+}
@@ -0,0 +1,193 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// Leading bytes of each accepted format, and an error page served in place of a file.
+var (
+	mp3ID3Header   = []byte("ID3\x04\x00\x00\x00\x00\x00\x00")
+	mp3FrameHeader = []byte{0xFF, 0xFB, 0x90, 0x64, 0x00, 0x00}
+	m4aHeader      = []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00")
+	oggHeader      = []byte("OggS\x00\x02\x00\x00")
+	pngHeader      = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	jpegHeader     = []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F'}
+	webpHeader     = []byte("RIFF\x24\x00\x00\x00WEBPVP8 ")
+	htmlPage       = []byte("<!DOCTYPE html>\n<html><head><title>403 Forbidden</title></head><body>Access denied</body></html>")
+)
+
+func TestMediaHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		head      []byte
+		wantAudio bool
+		wantImage bool
+	}{
+		{name: "mp3 with an ID3 tag", head: mp3ID3Header, wantAudio: true},
+		{name: "mp3 frame sync", head: mp3FrameHeader, wantAudio: true},
+		{name: "m4a", head: m4aHeader, wantAudio: true},
+		{name: "ogg", head: oggHeader, wantAudio: true},
+		{name: "png", head: pngHeader, wantImage: true},
+		{name: "jpeg", head: jpegHeader, wantImage: true},
+		{name: "webp", head: webpHeader, wantImage: true},
+		{name: "html error page", head: htmlPage},
+		{name: "json error", head: []byte(`{"error":"expired"}`)},
+		{name: "riff without webp", head: []byte("RIFF\x24\x00\x00\x00WAVEfmt ")},
+		{name: "truncated png", head: pngHeader[:4]},
+		{name: "empty", head: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isAudioHeader(tt.head); got != tt.wantAudio {
+				t.Errorf("isAudioHeader() = %v, want %v", got, tt.wantAudio)
+			}
+			if got := isImageHeader(tt.head); got != tt.wantImage {
+				t.Errorf("isImageHeader() = %v, want %v", got, tt.wantImage)
+			}
+		})
+	}
+}
+
+// mediaServer serves body with contentType and records the Accept header of
+// the last request. Unless chunked, the response states its Content-Length.
+type mediaServer struct {
+	*httptest.Server
+	accept string
+}
+
+func newMediaServer(t *testing.T, contentType string, body []byte, chunked bool) *mediaServer {
+	t.Helper()
+	s := &mediaServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.accept = r.Header.Get("Accept")
+		w.Header().Set("Content-Type", contentType)
+		if !chunked {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		w.WriteHeader(http.StatusOK)
+		// Without a Content-Length, writing in parts makes the response chunked
+		for len(body) > 0 {
+			n := min(len(body), 512)
+			w.Write(body[:n])
+			if f, ok := w.(http.Flusher); ok && chunked {
+				f.Flush()
+			}
+			body = body[n:]
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestDownloadFile(t *testing.T) {
+	audio := append(append([]byte{}, mp3ID3Header...), bytes.Repeat([]byte{0x55}, 4096)...)
+	image := append(append([]byte{}, webpHeader...), bytes.Repeat([]byte{0xAA}, 2048)...)
+
+	tests := []struct {
+		name        string
+		kind        mediaKind
+		contentType string
+		body        []byte
+		chunked     bool
+		maxBytes    int64
+		wantErr     error // nil when the download is kept
+	}{
+		{name: "audio", kind: audioMedia, contentType: "audio/mpeg", body: audio, maxBytes: DefaultMaxAudioBytes},
+		{name: "image with a generic content type", kind: imageMedia, contentType: "application/octet-stream", body: image, maxBytes: DefaultMaxImageBytes},
+		{name: "exactly the limit", kind: imageMedia, contentType: "image/webp", body: image, maxBytes: int64(len(image))},
+		{name: "exactly the limit, chunked", kind: imageMedia, contentType: "image/webp", body: image, chunked: true, maxBytes: int64(len(image))},
+		{name: "content length over the limit", kind: audioMedia, contentType: "audio/mpeg", body: audio,
+			maxBytes: int64(len(audio)) - 1, wantErr: ErrDownloadTooLarge},
+		{name: "chunked body over the limit", kind: audioMedia, contentType: "audio/mpeg", body: audio, chunked: true,
+			maxBytes: int64(len(audio)) - 1, wantErr: ErrDownloadTooLarge},
+		{name: "html page as audio", kind: audioMedia, contentType: "text/html; charset=utf-8", body: htmlPage,
+			maxBytes: DefaultMaxAudioBytes, wantErr: ErrUnexpectedContent},
+		{name: "audio as image", kind: imageMedia, contentType: "image/png", body: audio,
+			maxBytes: DefaultMaxImageBytes, wantErr: ErrUnexpectedContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMediaServer(t, tt.contentType, tt.body, tt.chunked)
+			dest := filepath.Join(t.TempDir(), "media")
+
+			err := downloadFile(context.Background(), server.URL, dest, tt.kind, tt.maxBytes)
+			if server.accept != tt.kind.accept {
+				t.Errorf("Accept = %q, want %q", server.accept, tt.kind.accept)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("downloadFile() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("downloadFile() error = %v", err)
+			}
+			if got, _ := os.ReadFile(dest); !bytes.Equal(got, tt.body) {
+				t.Errorf("downloaded %d bytes, want the %d served", len(got), len(tt.body))
+			}
+		})
+	}
+}
+
+// TestDownloadUnexpectedContent checks that a page served with status 200 in
+// place of a file is reported with its content type and first bytes.
+func TestDownloadUnexpectedContent(t *testing.T) {
+	server := newMediaServer(t, "text/html; charset=utf-8", htmlPage, false)
+	dest := filepath.Join(t.TempDir(), "image.png")
+
+	err := downloadOnce(context.Background(), server.URL, dest, imageMedia, DefaultMaxImageBytes)
+
+	var unexpected *UnexpectedContentError
+	if !errors.As(err, &unexpected) {
+		t.Fatalf("downloadOnce() error = %v, want an UnexpectedContentError", err)
+	}
+	want := UnexpectedContentError{
+		Kind:        "image",
+		ContentType: "text/html; charset=utf-8",
+		Head:        hex.EncodeToString(htmlPage[:sniffLength]),
+	}
+	if *unexpected != want {
+		t.Errorf("error = %+v, want %+v", *unexpected, want)
+	}
+	for _, path := range []string{dest, dest + ".partial"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("rejected download left %s behind", filepath.Base(path))
+		}
+	}
+}
+
+// TestDownloadOnceReusesFile checks that a download is renamed into place and
+// not fetched again by a later render.
+func TestDownloadOnceReusesFile(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(oggHeader)
+	}))
+	t.Cleanup(server.Close)
+	dest := filepath.Join(t.TempDir(), "audio.mp3")
+
+	for i := 0; i < 2; i++ {
+		if err := downloadOnce(context.Background(), server.URL, dest, audioMedia, DefaultMaxAudioBytes); err != nil {
+			t.Fatalf("downloadOnce() error = %v", err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("downloaded %d times, want once", requests)
+	}
+	if _, err := os.Stat(dest + ".partial"); !os.IsNotExist(err) {
+		t.Error("partial file left behind after the download completed")
+	}
+}
@@ -0,0 +1,136 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Encoding preset names.
+const (
+	PresetStandard = "standard"
+	PresetHigh     = "high"
+	PresetSmall    = "small"
+	PresetH265     = "h265"
+)
+
+// DefaultPreset is used when neither the job nor the server configuration picks one.
+const DefaultPreset = PresetStandard
+
+// Preset is a set of output encoding settings.
+type Preset struct {
+	Name         string
+	VideoCodec   string // FFmpeg encoder, e.g. libx264
+	CRF          int    // Constant rate factor; lower is higher quality
+	MaxBitrate   string // Caps the video bitrate when set, e.g. "2M"
+	AudioBitrate string // AAC bitrate, e.g. "192k"
+	PixelFormat  string
+	Tune         string // Encoder tuning; libx264 has a still image tune
+	Tag          string // Codec tag; hvc1 lets Apple players open H.265
+}
+
+// presets holds the supported presets. A static image compresses well, so the
+// CRF values sit above the usual film defaults without visible loss.
+var presets = map[string]Preset{
+	PresetStandard: {
+		Name: PresetStandard, VideoCodec: "libx264", CRF: 23, AudioBitrate: "192k",
+		PixelFormat: "yuv420p", Tune: "stillimage",
+	},
+	PresetHigh: {
+		Name: PresetHigh, VideoCodec: "libx264", CRF: 18, AudioBitrate: "320k",
+		PixelFormat: "yuv420p", Tune: "stillimage",
+	},
+	PresetSmall: {
+		Name: PresetSmall, VideoCodec: "libx264", CRF: 28, MaxBitrate: "1M", AudioBitrate: "128k",
+		PixelFormat: "yuv420p", Tune: "stillimage",
+	},
+	PresetH265: {
+		Name: PresetH265, VideoCodec: "libx265", CRF: 26, AudioBitrate: "192k",
+		PixelFormat: "yuv420p", Tag: "hvc1",
+	},
+}
+
+// Presets lists the preset names.
+var Presets = []string{PresetStandard, PresetHigh, PresetSmall, PresetH265}
+
+// IsValidPreset reports whether name is a known preset. Whether the worker's
+// FFmpeg can encode it is checked with Processor.SupportsPreset.
+func IsValidPreset(name string) bool {
+	_, ok := presets[name]
+	return ok
+}
+
+// PresetByName returns the named preset, or DefaultPreset for unknown names.
+func PresetByName(name string) Preset {
+	if preset, ok := presets[name]; ok {
+		return preset
+	}
+	return presets[DefaultPreset]
+}
+
+// DetectEncoders runs "ffmpeg -encoders" and caches the available encoders, so
+// presets needing an optional encoder (libx265) are only used where it exists.
+// Call it once at startup; until then only libx264 is assumed.
+func (p *Processor) DetectEncoders(ctx context.Context) error {
+	output, err := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", "-encoders").Output()
+	if err != nil {
+		return fmt.Errorf("ffmpeg -encoders failed: %w", err)
+	}
+
+	encoders := parseEncoders(string(output))
+	p.encodersMu.Lock()
+	p.encoders = encoders
+	p.encodersMu.Unlock()
+
+	p.logger.Info("detected ffmpeg encoders",
+		zap.Bool("libx264", encoders["libx264"]),
+		zap.Bool("libx265", encoders["libx265"]),
+	)
+	return nil
+}
+
+// parseEncoders returns the encoder names listed by "ffmpeg -encoders". Each
+// encoder line is a flags column (e.g. "V....D") followed by the name.
+func parseEncoders(output string) map[string]bool {
+	encoders := make(map[string]bool)
+	listing := false
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if !listing {
+			// The listing starts after the " ------" separator under the legend
+			listing = len(fields) == 1 && strings.Trim(fields[0], "-") == ""
+			continue
+		}
+		if len(fields) >= 2 {
+			encoders[fields[1]] = true
+		}
+	}
+	return encoders
+}
+
+// SupportsPreset reports whether the detected FFmpeg can encode the preset.
+// Before DetectEncoders runs only libx264 presets are supported.
+func (p *Processor) SupportsPreset(name string) bool {
+	codec := PresetByName(name).VideoCodec
+	p.encodersMu.RLock()
+	defer p.encodersMu.RUnlock()
+	if p.encoders == nil {
+		return codec == "libx264"
+	}
+	return p.encoders[codec]
+}
+
+// ResolvePreset returns the preset to encode with: the requested one, else
+// fallback, else DefaultPreset, skipping unknown names and presets the detected
+// FFmpeg cannot encode.
+func (p *Processor) ResolvePreset(requested, fallback string) Preset {
+	for _, name := range []string{requested, fallback} {
+		if IsValidPreset(name) && p.SupportsPreset(name) {
+			return presets[name]
+		}
+	}
+	return presets[DefaultPreset]
+}
@@ -0,0 +1,138 @@
+package ffmpeg
+
+import (
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestBuildMusicVideoArgsPresets(t *testing.T) {
+	// The arguments before and after the encoder settings are the same for every preset
+	head := []string{"-loop", "1", "-i", "image.png", "-i", "audio.mp3", "-vf", videoFilter}
+	tail := []string{"-pix_fmt", "yuv420p", "-shortest", "-y", "out.mp4"}
+
+	tests := []struct {
+		preset string
+		want   []string // Between head and tail
+	}{
+		{
+			preset: PresetStandard,
+			want:   []string{"-c:v", "libx264", "-crf", "23", "-tune", "stillimage", "-c:a", "aac", "-b:a", "192k"},
+		},
+		{
+			preset: PresetHigh,
+			want:   []string{"-c:v", "libx264", "-crf", "18", "-tune", "stillimage", "-c:a", "aac", "-b:a", "320k"},
+		},
+		{
+			preset: PresetSmall,
+			want: []string{"-c:v", "libx264", "-crf", "28", "-tune", "stillimage", "-maxrate", "1M", "-bufsize", "2M",
+				"-c:a", "aac", "-b:a", "128k"},
+		},
+		{
+			preset: PresetH265,
+			want:   []string{"-c:v", "libx265", "-crf", "26", "-tag:v", "hvc1", "-c:a", "aac", "-b:a", "192k"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			got := buildMusicVideoArgs(musicVideoArgs{
+				ImagePath:  "image.png",
+				AudioPath:  "audio.mp3",
+				OutputPath: "out.mp4",
+				Preset:     PresetByName(tt.preset),
+			})
+			want := slices.Concat(head, tt.want, tail)
+			if !slices.Equal(got, want) {
+				t.Errorf("args =\n%v\nwant\n%v", got, want)
+			}
+		})
+	}
+}
+
+func TestPresetByName(t *testing.T) {
+	for _, name := range Presets {
+		if !IsValidPreset(name) || PresetByName(name).Name != name {
+			t.Errorf("preset %q is listed but not defined", name)
+		}
+	}
+	for _, name := range []string{"", "ultra", "H265"} {
+		if IsValidPreset(name) {
+			t.Errorf("IsValidPreset(%q) = true, want false", name)
+		}
+		if got := PresetByName(name).Name; got != DefaultPreset {
+			t.Errorf("PresetByName(%q) = %s, want the default %s", name, got, DefaultPreset)
+		}
+	}
+}
+
+func TestDoubleBitrate(t *testing.T) {
+	tests := map[string]string{
+		"1M":    "2M",
+		"800k":  "1600k",
+		"1500K": "3000K",
+		"2000":  "4000",
+		"1.5M":  "1.5M", // Not an integer: unchanged
+		"fast":  "fast",
+		"":      "",
+	}
+	for bitrate, want := range tests {
+		if got := doubleBitrate(bitrate); got != want {
+			t.Errorf("doubleBitrate(%q) = %q, want %q", bitrate, got, want)
+		}
+	}
+}
+
+func TestParseEncoders(t *testing.T) {
+	const output = `Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)
+ V....D libx265              libx265 H.265 / HEVC (codec hevc)
+ A....D aac                  AAC (Advanced Audio Coding)
+`
+	encoders := parseEncoders(output)
+	for _, name := range []string{"libx264", "libx265", "aac"} {
+		if !encoders[name] {
+			t.Errorf("encoder %s not found", name)
+		}
+	}
+	// The legend above the separator is not an encoder list
+	if encoders["="] || len(encoders) != 3 {
+		t.Errorf("encoders = %v, want only the listed three", encoders)
+	}
+}
+
+func TestResolvePreset(t *testing.T) {
+	withX265 := map[string]bool{"libx264": true, "libx265": true}
+	withoutX265 := map[string]bool{"libx264": true}
+
+	tests := []struct {
+		name      string
+		encoders  map[string]bool // nil before DetectEncoders
+		requested string
+		fallback  string
+		want      string
+	}{
+		{name: "requested", encoders: withoutX265, requested: PresetHigh, fallback: PresetSmall, want: PresetHigh},
+		{name: "unknown request uses the fallback", encoders: withoutX265, requested: "ultra", fallback: PresetSmall, want: PresetSmall},
+		{name: "nothing requested uses the fallback", encoders: withoutX265, fallback: PresetSmall, want: PresetSmall},
+		{name: "nothing valid uses the default", encoders: withoutX265, requested: "ultra", fallback: "tiny", want: DefaultPreset},
+		{name: "h265 with libx265", encoders: withX265, requested: PresetH265, fallback: PresetSmall, want: PresetH265},
+		{name: "h265 without libx265", encoders: withoutX265, requested: PresetH265, fallback: PresetSmall, want: PresetSmall},
+		{name: "h265 fallback without libx265", encoders: withoutX265, requested: PresetH265, fallback: PresetH265, want: DefaultPreset},
+		{name: "h265 before detection", requested: PresetH265, fallback: PresetHigh, want: PresetHigh},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProcessor(1, zap.NewNop())
+			p.encoders = tt.encoders
+			if got := p.ResolvePreset(tt.requested, tt.fallback).Name; got != tt.want {
+				t.Errorf("ResolvePreset(%q, %q) = %s, want %s", tt.requested, tt.fallback, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,67 +3,166 @@ package ffmpeg
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/pkg/logsanitize"
 )
 
 // Processor handles video processing operations using FFmpeg.
 type Processor struct {
 	logger *zap.Logger
+	// encodeSlots limits how many FFmpeg encodes run at once.
+	encodeSlots chan struct{}
+
+	// encoders caches the encoders found by DetectEncoders; nil until it runs.
+	encodersMu sync.RWMutex
+	encoders   map[string]bool
 }
 
-// NewProcessor creates a new FFmpeg processor.
-func NewProcessor(logger *zap.Logger) *Processor {
+// NewProcessor creates a new FFmpeg processor that runs at most maxConcurrent
+// encodes at once. A non-positive maxConcurrent allows a single encode.
+func NewProcessor(maxConcurrent int, logger *zap.Logger) *Processor {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
 	return &Processor{
-		logger: logger,
+		logger:      logger,
+		encodeSlots: make(chan struct{}, maxConcurrent),
 	}
 }
 
+// acquireEncodeSlot blocks until an encode slot is free or ctx is done.
+// The returned function releases the slot.
+func (p *Processor) acquireEncodeSlot(ctx context.Context) (func(), error) {
+	select {
+	case p.encodeSlots <- struct{}{}:
+		return func() { <-p.encodeSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Audio filter settings. Streaming platforms normalize to about -14 LUFS, so
+// tracks encoded at that loudness are played back unchanged.
+const (
+	loudnormFilter = "loudnorm=I=-14:TP=-1.5:LRA=11"
+	// loudnorm resamples to 192kHz internally; AAC is encoded at 48kHz.
+	normalizedSampleRate = "48000"
+)
+
+// videoFilter forces 16:9 output (1920x1080): scale to cover the full frame, then
+// crop the center. This avoids black bars when the input image has a different
+// aspect ratio (e.g. 9:16 from NanoBanana).
+const videoFilter = "scale=1920:1080:force_original_aspect_ratio=increase,crop=1920:1080"
+
 // CreateMusicVideoInput contains the input parameters for creating a music video.
 type CreateMusicVideoInput struct {
 	AudioURL   string // URL of the audio file
 	ImageURL   string // URL of the background image
 	OutputPath string // Path where the output video will be saved
+
+	// NormalizeLoudness applies EBU R128 loudness normalization (-14 LUFS) to the audio.
+	NormalizeLoudness bool
+	// FadeOut fades the audio out and the video to black over the end of the track.
+	// Zero disables the fade.
+	FadeOut time.Duration
+	// Preset sets the codec, quality and audio bitrate; resolve it with
+	// Processor.ResolvePreset. A zero Preset uses DefaultPreset.
+	Preset Preset
+	// MaxDuration cuts a longer track at MaxDuration, fading out over at least
+	// minTrimFade before the cut. Zero keeps the whole track.
+	MaxDuration time.Duration
+
+	// WorkDir keeps the downloads and intermediate files of the render instead of a
+	// random temp directory, so a retried render can resume; the caller removes it.
+	// Empty uses a temp directory removed on return.
+	WorkDir string
+	// ResumeKey identifies the render's inputs and settings. Files left in WorkDir
+	// by a render with another key are discarded.
+	ResumeKey string
+	// SegmentThreshold renders videos longer than it in SegmentLength pieces kept in
+	// WorkDir (see renderSegmented). Zero, or an empty WorkDir, renders in one pass.
+	SegmentThreshold time.Duration
+	SegmentLength    time.Duration
+
+	// MaxAudioBytes and MaxImageBytes cap the downloads; zero uses
+	// DefaultMaxAudioBytes and DefaultMaxImageBytes.
+	MaxAudioBytes int64
+	MaxImageBytes int64
 }
 
+// minTrimFade is the shortest fade-out before a trimmed track's cut, so the music
+// never stops abruptly even when the fade-out is disabled.
+const minTrimFade = 3 * time.Second
+
 // CreateMusicVideoOutput contains the result of creating a music video.
 type CreateMusicVideoOutput struct {
 	OutputPath string        // Path to the generated video
 	Duration   time.Duration // Duration of the video
 	FileSize   int64         // Size of the video file in bytes
+	Preset     string        // Name of the preset encoded with
+	VideoCodec string        // Codec of the video stream as reported by ffprobe, e.g. h264
+	Bitrate    int64         // Overall bitrate in bits per second; 0 when ffprobe cannot tell
+	// SourceDuration is the length of the downloaded track; 0 when ffprobe cannot tell.
+	SourceDuration time.Duration
+	Trimmed        bool // The track was cut at MaxDuration
+	// Segments is how many pieces the video was rendered in; 0 for a single pass.
+	Segments int
 }
 
 // CreateMusicVideo creates a music video by combining an audio file with a static image.
 // It downloads the audio and image from URLs, then uses FFmpeg to create the video.
 func (p *Processor) CreateMusicVideo(ctx context.Context, input CreateMusicVideoInput) (*CreateMusicVideoOutput, error) {
 	p.logger.Info("starting music video creation",
-		zap.String("audio_url", input.AudioURL),
-		zap.String("image_url", input.ImageURL),
+		// Asset URLs are presigned; log only their hosts
+		zap.String("audio_host", logsanitize.URLHost(input.AudioURL)),
+		zap.String("image_host", logsanitize.URLHost(input.ImageURL)),
 		zap.String("output_path", input.OutputPath),
 	)
 
-	// Create temp directory for intermediate files
-	tempDir, err := os.MkdirTemp("", "ugc-video-*")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	// Create the directory for intermediate files; a work directory outlives the call
+	tempDir := input.WorkDir
+	if tempDir != "" {
+		if err := prepareWorkDir(tempDir, input.ResumeKey); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		tempDir, err = os.MkdirTemp("", "ugc-video-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
+	}
+
+	maxAudioBytes, maxImageBytes := input.MaxAudioBytes, input.MaxImageBytes
+	if maxAudioBytes <= 0 {
+		maxAudioBytes = DefaultMaxAudioBytes
+	}
+	if maxImageBytes <= 0 {
+		maxImageBytes = DefaultMaxImageBytes
 	}
-	defer os.RemoveAll(tempDir)
 
 	// Download audio file
 	audioPath := filepath.Join(tempDir, "audio.mp3")
-	if err := downloadFile(ctx, input.AudioURL, audioPath); err != nil {
+	if err := downloadOnce(ctx, input.AudioURL, audioPath, audioMedia, maxAudioBytes); err != nil {
 		return nil, fmt.Errorf("failed to download audio: %w", err)
 	}
 	p.logger.Debug("downloaded audio file", zap.String("path", audioPath))
 
 	// Download image file
 	imagePath := filepath.Join(tempDir, "image.png")
-	if err := downloadFile(ctx, input.ImageURL, imagePath); err != nil {
+	if err := downloadOnce(ctx, input.ImageURL, imagePath, imageMedia, maxImageBytes); err != nil {
 		return nil, fmt.Errorf("failed to download image: %w", err)
 	}
 	p.logger.Debug("downloaded image file", zap.String("path", imagePath))
@@ -74,34 +173,76 @@ func (p *Processor) CreateMusicVideo(ctx context.Context, input CreateMusicVideo
 		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// Create video using FFmpeg
-	// Force 16:9 output (1920x1080) — scale to cover full frame then crop center
-	// This avoids black bars when input image has different aspect ratio (e.g. 9:16 from NanoBanana)
-	args := []string{
-		"-loop", "1",
-		"-i", imagePath,
-		"-i", audioPath,
-		"-vf", "scale=1920:1080:force_original_aspect_ratio=increase,crop=1920:1080",
-		"-c:v", "libx264",
-		"-tune", "stillimage",
-		"-c:a", "aac",
-		"-b:a", "192k",
-		"-pix_fmt", "yuv420p",
-		"-shortest",
-		"-y", // Overwrite output file if exists
-		input.OutputPath,
+	// The fade starts relative to the end of the track, and segmenting depends on
+	// it too, so its length is needed up front
+	segmentable := input.WorkDir != "" && input.SegmentThreshold > 0 && input.SegmentLength > 0
+	var fadeStart, fadeOut, audioDuration, cut time.Duration
+	var err error
+	if input.FadeOut > 0 || input.MaxDuration > 0 || segmentable {
+		audioDuration, err = p.getMediaDuration(ctx, audioPath)
+		if err != nil {
+			p.logger.Warn("failed to get audio duration, skipping fade-out", zap.Error(err))
+			// Cutting at the cap is still safe; a shorter track just ends first
+			cut = input.MaxDuration
+		} else {
+			length, fade := audioDuration, input.FadeOut
+			if input.MaxDuration > 0 && audioDuration > input.MaxDuration {
+				length, cut = input.MaxDuration, input.MaxDuration
+				fade = max(fade, minTrimFade)
+			}
+			if fade > 0 {
+				fadeStart, fadeOut = fadeWindow(length, fade)
+			}
+		}
 	}
 
-	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
-	cmd.Stdout = nil
-	cmd.Stderr = nil
+	// Create video using FFmpeg
+	preset := input.Preset
+	if preset.Name == "" {
+		preset = PresetByName(DefaultPreset)
+	}
+	args := buildMusicVideoArgs(musicVideoArgs{
+		ImagePath:  imagePath,
+		AudioPath:  audioPath,
+		OutputPath: input.OutputPath,
+		Normalize:  input.NormalizeLoudness,
+		FadeStart:  fadeStart,
+		FadeOut:    fadeOut,
+		Duration:   cut,
+		Preset:     preset,
+	})
 
-	p.logger.Debug("executing ffmpeg command",
-		zap.Strings("args", args),
-	)
+	// Wait for a free encode slot so a burst of tasks cannot exhaust CPU/memory
+	release, err := p.acquireEncodeSlot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire ffmpeg slot: %w", err)
+	}
+	defer release()
 
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("ffmpeg command failed: %w", err)
+	// Long videos are rendered in resumable segments
+	length := audioDuration
+	if cut > 0 && cut < length {
+		length = cut
+	}
+	segments := 0
+	if segmentable && length > input.SegmentThreshold {
+		segments, err = p.renderSegmented(ctx, segmentedRender{
+			WorkDir:       tempDir,
+			ImagePath:     imagePath,
+			AudioPath:     audioPath,
+			OutputPath:    input.OutputPath,
+			Length:        length,
+			SegmentLength: input.SegmentLength,
+			Normalize:     input.NormalizeLoudness,
+			FadeStart:     fadeStart,
+			FadeOut:       fadeOut,
+			Preset:        preset,
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else if err := p.runFFmpeg(ctx, args); err != nil {
+		return nil, err
 	}
 
 	// Get output file info
@@ -110,33 +251,247 @@ func (p *Processor) CreateMusicVideo(ctx context.Context, input CreateMusicVideo
 		return nil, fmt.Errorf("failed to stat output file: %w", err)
 	}
 
-	// Get video duration using ffprobe
-	duration, err := p.getVideoDuration(ctx, input.OutputPath)
+	// Get video duration, codec and bitrate using ffprobe
+	info, err := p.probeVideo(ctx, input.OutputPath)
 	if err != nil {
-		p.logger.Warn("failed to get video duration, using 0", zap.Error(err))
-		duration = 0
+		p.logger.Warn("failed to probe video, using empty metadata", zap.Error(err))
+		info = &videoInfo{}
 	}
 
 	p.logger.Info("music video created successfully",
 		zap.String("output_path", input.OutputPath),
 		zap.Int64("file_size", fileInfo.Size()),
-		zap.Duration("duration", duration),
+		zap.Duration("duration", info.Duration),
+		zap.String("preset", preset.Name),
+		zap.String("video_codec", info.VideoCodec),
 	)
 
 	return &CreateMusicVideoOutput{
 		OutputPath: input.OutputPath,
-		Duration:   duration,
+		Duration:   info.Duration,
 		FileSize:   fileInfo.Size(),
+		Preset:     preset.Name,
+		VideoCodec: info.VideoCodec,
+		Bitrate:    info.Bitrate,
+
+		SourceDuration: audioDuration,
+		Trimmed:        cut > 0 && audioDuration > cut,
+		Segments:       segments,
 	}, nil
 }
 
-// getVideoDuration uses ffprobe to get the duration of a video file.
-func (p *Processor) getVideoDuration(ctx context.Context, videoPath string) (time.Duration, error) {
+// runFFmpeg runs ffmpeg with args, discarding its output.
+func (p *Processor) runFFmpeg(ctx context.Context, args []string) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+
+	p.logger.Debug("executing ffmpeg command",
+		zap.Strings("args", args),
+	)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg command failed: %w", err)
+	}
+	return nil
+}
+
+// thumbnailWidth is the width of video thumbnails; the height keeps the aspect ratio.
+const thumbnailWidth = 640
+
+// ExtractThumbnail writes a JPEG of the frame one second into the video at
+// videoPath to outputPath, scaled to thumbnailWidth pixels wide.
+func (p *Processor) ExtractThumbnail(ctx context.Context, videoPath, outputPath string) error {
+	args := []string{
+		"-ss", "1",
+		"-i", videoPath,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-2", thumbnailWidth),
+		"-q:v", "3",
+		"-y", // Overwrite output file if exists
+		outputPath,
+	}
+
+	release, err := p.acquireEncodeSlot(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire ffmpeg slot: %w", err)
+	}
+	defer release()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg thumbnail command failed: %w", err)
+	}
+
+	fileInfo, err := os.Stat(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat thumbnail: %w", err)
+	}
+	if fileInfo.Size() == 0 {
+		return fmt.Errorf("thumbnail is empty")
+	}
+	return nil
+}
+
+// fadeWindow returns when a fade of length fade starts in a track of length
+// duration. A track shorter than the fade fades over its whole length.
+func fadeWindow(duration, fade time.Duration) (start, length time.Duration) {
+	if duration <= 0 {
+		return 0, 0
+	}
+	if fade > duration {
+		return 0, duration
+	}
+	return duration - fade, fade
+}
+
+// musicVideoArgs are the inputs of buildMusicVideoArgs.
+type musicVideoArgs struct {
+	ImagePath  string
+	AudioPath  string
+	OutputPath string
+	Normalize  bool
+	FadeStart  time.Duration
+	FadeOut    time.Duration
+	Duration   time.Duration // Cuts the output; zero keeps the whole track
+	Preset     Preset
+}
+
+// buildMusicVideoArgs returns the FFmpeg arguments that loop the image over the
+// audio track, encoded with the preset. A positive FadeOut adds an audio fade-out
+// and a video fade to black starting at FadeStart. -shortest ends the looped
+// image with the audio, and a positive Duration cuts both there.
+func buildMusicVideoArgs(a musicVideoArgs) []string {
+	vf := videoFilter
+	var af []string
+	if a.Normalize {
+		af = append(af, loudnormFilter)
+	}
+	if a.FadeOut > 0 {
+		start := formatSeconds(a.FadeStart)
+		length := formatSeconds(a.FadeOut)
+		vf += ",fade=t=out:st=" + start + ":d=" + length
+		af = append(af, "afade=t=out:st="+start+":d="+length)
+	}
+
+	args := []string{
+		"-loop", "1",
+		"-i", a.ImagePath,
+		"-i", a.AudioPath,
+		"-vf", vf,
+	}
+	if len(af) > 0 {
+		args = append(args, "-af", strings.Join(af, ","))
+	}
+	args = append(args, videoCodecArgs(a.Preset)...)
+	args = append(args,
+		"-c:a", "aac",
+		"-b:a", a.Preset.AudioBitrate,
+	)
+	if a.Normalize {
+		args = append(args, "-ar", normalizedSampleRate)
+	}
+	if a.Duration > 0 {
+		args = append(args, "-t", formatSeconds(a.Duration))
+	}
+	return append(args,
+		"-pix_fmt", a.Preset.PixelFormat,
+		"-shortest",
+		"-y", // Overwrite output file if exists
+		a.OutputPath,
+	)
+}
+
+// videoCodecArgs returns the FFmpeg arguments encoding the video stream with the preset.
+func videoCodecArgs(preset Preset) []string {
+	args := []string{
+		"-c:v", preset.VideoCodec,
+		"-crf", strconv.Itoa(preset.CRF),
+	}
+	if preset.Tune != "" {
+		args = append(args, "-tune", preset.Tune)
+	}
+	if preset.MaxBitrate != "" {
+		// The VBV buffer of twice the cap lets a still image burst on its first frame
+		args = append(args, "-maxrate", preset.MaxBitrate, "-bufsize", doubleBitrate(preset.MaxBitrate))
+	}
+	if preset.Tag != "" {
+		args = append(args, "-tag:v", preset.Tag)
+	}
+	return args
+}
+
+// doubleBitrate doubles an FFmpeg bitrate such as "1M" or "800k". Values it
+// cannot parse are returned unchanged.
+func doubleBitrate(bitrate string) string {
+	number := strings.TrimRight(bitrate, "kKmM")
+	value, err := strconv.Atoi(number)
+	if err != nil {
+		return bitrate
+	}
+	return strconv.Itoa(value*2) + bitrate[len(number):]
+}
+
+// formatSeconds formats d as seconds with millisecond precision for FFmpeg filters.
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// videoInfo is what ffprobe reports about a rendered video.
+type videoInfo struct {
+	Duration   time.Duration
+	VideoCodec string
+	Bitrate    int64
+}
+
+// probeVideo uses ffprobe to get the duration, overall bitrate and video codec of a video.
+func (p *Processor) probeVideo(ctx context.Context, path string) (*videoInfo, error) {
+	args := []string{
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "format=duration,bit_rate:stream=codec_name",
+		"-of", "json",
+		path,
+	}
+
+	output, err := exec.CommandContext(ctx, "ffprobe", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe command failed: %w", err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			CodecName string `json:"codec_name"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+			BitRate  string `json:"bit_rate"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	info := &videoInfo{}
+	if seconds, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		info.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	if bitrate, err := strconv.ParseInt(probe.Format.BitRate, 10, 64); err == nil {
+		info.Bitrate = bitrate
+	}
+	if len(probe.Streams) > 0 {
+		info.VideoCodec = probe.Streams[0].CodecName
+	}
+	return info, nil
+}
+
+// getMediaDuration uses ffprobe to get the duration of an audio or video file.
+func (p *Processor) getMediaDuration(ctx context.Context, path string) (time.Duration, error) {
 	args := []string{
 		"-v", "error",
 		"-show_entries", "format=duration",
 		"-of", "default=noprint_wrappers=1:nokey=1",
-		videoPath,
+		path,
 	}
 
 	cmd := exec.CommandContext(ctx, "ffprobe", args...)
@@ -152,23 +507,3 @@ func (p *Processor) getVideoDuration(ctx context.Context, videoPath string) (tim
 
 	return time.Duration(seconds * float64(time.Second)), nil
 }
-
-// downloadFile downloads a file from a URL to a local path.
-func downloadFile(ctx context.Context, url, destPath string) error {
-	// Use curl for downloading as it handles various edge cases well
-	cmd := exec.CommandContext(ctx, "curl", "-L", "-o", destPath, "-s", "-f", url)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("curl download failed: %w", err)
-	}
-
-	// Verify file exists and has content
-	fileInfo, err := os.Stat(destPath)
-	if err != nil {
-		return fmt.Errorf("downloaded file not found: %w", err)
-	}
-	if fileInfo.Size() == 0 {
-		return fmt.Errorf("downloaded file is empty")
-	}
-
-	return nil
-}
@@ -0,0 +1,367 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// blockedFor is how long an acquire must stay blocked to count as blocked.
+const blockedFor = 50 * time.Millisecond
+
+func TestAcquireEncodeSlotBlocksWhenFull(t *testing.T) {
+	const slots = 3
+	p := NewProcessor(slots, zap.NewNop())
+	ctx := context.Background()
+
+	releases := make([]func(), 0, slots)
+	for i := 0; i < slots; i++ {
+		release, err := p.acquireEncodeSlot(ctx)
+		if err != nil {
+			t.Fatalf("acquire %d of %d error = %v", i+1, slots, err)
+		}
+		releases = append(releases, release)
+	}
+
+	acquired := make(chan func(), 1)
+	go func() {
+		release, err := p.acquireEncodeSlot(ctx)
+		if err != nil {
+			t.Errorf("blocked acquire error = %v", err)
+			return
+		}
+		acquired <- release
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("acquire %d succeeded with %d slots taken", slots+1, slots)
+	case <-time.After(blockedFor):
+	}
+
+	releases[0]()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(5 * time.Second):
+		t.Fatal("acquire still blocked after a release")
+	}
+
+	for _, release := range releases[1:] {
+		release()
+	}
+	if n := len(p.encodeSlots); n != 0 {
+		t.Errorf("%d slots held after every release, want 0", n)
+	}
+}
+
+func TestAcquireEncodeSlotHonorsContext(t *testing.T) {
+	p := NewProcessor(1, zap.NewNop())
+	release, err := p.acquireEncodeSlot(context.Background())
+	if err != nil {
+		t.Fatalf("acquire error = %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), blockedFor)
+	defer cancel()
+	if _, err := p.acquireEncodeSlot(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("acquire with every slot taken error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestNewProcessorAllowsOneEncodeAtLeast(t *testing.T) {
+	for _, maxConcurrent := range []int{0, -1} {
+		if got := cap(NewProcessor(maxConcurrent, zap.NewNop()).encodeSlots); got != 1 {
+			t.Errorf("NewProcessor(%d) allows %d encodes, want 1", maxConcurrent, got)
+		}
+	}
+}
+
+// argValue returns the argument following the first occurrence of flag in args,
+// or "" when flag is absent.
+func argValue(args []string, flag string) string {
+	for i, arg := range args[:len(args)-1] {
+		if arg == flag {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func TestBuildMusicVideoArgsNormalizeAndFade(t *testing.T) {
+	tests := []struct {
+		name      string
+		normalize bool
+		fadeStart time.Duration
+		fadeOut   time.Duration
+		wantVF    string
+		wantAF    string // Empty when no audio filter is expected
+		wantRate  string // Value of -ar, empty when absent
+	}{
+		{
+			name:   "neither",
+			wantVF: videoFilter,
+		},
+		{
+			name:      "normalize",
+			normalize: true,
+			wantVF:    videoFilter,
+			wantAF:    "loudnorm=I=-14:TP=-1.5:LRA=11",
+			wantRate:  "48000",
+		},
+		{
+			name:      "fade out",
+			fadeStart: 122 * time.Second,
+			fadeOut:   3 * time.Second,
+			wantVF:    videoFilter + ",fade=t=out:st=122.000:d=3.000",
+			wantAF:    "afade=t=out:st=122.000:d=3.000",
+		},
+		{
+			name:      "normalize before the fade",
+			normalize: true,
+			fadeStart: 1500 * time.Millisecond,
+			fadeOut:   2500 * time.Millisecond,
+			wantVF:    videoFilter + ",fade=t=out:st=1.500:d=2.500",
+			wantAF:    "loudnorm=I=-14:TP=-1.5:LRA=11,afade=t=out:st=1.500:d=2.500",
+			wantRate:  "48000",
+		},
+		{
+			name:      "fade start without a length",
+			fadeStart: 122 * time.Second,
+			wantVF:    videoFilter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := buildMusicVideoArgs(musicVideoArgs{
+				ImagePath:  "image.png",
+				AudioPath:  "audio.mp3",
+				OutputPath: "out.mp4",
+				Normalize:  tt.normalize,
+				FadeStart:  tt.fadeStart,
+				FadeOut:    tt.fadeOut,
+				Preset:     PresetByName(DefaultPreset),
+			})
+
+			if got := argValue(args, "-vf"); got != tt.wantVF {
+				t.Errorf("-vf = %q, want %q", got, tt.wantVF)
+			}
+			if got := argValue(args, "-af"); got != tt.wantAF {
+				t.Errorf("-af = %q, want %q", got, tt.wantAF)
+			}
+			if got := argValue(args, "-ar"); got != tt.wantRate {
+				t.Errorf("-ar = %q, want %q", got, tt.wantRate)
+			}
+
+			// The looped image still ends with the audio, and the output comes last
+			tail := strings.Join(args[len(args)-3:], " ")
+			if tail != "-shortest -y out.mp4" {
+				t.Errorf("args end with %q, want -shortest -y out.mp4; args: %v", tail, args)
+			}
+			if got := argValue(args, "-t"); got != "" {
+				t.Errorf("-t = %q without a duration cap, want none", got)
+			}
+			if got := strings.Join(args[:6], " "); got != "-loop 1 -i image.png -i audio.mp3" {
+				t.Errorf("inputs = %q, want the looped image then the audio", got)
+			}
+		})
+	}
+}
+
+func TestFadeWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		duration   time.Duration
+		fade       time.Duration
+		wantStart  time.Duration
+		wantLength time.Duration
+	}{
+		{name: "over the end", duration: 125 * time.Second, fade: 3 * time.Second, wantStart: 122 * time.Second, wantLength: 3 * time.Second},
+		{name: "as long as the track", duration: 3 * time.Second, fade: 3 * time.Second, wantStart: 0, wantLength: 3 * time.Second},
+		{name: "track shorter than the fade", duration: 2 * time.Second, fade: 3 * time.Second, wantStart: 0, wantLength: 2 * time.Second},
+		{name: "unknown duration", duration: 0, fade: 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, length := fadeWindow(tt.duration, tt.fade)
+			if start != tt.wantStart || length != tt.wantLength {
+				t.Errorf("fadeWindow(%v, %v) = %v, %v; want %v, %v", tt.duration, tt.fade, start, length, tt.wantStart, tt.wantLength)
+			}
+		})
+	}
+}
+
+// TestCreateMusicVideoFadesFromProbedDuration checks that the track is probed
+// before encoding, so the fade-out ends with it, and that a failed probe renders
+// without a fade rather than failing.
+func TestCreateMusicVideoFadesFromProbedDuration(t *testing.T) {
+	kieServer := testutil.NewFakeKIE(t)
+	input := func() CreateMusicVideoInput {
+		return CreateMusicVideoInput{
+			AudioURL:          kieServer.URL() + "/files/song.mp3",
+			ImageURL:          kieServer.URL() + "/files/cover.png",
+			OutputPath:        filepath.Join(t.TempDir(), "out.mp4"),
+			NormalizeLoudness: true,
+			FadeOut:           3 * time.Second,
+		}
+	}
+
+	fake := testutil.InstallFakeFFmpeg(t, 125*time.Second)
+	p := NewProcessor(1, zap.NewNop())
+	if _, err := p.CreateMusicVideo(context.Background(), input()); err != nil {
+		t.Fatalf("CreateMusicVideo: %v", err)
+	}
+	calls := fake.Calls(t)
+	if len(calls) != 1 {
+		t.Fatalf("ffmpeg ran %d times, want 1: %v", len(calls), calls)
+	}
+	args := strings.Fields(calls[0])
+	if got, want := argValue(args, "-af"), "loudnorm=I=-14:TP=-1.5:LRA=11,afade=t=out:st=122.000:d=3.000"; got != want {
+		t.Errorf("-af = %q, want %q", got, want)
+	}
+	if got := argValue(args, "-vf"); !strings.HasSuffix(got, ",fade=t=out:st=122.000:d=3.000") {
+		t.Errorf("-vf = %q, want a fade to black over the last 3 seconds", got)
+	}
+
+	// ffprobe fails: the video is still rendered, normalized but without a fade
+	fake.Reset(t)
+	if err := os.WriteFile(ffprobePath(t), []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatalf("failed to break the ffprobe stub: %v", err)
+	}
+	if _, err := p.CreateMusicVideo(context.Background(), input()); err != nil {
+		t.Fatalf("CreateMusicVideo with a failing ffprobe: %v", err)
+	}
+	args = strings.Fields(fake.Calls(t)[0])
+	if got := argValue(args, "-af"); got != "loudnorm=I=-14:TP=-1.5:LRA=11" {
+		t.Errorf("-af without a probed duration = %q, want only loudnorm", got)
+	}
+	if args[len(args)-3] != "-shortest" {
+		t.Errorf("args = %v, want -shortest before the output", args)
+	}
+}
+
+// ffprobePath returns the ffprobe first on PATH, the stub of InstallFakeFFmpeg.
+func ffprobePath(t *testing.T) string {
+	t.Helper()
+	path, err := exec.LookPath("ffprobe")
+	if err != nil {
+		t.Fatalf("ffprobe not found: %v", err)
+	}
+	return path
+}
+
+func TestBuildMusicVideoArgsTrim(t *testing.T) {
+	args := buildMusicVideoArgs(musicVideoArgs{
+		ImagePath:  "image.png",
+		AudioPath:  "audio.mp3",
+		OutputPath: "out.mp4",
+		FadeStart:  87 * time.Second,
+		FadeOut:    3 * time.Second,
+		Duration:   90 * time.Second,
+		Preset:     PresetByName(DefaultPreset),
+	})
+
+	if got := argValue(args, "-t"); got != "90.000" {
+		t.Errorf("-t = %q, want 90.000", got)
+	}
+	if got := argValue(args, "-af"); got != "afade=t=out:st=87.000:d=3.000" {
+		t.Errorf("-af = %q, want the fade to end at the cut", got)
+	}
+	// -t is an output option, so it must follow the inputs; -shortest still applies
+	if got := strings.Join(args[len(args)-3:], " "); got != "-shortest -y out.mp4" {
+		t.Errorf("args end with %q, want -shortest -y out.mp4", got)
+	}
+	if slices.Index(args, "-t") < slices.Index(args, "audio.mp3") {
+		t.Errorf("-t precedes the inputs: %v", args)
+	}
+}
+
+// TestCreateMusicVideoTrimsToMaxDuration renders tracks against a duration cap
+// and checks the cut, the fade before it and the reported durations.
+func TestCreateMusicVideoTrimsToMaxDuration(t *testing.T) {
+	kieServer := testutil.NewFakeKIE(t)
+
+	tests := []struct {
+		name        string
+		track       time.Duration // Probed length of the downloaded track
+		maxDuration time.Duration
+		fadeOut     time.Duration
+		wantCut     string // Value of -t, empty when the track is kept whole
+		wantAF      string
+		wantTrimmed bool
+	}{
+		{
+			name:        "longer track is cut with the fade-out",
+			track:       372 * time.Second,
+			maxDuration: 90 * time.Second,
+			fadeOut:     5 * time.Second,
+			wantCut:     "90.000",
+			wantAF:      "afade=t=out:st=85.000:d=5.000",
+			wantTrimmed: true,
+		},
+		{
+			name:        "fade disabled still fades before the cut",
+			track:       372 * time.Second,
+			maxDuration: 90 * time.Second,
+			wantCut:     "90.000",
+			wantAF:      "afade=t=out:st=87.000:d=3.000",
+			wantTrimmed: true,
+		},
+		{
+			name:        "shorter track is kept whole",
+			track:       80 * time.Second,
+			maxDuration: 90 * time.Second,
+			fadeOut:     3 * time.Second,
+			wantAF:      "afade=t=out:st=77.000:d=3.000",
+		},
+		{
+			name:    "no cap",
+			track:   372 * time.Second,
+			fadeOut: 3 * time.Second,
+			wantAF:  "afade=t=out:st=369.000:d=3.000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := testutil.InstallFakeFFmpeg(t, tt.track)
+			p := NewProcessor(1, zap.NewNop())
+
+			output, err := p.CreateMusicVideo(context.Background(), CreateMusicVideoInput{
+				AudioURL:    kieServer.URL() + "/files/song.mp3",
+				ImageURL:    kieServer.URL() + "/files/cover.png",
+				OutputPath:  filepath.Join(t.TempDir(), "out.mp4"),
+				FadeOut:     tt.fadeOut,
+				MaxDuration: tt.maxDuration,
+			})
+			if err != nil {
+				t.Fatalf("CreateMusicVideo: %v", err)
+			}
+
+			args := strings.Fields(fake.Calls(t)[0])
+			if got := argValue(args, "-t"); got != tt.wantCut {
+				t.Errorf("-t = %q, want %q", got, tt.wantCut)
+			}
+			if got := argValue(args, "-af"); got != tt.wantAF {
+				t.Errorf("-af = %q, want %q", got, tt.wantAF)
+			}
+			if output.Trimmed != tt.wantTrimmed || output.SourceDuration != tt.track {
+				t.Errorf("output trimmed = %v, source duration = %v; want %v, %v",
+					output.Trimmed, output.SourceDuration, tt.wantTrimmed, tt.track)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// resumeKeyFile records in a work directory the ResumeKey of the render that owns it.
+const resumeKeyFile = "resume-key"
+
+// segmentedRender are the inputs of renderSegmented.
+type segmentedRender struct {
+	WorkDir       string
+	ImagePath     string
+	AudioPath     string
+	OutputPath    string
+	Length        time.Duration // Length of the video; the audio is cut there
+	SegmentLength time.Duration
+	Normalize     bool
+	FadeStart     time.Duration
+	FadeOut       time.Duration
+	Preset        Preset
+}
+
+// renderSegmented renders a long video in pieces so a retried render resumes where
+// the last one stopped instead of starting over:
+//
+//  1. the audio is filtered (loudness, fade-out) and encoded once to audio.m4a;
+//  2. the still image is encoded to one video-only segment per SegmentLength;
+//  3. the segments are joined with the concat demuxer and muxed with the audio,
+//     without re-encoding.
+//
+// Each file is written under a partial name and renamed once complete, so files
+// found in WorkDir are finished and are skipped. Returns the number of segments.
+func (p *Processor) renderSegmented(ctx context.Context, r segmentedRender) (int, error) {
+	audioPath := filepath.Join(r.WorkDir, "audio.m4a")
+	if err := p.renderOnce(ctx, audioPath, func(partial string) []string {
+		return buildSegmentAudioArgs(r, partial)
+	}); err != nil {
+		return 0, fmt.Errorf("failed to encode audio: %w", err)
+	}
+
+	starts := segmentStarts(r.Length, r.SegmentLength, r.FadeStart, r.FadeOut)
+	list := make([]string, len(starts))
+	for i, start := range starts {
+		end := r.Length
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		name := fmt.Sprintf("segment-%03d.mp4", i)
+		list[i] = "file '" + name + "'"
+
+		path := filepath.Join(r.WorkDir, name)
+		if fileComplete(path) {
+			p.logger.Debug("segment already rendered, skipping", zap.Int("segment", i))
+			continue
+		}
+		if err := p.renderOnce(ctx, path, func(partial string) []string {
+			return buildSegmentVideoArgs(r, start, end-start, partial)
+		}); err != nil {
+			return 0, fmt.Errorf("failed to encode segment %d of %d: %w", i+1, len(starts), err)
+		}
+		p.logger.Debug("segment rendered", zap.Int("segment", i), zap.Int("segments", len(starts)))
+	}
+
+	listPath := filepath.Join(r.WorkDir, "segments.txt")
+	if err := os.WriteFile(listPath, []byte(strings.Join(list, "\n")+"\n"), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write segment list: %w", err)
+	}
+
+	args := []string{
+		"-f", "concat",
+		"-safe", "0",
+		"-i", listPath,
+		"-i", audioPath,
+		"-map", "0:v",
+		"-map", "1:a",
+		"-c", "copy",
+	}
+	if r.Preset.Tag != "" {
+		args = append(args, "-tag:v", r.Preset.Tag)
+	}
+	args = append(args,
+		"-shortest",
+		"-y", // Overwrite output file if exists
+		r.OutputPath,
+	)
+	if err := p.runFFmpeg(ctx, args); err != nil {
+		return 0, fmt.Errorf("failed to join segments: %w", err)
+	}
+
+	p.logger.Info("segmented video rendered",
+		zap.Int("segments", len(starts)),
+		zap.Duration("length", r.Length),
+	)
+	return len(starts), nil
+}
+
+// renderOnce runs the ffmpeg arguments built for a partial output path and renames
+// the result to path. Does nothing when path already exists.
+func (p *Processor) renderOnce(ctx context.Context, path string, buildArgs func(partial string) []string) error {
+	if fileComplete(path) {
+		return nil
+	}
+	// Keep the extension so ffmpeg picks the container from it
+	ext := filepath.Ext(path)
+	partial := strings.TrimSuffix(path, ext) + "-partial" + ext
+	if err := p.runFFmpeg(ctx, buildArgs(partial)); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, path)
+}
+
+// segmentStarts splits a video of length into segments of segmentLength and
+// returns when each starts. A fade-out never spans two segments: when it would,
+// the last segment starts with the fade instead.
+func segmentStarts(length, segmentLength, fadeStart, fadeOut time.Duration) []time.Duration {
+	starts := []time.Duration{0}
+	for start := segmentLength; start < length; start += segmentLength {
+		starts = append(starts, start)
+	}
+	if last := len(starts) - 1; fadeOut > 0 && last > 0 && starts[last] > fadeStart {
+		starts[last] = fadeStart
+		// A fade longer than the last segment swallows it
+		if starts[last] <= starts[last-1] {
+			starts = starts[:last]
+		}
+	}
+	return starts
+}
+
+// buildSegmentAudioArgs returns the FFmpeg arguments encoding the whole audio track
+// of a segmented render, with the loudness normalization and fade-out applied.
+func buildSegmentAudioArgs(r segmentedRender, outputPath string) []string {
+	var af []string
+	if r.Normalize {
+		af = append(af, loudnormFilter)
+	}
+	if r.FadeOut > 0 {
+		af = append(af, "afade=t=out:st="+formatSeconds(r.FadeStart)+":d="+formatSeconds(r.FadeOut))
+	}
+
+	args := []string{"-i", r.AudioPath, "-vn"}
+	if len(af) > 0 {
+		args = append(args, "-af", strings.Join(af, ","))
+	}
+	args = append(args,
+		"-c:a", "aac",
+		"-b:a", r.Preset.AudioBitrate,
+	)
+	if r.Normalize {
+		args = append(args, "-ar", normalizedSampleRate)
+	}
+	return append(args,
+		"-t", formatSeconds(r.Length),
+		"-y",
+		outputPath,
+	)
+}
+
+// buildSegmentVideoArgs returns the FFmpeg arguments encoding the still image for
+// the segment from start lasting length, fading to black when the fade-out falls in it.
+func buildSegmentVideoArgs(r segmentedRender, start, length time.Duration, outputPath string) []string {
+	vf := videoFilter
+	if r.FadeOut > 0 && r.FadeStart >= start && r.FadeStart < start+length {
+		vf += ",fade=t=out:st=" + formatSeconds(r.FadeStart-start) + ":d=" + formatSeconds(r.FadeOut)
+	}
+
+	args := []string{
+		"-loop", "1",
+		"-i", r.ImagePath,
+		"-vf", vf,
+		"-an",
+	}
+	args = append(args, videoCodecArgs(r.Preset)...)
+	return append(args,
+		"-t", formatSeconds(length),
+		"-pix_fmt", r.Preset.PixelFormat,
+		"-y",
+		outputPath,
+	)
+}
+
+// prepareWorkDir creates dir for a render identified by resumeKey. Files left by a
+// render with a different key (another song, image or setting) are removed first.
+func prepareWorkDir(dir, resumeKey string) error {
+	keyPath := filepath.Join(dir, resumeKeyFile)
+	existing, err := os.ReadFile(keyPath)
+	switch {
+	case err == nil && string(existing) == resumeKey:
+		return nil
+	case err == nil, !errors.Is(err, os.ErrNotExist):
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to clear work directory: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+	if err := os.WriteFile(keyPath, []byte(resumeKey), 0644); err != nil {
+		return fmt.Errorf("failed to write resume key: %w", err)
+	}
+	return nil
+}
+
+// fileComplete reports whether path exists and is not empty.
+func fileComplete(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Size() > 0
+}
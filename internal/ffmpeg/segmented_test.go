@@ -0,0 +1,127 @@
+package ffmpeg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// newSegmentedRender returns a render of three 10-second segments in workDir.
+func newSegmentedRender(workDir string) segmentedRender {
+	return segmentedRender{
+		WorkDir:       workDir,
+		ImagePath:     filepath.Join(workDir, "image.png"),
+		AudioPath:     filepath.Join(workDir, "audio.mp3"),
+		OutputPath:    filepath.Join(workDir, "output.mp4"),
+		Length:        25 * time.Second,
+		SegmentLength: 10 * time.Second,
+		Preset:        PresetByName(DefaultPreset),
+	}
+}
+
+// writeFile writes a placeholder file to path.
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("finished"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+// outputs returns the output path, the last argument, of every ffmpeg call.
+func outputs(calls []string) []string {
+	paths := make([]string, len(calls))
+	for i, call := range calls {
+		args := strings.Fields(call)
+		paths[i] = filepath.Base(args[len(args)-1])
+	}
+	return paths
+}
+
+func TestRenderSegmentedResumesFinishedFiles(t *testing.T) {
+	fake := testutil.InstallFakeFFmpeg(t, 25*time.Second)
+	workDir := filepath.Join(t.TempDir(), "render")
+	if err := prepareWorkDir(workDir, "job-1"); err != nil {
+		t.Fatalf("prepareWorkDir: %v", err)
+	}
+
+	// A previous attempt encoded the audio and the first segment
+	writeFile(t, filepath.Join(workDir, "audio.m4a"))
+	writeFile(t, filepath.Join(workDir, "segment-000.mp4"))
+
+	p := NewProcessor(1, zap.NewNop())
+	segments, err := p.renderSegmented(context.Background(), newSegmentedRender(workDir))
+	if err != nil {
+		t.Fatalf("renderSegmented: %v", err)
+	}
+	if segments != 3 {
+		t.Errorf("segments = %d, want 3", segments)
+	}
+
+	want := []string{"segment-001-partial.mp4", "segment-002-partial.mp4", "output.mp4"}
+	if got := outputs(fake.Calls(t)); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("ffmpeg wrote %v, want %v", got, want)
+	}
+	if calls := fake.Calls(t); !strings.Contains(calls[len(calls)-1], "-f concat") {
+		t.Errorf("last ffmpeg call = %q, want the concat join", calls[len(calls)-1])
+	}
+	for _, name := range []string{"segment-001.mp4", "segment-002.mp4"} {
+		if !fileComplete(filepath.Join(workDir, name)) {
+			t.Errorf("%s was not renamed from its partial file", name)
+		}
+	}
+}
+
+func TestRenderSegmentedChangedResumeKeyStartsOver(t *testing.T) {
+	fake := testutil.InstallFakeFFmpeg(t, 25*time.Second)
+	workDir := filepath.Join(t.TempDir(), "render")
+	p := NewProcessor(1, zap.NewNop())
+	ctx := context.Background()
+
+	if err := prepareWorkDir(workDir, "song-a"); err != nil {
+		t.Fatalf("prepareWorkDir: %v", err)
+	}
+	if _, err := p.renderSegmented(ctx, newSegmentedRender(workDir)); err != nil {
+		t.Fatalf("first render: %v", err)
+	}
+
+	// The same key keeps every finished file: only the join runs again
+	fake.Reset(t)
+	if err := prepareWorkDir(workDir, "song-a"); err != nil {
+		t.Fatalf("prepareWorkDir: %v", err)
+	}
+	if _, err := p.renderSegmented(ctx, newSegmentedRender(workDir)); err != nil {
+		t.Fatalf("resumed render: %v", err)
+	}
+	if got := outputs(fake.Calls(t)); len(got) != 1 || got[0] != "output.mp4" {
+		t.Errorf("resumed render wrote %v, want only output.mp4", got)
+	}
+
+	// Another key, e.g. a different song, clears the directory
+	fake.Reset(t)
+	if err := prepareWorkDir(workDir, "song-b"); err != nil {
+		t.Fatalf("prepareWorkDir: %v", err)
+	}
+	for _, name := range []string{"audio.m4a", "segment-000.mp4", "segment-001.mp4", "segment-002.mp4"} {
+		if _, err := os.Stat(filepath.Join(workDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s survived a resume key change", name)
+		}
+	}
+	if key, _ := os.ReadFile(filepath.Join(workDir, resumeKeyFile)); string(key) != "song-b" {
+		t.Errorf("resume key = %q, want song-b", key)
+	}
+
+	if _, err := p.renderSegmented(ctx, newSegmentedRender(workDir)); err != nil {
+		t.Fatalf("render after key change: %v", err)
+	}
+	want := []string{"audio-partial.m4a", "segment-000-partial.mp4", "segment-001-partial.mp4", "segment-002-partial.mp4", "output.mp4"}
+	if got := outputs(fake.Calls(t)); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("render after key change wrote %v, want %v", got, want)
+	}
+}
@@ -2,32 +2,76 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
 
 	"github.com/jaochai/ugc/internal/middleware"
 	"github.com/jaochai/ugc/internal/models"
 	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/worker"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
 	"github.com/jaochai/ugc/pkg/response"
 )
 
-const maxSystemPromptLength = 15000
+const (
+	minSystemPromptLength = 100
+	maxSystemPromptLength = 15000
+
+	invalidPromptTypeMessage = "invalid prompt type. Must be: song_concept, song_selector, image_concept, or image_selector"
+)
 
 // AdminHandler handles admin-related HTTP requests
 type AdminHandler struct {
 	systemPromptRepo repository.SystemPromptRepository
+	userRepo         repository.UserRepository
+	jobRepo          repository.JobRepository
+	webhookEventRepo repository.WebhookEventRepository
+	spendRepo        repository.UserSpendRepository
+	asynqClient      *asynq.Client
+	queueInspector   worker.QueueInspector
+	workerRegistry   worker.InstanceRegistry // nil without Redis
+	secretUsage      *middleware.WebhookSecretUsage
+	audit            service.AuditService
+	settingsService  service.RuntimeSettingsService
 	logger           *zap.Logger
 }
 
 // NewAdminHandler creates a new AdminHandler instance
 func NewAdminHandler(
 	systemPromptRepo repository.SystemPromptRepository,
+	userRepo repository.UserRepository,
+	jobRepo repository.JobRepository,
+	webhookEventRepo repository.WebhookEventRepository,
+	spendRepo repository.UserSpendRepository,
+	asynqClient *asynq.Client,
+	queueInspector worker.QueueInspector,
+	workerRegistry worker.InstanceRegistry,
+	secretUsage *middleware.WebhookSecretUsage,
+	audit service.AuditService,
+	settingsService service.RuntimeSettingsService,
 	logger *zap.Logger,
 ) *AdminHandler {
 	return &AdminHandler{
 		systemPromptRepo: systemPromptRepo,
+		userRepo:         userRepo,
+		jobRepo:          jobRepo,
+		webhookEventRepo: webhookEventRepo,
+		spendRepo:        spendRepo,
+		asynqClient:      asynqClient,
+		queueInspector:   queueInspector,
+		workerRegistry:   workerRegistry,
+		secretUsage:      secretUsage,
+		audit:            audit,
+		settingsService:  settingsService,
 		logger:           logger,
 	}
 }
@@ -40,7 +84,672 @@ func (h *AdminHandler) RegisterRoutes(rg *gin.RouterGroup, authMiddleware, admin
 	{
 		admin.GET("/system-prompts", h.GetSystemPrompts)
 		admin.PUT("/system-prompts", h.UpdateSystemPrompt)
+		admin.GET("/system-prompts/:type/history", h.ListSystemPromptHistory)
+		admin.POST("/system-prompts/:type/rollback/:revision_id", h.RollbackSystemPrompt)
+
+		admin.GET("/users", h.ListUsers)
+		admin.GET("/users/:id", h.GetUser)
+		admin.PATCH("/users/:id", h.UpdateUser)
+
+		admin.POST("/secrets/reencrypt", h.ReencryptSecrets)
+
+		admin.GET("/webhook-events", h.ListWebhookEvents)
+		admin.GET("/webhook-secret", h.GetWebhookSecretUsage)
+
+		admin.GET("/settings", h.GetSettings)
+		admin.PUT("/settings", h.UpdateSettings)
+
+		admin.GET("/stats/stages", h.GetStageStats)
+
+		admin.GET("/audit-logs", h.ListAuditLogs)
+
+		admin.GET("/queues", h.ListQueues)
+		admin.GET("/workers", h.ListWorkers)
+		admin.GET("/tasks", h.ListTasks)
+		admin.POST("/tasks/:id/retry", h.RetryTask)
+		admin.DELETE("/tasks/:id", h.DeleteTask)
+	}
+}
+
+// ReencryptSecrets starts re-encrypting stored user secrets with the primary key
+// @Summary Re-encrypt secrets
+// @Description Enqueues a background task that rewrites every stored API key and YouTube token with the current ENCRYPTION_KEY. Run after rotating the key, before removing it from ENCRYPTION_KEYS_LEGACY (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} response.Response{data=map[string]string}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/secrets/reencrypt [post]
+func (h *AdminHandler) ReencryptSecrets(c *gin.Context) {
+	adminID, _ := middleware.GetUserIDFromContext(c)
+
+	task, err := worker.NewReencryptSecretsTask(adminID, middleware.GetRequestID(c))
+	if err == nil {
+		_, err = h.asynqClient.EnqueueContext(c.Request.Context(), task)
+	}
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			response.Error(c, apperrors.NewConflict("re-encryption is already in progress"))
+			return
+		}
+		h.logger.Error("failed to enqueue re-encrypt secrets task", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	h.logger.Info("re-encrypt secrets task enqueued", zap.String("requested_by", adminID.String()))
+	response.Accepted(c, map[string]string{"message": "re-encryption started"})
+}
+
+// maxUserSearchLength bounds the q search parameter.
+const maxUserSearchLength = 200
+
+// ListUsers returns a page of registered users
+// @Summary List users
+// @Description Returns registered users, newest first, optionally filtered by email, with their estimated KIE credit spend this month (admin only)
+// @Tags admin
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Param q query string false "Case-insensitive email search"
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]models.AdminUserResponse,meta=response.Meta}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/users [get]
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	page, perPage := parsePagination(c, 20, 100)
+
+	filter := models.UserFilter{Query: strings.TrimSpace(c.Query("q"))}
+	if len(filter.Query) > maxUserSearchLength {
+		response.ValidationError(c, map[string]string{"q": fmt.Sprintf("must be %d characters or less", maxUserSearchLength)})
+		return
+	}
+
+	users, total, err := h.userRepo.List(c.Request.Context(), filter, page, perPage)
+	if err != nil {
+		h.logger.Error("failed to list users", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(users))
+	for _, u := range users {
+		userIDs = append(userIDs, u.ID)
+	}
+	spend, err := h.spendRepo.SummarizeSince(c.Request.Context(), userIDs, models.SpendPeriodStart(time.Now()))
+	if err != nil {
+		h.logger.Error("failed to summarize user spend", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	items := make([]models.AdminUserResponse, 0, len(users))
+	for _, u := range users {
+		item := u.ToAdminResponse()
+		item.Spend = spend[u.ID]
+		items = append(items, item)
+	}
+
+	response.SuccessWithMeta(c, items, response.NewMeta(page, perPage, total))
+}
+
+// GetUser returns a single user with job counts
+// @Summary Get user
+// @Description Returns a user with the number of jobs in each status and their estimated KIE credit spend this month (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=models.AdminUserResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/users/{id} [get]
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, ok := h.loadUser(c, userID)
+	if !ok {
+		return
+	}
+
+	counts, err := h.jobRepo.CountByStatusForUser(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to count user jobs", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, err)
+		return
+	}
+
+	spend, err := h.spendRepo.SummarizeSince(c.Request.Context(), []uuid.UUID{userID}, models.SpendPeriodStart(time.Now()))
+	if err != nil {
+		h.logger.Error("failed to summarize user spend", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, err)
+		return
+	}
+
+	resp := user.ToAdminResponse()
+	resp.JobCounts = counts
+	resp.Spend = spend[userID]
+	response.Success(c, resp)
+}
+
+// UpdateUser changes a user's role, disabled flag or OpenRouter token budget
+// @Summary Update user
+// @Description Sets a user's role, disabled flag and/or monthly OpenRouter token budget (0 removes it). Refuses changes that would leave no active admin (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param input body models.UpdateUserAdminInput true "Fields to update"
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=models.AdminUserResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/users/{id} [patch]
+func (h *AdminHandler) UpdateUser(c *gin.Context) {
+	adminID, _ := middleware.GetUserIDFromContext(c)
+
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input models.UpdateUserAdminInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BadRequest(c, "invalid request body")
+		return
+	}
+
+	if input.Role == nil && input.Disabled == nil && input.OpenRouterMonthlyTokenLimit == nil {
+		response.BadRequest(c, "role, disabled or openrouter_monthly_token_limit is required")
+		return
+	}
+	if input.Role != nil && !models.IsValidRole(*input.Role) {
+		response.ValidationError(c, map[string]string{"role": "must be one of user, admin"})
+		return
+	}
+	if err := validateTokenLimit(input.OpenRouterMonthlyTokenLimit); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if input.Role != nil {
+		if err := h.userRepo.SetRole(ctx, userID, *input.Role); err != nil {
+			h.respondUserUpdateError(c, err, userID)
+			return
+		}
+	}
+	if input.Disabled != nil {
+		if err := h.userRepo.SetDisabled(ctx, userID, *input.Disabled); err != nil {
+			h.respondUserUpdateError(c, err, userID)
+			return
+		}
+	}
+	if input.OpenRouterMonthlyTokenLimit != nil {
+		limit := optionalTokenLimit(*input.OpenRouterMonthlyTokenLimit)
+		if err := h.userRepo.SetOpenRouterTokenLimit(ctx, userID, limit); err != nil {
+			h.respondUserUpdateError(c, err, userID)
+			return
+		}
+	}
+
+	h.logger.Info("user updated by admin",
+		zap.String("user_id", userID.String()),
+		zap.String("updated_by", adminID.String()),
+		zap.Any("role", input.Role),
+		zap.Any("disabled", input.Disabled),
+		zap.Any("openrouter_monthly_token_limit", input.OpenRouterMonthlyTokenLimit),
+	)
+
+	user, ok := h.loadUser(c, userID)
+	if !ok {
+		return
+	}
+
+	response.Success(c, user.ToAdminResponse())
+}
+
+// respondUserUpdateError maps user repository errors from admin updates to responses.
+func (h *AdminHandler) respondUserUpdateError(c *gin.Context, err error, userID uuid.UUID) {
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		response.Error(c, apperrors.NewNotFound("user not found").WithCode(apperrors.CodeUserNotFound))
+	case errors.Is(err, repository.ErrLastAdmin):
+		response.Error(c, apperrors.NewConflict("cannot demote or disable the last active admin").WithCode(apperrors.CodeLastAdmin))
+	default:
+		h.logger.Error("failed to update user", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, err)
+	}
+}
+
+// loadUser fetches a non-deleted user, writing a 404 or 500 response on failure.
+func (h *AdminHandler) loadUser(c *gin.Context, userID uuid.UUID) (*models.User, bool) {
+	user, err := h.userRepo.GetByID(c.Request.Context(), userID)
+	if err == nil && user.IsDeleted() {
+		err = repository.ErrUserNotFound
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			response.Error(c, apperrors.NewNotFound("user not found").WithCode(apperrors.CodeUserNotFound))
+			return nil, false
+		}
+		h.logger.Error("failed to get user", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// parseUserIDParam parses the :id path parameter, writing a 400 response if invalid.
+func parseUserIDParam(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "invalid user ID")
+		return uuid.Nil, false
 	}
+	return userID, true
+}
+
+// ListWebhookEvents returns captured webhook callbacks
+// @Summary List captured webhook callbacks
+// @Description Returns the most recent raw webhook callbacks, newest first, optionally for a single job. Callbacks are only captured while WEBHOOK_CAPTURE_ENABLED is set (admin only)
+// @Tags admin
+// @Produce json
+// @Param job_id query string false "Job ID"
+// @Param limit query int false "Maximum number of events" default(50)
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]models.WebhookEvent}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/webhook-events [get]
+func (h *AdminHandler) ListWebhookEvents(c *gin.Context) {
+	limit := 50
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+			if limit > 200 {
+				limit = 200
+			}
+		}
+	}
+
+	var jobID *uuid.UUID
+	if jobIDStr := c.Query("job_id"); jobIDStr != "" {
+		id, err := uuid.Parse(jobIDStr)
+		if err != nil {
+			response.BadRequest(c, "invalid job ID")
+			return
+		}
+		jobID = &id
+	}
+
+	events, err := h.webhookEventRepo.List(c.Request.Context(), jobID, limit)
+	if err != nil {
+		h.logger.Error("failed to list webhook events", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, events)
+}
+
+// ListAuditLogs returns a page of the audit log
+// @Summary List audit logs
+// @Description Returns security-relevant actions, newest first: logins (with the failure reason), API key, profile and prompt changes, system prompt updates and YouTube connections. Metadata never contains secrets (admin only)
+// @Tags admin
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(50) maximum(200)
+// @Param user_id query string false "Only actions of this user"
+// @Param action query string false "Only this action, e.g. login.failure"
+// @Param created_after query string false "Only entries at or after (RFC3339)"
+// @Param created_before query string false "Only entries before (RFC3339)"
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]models.AuditLog,meta=response.Meta}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/audit-logs [get]
+func (h *AdminHandler) ListAuditLogs(c *gin.Context) {
+	page, perPage := parsePagination(c, 50, 200)
+
+	var filter models.AuditLogFilter
+	details := make(map[string]string)
+	if v := c.Query("user_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			details["user_id"] = "user_id must be a UUID"
+		} else {
+			filter.UserID = &id
+		}
+	}
+	filter.Action = strings.TrimSpace(c.Query("action"))
+	if v := c.Query("created_after"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			details["created_after"] = "created_after must be an RFC3339 timestamp"
+		} else {
+			filter.CreatedAfter = &t
+		}
+	}
+	if v := c.Query("created_before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			details["created_before"] = "created_before must be an RFC3339 timestamp"
+		} else {
+			filter.CreatedBefore = &t
+		}
+	}
+	if len(details) > 0 {
+		response.ValidationError(c, details)
+		return
+	}
+
+	entries, meta, err := h.audit.List(c.Request.Context(), filter, page, perPage)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.SuccessWithMeta(c, entries, meta)
+}
+
+// defaultStageStatsDays is the window of GetStageStats; maxStageStatsDays caps it.
+const (
+	defaultStageStatsDays = 7
+	maxStageStatsDays     = 90
+)
+
+// GetWebhookSecretUsage reports which webhook secret incoming callbacks use
+// @Summary Webhook secret usage
+// @Description Returns how many webhook callbacks this API instance authenticated with WEBHOOK_SECRET and with WEBHOOK_SECRET_PREVIOUS since it started, and when each was last used. Once no callback uses the previous secret for longer than the slowest KIE task, it can be removed (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=middleware.WebhookSecretReport}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /admin/webhook-secret [get]
+func (h *AdminHandler) GetWebhookSecretUsage(c *gin.Context) {
+	response.Success(c, h.secretUsage.Report())
+}
+
+// GetSettings returns the runtime settings
+// @Summary Get runtime settings
+// @Description Returns whether job intake is paused and the maintenance banner message (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=models.RuntimeSettings}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/settings [get]
+func (h *AdminHandler) GetSettings(c *gin.Context) {
+	settings, err := h.settingsService.Get(c.Request.Context())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, settings)
+}
+
+// UpdateSettings changes the runtime settings
+// @Summary Update runtime settings
+// @Description Pauses or resumes job intake and sets the maintenance banner (max 500 characters; empty removes it). While paused, creating jobs (single, bulk) returns 503 JOB_INTAKE_PAUSED with the banner and schedules wait; jobs already created keep processing. GET /status and GET /auth/me show the banner. Other API instances pick the change up within 10 seconds (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param input body models.UpdateRuntimeSettingsInput true "Settings to update"
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=models.RuntimeSettings}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/settings [put]
+func (h *AdminHandler) UpdateSettings(c *gin.Context) {
+	adminID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Unauthorized(c, "user not authenticated")
+		return
+	}
+
+	var input models.UpdateRuntimeSettingsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BadRequest(c, "invalid request body")
+		return
+	}
+
+	settings, err := h.settingsService.Update(c.Request.Context(), adminID, input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	recordAudit(c, h.audit, adminID, models.AuditActionSettingsUpdate, "", map[string]interface{}{
+		"job_intake_paused": settings.JobIntakePaused,
+		"banner_message":    settings.BannerMessage,
+	})
+	response.Success(c, settings)
+}
+
+// GetStageStats returns pipeline stage duration percentiles
+// @Summary Pipeline stage duration stats
+// @Description Returns the number of timed jobs and the p50/p95 duration of each pipeline stage (analyze, music, image, video, upload, and video_upload for the R2 transfer) for jobs created in the last days (admin only)
+// @Tags admin
+// @Produce json
+// @Param days query int false "Window in days (1-90)" default(7)
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]models.StageDurationStats}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/stats/stages [get]
+func (h *AdminHandler) GetStageStats(c *gin.Context) {
+	days := defaultStageStatsDays
+	if daysStr := c.Query("days"); daysStr != "" {
+		d, err := strconv.Atoi(daysStr)
+		if err != nil || d < 1 || d > maxStageStatsDays {
+			response.ValidationError(c, map[string]string{
+				"days": fmt.Sprintf("must be between 1 and %d", maxStageStatsDays),
+			})
+			return
+		}
+		days = d
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	stats, err := h.jobRepo.StageDurationStats(c.Request.Context(), since)
+	if err != nil {
+		h.logger.Error("failed to get stage duration stats", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, stats)
+}
+
+// ListQueues returns task counts per queue
+// @Summary List task queues
+// @Description Returns the number of pending, active, scheduled, retry, archived and completed tasks in each asynq queue, plus today's processed and failed counts (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]worker.QueueStats}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/queues [get]
+func (h *AdminHandler) ListQueues(c *gin.Context) {
+	queues, err := h.queueInspector.Queues(c.Request.Context())
+	if err != nil {
+		h.logger.Error("failed to get queue stats", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, queues)
+}
+
+// ListWorkers returns the registered worker instances
+// @Summary List worker instances
+// @Description Returns each worker instance with its hostname, pid, version, start time and last heartbeat. Instances without a heartbeat for 90 seconds are reported with alive=false; the list is empty when Redis is not configured (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]worker.Instance}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/workers [get]
+func (h *AdminHandler) ListWorkers(c *gin.Context) {
+	if h.workerRegistry == nil {
+		response.Success(c, []*worker.Instance{})
+		return
+	}
+
+	instances, err := h.workerRegistry.List(c.Request.Context())
+	if err != nil {
+		h.logger.Error("failed to list worker instances", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, instances)
+}
+
+// ListTasks returns a page of queued tasks in one state
+// @Summary List queued tasks
+// @Description Returns tasks in the given state, e.g. archived tasks that ran out of retries, with the job ID decoded from their payload (admin only)
+// @Tags admin
+// @Produce json
+// @Param state query string false "Task state" Enums(pending, active, scheduled, retry, archived, completed) default(archived)
+// @Param type query string false "Task type, e.g. job:process_video"
+// @Param queue query string false "Queue name; all queues when empty"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]worker.TaskSummary}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/tasks [get]
+func (h *AdminHandler) ListTasks(c *gin.Context) {
+	page, perPage := parsePagination(c, 20, 100)
+
+	state := c.DefaultQuery("state", worker.TaskStateArchived)
+	tasks, err := h.queueInspector.ListTasks(c.Request.Context(), c.Query("queue"), state, c.Query("type"), page, perPage)
+	if err != nil {
+		if errors.Is(err, worker.ErrInvalidTaskState) {
+			response.ValidationError(c, map[string]string{
+				"state": "must be one of pending, active, scheduled, retry, archived, completed",
+			})
+			return
+		}
+		h.logger.Error("failed to list tasks", zap.String("state", state), zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, tasks)
+}
+
+// RetryTask runs a failed or scheduled task now
+// @Summary Retry a task
+// @Description Moves a retry, archived or scheduled task back to pending so a worker runs it now (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Task ID"
+// @Param queue query string false "Queue name; searched when empty"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /admin/tasks/{id}/retry [post]
+func (h *AdminHandler) RetryTask(c *gin.Context) {
+	adminID, _ := middleware.GetUserIDFromContext(c)
+	taskID := c.Param("id")
+
+	if err := h.queueInspector.RetryTask(c.Request.Context(), c.Query("queue"), taskID); err != nil {
+		h.taskActionError(c, taskID, "retry", err)
+		return
+	}
+
+	h.logger.Info("task retried by admin",
+		zap.String("task_id", taskID),
+		zap.String("admin_id", adminID.String()),
+	)
+	response.NoContent(c)
+}
+
+// DeleteTask drops a queued task
+// @Summary Delete a task
+// @Description Deletes a task that is not currently running, e.g. an archived task that should not be retried (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Task ID"
+// @Param queue query string false "Queue name; searched when empty"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /admin/tasks/{id} [delete]
+func (h *AdminHandler) DeleteTask(c *gin.Context) {
+	adminID, _ := middleware.GetUserIDFromContext(c)
+	taskID := c.Param("id")
+
+	if err := h.queueInspector.DeleteTask(c.Request.Context(), c.Query("queue"), taskID); err != nil {
+		h.taskActionError(c, taskID, "delete", err)
+		return
+	}
+
+	h.logger.Info("task deleted by admin",
+		zap.String("task_id", taskID),
+		zap.String("admin_id", adminID.String()),
+	)
+	response.NoContent(c)
+}
+
+// taskActionError writes the response for a failed task retry or delete. asynq
+// refuses the action for tasks in the wrong state (e.g. active), reported as 409.
+func (h *AdminHandler) taskActionError(c *gin.Context, taskID, action string, err error) {
+	if errors.Is(err, worker.ErrTaskNotFound) {
+		response.NotFound(c, "task not found")
+		return
+	}
+	h.logger.Warn("task action failed",
+		zap.String("task_id", taskID),
+		zap.String("action", action),
+		zap.Error(err),
+	)
+	response.Error(c, apperrors.NewConflict(fmt.Sprintf("cannot %s task: %v", action, err)))
 }
 
 // GetSystemPrompts returns all system prompts
@@ -72,6 +781,8 @@ func (h *AdminHandler) GetSystemPrompts(c *gin.Context) {
 			resp.SongSelector = p
 		case "image_concept":
 			resp.ImageConcept = p
+		case "image_selector":
+			resp.ImageSelector = p
 		}
 	}
 
@@ -105,49 +816,156 @@ func (h *AdminHandler) UpdateSystemPrompt(c *gin.Context) {
 		return
 	}
 
-	// Validate prompt type
-	validTypes := map[string]bool{
-		"song_concept":  true,
-		"song_selector": true,
-		"image_concept": true,
+	if !models.IsValidPromptType(input.PromptType) {
+		response.BadRequest(c, invalidPromptTypeMessage)
+		return
+	}
+
+	if err := validateSystemPromptContent(input.PromptContent); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	h.writeSystemPrompt(c, input.PromptType, input.PromptContent, userID, models.AuditActionSystemPromptUpdate, nil)
+}
+
+// ListSystemPromptHistory returns previous versions of a system prompt
+// @Summary List system prompt history
+// @Description Returns the archived versions of a system prompt, newest first. A revision is recorded on every update that changes the content (admin only)
+// @Tags admin
+// @Produce json
+// @Param type path string true "Prompt type"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]models.SystemPromptRevision}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/system-prompts/{type}/history [get]
+func (h *AdminHandler) ListSystemPromptHistory(c *gin.Context) {
+	promptType := c.Param("type")
+	if !models.IsValidPromptType(promptType) {
+		response.BadRequest(c, invalidPromptTypeMessage)
+		return
+	}
+
+	page, perPage := parsePagination(c, 20, 100)
+
+	revisions, total, err := h.systemPromptRepo.ListRevisions(c.Request.Context(), promptType, page, perPage)
+	if err != nil {
+		h.logger.Error("failed to list system prompt revisions",
+			zap.Error(err),
+			zap.String("prompt_type", promptType),
+		)
+		response.Error(c, err)
+		return
 	}
-	if !validTypes[input.PromptType] {
-		response.BadRequest(c, "invalid prompt type. Must be: song_concept, song_selector, or image_concept")
+
+	response.SuccessWithMeta(c, revisions, response.NewMeta(page, perPage, total))
+}
+
+// RollbackSystemPrompt restores a system prompt to a previous revision
+// @Summary Roll back a system prompt
+// @Description Replaces a system prompt with the content of one of its revisions. The content being replaced is archived as a new revision, so a rollback can itself be undone (admin only)
+// @Tags admin
+// @Produce json
+// @Param type path string true "Prompt type"
+// @Param revision_id path string true "Revision ID"
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=models.SystemPrompt}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/system-prompts/{type}/rollback/{revision_id} [post]
+func (h *AdminHandler) RollbackSystemPrompt(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Unauthorized(c, "user not authenticated")
 		return
 	}
 
-	// Validate prompt length
-	if len(input.PromptContent) < 100 {
-		response.BadRequest(c, "prompt must be at least 100 characters")
+	promptType := c.Param("type")
+	if !models.IsValidPromptType(promptType) {
+		response.BadRequest(c, invalidPromptTypeMessage)
 		return
 	}
-	if len(input.PromptContent) > maxSystemPromptLength {
-		response.BadRequest(c, fmt.Sprintf("prompt must be %d characters or less", maxSystemPromptLength))
+
+	revisionID, err := uuid.Parse(c.Param("revision_id"))
+	if err != nil {
+		response.BadRequest(c, "invalid revision ID")
+		return
+	}
+
+	revision, err := h.systemPromptRepo.GetRevision(c.Request.Context(), promptType, revisionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSystemPromptRevisionNotFound) {
+			response.NotFound(c, "revision not found")
+			return
+		}
+		h.logger.Error("failed to get system prompt revision",
+			zap.Error(err),
+			zap.String("revision_id", revisionID.String()),
+		)
+		response.Error(c, err)
 		return
 	}
 
-	// Update prompt
-	if err := h.systemPromptRepo.Update(
-		c.Request.Context(),
-		input.PromptType,
-		input.PromptContent,
-		userID,
-	); err != nil {
+	// Limits may have tightened since the revision was archived
+	if err := validateSystemPromptContent(revision.PromptContent); err != nil {
+		response.BadRequest(c, "revision cannot be restored: "+err.Error())
+		return
+	}
+
+	h.logger.Info("rolling back system prompt",
+		zap.String("prompt_type", promptType),
+		zap.String("revision_id", revisionID.String()),
+	)
+
+	h.writeSystemPrompt(c, promptType, revision.PromptContent, userID, models.AuditActionSystemPromptRollback, map[string]interface{}{
+		"revision_id": revisionID.String(),
+	})
+}
+
+// validateSystemPromptContent applies the length limits for system prompts.
+func validateSystemPromptContent(content string) error {
+	if len(content) < minSystemPromptLength {
+		return fmt.Errorf("prompt must be at least %d characters", minSystemPromptLength)
+	}
+	if len(content) > maxSystemPromptLength {
+		return fmt.Errorf("prompt must be %d characters or less", maxSystemPromptLength)
+	}
+	return nil
+}
+
+// writeSystemPrompt stores new prompt content, invalidates the prompt cache, records
+// the change in the audit log and responds with the updated prompt.
+func (h *AdminHandler) writeSystemPrompt(c *gin.Context, promptType, content string, userID uuid.UUID, action string, metadata map[string]interface{}) {
+	if err := h.systemPromptRepo.Update(c.Request.Context(), promptType, content, userID); err != nil {
 		h.logger.Error("failed to update system prompt",
 			zap.Error(err),
-			zap.String("prompt_type", input.PromptType),
+			zap.String("prompt_type", promptType),
 		)
 		response.Error(c, err)
 		return
 	}
 
+	// Drop cached prompts so agents pick up the new content immediately
+	if cache, ok := h.systemPromptRepo.(repository.SystemPromptCacheInvalidator); ok {
+		cache.Invalidate()
+	}
+
 	h.logger.Info("system prompt updated",
-		zap.String("prompt_type", input.PromptType),
+		zap.String("prompt_type", promptType),
 		zap.String("updated_by", userID.String()),
 	)
+	recordAudit(c, h.audit, userID, action, promptType, metadata)
 
 	// Return updated prompt
-	prompt, err := h.systemPromptRepo.GetByType(c.Request.Context(), input.PromptType)
+	prompt, err := h.systemPromptRepo.GetByType(c.Request.Context(), promptType)
 	if err != nil {
 		h.logger.Error("failed to get updated prompt", zap.Error(err))
 		response.Error(c, err)
@@ -0,0 +1,401 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/handler"
+	"github.com/jaochai/ugc/internal/middleware"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/testutil"
+	"github.com/jaochai/ugc/internal/worker"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/response"
+)
+
+const testJWTSecret = "test-jwt-secret-0123456789abcdef0123456789"
+
+// newAdminRouter serves the admin routes behind the real auth and admin
+// middleware, over in-memory users and the given system prompts, audit log and
+// queue inspector.
+func newAdminRouter(users *testutil.FakeUserRepository, prompts repository.SystemPromptRepository, audit service.AuditService, inspector worker.QueueInspector) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := zap.NewNop()
+
+	authService := service.NewAuthService(users, nil, nil, nil, nil, service.AuthConfig{JWTSecret: testJWTSecret}, logger)
+	adminHandler := handler.NewAdminHandler(prompts, users, nil, nil, testutil.FakeUserSpendRepository{},
+		nil, inspector, nil, nil, audit, nil, logger)
+
+	router := gin.New()
+	adminHandler.RegisterRoutes(router.Group("/api/v1"),
+		middleware.AuthMiddleware(authService, logger), middleware.AdminMiddleware(logger))
+	return router
+}
+
+// newTestUser returns an active user with role.
+func newTestUser(role string) *models.User {
+	return &models.User{ID: uuid.New(), Email: role + "-" + uuid.NewString() + "@example.com", Role: role}
+}
+
+// serveAs sends a request authenticated as user, or anonymously when user is nil,
+// and decodes the response envelope.
+func serveAs(t *testing.T, router *gin.Engine, user *models.User, method, path, body string) (int, response.Response) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if user != nil {
+		req.Header.Set("Authorization", "Bearer "+testutil.AccessToken(t, testJWTSecret, user))
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var resp response.Response
+	if rec.Code == http.StatusNoContent {
+		return rec.Code, resp
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not the standard envelope: %v; body: %s", err, rec.Body.String())
+	}
+	return rec.Code, resp
+}
+
+func TestAdminUsersAuthorization(t *testing.T) {
+	admin := newTestUser(models.RoleAdmin)
+	user := newTestUser(models.RoleUser)
+	disabledAdmin := newTestUser(models.RoleAdmin)
+	disabledAdmin.Disabled = true
+	// The token still says admin, but the role was changed since it was issued
+	demoted := newTestUser(models.RoleUser)
+	demotedToken := &models.User{ID: demoted.ID, Email: demoted.Email, Role: models.RoleAdmin}
+
+	router := newAdminRouter(testutil.NewFakeUserRepository(admin, user, disabledAdmin, demoted), nil, nil, nil)
+
+	tests := []struct {
+		name          string
+		as            *models.User
+		wantStatus    int
+		wantErrorCode string
+	}{
+		{name: "anonymous", as: nil, wantStatus: http.StatusUnauthorized},
+		{name: "user", as: user, wantStatus: http.StatusForbidden},
+		{name: "admin", as: admin, wantStatus: http.StatusOK},
+		{name: "disabled admin", as: disabledAdmin, wantStatus: http.StatusForbidden, wantErrorCode: apperrors.CodeAccountDisabled},
+		{name: "admin token of a demoted user", as: demotedToken, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := serveAs(t, router, tt.as, http.MethodGet, "/api/v1/admin/users", "")
+			if status != tt.wantStatus {
+				t.Fatalf("GET /admin/users status = %d, want %d; error: %+v", status, tt.wantStatus, resp.Error)
+			}
+			if tt.wantErrorCode != "" && (resp.Error == nil || resp.Error.ErrorCode != tt.wantErrorCode) {
+				t.Errorf("error = %+v, want error_code %s", resp.Error, tt.wantErrorCode)
+			}
+		})
+	}
+}
+
+func TestAdminCannotRemoveLastAdmin(t *testing.T) {
+	admin := newTestUser(models.RoleAdmin)
+	users := testutil.NewFakeUserRepository(admin, newTestUser(models.RoleUser))
+	router := newAdminRouter(users, nil, nil, nil)
+	path := "/api/v1/admin/users/" + admin.ID.String()
+
+	for _, body := range []string{`{"role": "user"}`, `{"disabled": true}`} {
+		status, resp := serveAs(t, router, admin, http.MethodPatch, path, body)
+		if status != http.StatusConflict || resp.Error == nil || resp.Error.ErrorCode != apperrors.CodeLastAdmin {
+			t.Errorf("PATCH %s by the last admin = %d %+v, want 409 %s", body, status, resp.Error, apperrors.CodeLastAdmin)
+		}
+	}
+	if stored, _ := users.GetByID(t.Context(), admin.ID); stored.Role != models.RoleAdmin || stored.Disabled {
+		t.Fatalf("last admin was changed to role %s, disabled %v", stored.Role, stored.Disabled)
+	}
+
+	// With a second active admin the first may step down
+	users = testutil.NewFakeUserRepository(admin, newTestUser(models.RoleAdmin))
+	router = newAdminRouter(users, nil, nil, nil)
+	status, resp := serveAs(t, router, admin, http.MethodPatch, path, `{"role": "user"}`)
+	if status != http.StatusOK {
+		t.Fatalf("PATCH role by one of two admins = %d %+v, want 200", status, resp.Error)
+	}
+	if stored, _ := users.GetByID(t.Context(), admin.ID); stored.Role != models.RoleUser {
+		t.Errorf("role = %s, want user", stored.Role)
+	}
+
+	// The demotion applies to the token issued before it
+	if status, _ := serveAs(t, router, admin, http.MethodGet, "/api/v1/admin/users", ""); status != http.StatusForbidden {
+		t.Errorf("GET /admin/users after stepping down = %d, want 403", status)
+	}
+}
+
+// auditRecorder records audit entries in memory.
+type auditRecorder struct {
+	service.AuditService
+
+	mu      sync.Mutex
+	entries []service.AuditEntry
+}
+
+func (a *auditRecorder) Log(ctx context.Context, entry service.AuditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = append(a.entries, entry)
+}
+
+// decodeData decodes the data of a response envelope into v.
+func decodeData(t *testing.T, resp response.Response, v interface{}) {
+	t.Helper()
+	raw, err := json.Marshal(resp.Data)
+	if err != nil {
+		t.Fatalf("failed to encode response data: %v", err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		t.Fatalf("failed to decode response data: %v; data: %s", err, raw)
+	}
+}
+
+// TestAdminSystemPromptRollback replaces a Thai system prompt, rolls it back
+// from the history and checks that the restored content is byte-identical in
+// the response, the stored prompt and the cache, and that the rollback is
+// itself archived and audited.
+func TestAdminSystemPromptRollback(t *testing.T) {
+	// Tone marks, above and below vowels, Thai digits, characters JSON escapes
+	// and surrounding whitespace
+	const thai = "  คุณคือโปรดิวเซอร์เพลงไทย ใช้วรรณยุกต์ ่ ้ ๊ ๋ และสระ ิ ี ึ ื ุ ู ำ ให้ถูกต้อง\r\n" +
+		"เขียนเนื้อเพลง ๑๒๓ ท่อน <Verse> & \"Chorus\" ภาษา %s\n\t"
+	replacement := strings.Repeat("An English prompt that replaces the Thai one. ", 3)
+
+	admin := newTestUser(models.RoleAdmin)
+	inner := testutil.NewFakeSystemPromptRepository(map[string]string{models.PromptTypeSongConcept: thai})
+	audit := &auditRecorder{}
+	router := newAdminRouter(testutil.NewFakeUserRepository(admin),
+		repository.NewCachedSystemPromptRepository(inner, time.Hour), audit, nil)
+
+	// current returns the song concept prompt as listed by the API
+	current := func() string {
+		t.Helper()
+		status, resp := serveAs(t, router, admin, http.MethodGet, "/api/v1/admin/system-prompts", "")
+		if status != http.StatusOK {
+			t.Fatalf("GET /admin/system-prompts = %d %+v", status, resp.Error)
+		}
+		var prompts models.SystemPromptsResponse
+		decodeData(t, resp, &prompts)
+		return prompts.SongConcept.PromptContent
+	}
+	// history returns the song concept revisions, newest first
+	history := func() []models.SystemPromptRevision {
+		t.Helper()
+		status, resp := serveAs(t, router, admin, http.MethodGet, "/api/v1/admin/system-prompts/song_concept/history", "")
+		if status != http.StatusOK {
+			t.Fatalf("GET history = %d %+v", status, resp.Error)
+		}
+		var revisions []models.SystemPromptRevision
+		decodeData(t, resp, &revisions)
+		return revisions
+	}
+
+	if got := current(); got != thai {
+		t.Fatalf("seeded content = %q, want %q", got, thai)
+	}
+
+	body, err := json.Marshal(models.UpdateSystemPromptInput{PromptType: models.PromptTypeSongConcept, PromptContent: replacement})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, resp := serveAs(t, router, admin, http.MethodPut, "/api/v1/admin/system-prompts", string(body)); status != http.StatusOK {
+		t.Fatalf("PUT /admin/system-prompts = %d %+v", status, resp.Error)
+	}
+	if got := current(); got != replacement {
+		t.Fatalf("content after the update = %q, want the replacement", got)
+	}
+
+	revisions := history()
+	if len(revisions) != 1 || revisions[0].PromptContent != thai {
+		t.Fatalf("history = %+v, want the Thai prompt", revisions)
+	}
+	revisionID := revisions[0].ID.String()
+
+	status, resp := serveAs(t, router, admin, http.MethodPost,
+		"/api/v1/admin/system-prompts/song_concept/rollback/"+revisionID, "")
+	if status != http.StatusOK {
+		t.Fatalf("POST rollback = %d %+v", status, resp.Error)
+	}
+	var restored models.SystemPrompt
+	decodeData(t, resp, &restored)
+	if restored.PromptContent != thai {
+		t.Errorf("rollback response content = %q, want %q", restored.PromptContent, thai)
+	}
+	if got := current(); got != thai {
+		t.Errorf("listed content after the rollback = %q, want %q", got, thai)
+	}
+	stored, err := inner.GetByType(context.Background(), models.PromptTypeSongConcept)
+	if err != nil {
+		t.Fatalf("GetByType: %v", err)
+	}
+	if stored.PromptContent != thai {
+		t.Errorf("stored content after the rollback = %q, want %q", stored.PromptContent, thai)
+	}
+
+	if revisions := history(); len(revisions) != 2 || revisions[0].PromptContent != replacement {
+		t.Errorf("history after the rollback = %+v, want the replacement archived first", revisions)
+	}
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	last := audit.entries[len(audit.entries)-1]
+	if last.Action != models.AuditActionSystemPromptRollback || last.Metadata["revision_id"] != revisionID {
+		t.Errorf("last audit entry = %+v, want a rollback of revision %s", last, revisionID)
+	}
+}
+
+// fakeInspector records the task queries it receives and serves fixed tasks.
+// Unused QueueInspector methods panic.
+type fakeInspector struct {
+	worker.QueueInspector
+	tasks   map[string]worker.TaskSummary // By ID
+	failing string                        // ID of a task whose actions fail
+	calls   []string
+}
+
+func (f *fakeInspector) Queues(ctx context.Context) ([]worker.QueueStats, error) {
+	return []worker.QueueStats{{Queue: "default", Pending: 2, Archived: 1}, {Queue: "low", Retry: 1}}, nil
+}
+
+func (f *fakeInspector) ListTasks(ctx context.Context, queue, state, taskType string, page, pageSize int) ([]worker.TaskSummary, error) {
+	f.calls = append(f.calls, fmt.Sprintf("list queue=%s state=%s type=%s page=%d size=%d", queue, state, taskType, page, pageSize))
+	if state == "dead" {
+		return nil, worker.ErrInvalidTaskState
+	}
+	tasks := []worker.TaskSummary{}
+	for _, task := range f.tasks {
+		if task.State == state && (taskType == "" || task.Type == taskType) {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+func (f *fakeInspector) RetryTask(ctx context.Context, queue, id string) error {
+	return f.action("retry", queue, id)
+}
+
+func (f *fakeInspector) DeleteTask(ctx context.Context, queue, id string) error {
+	return f.action("delete", queue, id)
+}
+
+func (f *fakeInspector) action(name, queue, id string) error {
+	f.calls = append(f.calls, fmt.Sprintf("%s queue=%s id=%s", name, queue, id))
+	if id == f.failing {
+		return errors.New("task is active")
+	}
+	if _, ok := f.tasks[id]; !ok {
+		return worker.ErrTaskNotFound
+	}
+	delete(f.tasks, id)
+	return nil
+}
+
+// TestAdminTaskReview lists queue stats and archived tasks, then retries and
+// deletes tasks through the admin API.
+func TestAdminTaskReview(t *testing.T) {
+	jobID := uuid.NewString()
+	inspector := &fakeInspector{
+		tasks: map[string]worker.TaskSummary{
+			"video": {ID: "video", Queue: "default", Type: "job:process_video", State: worker.TaskStateArchived, JobID: &jobID,
+				Retried: 3, MaxRetry: 3, LastError: "ffmpeg exited with status 1"},
+			"cleanup": {ID: "cleanup", Queue: "low", Type: "user:delete_data", State: worker.TaskStateArchived},
+			"running": {ID: "running", Queue: "default", Type: "job:generate_music", State: worker.TaskStateActive},
+		},
+		failing: "running",
+	}
+	admin, user := newTestUser(models.RoleAdmin), newTestUser(models.RoleUser)
+	router := newAdminRouter(testutil.NewFakeUserRepository(admin, user), nil, nil, inspector)
+
+	status, resp := serveAs(t, router, admin, http.MethodGet, "/api/v1/admin/queues", "")
+	if status != http.StatusOK {
+		t.Fatalf("GET /admin/queues = %d %+v", status, resp.Error)
+	}
+	var queues []worker.QueueStats
+	decodeData(t, resp, &queues)
+	if len(queues) != 2 || queues[0].Pending != 2 || queues[1].Retry != 1 {
+		t.Errorf("queues = %+v, want the inspector's stats", queues)
+	}
+
+	// Archived tasks are listed by default
+	status, resp = serveAs(t, router, admin, http.MethodGet, "/api/v1/admin/tasks?type=job:process_video&page=2&per_page=500", "")
+	if status != http.StatusOK {
+		t.Fatalf("GET /admin/tasks = %d %+v", status, resp.Error)
+	}
+	var tasks []worker.TaskSummary
+	decodeData(t, resp, &tasks)
+	if len(tasks) != 1 || tasks[0].ID != "video" || tasks[0].JobID == nil || *tasks[0].JobID != jobID || tasks[0].LastError == "" {
+		t.Errorf("tasks = %+v, want the archived process_video task with its job ID and error", tasks)
+	}
+	if want := "list queue= state=archived type=job:process_video page=2 size=100"; inspector.calls[0] != want {
+		t.Errorf("inspector called with %q, want %q", inspector.calls[0], want)
+	}
+
+	status, resp = serveAs(t, router, admin, http.MethodGet, "/api/v1/admin/tasks?state=dead", "")
+	if status != http.StatusBadRequest || resp.Error == nil || resp.Error.ErrorCode != apperrors.CodeValidationFailed {
+		t.Errorf("GET /admin/tasks?state=dead = %d %+v, want a validation error", status, resp.Error)
+	} else if resp.Error.Details["state"] == "" {
+		t.Errorf("validation details = %v, want the state field", resp.Error.Details)
+	}
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		method     string
+		path       string
+		wantStatus int
+		wantCall   string // Empty when the inspector must not be called
+	}{
+		{name: "retry", user: admin, method: http.MethodPost, path: "/api/v1/admin/tasks/video/retry?queue=default",
+			wantStatus: http.StatusNoContent, wantCall: "retry queue=default id=video"},
+		{name: "delete", user: admin, method: http.MethodDelete, path: "/api/v1/admin/tasks/cleanup",
+			wantStatus: http.StatusNoContent, wantCall: "delete queue= id=cleanup"},
+		{name: "retry an unknown task", user: admin, method: http.MethodPost, path: "/api/v1/admin/tasks/missing/retry",
+			wantStatus: http.StatusNotFound, wantCall: "retry queue= id=missing"},
+		{name: "delete a deleted task", user: admin, method: http.MethodDelete, path: "/api/v1/admin/tasks/cleanup",
+			wantStatus: http.StatusNotFound, wantCall: "delete queue= id=cleanup"},
+		{name: "delete an active task", user: admin, method: http.MethodDelete, path: "/api/v1/admin/tasks/running",
+			wantStatus: http.StatusConflict, wantCall: "delete queue= id=running"},
+		{name: "user cannot retry", user: user, method: http.MethodPost, path: "/api/v1/admin/tasks/running/retry",
+			wantStatus: http.StatusForbidden},
+		{name: "anonymous cannot delete", method: http.MethodDelete, path: "/api/v1/admin/tasks/running",
+			wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspector.calls = nil
+			status, resp := serveAs(t, router, tt.user, tt.method, tt.path, "")
+			if status != tt.wantStatus {
+				t.Errorf("%s %s = %d %+v, want %d", tt.method, tt.path, status, resp.Error, tt.wantStatus)
+			}
+			var want []string
+			if tt.wantCall != "" {
+				want = []string{tt.wantCall}
+			}
+			if !slices.Equal(inspector.calls, want) {
+				t.Errorf("inspector calls = %v, want %v", inspector.calls, want)
+			}
+		})
+	}
+}
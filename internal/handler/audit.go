@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/service"
+)
+
+// recordAudit writes an audit log entry for an action of userID, with the
+// request's client IP. metadata must not hold secrets.
+func recordAudit(c *gin.Context, audit service.AuditService, userID uuid.UUID, action, target string, metadata map[string]interface{}) {
+	audit.Log(c.Request.Context(), service.AuditEntry{
+		UserID:   &userID,
+		Action:   action,
+		Target:   target,
+		Metadata: metadata,
+		IP:       c.ClientIP(),
+	})
+}
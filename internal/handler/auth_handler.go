@@ -2,44 +2,57 @@
 package handler
 
 import (
-	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
 
+	"github.com/jaochai/ugc/internal/agents"
+	"github.com/jaochai/ugc/internal/external/kie"
 	"github.com/jaochai/ugc/internal/external/youtube"
 	"github.com/jaochai/ugc/internal/middleware"
 	"github.com/jaochai/ugc/internal/models"
 	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/security"
 	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/worker"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/logsanitize"
 	"github.com/jaochai/ugc/pkg/response"
 )
 
-// maxResponseBodySize limits the size of external API response bodies to prevent memory exhaustion
-const maxResponseBodySize = 1024 // 1KB
-
 // maxNameLength is the maximum allowed length for user names
 const maxNameLength = 100
 
+// minPasswordLength is the minimum allowed length for passwords
+const minPasswordLength = 8
+
 // maxModelLength is the maximum allowed length for model names
 const maxModelLength = 100
 
+// modelIDPattern matches OpenRouter model IDs of the form provider/model[:variant]
+var modelIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*/[A-Za-z0-9][A-Za-z0-9._:-]*$`)
+
 // LoginResponse represents the response for successful login
 type LoginResponse struct {
-	Token string              `json:"token"`
-	User  models.UserResponse `json:"user"`
+	Token        string              `json:"token"` // Access token
+	RefreshToken string              `json:"refresh_token"`
+	ExpiresAt    time.Time           `json:"expires_at"` // Access token expiry
+	User         models.UserResponse `json:"user"`
 }
 
 // RefreshResponse represents the response for token refresh
 type RefreshResponse struct {
-	Token string `json:"token"`
+	Token        string    `json:"token"` // Access token
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"` // Access token expiry
 }
 
 // AuthHandler handles authentication-related HTTP requests
@@ -48,7 +61,14 @@ type AuthHandler struct {
 	userRepo         repository.UserRepository
 	systemPromptRepo repository.SystemPromptRepository
 	cryptoService    service.CryptoService
+	keyValidator     service.APIKeyValidator
+	creditService    service.KIECreditService
+	captchaVerifier  security.CaptchaVerifier
 	youtubeClient    *youtube.Client
+	asynqClient      *asynq.Client
+	outbox           *worker.Outbox
+	audit            service.AuditService
+	settingsService  service.RuntimeSettingsService
 	frontendURL      string
 	logger           *zap.Logger
 }
@@ -59,7 +79,14 @@ func NewAuthHandler(
 	userRepo repository.UserRepository,
 	systemPromptRepo repository.SystemPromptRepository,
 	cryptoService service.CryptoService,
+	keyValidator service.APIKeyValidator,
+	creditService service.KIECreditService,
+	captchaVerifier security.CaptchaVerifier,
 	youtubeClient *youtube.Client,
+	asynqClient *asynq.Client,
+	outbox *worker.Outbox,
+	audit service.AuditService,
+	settingsService service.RuntimeSettingsService,
 	frontendURL string,
 	logger *zap.Logger,
 ) *AuthHandler {
@@ -68,31 +95,52 @@ func NewAuthHandler(
 		userRepo:         userRepo,
 		systemPromptRepo: systemPromptRepo,
 		cryptoService:    cryptoService,
+		keyValidator:     keyValidator,
+		creditService:    creditService,
+		captchaVerifier:  captchaVerifier,
 		youtubeClient:    youtubeClient,
+		asynqClient:      asynqClient,
+		outbox:           outbox,
+		audit:            audit,
+		settingsService:  settingsService,
 		frontendURL:      frontendURL,
 		logger:           logger,
 	}
 }
 
-// RegisterRoutes registers all auth routes to the given router group
-func (h *AuthHandler) RegisterRoutes(rg *gin.RouterGroup) {
+// RegisterRoutes registers all auth routes to the given router group.
+// rateLimitMiddleware (may be nil) is applied to the unauthenticated routes.
+func (h *AuthHandler) RegisterRoutes(rg *gin.RouterGroup, rateLimitMiddleware gin.HandlerFunc) {
 	auth := rg.Group("/auth")
 	{
-		auth.POST("/register", h.Register)
-		auth.POST("/login", h.Login)
-		auth.POST("/refresh", h.Refresh)
+		public := auth.Group("")
+		if rateLimitMiddleware != nil {
+			public.Use(rateLimitMiddleware)
+		}
+		public.POST("/register", h.Register)
+		public.POST("/login", h.Login)
+		public.POST("/refresh", h.Refresh)
+		public.POST("/logout", h.Logout)
+		public.POST("/forgot-password", h.ForgotPassword)
+		public.POST("/reset-password", h.ResetPassword)
 
 		// Protected routes
 		protected := auth.Group("")
 		protected.Use(middleware.AuthMiddleware(h.authService, h.logger))
 		{
 			protected.GET("/me", h.Me)
+			protected.DELETE("/me", h.DeleteAccount)
+			protected.POST("/change-password", h.ChangePassword)
 			protected.PATCH("/profile", h.UpdateProfile)
 			protected.GET("/api-keys", h.GetAPIKeysStatus)
 			protected.PUT("/api-keys", h.UpdateAPIKeys)
 			protected.DELETE("/api-keys", h.DeleteAPIKeys)
 			protected.POST("/test-openrouter", h.TestOpenRouterConnection)
 			protected.POST("/test-kie", h.TestKIEConnection)
+			protected.GET("/kie-credits", h.GetKIECredits)
+			protected.GET("/prompts", h.GetPrompts)
+			protected.PUT("/prompts", h.UpdatePrompt)
+			protected.PUT("/prompts/params", h.UpdatePromptParams)
 
 			// YouTube OAuth routes
 			protected.GET("/youtube/connect", h.YouTubeConnect)
@@ -106,7 +154,7 @@ func (h *AuthHandler) RegisterRoutes(rg *gin.RouterGroup) {
 
 // Register handles user registration
 // @Summary Register a new user
-// @Description Create a new user account
+// @Description Create a new user account. The email is stored lowercased; disposable email domains are rejected (DISPOSABLE_EMAIL) when a blocklist is configured. With Turnstile enabled, captcha_token must be a solved challenge (CAPTCHA_FAILED otherwise).
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -114,19 +162,32 @@ func (h *AuthHandler) RegisterRoutes(rg *gin.RouterGroup) {
 // @Success 201 {object} response.Response{data=models.UserResponse}
 // @Failure 400 {object} response.Response
 // @Failure 409 {object} response.Response
+// @Failure 429 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Failure 502 {object} response.Response
 // @Router /auth/register [post]
 func (h *AuthHandler) Register(c *gin.Context) {
 	var input models.CreateUserInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		h.logger.Debug("failed to bind registration input", zap.Error(err))
-		response.BadRequest(c, "invalid request body")
+		response.Error(c, apperrors.NewInvalidRequestBody())
 		return
 	}
 
 	// Validate input
 	if err := h.validateCreateUserInput(&input); err != nil {
-		response.BadRequest(c, err.Error())
+		response.Error(c, err)
+		return
+	}
+
+	// Verify the captcha when configured
+	if err := h.captchaVerifier.Verify(c.Request.Context(), input.CaptchaToken, c.ClientIP()); err != nil {
+		if errors.Is(err, security.ErrCaptchaFailed) {
+			response.Error(c, apperrors.NewBadRequest("captcha verification failed").WithCode(apperrors.CodeCaptchaFailed))
+			return
+		}
+		h.logger.Error("failed to verify captcha", zap.Error(err))
+		response.Error(c, apperrors.NewBadGateway("captcha verification is unavailable").WithCode(apperrors.CodeUpstreamUnavailable))
 		return
 	}
 
@@ -134,7 +195,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	user, err := h.authService.Register(c.Request.Context(), input)
 	if err != nil {
 		if errors.Is(err, service.ErrEmailAlreadyExists) {
-			response.BadRequest(c, "email already exists")
+			response.Error(c, err)
 			return
 		}
 		h.logger.Error("failed to register user", zap.Error(err))
@@ -144,7 +205,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	h.logger.Info("user registered successfully",
 		zap.String("user_id", user.ID.String()),
-		zap.String("email", user.Email),
+		zap.String("email", logsanitize.Email(user.Email)),
 	)
 
 	response.Created(c, user.ToResponse())
@@ -152,7 +213,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 // Login handles user authentication
 // @Summary Login user
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and return an access token and a refresh token
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -160,27 +221,40 @@ func (h *AuthHandler) Register(c *gin.Context) {
 // @Success 200 {object} response.Response{data=LoginResponse}
 // @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
+// @Failure 429 {object} response.Response "Too many requests, or the address is locked after repeated failed logins (ACCOUNT_LOCKED, with Retry-After)"
 // @Failure 500 {object} response.Response
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var input models.LoginInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		h.logger.Debug("failed to bind login input", zap.Error(err))
-		response.BadRequest(c, "invalid request body")
+		response.Error(c, apperrors.NewInvalidRequestBody())
 		return
 	}
 
 	// Validate input
 	if err := h.validateLoginInput(&input); err != nil {
-		response.BadRequest(c, err.Error())
+		response.Error(c, err)
 		return
 	}
 
 	// Call service to authenticate user
-	token, user, err := h.authService.Login(c.Request.Context(), input)
+	tokens, user, err := h.authService.Login(c.Request.Context(), input, deviceInfo(c))
 	if err != nil {
+		h.audit.Log(c.Request.Context(), service.AuditEntry{
+			Action:   models.AuditActionLoginFailure,
+			Target:   security.NormalizeEmail(input.Email),
+			Metadata: map[string]interface{}{"reason": loginFailureReason(err)},
+			IP:       c.ClientIP(),
+		})
 		if errors.Is(err, service.ErrInvalidCredentials) {
-			response.Unauthorized(c, "invalid email or password")
+			response.Error(c, err)
+			return
+		}
+		var appErr *apperrors.AppError
+		if errors.As(err, &appErr) && appErr.ErrorCode == apperrors.CodeAccountLocked {
+			c.Header("Retry-After", appErr.Details["retry_after_seconds"])
+			response.Error(c, err)
 			return
 		}
 		h.logger.Error("failed to login user", zap.Error(err))
@@ -190,51 +264,57 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	h.logger.Info("user logged in successfully",
 		zap.String("user_id", user.ID.String()),
-		zap.String("email", user.Email),
+		zap.String("email", logsanitize.Email(user.Email)),
 	)
+	recordAudit(c, h.audit, user.ID, models.AuditActionLoginSuccess, "", nil)
 
 	response.Success(c, LoginResponse{
-		Token: token,
-		User:  user.ToResponse(),
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
+		User:         user.ToResponse(),
 	})
 }
 
-// Refresh handles JWT token refresh
-// @Summary Refresh JWT token
-// @Description Refresh an existing JWT token
+// loginFailureReason names why a login failed, for the audit log.
+func loginFailureReason(err error) string {
+	var appErr *apperrors.AppError
+	switch {
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return "invalid_credentials"
+	case errors.Is(err, service.ErrAccountDisabled):
+		return "account_disabled"
+	case errors.As(err, &appErr) && appErr.ErrorCode == apperrors.CodeAccountLocked:
+		return "account_locked"
+	default:
+		return "error"
+	}
+}
+
+// Refresh exchanges a refresh token for a new token pair
+// @Summary Refresh tokens
+// @Description Exchanges a refresh token for a new access token and a new refresh token. The presented refresh token is revoked; reusing it revokes every token from the same login.
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param Authorization header string true "Bearer token"
+// @Param input body models.RefreshTokenInput true "Refresh token"
 // @Success 200 {object} response.Response{data=RefreshResponse}
+// @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /auth/refresh [post]
 func (h *AuthHandler) Refresh(c *gin.Context) {
-	// Extract token from Authorization header
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		response.Unauthorized(c, "authorization header required")
-		return
-	}
-
-	// Check Bearer token format
-	parts := strings.SplitN(authHeader, " ", 2)
-	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-		response.Unauthorized(c, "invalid authorization header format")
+	var input models.RefreshTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil || input.RefreshToken == "" {
+		response.Error(c, apperrors.NewRequiredField("refresh_token"))
 		return
 	}
 
-	tokenString := parts[1]
-
-	// Call service to refresh token
-	newToken, err := h.authService.RefreshToken(tokenString)
+	tokens, err := h.authService.RefreshToken(c.Request.Context(), input.RefreshToken, deviceInfo(c))
 	if err != nil {
-		if errors.Is(err, service.ErrInvalidToken) {
-			response.Unauthorized(c, "invalid token")
-			return
+		if !errors.Is(err, service.ErrInvalidRefreshToken) && !errors.Is(err, service.ErrAccountDeleted) {
+			h.logger.Error("failed to refresh token", zap.Error(err))
 		}
-		h.logger.Error("failed to refresh token", zap.Error(err))
 		response.Error(c, err)
 		return
 	}
@@ -242,13 +322,50 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 	h.logger.Debug("token refreshed successfully")
 
 	response.Success(c, RefreshResponse{
-		Token: newToken,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
 	})
 }
 
+// Logout revokes a refresh token
+// @Summary Logout
+// @Description Revokes the given refresh token. The access token stays valid until it expires.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.RefreshTokenInput true "Refresh token"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var input models.RefreshTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil || input.RefreshToken == "" {
+		response.Error(c, apperrors.NewRequiredField("refresh_token"))
+		return
+	}
+
+	if err := h.authService.Logout(c.Request.Context(), input.RefreshToken); err != nil {
+		h.logger.Error("failed to logout", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.NoContent(c)
+}
+
+// deviceInfo describes the requesting client for refresh token records
+func deviceInfo(c *gin.Context) service.DeviceInfo {
+	return service.DeviceInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
+
 // Me handles getting the current user's profile
 // @Summary Get current user
-// @Description Get the authenticated user's profile
+// @Description Get the authenticated user's profile. maintenance carries the admins' maintenance banner and whether new jobs are paused, and is omitted when there is none.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -262,7 +379,7 @@ func (h *AuthHandler) Me(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
@@ -270,7 +387,7 @@ func (h *AuthHandler) Me(c *gin.Context) {
 	user, err := h.authService.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
 		if errors.Is(err, service.ErrUserNotFound) {
-			response.NotFound(c, "user not found")
+			response.Error(c, err)
 			return
 		}
 		h.logger.Error("failed to get user", zap.Error(err), zap.String("user_id", userID.String()))
@@ -278,26 +395,214 @@ func (h *AuthHandler) Me(c *gin.Context) {
 		return
 	}
 
-	response.Success(c, user.ToResponse())
+	resp := user.ToResponse()
+	resp.Maintenance = h.settingsService.Notice(c.Request.Context())
+	response.Success(c, resp)
+}
+
+// DeleteAccount deletes the current user's account
+// @Summary Delete account
+// @Description Deletes the account after re-checking the password. Jobs, stored assets, API keys and the YouTube connection are removed in the background; shared job links stop working immediately.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.DeleteAccountInput true "Current password"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/me [delete]
+func (h *AuthHandler) DeleteAccount(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	var input models.DeleteAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil || input.Password == "" {
+		response.Error(c, apperrors.NewRequiredField("password"))
+		return
+	}
+
+	if err := h.authService.DeleteAccount(c.Request.Context(), userID, input.Password); err != nil {
+		if !errors.Is(err, service.ErrInvalidPassword) {
+			h.logger.Error("failed to delete account", zap.Error(err), zap.String("user_id", userID.String()))
+		}
+		response.Error(c, err)
+		return
+	}
+
+	// The account is already unusable; a failed enqueue only delays data cleanup
+	// until the DeletedUserSweeper picks the account up
+	task, err := worker.NewDeleteUserDataTask(userID, middleware.GetRequestID(c))
+	if err == nil {
+		opts := worker.DeleteUserDataTaskOptions(userID)
+		if h.outbox != nil {
+			err = h.outbox.EnqueueForUser(c.Request.Context(), task, userID, opts...)
+		} else {
+			_, err = h.asynqClient.EnqueueContext(c.Request.Context(), task, opts...)
+		}
+	}
+	if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		h.logger.Error("failed to enqueue delete user data task", zap.Error(err), zap.String("user_id", userID.String()))
+	}
+
+	h.logger.Info("account deleted", zap.String("user_id", userID.String()))
+	response.NoContent(c)
+}
+
+// ChangePassword changes the current user's password
+// @Summary Change password
+// @Description Replaces the password after verifying the current one
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.ChangePasswordInput true "Current and new password"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/change-password [post]
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	var input models.ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Debug("failed to bind change password input", zap.Error(err))
+		response.Error(c, apperrors.NewInvalidRequestBody())
+		return
+	}
+
+	if input.CurrentPassword == "" {
+		response.Error(c, apperrors.NewRequiredField("current password"))
+		return
+	}
+	if err := validateNewPassword(input.NewPassword); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	if err := h.authService.ChangePassword(c.Request.Context(), userID, input.CurrentPassword, input.NewPassword); err != nil {
+		if !errors.Is(err, service.ErrInvalidPassword) {
+			h.logger.Error("failed to change password", zap.Error(err), zap.String("user_id", userID.String()))
+		}
+		response.Error(c, err)
+		return
+	}
+
+	response.NoContent(c)
+}
+
+// ForgotPassword emails a password reset link
+// @Summary Request password reset
+// @Description Sends a single-use password reset link if the email belongs to an account. Always succeeds so registered emails cannot be probed.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.ForgotPasswordInput true "Account email"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/forgot-password [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var input models.ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil || input.Email == "" {
+		response.Error(c, apperrors.NewRequiredField("email"))
+		return
+	}
+
+	if err := h.authService.RequestPasswordReset(c.Request.Context(), input.Email); err != nil {
+		h.logger.Error("failed to request password reset", zap.Error(err))
+		response.Error(c, err)
+		return
+	}
+
+	response.NoContent(c)
+}
+
+// ResetPassword sets a new password using a reset token
+// @Summary Reset password
+// @Description Sets a new password using the token from the reset email. Each token can be used once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.ResetPasswordInput true "Reset token and new password"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/reset-password [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var input models.ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Debug("failed to bind reset password input", zap.Error(err))
+		response.Error(c, apperrors.NewInvalidRequestBody())
+		return
+	}
+
+	if input.Token == "" {
+		response.Error(c, apperrors.NewRequiredField("token"))
+		return
+	}
+	if err := validateNewPassword(input.NewPassword); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	if err := h.authService.ResetPassword(c.Request.Context(), input.Token, input.NewPassword); err != nil {
+		if !errors.Is(err, service.ErrInvalidResetToken) {
+			h.logger.Error("failed to reset password", zap.Error(err))
+		}
+		response.Error(c, err)
+		return
+	}
+
+	response.NoContent(c)
+}
+
+// validateNewPassword applies the registration password rules to a new password
+func validateNewPassword(password string) error {
+	if password == "" {
+		return apperrors.NewRequiredField("new password")
+	}
+
+	if len(password) < minPasswordLength {
+		return errPasswordTooShort()
+	}
+
+	return nil
+}
+
+// errPasswordTooShort is returned for a password shorter than minPasswordLength.
+func errPasswordTooShort() error {
+	return apperrors.NewBadRequest(fmt.Sprintf("password must be at least %d characters", minPasswordLength)).
+		WithCode(apperrors.CodePasswordTooShort).
+		WithParams(map[string]string{"min": strconv.Itoa(minPasswordLength)})
 }
 
 // validateCreateUserInput validates the user registration input
 func (h *AuthHandler) validateCreateUserInput(input *models.CreateUserInput) error {
+	input.Email = security.NormalizeEmail(input.Email)
 	if input.Email == "" {
-		return errors.New("email is required")
+		return apperrors.NewRequiredField("email")
 	}
 
-	// Basic email format validation
-	if !strings.Contains(input.Email, "@") || !strings.Contains(input.Email, ".") {
-		return errors.New("invalid email format")
+	if err := security.ValidateEmail(input.Email); err != nil {
+		return apperrors.NewBadRequest(err.Error()).WithCode(apperrors.CodeInvalidEmail)
 	}
 
 	if input.Password == "" {
-		return errors.New("password is required")
+		return apperrors.NewRequiredField("password")
 	}
 
-	if len(input.Password) < 8 {
-		return errors.New("password must be at least 8 characters")
+	if len(input.Password) < minPasswordLength {
+		return errPasswordTooShort()
 	}
 
 	return nil
@@ -306,11 +611,11 @@ func (h *AuthHandler) validateCreateUserInput(input *models.CreateUserInput) err
 // validateLoginInput validates the login input
 func (h *AuthHandler) validateLoginInput(input *models.LoginInput) error {
 	if input.Email == "" {
-		return errors.New("email is required")
+		return apperrors.NewRequiredField("email")
 	}
 
 	if input.Password == "" {
-		return errors.New("password is required")
+		return apperrors.NewRequiredField("password")
 	}
 
 	return nil
@@ -329,58 +634,24 @@ func (h *AuthHandler) validateLoginInput(input *models.LoginInput) error {
 func (h *AuthHandler) GetAPIKeysStatus(c *gin.Context) {
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
-	openRouterKey, kieKey, err := h.userRepo.GetAPIKeys(c.Request.Context(), userID)
+	// Presence only: keys are never stored empty, so there is nothing to decrypt
+	status, err := h.userRepo.GetAPIKeysStatus(c.Request.Context(), userID)
 	if err != nil {
 		h.logger.Error("failed to get API keys status", zap.Error(err), zap.String("user_id", userID.String()))
 		response.Error(c, err)
 		return
 	}
 
-	// Decrypt and check if keys exist (only check non-empty after decryption)
-	hasOpenRouterKey := false
-	hasKIEKey := false
-
-	if openRouterKey != nil && *openRouterKey != "" {
-		decrypted, err := h.cryptoService.Decrypt(*openRouterKey)
-		if err != nil {
-			h.logger.Warn("failed to decrypt OpenRouter API key", zap.Error(err), zap.String("user_id", userID.String()))
-		} else if decrypted != "" {
-			hasOpenRouterKey = true
-		}
-	}
-
-	if kieKey != nil && *kieKey != "" {
-		decrypted, err := h.cryptoService.Decrypt(*kieKey)
-		if err != nil {
-			h.logger.Warn("failed to decrypt KIE API key", zap.Error(err), zap.String("user_id", userID.String()))
-		} else if decrypted != "" {
-			hasKIEKey = true
-		}
-	}
-
-	// Check YouTube connection
-	hasYouTube := false
-	ytToken, err := h.userRepo.GetYouTubeToken(c.Request.Context(), userID)
-	if err != nil {
-		h.logger.Warn("failed to check YouTube token", zap.Error(err), zap.String("user_id", userID.String()))
-	} else if ytToken != nil && *ytToken != "" {
-		hasYouTube = true
-	}
-
-	response.Success(c, models.APIKeysStatusResponse{
-		HasOpenRouterKey: hasOpenRouterKey,
-		HasKIEKey:        hasKIEKey,
-		HasYouTube:       hasYouTube,
-	})
+	response.Success(c, status)
 }
 
 // UpdateAPIKeys updates the user's API keys
 // @Summary Update API keys
-// @Description Updates the user's API keys (encrypted at rest)
+// @Description Updates the user's API keys (encrypted at rest). With validate=true, each new key is checked against its provider first; keys that fail are not saved and a 400 lists the result per key. If a provider could not verify a key, nothing is saved and a 503 lists the result per key
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -390,17 +661,18 @@ func (h *AuthHandler) GetAPIKeysStatus(c *gin.Context) {
 // @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Failure 503 {object} response.Response
 // @Router /auth/api-keys [put]
 func (h *AuthHandler) UpdateAPIKeys(c *gin.Context) {
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
 	var input models.UpdateAPIKeysInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		response.BadRequest(c, "invalid request body")
+		response.Error(c, apperrors.NewInvalidRequestBody())
 		return
 	}
 
@@ -412,6 +684,24 @@ func (h *AuthHandler) UpdateAPIKeys(c *gin.Context) {
 		return
 	}
 
+	// Check new keys against their providers before saving them
+	var results map[string]service.KeyCheckResult
+	if input.Validate {
+		results = h.checkNewAPIKeys(c, &input)
+		if details := unverifiedKeyChecks(results); len(details) > 0 {
+			response.Error(c, apperrors.NewServiceUnavailable("could not verify API keys; nothing was saved, try again later").
+				WithCode(apperrors.CodeUpstreamUnavailable).
+				WithDetails(details))
+			return
+		}
+		if r, ok := results["openrouter_api_key"]; ok && !r.Valid {
+			input.OpenRouterAPIKey = nil
+		}
+		if r, ok := results["kie_api_key"]; ok && !r.Valid {
+			input.KIEAPIKey = nil
+		}
+	}
+
 	// Encrypt new keys if provided, otherwise keep existing
 	var encryptedOpenRouterKey, encryptedKIEKey *string
 
@@ -451,6 +741,19 @@ func (h *AuthHandler) UpdateAPIKeys(c *gin.Context) {
 	}
 
 	h.logger.Info("API keys updated", zap.String("user_id", userID.String()))
+	recordAudit(c, h.audit, userID, models.AuditActionAPIKeysUpdate, "", map[string]interface{}{
+		"openrouter_key_changed": input.OpenRouterAPIKey != nil,
+		"kie_key_changed":        input.KIEAPIKey != nil,
+		"openrouter_key_set":     encryptedOpenRouterKey != nil && *encryptedOpenRouterKey != "",
+		"kie_key_set":            encryptedKIEKey != nil && *encryptedKIEKey != "",
+	})
+
+	if details := failedKeyChecks(results); len(details) > 0 {
+		response.Error(c, apperrors.NewBadRequest("API key validation failed; failing keys were not saved").
+			WithCode(apperrors.CodeInvalidAPIKey).
+			WithDetails(details))
+		return
+	}
 
 	// Return updated status
 	response.Success(c, models.APIKeysStatusResponse{
@@ -459,6 +762,55 @@ func (h *AuthHandler) UpdateAPIKeys(c *gin.Context) {
 	})
 }
 
+// checkNewAPIKeys checks each non-empty key in input against its provider,
+// keyed by the input's JSON field name.
+func (h *AuthHandler) checkNewAPIKeys(c *gin.Context, input *models.UpdateAPIKeysInput) map[string]service.KeyCheckResult {
+	results := make(map[string]service.KeyCheckResult)
+	ctx := c.Request.Context()
+
+	if input.OpenRouterAPIKey != nil && *input.OpenRouterAPIKey != "" {
+		results["openrouter_api_key"] = h.keyValidator.CheckOpenRouterKey(ctx, *input.OpenRouterAPIKey)
+	}
+	if input.KIEAPIKey != nil && *input.KIEAPIKey != "" {
+		results["kie_api_key"] = h.keyValidator.CheckKIEKey(ctx, *input.KIEAPIKey)
+	}
+
+	return results
+}
+
+// failedKeyChecks returns the result message of every checked key if any check failed, or nil.
+func failedKeyChecks(results map[string]service.KeyCheckResult) map[string]string {
+	failed := false
+	details := make(map[string]string, len(results))
+	for field, r := range results {
+		details[field] = r.Message
+		if !r.Valid {
+			failed = true
+		}
+	}
+	if !failed {
+		return nil
+	}
+	return details
+}
+
+// unverifiedKeyChecks returns the result message of every checked key if any
+// key could not be verified, or nil.
+func unverifiedKeyChecks(results map[string]service.KeyCheckResult) map[string]string {
+	unverified := false
+	details := make(map[string]string, len(results))
+	for field, r := range results {
+		details[field] = r.Message
+		if r.Retryable {
+			unverified = true
+		}
+	}
+	if !unverified {
+		return nil
+	}
+	return details
+}
+
 // DeleteAPIKeys removes all API keys for the user
 // @Summary Delete API keys
 // @Description Removes all API keys for the user
@@ -472,7 +824,7 @@ func (h *AuthHandler) UpdateAPIKeys(c *gin.Context) {
 func (h *AuthHandler) DeleteAPIKeys(c *gin.Context) {
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
@@ -483,12 +835,13 @@ func (h *AuthHandler) DeleteAPIKeys(c *gin.Context) {
 	}
 
 	h.logger.Info("API keys deleted", zap.String("user_id", userID.String()))
+	recordAudit(c, h.audit, userID, models.AuditActionAPIKeysDelete, "", nil)
 	response.NoContent(c)
 }
 
-// UpdateProfile updates the user's profile (name, openrouter_model)
+// UpdateProfile updates the user's profile (name, default and per-agent models, email notifications, job defaults)
 // @Summary Update user profile
-// @Description Updates the user's profile settings
+// @Description Updates the user's profile settings. default_suno_model (V3_5, V4, V4_5, V4_5PLUS or V5) is used for jobs that don't set suno_model; an empty string restores V5. locale ("en" or "th") sets the language of API error messages; an empty string falls back to Accept-Language. job_defaults (image_candidates, aspect_ratio, video_options) replaces the settings used for new jobs that leave them unset, after the job's template; they are validated like the same job fields, and {} clears them.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -502,23 +855,54 @@ func (h *AuthHandler) DeleteAPIKeys(c *gin.Context) {
 func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
 	var input models.UpdateUserInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		response.BadRequest(c, "invalid request body")
+		response.Error(c, apperrors.NewInvalidRequestBody())
 		return
 	}
 
 	// Validate input
 	if input.Name != nil && len(*input.Name) > maxNameLength {
-		response.BadRequest(c, "name must be 100 characters or less")
+		response.Error(c, apperrors.NewBadRequest(fmt.Sprintf("name must be %d characters or less", maxNameLength)).
+			WithCode(apperrors.CodeNameTooLong).
+			WithParams(map[string]string{"max": strconv.Itoa(maxNameLength)}))
 		return
 	}
-	if input.OpenRouterModel != nil && len(*input.OpenRouterModel) > maxModelLength {
-		response.BadRequest(c, "model name must be 100 characters or less")
+	modelFields := map[string]*string{
+		"openrouter_model":    input.OpenRouterModel,
+		"song_concept_model":  input.SongConceptModel,
+		"song_selector_model": input.SongSelectorModel,
+		"image_concept_model": input.ImageConceptModel,
+	}
+	for field, model := range modelFields {
+		if err := validateModelID(model); err != nil {
+			response.ValidationError(c, map[string]string{field: err.Error()})
+			return
+		}
+	}
+	if input.DefaultSunoModel != nil && *input.DefaultSunoModel != "" && !kie.IsValidModel(*input.DefaultSunoModel) {
+		allowed := strings.Join(kie.Models, ", ")
+		response.Error(c, apperrors.NewFieldError("default_suno_model", apperrors.FieldSunoModelInvalid,
+			"default_suno_model must be one of "+allowed).
+			WithParams(map[string]string{"allowed": allowed}))
+		return
+	}
+	if input.Locale != nil && *input.Locale != "" && !response.IsSupportedLocale(*input.Locale) {
+		response.Error(c, apperrors.NewBadRequest("locale must be one of en, th").WithCode(apperrors.CodeUnsupportedLocale))
+		return
+	}
+	if input.JobDefaults != nil {
+		if err := validateJobSettings(input.JobDefaults.Apply(models.CreateJobInput{})); err != nil {
+			response.Error(c, err)
+			return
+		}
+	}
+	if err := validateTokenLimit(input.OpenRouterMonthlyTokenLimit); err != nil {
+		response.Error(c, err)
 		return
 	}
 
@@ -537,6 +921,30 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	if input.OpenRouterModel != nil {
 		user.OpenRouterModel = *input.OpenRouterModel
 	}
+	if input.SongConceptModel != nil {
+		user.SongConceptModel = optionalString(*input.SongConceptModel)
+	}
+	if input.SongSelectorModel != nil {
+		user.SongSelectorModel = optionalString(*input.SongSelectorModel)
+	}
+	if input.ImageConceptModel != nil {
+		user.ImageConceptModel = optionalString(*input.ImageConceptModel)
+	}
+	if input.DefaultSunoModel != nil {
+		user.DefaultSunoModel = optionalString(*input.DefaultSunoModel)
+	}
+	if input.NotifyEmail != nil {
+		user.NotifyEmail = *input.NotifyEmail
+	}
+	if input.Locale != nil {
+		user.Locale = optionalString(*input.Locale)
+	}
+	if input.JobDefaults != nil {
+		user.JobDefaults = *input.JobDefaults
+	}
+	if input.OpenRouterMonthlyTokenLimit != nil {
+		user.OpenRouterMonthlyTokenLimit = optionalTokenLimit(*input.OpenRouterMonthlyTokenLimit)
+	}
 
 	// Save to database
 	if err := h.userRepo.Update(c.Request.Context(), user); err != nil {
@@ -546,13 +954,84 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	}
 
 	h.logger.Info("user profile updated", zap.String("user_id", userID.String()))
+	recordAudit(c, h.audit, userID, models.AuditActionProfileUpdate, "", map[string]interface{}{
+		"fields": updatedProfileFields(input),
+	})
 	response.Success(c, user.ToResponse())
 }
 
+// updatedProfileFields lists the JSON names of the profile fields set in input.
+func updatedProfileFields(input models.UpdateUserInput) []string {
+	fields := make([]string, 0)
+	set := []struct {
+		name string
+		ok   bool
+	}{
+		{"name", input.Name != nil},
+		{"openrouter_model", input.OpenRouterModel != nil},
+		{"song_concept_model", input.SongConceptModel != nil},
+		{"song_selector_model", input.SongSelectorModel != nil},
+		{"image_concept_model", input.ImageConceptModel != nil},
+		{"default_suno_model", input.DefaultSunoModel != nil},
+		{"notify_email", input.NotifyEmail != nil},
+		{"locale", input.Locale != nil},
+		{"job_defaults", input.JobDefaults != nil},
+		{"openrouter_monthly_token_limit", input.OpenRouterMonthlyTokenLimit != nil},
+	}
+	for _, f := range set {
+		if f.ok {
+			fields = append(fields, f.name)
+		}
+	}
+	return fields
+}
+
+// validateTokenLimit rejects a negative monthly OpenRouter token budget. nil
+// leaves the budget unchanged and 0 removes it.
+func validateTokenLimit(limit *int64) error {
+	if limit == nil || *limit >= 0 {
+		return nil
+	}
+	return apperrors.NewFieldError("openrouter_monthly_token_limit", apperrors.FieldTokenLimitInvalid,
+		"openrouter_monthly_token_limit must not be negative (0 removes the budget)")
+}
+
+// optionalTokenLimit maps a submitted token budget to its stored value: 0 means no budget.
+func optionalTokenLimit(limit int64) *int64 {
+	if limit == 0 {
+		return nil
+	}
+	return &limit
+}
+
+// validateModelID checks an optional model ID's length and provider/model format.
+// nil and empty values are valid; empty clears the setting.
+func validateModelID(model *string) error {
+	if model == nil || *model == "" {
+		return nil
+	}
+	if len(*model) > maxModelLength {
+		return fmt.Errorf("model name must be %d characters or less", maxModelLength)
+	}
+	if !modelIDPattern.MatchString(*model) {
+		return errors.New("model must have the form provider/model, e.g. anthropic/claude-3.5-sonnet")
+	}
+	return nil
+}
+
+// optionalString returns nil for an empty string, otherwise a pointer to s.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
 // TestConnectionResponse represents the response for API connection tests
 type TestConnectionResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
+	Success   bool   `json:"success"`
+	Retryable bool   `json:"retryable"` // The provider could not verify the key; test again later
+	Message   string `json:"message"`
 }
 
 // TestOpenRouterConnection tests the OpenRouter API connection with user's API key
@@ -569,7 +1048,7 @@ type TestConnectionResponse struct {
 func (h *AuthHandler) TestOpenRouterConnection(c *gin.Context) {
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
@@ -582,7 +1061,7 @@ func (h *AuthHandler) TestOpenRouterConnection(c *gin.Context) {
 	}
 
 	if openRouterKey == nil || *openRouterKey == "" {
-		response.BadRequest(c, "OpenRouter API key not configured")
+		response.Error(c, apperrors.NewBadRequest("OpenRouter API key not configured").WithCode(apperrors.CodeMissingOpenRouterKey))
 		return
 	}
 
@@ -595,16 +1074,17 @@ func (h *AuthHandler) TestOpenRouterConnection(c *gin.Context) {
 	}
 
 	// Test the connection by making a simple request to OpenRouter
-	success, message := testOpenRouterAPI(c.Request.Context(), decryptedKey, h.logger)
+	result := h.keyValidator.CheckOpenRouterKey(c.Request.Context(), decryptedKey)
 
 	h.logger.Info("OpenRouter connection test",
 		zap.String("user_id", userID.String()),
-		zap.Bool("success", success),
+		zap.Bool("success", result.Valid),
 	)
 
 	response.Success(c, TestConnectionResponse{
-		Success: success,
-		Message: message,
+		Success:   result.Valid,
+		Retryable: result.Retryable,
+		Message:   result.Message,
 	})
 }
 
@@ -622,7 +1102,7 @@ func (h *AuthHandler) TestOpenRouterConnection(c *gin.Context) {
 func (h *AuthHandler) TestKIEConnection(c *gin.Context) {
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
@@ -635,7 +1115,7 @@ func (h *AuthHandler) TestKIEConnection(c *gin.Context) {
 	}
 
 	if kieKey == nil || *kieKey == "" {
-		response.BadRequest(c, "KIE API key not configured")
+		response.Error(c, apperrors.NewBadRequest("KIE API key not configured").WithCode(apperrors.CodeMissingKIEKey))
 		return
 	}
 
@@ -648,129 +1128,68 @@ func (h *AuthHandler) TestKIEConnection(c *gin.Context) {
 	}
 
 	// Test the connection by making a simple request to KIE
-	success, message := testKIEAPI(c.Request.Context(), decryptedKey, h.logger)
+	result := h.keyValidator.CheckKIEKey(c.Request.Context(), decryptedKey)
 
 	h.logger.Info("KIE connection test",
 		zap.String("user_id", userID.String()),
-		zap.Bool("success", success),
+		zap.Bool("success", result.Valid),
 	)
 
 	response.Success(c, TestConnectionResponse{
-		Success: success,
-		Message: message,
+		Success:   result.Valid,
+		Retryable: result.Retryable,
+		Message:   result.Message,
 	})
 }
 
-// testOpenRouterAPI tests the OpenRouter API connection
-func testOpenRouterAPI(ctx context.Context, apiKey string, logger *zap.Logger) (bool, string) {
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	// Make a simple request to list models (lightweight endpoint)
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://openrouter.ai/api/v1/models", nil)
-	if err != nil {
-		logger.Error("failed to create OpenRouter request", zap.Error(err))
-		return false, "Failed to create request"
-	}
-
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		logger.Error("OpenRouter connection failed", zap.Error(err))
-		return false, "Connection failed. Please check your network and try again."
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusUnauthorized {
-		return false, "Invalid API key"
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
-		logger.Error("OpenRouter API error",
-			zap.Int("status_code", resp.StatusCode),
-			zap.String("body", string(body)))
-		return false, "API returned an error. Please try again later."
+// GetKIECredits returns the credit balance of the user's KIE account
+// @Summary Get KIE credit balance
+// @Description Returns the credit balance of the user's KIE account, cached for a few minutes. low is true below the server's warning threshold; job creation is rejected with 402 at zero credits.
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=service.KIECredits}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Failure 502 {object} response.Response
+// @Router /auth/kie-credits [get]
+func (h *AuthHandler) GetKIECredits(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
 	}
 
-	return true, "Connection successful"
-}
-
-// kieCreditsResponse represents the response from KIE credits endpoint
-type kieCreditsResponse struct {
-	Code int    `json:"code"`
-	Msg  string `json:"msg"`
-	Data int    `json:"data"`
-}
-
-// testKIEAPI tests the KIE API connection using the credits endpoint
-// API docs: https://docs.kie.ai/common-api/get-account-credits
-func testKIEAPI(ctx context.Context, apiKey string, logger *zap.Logger) (bool, string) {
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	// Use GET /api/v1/chat/credit endpoint per KIE docs
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.kie.ai/api/v1/chat/credit", nil)
+	user, err := h.userRepo.GetByID(c.Request.Context(), userID)
 	if err != nil {
-		logger.Error("failed to create KIE request", zap.Error(err))
-		return false, "Failed to create request"
+		h.logger.Error("failed to get user", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, err)
+		return
 	}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-
-	resp, err := client.Do(req)
+	credits, err := h.creditService.GetCredits(c.Request.Context(), user)
 	if err != nil {
-		logger.Error("KIE connection failed", zap.Error(err))
-		return false, "Connection failed. Please check your network and try again."
+		h.logger.Warn("failed to get KIE credits", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, err)
+		return
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
-
-	// Handle specific error codes per KIE API docs
-	switch resp.StatusCode {
-	case http.StatusOK:
-		var creditsResp kieCreditsResponse
-		if err := json.Unmarshal(body, &creditsResp); err != nil {
-			logger.Warn("failed to parse KIE credits response", zap.Error(err))
-			return true, "Connection successful"
-		}
-		if creditsResp.Code == 200 {
-			return true, fmt.Sprintf("Connection successful. Credits: %d", creditsResp.Data)
-		}
-		return true, "Connection successful"
-
-	case http.StatusUnauthorized: // 401
-		return false, "Invalid API key"
-
-	case http.StatusPaymentRequired: // 402 - Insufficient Credits
-		return false, "Insufficient credits in your KIE account"
-
-	case http.StatusUnprocessableEntity: // 422
-		return false, "Validation error. Please check your API key format."
-
-	case http.StatusTooManyRequests: // 429
-		return false, "Rate limited. Please try again later."
-
-	case 455: // KIE-specific: Service Unavailable
-		return false, "KIE service temporarily unavailable. Please try again later."
 
-	case http.StatusInternalServerError: // 500
-		return false, "KIE server error. Please try again later."
-
-	case 505: // KIE-specific: Feature Disabled
-		return false, "This feature is disabled for your account"
-
-	default:
-		logger.Error("KIE API error",
-			zap.Int("status_code", resp.StatusCode),
-			zap.String("body", string(body)))
-		return false, fmt.Sprintf("API error (status %d). Please try again later.", resp.StatusCode)
-	}
+	response.Success(c, credits)
 }
 
 // YouTubeConnect initiates the YouTube OAuth2 flow.
 // Returns a URL the frontend should redirect the user to.
+// @Summary Start connecting YouTube
+// @Description Returns the Google consent URL to send the user to. Google redirects back to /auth/youtube/callback.
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=map[string]string} "auth_url"
+// @Failure 400 {object} response.Response "YouTube integration is not configured"
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/youtube/connect [get]
 func (h *AuthHandler) YouTubeConnect(c *gin.Context) {
 	if h.youtubeClient == nil {
 		response.BadRequest(c, "YouTube integration is not configured")
@@ -779,12 +1198,12 @@ func (h *AuthHandler) YouTubeConnect(c *gin.Context) {
 
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
 	// Generate a short-lived JWT as the OAuth state parameter (CSRF protection)
-	state, err := h.authService.GenerateShortToken(userID, 10*time.Minute)
+	state, err := h.authService.GenerateShortToken(userID, service.TokenPurposeOAuthState, 10*time.Minute)
 	if err != nil {
 		h.logger.Error("failed to generate OAuth state token", zap.Error(err))
 		response.Error(c, errors.New("failed to initiate YouTube connection"))
@@ -808,6 +1227,14 @@ func (h *AuthHandler) settingsRedirect(query string) string {
 
 // YouTubeCallback handles the OAuth2 callback from Google.
 // Exchanges the authorization code for a refresh token, encrypts it, and saves to DB.
+// @Summary YouTube OAuth callback
+// @Description Google redirects here after consent. Stores the encrypted refresh token and redirects to the frontend settings page with youtube=connected or youtube=error&reason=...
+// @Tags auth
+// @Param code query string false "Authorization code"
+// @Param state query string false "OAuth state issued by /auth/youtube/connect"
+// @Param error query string false "OAuth error from Google"
+// @Success 302 "Redirect to the frontend settings page"
+// @Router /auth/youtube/callback [get]
 func (h *AuthHandler) YouTubeCallback(c *gin.Context) {
 	if h.youtubeClient == nil {
 		c.Redirect(http.StatusFound, h.settingsRedirect("youtube=error&reason=not_configured"))
@@ -830,7 +1257,7 @@ func (h *AuthHandler) YouTubeCallback(c *gin.Context) {
 	}
 
 	// Validate the state parameter (JWT) to extract userID and prevent CSRF
-	userID, err := h.authService.ValidateShortToken(state)
+	userID, err := h.authService.ValidateShortToken(state, service.TokenPurposeOAuthState)
 	if err != nil {
 		h.logger.Warn("invalid YouTube OAuth state", zap.Error(err))
 		c.Redirect(http.StatusFound, h.settingsRedirect("youtube=error&reason=invalid_state"))
@@ -861,10 +1288,20 @@ func (h *AuthHandler) YouTubeCallback(c *gin.Context) {
 	}
 
 	h.logger.Info("YouTube connected successfully", zap.String("user_id", userID.String()))
+	recordAudit(c, h.audit, userID, models.AuditActionYouTubeConnect, "", nil)
 	c.Redirect(http.StatusFound, h.settingsRedirect("youtube=connected"))
 }
 
 // YouTubeDisconnect revokes the YouTube OAuth token and removes it from DB.
+// @Summary Disconnect YouTube
+// @Description Revokes the stored YouTube token with Google and removes it
+// @Tags auth
+// @Security BearerAuth
+// @Success 204 "Disconnected"
+// @Failure 400 {object} response.Response "YouTube integration is not configured"
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/youtube [delete]
 func (h *AuthHandler) YouTubeDisconnect(c *gin.Context) {
 	if h.youtubeClient == nil {
 		response.BadRequest(c, "YouTube integration is not configured")
@@ -873,7 +1310,7 @@ func (h *AuthHandler) YouTubeDisconnect(c *gin.Context) {
 
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
@@ -905,9 +1342,193 @@ func (h *AuthHandler) YouTubeDisconnect(c *gin.Context) {
 	}
 
 	h.logger.Info("YouTube disconnected", zap.String("user_id", userID.String()))
+	recordAudit(c, h.audit, userID, models.AuditActionYouTubeDisconnect, "", nil)
 	response.NoContent(c)
 }
 
-// maxPromptLength is the maximum allowed length for custom prompts
-const maxPromptLength = 10000
+// GetPrompts returns the user's custom agent prompts and the defaults they override
+// @Summary Get agent prompts
+// @Description Returns the user's custom system prompt per agent (null when unset) and the default each one replaces, plus the user's temperature/max_tokens overrides and the agents' default parameters
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=models.AgentPromptsResponse}
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/prompts [get]
+func (h *AuthHandler) GetPrompts(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	prompts, err := h.userRepo.GetPrompts(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to get prompts", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, err)
+		return
+	}
+
+	params, err := h.userRepo.GetAgentParams(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to get agent params", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, models.AgentPromptsResponse{
+		Prompts: *prompts,
+		Defaults: models.AgentDefaultPrompts{
+			SongConcept:   h.defaultPrompt(c, models.PromptTypeSongConcept),
+			SongSelector:  h.defaultPrompt(c, models.PromptTypeSongSelector),
+			ImageConcept:  h.defaultPrompt(c, models.PromptTypeImageConcept),
+			ImageSelector: h.defaultPrompt(c, models.PromptTypeImageSelector),
+		},
+		Params: params,
+		DefaultParams: map[string]models.GenerationParams{
+			models.PromptTypeSongConcept:   agents.DefaultGenerationParams(models.PromptTypeSongConcept),
+			models.PromptTypeSongSelector:  agents.DefaultGenerationParams(models.PromptTypeSongSelector),
+			models.PromptTypeImageConcept:  agents.DefaultGenerationParams(models.PromptTypeImageConcept),
+			models.PromptTypeImageSelector: agents.DefaultGenerationParams(models.PromptTypeImageSelector),
+		},
+	})
+}
+
+// defaultPrompt returns the system prompt for promptType, or the hardcoded default if none is stored.
+func (h *AuthHandler) defaultPrompt(c *gin.Context, promptType string) string {
+	systemPrompt, err := h.systemPromptRepo.GetByType(c.Request.Context(), promptType)
+	if err != nil {
+		return agents.DefaultPrompt(promptType)
+	}
+	return systemPrompt.PromptContent
+}
+
+// UpdatePrompt sets or resets the user's custom prompt for one agent
+// @Summary Update agent prompt
+// @Description Sets the user's custom system prompt for an agent. A null or empty prompt resets it to the default
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.UpdateAgentPromptInput true "Agent type and prompt"
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=models.AgentPrompts}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/prompts [put]
+func (h *AuthHandler) UpdatePrompt(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	var input models.UpdateAgentPromptInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, apperrors.NewInvalidRequestBody())
+		return
+	}
+
+	if !models.IsValidPromptType(input.AgentType) {
+		response.ValidationError(c, map[string]string{"agent_type": "must be one of song_concept, song_selector, image_concept, image_selector"})
+		return
+	}
+
+	prompt := input.Prompt
+	if prompt != nil && strings.TrimSpace(*prompt) == "" {
+		prompt = nil
+	}
+	if prompt != nil {
+		if err := agents.ValidateCustomPrompt(*prompt); err != nil {
+			response.ValidationError(c, map[string]string{"prompt": err.Error()})
+			return
+		}
+	}
+
+	if err := h.userRepo.UpdatePrompt(c.Request.Context(), userID, input.AgentType, prompt); err != nil {
+		h.logger.Error("failed to update prompt", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, err)
+		return
+	}
+
+	h.logger.Info("agent prompt updated",
+		zap.String("user_id", userID.String()),
+		zap.String("agent_type", input.AgentType),
+		zap.Bool("reset", prompt == nil),
+	)
+	action := models.AuditActionPromptUpdate
+	if prompt == nil {
+		action = models.AuditActionPromptReset
+	}
+	recordAudit(c, h.audit, userID, action, input.AgentType, nil)
+
+	prompts, err := h.userRepo.GetPrompts(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to get prompts", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, prompts)
+}
+
+// UpdatePromptParams sets or resets the user's generation parameters for one agent
+// @Summary Update agent generation parameters
+// @Description Overrides the temperature (0-2) and max_tokens (1-8000) of an agent's LLM requests. Omitted fields use the agent's default; omitting both resets the agent to its defaults
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.UpdateAgentParamsInput true "Agent type and parameters"
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=map[string]models.GenerationParams}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/prompts/params [put]
+func (h *AuthHandler) UpdatePromptParams(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	var input models.UpdateAgentParamsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, apperrors.NewInvalidRequestBody())
+		return
+	}
+
+	if !models.IsValidPromptType(input.AgentType) {
+		response.ValidationError(c, map[string]string{"agent_type": "must be one of song_concept, song_selector, image_concept, image_selector"})
+		return
+	}
+
+	params := models.GenerationParams{Temperature: input.Temperature, MaxTokens: input.MaxTokens}
+	if details := agents.ValidateGenerationParams(params); details != nil {
+		response.ValidationError(c, details)
+		return
+	}
+
+	if err := h.userRepo.UpdateAgentParams(c.Request.Context(), userID, input.AgentType, params); err != nil {
+		h.logger.Error("failed to update agent params", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, err)
+		return
+	}
+
+	h.logger.Info("agent params updated",
+		zap.String("user_id", userID.String()),
+		zap.String("agent_type", input.AgentType),
+		zap.Bool("reset", params.IsEmpty()),
+	)
+
+	overrides, err := h.userRepo.GetAgentParams(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to get agent params", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, overrides)
+}
 
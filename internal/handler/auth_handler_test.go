@@ -0,0 +1,118 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/handler"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/security"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/testutil"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+)
+
+// stubCaptcha returns err for every token and records the tokens it was asked to verify.
+type stubCaptcha struct {
+	err    error
+	tokens []string
+}
+
+func (s *stubCaptcha) Verify(ctx context.Context, token, remoteIP string) error {
+	s.tokens = append(s.tokens, token)
+	return s.err
+}
+
+func (s *stubCaptcha) Enabled() bool { return true }
+
+// TestRegister checks the registration path: the email is validated and
+// lowercased, the captcha is verified before the account is created, and a
+// rejected token is told apart from an unreachable captcha provider.
+func TestRegister(t *testing.T) {
+	existing := &models.User{ID: uuid.New(), Email: "taken@example.com", Role: models.RoleUser}
+
+	tests := []struct {
+		name       string
+		captcha    security.CaptchaVerifier
+		body       string
+		wantStatus int
+		wantCode   string // Error code, empty when the account is created
+		wantEmail  string
+		wantTokens []string // Tokens the stub captcha was asked to verify
+	}{
+		{name: "captcha not configured", captcha: security.NewCaptchaVerifier(""),
+			body:       `{"email": " New.User@Example.COM ", "password": "correct horse battery staple"}`,
+			wantStatus: http.StatusCreated, wantEmail: "new.user@example.com"},
+		{name: "solved captcha", captcha: &stubCaptcha{},
+			body:       `{"email": "solved@example.com", "password": "correct horse battery staple", "captcha_token": "solved-token"}`,
+			wantStatus: http.StatusCreated, wantEmail: "solved@example.com", wantTokens: []string{"solved-token"}},
+		{name: "rejected captcha", captcha: &stubCaptcha{err: fmt.Errorf("%w: timeout-or-duplicate", security.ErrCaptchaFailed)},
+			body:       `{"email": "bot@example.com", "password": "correct horse battery staple", "captcha_token": "reused-token"}`,
+			wantStatus: http.StatusBadRequest, wantCode: apperrors.CodeCaptchaFailed, wantTokens: []string{"reused-token"}},
+		{name: "captcha provider unavailable", captcha: &stubCaptcha{err: errors.New("captcha request failed: connection refused")},
+			body:       `{"email": "user@example.com", "password": "correct horse battery staple", "captcha_token": "solved-token"}`,
+			wantStatus: http.StatusBadGateway, wantCode: apperrors.CodeUpstreamUnavailable, wantTokens: []string{"solved-token"}},
+		{name: "invalid email before the captcha", captcha: &stubCaptcha{},
+			body:       `{"email": "User <user@example.com>", "password": "correct horse battery staple", "captcha_token": "solved-token"}`,
+			wantStatus: http.StatusBadRequest, wantCode: apperrors.CodeInvalidEmail},
+		{name: "disposable email", captcha: &stubCaptcha{},
+			body:       `{"email": "someone@mailinator.com", "password": "correct horse battery staple", "captcha_token": "solved-token"}`,
+			wantStatus: http.StatusBadRequest, wantCode: apperrors.CodeDisposableEmail, wantTokens: []string{"solved-token"}},
+		{name: "existing email in another case", captcha: &stubCaptcha{},
+			body:       `{"email": "Taken@Example.com", "password": "correct horse battery staple", "captcha_token": "solved-token"}`,
+			wantStatus: http.StatusBadRequest, wantCode: apperrors.CodeEmailAlreadyExists, wantTokens: []string{"solved-token"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := testutil.NewFakeUserRepository(existing)
+			authService := service.NewAuthService(users, nil, nil, nil, nil, service.AuthConfig{
+				JWTSecret:      testJWTSecret,
+				AccessExpiry:   15 * time.Minute,
+				EmailBlocklist: security.NewEmailDomainBlocklist([]string{"mailinator.com"}),
+			}, zap.NewNop())
+			authHandler := handler.NewAuthHandler(authService, users, nil, nil, nil, nil, tt.captcha,
+				nil, nil, nil, nil, nil, "https://ugc.example.com", zap.NewNop())
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.POST("/api/v1/auth/register", authHandler.Register)
+
+			status, resp := serveAsTestUser(t, router, nil, http.MethodPost, "/auth/register", tt.body)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; response: %+v", status, tt.wantStatus, resp.Error)
+			}
+			if stub, ok := tt.captcha.(*stubCaptcha); ok && !slices.Equal(stub.tokens, tt.wantTokens) {
+				t.Errorf("captcha verified %q, want %q", stub.tokens, tt.wantTokens)
+			}
+
+			registered, _, _ := users.List(context.Background(), models.UserFilter{}, 1, 10)
+			if tt.wantCode != "" {
+				if resp.Error == nil || resp.Error.ErrorCode != tt.wantCode {
+					t.Errorf("error = %+v, want %s", resp.Error, tt.wantCode)
+				}
+				if len(registered) != 1 {
+					t.Errorf("stored %d users after a rejected registration, want only the existing one", len(registered))
+				}
+				return
+			}
+
+			var created models.UserResponse
+			decodeData(t, resp, &created)
+			if created.Email != tt.wantEmail {
+				t.Errorf("registered email = %q, want %q", created.Email, tt.wantEmail)
+			}
+			if stored, err := users.GetByID(context.Background(), created.ID); err != nil || stored.Email != tt.wantEmail {
+				t.Errorf("stored user = %+v (%v), want email %s", stored, err, tt.wantEmail)
+			}
+		})
+	}
+}
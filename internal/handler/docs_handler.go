@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// swaggerUIPage renders Swagger UI from the CDN against the spec served next to it,
+// so no UI assets are bundled into the binary.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>UGC API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>`
+
+// DocsHandler serves the API spec generated by swag from the handler annotations
+// (go generate ./cmd/ugc) and a Swagger UI page for it.
+type DocsHandler struct {
+	spec   []byte
+	logger *zap.Logger
+}
+
+// NewDocsHandler loads the generated spec from specPath. A missing or invalid spec
+// is logged and the endpoints answer 404 until it is generated.
+func NewDocsHandler(specPath string, logger *zap.Logger) *DocsHandler {
+	h := &DocsHandler{logger: logger}
+
+	spec, err := os.ReadFile(specPath)
+	if err != nil {
+		logger.Warn("API spec not found, check API_DOCS_SPEC_PATH or run go generate ./cmd/ugc",
+			zap.String("path", specPath), zap.Error(err))
+		return h
+	}
+	if !json.Valid(spec) {
+		logger.Warn("API spec is not valid JSON, regenerate it with go generate ./cmd/ugc",
+			zap.String("path", specPath))
+		return h
+	}
+
+	h.spec = spec
+	return h
+}
+
+// RegisterRoutes registers the docs routes to the given router group.
+func (h *DocsHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/openapi.json", h.Spec)
+	rg.GET("/docs", h.UI)
+}
+
+// Spec serves the generated API spec.
+// @Summary Get the API spec
+// @Description The Swagger 2.0 spec of this API, generated from the handler annotations. Returns 404 when the server has no spec to serve.
+// @Tags docs
+// @Produce json
+// @Success 200 {object} object
+// @Failure 404 {object} object
+// @Router /openapi.json [get]
+func (h *DocsHandler) Spec(c *gin.Context) {
+	if h.spec == nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "API spec has not been generated"})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.spec)
+}
+
+// UI serves the Swagger UI page.
+// @Summary Browse the API docs
+// @Description A Swagger UI page for /openapi.json.
+// @Tags docs
+// @Produce html
+// @Success 200 {string} string "HTML page"
+// @Router /docs [get]
+func (h *DocsHandler) UI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
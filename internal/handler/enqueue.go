@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/jaochai/ugc/internal/worker"
+)
+
+// enqueueOrOutbox enqueues task for jobID with opts, storing it in the outbox if the queue
+// is unavailable. It only returns an error if the task could not be enqueued or
+// stored, or if it is a duplicate (asynq.ErrTaskIDConflict).
+// Without an outbox it falls back to a plain enqueue.
+func enqueueOrOutbox(ctx context.Context, outbox *worker.Outbox, client *asynq.Client, task *asynq.Task, jobID uuid.UUID, opts ...asynq.Option) error {
+	if outbox != nil {
+		return outbox.Enqueue(ctx, task, jobID, opts...)
+	}
+	_, err := client.EnqueueContext(ctx, task, opts...)
+	return err
+}
@@ -0,0 +1,310 @@
+package handler
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/lyrics"
+	"github.com/jaochai/ugc/internal/middleware"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/service"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/response"
+)
+
+// exportWriteTimeout bounds how long streaming one export may take.
+const exportWriteTimeout = 30 * time.Minute
+
+// ExportHandler streams a completed job's artifacts as a zip archive.
+type ExportHandler struct {
+	jobService service.JobService
+	r2Client   *r2.Client
+	limiter    service.ExportLimiter // nil disables the per-user concurrency cap
+	logger     *zap.Logger
+}
+
+// NewExportHandler creates a new ExportHandler instance. limiter may be nil.
+func NewExportHandler(jobService service.JobService, r2Client *r2.Client, limiter service.ExportLimiter, logger *zap.Logger) *ExportHandler {
+	return &ExportHandler{
+		jobService: jobService,
+		r2Client:   r2Client,
+		limiter:    limiter,
+		logger:     logger,
+	}
+}
+
+// RegisterRoutes registers the export route to the given router group.
+func (h *ExportHandler) RegisterRoutes(rg *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	rg.GET("/jobs/:id/export", authMiddleware, h.Export)
+}
+
+// exportMetadata is written to metadata.json in a job export.
+type exportMetadata struct {
+	JobID           uuid.UUID              `json:"job_id"`
+	Title           string                 `json:"title"`
+	TitleEn         string                 `json:"title_en,omitempty"`
+	Style           string                 `json:"style"`
+	Concept         string                 `json:"concept"`
+	LLMModel        string                 `json:"llm_model"`
+	AgentModels     map[string]string      `json:"agent_models,omitempty"`
+	SunoModel       *string                `json:"suno_model,omitempty"`
+	Instrumental    bool                   `json:"instrumental"`
+	DurationSeconds float64                `json:"duration_seconds,omitempty"`
+	Tags            []string               `json:"tags"`
+	CreatedAt       time.Time              `json:"created_at"`
+	StageDurations  []models.StageDuration `json:"stage_durations,omitempty"`
+	// Files lists the archive entries; media not stored in R2 is left out.
+	Files []string `json:"files"`
+}
+
+// exportFile is an R2 object added to a job export.
+type exportFile struct {
+	name string
+	key  string
+}
+
+// Export handles downloading a job's artifacts as a zip.
+// @Summary Export a job
+// @Description Streams a zip of a completed job: lyrics.txt (unless instrumental), cover image, audio.mp3 and video.mp4 as stored in R2, and metadata.json (title, style, models, stage timings and the files included). Media that is not stored in R2 is left out. A failed job exports what it produced before failing, without the video. Each user can run 2 exports at a time.
+// @Tags jobs
+// @Produce application/zip
+// @Param id path string true "Job ID" format(uuid)
+// @Success 200 {file} binary "Zip archive"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 429 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /jobs/{id}/export [get]
+func (h *ExportHandler) Export(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Error(c, apperrors.NewBadRequest("invalid job ID format").WithCode(apperrors.CodeInvalidJobID))
+		return
+	}
+
+	// Get job (service checks access via userID)
+	job, err := h.jobService.GetVisible(c.Request.Context(), userID, jobID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	if job.Status != models.StatusCompleted && job.Status != models.StatusFailed {
+		response.Error(c, apperrors.NewConflict("job is not completed").WithCode(apperrors.CodeJobNotCompleted))
+		return
+	}
+
+	if h.r2Client == nil {
+		h.logger.Error("export requested but R2 storage is not configured")
+		response.InternalServerError(c, "storage is not configured")
+		return
+	}
+
+	if h.limiter != nil {
+		acquired, err := h.limiter.Acquire(c.Request.Context(), userID)
+		if err != nil {
+			// Fail open: exports still work while Redis is unavailable
+			h.logger.Warn("failed to acquire export slot", zap.Error(err))
+		} else if !acquired {
+			response.Error(c, apperrors.NewTooManyRequests("too many exports in progress; wait for one to finish").
+				WithCode(apperrors.CodeTooManyExports))
+			return
+		} else {
+			defer func() {
+				if err := h.limiter.Release(context.WithoutCancel(c.Request.Context()), userID); err != nil {
+					h.logger.Warn("failed to release export slot", zap.Error(err))
+				}
+			}()
+		}
+	}
+
+	// A completed job's video is required; open it before the response starts so
+	// a missing object can still be reported as an error. Failed jobs have none.
+	files := exportFiles(job)
+	var video io.ReadCloser
+	if job.Status == models.StatusCompleted {
+		video, err = h.r2Client.Open(c.Request.Context(), files[0].key)
+		if err != nil {
+			if errors.Is(err, r2.ErrObjectNotFound) {
+				response.NotFound(c, "video not found")
+				return
+			}
+			h.logger.Error("failed to open video for export", zap.Error(err), zap.String("job_id", jobID.String()))
+			response.InternalServerError(c, "failed to read video")
+			return
+		}
+	} else {
+		files = files[1:]
+	}
+
+	// Large videos take longer than the server's write timeout to stream
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(exportWriteTimeout)); err != nil {
+		h.logger.Warn("failed to extend export write deadline", zap.Error(err))
+	}
+
+	filename := downloadFilename(job, "zip")
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Status(http.StatusOK)
+
+	if err := h.writeExport(c.Request.Context(), c.Writer, job, files, video); err != nil {
+		// The zip is already partly sent; the client sees a truncated archive
+		h.logger.Error("failed to stream job export", zap.Error(err), zap.String("job_id", jobID.String()))
+		c.Abort()
+		return
+	}
+
+	h.logger.Info("job exported",
+		zap.String("job_id", jobID.String()),
+		zap.String("user_id", userID.String()),
+	)
+}
+
+// exportFiles lists the R2 objects of a job export; the video comes first.
+func exportFiles(job *models.Job) []exportFile {
+	videoKey, _ := r2.JobAssetKey(r2.AssetVideo, job.ID.String())
+	if job.VideoKey != nil && *job.VideoKey != "" {
+		videoKey = *job.VideoKey
+	}
+	audioKey, _ := r2.JobAssetKey(r2.AssetAudio, job.ID.String())
+	if job.AudioKey != nil && *job.AudioKey != "" {
+		audioKey = *job.AudioKey
+	}
+	// User-supplied covers keep their own format
+	imageKey, _ := r2.JobAssetKey(r2.AssetImage, job.ID.String())
+	if job.ImageKey != nil && *job.ImageKey != "" {
+		imageKey = *job.ImageKey
+	}
+
+	return []exportFile{
+		{name: "video.mp4", key: videoKey},
+		{name: "cover" + path.Ext(imageKey), key: imageKey},
+		{name: "audio.mp3", key: audioKey},
+	}
+}
+
+// writeExport streams the zip: lyrics, the media files copied from R2 without
+// buffering them in memory, then metadata.json. video is the opened first file,
+// or nil when files holds no video.
+func (h *ExportHandler) writeExport(ctx context.Context, w io.Writer, job *models.Job, files []exportFile, video io.ReadCloser) error {
+	zw := zip.NewWriter(w)
+	included := make([]string, 0, len(files)+2)
+
+	if job.SongPrompt != nil && !job.SongPrompt.Instrumental && strings.TrimSpace(job.SongPrompt.Prompt) != "" {
+		entry, err := zw.Create("lyrics.txt")
+		if err == nil {
+			_, err = io.WriteString(entry, lyrics.Parse(job.SongPrompt.Prompt).Text())
+		}
+		if err != nil {
+			if video != nil {
+				video.Close()
+			}
+			return fmt.Errorf("failed to write lyrics: %w", err)
+		}
+		included = append(included, "lyrics.txt")
+	}
+
+	for i, file := range files {
+		body := video
+		if i > 0 || video == nil {
+			var err error
+			body, err = h.r2Client.Open(ctx, file.key)
+			if errors.Is(err, r2.ErrObjectNotFound) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", file.name, err)
+			}
+		}
+
+		err := copyToZip(zw, file.name, body)
+		body.Close()
+		if err != nil {
+			return err
+		}
+		included = append(included, file.name)
+	}
+
+	included = append(included, "metadata.json")
+	metadata, err := json.MarshalIndent(newExportMetadata(job, included), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	entry, err := zw.Create("metadata.json")
+	if err != nil {
+		return fmt.Errorf("failed to add metadata: %w", err)
+	}
+	if _, err := entry.Write(metadata); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish zip: %w", err)
+	}
+	return nil
+}
+
+// copyToZip stores body as name. Media is already compressed, so entries are
+// stored rather than deflated.
+func copyToZip(zw *zip.Writer, name string, body io.Reader) error {
+	entry, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	if _, err := io.Copy(entry, body); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", name, err)
+	}
+	return nil
+}
+
+// newExportMetadata describes the job for metadata.json.
+func newExportMetadata(job *models.Job, files []string) exportMetadata {
+	metadata := exportMetadata{
+		JobID:          job.ID,
+		Concept:        job.Concept,
+		LLMModel:       job.LLMModel,
+		AgentModels:    job.AgentModels,
+		SunoModel:      job.SunoModel,
+		Tags:           job.Tags,
+		CreatedAt:      job.CreatedAt,
+		StageDurations: job.StageDurations(),
+		Files:          files,
+	}
+	if job.SongPrompt != nil {
+		metadata.Title = job.SongPrompt.Title
+		metadata.TitleEn = job.SongPrompt.TitleEn
+		metadata.Style = job.SongPrompt.Style
+		metadata.Instrumental = job.SongPrompt.Instrumental
+	}
+	if song := job.SelectedSong(); song != nil {
+		metadata.DurationSeconds = song.Duration
+	}
+	return metadata
+}
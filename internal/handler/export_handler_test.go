@@ -0,0 +1,283 @@
+package handler_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/handler"
+	"github.com/jaochai/ugc/internal/lyrics"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/testutil"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+)
+
+// exportJobs serves jobs to their owners, as JobService.GetVisible does.
+type exportJobs struct {
+	service.JobService
+	jobs map[uuid.UUID]*models.Job
+}
+
+func (s *exportJobs) GetVisible(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*models.Job, error) {
+	job, ok := s.jobs[jobID]
+	if !ok || job.UserID != userID {
+		return nil, apperrors.NewNotFound("job not found").WithCode(apperrors.CodeJobNotFound)
+	}
+	return job, nil
+}
+
+// exportFixture is an export router over a fake R2 and a Redis export limiter
+// allowing two exports per user.
+type exportFixture struct {
+	router *gin.Engine
+	r2     *r2.Client
+	jobs   *exportJobs
+	redis  *miniredis.Miniredis
+}
+
+func newExportFixture(t *testing.T) *exportFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	r2Client, err := r2.NewClient(context.Background(), testutil.NewFakeR2(t).Config())
+	if err != nil {
+		t.Fatalf("failed to create R2 client: %v", err)
+	}
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	f := &exportFixture{r2: r2Client, jobs: &exportJobs{jobs: make(map[uuid.UUID]*models.Job)}, redis: mr}
+	exportHandler := handler.NewExportHandler(f.jobs, r2Client, service.NewRedisExportLimiter(redisClient, "ugc", 2), zap.NewNop())
+	f.router = gin.New()
+	exportHandler.RegisterRoutes(f.router.Group("/api/v1"), testUserAuth)
+	return f
+}
+
+// addJob stores job and uploads the given assets to its default keys.
+func (f *exportFixture) addJob(t *testing.T, job *models.Job, assets map[string]string) {
+	t.Helper()
+	f.jobs.jobs[job.ID] = job
+	for asset, content := range assets {
+		key, err := r2.JobAssetKey(asset, job.ID.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.r2.Upload(context.Background(), key, strings.NewReader(content), r2.JobAssetContentType(asset)); err != nil {
+			t.Fatalf("failed to upload %s: %v", key, err)
+		}
+	}
+}
+
+// export requests the job's export as user and returns the response.
+func (f *exportFixture) export(user *models.User, jobID uuid.UUID) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+jobID.String()+"/export", nil)
+	req.Header.Set("X-Test-User", user.ID.String())
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// readExport returns the entries of an export archive in order, by name.
+func readExport(t *testing.T, rec *httptest.ResponseRecorder) ([]string, map[string]string) {
+	t.Helper()
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export = %d %s, want 200", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Content-Type = %q, want application/zip", ct)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("export is not a zip: %v", err)
+	}
+
+	var names []string
+	contents := make(map[string]string)
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file.Name, err)
+		}
+		names = append(names, file.Name)
+		contents[file.Name] = string(data)
+	}
+	return names, contents
+}
+
+func TestExportCompletedJob(t *testing.T) {
+	f := newExportFixture(t)
+	owner := &models.User{ID: uuid.New()}
+	const prompt = "[Verse]\nคลื่นซัดฝั่งในคืนจันทร์เพ็ญ\n[Chorus]\nรักเราไม่มีวันจาง"
+	job := &models.Job{
+		ID:       uuid.New(),
+		UserID:   owner.ID,
+		Status:   models.StatusCompleted,
+		Concept:  "งานแต่งงานริมทะเล",
+		LLMModel: "google/gemini-2.5-flash",
+		Tags:     []string{"wedding"},
+		SongPrompt: &models.SongPrompt{
+			Prompt: prompt,
+			Style:  "acoustic pop",
+			Title:  "ทะเลแห่งรัก",
+		},
+	}
+	f.addJob(t, job, map[string]string{r2.AssetVideo: "fake mp4", r2.AssetImage: "fake png", r2.AssetAudio: "fake mp3"})
+
+	rec := f.export(owner, job.ID)
+	names, contents := readExport(t, rec)
+
+	want := []string{"lyrics.txt", "video.mp4", "cover.png", "audio.mp3", "metadata.json"}
+	if !slices.Equal(names, want) {
+		t.Fatalf("entries = %v, want %v", names, want)
+	}
+	if contents["video.mp4"] != "fake mp4" || contents["cover.png"] != "fake png" || contents["audio.mp3"] != "fake mp3" {
+		t.Errorf("media entries differ from the R2 objects")
+	}
+	if got, want := contents["lyrics.txt"], lyrics.Parse(prompt).Text(); got != want || !strings.Contains(got, "รักเราไม่มีวันจาง") {
+		t.Errorf("lyrics.txt = %q, want %q", got, want)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment") || !strings.Contains(cd, ".zip") {
+		t.Errorf("Content-Disposition = %q, want a zip attachment", cd)
+	}
+
+	var metadata struct {
+		JobID   uuid.UUID `json:"job_id"`
+		Title   string    `json:"title"`
+		Style   string    `json:"style"`
+		Concept string    `json:"concept"`
+		Model   string    `json:"llm_model"`
+		Tags    []string  `json:"tags"`
+		Files   []string  `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(contents["metadata.json"]), &metadata); err != nil {
+		t.Fatalf("metadata.json is not JSON: %v", err)
+	}
+	if metadata.JobID != job.ID || metadata.Title != "ทะเลแห่งรัก" || metadata.Style != "acoustic pop" ||
+		metadata.Concept != job.Concept || metadata.Model != job.LLMModel || !slices.Equal(metadata.Tags, job.Tags) {
+		t.Errorf("metadata = %+v, want the job's details", metadata)
+	}
+	if !slices.Equal(metadata.Files, want) {
+		t.Errorf("metadata files = %v, want %v", metadata.Files, want)
+	}
+
+	// The export slot is released once the archive is sent
+	if count, _ := f.redis.Get("ugc:exports:" + owner.ID.String()); count != "0" {
+		t.Errorf("export slots in use = %q after the export, want 0", count)
+	}
+}
+
+func TestExportPartialJobs(t *testing.T) {
+	owner := &models.User{ID: uuid.New()}
+
+	tests := []struct {
+		name   string
+		job    models.Job
+		assets map[string]string
+		want   []string
+	}{
+		{
+			name:   "instrumental without audio in R2",
+			job:    models.Job{Status: models.StatusCompleted, SongPrompt: &models.SongPrompt{Prompt: "[Intro]", Instrumental: true}},
+			assets: map[string]string{r2.AssetVideo: "fake mp4", r2.AssetImage: "fake png"},
+			want:   []string{"video.mp4", "cover.png", "metadata.json"},
+		},
+		{
+			name:   "failed job exports what it produced without the video",
+			job:    models.Job{Status: models.StatusFailed, SongPrompt: &models.SongPrompt{Prompt: "[Verse]\nฝนตก"}},
+			assets: map[string]string{r2.AssetVideo: "partial mp4", r2.AssetAudio: "fake mp3"},
+			want:   []string{"lyrics.txt", "audio.mp3", "metadata.json"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newExportFixture(t)
+			job := tt.job
+			job.ID, job.UserID = uuid.New(), owner.ID
+			f.addJob(t, &job, tt.assets)
+
+			names, _ := readExport(t, f.export(owner, job.ID))
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("entries = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportRejected(t *testing.T) {
+	f := newExportFixture(t)
+	owner, stranger := &models.User{ID: uuid.New()}, &models.User{ID: uuid.New()}
+
+	completed := &models.Job{ID: uuid.New(), UserID: owner.ID, Status: models.StatusCompleted}
+	f.addJob(t, completed, map[string]string{r2.AssetVideo: "fake mp4"})
+	pending := &models.Job{ID: uuid.New(), UserID: owner.ID, Status: models.StatusGeneratingMusic}
+	f.addJob(t, pending, nil)
+	noVideo := &models.Job{ID: uuid.New(), UserID: owner.ID, Status: models.StatusCompleted}
+	f.addJob(t, noVideo, map[string]string{r2.AssetAudio: "fake mp3"})
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		jobID      uuid.UUID
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "another user's job", user: stranger, jobID: completed.ID, wantStatus: http.StatusNotFound, wantCode: apperrors.CodeJobNotFound},
+		{name: "unfinished job", user: owner, jobID: pending.ID, wantStatus: http.StatusConflict, wantCode: apperrors.CodeJobNotCompleted},
+		{name: "video missing from R2", user: owner, jobID: noVideo.ID, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := f.export(tt.user, tt.jobID)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("export = %d %s, want %d", rec.Code, rec.Body.String(), tt.wantStatus)
+			}
+			if tt.wantCode != "" && !strings.Contains(rec.Body.String(), tt.wantCode) {
+				t.Errorf("body = %s, want error code %s", rec.Body.String(), tt.wantCode)
+			}
+		})
+	}
+
+	// Two exports in progress use up the owner's slots; others are unaffected
+	if err := f.redis.Set("ugc:exports:"+owner.ID.String(), "2"); err != nil {
+		t.Fatal(err)
+	}
+	rec := f.export(owner, completed.ID)
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), apperrors.CodeTooManyExports) {
+		t.Errorf("export with every slot in use = %d %s, want 429 %s", rec.Code, rec.Body.String(), apperrors.CodeTooManyExports)
+	}
+	if count, _ := f.redis.Get("ugc:exports:" + owner.ID.String()); count != "2" {
+		t.Errorf("export slots in use = %q after a refused export, want 2", count)
+	}
+
+	// The refused export is served once a slot frees up
+	if err := f.redis.Set("ugc:exports:"+owner.ID.String(), "1"); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := readExport(t, f.export(owner, completed.ID)); !slices.Contains(names, "video.mp4") {
+		t.Errorf("entries = %v, want the video", names)
+	}
+}
@@ -0,0 +1,231 @@
+// Package handler provides HTTP handlers for the UGC API.
+package handler
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+)
+
+// healthCheckTimeout bounds each readiness check.
+const healthCheckTimeout = 2 * time.Second
+
+// Dependency check results.
+const (
+	checkStatusOK            = "ok"
+	checkStatusFailed        = "failed"
+	checkStatusNotConfigured = "not_configured"
+)
+
+// HealthConfig marks which dependencies may be missing or down without failing readiness.
+type HealthConfig struct {
+	RedisOptional    bool
+	R2Optional       bool
+	RequireFFmpeg    bool          // The process runs the worker, which renders videos with ffmpeg
+	DBMaxAcquireWait time.Duration // Fail when the average pool acquire wait exceeds this; zero skips the check
+	Startup          *StartupState // Fail until the process has started; nil skips the check
+}
+
+// StartupState tells the readiness probe whether the process has finished
+// starting: migrations ran and, in processes running the worker, the worker is
+// fetching tasks. It is not ready again once shutdown begins. The zero value is
+// not ready.
+type StartupState struct {
+	ready atomic.Bool
+}
+
+// SetReady marks the process as started, or as shutting down.
+func (s *StartupState) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// Ready reports whether the process has started and is not shutting down.
+func (s *StartupState) Ready() bool {
+	return s.ready.Load()
+}
+
+// DependencyStatus is the readiness result for one dependency.
+type DependencyStatus struct {
+	Status   string `json:"status"`
+	Required bool   `json:"required"`
+}
+
+// ReadinessResponse is the body returned by the readiness probe.
+type ReadinessResponse struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// DatabaseHealth is the part of database.DB checked by the readiness probe.
+type DatabaseHealth interface {
+	Health(ctx context.Context) error
+	CheckAcquireWait(ctx context.Context, maxWait time.Duration) error
+}
+
+// HealthHandler serves liveness and readiness probes.
+type HealthHandler struct {
+	db          DatabaseHealth
+	redisClient *redis.Client
+	r2Client    *r2.Client
+	cfg         HealthConfig
+	logger      *zap.Logger
+
+	ffmpegOnce sync.Once
+	ffmpegErr  error
+}
+
+// NewHealthHandler creates a new HealthHandler instance.
+// redisClient and r2Client may be nil when those services are not configured.
+func NewHealthHandler(
+	db DatabaseHealth,
+	redisClient *redis.Client,
+	r2Client *r2.Client,
+	cfg HealthConfig,
+	logger *zap.Logger,
+) *HealthHandler {
+	return &HealthHandler{
+		db:          db,
+		redisClient: redisClient,
+		r2Client:    r2Client,
+		cfg:         cfg,
+		logger:      logger,
+	}
+}
+
+// RegisterRoutes registers the health routes on the router root.
+func (h *HealthHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/health", h.Live)
+	router.GET("/health/ready", h.Ready)
+}
+
+// Live is the cheap liveness probe; it never touches dependencies.
+// @Summary Liveness probe
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /health [get]
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "healthy",
+		"service": "ugc",
+	})
+}
+
+// Ready checks the database, its connection pool, Redis, R2 and, in processes
+// running the worker, ffmpeg concurrently.
+// While the process is starting or shutting down it fails without checking them.
+// @Summary Readiness probe
+// @Description Returns 503 with a per-dependency status map if any required dependency fails (errors are logged, not returned), or with only startup while the process is starting or shutting down
+// @Tags health
+// @Produce json
+// @Success 200 {object} ReadinessResponse
+// @Failure 503 {object} ReadinessResponse
+// @Router /health/ready [get]
+func (h *HealthHandler) Ready(c *gin.Context) {
+	if h.cfg.Startup != nil && !h.cfg.Startup.Ready() {
+		c.JSON(http.StatusServiceUnavailable, ReadinessResponse{
+			Status: "not_ready",
+			Dependencies: map[string]DependencyStatus{
+				"startup": {Status: checkStatusFailed, Required: true},
+			},
+		})
+		return
+	}
+
+	type check struct {
+		name     string
+		required bool
+		run      func(ctx context.Context) error // nil means not configured
+	}
+
+	checks := []check{
+		{name: "database", required: true, run: h.db.Health},
+		{name: "ffmpeg", required: h.cfg.RequireFFmpeg},
+		{name: "redis", required: !h.cfg.RedisOptional},
+		{name: "r2", required: !h.cfg.R2Optional},
+		{name: "database_pool", required: h.cfg.DBMaxAcquireWait > 0},
+	}
+	if h.redisClient != nil {
+		checks[2].run = func(ctx context.Context) error {
+			return h.redisClient.Ping(ctx).Err()
+		}
+	}
+	if h.r2Client != nil {
+		checks[3].run = h.r2Client.HeadBucket
+	}
+	if h.cfg.RequireFFmpeg {
+		checks[1].run = h.checkFFmpeg
+	}
+	if h.cfg.DBMaxAcquireWait > 0 {
+		checks[4].run = func(ctx context.Context) error {
+			return h.db.CheckAcquireWait(ctx, h.cfg.DBMaxAcquireWait)
+		}
+	}
+
+	results := make([]DependencyStatus, len(checks))
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		if chk.run == nil {
+			results[i] = DependencyStatus{Status: checkStatusNotConfigured, Required: chk.required}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, chk check) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+			defer cancel()
+
+			result := DependencyStatus{Status: checkStatusOK, Required: chk.required}
+			// The probe is public: errors, which may name hosts or buckets, are only logged
+			if err := chk.run(ctx); err != nil {
+				result.Status = checkStatusFailed
+				h.logger.Warn("readiness check failed",
+					zap.String("dependency", chk.name),
+					zap.Bool("required", chk.required),
+					zap.Error(err),
+				)
+			}
+			results[i] = result
+		}(i, chk)
+	}
+	wg.Wait()
+
+	resp := ReadinessResponse{
+		Status:       "ready",
+		Dependencies: make(map[string]DependencyStatus, len(checks)),
+	}
+	for i, chk := range checks {
+		result := results[i]
+		resp.Dependencies[chk.name] = result
+		if result.Required && result.Status != checkStatusOK {
+			resp.Status = "not_ready"
+		}
+	}
+
+	if resp.Status != "ready" {
+		c.JSON(http.StatusServiceUnavailable, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// checkFFmpeg verifies the ffmpeg binary is on PATH. The lookup result is cached
+// since the binary cannot appear or disappear without a redeploy.
+func (h *HealthHandler) checkFFmpeg(_ context.Context) error {
+	h.ffmpegOnce.Do(func() {
+		_, h.ffmpegErr = exec.LookPath("ffmpeg")
+	})
+	return h.ffmpegErr
+}
@@ -0,0 +1,141 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/handler"
+)
+
+// TestReadyFailsUntilStarted checks that readiness answers 503 while the process
+// is starting or shutting down, before any dependency is checked; the database
+// is nil here, so a dependency check would panic.
+func TestReadyFailsUntilStarted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	startup := &handler.StartupState{}
+	router := gin.New()
+	handler.NewHealthHandler(nil, nil, nil, handler.HealthConfig{Startup: startup}, zap.NewNop()).RegisterRoutes(router)
+
+	ready := func() (int, handler.ReadinessResponse) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+		var resp handler.ReadinessResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid readiness response: %v", err)
+		}
+		return rec.Code, resp
+	}
+
+	// Migrations or the worker have not finished starting
+	code, resp := ready()
+	if code != http.StatusServiceUnavailable || resp.Status != "not_ready" {
+		t.Fatalf("readiness while starting = %d %q, want 503 not_ready", code, resp.Status)
+	}
+	if dep := resp.Dependencies["startup"]; dep.Status != "failed" || !dep.Required {
+		t.Errorf("startup dependency = %+v, want a required failure", dep)
+	}
+
+	// Shutdown began after a successful start
+	startup.SetReady(true)
+	startup.SetReady(false)
+	if code, _ := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("readiness while shutting down = %d, want 503", code)
+	}
+
+	// Liveness does not depend on startup
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("liveness while starting = %d, want 200", rec.Code)
+	}
+}
+
+// fakeDB is a database whose checks return err.
+type fakeDB struct{ err error }
+
+func (db fakeDB) Health(ctx context.Context) error { return db.err }
+
+func (db fakeDB) CheckAcquireWait(ctx context.Context, maxWait time.Duration) error { return db.err }
+
+// readinessBody serves one readiness probe against db with cfg and returns its
+// status code and raw body.
+func readinessBody(t *testing.T, db fakeDB, cfg handler.HealthConfig) (int, []byte) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.NewHealthHandler(db, nil, nil, cfg, zap.NewNop()).RegisterRoutes(router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	return rec.Code, rec.Body.Bytes()
+}
+
+// readiness serves one readiness probe with a healthy database and cfg and
+// returns its status code and body.
+func readiness(t *testing.T, cfg handler.HealthConfig) (int, handler.ReadinessResponse) {
+	t.Helper()
+	code, body := readinessBody(t, fakeDB{}, cfg)
+	var resp handler.ReadinessResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("invalid readiness response: %v", err)
+	}
+	return code, resp
+}
+
+// TestReadyHidesCheckErrors checks that a failing dependency is reported by
+// status only; its error may name internal hosts.
+func TestReadyHidesCheckErrors(t *testing.T) {
+	db := fakeDB{err: errors.New("dial tcp db-internal.example:5432: connection refused")}
+	code, body := readinessBody(t, db, handler.HealthConfig{RedisOptional: true, R2Optional: true})
+
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("readiness with the database down = %d, want 503", code)
+	}
+	if strings.Contains(string(body), "db-internal") || strings.Contains(string(body), "refused") {
+		t.Errorf("readiness body leaks the check error: %s", body)
+	}
+
+	var resp struct {
+		Dependencies map[string]map[string]any `json:"dependencies"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("invalid readiness response: %v", err)
+	}
+	database := resp.Dependencies["database"]
+	if database["status"] != "failed" || len(database) != 2 {
+		t.Errorf("database dependency = %v, want only status failed and required", database)
+	}
+}
+
+// TestReadyRequiresFFmpegOnlyInWorkers checks that a missing ffmpeg fails
+// readiness only in processes that run the worker.
+func TestReadyRequiresFFmpegOnlyInWorkers(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	cfg := handler.HealthConfig{RedisOptional: true, R2Optional: true}
+
+	code, resp := readiness(t, cfg)
+	if code != http.StatusOK {
+		t.Fatalf("API-only readiness without ffmpeg = %d, want 200", code)
+	}
+	if dep := resp.Dependencies["ffmpeg"]; dep.Status != "not_configured" || dep.Required {
+		t.Errorf("API-only ffmpeg dependency = %+v, want an optional, unchecked one", dep)
+	}
+
+	cfg.RequireFFmpeg = true
+	code, resp = readiness(t, cfg)
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("worker readiness without ffmpeg = %d, want 503", code)
+	}
+	if dep := resp.Dependencies["ffmpeg"]; dep.Status != "failed" || !dep.Required {
+		t.Errorf("worker ffmpeg dependency = %+v, want a required failure", dep)
+	}
+}
@@ -2,99 +2,206 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
 
+	"github.com/jaochai/ugc/internal/external/kie"
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/ffmpeg"
+	"github.com/jaochai/ugc/internal/lyrics"
 	"github.com/jaochai/ugc/internal/middleware"
 	"github.com/jaochai/ugc/internal/models"
 	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/security"
 	"github.com/jaochai/ugc/internal/service"
 	"github.com/jaochai/ugc/internal/worker"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
 	"github.com/jaochai/ugc/pkg/response"
 )
 
+// downloadURLExpiry is how long a presigned download URL stays valid.
+const downloadURLExpiry = 15 * time.Minute
+
+// jobPollIntervalSeconds is the Retry-After hint returned with a newly created job.
+const jobPollIntervalSeconds = 5
+
+// legacyCreateAPIVersion selects the old 201 Created response for POST /jobs, via the
+// Accept-Version header or the api_version query parameter.
+const legacyCreateAPIVersion = "1"
+
 // JobHandler handles job-related HTTP requests.
 type JobHandler struct {
-	jobService    service.JobService
-	userRepo      repository.UserRepository
-	cryptoService service.CryptoService
-	asynqClient   *asynq.Client
-	logger        *zap.Logger
+	jobService      service.JobService
+	templateService service.JobTemplateService
+	userRepo        repository.UserRepository
+	keyService      service.ProviderKeyService
+	creditService   service.KIECreditService
+	moderator       service.ContentModerator
+	asynqClient     *asynq.Client
+	outbox          *worker.Outbox
+	r2Client        *r2.Client
+	queuePositions  *worker.QueuePositionEstimator
+	syncRunner      *worker.SyncRunner // nil unless SYNC_JOBS_ENABLED
+	settingsService service.RuntimeSettingsService
+	logger          *zap.Logger
+
+	maxConceptLength int // Longest concept accepted, in characters
 }
 
 // NewJobHandler creates a new JobHandler instance.
 func NewJobHandler(
 	jobService service.JobService,
+	templateService service.JobTemplateService,
 	userRepo repository.UserRepository,
-	cryptoService service.CryptoService,
+	keyService service.ProviderKeyService,
+	creditService service.KIECreditService,
+	moderator service.ContentModerator,
 	asynqClient *asynq.Client,
+	outbox *worker.Outbox,
+	r2Client *r2.Client,
+	queuePositions *worker.QueuePositionEstimator,
+	syncRunner *worker.SyncRunner,
+	settingsService service.RuntimeSettingsService,
+	maxConceptLength int,
 	logger *zap.Logger,
 ) *JobHandler {
 	return &JobHandler{
-		jobService:    jobService,
-		userRepo:      userRepo,
-		cryptoService: cryptoService,
-		asynqClient:   asynqClient,
-		logger:        logger,
+		jobService:      jobService,
+		templateService: templateService,
+		userRepo:        userRepo,
+		keyService:      keyService,
+		creditService:   creditService,
+		moderator:       moderator,
+		asynqClient:     asynqClient,
+		outbox:          outbox,
+		r2Client:        r2Client,
+		queuePositions:  queuePositions,
+		syncRunner:      syncRunner,
+		settingsService: settingsService,
+		logger:          logger,
+
+		maxConceptLength: maxConceptLength,
 	}
 }
 
 // RegisterRoutes registers job-related routes to the given router group.
-func (h *JobHandler) RegisterRoutes(rg *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+// bulkRateLimitMiddleware, when non-nil, guards the bulk create endpoint.
+func (h *JobHandler) RegisterRoutes(rg *gin.RouterGroup, authMiddleware, bulkRateLimitMiddleware gin.HandlerFunc) {
 	jobs := rg.Group("/jobs")
 	jobs.Use(authMiddleware)
 	{
 		jobs.POST("", h.Create)
+		if bulkRateLimitMiddleware != nil {
+			jobs.POST("/bulk", bulkRateLimitMiddleware, h.BulkCreate)
+		} else {
+			jobs.POST("/bulk", h.BulkCreate)
+		}
 		jobs.GET("", h.List)
 		jobs.GET("/:id", h.GetByID)
-		jobs.DELETE("/:id", h.Cancel)
+		jobs.GET("/:id/download", h.Download)
+		jobs.GET("/:id/lyrics", h.GetLyrics)
+		jobs.DELETE("/:id", h.Delete)
+		jobs.POST("/:id/cancel", h.Cancel)
+		jobs.POST("/:id/restore", h.Restore)
+		jobs.POST("/:id/retry", h.Retry)
 		jobs.POST("/:id/youtube-upload", h.RetryYouTubeUpload)
+		jobs.POST("/:id/share", h.Share)
+		jobs.POST("/:id/image", h.UploadImage)
+		jobs.DELETE("/:id/share", h.Unshare)
+		jobs.PATCH("/:id/tags", h.UpdateTags)
 	}
 }
 
 // Create handles job creation requests.
 // @Summary Create a new job
-// @Description Creates a new UGC generation job with the given concept
+// @Description Creates a new UGC generation job with the given concept and queues it. template_id pre-fills any settings left unset from one of the user's job templates, then the profile's job_defaults fill the rest. image_url (public HTTPS PNG/JPEG/WebP, max 10MB) replaces image generation with the user's own cover. image_source "suno" (not with image_url) uses the selected track's Suno cover art instead, falling back to image generation when the track has none or it cannot be copied; the job's image_source then records which was used. video_options sets loudness normalization to -14 LUFS (normalize_audio, default true) the audio/video fade-out length (fade_out_seconds, 0-10, default 3) and the encoding preset (preset: standard, high, small or h265; unset uses the server default, and h265 falls back to it on workers without libx265). tags (max 10, 30 characters each) label the job for search and are stored lowercase. suno_model (V3_5, V4, V4_5, V4_5PLUS or V5) picks the Suno model; unset uses the profile's default_suno_model, then V5. Returns 202 with a Location header for polling the job, a Retry-After hint in seconds, and estimated_duration_seconds from recently completed jobs. Clients sending Accept-Version: 1 (or api_version=1) get the previous 201 response. callback_mode (auto, webhook or poll) picks provider callbacks or polling for this job; auto follows the server's WEBHOOK_BASE_URL. With sync=true (only when SYNC_JOBS_ENABLED, never in production) the pipeline runs inside the request and its progress streams as NDJSON events (created, task_started, task_finished, then done or error). While an admin has paused job intake, returns 503 JOB_INTAKE_PAUSED with the maintenance banner in details.banner_message.
 // @Tags jobs
 // @Accept json
 // @Produce json
 // @Param input body models.CreateJobInput true "Job creation input"
-// @Success 201 {object} response.Response{data=models.JobResponse}
+// @Param Accept-Version header string false "Set to 1 for the legacy 201 Created response"
+// @Param api_version query string false "Set to 1 for the legacy 201 Created response"
+// @Param sync query bool false "Run the pipeline inline and stream NDJSON progress (development only)"
+// @Success 202 {object} response.Response{data=models.JobResponse}
+// @Header 202 {string} Location "URL of the created job"
+// @Header 202 {integer} Retry-After "Seconds to wait before polling the job"
+// @Success 201 {object} response.Response{data=models.JobResponse} "Legacy response (Accept-Version: 1)"
+// @Success 200 {object} worker.SyncEvent "One NDJSON line per event (sync=true)"
 // @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response "sync=true while synchronous jobs are disabled"
 // @Failure 500 {object} response.Response
+// @Failure 503 {object} response.Response "Job intake is paused for maintenance"
 // @Security BearerAuth
 // @Router /jobs [post]
 func (h *JobHandler) Create(c *gin.Context) {
 	// Get user ID from context
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
 	// Bind JSON input
 	var input models.CreateJobInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		response.BadRequest(c, "invalid request body")
+		response.Error(c, apperrors.NewInvalidRequestBody())
+		return
+	}
+
+	// Existing jobs keep processing while intake is paused; only new ones are refused
+	if err := h.settingsService.CheckJobIntake(c.Request.Context()); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	sync := c.Query("sync") == "true"
+	if sync && h.syncRunner == nil {
+		response.Error(c, apperrors.NewForbidden("synchronous jobs are disabled").WithCode(apperrors.CodeSyncJobsDisabled))
 		return
 	}
 
+	// Pre-fill settings from a template; fields set in the request still win
+	if input.TemplateID != nil {
+		template, err := h.templateService.Get(c.Request.Context(), userID, *input.TemplateID)
+		if err != nil {
+			response.Error(c, err)
+			return
+		}
+		input = template.Apply(input)
+	}
+
 	// Validate input
-	if input.Concept == "" {
-		response.ValidationError(c, map[string]string{
-			"concept": "concept is required",
-		})
+	input.Concept = service.NormalizeConcept(input.Concept)
+	if err := validateCreateJobInput(input, h.maxConceptLength); err != nil {
+		response.Error(c, err)
 		return
 	}
-	if len(input.Concept) < 5 {
-		response.ValidationError(c, map[string]string{
-			"concept": "concept must be at least 5 characters",
-		})
+	tags, err := service.NormalizeTags(input.Tags)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	input.Tags = tags
+
+	// Reject disallowed concepts before any provider credits are spent
+	if err := h.moderator.CheckConcept(c.Request.Context(), input.Concept); err != nil {
+		response.Error(c, err)
 		return
 	}
 
@@ -109,38 +216,19 @@ func (h *JobHandler) Create(c *gin.Context) {
 		return
 	}
 
-	// Validate user has required API keys (user already has encrypted keys from GetByID)
-	hasOpenRouterKey := false
-	if user.OpenRouterAPIKey != nil && *user.OpenRouterAPIKey != "" {
-		decrypted, err := h.cryptoService.Decrypt(*user.OpenRouterAPIKey)
-		if err != nil {
-			h.logger.Warn("failed to decrypt OpenRouter API key", zap.Error(err))
-		} else if decrypted != "" {
-			hasOpenRouterKey = true
-		}
-	}
-	if !hasOpenRouterKey {
-		response.BadRequest(c, "OpenRouter API key is required. Please configure in Settings.")
-		return
-	}
-
-	hasKIEKey := false
-	if user.KIEAPIKey != nil && *user.KIEAPIKey != "" {
-		decrypted, err := h.cryptoService.Decrypt(*user.KIEAPIKey)
-		if err != nil {
-			h.logger.Warn("failed to decrypt KIE API key", zap.Error(err))
-		} else if decrypted != "" {
-			hasKIEKey = true
-		}
-	}
-	if !hasKIEKey {
-		response.BadRequest(c, "KIE API key is required. Please configure in Settings.")
+	keySources, err := h.requireProviderKeys(c.Request.Context(), user, 1)
+	if err != nil {
+		response.Error(c, err)
 		return
 	}
+	input.OpenRouterKeySource = keySources.OpenRouter
+	input.KIEKeySource = keySources.KIE
+	input.OrgID = user.OrgID
 
 	// Create job
-	job, err := h.jobService.Create(c.Request.Context(), userID, input, user.OpenRouterModel)
+	job, err := h.jobService.Create(c.Request.Context(), user, input)
 	if err != nil {
+		h.keyService.ReleaseKeys(c.Request.Context(), keySources, 1)
 		h.logger.Error("failed to create job",
 			zap.Error(err),
 			zap.String("user_id", userID.String()),
@@ -149,26 +237,12 @@ func (h *JobHandler) Create(c *gin.Context) {
 		return
 	}
 
-	// Enqueue analyze concept task
-	task, err := worker.NewAnalyzeConceptTask(job.ID)
-	if err != nil {
-		h.logger.Error("failed to create analyze concept task",
-			zap.Error(err),
-			zap.String("job_id", job.ID.String()),
-		)
-		// Job is created but task enqueue failed - mark job as failed
-		_ = h.jobService.MarkFailed(c.Request.Context(), job.ID, "failed to enqueue analyze task")
-		response.Error(c, err)
+	if sync {
+		h.runSync(c, job)
 		return
 	}
 
-	if _, err := h.asynqClient.Enqueue(task); err != nil {
-		h.logger.Error("failed to enqueue analyze concept task",
-			zap.Error(err),
-			zap.String("job_id", job.ID.String()),
-		)
-		// Job is created but task enqueue failed - mark job as failed
-		_ = h.jobService.MarkFailed(c.Request.Context(), job.ID, "failed to enqueue analyze task")
+	if err := h.enqueueAnalyze(c, job.ID); err != nil {
 		response.Error(c, err)
 		return
 	}
@@ -178,52 +252,159 @@ func (h *JobHandler) Create(c *gin.Context) {
 		zap.String("user_id", userID.String()),
 	)
 
-	response.Created(c, job.ToResponse())
+	resp := job.ToResponse()
+	if wantsLegacyCreateResponse(c) {
+		response.Created(c, resp)
+		return
+	}
+
+	resp.EstimatedDurationSeconds = int(h.jobService.EstimatedDuration(c.Request.Context()).Seconds())
+	c.Header("Location", "/api/v1/jobs/"+job.ID.String())
+	c.Header("Retry-After", strconv.Itoa(jobPollIntervalSeconds))
+	response.Accepted(c, resp)
 }
 
-// List handles listing jobs for the authenticated user.
-// @Summary List jobs
-// @Description Lists all jobs for the authenticated user with pagination
+// runSync runs the pipeline of the new job inside the request and streams its
+// progress as NDJSON: a created event, then the events of worker.SyncRunner.
+func (h *JobHandler) runSync(c *gin.Context, job *models.Job) {
+	// The pipeline takes far longer than the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(worker.SyncRunTimeout + time.Minute)); err != nil {
+		h.logger.Warn("failed to extend synchronous job write deadline", zap.Error(err))
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	emit := func(event worker.SyncEvent) {
+		if err := encoder.Encode(event); err != nil {
+			h.logger.Debug("failed to write synchronous job event", zap.Error(err))
+			return
+		}
+		c.Writer.Flush()
+	}
+	emit(worker.SyncEvent{Event: worker.SyncEventCreated, Status: job.Status, Job: job.ToResponse()})
+
+	h.logger.Info("running job synchronously", zap.String("job_id", job.ID.String()))
+	if err := h.syncRunner.Run(c.Request.Context(), job.ID, middleware.GetRequestID(c), emit); err != nil {
+		h.logger.Warn("synchronous job failed", zap.String("job_id", job.ID.String()), zap.Error(err))
+	}
+}
+
+// wantsLegacyCreateResponse reports whether the client asked for the pre-202 job creation response.
+func wantsLegacyCreateResponse(c *gin.Context) bool {
+	return c.GetHeader("Accept-Version") == legacyCreateAPIVersion || c.Query("api_version") == legacyCreateAPIVersion
+}
+
+// BulkCreate handles creating one job per concept.
+// @Summary Create jobs in bulk
+// @Description Creates up to 50 jobs at once, one per concept, sharing model, image_candidates, aspect_ratio and suno_model. Each concept is validated like a single create. With atomic=true any rejected concept fails the whole request; otherwise valid concepts are created and rejected ones are listed. Rate limited per user. Returns 503 JOB_INTAKE_PAUSED while an admin has paused job intake.
 // @Tags jobs
+// @Accept json
 // @Produce json
-// @Param page query int false "Page number" default(1)
-// @Param per_page query int false "Items per page" default(10) maximum(100)
-// @Success 200 {object} response.Response{data=[]models.JobResponse,meta=response.Meta}
+// @Param input body models.BulkCreateJobsInput true "Bulk job creation input"
+// @Success 201 {object} response.Response{data=models.BulkCreateJobsResponse}
+// @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
+// @Failure 429 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Failure 503 {object} response.Response "Job intake is paused for maintenance"
 // @Security BearerAuth
-// @Router /jobs [get]
-func (h *JobHandler) List(c *gin.Context) {
-	// Get user ID from context
+// @Router /jobs/bulk [post]
+func (h *JobHandler) BulkCreate(c *gin.Context) {
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
-	// Parse pagination params
-	page := 1
-	perPage := 10
+	var input models.BulkCreateJobsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, apperrors.NewInvalidRequestBody())
+		return
+	}
+
+	if err := h.settingsService.CheckJobIntake(c.Request.Context()); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	if len(input.Concepts) == 0 {
+		response.Error(c, apperrors.NewFieldError("concepts", apperrors.FieldConceptsRequired, "at least one concept is required"))
+		return
+	}
+	if len(input.Concepts) > models.MaxBulkJobConcepts {
+		response.Error(c, apperrors.NewFieldError("concepts", apperrors.FieldTooManyConcepts,
+			fmt.Sprintf("at most %d concepts per request", models.MaxBulkJobConcepts)).
+			WithParams(map[string]string{"max": strconv.Itoa(models.MaxBulkJobConcepts)}))
+		return
+	}
+
+	// Batch-level checks apply once before any concept is considered
+	user, err := h.userRepo.GetByID(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to get user for bulk job creation",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+		)
+		response.Error(c, err)
+		return
+	}
+	keySources, err := h.requireProviderKeys(c.Request.Context(), user, len(input.Concepts))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
 
-	if pageStr := c.Query("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
+	accepted := make([]models.CreateJobInput, 0, len(input.Concepts))
+	acceptedIndexes := make([]int, 0, len(input.Concepts))
+	rejected := make([]models.BulkJobError, 0)
+	for i, concept := range input.Concepts {
+		item := models.CreateJobInput{
+			Concept:         service.NormalizeConcept(concept),
+			Model:           input.Model,
+			ImageCandidates: input.ImageCandidates,
+			AspectRatio:     input.AspectRatio,
+			SunoModel:       input.SunoModel,
+
+			OpenRouterKeySource: keySources.OpenRouter,
+			KIEKeySource:        keySources.KIE,
+			OrgID:               user.OrgID,
+		}
+
+		err := validateCreateJobInput(item, h.maxConceptLength)
+		if err == nil {
+			err = h.moderator.CheckConcept(c.Request.Context(), item.Concept)
+		}
+		if err != nil {
+			rejected = append(rejected, models.BulkJobError{
+				Index:   i,
+				Code:    apperrors.GetErrorCode(err),
+				Message: err.Error(),
+				Details: apperrors.GetDetails(err),
+			})
+			continue
 		}
+
+		accepted = append(accepted, item)
+		acceptedIndexes = append(acceptedIndexes, i)
 	}
 
-	if perPageStr := c.Query("per_page"); perPageStr != "" {
-		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 {
-			perPage = pp
-			if perPage > 100 {
-				perPage = 100
-			}
+	if len(accepted) == 0 || (input.Atomic && len(rejected) > 0) {
+		h.keyService.ReleaseKeys(c.Request.Context(), keySources, len(input.Concepts))
+		details := make(map[string]string, len(rejected))
+		for _, r := range rejected {
+			details[fmt.Sprintf("concepts[%d]", r.Index)] = r.Message
 		}
+		response.Error(c, apperrors.NewValidationError(details).WithCode(apperrors.CodeInvalidConcept))
+		return
 	}
 
-	// Get jobs
-	jobs, meta, err := h.jobService.List(c.Request.Context(), userID, page, perPage)
+	jobs, err := h.jobService.CreateBatch(c.Request.Context(), user, accepted)
 	if err != nil {
-		h.logger.Error("failed to list jobs",
+		h.keyService.ReleaseKeys(c.Request.Context(), keySources, len(input.Concepts))
+		h.logger.Error("failed to create job batch",
 			zap.Error(err),
 			zap.String("user_id", userID.String()),
 		)
@@ -231,150 +412,1119 @@ func (h *JobHandler) List(c *gin.Context) {
 		return
 	}
 
-	// Convert to responses
-	jobResponses := make([]*models.JobResponse, len(jobs))
+	// Rejected concepts never become jobs
+	h.keyService.ReleaseKeys(c.Request.Context(), keySources, len(input.Concepts)-len(jobs))
+
+	resp := models.BulkCreateJobsResponse{
+		Created:  make([]models.BulkCreatedJob, 0, len(jobs)),
+		Rejected: rejected,
+	}
 	for i, job := range jobs {
-		jobResponses[i] = job.ToResponse()
+		// A job whose task cannot be built is marked failed; its status reports that
+		_ = h.enqueueAnalyze(c, job.ID)
+		resp.Created = append(resp.Created, models.BulkCreatedJob{Index: acceptedIndexes[i], JobID: job.ID})
 	}
 
-	response.SuccessWithMeta(c, jobResponses, meta)
+	h.logger.Info("bulk jobs created",
+		zap.String("user_id", userID.String()),
+		zap.Int("created", len(resp.Created)),
+		zap.Int("rejected", len(resp.Rejected)),
+	)
+
+	response.Created(c, resp)
 }
 
-// GetByID handles getting a job by ID.
-// @Summary Get job by ID
-// @Description Gets a job by its ID for the authenticated user
+// validateCreateJobInput checks a single job's creation input. The concept
+// must already be normalized with service.NormalizeConcept.
+func validateCreateJobInput(input models.CreateJobInput, maxConceptLength int) error {
+	if err := service.ValidateConcept(input.Concept, maxConceptLength); err != nil {
+		return err
+	}
+	return validateJobSettings(input)
+}
+
+// validateJobSettings checks the settings of a job's creation input, everything
+// but the concept. Profile job defaults are held to the same rules.
+func validateJobSettings(input models.CreateJobInput) error {
+	if input.ImageCandidates != nil &&
+		(*input.ImageCandidates < models.MinImageCandidates || *input.ImageCandidates > models.MaxImageCandidates) {
+		return apperrors.NewFieldError("image_candidates", apperrors.FieldImageCandidatesRange,
+			"image_candidates must be between 1 and 3").
+			WithParams(map[string]string{
+				"min": strconv.Itoa(models.MinImageCandidates),
+				"max": strconv.Itoa(models.MaxImageCandidates),
+			})
+	}
+	if input.AspectRatio != nil && !kie.IsValidAspectRatio(*input.AspectRatio) {
+		return apperrors.NewFieldError("aspect_ratio", apperrors.FieldAspectRatioInvalid,
+			"aspect_ratio must be one of 16:9, 9:16, 1:1, 4:3, 3:4").
+			WithParams(map[string]string{"allowed": "16:9, 9:16, 1:1, 4:3, 3:4"})
+	}
+	if input.SunoModel != nil && !kie.IsValidModel(*input.SunoModel) {
+		allowed := strings.Join(kie.Models, ", ")
+		return apperrors.NewFieldError("suno_model", apperrors.FieldSunoModelInvalid,
+			"suno_model must be one of "+allowed).
+			WithParams(map[string]string{"allowed": allowed})
+	}
+	if input.ImageURL != nil && *input.ImageURL != "" {
+		if err := security.ValidatePublicURL(*input.ImageURL); err != nil {
+			return apperrors.NewFieldError("image_url", apperrors.FieldImageURLInvalid,
+				"image_url must be a public HTTPS URL: "+err.Error())
+		}
+	}
+	if input.ImageSource != nil {
+		if !models.IsRequestableImageSource(*input.ImageSource) {
+			allowed := strings.Join(models.RequestableImageSources, ", ")
+			return apperrors.NewFieldError("image_source", apperrors.FieldImageSourceInvalid,
+				"image_source must be one of "+allowed).
+				WithParams(map[string]string{"allowed": allowed})
+		}
+		if input.ImageURL != nil && *input.ImageURL != "" {
+			return apperrors.NewFieldError("image_source", apperrors.FieldImageSourceConflict,
+				"image_source cannot be combined with image_url")
+		}
+	}
+	if opts := input.VideoOptions; opts != nil && opts.FadeOutSeconds != nil &&
+		(*opts.FadeOutSeconds < 0 || *opts.FadeOutSeconds > models.MaxFadeOutSeconds) {
+		return apperrors.NewFieldError("video_options.fade_out_seconds", apperrors.FieldFadeOutSecondsRange,
+			fmt.Sprintf("fade_out_seconds must be between 0 and %d", models.MaxFadeOutSeconds)).
+			WithParams(map[string]string{"max": strconv.Itoa(models.MaxFadeOutSeconds)})
+	}
+	if opts := input.VideoOptions; opts != nil && opts.Preset != nil && !ffmpeg.IsValidPreset(*opts.Preset) {
+		allowed := strings.Join(ffmpeg.Presets, ", ")
+		return apperrors.NewFieldError("video_options.preset", apperrors.FieldVideoPresetInvalid,
+			"preset must be one of "+allowed).
+			WithParams(map[string]string{"allowed": allowed})
+	}
+	if input.MaxDurationSeconds != nil &&
+		(*input.MaxDurationSeconds < models.MinMaxDurationSeconds || *input.MaxDurationSeconds > models.MaxMaxDurationSeconds) {
+		return apperrors.NewFieldError("max_duration_seconds", apperrors.FieldMaxDurationRange,
+			fmt.Sprintf("max_duration_seconds must be between %d and %d", models.MinMaxDurationSeconds, models.MaxMaxDurationSeconds)).
+			WithParams(map[string]string{
+				"min": strconv.Itoa(models.MinMaxDurationSeconds),
+				"max": strconv.Itoa(models.MaxMaxDurationSeconds),
+			})
+	}
+	if input.CallbackMode != nil && !models.IsValidCallbackMode(*input.CallbackMode) {
+		allowed := strings.Join(models.CallbackModes, ", ")
+		return apperrors.NewFieldError("callback_mode", apperrors.FieldCallbackModeInvalid,
+			"callback_mode must be one of "+allowed).
+			WithParams(map[string]string{"allowed": allowed})
+	}
+	return nil
+}
+
+// errImageTooLarge is returned when an uploaded cover image exceeds r2.MaxUserImageSize.
+func errImageTooLarge() error {
+	maxMB := strconv.Itoa(r2.MaxUserImageSize >> 20)
+	return apperrors.NewPayloadTooLarge("image must be " + maxMB + "MB or less").
+		WithCode(apperrors.CodeImageTooLarge).
+		WithParams(map[string]string{"max_mb": maxMB})
+}
+
+// UploadImage handles replacing image generation with the user's own cover image.
+// @Summary Upload a job's cover image
+// @Description Stores a PNG, JPEG or WebP image (multipart field "image", max 10MB, type detected from the file content) as the job's cover. The pipeline then skips image generation. Only allowed before image generation starts.
 // @Tags jobs
+// @Accept multipart/form-data
 // @Produce json
 // @Param id path string true "Job ID" format(uuid)
+// @Param image formData file true "Cover image"
 // @Success 200 {object} response.Response{data=models.JobResponse}
+// @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
 // @Failure 403 {object} response.Response
 // @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 413 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Security BearerAuth
-// @Router /jobs/{id} [get]
-func (h *JobHandler) GetByID(c *gin.Context) {
-	// Get user ID from context
+// @Router /jobs/{id}/image [post]
+func (h *JobHandler) UploadImage(c *gin.Context) {
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
-	// Parse job ID from URL
-	jobIDStr := c.Param("id")
-	jobID, err := uuid.Parse(jobIDStr)
+	jobID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		response.BadRequest(c, "invalid job ID format")
+		response.Error(c, apperrors.NewBadRequest("invalid job ID format").WithCode(apperrors.CodeInvalidJobID))
 		return
 	}
 
-	// Get job
+	if h.r2Client == nil {
+		h.logger.Error("image upload requested but R2 storage is not configured")
+		response.InternalServerError(c, "storage is not configured")
+		return
+	}
+
+	// Check ownership and status before accepting the upload
 	job, err := h.jobService.GetByID(c.Request.Context(), userID, jobID)
 	if err != nil {
-		h.logger.Debug("failed to get job",
-			zap.Error(err),
-			zap.String("job_id", jobIDStr),
-			zap.String("user_id", userID.String()),
-		)
+		response.Error(c, err)
+		return
+	}
+	if !job.AcceptsUserImage() {
+		response.Error(c, apperrors.NewConflict("the image can only be set before image generation starts").WithCode(apperrors.CodeJobStatusConflict))
+		return
+	}
+
+	// Leave room for the multipart framing around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, r2.MaxUserImageSize+64*1024)
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			response.Error(c, errImageTooLarge())
+			return
+		}
+		response.BadRequest(c, "multipart field \"image\" is required")
+		return
+	}
+	if fileHeader.Size > r2.MaxUserImageSize {
+		response.Error(c, errImageTooLarge())
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.BadRequest(c, "failed to read image")
+		return
+	}
+	defer file.Close()
+
+	head := make([]byte, r2.UserImageSniffLength)
+	n, _ := io.ReadFull(file, head)
+	extension, contentType, err := r2.SniffUserImage(head[:n])
+	if err != nil {
+		response.ValidationError(c, map[string]string{"image": err.Error()})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		response.BadRequest(c, "failed to read image")
+		return
+	}
+
+	key := r2.UserImageKey(jobID.String(), extension)
+	if err := h.r2Client.Upload(c.Request.Context(), key, file, contentType); err != nil {
+		h.logger.Error("failed to upload user image", zap.Error(err), zap.String("job_id", jobID.String()))
+		response.Error(c, apperrors.NewInternalError(err))
+		return
+	}
+
+	imageURL, err := h.r2Client.AssetURL(c.Request.Context(), key)
+	if err != nil {
+		h.logger.Error("failed to resolve user image URL", zap.Error(err), zap.String("job_id", jobID.String()))
+		response.Error(c, apperrors.NewInternalError(err))
+		return
+	}
+
+	job, err = h.jobService.SetUserImage(c.Request.Context(), userID, jobID, key, imageURL)
+	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Success(c, job.ToResponse())
+	response.Success(c, job.ToResponseWithSigner(c.Request.Context(), h.assetSigner()))
 }
 
-// Cancel handles job cancellation requests.
-// @Summary Cancel a job
-// @Description Cancels a job if it's not in a terminal state
+// Share handles enabling a job's public share link.
+// @Summary Share a job
+// @Description Creates an unguessable public link token for a completed job (GET /share/{token}). Sharing an already shared job returns the existing token.
 // @Tags jobs
 // @Produce json
 // @Param id path string true "Job ID" format(uuid)
-// @Success 204 "No Content"
+// @Success 200 {object} response.Response{data=models.JobResponse}
 // @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
 // @Failure 403 {object} response.Response
 // @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Security BearerAuth
-// @Router /jobs/{id} [delete]
-func (h *JobHandler) Cancel(c *gin.Context) {
-	// Get user ID from context
+// @Router /jobs/{id}/share [post]
+func (h *JobHandler) Share(c *gin.Context) {
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
-	// Parse job ID from URL
-	jobIDStr := c.Param("id")
-	jobID, err := uuid.Parse(jobIDStr)
+	jobID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		response.BadRequest(c, "invalid job ID format")
+		response.Error(c, apperrors.NewBadRequest("invalid job ID format").WithCode(apperrors.CodeInvalidJobID))
 		return
 	}
 
-	// Cancel job
-	if err := h.jobService.Cancel(c.Request.Context(), userID, jobID); err != nil {
-		h.logger.Debug("failed to cancel job",
-			zap.Error(err),
-			zap.String("job_id", jobIDStr),
-			zap.String("user_id", userID.String()),
-		)
+	job, err := h.jobService.Share(c.Request.Context(), userID, jobID)
+	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	h.logger.Info("job cancelled",
-		zap.String("job_id", jobIDStr),
-		zap.String("user_id", userID.String()),
-	)
-
-	response.NoContent(c)
+	response.Success(c, job.ToResponseWithSigner(c.Request.Context(), h.assetSigner()))
 }
 
-// RetryYouTubeUpload enqueues a YouTube upload task for a completed job.
-func (h *JobHandler) RetryYouTubeUpload(c *gin.Context) {
+// UpdateTags handles replacing a job's tags.
+// @Summary Replace a job's tags
+// @Description Replaces the job's tags with the given list (max 10, 30 characters each). Tags are trimmed, lowercased and deduplicated; an empty list removes them all.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID" format(uuid)
+// @Param input body models.UpdateJobTagsInput true "New tags"
+// @Success 200 {object} response.Response{data=models.JobResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /jobs/{id}/tags [patch]
+func (h *JobHandler) UpdateTags(c *gin.Context) {
 	userID, ok := middleware.GetUserIDFromContext(c)
 	if !ok {
-		response.Unauthorized(c, "user not authenticated")
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
 
-	jobIDStr := c.Param("id")
-	jobID, err := uuid.Parse(jobIDStr)
+	jobID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		response.BadRequest(c, "invalid job ID format")
+		response.Error(c, apperrors.NewBadRequest("invalid job ID format").WithCode(apperrors.CodeInvalidJobID))
 		return
 	}
 
-	// Get job (service checks ownership via userID)
-	job, err := h.jobService.GetByID(c.Request.Context(), userID, jobID)
+	var input models.UpdateJobTagsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, apperrors.NewInvalidRequestBody())
+		return
+	}
+
+	job, err := h.jobService.UpdateTags(c.Request.Context(), userID, jobID, input.Tags)
 	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	// Only allow for completed jobs with video URL
-	if job.Status != models.StatusCompleted {
-		response.BadRequest(c, "job must be completed to upload to YouTube")
+	response.Success(c, job.ToResponseWithSigner(c.Request.Context(), h.assetSigner()))
+}
+
+// Unshare handles revoking a job's public share link.
+// @Summary Revoke a job's share link
+// @Description Revokes the job's public link; the old token stops working immediately
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID" format(uuid)
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /jobs/{id}/share [delete]
+func (h *JobHandler) Unshare(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
 		return
 	}
-	if job.VideoURL == nil || *job.VideoURL == "" {
-		response.BadRequest(c, "job has no video to upload")
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Error(c, apperrors.NewBadRequest("invalid job ID format").WithCode(apperrors.CodeInvalidJobID))
 		return
 	}
 
-	// Enqueue YouTube upload task
-	if err := worker.EnqueueTask(c.Request.Context(), h.asynqClient, worker.TypeUploadYouTube, jobID); err != nil {
-		h.logger.Error("failed to enqueue YouTube upload task", zap.Error(err))
-		response.InternalServerError(c, "failed to enqueue YouTube upload")
+	if err := h.jobService.Unshare(c.Request.Context(), userID, jobID); err != nil {
+		response.Error(c, err)
 		return
 	}
-	h.logger.Info("YouTube upload enqueued",
-		zap.String("job_id", jobIDStr),
-		zap.String("user_id", userID.String()),
-	)
 
-	response.Success(c, map[string]string{"message": "YouTube upload enqueued"})
+	response.NoContent(c)
+}
+
+// requireProviderKeys checks that the user can run count new jobs: usable OpenRouter
+// and KIE API keys (their own or the platform's), and KIE credits left on their own
+// KIE key. It returns the key sources to record on the jobs, whose platform quota
+// is reserved until released with keyService.ReleaseKeys.
+func (h *JobHandler) requireProviderKeys(ctx context.Context, user *models.User, count int) (*service.ProviderKeySources, error) {
+	keySources, err := h.keyService.RequireKeys(ctx, user, count)
+	if err != nil {
+		return nil, err
+	}
+	if keySources.KIE == models.KeySourceUser {
+		if err := h.creditService.CheckJobCredits(ctx, user); err != nil {
+			h.keyService.ReleaseKeys(ctx, keySources, count)
+			return nil, err
+		}
+	}
+	return keySources, nil
+}
+
+// enqueueAnalyze starts the pipeline for a newly created job.
+// It only returns an error when the task cannot be built, in which case the job is
+// marked failed; enqueue failures leave the job pending for the reconciler.
+func (h *JobHandler) enqueueAnalyze(c *gin.Context, jobID uuid.UUID) error {
+	task, err := worker.NewAnalyzeConceptTask(jobID, middleware.GetRequestID(c))
+	if err != nil {
+		h.logger.Error("failed to create analyze concept task",
+			zap.Error(err),
+			zap.String("job_id", jobID.String()),
+		)
+		// Job is created but task enqueue failed - mark job as failed
+		_ = h.jobService.MarkFailed(c.Request.Context(), jobID, "failed to enqueue analyze task")
+		return err
+	}
+
+	if err := enqueueOrOutbox(c.Request.Context(), h.outbox, h.asynqClient, task, jobID); err != nil {
+		// The job stays pending; the job reconciler re-enqueues it with the same TaskID
+		h.logger.Error("failed to enqueue analyze concept task, leaving job for reconciliation",
+			zap.Error(err),
+			zap.String("job_id", jobID.String()),
+		)
+	}
+
+	return nil
+}
+
+// List handles listing jobs for the authenticated user.
+// @Summary List jobs
+// @Description Lists all jobs for the authenticated user with pagination
+// @Tags jobs
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(10) maximum(100)
+// @Param status query string false "Comma-separated statuses to include"
+// @Param created_after query string false "Only jobs created at or after (RFC3339)"
+// @Param created_before query string false "Only jobs created before (RFC3339)"
+// @Param q query string false "Search the concept: words match as prefixes (full-text); queries under 3 characters or in Thai match as a case-insensitive substring"
+// @Param tags query string false "Comma-separated tags the jobs must all carry"
+// @Param include_deleted query bool false "Also list deleted jobs that can still be restored"
+// @Param sort query string false "Sort field and order, e.g. created_at:desc or updated_at:asc"
+// @Success 200 {object} response.Response{data=[]models.JobListItem,meta=response.Meta}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /jobs [get]
+func (h *JobHandler) List(c *gin.Context) {
+	// Get user ID from context
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	// Parse pagination params
+	page, perPage := parsePagination(c, 10, 100)
+
+	filter, details := parseJobFilter(c)
+	if len(details) > 0 {
+		response.ValidationError(c, details)
+		return
+	}
+
+	// Get jobs
+	jobs, meta, err := h.jobService.List(c.Request.Context(), userID, filter, page, perPage)
+	if err != nil {
+		h.logger.Error("failed to list jobs",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+		)
+		response.Error(c, err)
+		return
+	}
+
+	if signer := h.assetSigner(); signer != nil {
+		for _, item := range jobs {
+			item.ResolveAssetURLs(c.Request.Context(), signer)
+		}
+	}
+
+	response.SuccessWithMeta(c, jobs, meta)
+}
+
+// maxJobSearchLength bounds the q search parameter.
+const maxJobSearchLength = 200
+
+// parseJobFilter parses list filter query params.
+// Returns validation details keyed by parameter name when any value is invalid.
+func parseJobFilter(c *gin.Context) (models.JobFilter, map[string]string) {
+	var filter models.JobFilter
+	details := make(map[string]string)
+
+	if statusStr := c.Query("status"); statusStr != "" {
+		for _, status := range strings.Split(statusStr, ",") {
+			status = strings.TrimSpace(status)
+			if status == "" {
+				continue
+			}
+			if !models.IsValidStatus(status) {
+				details["status"] = fmt.Sprintf("invalid status %q", status)
+				break
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	if v := c.Query("created_after"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			details["created_after"] = "created_after must be an RFC3339 timestamp"
+		} else {
+			filter.CreatedAfter = &t
+		}
+	}
+
+	if v := c.Query("created_before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			details["created_before"] = "created_before must be an RFC3339 timestamp"
+		} else {
+			filter.CreatedBefore = &t
+		}
+	}
+
+	filter.Query = strings.TrimSpace(c.Query("q"))
+	if len(filter.Query) > maxJobSearchLength {
+		details["q"] = fmt.Sprintf("q must be at most %d characters", maxJobSearchLength)
+	}
+
+	filter.IncludeDeleted = c.Query("include_deleted") == "true"
+
+	switch scope := c.Query("scope"); scope {
+	case "", "own":
+	case models.JobScopeOrg:
+		filter.Scope = scope
+	default:
+		details["scope"] = "scope must be own or org"
+	}
+
+	if tagsStr := c.Query("tags"); tagsStr != "" {
+		tags, err := service.NormalizeTags(strings.Split(tagsStr, ","))
+		if err != nil {
+			details["tags"] = fmt.Sprintf("tags must list at most %d tags of at most %d characters", service.MaxJobTags, service.MaxJobTagLength)
+		} else {
+			filter.Tags = tags
+		}
+	}
+
+	if sortStr := c.Query("sort"); sortStr != "" {
+		field, order, _ := strings.Cut(sortStr, ":")
+		if order == "" {
+			order = models.SortDesc
+		}
+		if field != models.JobSortCreatedAt && field != models.JobSortUpdatedAt {
+			details["sort"] = "sort field must be created_at or updated_at"
+		} else if order != models.SortAsc && order != models.SortDesc {
+			details["sort"] = "sort order must be asc or desc"
+		} else {
+			filter.SortBy = field
+			filter.SortOrder = order
+		}
+	}
+
+	return filter, details
+}
+
+// GetByID handles getting a job by ID.
+// @Summary Get job by ID
+// @Description Gets a job by its ID for the authenticated user. include=agent_outputs adds each agent's model, reasoning and output summary (generated prompts are left out). Pending jobs waiting in the task queue also get queue_position (tasks ahead of them) and estimated_start_seconds from the median analyze stage duration; both are refreshed every few seconds.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID" format(uuid)
+// @Param include query string false "Comma-separated extra fields" Enums(agent_outputs)
+// @Param include_deleted query bool false "Also return the job if it was deleted"
+// @Success 200 {object} response.Response{data=models.JobResponse}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /jobs/{id} [get]
+func (h *JobHandler) GetByID(c *gin.Context) {
+	// Get user ID from context
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	// Parse job ID from URL
+	jobIDStr := c.Param("id")
+	jobID, err := uuid.Parse(jobIDStr)
+	if err != nil {
+		response.Error(c, apperrors.NewBadRequest("invalid job ID format").WithCode(apperrors.CodeInvalidJobID))
+		return
+	}
+
+	// Get job
+	getJob := h.jobService.GetVisible
+	if c.Query("include_deleted") == "true" {
+		getJob = h.jobService.GetByIDIncludingDeleted
+	}
+	job, err := getJob(c.Request.Context(), userID, jobID)
+	if err != nil {
+		h.logger.Debug("failed to get job",
+			zap.Error(err),
+			zap.String("job_id", jobIDStr),
+			zap.String("user_id", userID.String()),
+		)
+		response.Error(c, err)
+		return
+	}
+
+	resp := job.ToResponseWithSigner(c.Request.Context(), h.assetSigner())
+	if queryIncludes(c, "agent_outputs") {
+		resp.AgentOutputs = job.AgentOutputResponses()
+	}
+	if job.Status == models.StatusPending && h.queuePositions != nil {
+		if pos, ok := h.queuePositions.Estimate(c.Request.Context(), job.ID); ok {
+			resp.QueuePosition = &pos.Position
+			// Without recent analyze timings only the front of the queue can be estimated
+			if pos.EstimatedStart > 0 || pos.Position == 0 {
+				startSeconds := int(pos.EstimatedStart.Seconds())
+				resp.EstimatedStartSeconds = &startSeconds
+			}
+		}
+	}
+	response.Success(c, resp)
+}
+
+// queryIncludes reports whether the comma-separated include query parameter lists field.
+func queryIncludes(c *gin.Context, field string) bool {
+	for _, value := range strings.Split(c.Query("include"), ",") {
+		if strings.TrimSpace(value) == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Cancel handles job cancellation requests.
+// @Summary Cancel a job
+// @Description Cancels a job if it's not in a terminal state. This was DELETE /jobs/{id} before deleting jobs moved there.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID" format(uuid)
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /jobs/{id}/cancel [post]
+func (h *JobHandler) Cancel(c *gin.Context) {
+	// Get user ID from context
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	// Parse job ID from URL
+	jobIDStr := c.Param("id")
+	jobID, err := uuid.Parse(jobIDStr)
+	if err != nil {
+		response.Error(c, apperrors.NewBadRequest("invalid job ID format").WithCode(apperrors.CodeInvalidJobID))
+		return
+	}
+
+	// Cancel job
+	if err := h.jobService.Cancel(c.Request.Context(), userID, jobID); err != nil {
+		h.logger.Debug("failed to cancel job",
+			zap.Error(err),
+			zap.String("job_id", jobIDStr),
+			zap.String("user_id", userID.String()),
+		)
+		response.Error(c, err)
+		return
+	}
+
+	h.logger.Info("job cancelled",
+		zap.String("job_id", jobIDStr),
+		zap.String("user_id", userID.String()),
+	)
+
+	response.NoContent(c)
+}
+
+// Retry handles restarting a failed job.
+// @Summary Retry a failed job
+// @Description Restarts a failed, uncancelled job from the step that failed, keeping the outputs of earlier steps. Like job creation it requires usable provider keys and KIE credits (400 MISSING_OPENROUTER_KEY or MISSING_KIE_KEY, 429 QUOTA_EXCEEDED), and the job runs on the key sources checked now. Jobs that failed because Suno returned no playable tracks (error_code NO_PLAYABLE_SONGS) or whose audio could not be downloaded (AUDIO_UNAVAILABLE) restart from music generation. Returns the reset job and the step it restarts from.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID" format(uuid)
+// @Success 202 {object} response.Response{data=models.RetryJobResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 429 {object} response.Response "Platform key quota exceeded"
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /jobs/{id}/retry [post]
+func (h *JobHandler) Retry(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	jobIDStr := c.Param("id")
+	jobID, err := uuid.Parse(jobIDStr)
+	if err != nil {
+		response.Error(c, apperrors.NewBadRequest("invalid job ID format").WithCode(apperrors.CodeInvalidJobID))
+		return
+	}
+
+	// A retry calls the providers again, so it needs the same keys and credits as a new job
+	user, err := h.userRepo.GetByID(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to get user for job retry",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+		)
+		response.Error(c, err)
+		return
+	}
+	keySources, err := h.requireProviderKeys(c.Request.Context(), user, 1)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	job, step, err := h.jobService.Retry(c.Request.Context(), userID, jobID, keySources)
+	if err != nil {
+		h.keyService.ReleaseKeys(c.Request.Context(), keySources, 1)
+		response.Error(c, err)
+		return
+	}
+
+	task, err := worker.NewRetryTask(step, jobID, middleware.GetRequestID(c))
+	if err == nil {
+		err = enqueueOrOutbox(c.Request.Context(), h.outbox, h.asynqClient, task, jobID)
+		if errors.Is(err, asynq.ErrTaskIDConflict) || errors.Is(err, asynq.ErrDuplicateTask) {
+			// A previous run of the step is still queued; it picks the reset job up
+			err = nil
+		}
+	}
+	if err != nil {
+		h.logger.Error("failed to enqueue retry task",
+			zap.Error(err),
+			zap.String("job_id", jobIDStr),
+			zap.String("retry_from", step),
+		)
+		_ = h.jobService.MarkFailure(c.Request.Context(), jobID, models.JobFailure{
+			Message:   "failed to enqueue retry task",
+			RetryFrom: step,
+		})
+		h.keyService.ReleaseKeys(c.Request.Context(), keySources, 1)
+		response.InternalServerError(c, "failed to enqueue retry")
+		return
+	}
+
+	h.logger.Info("job retry enqueued",
+		zap.String("job_id", jobIDStr),
+		zap.String("user_id", userID.String()),
+		zap.String("retry_from", step),
+	)
+
+	response.Accepted(c, models.RetryJobResponse{
+		Job:       job.ToResponseWithSigner(c.Request.Context(), h.assetSigner()),
+		RetryFrom: step,
+	})
+}
+
+// Delete handles job deletion requests.
+// @Summary Delete a job
+// @Description Deletes a completed or failed job. It is hidden from the job list and can be restored with POST /jobs/{id}/restore for 30 days, after which it is purged along with its assets.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID" format(uuid)
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /jobs/{id} [delete]
+func (h *JobHandler) Delete(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	jobIDStr := c.Param("id")
+	jobID, err := uuid.Parse(jobIDStr)
+	if err != nil {
+		response.Error(c, apperrors.NewBadRequest("invalid job ID format").WithCode(apperrors.CodeInvalidJobID))
+		return
+	}
+
+	if err := h.jobService.Delete(c.Request.Context(), userID, jobID); err != nil {
+		h.logger.Debug("failed to delete job",
+			zap.Error(err),
+			zap.String("job_id", jobIDStr),
+			zap.String("user_id", userID.String()),
+		)
+		response.Error(c, err)
+		return
+	}
+
+	response.NoContent(c)
+}
+
+// Restore handles undoing a job deletion.
+// @Summary Restore a deleted job
+// @Description Restores a job deleted less than 30 days ago
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID" format(uuid)
+// @Success 200 {object} response.Response{data=models.JobResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /jobs/{id}/restore [post]
+func (h *JobHandler) Restore(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Error(c, apperrors.NewBadRequest("invalid job ID format").WithCode(apperrors.CodeInvalidJobID))
+		return
+	}
+
+	job, err := h.jobService.Restore(c.Request.Context(), userID, jobID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, job.ToResponseWithSigner(c.Request.Context(), h.assetSigner()))
+}
+
+// RetryYouTubeUpload enqueues a YouTube upload task for a completed job.
+// @Summary Upload a job to YouTube
+// @Description Enqueues a YouTube upload of a completed job's video, e.g. after connecting YouTube or when the automatic upload failed. The result is reported in youtube_url / youtube_error.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID" format(uuid)
+// @Success 200 {object} response.Response{data=map[string]string}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /jobs/{id}/youtube-upload [post]
+func (h *JobHandler) RetryYouTubeUpload(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	jobIDStr := c.Param("id")
+	jobID, err := uuid.Parse(jobIDStr)
+	if err != nil {
+		response.Error(c, apperrors.NewBadRequest("invalid job ID format").WithCode(apperrors.CodeInvalidJobID))
+		return
+	}
+
+	// Get job (service checks access via userID)
+	job, err := h.jobService.GetVisible(c.Request.Context(), userID, jobID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	// Only allow for completed jobs with video URL
+	if job.Status != models.StatusCompleted {
+		response.Error(c, apperrors.NewBadRequest("job must be completed to upload to YouTube").WithCode(apperrors.CodeJobNotCompleted))
+		return
+	}
+	if !job.HasVideo() {
+		response.BadRequest(c, "job has no video to upload")
+		return
+	}
+
+	// Enqueue YouTube upload task
+	err = worker.EnqueueTask(c.Request.Context(), h.asynqClient, tasks.TypeUploadYouTube, jobID, middleware.GetRequestID(c),
+		asynq.TaskID(tasks.DedupTaskID(tasks.TypeUploadYouTube, jobID)))
+	if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		h.logger.Error("failed to enqueue YouTube upload task", zap.Error(err))
+		response.InternalServerError(c, "failed to enqueue YouTube upload")
+		return
+	}
+	h.logger.Info("YouTube upload enqueued",
+		zap.String("job_id", jobIDStr),
+		zap.String("user_id", userID.String()),
+	)
+
+	response.Success(c, map[string]string{"message": "YouTube upload enqueued"})
+}
+
+// assetTrack is the download asset of one track kept with keep_all_tracks.
+const assetTrack = "track"
+
+// Download redirects to a fresh URL for a job asset stored in R2.
+// @Summary Download a job asset
+// @Description Redirects to the public URL or a fresh presigned URL for a completed job's asset. The audio and image of a failed job can be downloaded too; assets not stored in R2 redirect to the provider URL saved on the job. asset=track downloads one of the tracks kept with keep_all_tracks, chosen by track_id.
+// @Tags jobs
+// @Param id path string true "Job ID" format(uuid)
+// @Param asset query string false "Asset type" Enums(video, audio, image, thumbnail, track) default(video)
+// @Param track_id query string false "Track ID from the job's tracks (asset=track)"
+// @Success 302 "Redirect to the asset URL"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /jobs/{id}/download [get]
+func (h *JobHandler) Download(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	jobIDStr := c.Param("id")
+	jobID, err := uuid.Parse(jobIDStr)
+	if err != nil {
+		response.Error(c, apperrors.NewBadRequest("invalid job ID format").WithCode(apperrors.CodeInvalidJobID))
+		return
+	}
+
+	asset := c.DefaultQuery("asset", r2.AssetVideo)
+	var key string
+	if asset != assetTrack {
+		key, err = r2.JobAssetKey(asset, jobID.String())
+		if err != nil {
+			response.BadRequest(c, "invalid asset. Must be: video, audio, image, thumbnail, or track")
+			return
+		}
+	}
+
+	// Get job (service checks access via userID)
+	job, err := h.jobService.GetVisible(c.Request.Context(), userID, jobID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	// A failed job keeps the song and cover made before it failed
+	partial := job.Status == models.StatusFailed && (asset == r2.AssetAudio || asset == r2.AssetImage)
+	if job.Status != models.StatusCompleted && !partial {
+		response.Error(c, apperrors.NewConflict("job is not completed").WithCode(apperrors.CodeJobNotCompleted))
+		return
+	}
+
+	extension := r2.JobAssetExtension(asset)
+	var track *models.JobTrack
+	if asset == assetTrack {
+		track = job.Track(c.Query("track_id"))
+		if track == nil || track.Key == "" {
+			response.NotFound(c, "track not found")
+			return
+		}
+		key = track.Key
+		extension = r2.JobAssetExtension(r2.AssetAudio)
+	}
+
+	if h.r2Client == nil {
+		h.logger.Error("download requested but R2 storage is not configured")
+		response.InternalServerError(c, "storage is not configured")
+		return
+	}
+
+	exists, err := h.r2Client.Exists(c.Request.Context(), key)
+	if err != nil {
+		h.logger.Error("failed to check asset existence",
+			zap.Error(err),
+			zap.String("job_id", jobIDStr),
+			zap.String("key", key),
+		)
+		response.InternalServerError(c, "failed to locate asset")
+		return
+	}
+	if !exists {
+		if url := storedAssetURL(job, asset); url != "" {
+			c.Redirect(http.StatusFound, url)
+			return
+		}
+		response.NotFound(c, "asset not found")
+		return
+	}
+
+	// Prefer the public URL when configured; it never expires
+	if publicURL := h.r2Client.GetPublicURL(key); publicURL != "" {
+		c.Redirect(http.StatusFound, publicURL)
+		return
+	}
+
+	filename := downloadFilename(job, extension)
+	if track != nil {
+		filename = strings.TrimSuffix(filename, "."+extension) + "-" + track.ID + "." + extension
+	}
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+	url, err := h.r2Client.GetPresignedDownloadURL(c.Request.Context(), key, downloadURLExpiry, disposition)
+	if err != nil {
+		h.logger.Error("failed to generate presigned download URL",
+			zap.Error(err),
+			zap.String("job_id", jobIDStr),
+		)
+		response.InternalServerError(c, "failed to generate download URL")
+		return
+	}
+
+	c.Redirect(http.StatusFound, url)
+}
+
+// storedAssetURL returns the provider URL saved on the job for an audio or image
+// asset that is not stored in R2, or "" when there is none.
+func storedAssetURL(job *models.Job, asset string) string {
+	var url *string
+	switch asset {
+	case r2.AssetAudio:
+		url = job.AudioURL
+	case r2.AssetImage:
+		url = job.ImageURL
+	}
+	if url == nil {
+		return ""
+	}
+	return *url
+}
+
+// assetSigner returns the signer used to turn stored asset keys into URLs,
+// or nil when R2 is not configured.
+func (h *JobHandler) assetSigner() models.AssetURLSigner {
+	if h.r2Client == nil {
+		return nil
+	}
+	return h.r2Client
+}
+
+// Lyrics formats accepted by GetLyrics.
+const (
+	lyricsFormatJSON = "json"
+	lyricsFormatText = "txt"
+	lyricsFormatLRC  = "lrc"
+)
+
+// GetLyrics returns a job's lyrics split into sections.
+// @Summary Get job lyrics
+// @Description Returns the lyrics from the job's song prompt split into sections by their Suno metatags ([Verse 1], [Chorus], ...). Other tags such as [Whisper] are listed as section cues and ad-libs in parentheses stay on their line. format=txt or format=lrc downloads a plain text or LRC file instead (LRC lines are untimed).
+// @Tags jobs
+// @Produce json
+// @Produce plain
+// @Param id path string true "Job ID" format(uuid)
+// @Param format query string false "Response format" Enums(json, txt, lrc) default(json)
+// @Success 200 {object} response.Response{data=models.JobLyricsResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /jobs/{id}/lyrics [get]
+func (h *JobHandler) GetLyrics(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Error(c, apperrors.NewBadRequest("invalid job ID format").WithCode(apperrors.CodeInvalidJobID))
+		return
+	}
+
+	format := c.DefaultQuery("format", lyricsFormatJSON)
+	if format != lyricsFormatJSON && format != lyricsFormatText && format != lyricsFormatLRC {
+		response.BadRequest(c, "invalid format. Must be: json, txt, or lrc")
+		return
+	}
+
+	// Get job (service checks access via userID)
+	job, err := h.jobService.GetVisible(c.Request.Context(), userID, jobID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	// Instrumental prompts describe the music rather than holding lyrics
+	if job.SongPrompt == nil || job.SongPrompt.Instrumental || strings.TrimSpace(job.SongPrompt.Prompt) == "" {
+		response.Error(c, apperrors.NewNotFound("job has no lyrics").WithCode(apperrors.CodeLyricsUnavailable))
+		return
+	}
+
+	parsed := lyrics.Parse(job.SongPrompt.Prompt)
+
+	switch format {
+	case lyricsFormatText:
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": downloadFilename(job, lyricsFormatText)}))
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(parsed.Text()))
+	case lyricsFormatLRC:
+		var duration time.Duration
+		if song := job.SelectedSong(); song != nil {
+			duration = time.Duration(song.Duration * float64(time.Second))
+		}
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": downloadFilename(job, lyricsFormatLRC)}))
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(parsed.LRC(job.SongPrompt.Title, duration)))
+	default:
+		response.Success(c, models.JobLyricsResponse{
+			JobID:    job.ID,
+			Title:    job.SongPrompt.Title,
+			Sections: parsed.Sections,
+			Text:     parsed.Text(),
+		})
+	}
+}
+
+// downloadFilename builds a filesystem-safe download filename from the song title,
+// falling back to the job ID when no title is available.
+func downloadFilename(job *models.Job, extension string) string {
+	var title string
+	if job.SongPrompt != nil {
+		title = job.SongPrompt.Title
+	}
+
+	var sb strings.Builder
+	lastDash := false
+	for _, r := range strings.TrimSpace(title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r):
+			sb.WriteRune(r)
+			lastDash = false
+		case !lastDash && sb.Len() > 0:
+			sb.WriteRune('-')
+			lastDash = true
+		}
+	}
+
+	name := strings.TrimSuffix(sb.String(), "-")
+	if name == "" {
+		name = job.ID.String()
+	}
+	return fmt.Sprintf("%s.%s", name, extension)
 }
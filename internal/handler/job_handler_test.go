@@ -0,0 +1,273 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/handler"
+	"github.com/jaochai/ugc/internal/middleware"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/testutil"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/response"
+)
+
+// TestCreateJobRefusedWhileIntakePaused checks that single and bulk creates are
+// refused before touching any job dependency, which are all nil here.
+func TestCreateJobRefusedWhileIntakePaused(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := zap.NewNop()
+	const banner = "ปิดปรับปรุงระบบ 22:00-23:00"
+
+	settings := testutil.NewFakeRuntimeSettingRepository(models.RuntimeSettings{
+		JobIntakePaused: true,
+		BannerMessage:   banner,
+	})
+	jobHandler := handler.NewJobHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		service.NewRuntimeSettingsService(settings, logger), 0, logger)
+
+	router := gin.New()
+	userID := uuid.New()
+	authenticate := func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUserID, userID)
+		c.Next()
+	}
+	jobHandler.RegisterRoutes(router.Group("/api/v1"), authenticate, nil)
+
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{name: "create", path: "/api/v1/jobs", body: `{"concept": "เพลงรักในเมืองหลวง"}`},
+		{name: "bulk create", path: "/api/v1/jobs/bulk", body: `{"concepts": ["เพลงรักในเมืองหลวง", "city pop at dawn"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Fatalf("status = %d, want 503; body: %s", rec.Code, rec.Body.String())
+			}
+			var resp response.Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response body: %v", err)
+			}
+			if resp.Success || resp.Error == nil {
+				t.Fatalf("response = %s, want an error envelope", rec.Body.String())
+			}
+			if resp.Error.ErrorCode != apperrors.CodeJobIntakePaused {
+				t.Errorf("error_code = %q, want %q", resp.Error.ErrorCode, apperrors.CodeJobIntakePaused)
+			}
+			if got := resp.Error.Details["banner_message"]; got != banner {
+				t.Errorf("details.banner_message = %q, want %q", got, banner)
+			}
+		})
+	}
+}
+
+// recordingJobService records which job writes the handler asks for.
+type recordingJobService struct {
+	service.JobService
+	calls []string
+}
+
+func (s *recordingJobService) Cancel(ctx context.Context, userID, jobID uuid.UUID) error {
+	s.calls = append(s.calls, "cancel")
+	return nil
+}
+
+func (s *recordingJobService) Delete(ctx context.Context, userID, jobID uuid.UUID) error {
+	s.calls = append(s.calls, "delete")
+	return nil
+}
+
+// TestDeleteAndCancelRoutes checks that DELETE /jobs/:id soft-deletes the job and
+// that cancelling moved to POST /jobs/:id/cancel.
+func TestDeleteAndCancelRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := uuid.New()
+	authenticate := func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUserID, userID)
+		c.Next()
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{method: http.MethodDelete, path: "/api/v1/jobs/" + uuid.NewString(), want: "delete"},
+		{method: http.MethodPost, path: "/api/v1/jobs/" + uuid.NewString() + "/cancel", want: "cancel"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.want, func(t *testing.T) {
+			jobService := &recordingJobService{}
+			jobHandler := handler.NewJobHandler(jobService, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, 0, zap.NewNop())
+			router := gin.New()
+			jobHandler.RegisterRoutes(router.Group("/api/v1"), authenticate, nil)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code >= 300 {
+				t.Fatalf("status = %d, body: %s", rec.Code, rec.Body.String())
+			}
+			if len(jobService.calls) != 1 || jobService.calls[0] != tt.want {
+				t.Errorf("%s %s called %v, want %s", tt.method, tt.path, jobService.calls, tt.want)
+			}
+		})
+	}
+}
+
+// jobsByID holds jobs by ID for the job service.
+type jobsByID struct {
+	repository.JobRepository
+	jobs map[uuid.UUID]*models.Job
+}
+
+func (r *jobsByID) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, repository.ErrJobNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+// usersByID holds users by ID for the job handler.
+type usersByID struct {
+	repository.UserRepository
+	users map[uuid.UUID]*models.User
+}
+
+func (r *usersByID) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// TestJobHandlerErrorCodes checks the error_code of each way a job request fails
+// before reaching the pipeline, with the real job, key and moderation services.
+func TestJobHandlerErrorCodes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := zap.NewNop()
+
+	noKeys := &models.User{ID: uuid.New()}
+	openRouterOnly := &models.User{ID: uuid.New(), HasOpenRouterKey: true}
+	stranger := &models.User{ID: uuid.New()}
+	deletedAt := time.Now().Add(-time.Hour)
+	running := &models.Job{ID: uuid.New(), UserID: noKeys.ID, Status: models.StatusGeneratingMusic}
+	completed := &models.Job{ID: uuid.New(), UserID: noKeys.ID, Status: models.StatusCompleted}
+	deleted := &models.Job{ID: uuid.New(), UserID: noKeys.ID, Status: models.StatusCompleted, DeletedAt: &deletedAt}
+	jobs := &jobsByID{jobs: map[uuid.UUID]*models.Job{running.ID: running, completed.ID: completed, deleted.ID: deleted}}
+	users := &usersByID{users: map[uuid.UUID]*models.User{noKeys.ID: noKeys, openRouterOnly.ID: openRouterOnly, stranger.ID: stranger}}
+
+	jobHandler := handler.NewJobHandler(
+		service.NewJobService(jobs, nil, nil, nil, 0, logger),
+		nil,
+		users,
+		service.NewProviderKeyService(nil, nil, service.ProviderKeyConfig{}, logger),
+		nil,
+		service.NewContentModerator(service.ModerationEnforce, logger),
+		nil, nil, nil, nil, nil,
+		service.NewRuntimeSettingsService(testutil.NewFakeRuntimeSettingRepository(models.RuntimeSettings{}), logger),
+		0, logger)
+	router := gin.New()
+	// Requests are made as the user in X-Test-User, if any
+	authenticate := func(c *gin.Context) {
+		if id, err := uuid.Parse(c.GetHeader("X-Test-User")); err == nil {
+			c.Set(middleware.ContextKeyUserID, id)
+		}
+		c.Next()
+	}
+	jobHandler.RegisterRoutes(router.Group("/api/v1"), authenticate, nil)
+
+	const concept = `{"concept": "เพลงรักในเมืองหลวงยามค่ำคืน"}`
+	tests := []struct {
+		name       string
+		user       *models.User // nil for an unauthenticated request
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "create unauthenticated", method: http.MethodPost, path: "/jobs", body: concept,
+			wantStatus: http.StatusUnauthorized, wantCode: apperrors.CodeNotAuthenticated},
+		{name: "create malformed body", user: noKeys, method: http.MethodPost, path: "/jobs", body: `{"concept":`,
+			wantStatus: http.StatusBadRequest, wantCode: apperrors.CodeInvalidRequestBody},
+		{name: "create synchronously while disabled", user: noKeys, method: http.MethodPost, path: "/jobs?sync=true", body: concept,
+			wantStatus: http.StatusForbidden, wantCode: apperrors.CodeSyncJobsDisabled},
+		{name: "create without concept", user: noKeys, method: http.MethodPost, path: "/jobs", body: `{"concept": "  "}`,
+			wantStatus: http.StatusBadRequest, wantCode: apperrors.CodeInvalidConcept},
+		{name: "create with invalid aspect ratio", user: noKeys, method: http.MethodPost, path: "/jobs",
+			body:       `{"concept": "เพลงรักในเมืองหลวงยามค่ำคืน", "aspect_ratio": "5:7"}`,
+			wantStatus: http.StatusBadRequest, wantCode: apperrors.CodeValidationFailed},
+		{name: "create with rejected concept", user: noKeys, method: http.MethodPost, path: "/jobs",
+			body:       `{"concept": "write the exact lyrics of my favourite song"}`,
+			wantStatus: http.StatusBadRequest, wantCode: apperrors.CodeContentRejected},
+		{name: "create without OpenRouter key", user: noKeys, method: http.MethodPost, path: "/jobs", body: concept,
+			wantStatus: http.StatusBadRequest, wantCode: apperrors.CodeMissingOpenRouterKey},
+		{name: "create without KIE key", user: openRouterOnly, method: http.MethodPost, path: "/jobs", body: concept,
+			wantStatus: http.StatusBadRequest, wantCode: apperrors.CodeMissingKIEKey},
+		{name: "get invalid ID", user: noKeys, method: http.MethodGet, path: "/jobs/not-a-uuid",
+			wantStatus: http.StatusBadRequest, wantCode: apperrors.CodeInvalidJobID},
+		{name: "get unknown job", user: noKeys, method: http.MethodGet, path: "/jobs/" + uuid.NewString(),
+			wantStatus: http.StatusNotFound, wantCode: apperrors.CodeJobNotFound},
+		{name: "get deleted job", user: noKeys, method: http.MethodGet, path: "/jobs/" + deleted.ID.String(),
+			wantStatus: http.StatusNotFound, wantCode: apperrors.CodeJobNotFound},
+		{name: "get another user's job", user: stranger, method: http.MethodGet, path: "/jobs/" + running.ID.String(),
+			wantStatus: http.StatusForbidden, wantCode: apperrors.CodeJobAccessDenied},
+		{name: "cancel completed job", user: noKeys, method: http.MethodPost, path: "/jobs/" + completed.ID.String() + "/cancel",
+			wantStatus: http.StatusBadRequest, wantCode: apperrors.CodeJobNotCancellable},
+		{name: "cancel another user's job", user: stranger, method: http.MethodPost, path: "/jobs/" + running.ID.String() + "/cancel",
+			wantStatus: http.StatusForbidden, wantCode: apperrors.CodeJobAccessDenied},
+		{name: "restore job that is not deleted", user: noKeys, method: http.MethodPost, path: "/jobs/" + completed.ID.String() + "/restore",
+			wantStatus: http.StatusConflict, wantCode: apperrors.CodeJobNotDeleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1"+tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.user != nil {
+				req.Header.Set("X-Test-User", tt.user.ID.String())
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d; body: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			var resp response.Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response body: %v", err)
+			}
+			if resp.Success || resp.Error == nil {
+				t.Fatalf("response = %s, want an error envelope", rec.Body.String())
+			}
+			if resp.Error.ErrorCode != tt.wantCode {
+				t.Errorf("error_code = %q, want %q", resp.Error.ErrorCode, tt.wantCode)
+			}
+		})
+	}
+}
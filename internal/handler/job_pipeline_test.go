@@ -0,0 +1,178 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/kie"
+	"github.com/jaochai/ugc/internal/external/openrouter"
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/ffmpeg"
+	"github.com/jaochai/ugc/internal/handler"
+	"github.com/jaochai/ugc/internal/middleware"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/testutil"
+	"github.com/jaochai/ugc/internal/worker"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// TestCreateJobRunsPipelineToCompletion creates a job through JobHandler and runs
+// every worker task inline, against the test database, fake providers, a fake R2
+// bucket and the ffmpeg stub. It needs TEST_DATABASE_URL.
+func TestCreateJobRunsPipelineToCompletion(t *testing.T) {
+	db := testutil.NewDB(t)
+	testutil.InstallFakeFFmpeg(t, 125*time.Second)
+	gin.SetMode(gin.TestMode)
+
+	ctx := context.Background()
+	logger := zap.NewNop()
+	kieServer := testutil.NewFakeKIE(t)
+	r2Server := testutil.NewFakeR2(t)
+
+	cryptoService, err := service.NewCryptoService(base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		t.Fatalf("failed to create crypto service: %v", err)
+	}
+	r2Client, err := r2.NewClient(ctx, r2Server.Config())
+	if err != nil {
+		t.Fatalf("failed to create R2 client: %v", err)
+	}
+
+	// A user with their own provider keys
+	userRepo := repository.NewUserRepository(db)
+	user := &models.User{ID: uuid.New(), Email: "pipeline-" + uuid.NewString() + "@example.com", PasswordHash: "unused"}
+	if err := userRepo.Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	openRouterKey, _ := cryptoService.Encrypt("sk-or-test")
+	kieKey, _ := cryptoService.Encrypt("kie-test")
+	if err := userRepo.UpdateAPIKeys(ctx, user.ID, &openRouterKey, &kieKey); err != nil {
+		t.Fatalf("failed to set API keys: %v", err)
+	}
+
+	// One LLM reply per agent: song concept, song selection, image concept
+	chat := testutil.NewFakeChatClient(
+		`{"prompt": "[Verse]\nแสงไฟในเมืองหลวง", "style": "thai pop", "title": "แสงไฟ", "title_en": "City Lights"}`,
+		`{"selectedSongId": "song-2", "reasoning": "stronger chorus"}`,
+		`{"prompt": "neon city at night, cinematic", "aspect_ratio": "16:9", "resolution": "1K"}`,
+	)
+	music := &testutil.FakeMusicClient{Songs: []kie.SongData{
+		{Id: "song-1", AudioUrl: kieServer.URL() + "/files/song-1.mp3", Title: "แสงไฟ", Duration: 121},
+		{Id: "song-2", AudioUrl: kieServer.URL() + "/files/song-2.mp3", Title: "แสงไฟ", Duration: 125},
+	}}
+	image := &testutil.FakeImageClient{ImageURL: kieServer.URL() + "/files/cover.png"}
+
+	jobRepo := repository.NewJobRepository(db)
+	spendRepo := repository.NewUserSpendRepository(db)
+	orgRepo := repository.NewOrganizationRepository(db)
+	deps := &tasks.Dependencies{
+		JobRepo:          jobRepo,
+		UserRepo:         userRepo,
+		SystemPromptRepo: repository.NewSystemPromptRepository(db),
+		CryptoService:    cryptoService,
+		R2Client:         r2Client,
+		FFmpegProcessor:  ffmpeg.NewProcessor(1, logger),
+		Logger:           logger,
+		WorkerID:         "e2e",
+		KIEBaseURL:       kieServer.URL(),
+		ImageCandidates:  1,
+		SpendRepo:        spendRepo,
+		OrganizationRepo: orgRepo,
+
+		NewChatClient:  func(apiKey string) openrouter.ChatClient { return chat },
+		NewMusicClient: func(apiKey string) kie.MusicClient { return music },
+		NewImageClient: func(apiKey string) kie.ImageClient { return image },
+	}
+
+	jobService := service.NewJobService(jobRepo, orgRepo, spendRepo, nil, 0, logger)
+	jobHandler := handler.NewJobHandler(
+		jobService,
+		service.NewJobTemplateService(repository.NewJobTemplateRepository(db), logger),
+		userRepo,
+		service.NewProviderKeyService(repository.NewPlatformQuotaRepository(db), orgRepo, service.ProviderKeyConfig{}, logger),
+		service.NewKIECreditService(cryptoService, nil, service.KIECreditsConfig{BaseURL: kieServer.URL()}, logger),
+		service.NewContentModerator(service.ModerationOff, logger),
+		nil, nil, r2Client, nil,
+		worker.NewSyncRunner(deps, logger),
+		service.NewRuntimeSettingsService(repository.NewRuntimeSettingRepository(db), logger),
+		0,
+		logger,
+	)
+
+	router := gin.New()
+	authenticate := func(c *gin.Context) {
+		c.Set(middleware.ContextKeyUserID, user.ID)
+		c.Next()
+	}
+	jobHandler.RegisterRoutes(router.Group("/api/v1"), authenticate, nil)
+
+	body := `{"concept": "เพลงรักในเมืองหลวงยามค่ำคืน"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs?sync=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /jobs?sync=true status = %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	// The last NDJSON event is done, carrying the final job
+	var last worker.SyncEvent
+	var tasksRun []string
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var event worker.SyncEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+		}
+		if event.Event == worker.SyncEventTaskFinished {
+			tasksRun = append(tasksRun, event.Task)
+		}
+		last = event
+	}
+	if last.Event != worker.SyncEventDone {
+		t.Fatalf("last event = %+v, want %s; tasks run: %v", last, worker.SyncEventDone, tasksRun)
+	}
+	if last.Job == nil {
+		t.Fatal("done event has no job")
+	}
+
+	job, err := jobRepo.GetByID(ctx, last.Job.ID)
+	if err != nil {
+		t.Fatalf("failed to load job: %v", err)
+	}
+	if job.Status != models.StatusCompleted {
+		var jobErr string
+		if job.ErrorMessage != nil {
+			jobErr = *job.ErrorMessage
+		}
+		t.Fatalf("job status = %s, want %s (error: %q)", job.Status, models.StatusCompleted, jobErr)
+	}
+	if job.SelectedSongID == nil || *job.SelectedSongID != "song-2" {
+		t.Errorf("selected song = %v, want song-2", job.SelectedSongID)
+	}
+
+	videoKey, _ := r2.JobAssetKey(r2.AssetVideo, job.ID.String())
+	if job.VideoKey == nil || *job.VideoKey != videoKey {
+		t.Errorf("video key = %v, want %s", job.VideoKey, videoKey)
+	}
+	if video, ok := r2Server.Object(videoKey); !ok || !strings.HasPrefix(string(video), "fake video") {
+		t.Errorf("uploaded video = %q (found %v), want the ffmpeg stub's output", video, ok)
+	}
+	if len(chat.Requests) != 3 {
+		t.Errorf("LLM called %d times, want 3", len(chat.Requests))
+	}
+}
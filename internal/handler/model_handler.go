@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/openrouter"
+	"github.com/jaochai/ugc/internal/middleware"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/service"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/response"
+)
+
+// modelCatalogueTTL is how long a user's model list is cached in Redis.
+const modelCatalogueTTL = 10 * time.Minute
+
+// ModelInfo represents an OpenRouter model in the catalogue response
+type ModelInfo struct {
+	ID            string                  `json:"id"`
+	Name          string                  `json:"name"`
+	ContextLength int                     `json:"context_length"`
+	Pricing       openrouter.ModelPricing `json:"pricing"`
+}
+
+// ModelHandler handles the LLM model catalogue
+type ModelHandler struct {
+	userRepo      repository.UserRepository
+	cryptoService service.CryptoService
+	redisClient   *redis.Client
+	logger        *zap.Logger
+}
+
+// NewModelHandler creates a new ModelHandler instance.
+// redisClient may be nil, in which case the catalogue is not cached.
+func NewModelHandler(
+	userRepo repository.UserRepository,
+	cryptoService service.CryptoService,
+	redisClient *redis.Client,
+	logger *zap.Logger,
+) *ModelHandler {
+	return &ModelHandler{
+		userRepo:      userRepo,
+		cryptoService: cryptoService,
+		redisClient:   redisClient,
+		logger:        logger,
+	}
+}
+
+// RegisterRoutes registers model catalogue routes to the given router group
+func (h *ModelHandler) RegisterRoutes(rg *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	rg.GET("/models", authMiddleware, h.List)
+}
+
+// List returns the chat-capable OpenRouter models available to the user's API key
+// @Summary List available models
+// @Description Returns chat-capable OpenRouter models for the user's API key. Cached per user for 10 minutes
+// @Tags models
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]ModelInfo}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 502 {object} response.Response
+// @Router /models [get]
+func (h *ModelHandler) List(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	ctx := c.Request.Context()
+	if cached, ok := h.cachedModels(c, userID); ok {
+		response.Success(c, cached)
+		return
+	}
+
+	openRouterKey, _, err := h.userRepo.GetAPIKeys(ctx, userID)
+	if err != nil {
+		h.logger.Error("failed to get API keys", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, err)
+		return
+	}
+	if openRouterKey == nil || *openRouterKey == "" {
+		response.Error(c, apperrors.NewBadRequest("OpenRouter API key not configured").WithCode(apperrors.CodeMissingOpenRouterKey))
+		return
+	}
+
+	apiKey, err := h.cryptoService.Decrypt(*openRouterKey)
+	if err != nil {
+		h.logger.Error("failed to decrypt OpenRouter API key", zap.Error(err))
+		response.Error(c, errors.New("failed to decrypt API key"))
+		return
+	}
+
+	all, err := openrouter.NewClient(apiKey).ListModels(ctx)
+	if err != nil {
+		if errors.Is(err, openrouter.ErrUnauthorized) {
+			response.Error(c, apperrors.NewBadRequest("OpenRouter rejected the saved API key").WithCode(apperrors.CodeInvalidAPIKey))
+			return
+		}
+		h.logger.Error("failed to list OpenRouter models", zap.Error(err), zap.String("user_id", userID.String()))
+		response.Error(c, apperrors.NewBadGateway("failed to fetch models from OpenRouter").WithCode(apperrors.CodeUpstreamUnavailable).WithError(err))
+		return
+	}
+
+	catalogue := make([]ModelInfo, 0, len(all))
+	for i := range all {
+		if !all[i].IsChatCapable() {
+			continue
+		}
+		catalogue = append(catalogue, ModelInfo{
+			ID:            all[i].ID,
+			Name:          all[i].Name,
+			ContextLength: all[i].ContextLength,
+			Pricing:       all[i].Pricing,
+		})
+	}
+
+	h.cacheModels(c, userID, catalogue)
+	response.Success(c, catalogue)
+}
+
+// modelCatalogueKey returns the Redis key of a user's cached catalogue.
+func modelCatalogueKey(userID uuid.UUID) string {
+	return fmt.Sprintf("ugc:models:%s", userID.String())
+}
+
+// cachedModels returns the user's cached catalogue, if any. Cache errors are treated as misses.
+func (h *ModelHandler) cachedModels(c *gin.Context, userID uuid.UUID) ([]ModelInfo, bool) {
+	if h.redisClient == nil {
+		return nil, false
+	}
+
+	data, err := h.redisClient.Get(c.Request.Context(), modelCatalogueKey(userID)).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			h.logger.Warn("failed to read model catalogue cache", zap.Error(err))
+		}
+		return nil, false
+	}
+
+	var catalogue []ModelInfo
+	if err := json.Unmarshal(data, &catalogue); err != nil {
+		h.logger.Warn("failed to decode cached model catalogue", zap.Error(err))
+		return nil, false
+	}
+	return catalogue, true
+}
+
+// cacheModels stores the user's catalogue for modelCatalogueTTL. Failures are logged only.
+func (h *ModelHandler) cacheModels(c *gin.Context, userID uuid.UUID, catalogue []ModelInfo) {
+	if h.redisClient == nil {
+		return
+	}
+
+	data, err := json.Marshal(catalogue)
+	if err != nil {
+		h.logger.Warn("failed to encode model catalogue", zap.Error(err))
+		return
+	}
+
+	if err := h.redisClient.Set(c.Request.Context(), modelCatalogueKey(userID), data, modelCatalogueTTL).Err(); err != nil {
+		h.logger.Warn("failed to cache model catalogue", zap.Error(err))
+	}
+}
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/middleware"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/service"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/response"
+)
+
+// OrganizationHandler handles the user's organization.
+type OrganizationHandler struct {
+	orgService service.OrganizationService
+	audit      service.AuditService
+	logger     *zap.Logger
+}
+
+// NewOrganizationHandler creates a new OrganizationHandler instance.
+func NewOrganizationHandler(orgService service.OrganizationService, audit service.AuditService, logger *zap.Logger) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService: orgService,
+		audit:      audit,
+		logger:     logger,
+	}
+}
+
+// RegisterRoutes registers organization routes to the given router group.
+func (h *OrganizationHandler) RegisterRoutes(rg *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	orgs := rg.Group("/orgs")
+	orgs.Use(authMiddleware)
+	{
+		orgs.POST("", h.Create)
+		orgs.GET("/me", h.Get)
+		orgs.POST("/me/invitations", h.Invite)
+		orgs.DELETE("/me/invitations/:id", h.RevokeInvitation)
+		orgs.POST("/invitations/accept", h.AcceptInvitation)
+		orgs.PUT("/me/api-keys", h.UpdateAPIKeys)
+	}
+}
+
+// Create handles creating an organization.
+// @Summary Create an organization
+// @Description Creates an organization owned by the authenticated user. A user belongs to at most one organization. Jobs created afterwards belong to it and are visible to its members.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param input body models.CreateOrganizationInput true "Organization name"
+// @Success 201 {object} response.Response{data=models.OrganizationResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /orgs [post]
+func (h *OrganizationHandler) Create(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	var input models.CreateOrganizationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, apperrors.NewInvalidRequestBody())
+		return
+	}
+
+	org, err := h.orgService.Create(c.Request.Context(), userID, input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, org)
+}
+
+// Get handles fetching the user's organization.
+// @Summary Get my organization
+// @Description Gets the authenticated user's organization with its members and the user's role. Owners also see pending invitations. API keys are never returned, only whether they are set.
+// @Tags organizations
+// @Produce json
+// @Success 200 {object} response.Response{data=models.OrganizationResponse}
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /orgs/me [get]
+func (h *OrganizationHandler) Get(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	org, err := h.orgService.Get(c.Request.Context(), userID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, org)
+}
+
+// Invite handles inviting a user to the organization.
+// @Summary Invite a member
+// @Description Invites an email address to the authenticated owner's organization and emails it a link with a single-use token. The invitee accepts it with POST /orgs/invitations/accept while signed in with that address, within 7 days. role is owner or member (default member).
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param input body models.InviteMemberInput true "Invitation"
+// @Success 201 {object} response.Response{data=models.OrganizationInvitation}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /orgs/me/invitations [post]
+func (h *OrganizationHandler) Invite(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	var input models.InviteMemberInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, apperrors.NewInvalidRequestBody())
+		return
+	}
+
+	invitation, err := h.orgService.Invite(c.Request.Context(), userID, input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, invitation)
+}
+
+// RevokeInvitation handles revoking a pending invitation.
+// @Summary Revoke an invitation
+// @Description Revokes a pending invitation of the authenticated owner's organization; its link stops working. Owners only.
+// @Tags organizations
+// @Produce json
+// @Param id path string true "Invitation ID" format(uuid)
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /orgs/me/invitations/{id} [delete]
+func (h *OrganizationHandler) RevokeInvitation(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	invitationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "invalid invitation ID format")
+		return
+	}
+
+	if err := h.orgService.RevokeInvitation(c.Request.Context(), userID, invitationID); err != nil {
+		response.Error(c, err)
+		return
+	}
+	recordAudit(c, h.audit, userID, models.AuditActionInvitationRevoke, invitationID.String(), nil)
+
+	response.NoContent(c)
+}
+
+// AcceptInvitation handles joining an organization with an invitation token.
+// @Summary Accept an invitation
+// @Description Joins the organization of the invitation with the emailed token. The authenticated user's email must be the invited one, the invitation must not be expired, accepted or revoked, and the user must not already belong to an organization.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param input body models.AcceptInvitationInput true "Invitation token"
+// @Success 200 {object} response.Response{data=models.OrganizationResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /orgs/invitations/accept [post]
+func (h *OrganizationHandler) AcceptInvitation(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	var input models.AcceptInvitationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, apperrors.NewInvalidRequestBody())
+		return
+	}
+
+	org, err := h.orgService.AcceptInvitation(c.Request.Context(), userID, input.Token)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	recordAudit(c, h.audit, userID, models.AuditActionInvitationAccept, org.ID.String(), map[string]interface{}{
+		"role": org.Role,
+	})
+
+	response.Success(c, org)
+}
+
+// UpdateAPIKeys handles setting the organization's shared provider keys.
+// @Summary Update organization API keys
+// @Description Sets the OpenRouter and KIE keys used by members without their own key, before the platform key. Omitted keys are unchanged and empty strings remove them. Owners only.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param input body models.UpdateOrganizationAPIKeysInput true "API keys"
+// @Success 200 {object} response.Response{data=models.OrganizationResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /orgs/me/api-keys [put]
+func (h *OrganizationHandler) UpdateAPIKeys(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	var input models.UpdateOrganizationAPIKeysInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, apperrors.NewInvalidRequestBody())
+		return
+	}
+
+	org, err := h.orgService.UpdateAPIKeys(c.Request.Context(), userID, input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+	recordAudit(c, h.audit, userID, models.AuditActionOrganizationKeyUpdate, org.ID.String(), map[string]interface{}{
+		"openrouter_key_changed": input.OpenRouterAPIKey != nil,
+		"kie_key_changed":        input.KIEAPIKey != nil,
+		"openrouter_key_set":     org.HasOpenRouterKey,
+		"kie_key_set":            org.HasKIEKey,
+	})
+
+	response.Success(c, org)
+}
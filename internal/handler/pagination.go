@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parsePagination reads the page and per_page query parameters. Missing or
+// invalid values fall back to page 1 and defaultPerPage; per_page is capped at
+// maxPerPage.
+func parsePagination(c *gin.Context, defaultPerPage, maxPerPage int) (page, perPage int) {
+	page, perPage = 1, defaultPerPage
+
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		page = p
+	}
+	if pp, err := strconv.Atoi(c.Query("per_page")); err == nil && pp > 0 {
+		perPage = min(pp, maxPerPage)
+	}
+	return page, perPage
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParsePagination(t *testing.T) {
+	const defaultPerPage, maxPerPage = 20, 100
+
+	tests := []struct {
+		name        string
+		query       string
+		wantPage    int
+		wantPerPage int
+	}{
+		{name: "no parameters", query: "", wantPage: 1, wantPerPage: defaultPerPage},
+		{name: "page and per_page", query: "page=3&per_page=50", wantPage: 3, wantPerPage: 50},
+		{name: "per_page zero", query: "per_page=0", wantPage: 1, wantPerPage: defaultPerPage},
+		{name: "per_page negative", query: "per_page=-5", wantPage: 1, wantPerPage: defaultPerPage},
+		{name: "per_page at the cap", query: "per_page=100", wantPage: 1, wantPerPage: maxPerPage},
+		{name: "per_page over the cap", query: "per_page=1000", wantPage: 1, wantPerPage: maxPerPage},
+		{name: "page zero", query: "page=0", wantPage: 1, wantPerPage: defaultPerPage},
+		{name: "page negative", query: "page=-2", wantPage: 1, wantPerPage: defaultPerPage},
+		{name: "non-numeric", query: "page=two&per_page=ten", wantPage: 1, wantPerPage: defaultPerPage},
+		{name: "decimal", query: "page=1.5&per_page=2.5", wantPage: 1, wantPerPage: defaultPerPage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/jobs?"+tt.query, nil)
+
+			page, perPage := parsePagination(c, defaultPerPage, maxPerPage)
+			if page != tt.wantPage || perPage != tt.wantPerPage {
+				t.Errorf("parsePagination(%q) = (%d, %d), want (%d, %d)",
+					tt.query, page, perPage, tt.wantPage, tt.wantPerPage)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/middleware"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/service"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/response"
+)
+
+// ScheduleHandler handles job schedule HTTP requests.
+type ScheduleHandler struct {
+	scheduleService service.JobScheduleService
+	moderator       service.ContentModerator
+	logger          *zap.Logger
+
+	maxConceptLength int
+}
+
+// NewScheduleHandler creates a new ScheduleHandler instance.
+func NewScheduleHandler(scheduleService service.JobScheduleService, moderator service.ContentModerator, maxConceptLength int, logger *zap.Logger) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduleService:  scheduleService,
+		moderator:        moderator,
+		maxConceptLength: maxConceptLength,
+		logger:           logger,
+	}
+}
+
+// RegisterRoutes registers job schedule routes to the given router group.
+func (h *ScheduleHandler) RegisterRoutes(rg *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	schedules := rg.Group("/schedules")
+	schedules.Use(authMiddleware)
+	{
+		schedules.GET("", h.List)
+		schedules.POST("", h.Create)
+		schedules.GET("/:id", h.Get)
+		schedules.PUT("/:id", h.Update)
+		schedules.DELETE("/:id", h.Delete)
+	}
+}
+
+// List handles listing the user's job schedules.
+// @Summary List job schedules
+// @Description Lists the authenticated user's recurring job schedules, newest first
+// @Tags schedules
+// @Produce json
+// @Success 200 {object} response.Response{data=[]models.JobSchedule}
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /schedules [get]
+func (h *ScheduleHandler) List(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	schedules, err := h.scheduleService.List(c.Request.Context(), userID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, schedules)
+}
+
+// Create handles saving a new job schedule.
+// @Summary Create a job schedule
+// @Description Creates a job from the same concept (and optional template) every day, or every week on weekday, at time_of_day in timezone. Each user can keep up to 10 schedules. A schedule is disabled with a reason when a run cannot create its job, e.g. because an API key is missing.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param input body models.JobScheduleInput true "Schedule settings"
+// @Success 201 {object} response.Response{data=models.JobSchedule}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /schedules [post]
+func (h *ScheduleHandler) Create(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	input, ok := h.bindScheduleInput(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.scheduleService.Create(c.Request.Context(), userID, input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, schedule)
+}
+
+// Get handles fetching a single job schedule.
+// @Summary Get job schedule
+// @Description Gets one of the authenticated user's job schedules
+// @Tags schedules
+// @Produce json
+// @Param id path string true "Schedule ID" format(uuid)
+// @Success 200 {object} response.Response{data=models.JobSchedule}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /schedules/{id} [get]
+func (h *ScheduleHandler) Get(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	scheduleID, ok := parseScheduleID(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.scheduleService.Get(c.Request.Context(), userID, scheduleID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, schedule)
+}
+
+// Update handles replacing a job schedule.
+// @Summary Update a job schedule
+// @Description Replaces one of the authenticated user's job schedules and recomputes its next run. Setting enabled re-enables a schedule the scheduler disabled and clears disabled_reason.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param id path string true "Schedule ID" format(uuid)
+// @Param input body models.JobScheduleInput true "Schedule settings"
+// @Success 200 {object} response.Response{data=models.JobSchedule}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /schedules/{id} [put]
+func (h *ScheduleHandler) Update(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	scheduleID, ok := parseScheduleID(c)
+	if !ok {
+		return
+	}
+
+	input, ok := h.bindScheduleInput(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.scheduleService.Update(c.Request.Context(), userID, scheduleID, input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, schedule)
+}
+
+// Delete handles removing a job schedule.
+// @Summary Delete a job schedule
+// @Description Deletes one of the authenticated user's job schedules. Jobs it already created are unaffected.
+// @Tags schedules
+// @Produce json
+// @Param id path string true "Schedule ID" format(uuid)
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /schedules/{id} [delete]
+func (h *ScheduleHandler) Delete(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	scheduleID, ok := parseScheduleID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.scheduleService.Delete(c.Request.Context(), userID, scheduleID); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.NoContent(c)
+}
+
+// parseScheduleID parses the :id path parameter, writing a 400 response on failure.
+func parseScheduleID(c *gin.Context) (uuid.UUID, bool) {
+	scheduleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "invalid schedule ID format")
+		return uuid.Nil, false
+	}
+	return scheduleID, true
+}
+
+// bindScheduleInput binds and validates a schedule body, writing an error response on failure.
+// The concept is checked with the same rules and moderation as a single job create.
+func (h *ScheduleHandler) bindScheduleInput(c *gin.Context) (models.JobScheduleInput, bool) {
+	var input models.JobScheduleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BadRequest(c, "invalid request body")
+		return input, false
+	}
+
+	input.Concept = service.NormalizeConcept(input.Concept)
+	if input.Timezone == "" {
+		input.Timezone = "UTC"
+	}
+
+	if err := validateCreateJobInput(models.CreateJobInput{Concept: input.Concept}, h.maxConceptLength); err != nil {
+		response.Error(c, err)
+		return input, false
+	}
+	if details := models.ValidateSchedule(input.Frequency, input.TimeOfDay, input.Weekday, input.Timezone); len(details) > 0 {
+		response.ValidationError(c, details)
+		return input, false
+	}
+	if err := h.moderator.CheckConcept(c.Request.Context(), input.Concept); err != nil {
+		response.Error(c, err)
+		return input, false
+	}
+
+	return input, true
+}
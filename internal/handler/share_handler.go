@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/service"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/response"
+)
+
+// maxShareTokenLength bounds the :token parameter before it reaches the database.
+const maxShareTokenLength = 64
+
+// ShareHandler serves public, read-only views of shared jobs.
+type ShareHandler struct {
+	jobService service.JobService
+	r2Client   *r2.Client
+	logger     *zap.Logger
+}
+
+// NewShareHandler creates a new ShareHandler instance.
+func NewShareHandler(jobService service.JobService, r2Client *r2.Client, logger *zap.Logger) *ShareHandler {
+	return &ShareHandler{
+		jobService: jobService,
+		r2Client:   r2Client,
+		logger:     logger,
+	}
+}
+
+// RegisterRoutes registers the unauthenticated share routes.
+// rateLimitMiddleware, when non-nil, limits lookups per client.
+func (h *ShareHandler) RegisterRoutes(rg *gin.RouterGroup, rateLimitMiddleware gin.HandlerFunc) {
+	share := rg.Group("/share")
+	if rateLimitMiddleware != nil {
+		share.Use(rateLimitMiddleware)
+	}
+	share.GET("/:token", h.Get)
+}
+
+// Get returns the public view of a shared job.
+// @Summary Get a shared job
+// @Description Returns the title, a fresh video URL, duration and creation time of a shared, completed job. Revoked links and jobs that are no longer completed return 404.
+// @Tags share
+// @Produce json
+// @Param token path string true "Share token"
+// @Success 200 {object} response.Response{data=models.SharedJobResponse}
+// @Failure 404 {object} response.Response
+// @Failure 429 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /share/{token} [get]
+func (h *ShareHandler) Get(c *gin.Context) {
+	token := c.Param("token")
+	if token == "" || len(token) > maxShareTokenLength {
+		response.Error(c, apperrors.NewNotFound("shared job not found").WithCode(apperrors.CodeJobNotFound))
+		return
+	}
+
+	job, err := h.jobService.GetShared(c.Request.Context(), token)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	var signer models.AssetURLSigner
+	if h.r2Client != nil {
+		signer = h.r2Client
+	}
+
+	response.Success(c, job.ToSharedResponse(c.Request.Context(), signer))
+}
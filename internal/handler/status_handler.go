@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/worker"
+	"github.com/jaochai/ugc/pkg/response"
+)
+
+// StatusHandler serves the public status page data.
+type StatusHandler struct {
+	aggregator      *worker.StatusAggregator
+	settingsService service.RuntimeSettingsService
+	logger          *zap.Logger
+}
+
+// NewStatusHandler creates a new StatusHandler instance.
+func NewStatusHandler(aggregator *worker.StatusAggregator, settingsService service.RuntimeSettingsService, logger *zap.Logger) *StatusHandler {
+	return &StatusHandler{
+		aggregator:      aggregator,
+		settingsService: settingsService,
+		logger:          logger,
+	}
+}
+
+// RegisterRoutes registers the unauthenticated status route.
+func (h *StatusHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/status", h.Get)
+}
+
+// Get returns the aggregate health of the generator.
+// @Summary Get the service status
+// @Description Public, coarse health of the generator for a status page: pipeline_ok is false when jobs failed in the last 15 minutes and none completed; providers maps openrouter, kie and r2 to operational, degraded, down or unknown (too few recent calls) from their recent error rates; queue_depth is low, medium or high; maintenance carries the admins' banner message and whether new jobs are paused, and is omitted when there is none. Contains no per-user data and is cached for up to 60 seconds, by the server and by CDNs (Cache-Control: public).
+// @Tags status
+// @Produce json
+// @Success 200 {object} response.Response{data=models.ServiceStatus}
+// @Router /status [get]
+func (h *StatusHandler) Get(c *gin.Context) {
+	status := h.aggregator.Status(c.Request.Context())
+	// The banner has its own, shorter cache so a pause shows up quickly
+	status.Maintenance = h.settingsService.Notice(c.Request.Context())
+
+	// Shared caches may keep the response until the server recomputes it
+	maxAge := int((worker.StatusCacheTTL - time.Since(status.UpdatedAt)).Seconds())
+	maxAge = max(maxAge, 0)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d, s-maxage=%d", maxAge, maxAge))
+	c.Header("Last-Modified", status.UpdatedAt.Format(http.TimeFormat))
+
+	response.Success(c, status)
+}
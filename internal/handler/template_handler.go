@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/agents"
+	"github.com/jaochai/ugc/internal/external/kie"
+	"github.com/jaochai/ugc/internal/middleware"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/service"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/response"
+)
+
+// maxTemplateNameLength bounds a job template's name.
+const maxTemplateNameLength = 100
+
+// TemplateHandler handles job template HTTP requests.
+type TemplateHandler struct {
+	templateService service.JobTemplateService
+	logger          *zap.Logger
+}
+
+// NewTemplateHandler creates a new TemplateHandler instance.
+func NewTemplateHandler(templateService service.JobTemplateService, logger *zap.Logger) *TemplateHandler {
+	return &TemplateHandler{
+		templateService: templateService,
+		logger:          logger,
+	}
+}
+
+// RegisterRoutes registers job template routes to the given router group.
+func (h *TemplateHandler) RegisterRoutes(rg *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	templates := rg.Group("/templates")
+	templates.Use(authMiddleware)
+	{
+		templates.GET("", h.List)
+		templates.POST("", h.Create)
+		templates.GET("/:id", h.Get)
+		templates.PUT("/:id", h.Update)
+		templates.DELETE("/:id", h.Delete)
+	}
+}
+
+// List handles listing the user's job templates.
+// @Summary List job templates
+// @Description Lists the authenticated user's job templates, newest first
+// @Tags templates
+// @Produce json
+// @Success 200 {object} response.Response{data=[]models.JobTemplate}
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /templates [get]
+func (h *TemplateHandler) List(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	templates, err := h.templateService.List(c.Request.Context(), userID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, templates)
+}
+
+// Create handles saving a new job template.
+// @Summary Create a job template
+// @Description Saves job settings (model, image candidates, aspect ratio, agent prompt overrides) for reuse with POST /jobs template_id. Each user can keep up to 20 templates.
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param input body models.JobTemplateInput true "Template settings"
+// @Success 201 {object} response.Response{data=models.JobTemplate}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /templates [post]
+func (h *TemplateHandler) Create(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	input, ok := bindTemplateInput(c)
+	if !ok {
+		return
+	}
+
+	template, err := h.templateService.Create(c.Request.Context(), userID, input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, template)
+}
+
+// Get handles fetching a single job template.
+// @Summary Get job template
+// @Description Gets one of the authenticated user's job templates
+// @Tags templates
+// @Produce json
+// @Param id path string true "Template ID" format(uuid)
+// @Success 200 {object} response.Response{data=models.JobTemplate}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /templates/{id} [get]
+func (h *TemplateHandler) Get(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	templateID, ok := parseTemplateID(c)
+	if !ok {
+		return
+	}
+
+	template, err := h.templateService.Get(c.Request.Context(), userID, templateID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, template)
+}
+
+// Update handles replacing a job template's settings.
+// @Summary Update a job template
+// @Description Replaces the name and settings of one of the authenticated user's job templates
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path string true "Template ID" format(uuid)
+// @Param input body models.JobTemplateInput true "Template settings"
+// @Success 200 {object} response.Response{data=models.JobTemplate}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /templates/{id} [put]
+func (h *TemplateHandler) Update(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	templateID, ok := parseTemplateID(c)
+	if !ok {
+		return
+	}
+
+	input, ok := bindTemplateInput(c)
+	if !ok {
+		return
+	}
+
+	template, err := h.templateService.Update(c.Request.Context(), userID, templateID, input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, template)
+}
+
+// Delete handles removing a job template.
+// @Summary Delete a job template
+// @Description Deletes one of the authenticated user's job templates. Jobs already created from it are unaffected.
+// @Tags templates
+// @Produce json
+// @Param id path string true "Template ID" format(uuid)
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /templates/{id} [delete]
+func (h *TemplateHandler) Delete(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	templateID, ok := parseTemplateID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.templateService.Delete(c.Request.Context(), userID, templateID); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.NoContent(c)
+}
+
+// parseTemplateID parses the :id path parameter, writing a 400 response on failure.
+func parseTemplateID(c *gin.Context) (uuid.UUID, bool) {
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "invalid template ID format")
+		return uuid.Nil, false
+	}
+	return templateID, true
+}
+
+// bindTemplateInput binds and validates a template body, writing a 400 response on failure.
+func bindTemplateInput(c *gin.Context) (models.JobTemplateInput, bool) {
+	var input models.JobTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BadRequest(c, "invalid request body")
+		return input, false
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if details := validateTemplateInput(&input); len(details) > 0 {
+		response.ValidationError(c, details)
+		return input, false
+	}
+
+	return input, true
+}
+
+// validateTemplateInput checks a template's fields with the same rules as job creation
+// and custom prompts. Blank prompt overrides are cleared so the user's own prompts apply.
+func validateTemplateInput(input *models.JobTemplateInput) map[string]string {
+	details := make(map[string]string)
+
+	if input.Name == "" {
+		details["name"] = "name is required"
+	} else if len(input.Name) > maxTemplateNameLength {
+		details["name"] = fmt.Sprintf("name must be %d characters or less", maxTemplateNameLength)
+	}
+	if input.ImageCandidates != nil &&
+		(*input.ImageCandidates < models.MinImageCandidates || *input.ImageCandidates > models.MaxImageCandidates) {
+		details["image_candidates"] = "image_candidates must be between 1 and 3"
+	}
+	if input.AspectRatio != nil && !kie.IsValidAspectRatio(*input.AspectRatio) {
+		details["aspect_ratio"] = "aspect_ratio must be one of 16:9, 9:16, 1:1, 4:3, 3:4"
+	}
+
+	if p := input.PromptOverrides; p != nil {
+		overrides := map[string]**string{
+			models.PromptTypeSongConcept:   &p.SongConceptPrompt,
+			models.PromptTypeSongSelector:  &p.SongSelectorPrompt,
+			models.PromptTypeImageConcept:  &p.ImageConceptPrompt,
+			models.PromptTypeImageSelector: &p.ImageSelectorPrompt,
+		}
+		for promptType, prompt := range overrides {
+			if *prompt == nil {
+				continue
+			}
+			if strings.TrimSpace(**prompt) == "" {
+				*prompt = nil
+				continue
+			}
+			if err := agents.ValidateCustomPrompt(**prompt); err != nil {
+				details["prompt_overrides."+promptType+"_prompt"] = err.Error()
+			}
+		}
+		if *p == (models.AgentPrompts{}) {
+			input.PromptOverrides = nil
+		}
+	}
+
+	return details
+}
@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/middleware"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/security"
+	"github.com/jaochai/ugc/internal/service"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/response"
+)
+
+// maxUserWebhookURLLength bounds a user webhook's URL.
+const maxUserWebhookURLLength = 2048
+
+// UserWebhookHandler handles the user's outbound webhook settings.
+type UserWebhookHandler struct {
+	webhookService service.UserWebhookService
+	logger         *zap.Logger
+}
+
+// NewUserWebhookHandler creates a new UserWebhookHandler instance.
+func NewUserWebhookHandler(webhookService service.UserWebhookService, logger *zap.Logger) *UserWebhookHandler {
+	return &UserWebhookHandler{
+		webhookService: webhookService,
+		logger:         logger,
+	}
+}
+
+// RegisterRoutes registers user webhook routes to the given router group.
+func (h *UserWebhookHandler) RegisterRoutes(rg *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	webhooks := rg.Group("/auth/webhooks")
+	webhooks.Use(authMiddleware)
+	{
+		webhooks.GET("", h.List)
+		webhooks.POST("", h.Create)
+		webhooks.GET("/:id", h.Get)
+		webhooks.PUT("/:id", h.Update)
+		webhooks.DELETE("/:id", h.Delete)
+	}
+}
+
+// List handles listing the user's webhooks.
+// @Summary List webhooks
+// @Description Lists the authenticated user's outbound webhooks with their delivery count, failure count and last delivery status
+// @Tags webhooks
+// @Produce json
+// @Success 200 {object} response.Response{data=[]models.UserWebhook}
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /auth/webhooks [get]
+func (h *UserWebhookHandler) List(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	webhooks, err := h.webhookService.List(c.Request.Context(), userID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, webhooks)
+}
+
+// Create handles registering a new webhook.
+// @Summary Create a webhook
+// @Description Registers an HTTPS endpoint that receives a signed POST when the user's jobs complete or fail. The signing secret is only returned in this response. Each user can register up to 5 webhooks.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param input body models.UserWebhookInput true "Webhook settings"
+// @Success 201 {object} response.Response{data=models.CreatedUserWebhook}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /auth/webhooks [post]
+func (h *UserWebhookHandler) Create(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	input, ok := bindUserWebhookInput(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.webhookService.Create(c.Request.Context(), userID, input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, webhook)
+}
+
+// Get handles fetching a single webhook.
+// @Summary Get webhook
+// @Description Gets one of the authenticated user's webhooks, including its last delivery status
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID" format(uuid)
+// @Success 200 {object} response.Response{data=models.UserWebhook}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /auth/webhooks/{id} [get]
+func (h *UserWebhookHandler) Get(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	webhookID, ok := parseUserWebhookID(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.webhookService.Get(c.Request.Context(), userID, webhookID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, webhook)
+}
+
+// Update handles replacing a webhook's settings.
+// @Summary Update a webhook
+// @Description Replaces the URL, events and enabled flag of one of the authenticated user's webhooks. The signing secret is unchanged.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Webhook ID" format(uuid)
+// @Param input body models.UserWebhookInput true "Webhook settings"
+// @Success 200 {object} response.Response{data=models.UserWebhook}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /auth/webhooks/{id} [put]
+func (h *UserWebhookHandler) Update(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	webhookID, ok := parseUserWebhookID(c)
+	if !ok {
+		return
+	}
+
+	input, ok := bindUserWebhookInput(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.webhookService.Update(c.Request.Context(), userID, webhookID, input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, webhook)
+}
+
+// Delete handles removing a webhook.
+// @Summary Delete a webhook
+// @Description Deletes one of the authenticated user's webhooks. Pending deliveries to it are dropped.
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID" format(uuid)
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /auth/webhooks/{id} [delete]
+func (h *UserWebhookHandler) Delete(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		response.Error(c, apperrors.NewUnauthorized("user not authenticated").WithCode(apperrors.CodeNotAuthenticated))
+		return
+	}
+
+	webhookID, ok := parseUserWebhookID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.webhookService.Delete(c.Request.Context(), userID, webhookID); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.NoContent(c)
+}
+
+// parseUserWebhookID parses the :id path parameter, writing a 400 response on failure.
+func parseUserWebhookID(c *gin.Context) (uuid.UUID, bool) {
+	webhookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "invalid webhook ID format")
+		return uuid.Nil, false
+	}
+	return webhookID, true
+}
+
+// bindUserWebhookInput binds and validates a webhook body, writing a 400 response on failure.
+func bindUserWebhookInput(c *gin.Context) (models.UserWebhookInput, bool) {
+	var input models.UserWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BadRequest(c, "invalid request body")
+		return input, false
+	}
+
+	input.URL = strings.TrimSpace(input.URL)
+	if details := validateUserWebhookInput(input); len(details) > 0 {
+		response.ValidationError(c, details)
+		return input, false
+	}
+
+	return input, true
+}
+
+// validateUserWebhookInput checks the URL (HTTPS, public address) and events.
+func validateUserWebhookInput(input models.UserWebhookInput) map[string]string {
+	details := make(map[string]string)
+
+	switch {
+	case input.URL == "":
+		details["url"] = "url is required"
+	case len(input.URL) > maxUserWebhookURLLength:
+		details["url"] = "url is too long"
+	default:
+		if err := security.ValidatePublicURL(input.URL); err != nil {
+			details["url"] = "url must be a public HTTPS URL: " + err.Error()
+		}
+	}
+
+	for _, event := range input.Events {
+		if !isUserWebhookEvent(event) {
+			details["events"] = "events must be one of " + strings.Join(models.UserWebhookEvents, ", ")
+			break
+		}
+	}
+
+	return details
+}
+
+// isUserWebhookEvent reports whether event is a known user webhook event.
+func isUserWebhookEvent(event string) bool {
+	for _, known := range models.UserWebhookEvents {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
@@ -2,47 +2,84 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
 
-	apperrors "github.com/jaochai/ugc/pkg/errors"
-
+	"github.com/jaochai/ugc/internal/metrics"
+	"github.com/jaochai/ugc/internal/middleware"
 	"github.com/jaochai/ugc/internal/models"
 	"github.com/jaochai/ugc/internal/repository"
-	"github.com/jaochai/ugc/internal/security"
-	"github.com/jaochai/ugc/internal/service"
 	"github.com/jaochai/ugc/internal/worker"
 )
 
+// claimedCallbackKey is the gin context key holding the callback claimed by claimCallback.
+const claimedCallbackKey = "webhook_claimed_callback"
+
+// Webhook capture settings (see captureCallback).
+const (
+	// webhookBodyKey is the gin context key holding the buffered request body.
+	webhookBodyKey = "webhook_body"
+	// webhookParseErrorKey is the gin context key holding the payload parse error, if any.
+	webhookParseErrorKey = "webhook_parse_error"
+	// maxCapturedBodySize caps the stored body; longer bodies are truncated.
+	maxCapturedBodySize = 64 * 1024
+	// webhookCaptureTimeout bounds the background insert of a captured callback.
+	webhookCaptureTimeout = 5 * time.Second
+)
+
+// capturedWebhookHeaders are the request headers stored with a captured callback.
+// The callback URL itself is not stored because it contains the webhook token.
+var capturedWebhookHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"User-Agent",
+	"X-Request-ID",
+	"X-Forwarded-For",
+}
+
+// claimedCallback identifies a callback recorded in processed_webhooks.
+type claimedCallback struct {
+	source       string
+	taskID       string
+	callbackType string
+}
+
 // SunoWebhookPayload represents the callback payload from KIE Suno API.
 // https://docs.kie.ai/suno-api/quickstart#callback-format
 type SunoWebhookPayload struct {
 	Code int    `json:"code"`
 	Msg  string `json:"msg"`
 	Data struct {
-		CallbackType string `json:"callbackType"` // "text", "first", "complete"
-		TaskID       string `json:"task_id"`      // Note: snake_case from KIE API
-		Data         []struct {
-			ID             string  `json:"id"`
-			AudioURL       string  `json:"audio_url"` // Note: snake_case from KIE API
-			StreamAudioURL string  `json:"stream_audio_url,omitempty"`
-			ImageURL       string  `json:"image_url,omitempty"`
-			Title          string  `json:"title"`
-			Prompt         string  `json:"prompt,omitempty"`
-			Tags           string  `json:"tags,omitempty"`
-			Duration       float64 `json:"duration"`
-			CreateTime     int64   `json:"createTime,omitempty"`
-		} `json:"data"`
-		ErrorMessage string `json:"errorMessage,omitempty"`
+		CallbackType string            `json:"callbackType"` // "text", "first", "complete"
+		TaskID       string            `json:"task_id"`      // Note: snake_case from KIE API
+		Data         []SunoWebhookSong `json:"data"`
+		ErrorMessage string            `json:"errorMessage,omitempty"`
 	} `json:"data"`
 }
 
+// SunoWebhookSong is one generated track in a Suno callback.
+type SunoWebhookSong struct {
+	ID             string  `json:"id"`
+	AudioURL       string  `json:"audio_url"` // Note: snake_case from KIE API
+	StreamAudioURL string  `json:"stream_audio_url,omitempty"`
+	ImageURL       string  `json:"image_url,omitempty"`
+	Title          string  `json:"title"`
+	Prompt         string  `json:"prompt,omitempty"`
+	Tags           string  `json:"tags,omitempty"`
+	Duration       float64 `json:"duration"`
+	CreateTime     int64   `json:"createTime,omitempty"`
+}
+
 // NanoWebhookPayload represents the callback payload from KIE NanoBanana API.
 // Uses the same format as TaskStatusResponse but delivered via webhook.
 // https://docs.kie.ai/market/common/get-task-detail
@@ -60,32 +97,36 @@ type NanoWebhookPayload struct {
 }
 
 // WebhookHandler handles webhook callbacks from external services.
+// Parsed callbacks are applied by a WebhookProcessor.
 type WebhookHandler struct {
-	jobRepo      repository.JobRepository
-	jobService   service.JobService
-	asynqClient  *asynq.Client
-	urlValidator *security.URLValidator
-	logger       *zap.Logger
+	processor     *WebhookProcessor
+	webhookRepo   repository.ProcessedWebhookRepository
+	eventRepo     repository.WebhookEventRepository
+	captureEvents bool
+	asynqClient   *asynq.Client
+	metrics       *metrics.Metrics
+	logger        *zap.Logger
 }
 
 // NewWebhookHandler creates a new WebhookHandler instance.
+// When captureEvents is set, authenticated callbacks are stored in eventRepo for debugging.
 func NewWebhookHandler(
-	jobRepo repository.JobRepository,
-	jobService service.JobService,
+	processor *WebhookProcessor,
+	webhookRepo repository.ProcessedWebhookRepository,
+	eventRepo repository.WebhookEventRepository,
+	captureEvents bool,
 	asynqClient *asynq.Client,
-	urlValidator *security.URLValidator,
+	appMetrics *metrics.Metrics,
 	logger *zap.Logger,
 ) *WebhookHandler {
-	// Use default validator if none provided
-	if urlValidator == nil {
-		urlValidator = security.NewURLValidator(nil)
-	}
 	return &WebhookHandler{
-		jobRepo:      jobRepo,
-		jobService:   jobService,
-		asynqClient:  asynqClient,
-		urlValidator: urlValidator,
-		logger:       logger,
+		processor:     processor,
+		webhookRepo:   webhookRepo,
+		eventRepo:     eventRepo,
+		captureEvents: captureEvents,
+		asynqClient:   asynqClient,
+		metrics:       appMetrics,
+		logger:        logger,
 	}
 }
 
@@ -95,6 +136,10 @@ func NewWebhookHandler(
 func (h *WebhookHandler) RegisterRoutes(rg *gin.RouterGroup, rateLimitMiddleware, authMiddleware gin.HandlerFunc) {
 	webhooks := rg.Group("/webhooks")
 
+	// Count callbacks first so rate-limited and unauthenticated requests are recorded as rejected
+	webhooks.Use(h.countCallbacks)
+	webhooks.Use(h.releaseOnFailure)
+
 	// Apply rate limiting to all webhook routes
 	if rateLimitMiddleware != nil {
 		webhooks.Use(rateLimitMiddleware)
@@ -107,6 +152,10 @@ func (h *WebhookHandler) RegisterRoutes(rg *gin.RouterGroup, rateLimitMiddleware
 		if authMiddleware != nil {
 			authenticated.Use(authMiddleware)
 		}
+		// Capture only authenticated callbacks so unauthenticated traffic cannot fill the table
+		if h.captureEvents {
+			authenticated.Use(h.captureCallback)
+		}
 		{
 			authenticated.POST("/suno/:job_id", h.SunoCallbackWithJobID)
 			authenticated.POST("/nano/:job_id", h.NanoCallbackWithJobID)
@@ -114,20 +163,254 @@ func (h *WebhookHandler) RegisterRoutes(rg *gin.RouterGroup, rateLimitMiddleware
 	}
 }
 
+// countCallbacks records each webhook callback as accepted (2xx) or rejected.
+func (h *WebhookHandler) countCallbacks(c *gin.Context) {
+	c.Next()
+
+	result := metrics.WebhookRejected
+	if status := c.Writer.Status(); status >= 200 && status < 300 {
+		result = metrics.WebhookAccepted
+	}
+	h.metrics.WebhookCallback(webhookSource(c.FullPath()), result)
+}
+
+// releaseOnFailure removes the processed record of a claimed callback whose handling
+// failed with a 5xx response, so the provider's retry is processed again.
+func (h *WebhookHandler) releaseOnFailure(c *gin.Context) {
+	c.Next()
+
+	if c.Writer.Status() < http.StatusInternalServerError {
+		return
+	}
+	value, ok := c.Get(claimedCallbackKey)
+	if !ok {
+		return
+	}
+	claimed := value.(claimedCallback)
+	if err := h.webhookRepo.Unmark(c.Request.Context(), claimed.source, claimed.taskID, claimed.callbackType); err != nil {
+		h.logger.Error("failed to release webhook callback",
+			zap.Error(err),
+			zap.String("source", claimed.source),
+			zap.String("task_id", claimed.taskID),
+		)
+	}
+}
+
+// captureCallback stores the raw callback in webhook_events for debugging.
+// The insert runs in the background so it never delays the response to the provider.
+func (h *WebhookHandler) captureCallback(c *gin.Context) {
+	body, err := webhookBody(c)
+	if err != nil {
+		c.Set(webhookParseErrorKey, err.Error())
+	}
+
+	c.Next()
+
+	event := &models.WebhookEvent{
+		Source:     webhookSource(c.FullPath()),
+		StatusCode: c.Writer.Status(),
+		Headers:    make(map[string]string, len(capturedWebhookHeaders)),
+	}
+	for _, name := range capturedWebhookHeaders {
+		if value := c.GetHeader(name); value != "" {
+			event.Headers[name] = value
+		}
+	}
+	if jobID, err := uuid.Parse(c.Param("job_id")); err == nil {
+		event.JobID = &jobID
+	}
+	if len(body) > maxCapturedBodySize {
+		body = body[:maxCapturedBodySize]
+		event.BodyTruncated = true
+	}
+	// TEXT columns reject invalid UTF-8, which truncation or a bad payload can produce
+	event.Body = strings.ToValidUTF8(string(body), "\uFFFD")
+	if parseErr := c.GetString(webhookParseErrorKey); parseErr != "" {
+		event.ParseError = &parseErr
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookCaptureTimeout)
+		defer cancel()
+
+		if err := h.eventRepo.Create(ctx, event); err != nil {
+			h.logger.Warn("failed to capture webhook event",
+				zap.Error(err),
+				zap.String("source", event.Source),
+			)
+		}
+	}()
+}
+
+// deferCallback stores a callback whose processing failed transiently and enqueues
+// a task that re-applies it with backoff, then acknowledges the provider. The
+// provider's own retry behaviour is not relied on. If the callback cannot be
+// stored, a 500 lets the provider retry instead (see releaseOnFailure).
+func (h *WebhookHandler) deferCallback(c *gin.Context, source string, payload any, cause error) {
+	ctx := c.Request.Context()
+	logger := h.logger.With(zap.String("source", source), zap.NamedError("cause", cause))
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("failed to marshal webhook payload for retry", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
+		return
+	}
+
+	event := &models.WebhookEvent{
+		Kind:       models.WebhookEventRetry,
+		Source:     source,
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{},
+		Body:       string(body),
+	}
+	if jobID, err := uuid.Parse(c.Param("job_id")); err == nil {
+		event.JobID = &jobID
+	}
+	if err := h.eventRepo.Create(ctx, event); err != nil {
+		logger.Error("failed to store webhook callback for retry", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
+		return
+	}
+
+	task, err := worker.NewReprocessWebhookTask(event.ID, middleware.GetRequestID(c))
+	if err == nil {
+		_, err = h.asynqClient.EnqueueContext(ctx, task)
+	}
+	if err != nil {
+		logger.Error("failed to enqueue webhook reprocess task", zap.Error(err), zap.String("event_id", event.ID.String()))
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
+		return
+	}
+
+	logger.Warn("webhook processing failed, scheduled for retry", zap.String("event_id", event.ID.String()))
+	c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
+}
+
+// webhookBody returns the request body, reading it into a buffer on first use so
+// payload binding and captureCallback share a single read.
+func webhookBody(c *gin.Context) ([]byte, error) {
+	if value, ok := c.Get(webhookBodyKey); ok {
+		return value.([]byte), nil
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	c.Set(webhookBodyKey, body)
+
+	return body, nil
+}
+
+// bindPayload decodes the buffered request body into payload, recording any
+// parse error for captureCallback.
+func bindPayload(c *gin.Context, payload any) error {
+	body, err := webhookBody(c)
+	if err == nil {
+		err = json.Unmarshal(body, payload)
+	}
+	if err != nil {
+		c.Set(webhookParseErrorKey, err.Error())
+	}
+
+	return err
+}
+
+// webhookSource returns the external service a webhook route belongs to.
+func webhookSource(route string) string {
+	switch {
+	case strings.Contains(route, "/suno/"):
+		return "suno"
+	case strings.Contains(route, "/nano/"):
+		return "nano"
+	default:
+		return "unknown"
+	}
+}
+
+// verifyJobTask checks that the callback's task belongs to the job in the URL, so
+// a valid token and a forged payload cannot change another job. Routes without a
+// job_id are not checked. On failure it writes a 404 (or a 500 when the job cannot
+// be loaded) and returns false.
+func (h *WebhookHandler) verifyJobTask(c *gin.Context, source, taskID string) bool {
+	rawID := c.Param("job_id")
+	if rawID == "" {
+		return true
+	}
+
+	jobID, err := uuid.Parse(rawID)
+	owns := false
+	if err == nil {
+		owns, err = h.processor.JobOwnsTask(c.Request.Context(), jobID, source, taskID)
+		if err != nil {
+			h.logger.Error("failed to verify webhook job",
+				zap.Error(err),
+				zap.String("source", source),
+				zap.String("job_id", rawID),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
+			return false
+		}
+	}
+
+	if !owns {
+		h.logger.Warn("webhook task does not belong to the job in the URL",
+			zap.String("source", source),
+			zap.String("job_id", rawID),
+			zap.String("task_id", taskID),
+		)
+		c.JSON(http.StatusNotFound, gin.H{"message": "job not found"})
+		return false
+	}
+	return true
+}
+
+// claimCallback records the callback as processed and reports whether the caller should handle it.
+// Replayed callbacks are acknowledged without side effects; see releaseOnFailure for retries.
+func (h *WebhookHandler) claimCallback(c *gin.Context, source, taskID, callbackType string) bool {
+	first, err := h.webhookRepo.MarkProcessed(c.Request.Context(), source, taskID, callbackType)
+	if err != nil {
+		h.logger.Error("failed to record webhook callback",
+			zap.Error(err),
+			zap.String("source", source),
+			zap.String("task_id", taskID),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
+		return false
+	}
+
+	if !first {
+		h.logger.Info("duplicate webhook callback ignored",
+			zap.String("source", source),
+			zap.String("task_id", taskID),
+			zap.String("callback_type", callbackType),
+		)
+		c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
+		return false
+	}
+
+	c.Set(claimedCallbackKey, claimedCallback{source: source, taskID: taskID, callbackType: callbackType})
+	return true
+}
+
 // SunoCallback handles the callback from KIE Suno API when music generation is complete.
 // @Summary Handle Suno webhook callback
 // @Description Receives callback from KIE Suno API when music generation is complete or failed
 // @Tags webhooks
 // @Accept json
 // @Produce json
+// @Param token path string true "HMAC-SHA256 of the job ID keyed with WEBHOOK_SECRET"
+// @Param job_id path string true "Job ID" format(uuid)
 // @Param payload body SunoWebhookPayload true "Suno webhook payload"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string "task_id does not belong to the job in the URL"
 // @Failure 500 {object} map[string]string
-// @Router /webhooks/kie/suno [post]
+// @Router /webhooks/{token}/suno/{job_id} [post]
 func (h *WebhookHandler) SunoCallback(c *gin.Context) {
 	var payload SunoWebhookPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err := bindPayload(c, &payload); err != nil {
 		h.logger.Error("failed to parse suno webhook payload",
 			zap.Error(err),
 		)
@@ -151,178 +434,23 @@ func (h *WebhookHandler) SunoCallback(c *gin.Context) {
 		return
 	}
 
-	// Find job by suno_task_id
-	job, err := h.jobRepo.GetBySunoTaskID(c.Request.Context(), payload.Data.TaskID)
-	if err != nil {
-		if errors.Is(err, repository.ErrJobNotFound) {
-			// Log warning but return 200 for idempotency
-			h.logger.Warn("job not found for suno task",
-				zap.String("task_id", payload.Data.TaskID),
-			)
-			c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
-			return
-		}
-		h.logger.Error("failed to find job by suno task ID",
-			zap.Error(err),
-			zap.String("task_id", payload.Data.TaskID),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
-		return
+	// Replay protection: each (task_id, callback_type) is handled once
+	callbackType := payload.Data.CallbackType
+	if payload.Code != 200 {
+		callbackType = "error"
 	}
-
-	// Idempotency check: only process if job is in expected status
-	if job.Status != models.StatusGeneratingMusic {
-		h.logger.Warn("suno callback received for job not in expected status",
-			zap.String("job_id", job.ID.String()),
-			zap.String("current_status", job.Status),
-			zap.String("expected_status", models.StatusGeneratingMusic),
-		)
-		c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
+	if !h.verifyJobTask(c, "suno", payload.Data.TaskID) {
 		return
 	}
-
-	// Handle failed status (code != 200 or callbackType indicates failure)
-	if payload.Code != 200 {
-		errorMsg := payload.Data.ErrorMessage
-		if errorMsg == "" {
-			errorMsg = payload.Msg
-		}
-		if errorMsg == "" {
-			errorMsg = "music generation failed"
-		}
-		if err := h.jobService.MarkFailed(c.Request.Context(), job.ID, errorMsg); err != nil {
-			h.logger.Error("failed to mark job as failed",
-				zap.Error(err),
-				zap.String("job_id", job.ID.String()),
-			)
-		}
-		c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
+	if !h.claimCallback(c, "suno", payload.Data.TaskID, callbackType) {
 		return
 	}
 
-	// Handle completed status (callbackType == "complete" or "first")
-	// "first" means first track is ready, "complete" means all tracks are ready
-	if payload.Data.CallbackType == "complete" || payload.Data.CallbackType == "first" {
-		// Validate songs array is not empty
-		if len(payload.Data.Data) == 0 {
-			h.logger.Error("suno callback has empty songs array",
-				zap.String("job_id", job.ID.String()),
-				zap.String("task_id", payload.Data.TaskID),
-			)
-			_ = h.jobService.MarkFailed(c.Request.Context(), job.ID, "music generation returned no songs")
-			c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
-			return
-		}
-
-		// Filter songs with valid AudioURL and validate URLs
-		songs := make([]models.GeneratedSong, 0, len(payload.Data.Data))
-		for _, s := range payload.Data.Data {
-			// Skip songs with empty AudioURL
-			if s.AudioURL == "" {
-				h.logger.Warn("skipping song with empty audio_url",
-					zap.String("job_id", job.ID.String()),
-					zap.String("song_id", s.ID),
-				)
-				continue
-			}
-
-			// Validate AudioURL to prevent SSRF
-			if err := h.urlValidator.ValidateURL(s.AudioURL); err != nil {
-				h.logger.Warn("skipping song with invalid audio_url",
-					zap.String("job_id", job.ID.String()),
-					zap.String("song_id", s.ID),
-					zap.String("audio_url", s.AudioURL),
-					zap.Error(err),
-				)
-				continue
-			}
-
-			songs = append(songs, models.GeneratedSong{
-				ID:       s.ID,
-				AudioURL: s.AudioURL,
-				Title:    s.Title,
-				Duration: s.Duration,
-			})
-		}
-
-		// Check if any valid songs remain
-		if len(songs) == 0 {
-			// For "first" callback, don't fail immediately - wait for "complete" callback
-			// which may have fully generated audio URLs
-			if payload.Data.CallbackType == "first" {
-				h.logger.Warn("first callback has no valid songs yet, waiting for complete callback",
-					zap.String("job_id", job.ID.String()),
-					zap.Int("total_songs", len(payload.Data.Data)),
-				)
-				c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
-				return
-			}
-			// For "complete" callback, fail the job
-			h.logger.Error("all songs have invalid audio URLs",
-				zap.String("job_id", job.ID.String()),
-				zap.Int("total_songs", len(payload.Data.Data)),
-			)
-			_ = h.jobService.MarkFailed(c.Request.Context(), job.ID, "all songs have invalid audio URLs")
-			c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
-			return
-		}
-
-		// Update job with generated songs (atomic — handles concurrent callbacks)
-		if err := h.jobService.UpdateGeneratedSongs(c.Request.Context(), job.ID, payload.Data.TaskID, songs); err != nil {
-			var appErr *apperrors.AppError
-			if errors.As(err, &appErr) && appErr.Code == http.StatusConflict {
-				h.logger.Warn("suno callback conflict - already processed by another callback",
-					zap.String("job_id", job.ID.String()),
-				)
-				c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
-				return
-			}
-			h.logger.Error("failed to update job with generated songs",
-				zap.Error(err),
-				zap.String("job_id", job.ID.String()),
-			)
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
-			return
-		}
-
-		// Enqueue select song task with deduplication
-		task, err := worker.NewSelectSongTask(job.ID)
-		if err != nil {
-			h.logger.Error("failed to create select song task",
-				zap.Error(err),
-				zap.String("job_id", job.ID.String()),
-			)
-			_ = h.jobService.MarkFailed(c.Request.Context(), job.ID, "failed to enqueue select song task")
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
-			return
-		}
-
-		if _, err := h.asynqClient.Enqueue(task); err != nil {
-			// Check if it's a duplicate task error (already enqueued)
-			if errors.Is(err, asynq.ErrTaskIDConflict) {
-				h.logger.Warn("select song task already enqueued (duplicate callback)",
-					zap.String("job_id", job.ID.String()),
-				)
-				c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
-				return
-			}
-			h.logger.Error("failed to enqueue select song task",
-				zap.Error(err),
-				zap.String("job_id", job.ID.String()),
-			)
-			_ = h.jobService.MarkFailed(c.Request.Context(), job.ID, "failed to enqueue select song task")
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
-			return
-		}
-
-		h.logger.Info("suno callback processed, select song task enqueued",
-			zap.String("job_id", job.ID.String()),
-			zap.Int("valid_song_count", len(songs)),
-			zap.Int("total_song_count", len(payload.Data.Data)),
-		)
+	if err := h.processor.ApplySuno(c.Request.Context(), &payload, middleware.GetRequestID(c)); err != nil {
+		h.deferCallback(c, "suno", &payload, err)
+		return
 	}
 
-	// For "text" callbackType, just acknowledge - lyrics generated but audio not ready
 	c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
 }
 
@@ -332,8 +460,7 @@ func (h *WebhookHandler) SunoCallbackWithJobID(c *gin.Context) {
 	jobID := c.Param("job_id")
 	h.logger.Debug("suno callback with job_id in path", zap.String("job_id", jobID))
 
-	// Delegate to main handler - the job_id in path is for reference,
-	// but we use task_id from payload for lookup (more reliable)
+	// SunoCallback checks that the payload's task belongs to the job in the path
 	h.SunoCallback(c)
 }
 
@@ -343,14 +470,17 @@ func (h *WebhookHandler) SunoCallbackWithJobID(c *gin.Context) {
 // @Tags webhooks
 // @Accept json
 // @Produce json
+// @Param token path string true "HMAC-SHA256 of the job ID keyed with WEBHOOK_SECRET"
+// @Param job_id path string true "Job ID" format(uuid)
 // @Param payload body NanoWebhookPayload true "Nano webhook payload"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string "task_id does not belong to the job in the URL"
 // @Failure 500 {object} map[string]string
-// @Router /webhooks/kie/nano [post]
+// @Router /webhooks/{token}/nano/{job_id} [post]
 func (h *WebhookHandler) NanoCallback(c *gin.Context) {
 	var payload NanoWebhookPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err := bindPayload(c, &payload); err != nil {
 		h.logger.Error("failed to parse nano webhook payload",
 			zap.Error(err),
 		)
@@ -374,133 +504,21 @@ func (h *WebhookHandler) NanoCallback(c *gin.Context) {
 		return
 	}
 
-	// Find job by nano_task_id
-	job, err := h.jobRepo.GetByNanoTaskID(c.Request.Context(), payload.Data.TaskID)
-	if err != nil {
-		if errors.Is(err, repository.ErrJobNotFound) {
-			// Log warning but return 200 for idempotency
-			h.logger.Warn("job not found for nano task",
-				zap.String("task_id", payload.Data.TaskID),
-			)
-			c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
-			return
-		}
-		h.logger.Error("failed to find job by nano task ID",
-			zap.Error(err),
-			zap.String("task_id", payload.Data.TaskID),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
-		return
+	// Replay protection: each (task_id, state) is handled once
+	callbackType := payload.Data.State
+	if payload.Code != 200 {
+		callbackType = "error"
 	}
-
-	// Idempotency check: only process if job is in expected status
-	if job.Status != models.StatusGeneratingImage {
-		h.logger.Warn("nano callback received for job not in expected status",
-			zap.String("job_id", job.ID.String()),
-			zap.String("current_status", job.Status),
-			zap.String("expected_status", models.StatusGeneratingImage),
-		)
-		c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
+	if !h.verifyJobTask(c, "nano", payload.Data.TaskID) {
 		return
 	}
-
-	// Handle failed status
-	if payload.Code != 200 || payload.Data.State == "fail" {
-		errorMsg := payload.Data.FailMsg
-		if errorMsg == "" {
-			errorMsg = payload.Message
-		}
-		if errorMsg == "" {
-			errorMsg = "image generation failed"
-		}
-		if err := h.jobService.MarkFailed(c.Request.Context(), job.ID, errorMsg); err != nil {
-			h.logger.Error("failed to mark job as failed",
-				zap.Error(err),
-				zap.String("job_id", job.ID.String()),
-			)
-		}
-		c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
+	if !h.claimCallback(c, "nano", payload.Data.TaskID, callbackType) {
 		return
 	}
 
-	// Handle completed status
-	if payload.Data.State == "success" {
-		// Extract image URL from resultJson
-		imageURL, err := extractImageURL(payload.Data.ResultJson)
-		if err != nil {
-			h.logger.Error("failed to extract image URL from callback",
-				zap.Error(err),
-				zap.String("job_id", job.ID.String()),
-				zap.Int("result_json_length", len(payload.Data.ResultJson)), // Sanitized log
-			)
-			_ = h.jobService.MarkFailed(c.Request.Context(), job.ID, "failed to extract image URL from callback")
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
-			return
-		}
-
-		// Validate image URL to prevent SSRF
-		if err := h.urlValidator.ValidateURL(imageURL); err != nil {
-			h.logger.Error("image URL validation failed",
-				zap.Error(err),
-				zap.String("job_id", job.ID.String()),
-			)
-			_ = h.jobService.MarkFailed(c.Request.Context(), job.ID, "image URL validation failed")
-			c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
-			return
-		}
-
-		// Update job with image URL (atomic — handles concurrent callbacks)
-		if err := h.jobService.UpdateImageURL(c.Request.Context(), job.ID, payload.Data.TaskID, imageURL); err != nil {
-			var appErr *apperrors.AppError
-			if errors.As(err, &appErr) && appErr.Code == http.StatusConflict {
-				h.logger.Warn("nano callback conflict - already processed by another callback",
-					zap.String("job_id", job.ID.String()),
-				)
-				c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
-				return
-			}
-			h.logger.Error("failed to update job with image URL",
-				zap.Error(err),
-				zap.String("job_id", job.ID.String()),
-			)
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
-			return
-		}
-
-		// Enqueue process video task with deduplication
-		task, err := worker.NewProcessVideoTask(job.ID)
-		if err != nil {
-			h.logger.Error("failed to create process video task",
-				zap.Error(err),
-				zap.String("job_id", job.ID.String()),
-			)
-			_ = h.jobService.MarkFailed(c.Request.Context(), job.ID, "failed to enqueue process video task")
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
-			return
-		}
-
-		if _, err := h.asynqClient.Enqueue(task); err != nil {
-			// Check if it's a duplicate task error (already enqueued)
-			if errors.Is(err, asynq.ErrTaskIDConflict) {
-				h.logger.Warn("process video task already enqueued (duplicate callback)",
-					zap.String("job_id", job.ID.String()),
-				)
-				c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
-				return
-			}
-			h.logger.Error("failed to enqueue process video task",
-				zap.Error(err),
-				zap.String("job_id", job.ID.String()),
-			)
-			_ = h.jobService.MarkFailed(c.Request.Context(), job.ID, "failed to enqueue process video task")
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "internal error"})
-			return
-		}
-
-		h.logger.Info("nano callback processed, process video task enqueued",
-			zap.String("job_id", job.ID.String()),
-			zap.Bool("has_image_url", true), // Sanitized log - don't log the actual URL
-		)
+	if err := h.processor.ApplyNano(c.Request.Context(), &payload, middleware.GetRequestID(c)); err != nil {
+		h.deferCallback(c, "nano", &payload, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "acknowledged"})
@@ -512,28 +530,6 @@ func (h *WebhookHandler) NanoCallbackWithJobID(c *gin.Context) {
 	jobID := c.Param("job_id")
 	h.logger.Debug("nano callback with job_id in path", zap.String("job_id", jobID))
 
-	// Delegate to main handler - the job_id in path is for reference,
-	// but we use task_id from payload for lookup (more reliable)
+	// NanoCallback checks that the payload's task belongs to the job in the path
 	h.NanoCallback(c)
 }
-
-// extractImageURL parses the resultJson and extracts the first image URL.
-// The resultJson format is: {"resultUrls":["https://..."]}
-func extractImageURL(resultJson string) (string, error) {
-	if resultJson == "" {
-		return "", fmt.Errorf("empty resultJson")
-	}
-
-	var result struct {
-		ResultUrls []string `json:"resultUrls"`
-	}
-	if err := json.Unmarshal([]byte(resultJson), &result); err != nil {
-		return "", fmt.Errorf("failed to parse resultJson: %w", err)
-	}
-
-	if len(result.ResultUrls) == 0 {
-		return "", fmt.Errorf("no image URLs in resultJson")
-	}
-
-	return result.ResultUrls[0], nil
-}
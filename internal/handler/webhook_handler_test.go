@@ -0,0 +1,180 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/handler"
+	"github.com/jaochai/ugc/internal/metrics"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/service"
+)
+
+// processedCallbacks records callbacks the way the processed_webhooks unique
+// constraint does: only the first MarkProcessed of a callback succeeds.
+type processedCallbacks struct {
+	mu        sync.Mutex
+	processed map[string]bool
+}
+
+func (r *processedCallbacks) MarkProcessed(ctx context.Context, source, taskID, callbackType string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := source + "/" + taskID + "/" + callbackType
+	if r.processed[key] {
+		return false, nil
+	}
+	r.processed[key] = true
+	return true, nil
+}
+
+func (r *processedCallbacks) Unmark(ctx context.Context, source, taskID, callbackType string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.processed, source+"/"+taskID+"/"+callbackType)
+	return nil
+}
+
+// callbackJobRepo returns one job by ID and Suno task.
+type callbackJobRepo struct {
+	repository.JobRepository
+	job *models.Job
+}
+
+func (r *callbackJobRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	copied := *r.job
+	return &copied, nil
+}
+
+func (r *callbackJobRepo) GetBySunoTaskID(ctx context.Context, taskID string) (*models.Job, error) {
+	copied := *r.job
+	return &copied, nil
+}
+
+// countingJobService counts the failures it is asked to store.
+type countingJobService struct {
+	service.JobService
+	mu       sync.Mutex
+	failures int
+}
+
+func (s *countingJobService) MarkFailure(ctx context.Context, jobID uuid.UUID, failure models.JobFailure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures++
+	return nil
+}
+
+// TestSunoCallbackReplayedConcurrently fires the same callback twice at once and
+// checks that both are acknowledged and exactly one is processed.
+func TestSunoCallbackReplayedConcurrently(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	taskID := "suno-task"
+	job := &models.Job{ID: uuid.New(), Status: models.StatusGeneratingMusic, SunoTaskID: &taskID}
+	body := `{"code": 400, "msg": "generation failed", "data": {"callbackType": "error", "task_id": "suno-task"}}`
+
+	for range 20 {
+		jobService := &countingJobService{}
+		processor := handler.NewWebhookProcessor(&callbackJobRepo{job: job}, nil, jobService, nil, nil, nil, 0, zap.NewNop())
+		webhookHandler := handler.NewWebhookHandler(processor, &processedCallbacks{processed: make(map[string]bool)}, nil, false, nil, nil, zap.NewNop())
+		router := gin.New()
+		webhookHandler.RegisterRoutes(router.Group("/api/v1"), nil, nil)
+
+		codes := make([]int, 2)
+		var wg sync.WaitGroup
+		for i := range codes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/token/suno/"+job.ID.String(), strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				codes[i] = w.Code
+			}()
+		}
+		wg.Wait()
+
+		for _, code := range codes {
+			if code != http.StatusOK {
+				t.Fatalf("callback responses %v, want both 200", codes)
+			}
+		}
+		if jobService.failures != 1 {
+			t.Fatalf("callback processed %d times, want once", jobService.failures)
+		}
+	}
+}
+
+// TestWebhookCallbackMetrics checks that callbacks are counted by source as
+// accepted, or rejected when rate limited or unauthenticated.
+func TestWebhookCallbackMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	taskID := "suno-task"
+	job := &models.Job{ID: uuid.New(), Status: models.StatusGeneratingMusic, SunoTaskID: &taskID}
+	processor := handler.NewWebhookProcessor(&callbackJobRepo{job: job}, nil, &countingJobService{}, nil, nil, nil, 0, zap.NewNop())
+	reg := prometheus.NewRegistry()
+	webhookHandler := handler.NewWebhookHandler(processor, &processedCallbacks{processed: make(map[string]bool)}, nil, false, nil, metrics.New(reg), zap.NewNop())
+
+	rateLimited := func(c *gin.Context) {
+		if c.GetHeader("X-Flood") != "" {
+			c.AbortWithStatus(http.StatusTooManyRequests)
+		}
+	}
+	auth := func(c *gin.Context) {
+		if c.Param("token") != "valid-token" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+		}
+	}
+	router := gin.New()
+	webhookHandler.RegisterRoutes(router.Group("/api/v1"), rateLimited, auth)
+
+	callbacks := []struct {
+		path   string
+		flood  bool
+		body   string
+		status int
+	}{
+		{path: "/webhooks/valid-token/suno/", status: http.StatusOK,
+			body: `{"code": 400, "msg": "generation failed", "data": {"callbackType": "error", "task_id": "suno-task"}}`},
+		{path: "/webhooks/stolen-token/suno/", status: http.StatusUnauthorized, body: `{}`},
+		{path: "/webhooks/stolen-token/nano/", status: http.StatusUnauthorized, body: `{}`},
+		{path: "/webhooks/valid-token/nano/", flood: true, status: http.StatusTooManyRequests, body: `{}`},
+	}
+	for _, cb := range callbacks {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1"+cb.path+job.ID.String(), strings.NewReader(cb.body))
+		req.Header.Set("Content-Type", "application/json")
+		if cb.flood {
+			req.Header.Set("X-Flood", "1")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != cb.status {
+			t.Fatalf("POST %s = %d %s, want %d", cb.path, w.Code, w.Body.String(), cb.status)
+		}
+	}
+
+	const want = `
+# HELP ugc_webhook_callbacks_total Webhook callbacks by source and outcome (accepted or rejected).
+# TYPE ugc_webhook_callbacks_total counter
+ugc_webhook_callbacks_total{result="accepted",source="suno"} 1
+ugc_webhook_callbacks_total{result="rejected",source="nano"} 2
+ugc_webhook_callbacks_total{result="rejected",source="suno"} 1
+`
+	if err := promtestutil.GatherAndCompare(reg, strings.NewReader(want), "ugc_webhook_callbacks_total"); err != nil {
+		t.Error(err)
+	}
+}
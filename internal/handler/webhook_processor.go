@@ -0,0 +1,743 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/logsanitize"
+
+	"github.com/jaochai/ugc/internal/external/kie"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/security"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/worker"
+)
+
+// WebhookProcessor applies parsed provider callbacks to jobs. It has no HTTP
+// dependencies so the worker can re-apply callbacks whose first attempt failed
+// (see WebhookHandler.deferCallback).
+//
+// Apply methods return an error only for transient failures (database or queue
+// errors) where applying the same payload again may succeed. Callbacks that are
+// stale, duplicated or describe a failed generation are handled and return nil,
+// as do callbacks that failed their job: once the job is failed, applying the
+// callback again finds it terminal and does nothing.
+type WebhookProcessor struct {
+	jobRepo      repository.JobRepository
+	eventRepo    repository.WebhookEventRepository
+	jobService   service.JobService
+	asynqClient  *asynq.Client
+	outbox       *worker.Outbox
+	urlValidator *security.URLValidator
+	// sunoCompleteGrace is how long song selection waits for "complete" after "first"
+	sunoCompleteGrace time.Duration
+	logger            *zap.Logger
+}
+
+// NewWebhookProcessor creates a new WebhookProcessor instance.
+func NewWebhookProcessor(
+	jobRepo repository.JobRepository,
+	eventRepo repository.WebhookEventRepository,
+	jobService service.JobService,
+	asynqClient *asynq.Client,
+	outbox *worker.Outbox,
+	urlValidator *security.URLValidator,
+	sunoCompleteGrace time.Duration,
+	logger *zap.Logger,
+) *WebhookProcessor {
+	// Use default validator if none provided
+	if urlValidator == nil {
+		urlValidator = security.NewURLValidator(nil)
+	}
+	return &WebhookProcessor{
+		jobRepo:           jobRepo,
+		eventRepo:         eventRepo,
+		jobService:        jobService,
+		asynqClient:       asynqClient,
+		outbox:            outbox,
+		urlValidator:      urlValidator,
+		sunoCompleteGrace: sunoCompleteGrace,
+		logger:            logger,
+	}
+}
+
+// JobOwnsTask reports whether the KIE task of a callback belongs to the job: its
+// Suno task for source "suno", its Nano task or an image candidate's task for
+// "nano". A job that does not exist owns no task.
+func (p *WebhookProcessor) JobOwnsTask(ctx context.Context, jobID uuid.UUID, source, taskID string) (bool, error) {
+	job, err := p.jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, repository.ErrJobNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to load job: %w", err)
+	}
+
+	switch source {
+	case "suno":
+		return job.SunoTaskID != nil && *job.SunoTaskID == taskID, nil
+	case "nano":
+		if job.NanoTaskID != nil && *job.NanoTaskID == taskID {
+			return true, nil
+		}
+		for _, image := range job.GeneratedImages {
+			if image.TaskID == taskID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// ReprocessEvent re-applies a callback stored with models.WebhookEventRetry.
+// Events that were already processed are skipped.
+func (p *WebhookProcessor) ReprocessEvent(ctx context.Context, eventID uuid.UUID, traceID string) error {
+	event, err := p.eventRepo.GetByID(ctx, eventID)
+	if err != nil {
+		if errors.Is(err, repository.ErrWebhookEventNotFound) {
+			// Pruned or never stored: no retry can find it
+			return fmt.Errorf("webhook event %s not found: %w", eventID, asynq.SkipRetry)
+		}
+		return fmt.Errorf("failed to load webhook event: %w", err)
+	}
+	if event.ProcessedAt != nil {
+		return nil
+	}
+
+	switch event.Source {
+	case "suno":
+		var payload SunoWebhookPayload
+		if err := json.Unmarshal([]byte(event.Body), &payload); err != nil {
+			return fmt.Errorf("failed to unmarshal suno payload: %v: %w", err, asynq.SkipRetry)
+		}
+		err = p.ApplySuno(ctx, &payload, traceID)
+	case "nano":
+		var payload NanoWebhookPayload
+		if err := json.Unmarshal([]byte(event.Body), &payload); err != nil {
+			return fmt.Errorf("failed to unmarshal nano payload: %v: %w", err, asynq.SkipRetry)
+		}
+		err = p.ApplyNano(ctx, &payload, traceID)
+	default:
+		return fmt.Errorf("unknown webhook source %q: %w", event.Source, asynq.SkipRetry)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := p.eventRepo.MarkProcessed(ctx, event.ID); err != nil {
+		p.logger.Warn("failed to mark webhook event processed",
+			zap.Error(err),
+			zap.String("event_id", event.ID.String()),
+		)
+	}
+	return nil
+}
+
+// ApplySuno applies a Suno music generation callback.
+func (p *WebhookProcessor) ApplySuno(ctx context.Context, payload *SunoWebhookPayload, traceID string) error {
+	// Find job by suno_task_id
+	job, err := p.jobRepo.GetBySunoTaskID(ctx, payload.Data.TaskID)
+	if err != nil {
+		if errors.Is(err, repository.ErrJobNotFound) {
+			// Log warning but acknowledge for idempotency
+			p.logger.Warn("job not found for suno task",
+				zap.String("task_id", payload.Data.TaskID),
+			)
+			return nil
+		}
+		p.logger.Error("failed to find job by suno task ID",
+			zap.Error(err),
+			zap.String("task_id", payload.Data.TaskID),
+		)
+		return fmt.Errorf("failed to find job by suno task ID: %w", err)
+	}
+
+	// Idempotency check: only process if job is in expected status and not deleted
+	if job.Status != models.StatusGeneratingMusic || job.IsDeleted() {
+		p.logger.Warn("suno callback received for job not in expected status",
+			zap.String("job_id", job.ID.String()),
+			zap.String("current_status", job.Status),
+			zap.String("expected_status", models.StatusGeneratingMusic),
+		)
+		return nil
+	}
+
+	// Handle failed status (code != 200 or callbackType indicates failure)
+	if payload.Code != 200 {
+		errorMsg := payload.Data.ErrorMessage
+		if errorMsg == "" {
+			errorMsg = payload.Msg
+		}
+		if errorMsg == "" {
+			errorMsg = "music generation failed"
+		}
+		if kie.IsSensitiveWordError(errorMsg) {
+			errorMsg = kie.SensitiveContentMessage
+		}
+		return p.failJob(ctx, job.ID, models.JobFailure{Message: errorMsg})
+	}
+
+	// For "text" callbackType, just acknowledge - lyrics generated but audio not ready
+	if payload.Data.CallbackType != "complete" && payload.Data.CallbackType != "first" {
+		return nil
+	}
+
+	// Handle audio callbacks: "first" means one track is ready, "complete" means all tracks are ready
+	isFirst := payload.Data.CallbackType == "first"
+
+	// Filter songs with valid AudioURL and validate URLs
+	songs := make([]models.GeneratedSong, 0, len(payload.Data.Data))
+	for _, s := range payload.Data.Data {
+		// Skip songs with empty AudioURL
+		if s.AudioURL == "" {
+			p.logger.Warn("skipping song with empty audio_url",
+				zap.String("job_id", job.ID.String()),
+				zap.String("song_id", s.ID),
+			)
+			continue
+		}
+
+		// Validate AudioURL to prevent SSRF
+		if err := p.urlValidator.ValidateURL(s.AudioURL); err != nil {
+			p.logger.Warn("skipping song with invalid audio_url",
+				zap.String("job_id", job.ID.String()),
+				zap.String("song_id", s.ID),
+				zap.String("audio_host", logsanitize.URLHost(s.AudioURL)),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		song := models.GeneratedSong{
+			ID:       s.ID,
+			AudioURL: s.AudioURL,
+			Title:    s.Title,
+			Duration: s.Duration,
+			ImageURL: s.ImageURL,
+		}
+		// Silent or empty tracks come back with a zero or near-zero duration
+		if !song.IsPlayable() {
+			p.logger.Warn("skipping song shorter than the minimum duration",
+				zap.String("job_id", job.ID.String()),
+				zap.String("song_id", s.ID),
+				zap.Float64("duration", s.Duration),
+			)
+			continue
+		}
+		songs = append(songs, song)
+	}
+
+	// Tracks recorded from an earlier "first" callback are kept; "complete" replaces them by ID
+	merged := models.MergeGeneratedSongs(job.GeneratedSongs, songs)
+
+	// Check if any valid songs remain
+	if len(merged) == 0 {
+		// For "first" callback, don't fail immediately - wait for "complete" callback
+		// which may have fully generated audio URLs
+		if isFirst {
+			p.logger.Warn("first callback has no valid songs yet, waiting for complete callback",
+				zap.String("job_id", job.ID.String()),
+				zap.Int("total_songs", len(payload.Data.Data)),
+			)
+			return nil
+		}
+		// For "complete" callback, fail the job; a retry generates new songs
+		p.logger.Error("suno callback has no playable songs",
+			zap.String("job_id", job.ID.String()),
+			zap.Int("total_songs", len(payload.Data.Data)),
+		)
+		return p.failJob(ctx, job.ID, models.JobFailure{
+			Message:   models.NoPlayableSongsMessage,
+			Code:      models.JobErrorNoPlayableSongs,
+			RetryFrom: models.RetryFromMusic,
+		})
+	}
+
+	if isFirst {
+		return p.applySunoFirst(ctx, job, payload.Data.TaskID, merged, traceID)
+	}
+
+	// Update job with generated songs (atomic — handles concurrent callbacks)
+	if err := p.jobService.UpdateGeneratedSongs(ctx, job.ID, payload.Data.TaskID, merged); err != nil {
+		if isConflict(err) {
+			p.logger.Warn("suno callback conflict - already processed by another callback",
+				zap.String("job_id", job.ID.String()),
+			)
+			return nil
+		}
+		p.logger.Error("failed to update job with generated songs",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return fmt.Errorf("failed to update generated songs: %w", err)
+	}
+
+	// Enqueue select song task with deduplication
+	task, err := worker.NewSelectSongTask(job.ID, traceID)
+	if err != nil {
+		p.logger.Error("failed to create select song task",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return p.failJob(ctx, job.ID, models.JobFailure{Message: "failed to enqueue select song task"})
+	}
+
+	if err := enqueueOrOutbox(ctx, p.outbox, p.asynqClient, task, job.ID); err != nil {
+		// Check if it's a duplicate task error (already enqueued)
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			p.logger.Warn("select song task already enqueued (duplicate callback)",
+				zap.String("job_id", job.ID.String()),
+			)
+			return nil
+		}
+		p.logger.Error("failed to enqueue select song task",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return p.failJob(ctx, job.ID, models.JobFailure{Message: "failed to enqueue select song task"})
+	}
+
+	p.logger.Info("suno callback processed, select song task enqueued",
+		zap.String("job_id", job.ID.String()),
+		zap.Int("valid_song_count", len(merged)),
+		zap.Int("total_song_count", len(payload.Data.Data)),
+	)
+	return nil
+}
+
+// applySunoFirst records the tracks of a "first" callback and schedules song
+// selection after the grace period, so the "complete" callback can still add the
+// second track. Whichever arrives first starts selection; see tasks.HandleFinalizeSongs.
+func (p *WebhookProcessor) applySunoFirst(ctx context.Context, job *models.Job, taskID string, songs []models.GeneratedSong, traceID string) error {
+	if err := p.jobService.RecordEarlySongs(ctx, job.ID, taskID, songs); err != nil {
+		if isConflict(err) {
+			p.logger.Warn("suno first callback conflict - job already moved on",
+				zap.String("job_id", job.ID.String()),
+			)
+			return nil
+		}
+		p.logger.Error("failed to record early songs",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return fmt.Errorf("failed to record early songs: %w", err)
+	}
+
+	task, err := worker.NewFinalizeSongsTask(job.ID, traceID)
+	if err != nil {
+		p.logger.Error("failed to create finalize songs task",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return fmt.Errorf("failed to create finalize songs task: %w", err)
+	}
+
+	if err := enqueueOrOutbox(ctx, p.outbox, p.asynqClient, task, job.ID, asynq.ProcessIn(p.sunoCompleteGrace)); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		p.logger.Error("failed to enqueue finalize songs task",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return fmt.Errorf("failed to enqueue finalize songs task: %w", err)
+	}
+
+	p.logger.Info("suno first callback recorded, waiting for complete callback",
+		zap.String("job_id", job.ID.String()),
+		zap.Int("song_count", len(songs)),
+		zap.Duration("grace_period", p.sunoCompleteGrace),
+	)
+	return nil
+}
+
+// ApplyNano applies a NanoBanana image generation callback.
+func (p *WebhookProcessor) ApplyNano(ctx context.Context, payload *NanoWebhookPayload, traceID string) error {
+	// Find job by nano_task_id
+	job, err := p.jobRepo.GetByNanoTaskID(ctx, payload.Data.TaskID)
+	if err != nil {
+		if errors.Is(err, repository.ErrJobNotFound) {
+			// Log warning but acknowledge for idempotency
+			p.logger.Warn("job not found for nano task",
+				zap.String("task_id", payload.Data.TaskID),
+			)
+			return nil
+		}
+		p.logger.Error("failed to find job by nano task ID",
+			zap.Error(err),
+			zap.String("task_id", payload.Data.TaskID),
+		)
+		return fmt.Errorf("failed to find job by nano task ID: %w", err)
+	}
+
+	// Idempotency check: only process if job is in expected status and not deleted
+	if job.Status != models.StatusGeneratingImage || job.IsDeleted() {
+		p.logger.Warn("nano callback received for job not in expected status",
+			zap.String("job_id", job.ID.String()),
+			zap.String("current_status", job.Status),
+			zap.String("expected_status", models.StatusGeneratingImage),
+		)
+		return nil
+	}
+
+	// Jobs without image candidates (created before multi-candidate support) use a single task
+	if len(job.GeneratedImages) == 0 {
+		return p.applySingleNanoResult(ctx, job, payload, traceID)
+	}
+
+	return p.applyNanoCandidateResult(ctx, job, payload, traceID)
+}
+
+// applyNanoCandidateResult records one image candidate result and, once no candidate
+// is pending, enqueues the select image task to pick the best image.
+func (p *WebhookProcessor) applyNanoCandidateResult(ctx context.Context, job *models.Job, payload *NanoWebhookPayload, traceID string) error {
+	taskID := payload.Data.TaskID
+
+	// Intermediate states carry no result yet
+	failed := payload.Code != 200 || payload.Data.State == "fail"
+	if !failed && payload.Data.State != "success" {
+		return nil
+	}
+
+	candidateStatus := models.ImageCandidateFailed
+	var imageURL string
+	if !failed {
+		url, err := extractImageURL(payload.Data.ResultJson)
+		if err != nil {
+			p.logger.Warn("failed to extract image URL from candidate callback",
+				zap.Error(err),
+				zap.String("job_id", job.ID.String()),
+				zap.Int("result_json_length", len(payload.Data.ResultJson)), // Sanitized log
+			)
+		} else if err := p.urlValidator.ValidateURL(url); err != nil {
+			p.logger.Warn("candidate image URL validation failed",
+				zap.Error(err),
+				zap.String("job_id", job.ID.String()),
+			)
+		} else {
+			candidateStatus = models.ImageCandidateSuccess
+			imageURL = url
+		}
+	} else {
+		p.logger.Warn("image candidate failed",
+			zap.String("job_id", job.ID.String()),
+			zap.String("task_id", taskID),
+			zap.String("fail_msg", payload.Data.FailMsg),
+		)
+	}
+
+	images, err := p.jobService.UpdateImageCandidate(ctx, job.ID, taskID, imageURL, candidateStatus)
+	if err != nil {
+		if isConflict(err) {
+			p.logger.Warn("nano candidate callback conflict - already processed",
+				zap.String("job_id", job.ID.String()),
+				zap.String("task_id", taskID),
+			)
+			return nil
+		}
+		p.logger.Error("failed to update image candidate",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return fmt.Errorf("failed to update image candidate: %w", err)
+	}
+
+	updated := models.Job{GeneratedImages: images}
+	if pending := updated.PendingImageCandidates(); pending > 0 {
+		p.logger.Info("nano candidate recorded, waiting for remaining candidates",
+			zap.String("job_id", job.ID.String()),
+			zap.Int("pending", pending),
+		)
+		return nil
+	}
+
+	if len(updated.SuccessfulImageCandidates()) == 0 {
+		// Every candidate shares one prompt, so the last refusal stands for all of them
+		if p.restartFlaggedImage(ctx, job, payload, traceID) {
+			return nil
+		}
+		errorMsg := nanoFailureMessage(payload)
+		if errorMsg == "" {
+			errorMsg = "image generation failed for all candidates"
+		}
+		return p.failJob(ctx, job.ID, models.JobFailure{Message: errorMsg})
+	}
+
+	// All candidates resolved - enqueue select image task with deduplication
+	task, err := worker.NewSelectImageTask(job.ID, traceID)
+	if err != nil {
+		p.logger.Error("failed to create select image task",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return p.failJob(ctx, job.ID, models.JobFailure{Message: "failed to enqueue select image task"})
+	}
+
+	if err := enqueueOrOutbox(ctx, p.outbox, p.asynqClient, task, job.ID); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			p.logger.Warn("select image task already enqueued (duplicate callback)",
+				zap.String("job_id", job.ID.String()),
+			)
+			return nil
+		}
+		p.logger.Error("failed to enqueue select image task",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return p.failJob(ctx, job.ID, models.JobFailure{Message: "failed to enqueue select image task"})
+	}
+
+	p.logger.Info("all nano candidates resolved, select image task enqueued",
+		zap.String("job_id", job.ID.String()),
+		zap.Int("candidates", len(images)),
+	)
+	return nil
+}
+
+// applySingleNanoResult handles the callback for a job with a single image task
+// by storing the image URL directly and enqueuing the process video task.
+func (p *WebhookProcessor) applySingleNanoResult(ctx context.Context, job *models.Job, payload *NanoWebhookPayload, traceID string) error {
+	// Handle failed status
+	if payload.Code != 200 || payload.Data.State == "fail" {
+		if p.restartFlaggedImage(ctx, job, payload, traceID) {
+			return nil
+		}
+		errorMsg := nanoFailureMessage(payload)
+		if errorMsg == "" {
+			errorMsg = payload.Message
+		}
+		if errorMsg == "" {
+			errorMsg = "image generation failed"
+		}
+		return p.failJob(ctx, job.ID, models.JobFailure{Message: errorMsg})
+	}
+
+	// Intermediate states carry no result yet
+	if payload.Data.State != "success" {
+		return nil
+	}
+
+	// Extract image URL from resultJson
+	imageURL, err := extractImageURL(payload.Data.ResultJson)
+	if err != nil {
+		p.logger.Error("failed to extract image URL from callback",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+			zap.Int("result_json_length", len(payload.Data.ResultJson)), // Sanitized log
+		)
+		// The payload itself is unusable, so retrying cannot help
+		return p.failJob(ctx, job.ID, models.JobFailure{Message: "failed to extract image URL from callback"})
+	}
+
+	// Validate image URL to prevent SSRF
+	if err := p.urlValidator.ValidateURL(imageURL); err != nil {
+		p.logger.Error("image URL validation failed",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return p.failJob(ctx, job.ID, models.JobFailure{Message: "image URL validation failed"})
+	}
+
+	// Update job with image URL (atomic — handles concurrent callbacks)
+	if err := p.jobService.UpdateImageURL(ctx, job.ID, payload.Data.TaskID, imageURL); err != nil {
+		if isConflict(err) {
+			p.logger.Warn("nano callback conflict - already processed by another callback",
+				zap.String("job_id", job.ID.String()),
+			)
+			return nil
+		}
+		p.logger.Error("failed to update job with image URL",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return fmt.Errorf("failed to update image URL: %w", err)
+	}
+
+	// Enqueue process video task with deduplication
+	task, err := worker.NewProcessVideoTask(job.ID, traceID)
+	if err != nil {
+		p.logger.Error("failed to create process video task",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return p.failJob(ctx, job.ID, models.JobFailure{Message: "failed to enqueue process video task"})
+	}
+
+	if err := enqueueOrOutbox(ctx, p.outbox, p.asynqClient, task, job.ID); err != nil {
+		// Check if it's a duplicate task error (already enqueued)
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			p.logger.Warn("process video task already enqueued (duplicate callback)",
+				zap.String("job_id", job.ID.String()),
+			)
+			return nil
+		}
+		p.logger.Error("failed to enqueue process video task",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return p.failJob(ctx, job.ID, models.JobFailure{Message: "failed to enqueue process video task"})
+	}
+
+	p.logger.Info("nano callback processed, process video task enqueued",
+		zap.String("job_id", job.ID.String()),
+		zap.Bool("has_image_url", true), // Sanitized log - don't log the actual URL
+	)
+	return nil
+}
+
+// restartFlaggedImage restarts image generation with a sanitized prompt when the
+// image model refused the job's prompt under its content policy, e.g. for a
+// celebrity or brand named in the lyrics, and the job has a sanitize attempt left.
+// It reports whether the failure was handled this way and must not fail the job.
+func (p *WebhookProcessor) restartFlaggedImage(ctx context.Context, job *models.Job, payload *NanoWebhookPayload, traceID string) bool {
+	if !kie.IsContentPolicyFailure(payload.Data.FailCode, payload.Data.FailMsg) || !job.CanSanitizeImagePrompt() {
+		return false
+	}
+	logger := p.logger.With(
+		zap.String("job_id", job.ID.String()),
+		zap.String("task_id", payload.Data.TaskID),
+		zap.String("fail_code", payload.Data.FailCode),
+	)
+
+	if err := p.jobService.RestartImageGeneration(ctx, job); err != nil {
+		if isConflict(err) {
+			logger.Warn("flagged image already restarted or job moved on")
+			return true
+		}
+		logger.Error("failed to restart flagged image generation", zap.Error(err))
+		return false
+	}
+
+	task, err := worker.NewGenerateImageTask(job.ID, traceID)
+	if err != nil {
+		logger.Error("failed to create generate image task", zap.Error(err))
+		return false
+	}
+	if err := enqueueOrOutbox(ctx, p.outbox, p.asynqClient, task, job.ID); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		// The job is left in generating_image without tasks for the job reconciler
+		logger.Error("failed to enqueue generate image task, leaving job for reconciliation", zap.Error(err))
+		return true
+	}
+
+	logger.Info("image prompt refused under content policy, regenerating with a sanitized prompt")
+	return true
+}
+
+// failJob marks the job failed. It returns nil once the job is failed, since
+// applying the callback again would find the job terminal; only an error storing
+// the failure is returned, so the callback is retried while the job is still active.
+func (p *WebhookProcessor) failJob(ctx context.Context, jobID uuid.UUID, failure models.JobFailure) error {
+	if err := p.jobService.MarkFailure(ctx, jobID, failure); err != nil {
+		p.logger.Error("failed to mark job as failed",
+			zap.Error(err),
+			zap.String("job_id", jobID.String()),
+		)
+		return fmt.Errorf("failed to mark job failed: %w", err)
+	}
+	return nil
+}
+
+// nanoFailureMessage returns the job error for a failed NanoBanana task: a
+// content policy refusal gets an explanation, anything else KIE's failMsg.
+func nanoFailureMessage(payload *NanoWebhookPayload) string {
+	if kie.IsContentPolicyFailure(payload.Data.FailCode, payload.Data.FailMsg) {
+		return kie.ContentPolicyMessage
+	}
+	return payload.Data.FailMsg
+}
+
+// isConflict reports whether err is a 409 AppError, i.e. another callback already
+// moved the job on.
+func isConflict(err error) bool {
+	var appErr *apperrors.AppError
+	return errors.As(err, &appErr) && appErr.Code == http.StatusConflict
+}
+
+// extractImageURL parses the resultJson and extracts the first image URL.
+// The resultJson format is: {"resultUrls":["https://..."]}
+func extractImageURL(resultJson string) (string, error) {
+	if resultJson == "" {
+		return "", fmt.Errorf("empty resultJson")
+	}
+
+	var result struct {
+		ResultUrls []string `json:"resultUrls"`
+	}
+	if err := json.Unmarshal([]byte(resultJson), &result); err != nil {
+		return "", fmt.Errorf("failed to parse resultJson: %w", err)
+	}
+
+	if len(result.ResultUrls) == 0 {
+		return "", fmt.Errorf("no image URLs in resultJson")
+	}
+
+	return result.ResultUrls[0], nil
+}
+
+// ApplySunoTask applies a Suno task status fetched by the worker's polling fallback.
+// The status is converted to the equivalent callback and applied with ApplySuno, so
+// a poll that races the real callback is handled by the same status guards.
+// Only finished tasks should be passed; in-progress statuses are ignored.
+func (p *WebhookProcessor) ApplySunoTask(ctx context.Context, resp *kie.TaskResponse, traceID string) error {
+	var payload SunoWebhookPayload
+	payload.Code = http.StatusOK
+	payload.Data.TaskID = resp.Data.TaskId
+	payload.Data.ErrorMessage = resp.Data.ErrorMessage
+
+	switch resp.Data.Status {
+	case kie.StatusSuccess:
+		payload.Data.CallbackType = "complete"
+	case kie.StatusCallbackException:
+		// KIE finished generating but could not deliver the callback; use any tracks it returned
+		payload.Data.CallbackType = "complete"
+		if len(resp.Data.Response.SunoData) == 0 {
+			payload.Code = http.StatusInternalServerError
+		}
+	case kie.StatusCreateTaskFailed, kie.StatusGenerateAudioFailed, kie.StatusSensitiveWordError:
+		payload.Code = http.StatusInternalServerError
+		if resp.Data.Status == kie.StatusSensitiveWordError {
+			payload.Data.ErrorMessage = kie.SensitiveContentMessage
+		}
+	default:
+		return nil
+	}
+
+	for _, s := range resp.Data.Response.SunoData {
+		payload.Data.Data = append(payload.Data.Data, SunoWebhookSong{
+			ID:             s.Id,
+			AudioURL:       s.AudioUrl,
+			StreamAudioURL: s.StreamAudioUrl,
+			ImageURL:       s.ImageUrl,
+			Title:          s.Title,
+			Prompt:         s.Prompt,
+			Tags:           s.Tags,
+			Duration:       s.Duration,
+			CreateTime:     s.CreateTime,
+		})
+	}
+
+	return p.ApplySuno(ctx, &payload, traceID)
+}
+
+// ApplyNanoTask applies a NanoBanana task status fetched by the worker's polling
+// fallback. The status has the same shape as the callback and is applied with ApplyNano.
+func (p *WebhookProcessor) ApplyNanoTask(ctx context.Context, resp *kie.TaskStatusResponse, traceID string) error {
+	var payload NanoWebhookPayload
+	payload.Code = resp.Code
+	payload.Message = resp.Message
+	payload.Data.TaskID = resp.Data.TaskId
+	payload.Data.Model = resp.Data.Model
+	payload.Data.State = resp.Data.State
+	payload.Data.ResultJson = resp.Data.ResultJson
+	payload.Data.FailCode = resp.Data.FailCode
+	payload.Data.FailMsg = resp.Data.FailMsg
+
+	return p.ApplyNano(ctx, &payload, traceID)
+}
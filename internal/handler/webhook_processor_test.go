@@ -0,0 +1,232 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/handler"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// TestCancelDuringSunoCallback cancels a job after a Suno callback has loaded it
+// and before the callback writes, and checks that the callback cannot move the
+// job out of failed or replace the cancellation. It needs TEST_DATABASE_URL.
+func TestCancelDuringSunoCallback(t *testing.T) {
+	db := testutil.NewDB(t)
+	ctx := context.Background()
+	logger := zap.NewNop()
+
+	userRepo := repository.NewUserRepository(db)
+	user := &models.User{ID: uuid.New(), Email: "cancel-" + uuid.NewString() + "@example.com", PasswordHash: "unused"}
+	if err := userRepo.Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	songs := []handler.SunoWebhookSong{
+		{ID: "song-1", AudioURL: "https://cdn1.suno.ai/song-1.mp3", Title: "แสงไฟ", Duration: 121},
+		{ID: "song-2", AudioURL: "https://cdn1.suno.ai/song-2.mp3", Title: "แสงไฟ", Duration: 125},
+	}
+	tests := []struct {
+		name         string
+		code         int
+		callbackType string
+	}{
+		{name: "complete callback", code: 200, callbackType: "complete"},
+		{name: "first callback", code: 200, callbackType: "first"},
+		{name: "failed generation callback", code: 400, callbackType: "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobRepo := repository.NewJobRepository(db)
+			taskID := "suno-" + uuid.NewString()
+			job := &models.Job{UserID: user.ID, Concept: "city lights at night", Status: models.StatusGeneratingMusic, SunoTaskID: &taskID}
+			if err := jobRepo.Create(ctx, job); err != nil {
+				t.Fatalf("failed to create job: %v", err)
+			}
+
+			jobService := service.NewJobService(jobRepo, repository.NewOrganizationRepository(db), repository.NewUserSpendRepository(db), nil, 0, logger)
+			inFlight := &testutil.HookedJobRepository{JobRepository: jobRepo}
+			inFlight.AfterGetBySunoTaskID = func(loaded *models.Job) {
+				if loaded.Status != models.StatusGeneratingMusic {
+					t.Errorf("callback loaded status %s, want %s", loaded.Status, models.StatusGeneratingMusic)
+				}
+				// The user cancels while the callback holds the generating_music copy
+				if err := jobService.Cancel(ctx, user.ID, loaded.ID); err != nil {
+					t.Errorf("Cancel() error = %v", err)
+				}
+			}
+			processor := handler.NewWebhookProcessor(inFlight, nil, jobService, nil, nil, nil, 0, logger)
+
+			payload := &handler.SunoWebhookPayload{Code: tt.code, Msg: "generation failed"}
+			payload.Data.TaskID = taskID
+			payload.Data.CallbackType = tt.callbackType
+			if tt.code == 200 {
+				payload.Data.Data = songs
+			}
+			if err := processor.ApplySuno(ctx, payload, "trace"); err != nil {
+				t.Fatalf("ApplySuno() error = %v", err)
+			}
+
+			stored, err := jobRepo.GetByID(ctx, job.ID)
+			if err != nil {
+				t.Fatalf("failed to reload job: %v", err)
+			}
+			if stored.Status != models.StatusFailed || stored.CancelledAt == nil {
+				t.Errorf("job status = %s, cancelled_at = %v, want failed and cancelled", stored.Status, stored.CancelledAt)
+			}
+			if stored.ErrorMessage == nil || *stored.ErrorMessage != "job cancelled by user" {
+				t.Errorf("error message = %v, want the cancellation", stored.ErrorMessage)
+			}
+			if len(stored.GeneratedSongs) != 0 {
+				t.Errorf("cancelled job has %d generated songs, want none", len(stored.GeneratedSongs))
+			}
+		})
+	}
+}
+
+// sunoJobRepo returns job for its Suno task and no webhook events.
+type sunoJobRepo struct {
+	repository.JobRepository
+	job *models.Job
+}
+
+func (r *sunoJobRepo) GetBySunoTaskID(ctx context.Context, taskID string) (*models.Job, error) {
+	copied := *r.job
+	return &copied, nil
+}
+
+// failingJobService records the failures it is asked to store and returns err.
+type failingJobService struct {
+	service.JobService
+	err      error
+	failures []models.JobFailure
+}
+
+func (s *failingJobService) MarkFailure(ctx context.Context, jobID uuid.UUID, failure models.JobFailure) error {
+	s.failures = append(s.failures, failure)
+	return s.err
+}
+
+// TestApplySunoFailedGeneration checks that a failed generation callback is
+// acknowledged once its job is failed, and retried while the failure could not be stored.
+func TestApplySunoFailedGeneration(t *testing.T) {
+	taskID := "suno-task"
+	job := &models.Job{ID: uuid.New(), Status: models.StatusGeneratingMusic, SunoTaskID: &taskID}
+	payload := &handler.SunoWebhookPayload{Code: 400, Msg: "generation failed"}
+	payload.Data.TaskID = taskID
+
+	tests := []struct {
+		name    string
+		markErr error
+		wantErr bool
+	}{
+		{name: "job failed", markErr: nil},
+		{name: "database down", markErr: errors.New("connection refused"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobService := &failingJobService{err: tt.markErr}
+			processor := handler.NewWebhookProcessor(&sunoJobRepo{job: job}, nil, jobService, nil, nil, nil, 0, zap.NewNop())
+
+			err := processor.ApplySuno(context.Background(), payload, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplySuno() error = %v, want error %v", err, tt.wantErr)
+			}
+			if errors.Is(err, asynq.SkipRetry) {
+				t.Errorf("ApplySuno() error = %v; a storage failure must be retried", err)
+			}
+			if len(jobService.failures) != 1 || jobService.failures[0].Message != "generation failed" {
+				t.Errorf("stored failures %+v, want the callback's message once", jobService.failures)
+			}
+		})
+	}
+}
+
+// TestApplySunoSkipsDeletedJob checks that a late callback for a job the user
+// deleted is acknowledged without writing to it. sunoJobRepo panics on writes.
+func TestApplySunoSkipsDeletedJob(t *testing.T) {
+	taskID := "suno-task"
+	deletedAt := time.Now().Add(-time.Minute)
+	job := &models.Job{ID: uuid.New(), Status: models.StatusGeneratingMusic, SunoTaskID: &taskID, DeletedAt: &deletedAt}
+
+	tests := []struct {
+		name         string
+		code         int
+		callbackType string
+	}{
+		{name: "complete callback", code: 200, callbackType: "complete"},
+		{name: "failed generation callback", code: 400, callbackType: "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobService := &failingJobService{}
+			processor := handler.NewWebhookProcessor(&sunoJobRepo{job: job}, nil, jobService, nil, nil, nil, 0, zap.NewNop())
+
+			payload := &handler.SunoWebhookPayload{Code: tt.code, Msg: "generation failed"}
+			payload.Data.TaskID = taskID
+			payload.Data.CallbackType = tt.callbackType
+			payload.Data.Data = []handler.SunoWebhookSong{{ID: "song-1", AudioURL: "https://cdn1.suno.ai/song-1.mp3", Duration: 121}}
+
+			if err := processor.ApplySuno(context.Background(), payload, ""); err != nil {
+				t.Fatalf("ApplySuno() error = %v, want the callback acknowledged", err)
+			}
+			if len(jobService.failures) != 0 {
+				t.Errorf("deleted job was failed with %+v", jobService.failures)
+			}
+		})
+	}
+}
+
+// missingEventRepo knows no webhook events.
+type missingEventRepo struct {
+	repository.WebhookEventRepository
+}
+
+func (missingEventRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.WebhookEvent, error) {
+	return nil, repository.ErrWebhookEventNotFound
+}
+
+// storedEventRepo returns event.
+type storedEventRepo struct {
+	repository.WebhookEventRepository
+	event *models.WebhookEvent
+}
+
+func (r storedEventRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.WebhookEvent, error) {
+	return r.event, nil
+}
+
+// TestReprocessEventSkipsRetryForPermanentErrors checks that deferred callbacks
+// that can never be applied are not retried.
+func TestReprocessEventSkipsRetryForPermanentErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		repo repository.WebhookEventRepository
+	}{
+		{name: "event pruned", repo: missingEventRepo{}},
+		{name: "unreadable body", repo: storedEventRepo{event: &models.WebhookEvent{ID: uuid.New(), Source: "suno", Body: "{"}}},
+		{name: "unknown source", repo: storedEventRepo{event: &models.WebhookEvent{ID: uuid.New(), Source: "veo", Body: "{}"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := handler.NewWebhookProcessor(nil, tt.repo, nil, nil, nil, nil, 0, zap.NewNop())
+			err := processor.ReprocessEvent(context.Background(), uuid.New(), "")
+			if !errors.Is(err, asynq.SkipRetry) {
+				t.Fatalf("ReprocessEvent() error = %v, want asynq.SkipRetry", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+// Package lyrics parses Suno lyrics markup into song sections.
+//
+// Song prompts mix lyric lines with bracketed metatags: structure tags such as
+// [Verse 1] or [Chorus] start a section, while other tags ([Whisper], [Reverb])
+// are performance cues for the current section. Ad-libs in parentheses stay on
+// the line they belong to.
+package lyrics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Section types. Tags outside this set are treated as cues.
+const (
+	SectionUnlabeled    = "unlabeled" // Lines before the first structure tag
+	SectionIntro        = "intro"
+	SectionVerse        = "verse"
+	SectionPreChorus    = "pre_chorus"
+	SectionChorus       = "chorus"
+	SectionPostChorus   = "post_chorus"
+	SectionBridge       = "bridge"
+	SectionOutro        = "outro"
+	SectionHook         = "hook"
+	SectionBreak        = "break"
+	SectionDrop         = "drop"
+	SectionBuildup      = "buildup"
+	SectionInterlude    = "interlude"
+	SectionInstrumental = "instrumental"
+	SectionRefrain      = "refrain"
+	SectionSolo         = "solo"
+	SectionEnd          = "end"
+)
+
+// sectionTypes maps a normalized tag name to its section type, including common
+// spellings of the tags in the default song concept prompt and the Thai names
+// the model sometimes writes instead, e.g. [ท่อนฮุค] for [Chorus].
+var sectionTypes = map[string]string{
+	"intro":        SectionIntro,
+	"verse":        SectionVerse,
+	"pre_chorus":   SectionPreChorus,
+	"prechorus":    SectionPreChorus,
+	"chorus":       SectionChorus,
+	"post_chorus":  SectionPostChorus,
+	"postchorus":   SectionPostChorus,
+	"bridge":       SectionBridge,
+	"outro":        SectionOutro,
+	"hook":         SectionHook,
+	"break":        SectionBreak,
+	"drop":         SectionDrop,
+	"buildup":      SectionBuildup,
+	"build_up":     SectionBuildup,
+	"interlude":    SectionInterlude,
+	"instrumental": SectionInstrumental,
+	"refrain":      SectionRefrain,
+	"solo":         SectionSolo,
+	"end":          SectionEnd,
+
+	"อินโทร":      SectionIntro,
+	"ท่อน":        SectionVerse, // [ท่อน 1], [ท่อน 2]
+	"ท่อนที่":     SectionVerse, // [ท่อนที่ 1]
+	"ท่อนร้อง":    SectionVerse,
+	"เวิร์ส":      SectionVerse,
+	"ท่อนก่อนฮุค": SectionPreChorus,
+	"พรีคอรัส":    SectionPreChorus,
+	"ท่อนฮุค":     SectionChorus,
+	"คอรัส":       SectionChorus,
+	"ฮุค":         SectionHook,
+	"ท่อนแยก":     SectionBridge,
+	"บริดจ์":      SectionBridge,
+	"ท่อนจบ":      SectionOutro,
+	"เอาท์โทร":    SectionOutro,
+	"โซโล่":       SectionSolo,
+	"ดนตรี":       SectionInstrumental,
+}
+
+// Section is one part of a song.
+type Section struct {
+	Type   string   `json:"type"`             // One of the Section* constants
+	Label  string   `json:"label,omitempty"`  // Tag as written, e.g. "Verse 1"
+	Detail string   `json:"detail,omitempty"` // Text after a colon, e.g. "Acoustic guitar" in [Intro: Acoustic guitar]
+	Cues   []string `json:"cues,omitempty"`   // Performance tags such as "Whisper" or "Reverb"
+	Lines  []string `json:"lines"`            // Lyric lines with tags removed; empty for instrumental parts
+}
+
+// Lyrics is a parsed song.
+type Lyrics struct {
+	Sections []Section `json:"sections"`
+}
+
+// Parse splits lyrics markup into sections. It never fails: unclosed brackets
+// close at the end of the line, tags nested in a tag become cues, and lines
+// before the first structure tag form an unlabeled section.
+func Parse(text string) *Lyrics {
+	p := &parser{lyrics: &Lyrics{Sections: make([]Section, 0)}}
+	for _, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		p.parseLine(raw)
+	}
+	return p.lyrics
+}
+
+type parser struct {
+	lyrics  *Lyrics
+	current *Section
+}
+
+// parseLine handles one line of markup: its tags first, then its lyric text.
+func (p *parser) parseLine(raw string) {
+	tags, text := splitTags(raw)
+	for _, tag := range tags {
+		p.applyTag(tag)
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return
+	}
+
+	section := p.section()
+	// A line holding only an ad-lib answers the line before it
+	if isAdLib(text) && len(section.Lines) > 0 {
+		section.Lines[len(section.Lines)-1] += " " + text
+		return
+	}
+	section.Lines = append(section.Lines, text)
+}
+
+// applyTag starts a section for a structure tag, or records any other tag as a
+// cue of the current section.
+func (p *parser) applyTag(tag string) {
+	name, detail, _ := strings.Cut(tag, ":")
+	name = strings.TrimSpace(name)
+	detail = strings.TrimSpace(detail)
+
+	sectionType, ok := sectionTypes[normalizeTagName(name)]
+	if !ok {
+		section := p.section()
+		section.Cues = append(section.Cues, tag)
+		return
+	}
+
+	p.lyrics.Sections = append(p.lyrics.Sections, Section{
+		Type:   sectionType,
+		Label:  name,
+		Detail: detail,
+		Lines:  make([]string, 0),
+	})
+	p.current = &p.lyrics.Sections[len(p.lyrics.Sections)-1]
+}
+
+// section returns the section being filled, opening an unlabeled one if no
+// structure tag has been seen yet.
+func (p *parser) section() *Section {
+	if p.current == nil {
+		p.lyrics.Sections = append(p.lyrics.Sections, Section{Type: SectionUnlabeled, Lines: make([]string, 0)})
+		p.current = &p.lyrics.Sections[len(p.lyrics.Sections)-1]
+	}
+	return p.current
+}
+
+// splitTags removes the bracketed tags from a line, returning them and the
+// remaining text. A nested tag follows its outer tag ([Chorus [Belting]] yields
+// "Chorus" then "Belting"); an unclosed tag runs to the end of the line.
+func splitTags(line string) ([]string, string) {
+	var (
+		tags   []string
+		nested []string
+		text   strings.Builder
+		outer  strings.Builder
+		inner  strings.Builder
+		depth  int
+	)
+
+	appendTag := func(list []string, b *strings.Builder) []string {
+		if t := strings.Join(strings.Fields(b.String()), " "); t != "" {
+			list = append(list, t)
+		}
+		b.Reset()
+		return list
+	}
+	closeOuter := func() {
+		tags = appendTag(tags, &outer)
+		nested = appendTag(nested, &inner)
+		tags = append(tags, nested...)
+		nested = nested[:0]
+	}
+
+	for _, r := range line {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']' && depth == 1:
+			depth = 0
+			closeOuter()
+			text.WriteRune(' ')
+		case r == ']' && depth > 1:
+			depth--
+			if depth == 1 {
+				nested = appendTag(nested, &inner)
+			}
+		case r == ']':
+			// Stray closing bracket
+		case depth == 1:
+			outer.WriteRune(r)
+		case depth > 1:
+			inner.WriteRune(r)
+		default:
+			text.WriteRune(r)
+		}
+	}
+	if depth > 0 {
+		closeOuter()
+	}
+
+	return tags, text.String()
+}
+
+// normalizeTagName turns a tag name into a sectionTypes key: lowercase, words
+// joined with underscores and a trailing number dropped ("Verse 2" is "verse").
+func normalizeTagName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '-' || r == '_'
+	})
+	if n := len(words); n > 1 && isNumber(words[n-1]) {
+		words = words[:n-1]
+	}
+	if len(words) > 0 {
+		words[len(words)-1] = strings.TrimRightFunc(words[len(words)-1], unicode.IsDigit)
+	}
+	return strings.Join(words, "_")
+}
+
+func isNumber(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// isAdLib reports whether text is a single parenthesized ad-lib such as "(oh yeah)".
+func isAdLib(text string) bool {
+	return strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")") &&
+		strings.Count(text, "(") == 1 && strings.Count(text, ")") == 1
+}
+
+// Text returns the lyrics as plain text: one line per lyric line and a blank
+// line between sections. Tags are left out; sections without lines are skipped.
+func (l *Lyrics) Text() string {
+	var sb strings.Builder
+	for _, section := range l.Sections {
+		if len(section.Lines) == 0 {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		for _, line := range section.Lines {
+			sb.WriteString(line)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// LRC returns the lyrics in LRC format with a title header. Suno does not report
+// line timings, so every line is stamped [00:00.00] for the user to adjust; the
+// [length] header is set when duration is known.
+func (l *Lyrics) LRC(title string, duration time.Duration) string {
+	var sb strings.Builder
+	if title = strings.TrimSpace(title); title != "" {
+		fmt.Fprintf(&sb, "[ti:%s]\n", title)
+	}
+	if duration > 0 {
+		seconds := int(duration.Round(time.Second) / time.Second)
+		fmt.Fprintf(&sb, "[length:%02d:%02d]\n", seconds/60, seconds%60)
+	}
+
+	for _, section := range l.Sections {
+		for _, line := range section.Lines {
+			fmt.Fprintf(&sb, "[00:00.00]%s\n", line)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,138 @@
+package lyrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Section
+	}{
+		{
+			name: "default prompt template tags with thai lyrics",
+			text: "[Intro: Acoustic guitar]\n\n[Verse 1]\nแสงไฟในเมืองหลวง\nส่องทางให้ฉันกลับบ้าน\n\n" +
+				"[Pre-Chorus]\nหัวใจยังรอ\n\n[Chorus]\nเมืองนี้ไม่เคยหลับ\nเหมือนใจที่ไม่เคยลืม\n\n" +
+				"[Verse 2]\nถนนเส้นเดิม\n\n[Bridge]\nถ้าวันหนึ่งเธอกลับมา\n\n[Hook]\nไม่เคยลืม\n\n[Outro]\nหลับตาแล้วฝัน",
+			want: []Section{
+				{Type: SectionIntro, Label: "Intro", Detail: "Acoustic guitar", Lines: []string{}},
+				{Type: SectionVerse, Label: "Verse 1", Lines: []string{"แสงไฟในเมืองหลวง", "ส่องทางให้ฉันกลับบ้าน"}},
+				{Type: SectionPreChorus, Label: "Pre-Chorus", Lines: []string{"หัวใจยังรอ"}},
+				{Type: SectionChorus, Label: "Chorus", Lines: []string{"เมืองนี้ไม่เคยหลับ", "เหมือนใจที่ไม่เคยลืม"}},
+				{Type: SectionVerse, Label: "Verse 2", Lines: []string{"ถนนเส้นเดิม"}},
+				{Type: SectionBridge, Label: "Bridge", Lines: []string{"ถ้าวันหนึ่งเธอกลับมา"}},
+				{Type: SectionHook, Label: "Hook", Lines: []string{"ไม่เคยลืม"}},
+				{Type: SectionOutro, Label: "Outro", Lines: []string{"หลับตาแล้วฝัน"}},
+			},
+		},
+		{
+			name: "thai section headers",
+			text: "[อินโทร: กีตาร์โปร่ง]\n[ท่อน 1]\nคืนนี้ฟ้าสวย\n[ท่อนที่ 2]\nดาวเต็มฟ้า\n[ท่อนก่อนฮุค]\nใจเต้นแรง\n" +
+				"[ท่อนฮุค]\nรักเธอทั้งหัวใจ\n[ท่อนแยก]\nถ้าเธอไม่อยู่\n[ดนตรี]\n[ท่อนจบ]\nลาก่อน",
+			want: []Section{
+				{Type: SectionIntro, Label: "อินโทร", Detail: "กีตาร์โปร่ง", Lines: []string{}},
+				{Type: SectionVerse, Label: "ท่อน 1", Lines: []string{"คืนนี้ฟ้าสวย"}},
+				{Type: SectionVerse, Label: "ท่อนที่ 2", Lines: []string{"ดาวเต็มฟ้า"}},
+				{Type: SectionPreChorus, Label: "ท่อนก่อนฮุค", Lines: []string{"ใจเต้นแรง"}},
+				{Type: SectionChorus, Label: "ท่อนฮุค", Lines: []string{"รักเธอทั้งหัวใจ"}},
+				{Type: SectionBridge, Label: "ท่อนแยก", Lines: []string{"ถ้าเธอไม่อยู่"}},
+				{Type: SectionInstrumental, Label: "ดนตรี", Lines: []string{}},
+				{Type: SectionOutro, Label: "ท่อนจบ", Lines: []string{"ลาก่อน"}},
+			},
+		},
+		{
+			name: "thai digits in a verse number",
+			text: "[ท่อน ๒]\nดาวเต็มฟ้า",
+			want: []Section{
+				{Type: SectionVerse, Label: "ท่อน ๒", Lines: []string{"ดาวเต็มฟ้า"}},
+			},
+		},
+		{
+			name: "mixed thai and english lines",
+			text: "[Chorus]\nรักเธอ baby ทุกคืน\nOh ฉันยังคิดถึง you\nคืนนี้ only you (only you)\nเต้นไปกับฉัน\n(โอ้ เย่ yeah)",
+			want: []Section{
+				{Type: SectionChorus, Label: "Chorus", Lines: []string{
+					"รักเธอ baby ทุกคืน",
+					"Oh ฉันยังคิดถึง you",
+					"คืนนี้ only you (only you)",
+					"เต้นไปกับฉัน (โอ้ เย่ yeah)",
+				}},
+			},
+		},
+		{
+			name: "thai cues and a nested cue",
+			text: "[ท่อนฮุค [Belting]]\nร้องให้ดังที่สุด\n[กระซิบ]\nเบาๆ นะ",
+			want: []Section{
+				{Type: SectionChorus, Label: "ท่อนฮุค", Cues: []string{"Belting", "กระซิบ"}, Lines: []string{"ร้องให้ดังที่สุด", "เบาๆ นะ"}},
+			},
+		},
+		{
+			name: "tag on the same line as the lyrics",
+			text: "[Verse 1] แสงไฟ  ในเมือง\n[Chorus]เมืองนี้ไม่เคยหลับ",
+			want: []Section{
+				{Type: SectionVerse, Label: "Verse 1", Lines: []string{"แสงไฟ ในเมือง"}},
+				{Type: SectionChorus, Label: "Chorus", Lines: []string{"เมืองนี้ไม่เคยหลับ"}},
+			},
+		},
+		{
+			name: "lines before the first tag",
+			text: "เพลงนี้ให้เธอ\n[Verse]\nแสงไฟ",
+			want: []Section{
+				{Type: SectionUnlabeled, Lines: []string{"เพลงนี้ให้เธอ"}},
+				{Type: SectionVerse, Label: "Verse", Lines: []string{"แสงไฟ"}},
+			},
+		},
+		{
+			name: "unclosed tag ends at the end of the line",
+			text: "[ท่อนฮุค\nรักเธอ",
+			want: []Section{
+				{Type: SectionChorus, Label: "ท่อนฮุค", Lines: []string{"รักเธอ"}},
+			},
+		},
+		{
+			name: "windows line endings",
+			text: "[Verse]\r\nแสงไฟ\r\n(เย่)\r\n",
+			want: []Section{
+				{Type: SectionVerse, Label: "Verse", Lines: []string{"แสงไฟ (เย่)"}},
+			},
+		},
+		{
+			name: "empty",
+			text: "",
+			want: []Section{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.text).Sections
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() sections =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLyricsText(t *testing.T) {
+	parsed := Parse("[Intro]\n[Verse 1]\nแสงไฟ\nในเมือง\n[ท่อนฮุค]\nรักเธอ baby (oh)")
+
+	want := "แสงไฟ\nในเมือง\n\nรักเธอ baby (oh)\n"
+	if got := parsed.Text(); got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
+
+func TestLyricsLRC(t *testing.T) {
+	parsed := Parse("[Verse 1]\nแสงไฟ\n[Chorus]\nรักเธอ")
+
+	want := "[ti:แสงไฟ]\n[length:02:05]\n[00:00.00]แสงไฟ\n[00:00.00]รักเธอ\n"
+	if got := parsed.LRC(" แสงไฟ ", 124600*time.Millisecond); got != want {
+		t.Errorf("LRC() = %q, want %q", got, want)
+	}
+	if got := parsed.LRC("", 0); got != "[00:00.00]แสงไฟ\n[00:00.00]รักเธอ\n" {
+		t.Errorf("LRC() without title and duration = %q", got)
+	}
+}
@@ -0,0 +1,229 @@
+// Package metrics provides Prometheus instrumentation for the API, worker and webhooks.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+)
+
+const namespace = "ugc"
+
+// Pipeline stages reported by the stage duration histogram.
+const (
+	StageAnalyze = models.StageAnalyze
+	StageMusic   = models.StageMusic
+	StageImage   = models.StageImage
+	StageVideo   = models.StageVideo
+	StageUpload  = models.StageUpload
+)
+
+// Webhook callback outcomes.
+const (
+	WebhookAccepted = "accepted"
+	WebhookRejected = "rejected"
+)
+
+// JobStatusCounter counts jobs grouped by status.
+// It is satisfied by repository.JobRepository.
+type JobStatusCounter interface {
+	CountByStatus(ctx context.Context) (map[string]int64, error)
+}
+
+// Metrics holds all application collectors.
+// A nil *Metrics is valid and records nothing, so instrumentation can be disabled.
+type Metrics struct {
+	registry prometheus.Registerer
+	gatherer prometheus.Gatherer
+
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+	tasksStarted     *prometheus.CounterVec
+	tasksSucceeded   *prometheus.CounterVec
+	tasksFailed      *prometheus.CounterVec
+	stageDuration    *prometheus.HistogramVec
+	jobsByStatus     *prometheus.GaugeVec
+	webhookCallbacks *prometheus.CounterVec
+	r2CleanupAborted *prometheus.CounterVec
+}
+
+// New creates the collectors and registers them with reg.
+// Pass prometheus.NewRegistry() in tests to inspect values in isolation.
+func New(reg *prometheus.Registry) *Metrics {
+	m := &Metrics{
+		registry: reg,
+		gatherer: reg,
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		tasksStarted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "worker_tasks_started_total",
+			Help:      "Worker tasks started by task type.",
+		}, []string{"type"}),
+		tasksSucceeded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "worker_tasks_succeeded_total",
+			Help:      "Worker tasks that returned without error by task type.",
+		}, []string{"type"}),
+		tasksFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "worker_tasks_failed_total",
+			Help:      "Worker tasks that returned an error by task type.",
+		}, []string{"type"}),
+		stageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "pipeline_stage_duration_seconds",
+			Help:      "Duration of the main task of each pipeline stage.",
+			Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200},
+		}, []string{"stage"}),
+		jobsByStatus: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "jobs",
+			Help:      "Jobs currently in each status.",
+		}, []string{"status"}),
+		webhookCallbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_callbacks_total",
+			Help:      "Webhook callbacks by source and outcome (accepted or rejected).",
+		}, []string{"source", "result"}),
+		r2CleanupAborted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "r2_cleanup_aborted_total",
+			Help:      "R2 cleanup passes aborted by the safety breaker, by reason. Any increase needs a look at the database.",
+		}, []string{"reason"}),
+	}
+
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.tasksStarted,
+		m.tasksSucceeded,
+		m.tasksFailed,
+		m.stageDuration,
+		m.jobsByStatus,
+		m.webhookCallbacks,
+		m.r2CleanupAborted,
+	)
+
+	return m
+}
+
+// Handler returns the HTTP handler that serves the registry in Prometheus format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveHTTPRequest records a completed HTTP request.
+func (m *Metrics) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// TaskStarted records the start of a worker task.
+func (m *Metrics) TaskStarted(taskType string) {
+	if m == nil {
+		return
+	}
+	m.tasksStarted.WithLabelValues(taskType).Inc()
+}
+
+// TaskFinished records the outcome of a worker task.
+func (m *Metrics) TaskFinished(taskType string, err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.tasksFailed.WithLabelValues(taskType).Inc()
+		return
+	}
+	m.tasksSucceeded.WithLabelValues(taskType).Inc()
+}
+
+// ObserveStage records how long a pipeline stage took.
+func (m *Metrics) ObserveStage(stage string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.stageDuration.WithLabelValues(stage).Observe(duration.Seconds())
+}
+
+// WebhookCallback records a webhook callback from source with the given result.
+func (m *Metrics) WebhookCallback(source, result string) {
+	if m == nil {
+		return
+	}
+	m.webhookCallbacks.WithLabelValues(source, result).Inc()
+}
+
+// R2CleanupAborted records an R2 cleanup pass aborted for reason.
+func (m *Metrics) R2CleanupAborted(reason string) {
+	if m == nil {
+		return
+	}
+	m.r2CleanupAborted.WithLabelValues(reason).Inc()
+}
+
+// SetJobStatusCounts replaces the jobs-by-status gauge values.
+// Statuses missing from counts are reported as zero.
+func (m *Metrics) SetJobStatusCounts(counts map[string]int64) {
+	if m == nil {
+		return
+	}
+	for _, status := range models.AllStatuses {
+		m.jobsByStatus.WithLabelValues(status).Set(float64(counts[status]))
+	}
+}
+
+// RunJobStatusCollector refreshes the jobs-by-status gauge every interval until ctx is done.
+func (m *Metrics) RunJobStatusCollector(ctx context.Context, counter JobStatusCounter, interval time.Duration, logger *zap.Logger) {
+	if m == nil {
+		return
+	}
+
+	refresh := func() {
+		counts, err := counter.CountByStatus(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Warn("failed to refresh job status metrics", zap.Error(err))
+			}
+			return
+		}
+		m.SetJobStatusCounts(counts)
+	}
+
+	refresh()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
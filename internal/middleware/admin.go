@@ -5,6 +5,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/jaochai/ugc/internal/models"
 	"github.com/jaochai/ugc/pkg/response"
 )
 
@@ -19,7 +20,7 @@ func AdminMiddleware(logger *zap.Logger) gin.HandlerFunc {
 			return
 		}
 
-		if role != "admin" {
+		if role != models.RoleAdmin {
 			logger.Debug("non-admin user attempted admin access",
 				zap.String("role", role),
 			)
@@ -2,6 +2,7 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -9,6 +10,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/jaochai/ugc/internal/service"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
 	"github.com/jaochai/ugc/pkg/response"
 )
 
@@ -40,11 +42,21 @@ func AuthMiddleware(authService service.AuthService, logger *zap.Logger) gin.Han
 
 		tokenString := parts[1]
 
-		// Validate token
-		claims, err := authService.ValidateToken(tokenString)
+		// Validate token and reject tokens of deleted accounts
+		claims, err := authService.AuthenticateToken(c.Request.Context(), tokenString)
 		if err != nil {
 			logger.Debug("token validation failed", zap.Error(err))
-			response.Unauthorized(c, "invalid or expired token")
+			switch {
+			case errors.Is(err, service.ErrAccountDeleted), errors.Is(err, service.ErrAccountDisabled):
+				response.Error(c, err)
+			case errors.Is(err, service.ErrTokenExpired):
+				response.Error(c, apperrors.NewUnauthorized("invalid or expired token").WithCode(apperrors.CodeTokenExpired))
+			case errors.Is(err, service.ErrInvalidToken):
+				response.Error(c, apperrors.NewUnauthorized("invalid or expired token").WithCode(apperrors.CodeInvalidToken))
+			default:
+				logger.Error("failed to authenticate token", zap.Error(err))
+				response.Error(c, err)
+			}
 			c.Abort()
 			return
 		}
@@ -53,6 +65,7 @@ func AuthMiddleware(authService service.AuthService, logger *zap.Logger) gin.Han
 		c.Set(ContextKeyUserID, claims.UserID)
 		c.Set(ContextKeyEmail, claims.Email)
 		c.Set(ContextKeyRole, claims.Role)
+		response.SetLocale(c, claims.Locale)
 
 		c.Next()
 	}
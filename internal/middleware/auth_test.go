@@ -0,0 +1,84 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/middleware"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/testutil"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/response"
+)
+
+const testJWTSecret = "test-jwt-secret-0123456789abcdef0123456789"
+
+// newAuthRouter serves GET /me behind AuthMiddleware over users, replying with
+// the authenticated user ID.
+func newAuthRouter(users *testutil.FakeUserRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := zap.NewNop()
+	authService := service.NewAuthService(users, nil, nil, nil, nil, service.AuthConfig{JWTSecret: testJWTSecret}, logger)
+
+	router := gin.New()
+	router.GET("/me", middleware.AuthMiddleware(authService, logger), func(c *gin.Context) {
+		userID, _ := middleware.GetUserIDFromContext(c)
+		response.Success(c, gin.H{"user_id": userID})
+	})
+	return router
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	deletedAt := time.Now().Add(-time.Minute)
+	active := &models.User{ID: uuid.New(), Email: "active@example.com", Role: models.RoleUser}
+	deleted := &models.User{ID: uuid.New(), Email: "deleted@example.com", Role: models.RoleUser, DeletedAt: &deletedAt}
+	disabled := &models.User{ID: uuid.New(), Email: "disabled@example.com", Role: models.RoleUser, Disabled: true}
+	unknown := &models.User{ID: uuid.New(), Email: "unknown@example.com", Role: models.RoleUser}
+
+	router := newAuthRouter(testutil.NewFakeUserRepository(active, deleted, disabled))
+
+	tests := []struct {
+		name          string
+		header        string
+		wantStatus    int
+		wantErrorCode string
+	}{
+		{name: "active user", header: "Bearer " + testutil.AccessToken(t, testJWTSecret, active), wantStatus: http.StatusOK},
+		{name: "soft-deleted user", header: "Bearer " + testutil.AccessToken(t, testJWTSecret, deleted), wantStatus: http.StatusUnauthorized, wantErrorCode: apperrors.CodeAccountDeleted},
+		{name: "disabled user", header: "Bearer " + testutil.AccessToken(t, testJWTSecret, disabled), wantStatus: http.StatusForbidden, wantErrorCode: apperrors.CodeAccountDisabled},
+		{name: "user no longer stored", header: "Bearer " + testutil.AccessToken(t, testJWTSecret, unknown), wantStatus: http.StatusUnauthorized},
+		{name: "token signed with another secret", header: "Bearer " + testutil.AccessToken(t, "another-secret-0123456789abcdef", active), wantStatus: http.StatusUnauthorized, wantErrorCode: apperrors.CodeInvalidToken},
+		{name: "missing header", header: "", wantStatus: http.StatusUnauthorized},
+		{name: "not a bearer token", header: "Basic dXNlcjpwYXNz", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			var resp response.Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("response is not the standard envelope: %v", err)
+			}
+			if tt.wantErrorCode != "" && (resp.Error == nil || resp.Error.ErrorCode != tt.wantErrorCode) {
+				t.Errorf("error = %+v, want error_code %s", resp.Error, tt.wantErrorCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jaochai/ugc/internal/middleware"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/response"
+)
+
+const (
+	testMaxBodyBytes    = 1 << 10
+	testWebhookMaxBytes = 4 << 10
+	testUploadMaxBytes  = 16 << 10
+	testMaxJSONDepth    = 5
+)
+
+// newBodyLimitRouter mirrors the route groups of setupRouter with small limits.
+// Handlers echo the size of the body they read; the upload handler reports a
+// capped stream the way JobHandler.UploadImage does.
+func newBodyLimitRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	echo := func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			response.Error(c, apperrors.NewInvalidRequestBody())
+			return
+		}
+		response.Success(c, gin.H{"bytes": len(body)})
+	}
+	upload := func(c *gin.Context) {
+		if _, err := c.FormFile("image"); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				response.Error(c, apperrors.NewRequestTooLarge(maxBytesErr.Limit))
+				return
+			}
+			response.BadRequest(c, "multipart field \"image\" is required")
+			return
+		}
+		response.Success(c, gin.H{"uploaded": true})
+	}
+
+	v1 := router.Group("/api/v1")
+	api := v1.Group("", middleware.BodyLimitMiddleware(middleware.BodyLimitConfig{
+		MaxBytes:     testMaxBodyBytes,
+		MaxJSONDepth: testMaxJSONDepth,
+		Overrides: map[string]int64{
+			"/api/v1/jobs/:id/image": testUploadMaxBytes,
+		},
+	}))
+	api.POST("/auth/login", echo)
+	api.POST("/jobs", echo)
+	api.POST("/jobs/:id/image", upload)
+
+	webhooks := v1.Group("", middleware.BodyLimitMiddleware(middleware.BodyLimitConfig{
+		MaxBytes:     testWebhookMaxBytes,
+		MaxJSONDepth: testMaxJSONDepth,
+	}))
+	webhooks.POST("/webhooks/:token/suno/:job_id", echo)
+
+	return router
+}
+
+// jsonBody returns a JSON object of roughly size bytes.
+func jsonBody(size int) string {
+	return `{"concept": "` + strings.Repeat("a", size) + `"}`
+}
+
+// chunked hides the length of body so the request has no Content-Length.
+type chunked struct{ io.Reader }
+
+// multipartImage returns a multipart form with an image field of size bytes.
+func multipartImage(t *testing.T, size int) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, err := writer.CreateFormFile("image", "cover.png")
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	part.Write(bytes.Repeat([]byte{0x89}, size))
+	writer.Close()
+	return &buf, writer.FormDataContentType()
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	router := newBodyLimitRouter()
+
+	tests := []struct {
+		name          string
+		path          string
+		body          func(t *testing.T) (io.Reader, string)
+		noLength      bool
+		wantStatus    int
+		wantErrorCode string
+	}{
+		{
+			name:       "auth body under the limit",
+			path:       "/api/v1/auth/login",
+			body:       func(t *testing.T) (io.Reader, string) { return strings.NewReader(jsonBody(100)), "application/json" },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "auth body over the limit",
+			path: "/api/v1/auth/login",
+			body: func(t *testing.T) (io.Reader, string) {
+				return strings.NewReader(jsonBody(2 << 10)), "application/json"
+			},
+			wantStatus:    http.StatusRequestEntityTooLarge,
+			wantErrorCode: apperrors.CodeRequestTooLarge,
+		},
+		{
+			name: "jobs body over the limit",
+			path: "/api/v1/jobs",
+			body: func(t *testing.T) (io.Reader, string) {
+				return strings.NewReader(jsonBody(2 << 10)), "application/json"
+			},
+			wantStatus:    http.StatusRequestEntityTooLarge,
+			wantErrorCode: apperrors.CodeRequestTooLarge,
+		},
+		{
+			name: "chunked jobs body over the limit",
+			path: "/api/v1/jobs",
+			body: func(t *testing.T) (io.Reader, string) {
+				return chunked{strings.NewReader(jsonBody(2 << 10))}, "application/json"
+			},
+			noLength:      true,
+			wantStatus:    http.StatusRequestEntityTooLarge,
+			wantErrorCode: apperrors.CodeRequestTooLarge,
+		},
+		{
+			name: "chunked jobs body under the limit",
+			path: "/api/v1/jobs",
+			body: func(t *testing.T) (io.Reader, string) {
+				return chunked{strings.NewReader(jsonBody(100))}, "application/json"
+			},
+			noLength:   true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "webhook body over the API limit but under its own",
+			path: "/api/v1/webhooks/token/suno/job",
+			body: func(t *testing.T) (io.Reader, string) {
+				return strings.NewReader(jsonBody(2 << 10)), "application/json"
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "webhook body over the limit",
+			path: "/api/v1/webhooks/token/suno/job",
+			body: func(t *testing.T) (io.Reader, string) {
+				return strings.NewReader(jsonBody(8 << 10)), "application/json"
+			},
+			wantStatus:    http.StatusRequestEntityTooLarge,
+			wantErrorCode: apperrors.CodeRequestTooLarge,
+		},
+		{
+			name:       "upload over the API limit but under the override",
+			path:       "/api/v1/jobs/123/image",
+			body:       func(t *testing.T) (io.Reader, string) { return multipartImage(t, 8<<10) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:          "upload over the override",
+			path:          "/api/v1/jobs/123/image",
+			body:          func(t *testing.T) (io.Reader, string) { return multipartImage(t, 32<<10) },
+			wantStatus:    http.StatusRequestEntityTooLarge,
+			wantErrorCode: apperrors.CodeRequestTooLarge,
+		},
+		{
+			name: "JSON nested too deep",
+			path: "/api/v1/jobs",
+			body: func(t *testing.T) (io.Reader, string) {
+				return strings.NewReader(`{"a": [[[[[["too deep"]]]]]]}`), "application/json"
+			},
+			wantStatus:    http.StatusBadRequest,
+			wantErrorCode: apperrors.CodeJSONTooDeep,
+		},
+		{
+			name: "brackets inside strings do not count",
+			path: "/api/v1/jobs",
+			body: func(t *testing.T) (io.Reader, string) {
+				return strings.NewReader(`{"concept": "[[[[[[{{{{{{ \" ]]]"}`), "application/json"
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType := tt.body(t)
+			req := httptest.NewRequest(http.MethodPost, tt.path, body)
+			req.Header.Set("Content-Type", contentType)
+			if tt.noLength {
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			var resp response.Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("response is not the standard envelope: %v; body: %s", err, rec.Body.String())
+			}
+			if tt.wantErrorCode == "" {
+				if !resp.Success {
+					t.Errorf("response = %s, want success", rec.Body.String())
+				}
+				return
+			}
+			if resp.Success || resp.Error == nil || resp.Error.ErrorCode != tt.wantErrorCode {
+				t.Errorf("response = %s, want error_code %s", rec.Body.String(), tt.wantErrorCode)
+			}
+			if resp.Error != nil && resp.Error.Code != tt.wantStatus {
+				t.Errorf("error.code = %d, want %d", resp.Error.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// TestBodyLimitMiddlewarePassesWholeBody checks that a buffered body reaches the
+// handler complete.
+func TestBodyLimitMiddlewarePassesWholeBody(t *testing.T) {
+	router := newBodyLimitRouter()
+	body := jsonBody(900)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", chunked{strings.NewReader(body)})
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var resp struct {
+		Data struct {
+			Bytes int `json:"bytes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.Data.Bytes != len(body) {
+		t.Errorf("handler read %d bytes, want %d", resp.Data.Bytes, len(body))
+	}
+}
@@ -49,9 +49,14 @@ func DefaultCORSConfig() CORSConfig {
 			"Content-Type",
 			"Accept",
 			"Authorization",
+			"Accept-Version",
+			RequestIDHeader,
 		},
 		ExposeHeaders: []string{
 			"Content-Length",
+			"Location",
+			"Retry-After",
+			RequestIDHeader,
 		},
 		AllowCredentials: true,
 		MaxAge:           86400, // 24 hours
@@ -76,9 +81,14 @@ func ProductionCORSConfig(allowedOrigins []string) CORSConfig {
 			"Content-Type",
 			"Accept",
 			"Authorization",
+			"Accept-Version",
+			RequestIDHeader,
 		},
 		ExposeHeaders: []string{
 			"Content-Length",
+			"Location",
+			"Retry-After",
+			RequestIDHeader,
 		},
 		AllowCredentials: true,
 		MaxAge:           86400, // 24 hours
@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 
+	"github.com/jaochai/ugc/pkg/logsanitize"
 	"github.com/jaochai/ugc/pkg/response"
 )
 
@@ -27,22 +28,64 @@ func GetRequestID(c *gin.Context) string {
 	return ""
 }
 
-// RequestIDMiddleware generates a UUID for each request and sets it in context and response header
+// RequestIDHeader is the header used to propagate request IDs.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied request IDs.
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware reuses a valid incoming X-Request-ID (or generates a UUID)
+// and sets it in context and response header
 func RequestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Generate a new UUID for the request
-		requestID := uuid.New().String()
+		requestID := c.GetHeader(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
 
 		// Set in context
 		c.Set(ContextKeyRequestID, requestID)
 
 		// Add to response header
-		c.Header("X-Request-ID", requestID)
+		c.Header(RequestIDHeader, requestID)
 
 		c.Next()
 	}
 }
 
+// isValidRequestID reports whether id is safe to log and echo back:
+// non-empty, bounded, and limited to letters, digits, '-', '_' and '.'.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// SanitizedPath returns the request path with secret route parameters, such as
+// webhook and share tokens, replaced by a short hash so they never reach the logs.
+func SanitizedPath(c *gin.Context) string {
+	params := make(map[string]string, len(c.Params))
+	for _, p := range c.Params {
+		params[p.Key] = p.Value
+	}
+	return logsanitize.Path(c.Request.URL.Path, params)
+}
+
+// SanitizedQuery returns the raw query string with sensitive parameters
+// (OAuth code and state, tokens, keys) redacted.
+func SanitizedQuery(c *gin.Context) string {
+	return logsanitize.Query(c.Request.URL.RawQuery)
+}
+
 // responseWriter wraps gin.ResponseWriter to capture response size
 type responseWriter struct {
 	gin.ResponseWriter
@@ -75,8 +118,8 @@ func LoggingMiddleware(logger *zap.Logger) gin.HandlerFunc {
 
 		// Get request info
 		method := c.Request.Method
-		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
+		path := SanitizedPath(c)
+		query := SanitizedQuery(c)
 		clientIP := c.ClientIP()
 		userAgent := c.Request.UserAgent()
 		requestID := GetRequestID(c)
@@ -144,7 +187,7 @@ func RecoveryMiddleware(logger *zap.Logger) gin.HandlerFunc {
 				logger.Error("panic recovered",
 					zap.String("request_id", requestID),
 					zap.String("method", c.Request.Method),
-					zap.String("path", c.Request.URL.Path),
+					zap.String("path", SanitizedPath(c)),
 					zap.String("client_ip", c.ClientIP()),
 					zap.Any("error", err),
 					zap.String("stack_trace", stack),
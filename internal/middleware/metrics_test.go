@@ -0,0 +1,83 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/jaochai/ugc/internal/metrics"
+	"github.com/jaochai/ugc/internal/middleware"
+)
+
+// TestMetricsMiddleware checks that requests are counted and timed by route
+// template rather than by path, with unknown paths grouped as unmatched.
+func TestMetricsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := prometheus.NewRegistry()
+
+	router := gin.New()
+	router.Use(middleware.MetricsMiddleware(metrics.New(reg)))
+	router.GET("/api/v1/jobs/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/api/v1/jobs", func(c *gin.Context) { c.Status(http.StatusBadRequest) })
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/jobs/0b6c3c52-7a4e-4a8e-9a49-1d1c7e6c0b01"},
+		{http.MethodGet, "/api/v1/jobs/5f1d0f6e-2f55-4a36-a8a5-64c1f1b1b2c2"},
+		{http.MethodPost, "/api/v1/jobs"},
+		{http.MethodGet, "/wp-login.php"},
+		{http.MethodGet, "/.env"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	const want = `
+# HELP ugc_http_requests_total HTTP requests by method, route and status code.
+# TYPE ugc_http_requests_total counter
+ugc_http_requests_total{method="GET",route="/api/v1/jobs/:id",status="200"} 2
+ugc_http_requests_total{method="GET",route="unmatched",status="404"} 2
+ugc_http_requests_total{method="POST",route="/api/v1/jobs",status="400"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "ugc_http_requests_total"); err != nil {
+		t.Error(err)
+	}
+
+	// One latency series per method and route, each with a sample per request
+	if n, err := testutil.GatherAndCount(reg, "ugc_http_request_duration_seconds"); err != nil || n != 3 {
+		t.Errorf("latency series = %d (%v), want 3", n, err)
+	}
+	wantSamples := map[string]uint64{"/api/v1/jobs/:id": 2, "/api/v1/jobs": 1, "unmatched": 2}
+	for route, count := range latencySamples(t, reg) {
+		if count != wantSamples[route] {
+			t.Errorf("latency samples of %s = %d, want %d", route, count, wantSamples[route])
+		}
+	}
+}
+
+// latencySamples returns the number of observations of each route's request latency.
+func latencySamples(t *testing.T, reg *prometheus.Registry) map[string]uint64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	samples := make(map[string]uint64)
+	for _, family := range families {
+		if family.GetName() != "ugc_http_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "route" {
+					samples[label.GetValue()] = metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	return samples
+}
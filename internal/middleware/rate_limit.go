@@ -15,15 +15,35 @@ import (
 // RateLimitConfig holds configuration for rate limiting middleware.
 type RateLimitConfig struct {
 	RedisClient *redis.Client
-	RPS         int    // Requests per second
-	Burst       int    // Burst size (max requests in window)
+	RPS         int    // Sustained requests per second; 0 refills Burst requests per Window
+	Burst       int    // Burst size (requests allowed at once)
 	KeyPrefix   string // Redis key prefix
 	Logger      *zap.Logger
+
+	// Optional settings; the zero values keep the original per-IP webhook limiter.
+	Scope   string                    // Key segment naming the limited endpoint (default "webhook")
+	Window  time.Duration             // Refill period of Burst requests when RPS is 0 (default 1s)
+	KeyFunc func(*gin.Context) string // Identifies the caller (default client IP)
 }
 
-// RateLimitMiddleware implements sliding window rate limiting using Redis.
-// It limits requests per IP address.
+// RateLimitMiddleware implements token bucket rate limiting using Redis: each
+// caller may send Burst requests at once, refilled at RPS per second (or Burst
+// per Window). It limits requests per IP address unless KeyFunc is set.
 func RateLimitMiddleware(cfg RateLimitConfig) gin.HandlerFunc {
+	if cfg.Scope == "" {
+		cfg.Scope = "webhook"
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Second
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = func(c *gin.Context) string { return c.ClientIP() }
+	}
+	refillPerSecond := float64(cfg.RPS)
+	if refillPerSecond <= 0 {
+		refillPerSecond = float64(cfg.Burst) / cfg.Window.Seconds()
+	}
+
 	return func(c *gin.Context) {
 		// Skip if Redis client is not configured
 		if cfg.RedisClient == nil {
@@ -32,14 +52,13 @@ func RateLimitMiddleware(cfg RateLimitConfig) gin.HandlerFunc {
 			return
 		}
 
-		// Use IP address as rate limit key
-		key := fmt.Sprintf("%s:webhook:ratelimit:%s", cfg.KeyPrefix, c.ClientIP())
+		key := fmt.Sprintf("%s:%s:tokenbucket:%s", cfg.KeyPrefix, cfg.Scope, cfg.KeyFunc(c))
 
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 100*time.Millisecond)
 		defer cancel()
 
 		// Check rate limit
-		allowed, err := checkRateLimit(ctx, cfg.RedisClient, key, cfg.Burst)
+		allowed, err := checkRateLimit(ctx, cfg.RedisClient, key, cfg.Burst, refillPerSecond)
 		if err != nil {
 			// Fail open for availability - log error but allow request
 			cfg.Logger.Error("rate limit check failed",
@@ -53,7 +72,7 @@ func RateLimitMiddleware(cfg RateLimitConfig) gin.HandlerFunc {
 		if !allowed {
 			cfg.Logger.Warn("rate limit exceeded",
 				zap.String("ip", c.ClientIP()),
-				zap.String("path", c.Request.URL.Path),
+				zap.String("path", SanitizedPath(c)),
 			)
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
 				"message": "rate limit exceeded",
@@ -65,31 +84,39 @@ func RateLimitMiddleware(cfg RateLimitConfig) gin.HandlerFunc {
 	}
 }
 
-// checkRateLimit uses Redis sorted set for sliding window rate limiting.
-// Returns true if request is allowed, false if rate limit exceeded.
-func checkRateLimit(ctx context.Context, client *redis.Client, key string, burst int) (bool, error) {
-	now := time.Now().UnixMilli()
-	windowMs := int64(1000) // 1 second window
-
-	pipe := client.Pipeline()
-
-	// Remove old entries outside the window
-	pipe.ZRemRangeByScore(ctx, key, "0", fmt.Sprintf("%d", now-windowMs))
-
-	// Count current entries in the window
-	countCmd := pipe.ZCard(ctx, key)
-
-	// Add current request with timestamp as score and member
-	pipe.ZAdd(ctx, key, redis.Z{Score: float64(now), Member: fmt.Sprintf("%d-%d", now, time.Now().UnixNano())})
-
-	// Set expiry on the key (2x window to ensure cleanup)
-	pipe.Expire(ctx, key, time.Duration(windowMs)*time.Millisecond*2)
-
-	_, err := pipe.Exec(ctx)
+// tokenBucketScript takes a token from the bucket at KEYS[1], holding at most
+// ARGV[1] tokens and refilled at ARGV[2] tokens per second, and returns 1 when
+// one was left. It reads the clock of the Redis server, so API instances with
+// skewed clocks share one bucket.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate * 1000) + 1000)
+return allowed
+`)
+
+// checkRateLimit takes a token from the caller's bucket of burst tokens, refilled
+// at refillPerSecond. Returns true if the request is allowed, false if the
+// bucket is empty.
+func checkRateLimit(ctx context.Context, client *redis.Client, key string, burst int, refillPerSecond float64) (bool, error) {
+	allowed, err := tokenBucketScript.Run(ctx, client, []string{key}, burst, refillPerSecond).Int()
 	if err != nil {
-		return false, fmt.Errorf("redis pipeline exec failed: %w", err)
+		return false, fmt.Errorf("redis token bucket failed: %w", err)
 	}
-
-	count := countCmd.Val()
-	return count < int64(burst), nil
+	return allowed == 1, nil
 }
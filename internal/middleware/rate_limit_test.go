@@ -0,0 +1,79 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/middleware"
+)
+
+// newRateLimitRouter serves /ping behind a limiter backed by miniredis.
+func newRateLimitRouter(t *testing.T, rps, burst int) (*gin.Engine, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RateLimitMiddleware(middleware.RateLimitConfig{
+		RedisClient: client,
+		RPS:         rps,
+		Burst:       burst,
+		KeyPrefix:   "test",
+		Logger:      zap.NewNop(),
+	}))
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router, mr
+}
+
+// allowedRequests sends n requests and counts the ones that were not limited.
+func allowedRequests(router *gin.Engine, n int) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ping", nil))
+		if rec.Code == http.StatusOK {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestRateLimitMiddleware_TokenBucket(t *testing.T) {
+	router, mr := newRateLimitRouter(t, 2, 5)
+
+	if got := allowedRequests(router, 8); got != 5 {
+		t.Fatalf("allowed %d requests from a full bucket, want the burst of 5", got)
+	}
+
+	// Refill happens at RPS, not at Burst per second
+	mr.SetTime(time.Date(2026, 1, 1, 0, 0, 1, 0, time.UTC))
+	if got := allowedRequests(router, 5); got != 2 {
+		t.Fatalf("allowed %d requests after 1s, want RPS 2", got)
+	}
+
+	// The bucket never holds more than Burst tokens
+	mr.SetTime(time.Date(2026, 1, 1, 0, 1, 0, 0, time.UTC))
+	if got := allowedRequests(router, 8); got != 5 {
+		t.Fatalf("allowed %d requests after a long pause, want the burst of 5", got)
+	}
+}
+
+func TestRateLimitMiddleware_FailsOpenWithoutRedis(t *testing.T) {
+	router, mr := newRateLimitRouter(t, 1, 1)
+	mr.Close()
+
+	if got := allowedRequests(router, 3); got != 3 {
+		t.Fatalf("allowed %d requests with Redis down, want all 3", got)
+	}
+}
@@ -4,21 +4,145 @@ package middleware
 import (
 	"crypto/subtle"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/security"
+	"github.com/jaochai/ugc/pkg/logsanitize"
+)
+
+// Webhook secret versions reported by WebhookSecretUsage.
+const (
+	WebhookSecretPrimary  = "primary"
+	WebhookSecretPrevious = "previous"
 )
 
 // WebhookAuthConfig holds configuration for webhook authentication middleware.
 type WebhookAuthConfig struct {
-	Secret      string
-	Environment string // "development", "staging", "production"
-	Logger      *zap.Logger
+	Secret string
+	// PreviousSecret is still accepted after rotating Secret, so callbacks of tasks
+	// submitted with the old callback URL keep working. Empty accepts Secret only.
+	PreviousSecret string
+	Environment    string // "development", "staging", "production"
+	Logger         *zap.Logger
+	// Usage records which secret authenticated each callback; nil skips recording.
+	Usage *WebhookSecretUsage
+	// LegacyTokensUntil is when callback URLs carrying the raw secret instead of
+	// the per-job token (see security.WebhookToken) stop being accepted. Zero
+	// rejects them.
+	LegacyTokensUntil time.Time
+}
+
+// WebhookSecretStats is how often one secret version authenticated a callback.
+type WebhookSecretStats struct {
+	Count    int64      `json:"count"`
+	LastUsed *time.Time `json:"last_used,omitempty"`
+}
+
+// WebhookSecretReport is the secret usage of this process since it started.
+// Legacy counts the callbacks among them whose URL carried the raw secret rather
+// than a per-job token.
+type WebhookSecretReport struct {
+	PreviousConfigured bool               `json:"previous_configured"`
+	Since              time.Time          `json:"since"`
+	Primary            WebhookSecretStats `json:"primary"`
+	Previous           WebhookSecretStats `json:"previous"`
+	Legacy             WebhookSecretStats `json:"legacy"`
+}
+
+// WebhookSecretUsage counts the callbacks authenticated with each secret version,
+// showing when WEBHOOK_SECRET_PREVIOUS is no longer used and can be removed.
+// Counts are per process and reset on restart.
+type WebhookSecretUsage struct {
+	mu     sync.Mutex
+	report WebhookSecretReport
+}
+
+// NewWebhookSecretUsage creates an empty usage tracker.
+func NewWebhookSecretUsage(previousConfigured bool) *WebhookSecretUsage {
+	return &WebhookSecretUsage{report: WebhookSecretReport{
+		PreviousConfigured: previousConfigured,
+		Since:              time.Now().UTC(),
+	}}
+}
+
+// record counts one callback authenticated with version.
+func (u *WebhookSecretUsage) record(version string, legacy bool) {
+	now := time.Now().UTC()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	stats := &u.report.Primary
+	if version == WebhookSecretPrevious {
+		stats = &u.report.Previous
+	}
+	stats.Count++
+	stats.LastUsed = &now
+	if legacy {
+		u.report.Legacy.Count++
+		u.report.Legacy.LastUsed = &now
+	}
+}
+
+// Report returns a snapshot of the usage counts.
+func (u *WebhookSecretUsage) Report() WebhookSecretReport {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.report
+}
+
+// matchWebhookToken returns the version of the secret token was made with, or ""
+// when it matches none. token is either the per-job token of jobID or, while
+// allowLegacy, the raw secret. Every candidate is compared in constant time, so
+// the response time does not reveal which one matched.
+func matchWebhookToken(token, jobID, secret, previous string, allowLegacy bool) (version string, legacy bool) {
+	match := func(candidate string) bool {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1
+	}
+
+	primaryMatch := jobID != "" && match(security.WebhookToken(secret, jobID))
+	primaryLegacy := match(secret) && allowLegacy
+	previousMatch, previousLegacy := false, false
+	if previous != "" {
+		previousMatch = jobID != "" && match(security.WebhookToken(previous, jobID))
+		previousLegacy = match(previous) && allowLegacy
+	}
+
+	switch {
+	case primaryMatch:
+		return WebhookSecretPrimary, false
+	case previousMatch:
+		return WebhookSecretPrevious, false
+	case primaryLegacy:
+		return WebhookSecretPrimary, true
+	case previousLegacy:
+		return WebhookSecretPrevious, true
+	default:
+		return "", false
+	}
+}
+
+// webhookNotFoundBody is gin's response body for a request matching no route.
+const webhookNotFoundBody = "404 page not found"
+
+// abortWebhookNotFound rejects a webhook request with the same response gin sends
+// for an unknown route, so a wrong token cannot be told apart from a wrong URL.
+func abortWebhookNotFound(c *gin.Context) {
+	c.Data(http.StatusNotFound, gin.MIMEPlain, []byte(webhookNotFoundBody))
+	c.Abort()
 }
 
 // WebhookAuthMiddleware validates webhook requests using token-based authentication.
 // The token can be provided in the URL path parameter (:token) or in the X-Webhook-Token header.
-// Since KIE API doesn't support HMAC signatures, we use a shared secret token.
+// Since KIE API doesn't support HMAC signatures, the token is an HMAC of the
+// job ID (security.WebhookToken), so a leaked URL only covers one job. During a
+// rotation tokens of both the current and the previous secret are accepted.
+//
+// Missing, oversized, malformed and wrong tokens all get gin's unknown-route 404,
+// and only a hash prefix of a token is ever logged.
 func WebhookAuthMiddleware(cfg WebhookAuthConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// If no secret is configured, behavior depends on environment
@@ -45,22 +169,61 @@ func WebhookAuthMiddleware(cfg WebhookAuthConfig) gin.HandlerFunc {
 		if token == "" {
 			cfg.Logger.Warn("webhook request without token",
 				zap.String("ip", c.ClientIP()),
-				zap.String("path", c.Request.URL.Path),
+				zap.String("path", SanitizedPath(c)),
+			)
+			abortWebhookNotFound(c)
+			return
+		}
+
+		// Nothing longer than any valid token is hashed or compared
+		if len(token) > security.MaxWebhookTokenLength {
+			cfg.Logger.Warn("webhook request with oversized token",
+				zap.String("ip", c.ClientIP()),
+				zap.String("path", SanitizedPath(c)),
+				zap.Int("token_length", len(token)),
 			)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
+			abortWebhookNotFound(c)
+			return
+		}
+
+		// Outside the legacy grace period only per-job tokens can match
+		allowLegacy := time.Now().Before(cfg.LegacyTokensUntil)
+		if !allowLegacy && !security.IsWebhookTokenFormat(token) {
+			cfg.Logger.Warn("webhook request with malformed token",
+				zap.String("ip", c.ClientIP()),
+				zap.String("path", SanitizedPath(c)),
+				zap.String("token_hash", logsanitize.Token(token)),
+			)
+			abortWebhookNotFound(c)
 			return
 		}
 
 		// Constant-time comparison to prevent timing attacks
-		if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Secret)) != 1 {
+		version, legacy := matchWebhookToken(token, c.Param("job_id"), cfg.Secret, cfg.PreviousSecret, allowLegacy)
+		if version == "" {
 			cfg.Logger.Warn("webhook request with invalid token",
 				zap.String("ip", c.ClientIP()),
-				zap.String("path", c.Request.URL.Path),
+				zap.String("path", SanitizedPath(c)),
+				zap.String("token_hash", logsanitize.Token(token)),
 			)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
+			abortWebhookNotFound(c)
 			return
 		}
 
+		if version == WebhookSecretPrevious {
+			cfg.Logger.Info("webhook request authenticated with the previous secret",
+				zap.String("path", SanitizedPath(c)),
+			)
+		}
+		if legacy {
+			cfg.Logger.Info("webhook request authenticated with a legacy raw-secret URL",
+				zap.String("path", SanitizedPath(c)),
+			)
+		}
+		if cfg.Usage != nil {
+			cfg.Usage.record(version, legacy)
+		}
+
 		c.Next()
 	}
 }
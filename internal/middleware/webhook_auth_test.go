@@ -0,0 +1,155 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/middleware"
+	"github.com/jaochai/ugc/internal/security"
+)
+
+const (
+	testWebhookSecret   = "current-webhook-secret-0123456789abcdef"
+	testPreviousSecret  = "previous-webhook-secret-0123456789abcdef"
+	testWebhookJobID    = "5f0c6a2e-8a7d-4f39-9d55-2b8f4f1f6a10"
+	testOtherWebhookJob = "0d9b1a44-3c61-4e0e-a2f7-7b3e5c9d2e81"
+)
+
+// newWebhookRouter registers the callback routes of WebhookHandler behind the
+// middleware configured by cfg.
+func newWebhookRouter(cfg middleware.WebhookAuthConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	cfg.Logger = zap.NewNop()
+
+	router := gin.New()
+	authenticated := router.Group("/api/v1/webhooks/:token", middleware.WebhookAuthMiddleware(cfg))
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"received": true}) }
+	authenticated.POST("/suno/:job_id", ok)
+	authenticated.POST("/nano/:job_id", ok)
+	return router
+}
+
+// postCallback sends a callback for jobID with token.
+func postCallback(router *gin.Engine, token, jobID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/"+token+"/suno/"+jobID, strings.NewReader(`{"code": 200}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestWebhookAuthRejectionsLookLikeUnknownRoutes checks that every rejected token
+// gets exactly the response gin sends for a URL matching no route.
+func TestWebhookAuthRejectionsLookLikeUnknownRoutes(t *testing.T) {
+	router := newWebhookRouter(middleware.WebhookAuthConfig{Secret: testWebhookSecret})
+
+	unknown := httptest.NewRecorder()
+	router.ServeHTTP(unknown, httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/no/such/route/here", nil))
+	if unknown.Code != http.StatusNotFound {
+		t.Fatalf("unknown route status = %d, want 404", unknown.Code)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		jobID string
+	}{
+		{name: "wrong token", token: strings.Repeat("0", 64), jobID: testWebhookJobID},
+		{name: "token of another job", token: security.WebhookToken(testWebhookSecret, testOtherWebhookJob), jobID: testWebhookJobID},
+		{name: "uppercase token", token: strings.ToUpper(security.WebhookToken(testWebhookSecret, testWebhookJobID)), jobID: testWebhookJobID},
+		{name: "overlong token", token: strings.Repeat("a", security.MaxWebhookTokenLength+1), jobID: testWebhookJobID},
+		{name: "raw secret without a grace period", token: testWebhookSecret, jobID: testWebhookJobID},
+		{name: "token of an unset previous secret", token: security.WebhookToken(testPreviousSecret, testWebhookJobID), jobID: testWebhookJobID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postCallback(router, tt.token, tt.jobID)
+			if rec.Code != unknown.Code || rec.Body.String() != unknown.Body.String() ||
+				rec.Header().Get("Content-Type") != unknown.Header().Get("Content-Type") {
+				t.Errorf("response = %d %q (%s), want the unknown-route response %d %q (%s)",
+					rec.Code, rec.Body.String(), rec.Header().Get("Content-Type"),
+					unknown.Code, unknown.Body.String(), unknown.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestWebhookAuthAcceptsJobToken(t *testing.T) {
+	usage := middleware.NewWebhookSecretUsage(false)
+	router := newWebhookRouter(middleware.WebhookAuthConfig{Secret: testWebhookSecret, Usage: usage})
+
+	rec := postCallback(router, security.WebhookToken(testWebhookSecret, testWebhookJobID), testWebhookJobID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body: %s", rec.Code, rec.Body.String())
+	}
+	report := usage.Report()
+	if report.Primary.Count != 1 || report.Previous.Count != 0 || report.Legacy.Count != 0 {
+		t.Errorf("usage = %+v, want one primary callback", report)
+	}
+}
+
+func TestWebhookAuthPreviousSecretGrace(t *testing.T) {
+	usage := middleware.NewWebhookSecretUsage(true)
+	router := newWebhookRouter(middleware.WebhookAuthConfig{
+		Secret:         testWebhookSecret,
+		PreviousSecret: testPreviousSecret,
+		Usage:          usage,
+	})
+
+	rec := postCallback(router, security.WebhookToken(testPreviousSecret, testWebhookJobID), testWebhookJobID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("previous-secret token status = %d, want 200", rec.Code)
+	}
+	// The previous secret's token is still bound to its job
+	rec = postCallback(router, security.WebhookToken(testPreviousSecret, testOtherWebhookJob), testWebhookJobID)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("previous-secret token of another job status = %d, want 404", rec.Code)
+	}
+
+	report := usage.Report()
+	if report.Previous.Count != 1 || report.Primary.Count != 0 {
+		t.Errorf("usage = %+v, want one previous-secret callback", report)
+	}
+}
+
+func TestWebhookAuthLegacyGrace(t *testing.T) {
+	tests := []struct {
+		name       string
+		until      time.Time
+		token      string
+		wantStatus int
+		wantLegacy int64
+	}{
+		{name: "raw secret during the grace period", until: time.Now().Add(time.Hour), token: testWebhookSecret, wantStatus: http.StatusOK, wantLegacy: 1},
+		{name: "raw previous secret during the grace period", until: time.Now().Add(time.Hour), token: testPreviousSecret, wantStatus: http.StatusOK, wantLegacy: 1},
+		{name: "raw secret after the grace period", until: time.Now().Add(-time.Hour), token: testWebhookSecret, wantStatus: http.StatusNotFound},
+		{name: "wrong raw token during the grace period", until: time.Now().Add(time.Hour), token: "not-the-secret", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage := middleware.NewWebhookSecretUsage(true)
+			router := newWebhookRouter(middleware.WebhookAuthConfig{
+				Secret:            testWebhookSecret,
+				PreviousSecret:    testPreviousSecret,
+				Usage:             usage,
+				LegacyTokensUntil: tt.until,
+			})
+
+			rec := postCallback(router, tt.token, testWebhookJobID)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := usage.Report().Legacy.Count; got != tt.wantLegacy {
+				t.Errorf("legacy callbacks = %d, want %d", got, tt.wantLegacy)
+			}
+		})
+	}
+}
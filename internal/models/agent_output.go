@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// Size caps of the text stored for each agent output, in bytes.
+const (
+	MaxAgentReasoningLength = 2000
+	MaxAgentSummaryLength   = 500
+	MaxAgentRawOutputLength = 4000
+)
+
+// AgentOutput is what an agent produced for a job, kept so its decisions can be
+// explained later (e.g. why a song was picked).
+type AgentOutput struct {
+	Model     string            `json:"model"`
+	Params    *GenerationParams `json:"params,omitempty"`    // Effective temperature and max_tokens, for reproducibility
+	Reasoning string            `json:"reasoning,omitempty"` // Selector agents' explanation of their pick
+	Summary   string            `json:"summary,omitempty"`   // Short description of the output
+	// RawOutput is the agent's JSON output. It can hold generated prompts, so it is never returned by the API.
+	RawOutput string    `json:"raw_output,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AgentOutputResponse is the API view of an AgentOutput, without the raw output.
+type AgentOutputResponse struct {
+	Model     string            `json:"model"`
+	Params    *GenerationParams `json:"params,omitempty"`
+	Reasoning string            `json:"reasoning,omitempty"`
+	Summary   string            `json:"summary,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Truncate caps the output's text fields to their maximum lengths.
+func (o *AgentOutput) Truncate() {
+	o.Reasoning = truncateUTF8(o.Reasoning, MaxAgentReasoningLength)
+	o.Summary = truncateUTF8(o.Summary, MaxAgentSummaryLength)
+	o.RawOutput = truncateUTF8(o.RawOutput, MaxAgentRawOutputLength)
+}
+
+// truncateUTF8 cuts s to at most limit bytes without splitting a character.
+func truncateUTF8(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	s = s[:limit]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// AgentOutputResponses returns the redacted agent outputs of the job, keyed by agent type.
+func (j *Job) AgentOutputResponses() map[string]AgentOutputResponse {
+	if len(j.AgentOutputs) == 0 {
+		return nil
+	}
+	outputs := make(map[string]AgentOutputResponse, len(j.AgentOutputs))
+	for agent, output := range j.AgentOutputs {
+		outputs[agent] = AgentOutputResponse{
+			Model:     output.Model,
+			Params:    output.Params,
+			Reasoning: output.Reasoning,
+			Summary:   output.Summary,
+			CreatedAt: output.CreatedAt,
+		}
+	}
+	return outputs
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit log actions.
+const (
+	AuditActionLoginSuccess          = "login.success"
+	AuditActionLoginFailure          = "login.failure"
+	AuditActionAPIKeysUpdate         = "api_keys.update"
+	AuditActionAPIKeysDelete         = "api_keys.delete"
+	AuditActionProfileUpdate         = "profile.update"
+	AuditActionPromptUpdate          = "prompt.update"
+	AuditActionPromptReset           = "prompt.reset"
+	AuditActionSystemPromptUpdate    = "system_prompt.update"
+	AuditActionSystemPromptRollback  = "system_prompt.rollback"
+	AuditActionYouTubeConnect        = "youtube.connect"
+	AuditActionYouTubeDisconnect     = "youtube.disconnect"
+	AuditActionOrganizationKeyUpdate = "organization.api_keys.update"
+	AuditActionInvitationAccept      = "organization.invitation.accept"
+	AuditActionInvitationRevoke      = "organization.invitation.revoke"
+	AuditActionSettingsUpdate        = "settings.update"
+)
+
+// AuditLog records a security-relevant action. Metadata never holds secrets: keys
+// are recorded as flags such as "openrouter_key_set": true.
+type AuditLog struct {
+	ID        uuid.UUID              `json:"id"`
+	UserID    *uuid.UUID             `json:"user_id,omitempty"` // nil for failed logins of unknown addresses
+	Action    string                 `json:"action"`
+	Target    *string                `json:"target,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata"`
+	IP        *string                `json:"ip,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditLogFilter narrows an audit log listing; zero values mean "no filter".
+type AuditLogFilter struct {
+	UserID        *uuid.UUID
+	Action        string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
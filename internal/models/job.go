@@ -2,25 +2,123 @@
 package models
 
 import (
+	"context"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/lyrics"
 )
 
 // JobStatus constants represent the possible states of a job.
 const (
-	StatusPending         = "pending"
-	StatusAnalyzing       = "analyzing"
-	StatusGeneratingMusic = "generating_music"
-	StatusSelectingSong   = "selecting_song"
-	StatusGeneratingImage = "generating_image"
-	StatusProcessingVideo = "processing_video"
+	StatusPending          = "pending"
+	StatusAnalyzing        = "analyzing"
+	StatusGeneratingMusic  = "generating_music"
+	StatusSelectingSong    = "selecting_song"
+	StatusGeneratingImage  = "generating_image"
+	StatusProcessingVideo  = "processing_video"
 	StatusUploading        = "uploading"
 	StatusUploadingYouTube = "uploading_youtube"
 	StatusCompleted        = "completed"
 	StatusFailed           = "failed"
 )
 
+// AllStatuses lists every valid job status in pipeline order.
+var AllStatuses = []string{
+	StatusPending,
+	StatusAnalyzing,
+	StatusGeneratingMusic,
+	StatusSelectingSong,
+	StatusGeneratingImage,
+	StatusProcessingVideo,
+	StatusUploading,
+	StatusUploadingYouTube,
+	StatusCompleted,
+	StatusFailed,
+}
+
+// IsValidStatus returns true if status is a known job status.
+func IsValidStatus(status string) bool {
+	for _, s := range AllStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageSourceUser marks a job whose image was supplied by the user; the pipeline
+// skips image generation. A nil image source means the image is generated.
+const ImageSourceUser = "user"
+
+// ImageSourceSuno marks a job that uses the selected Suno track's cover art as its
+// image. When the cover is missing or cannot be copied, the image stage clears the
+// source and generates the image instead.
+const ImageSourceSuno = "suno"
+
+// RequestableImageSources lists the image_source values accepted at job creation;
+// ImageSourceUser is set by giving image_url or uploading an image instead.
+var RequestableImageSources = []string{ImageSourceSuno}
+
+// IsRequestableImageSource reports whether source is one of RequestableImageSources.
+func IsRequestableImageSource(source string) bool {
+	for _, s := range RequestableImageSources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// Provider key sources recorded on a job: the user's own key, their organization's
+// shared key, or the platform's key when ALLOW_PLATFORM_OPENROUTER_KEY /
+// ALLOW_PLATFORM_KIE_KEY is on and neither is set.
+const (
+	KeySourceUser         = "user"
+	KeySourceOrganization = "organization"
+	KeySourcePlatform     = "platform"
+)
+
+// ImageUploadStatuses lists the statuses in which the user can still supply the
+// job's image, i.e. before image generation starts.
+var ImageUploadStatuses = []string{
+	StatusPending,
+	StatusAnalyzing,
+	StatusGeneratingMusic,
+	StatusSelectingSong,
+}
+
+// Job list sort fields and orders.
+const (
+	JobSortCreatedAt = "created_at"
+	JobSortUpdatedAt = "updated_at"
+	SortAsc          = "asc"
+	SortDesc         = "desc"
+)
+
+// JobScopeOrg lists the jobs of the user's whole organization (GET /jobs?scope=org).
+const JobScopeOrg = "org"
+
+// JobFilter narrows and orders a job listing.
+// Zero values mean "no filter" and default ordering (created_at desc).
+type JobFilter struct {
+	Statuses      []string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Query         string   // full-text (or, for short and Thai queries, substring) match on concept
+	Tags          []string // jobs must carry every tag
+	SortBy        string   // created_at or updated_at
+	SortOrder     string   // asc or desc
+	// Scope is empty for the user's own jobs or JobScopeOrg; the service resolves
+	// JobScopeOrg to OrgID.
+	Scope string
+	// OrgID lists the jobs of every member of the organization instead of the user's own.
+	OrgID *uuid.UUID
+	// IncludeDeleted also lists soft-deleted jobs still within the restore window.
+	IncludeDeleted bool
+}
+
 // SongPrompt represents the output from Agent 1 (music prompt generation).
 type SongPrompt struct {
 	Prompt       string `json:"prompt"`
@@ -37,97 +135,701 @@ type GeneratedSong struct {
 	AudioURL string  `json:"audio_url"`
 	Title    string  `json:"title"`
 	Duration float64 `json:"duration"`
+	// ImageURL is the track's Suno cover art; empty when Suno returned none.
+	ImageURL string `json:"image_url,omitempty"`
+}
+
+// MinSongDurationSeconds is the shortest track kept as a song candidate. Suno
+// occasionally returns silent or empty tracks with a zero duration.
+const MinSongDurationSeconds = 10
+
+// IsPlayable reports whether the song has audio and lasts at least MinSongDurationSeconds.
+func (s GeneratedSong) IsPlayable() bool {
+	return s.AudioURL != "" && s.Duration >= MinSongDurationSeconds
+}
+
+// JobTrack is a Suno track of a job created with keep_all_tracks, copied to R2
+// when the job is uploaded.
+type JobTrack struct {
+	ID       string  `json:"id"`
+	Title    string  `json:"title"`
+	Duration float64 `json:"duration"`
+	// Key is the track's R2 object key; empty when copying it failed.
+	Key string `json:"key,omitempty"`
+	// Primary marks the track the video was made with; Reasoning is why the song
+	// selector picked it.
+	Primary   bool   `json:"primary"`
+	Reasoning string `json:"reasoning,omitempty"`
+}
+
+// Image candidate status constants track each NanoBanana task of a job.
+const (
+	ImageCandidatePending = "pending"
+	ImageCandidateSuccess = "success"
+	ImageCandidateFailed  = "fail"
+)
+
+// Image candidate bounds for the number of NanoBanana tasks per job.
+const (
+	MinImageCandidates = 1
+	MaxImageCandidates = 3
+)
+
+// GeneratedImage represents an image candidate generated by the image service (NanoBanana).
+type GeneratedImage struct {
+	TaskID   string `json:"task_id"`
+	ImageURL string `json:"image_url,omitempty"`
+	Status   string `json:"status"`
 }
 
 // ImagePrompt represents the prompt for image generation.
 type ImagePrompt struct {
-	Prompt    string `json:"prompt"`
-	ImageSize string `json:"image_size"`
+	Prompt     string `json:"prompt"`
+	ImageSize  string `json:"image_size"`
+	Resolution string `json:"resolution,omitempty"`
 }
 
 // Job represents a UGC content generation job.
 type Job struct {
-	ID             uuid.UUID       `json:"id" db:"id"`
-	UserID         uuid.UUID       `json:"user_id" db:"user_id"`
-	Status         string          `json:"status" db:"status"`
-	Concept        string          `json:"concept" db:"concept"`
-	LLMModel       string          `json:"llm_model" db:"llm_model"`
-	SongPrompt     *SongPrompt     `json:"song_prompt,omitempty" db:"song_prompt"`
-	SunoTaskID     *string         `json:"suno_task_id,omitempty" db:"suno_task_id"`
-	GeneratedSongs []GeneratedSong `json:"generated_songs,omitempty" db:"generated_songs"`
-	SelectedSongID *string         `json:"selected_song_id,omitempty" db:"selected_song_id"`
-	ImagePrompt    *ImagePrompt    `json:"image_prompt,omitempty" db:"image_prompt"`
-	NanoTaskID     *string         `json:"nano_task_id,omitempty" db:"nano_task_id"`
-	AudioURL       *string         `json:"audio_url,omitempty" db:"audio_url"`
-	ImageURL       *string         `json:"image_url,omitempty" db:"image_url"`
-	VideoURL       *string         `json:"video_url,omitempty" db:"video_url"`
-	YouTubeURL     *string         `json:"youtube_url,omitempty" db:"youtube_url"`
-	YouTubeVideoID *string         `json:"youtube_video_id,omitempty" db:"youtube_video_id"`
-	YouTubeError   *string         `json:"youtube_error,omitempty" db:"youtube_error"`
-	ErrorMessage   *string         `json:"error_message,omitempty" db:"error_message"`
-	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+	ID              uuid.UUID        `json:"id" db:"id"`
+	UserID          uuid.UUID        `json:"user_id" db:"user_id"`
+	Status          string           `json:"status" db:"status"`
+	Concept         string           `json:"concept" db:"concept"`
+	LLMModel        string           `json:"llm_model" db:"llm_model"`
+	SongPrompt      *SongPrompt      `json:"song_prompt,omitempty" db:"song_prompt"`
+	SunoTaskID      *string          `json:"suno_task_id,omitempty" db:"suno_task_id"`
+	GeneratedSongs  []GeneratedSong  `json:"generated_songs,omitempty" db:"generated_songs"`
+	SelectedSongID  *string          `json:"selected_song_id,omitempty" db:"selected_song_id"`
+	ImagePrompt     *ImagePrompt     `json:"image_prompt,omitempty" db:"image_prompt"`
+	ImageCandidates *int             `json:"image_candidates,omitempty" db:"image_candidates"`
+	AspectRatio     *string          `json:"aspect_ratio,omitempty" db:"aspect_ratio"`
+	GeneratedImages []GeneratedImage `json:"generated_images,omitempty" db:"generated_images"`
+	NanoTaskID      *string          `json:"nano_task_id,omitempty" db:"nano_task_id"`
+	AudioURL        *string          `json:"audio_url,omitempty" db:"audio_url"`
+	ImageURL        *string          `json:"image_url,omitempty" db:"image_url"`
+	VideoURL        *string          `json:"video_url,omitempty" db:"video_url"`
+	VideoKey        *string          `json:"video_key,omitempty" db:"video_key"`
+	AudioKey        *string          `json:"audio_key,omitempty" db:"audio_key"`
+	ImageKey        *string          `json:"image_key,omitempty" db:"image_key"`
+	YouTubeURL      *string          `json:"youtube_url,omitempty" db:"youtube_url"`
+	YouTubeVideoID  *string          `json:"youtube_video_id,omitempty" db:"youtube_video_id"`
+	YouTubeError    *string          `json:"youtube_error,omitempty" db:"youtube_error"`
+	ErrorMessage    *string          `json:"error_message,omitempty" db:"error_message"`
+	CancelledAt     *time.Time       `json:"cancelled_at,omitempty" db:"cancelled_at"`
+	CreatedAt       time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at" db:"updated_at"`
+	Version         int              `json:"version" db:"version"` // bumped on every write; guards Update
+	// AgentModels maps each agent (prompt type) that has run to the LLM model it used.
+	AgentModels map[string]string `json:"agent_models,omitempty" db:"agent_models"`
+	// PromptOverrides holds agent prompts copied from a template; they win over the user's custom prompts.
+	PromptOverrides *AgentPrompts `json:"-" db:"prompt_overrides"`
+	// ShareToken is the random token of the job's public share link; nil when not shared.
+	ShareToken *string    `json:"-" db:"share_token"`
+	SharedAt   *time.Time `json:"-" db:"shared_at"`
+	// ImageSource is ImageSourceUser when the user supplied the image, ImageSourceSuno
+	// when the selected track's cover art is used; nil when generated.
+	ImageSource *string `json:"image_source,omitempty" db:"image_source"`
+	// SourceImageURL is the image URL given at creation; it is copied into R2 when the image stage runs.
+	SourceImageURL *string `json:"source_image_url,omitempty" db:"source_image_url"`
+	// VideoOptions holds the encoding preset and audio settings for the video encode; nil uses the defaults.
+	VideoOptions *VideoOptions `json:"video_options,omitempty" db:"video_options"`
+	// VideoMetadata describes the rendered video; nil until the video is rendered.
+	VideoMetadata *VideoMetadata `json:"video_metadata,omitempty" db:"video_metadata"`
+	// Tags are free-form, normalized lowercase labels for finding the job later.
+	Tags []string `json:"tags" db:"tags"`
+	// DeletedAt is set when the user deletes the job; it can be restored until
+	// JobRestoreWindow has passed, after which it is purged with its assets.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// OrgID is the organization the creator belonged to when the job was created.
+	// Its members can view the job and its owners can modify it.
+	OrgID *uuid.UUID `json:"org_id,omitempty" db:"org_id"`
+	// ThumbnailKey is the R2 key of the video's JPEG thumbnail; nil until the video is uploaded.
+	ThumbnailKey *string `json:"thumbnail_key,omitempty" db:"thumbnail_key"`
+	// OpenRouterKeySource is KeySourceUser, KeySourceOrganization or KeySourcePlatform.
+	OpenRouterKeySource string `json:"openrouter_key_source" db:"openrouter_key_source"`
+	// KIEKeySource is KeySourceUser, KeySourceOrganization or KeySourcePlatform.
+	KIEKeySource string `json:"kie_key_source" db:"kie_key_source"`
+	// AgentOutputs maps each agent (prompt type) that has run to what it produced.
+	AgentOutputs map[string]AgentOutput `json:"-" db:"agent_outputs"`
+	// StageTimings maps each pipeline stage that has started to its start and completion times.
+	StageTimings map[string]StageTiming `json:"-" db:"stage_timings"`
+	// SunoModel is the Suno model chosen at creation; nil uses the user's default, then V5.
+	SunoModel *string `json:"suno_model,omitempty" db:"suno_model"`
+	// ErrorCode classifies the failure (a JobError* constant); nil for unclassified failures.
+	ErrorCode *string `json:"error_code,omitempty" db:"error_code"`
+	// RetryFrom is the step a retry must restart from (a RetryFrom* constant); nil derives it from the job's outputs.
+	RetryFrom *string `json:"-" db:"retry_from"`
+	// ImageSanitizeAttempts counts image generations restarted with a sanitized prompt
+	// after the image model refused the previous one under its content policy.
+	ImageSanitizeAttempts int `json:"-" db:"image_sanitize_attempts"`
+	// KeepAllTracks copies every Suno track to R2 at upload instead of only the primary's audio.
+	KeepAllTracks bool `json:"keep_all_tracks" db:"keep_all_tracks"`
+	// Tracks are the tracks copied to R2; nil until the job is uploaded or without KeepAllTracks.
+	Tracks []JobTrack `json:"tracks,omitempty" db:"tracks"`
+	// MaxDurationSeconds caps the video length: the song selector prefers tracks
+	// within it and a longer track is trimmed with a fade-out. nil is unlimited.
+	MaxDurationSeconds *int `json:"max_duration_seconds,omitempty" db:"max_duration_seconds"`
+	// CallbackMode is how the workers learn that Suno and image tasks finished,
+	// one of the CallbackMode* constants.
+	CallbackMode string `json:"callback_mode" db:"callback_mode"`
+}
+
+// MaxDuration returns the job's video length cap, or 0 when it has none.
+func (j *Job) MaxDuration() time.Duration {
+	if j.MaxDurationSeconds == nil {
+		return 0
+	}
+	return time.Duration(*j.MaxDurationSeconds) * time.Second
+}
+
+// Track returns the kept track with the given ID, or nil.
+func (j *Job) Track(id string) *JobTrack {
+	for i := range j.Tracks {
+		if j.Tracks[i].ID == id {
+			return &j.Tracks[i]
+		}
+	}
+	return nil
+}
+
+// MaxImageSanitizeAttempts is how many times image generation is restarted with a
+// sanitized prompt before a content policy refusal fails the job.
+const MaxImageSanitizeAttempts = 1
+
+// CanSanitizeImagePrompt reports whether a content policy refusal of the job's
+// image prompt should restart image generation rather than fail the job.
+func (j *Job) CanSanitizeImagePrompt() bool {
+	return j.ImageSanitizeAttempts < MaxImageSanitizeAttempts && !j.HasUserImage()
+}
+
+// Video option defaults and bounds.
+const (
+	DefaultFadeOutSeconds = 3
+	MaxFadeOutSeconds     = 10
+)
+
+// Job callback modes.
+const (
+	// CallbackModeAuto uses provider callbacks when WEBHOOK_BASE_URL is set and polls otherwise.
+	CallbackModeAuto = "auto"
+	// CallbackModeWebhook always waits for provider callbacks (polling only as the usual
+	// fallback); it polls when the worker has no WEBHOOK_BASE_URL.
+	CallbackModeWebhook = "webhook"
+	// CallbackModePoll always polls the providers and sends them no callback URL.
+	CallbackModePoll = "poll"
+)
+
+// CallbackModes lists the valid job callback modes.
+var CallbackModes = []string{CallbackModeAuto, CallbackModeWebhook, CallbackModePoll}
+
+// IsValidCallbackMode reports whether mode is one of CallbackModes.
+func IsValidCallbackMode(mode string) bool {
+	for _, m := range CallbackModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Bounds of a job's max_duration_seconds.
+const (
+	MinMaxDurationSeconds = 30
+	MaxMaxDurationSeconds = 600
+)
+
+// VideoOptions controls how the final video is encoded. Unset fields use the
+// defaults: the server's preset, loudness normalization on and a
+// DefaultFadeOutSeconds fade-out.
+type VideoOptions struct {
+	// Preset is one of the ffmpeg.Preset* names (e.g. "high"). A preset the worker's
+	// FFmpeg cannot encode falls back to the server default.
+	Preset *string `json:"preset,omitempty"`
+	// NormalizeAudio normalizes the track to -14 LUFS.
+	NormalizeAudio *bool `json:"normalize_audio,omitempty"`
+	// FadeOutSeconds fades audio and video out over the last seconds of the track; 0 disables the fade.
+	FadeOutSeconds *int `json:"fade_out_seconds,omitempty"`
+}
+
+// PresetName returns the requested encoding preset, or "" for the server default.
+func (o *VideoOptions) PresetName() string {
+	if o == nil || o.Preset == nil {
+		return ""
+	}
+	return *o.Preset
+}
+
+// VideoMetadata describes the encoded output of a job.
+type VideoMetadata struct {
+	Preset          string  `json:"preset"`
+	Codec           string  `json:"codec"`   // Video codec reported by ffprobe, e.g. h264 or hevc
+	Bitrate         int64   `json:"bitrate"` // Overall bitrate in bits per second
+	SizeBytes       int64   `json:"size_bytes"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	// OriginalDurationSeconds is the selected track's length before a trim to
+	// max_duration_seconds; 0 when unknown.
+	OriginalDurationSeconds float64 `json:"original_duration_seconds,omitempty"`
+	Trimmed                 bool    `json:"trimmed,omitempty"`
+}
+
+// ShouldNormalizeAudio reports whether the track should be loudness normalized.
+func (o *VideoOptions) ShouldNormalizeAudio() bool {
+	return o == nil || o.NormalizeAudio == nil || *o.NormalizeAudio
+}
+
+// FadeOut returns how long the fade-out at the end of the video lasts.
+func (o *VideoOptions) FadeOut() time.Duration {
+	if o == nil || o.FadeOutSeconds == nil {
+		return DefaultFadeOutSeconds * time.Second
+	}
+	return time.Duration(*o.FadeOutSeconds) * time.Second
+}
+
+// AcceptsUserImage returns true if the user can still supply the job's image.
+func (j *Job) AcceptsUserImage() bool {
+	if j.CancelledAt != nil {
+		return false
+	}
+	for _, status := range ImageUploadStatuses {
+		if j.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// HasUserImage returns true if the user supplied the job's image.
+func (j *Job) HasUserImage() bool {
+	return j.ImageSource != nil && *j.ImageSource == ImageSourceUser
+}
+
+// UsesSunoImage returns true if the job takes its image from the selected Suno track.
+func (j *Job) UsesSunoImage() bool {
+	return j.ImageSource != nil && *j.ImageSource == ImageSourceSuno
 }
 
 // CreateJobInput represents the input for creating a new job.
 type CreateJobInput struct {
 	Concept string  `json:"concept" validate:"required,min=5"`
 	Model   *string `json:"model,omitempty"`
+	// ImageCandidates is the number of images to generate (1-3); nil uses the server default.
+	ImageCandidates *int `json:"image_candidates,omitempty"`
+	// AspectRatio overrides the image aspect ratio chosen by the image concept agent; nil lets the agent decide.
+	AspectRatio *string `json:"aspect_ratio,omitempty"`
+	// TemplateID pre-fills unset fields from one of the user's job templates.
+	TemplateID *uuid.UUID `json:"template_id,omitempty"`
+	// PromptOverrides is filled from the template, never from the request body.
+	PromptOverrides *AgentPrompts `json:"-"`
+	// ImageURL is the user's own cover image (public HTTPS png/jpg/webp, max 10MB);
+	// it replaces image generation and is copied into R2 when the image stage runs.
+	ImageURL *string `json:"image_url,omitempty"`
+	// ImageSource "suno" uses the selected track's cover art instead of generating
+	// an image; nil generates one. Cannot be combined with ImageURL.
+	ImageSource *string `json:"image_source,omitempty"`
+	// VideoOptions controls loudness normalization and the fade-out; nil uses the defaults.
+	VideoOptions *VideoOptions `json:"video_options,omitempty"`
+	// SunoModel is one of the kie.Model* constants (e.g. "V4_5"); nil uses the user's default, then V5.
+	SunoModel *string `json:"suno_model,omitempty"`
+	// Tags label the job for search (max 10, 30 characters each); they are stored lowercase.
+	Tags []string `json:"tags,omitempty"`
+	// KeepAllTracks keeps every Suno track in R2 as a downloadable track; the
+	// selected one is still used for the video.
+	KeepAllTracks bool `json:"keep_all_tracks,omitempty"`
+	// MaxDurationSeconds caps the video length (30-600); nil is unlimited.
+	MaxDurationSeconds *int `json:"max_duration_seconds,omitempty"`
+	// CallbackMode is auto (default), webhook or poll; see the CallbackMode* constants.
+	CallbackMode *string `json:"callback_mode,omitempty"`
+	// OpenRouterKeySource is set by the handler after checking the user's keys, never from the request body.
+	OpenRouterKeySource string `json:"-"`
+	KIEKeySource        string `json:"-"`
+	// OrgID is the creator's organization, set by the handler; nil for personal accounts.
+	OrgID *uuid.UUID `json:"-"`
+}
+
+// MaxBulkJobConcepts is the most concepts accepted by a single bulk create request.
+const MaxBulkJobConcepts = 50
+
+// BulkCreateJobsInput represents a request to create one job per concept.
+// Model, ImageCandidates and AspectRatio apply to every job in the batch.
+type BulkCreateJobsInput struct {
+	Concepts        []string `json:"concepts"`
+	Model           *string  `json:"model,omitempty"`
+	ImageCandidates *int     `json:"image_candidates,omitempty"`
+	AspectRatio     *string  `json:"aspect_ratio,omitempty"`
+	SunoModel       *string  `json:"suno_model,omitempty"`
+	// Atomic rejects the whole batch when any concept fails validation.
+	// When false, valid concepts are created and rejected ones are reported.
+	Atomic bool `json:"atomic"`
+}
+
+// BulkJobError describes why one concept of a bulk request was rejected.
+type BulkJobError struct {
+	Index   int               `json:"index"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// BulkCreatedJob pairs a created job with the index of its concept in the request.
+type BulkCreatedJob struct {
+	Index int       `json:"index"`
+	JobID uuid.UUID `json:"job_id"`
+}
+
+// BulkCreateJobsResponse is the result of a bulk create request.
+type BulkCreateJobsResponse struct {
+	Created  []BulkCreatedJob `json:"created"`
+	Rejected []BulkJobError   `json:"rejected"`
 }
 
 // JobResponse represents the API response for a job.
 type JobResponse struct {
-	ID             uuid.UUID       `json:"id"`
-	UserID         uuid.UUID       `json:"user_id"`
-	Status         string          `json:"status"`
-	Concept        string          `json:"concept"`
-	LLMModel       string          `json:"llm_model"`
-	SongPrompt     *SongPrompt     `json:"song_prompt,omitempty"`
-	GeneratedSongs []GeneratedSong `json:"generated_songs,omitempty"`
-	SelectedSongID *string         `json:"selected_song_id,omitempty"`
-	ImagePrompt    *ImagePrompt    `json:"image_prompt,omitempty"`
-	AudioURL       *string         `json:"audio_url,omitempty"`
-	ImageURL       *string         `json:"image_url,omitempty"`
-	VideoURL       *string         `json:"video_url,omitempty"`
-	YouTubeURL     *string         `json:"youtube_url,omitempty"`
-	YouTubeVideoID *string         `json:"youtube_video_id,omitempty"`
-	YouTubeError   *string         `json:"youtube_error,omitempty"`
-	ErrorMessage   *string         `json:"error_message,omitempty"`
-	CreatedAt      time.Time       `json:"created_at"`
-	UpdatedAt      time.Time       `json:"updated_at"`
+	ID              uuid.UUID         `json:"id"`
+	UserID          uuid.UUID         `json:"user_id"`
+	Status          string            `json:"status"`
+	Concept         string            `json:"concept"`
+	LLMModel        string            `json:"llm_model"`
+	SongPrompt      *SongPrompt       `json:"song_prompt,omitempty"`
+	GeneratedSongs  []GeneratedSong   `json:"generated_songs,omitempty"`
+	SelectedSongID  *string           `json:"selected_song_id,omitempty"`
+	ImagePrompt     *ImagePrompt      `json:"image_prompt,omitempty"`
+	AspectRatio     *string           `json:"aspect_ratio,omitempty"`
+	SunoModel       *string           `json:"suno_model,omitempty"`
+	ImageSource     *string           `json:"image_source,omitempty"`
+	VideoOptions    *VideoOptions     `json:"video_options,omitempty"`
+	VideoMetadata   *VideoMetadata    `json:"video_metadata,omitempty"`
+	Tags            []string          `json:"tags"`
+	DeletedAt       *time.Time        `json:"deleted_at,omitempty"`
+	OrgID           *uuid.UUID        `json:"org_id,omitempty"`
+	KeySource       string            `json:"openrouter_key_source"`
+	KIEKeySource    string            `json:"kie_key_source"`
+	GeneratedImages []GeneratedImage  `json:"generated_images,omitempty"`
+	AudioURL        *string           `json:"audio_url,omitempty"`
+	ImageURL        *string           `json:"image_url,omitempty"`
+	VideoURL        *string           `json:"video_url,omitempty"`
+	ThumbnailURL    *string           `json:"thumbnail_url,omitempty"`
+	YouTubeURL      *string           `json:"youtube_url,omitempty"`
+	YouTubeVideoID  *string           `json:"youtube_video_id,omitempty"`
+	YouTubeError    *string           `json:"youtube_error,omitempty"`
+	ErrorMessage    *string           `json:"error_message,omitempty"`
+	ErrorCode       *string           `json:"error_code,omitempty"`
+	CancelledAt     *time.Time        `json:"cancelled_at,omitempty"`
+	AgentModels     map[string]string `json:"agent_models,omitempty"`
+	Shared          bool              `json:"shared"`
+	ShareToken      *string           `json:"share_token,omitempty"`
+	SharedAt        *time.Time        `json:"shared_at,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	// EstimatedDurationSeconds is set on creation responses from recently completed jobs; 0 when unknown.
+	EstimatedDurationSeconds int `json:"estimated_duration_seconds,omitempty"`
+	// QueuePosition is how many tasks are ahead of a pending job's first task in
+	// its queue; EstimatedStartSeconds is when that task should start. Both are only
+	// set on pending jobs whose task was found in the queue.
+	QueuePosition         *int `json:"queue_position,omitempty"`
+	EstimatedStartSeconds *int `json:"estimated_start_seconds,omitempty"`
+	// AgentOutputs is only set when requested with ?include=agent_outputs.
+	AgentOutputs map[string]AgentOutputResponse `json:"agent_outputs,omitempty"`
+	// StageDurations lists the started pipeline stages in order, with durations once completed.
+	StageDurations []StageDuration `json:"stage_durations,omitempty"`
+	// FailedStage is the pipeline stage a failed job stopped in; the artifacts of
+	// earlier stages are still returned and can be downloaded.
+	FailedStage string `json:"failed_stage,omitempty"`
+	// Resumable is true when a retry would keep the job's completed work.
+	Resumable bool `json:"resumable"`
+	// KeepAllTracks and Tracks list every Suno track kept in R2; download one
+	// with GET /jobs/{id}/download?asset=track&track_id=...
+	KeepAllTracks bool       `json:"keep_all_tracks"`
+	Tracks        []JobTrack `json:"tracks,omitempty"`
+	// MaxDurationSeconds caps the video length; video_metadata records the trim.
+	MaxDurationSeconds *int `json:"max_duration_seconds,omitempty"`
+	// CallbackMode is auto, webhook or poll.
+	CallbackMode string `json:"callback_mode"`
+}
+
+// JobLyricsResponse is a job's lyrics split into song sections.
+type JobLyricsResponse struct {
+	JobID    uuid.UUID        `json:"job_id"`
+	Title    string           `json:"title"`
+	Sections []lyrics.Section `json:"sections"`
+	Text     string           `json:"text"` // Plain lyrics without tags, sections separated by a blank line
+}
+
+// SharedJobResponse is the public, read-only view of a shared job.
+type SharedJobResponse struct {
+	Title     string    `json:"title"`
+	VideoURL  *string   `json:"video_url,omitempty"`
+	Duration  float64   `json:"duration,omitempty"` // seconds; 0 when unknown
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// maxListConceptLength is the concept length returned in job list items.
+const maxListConceptLength = 200
+
+// JobListItem is a lightweight job summary for list responses.
+// It omits lyrics, prompts and candidate lists, which are only returned by the detail endpoint.
+type JobListItem struct {
+	ID       uuid.UUID `json:"id"`
+	Status   string    `json:"status"`
+	Concept  string    `json:"concept"`
+	Title    *string   `json:"title,omitempty"`
+	VideoURL *string   `json:"video_url,omitempty"`
+	VideoKey *string   `json:"-"`
+	// ThumbnailURL is a preview image of the finished video; set from ThumbnailKey.
+	ThumbnailURL *string   `json:"thumbnail_url,omitempty"`
+	ThumbnailKey *string   `json:"-"`
+	Progress     int       `json:"progress"`
+	Tags         []string  `json:"tags"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	// DeletedAt is only set on soft-deleted jobs, listed with include_deleted=true.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// UpdateJobTagsInput replaces a job's tags.
+type UpdateJobTagsInput struct {
+	Tags []string `json:"tags"`
+}
+
+// NewJobListItem builds a list item, truncating the concept and deriving progress from status.
+func NewJobListItem(id uuid.UUID, status, concept string, title, videoURL, videoKey *string, createdAt, updatedAt time.Time) *JobListItem {
+	if runes := []rune(concept); len(runes) > maxListConceptLength {
+		concept = string(runes[:maxListConceptLength])
+	}
+	return &JobListItem{
+		ID:        id,
+		Status:    status,
+		Concept:   concept,
+		Title:     title,
+		VideoURL:  videoURL,
+		VideoKey:  videoKey,
+		Progress:  StatusProgress(status),
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+	}
+}
+
+// ResolveAssetURLs replaces the stored video URL with a fresh one generated from the
+// video key, and generates the thumbnail URL from its key.
+func (i *JobListItem) ResolveAssetURLs(ctx context.Context, signer AssetURLSigner) {
+	i.VideoURL = resolveAssetURL(ctx, signer, i.VideoKey, i.VideoURL)
+	i.ThumbnailURL = resolveAssetURL(ctx, signer, i.ThumbnailKey, nil)
+}
+
+// statusProgress maps each pipeline status to an approximate completion percentage.
+var statusProgress = map[string]int{
+	StatusPending:          0,
+	StatusAnalyzing:        10,
+	StatusGeneratingMusic:  25,
+	StatusSelectingSong:    45,
+	StatusGeneratingImage:  55,
+	StatusProcessingVideo:  70,
+	StatusUploading:        85,
+	StatusUploadingYouTube: 95,
+	StatusCompleted:        100,
+	StatusFailed:           0,
+}
+
+// StatusProgress returns the approximate completion percentage for a status.
+func StatusProgress(status string) int {
+	return statusProgress[status]
 }
 
 // ToResponse converts a Job to a JobResponse.
 // This method filters out internal fields that should not be exposed in the API.
 func (j *Job) ToResponse() *JobResponse {
 	return &JobResponse{
-		ID:             j.ID,
-		UserID:         j.UserID,
-		Status:         j.Status,
-		Concept:        j.Concept,
-		LLMModel:       j.LLMModel,
-		SongPrompt:     j.SongPrompt,
-		GeneratedSongs: j.GeneratedSongs,
-		SelectedSongID: j.SelectedSongID,
-		ImagePrompt:    j.ImagePrompt,
-		AudioURL:       j.AudioURL,
-		ImageURL:       j.ImageURL,
-		VideoURL:       j.VideoURL,
-		YouTubeURL:     j.YouTubeURL,
-		YouTubeVideoID: j.YouTubeVideoID,
-		YouTubeError:   j.YouTubeError,
-		ErrorMessage:   j.ErrorMessage,
-		CreatedAt:      j.CreatedAt,
-		UpdatedAt:      j.UpdatedAt,
+		ID:              j.ID,
+		UserID:          j.UserID,
+		Status:          j.Status,
+		Concept:         j.Concept,
+		LLMModel:        j.LLMModel,
+		SongPrompt:      j.SongPrompt,
+		GeneratedSongs:  j.GeneratedSongs,
+		SelectedSongID:  j.SelectedSongID,
+		ImagePrompt:     j.ImagePrompt,
+		AspectRatio:     j.AspectRatio,
+		SunoModel:       j.SunoModel,
+		ImageSource:     j.ImageSource,
+		VideoOptions:    j.VideoOptions,
+		VideoMetadata:   j.VideoMetadata,
+		Tags:            j.Tags,
+		DeletedAt:       j.DeletedAt,
+		OrgID:           j.OrgID,
+		KeySource:       j.OpenRouterKeySource,
+		KIEKeySource:    j.KIEKeySource,
+		GeneratedImages: j.GeneratedImages,
+		AudioURL:        j.AudioURL,
+		ImageURL:        j.ImageURL,
+		VideoURL:        j.VideoURL,
+		YouTubeURL:      j.YouTubeURL,
+		YouTubeVideoID:  j.YouTubeVideoID,
+		YouTubeError:    j.YouTubeError,
+		ErrorMessage:    j.ErrorMessage,
+		ErrorCode:       j.ErrorCode,
+		CancelledAt:     j.CancelledAt,
+		AgentModels:     j.AgentModels,
+		Shared:          j.ShareToken != nil,
+		ShareToken:      j.ShareToken,
+		SharedAt:        j.SharedAt,
+		CreatedAt:       j.CreatedAt,
+		UpdatedAt:       j.UpdatedAt,
+		StageDurations:  j.StageDurations(),
+		FailedStage:     j.FailedStage(),
+		Resumable:       j.IsResumable(),
+		KeepAllTracks:   j.KeepAllTracks,
+		Tracks:          j.Tracks,
+
+		MaxDurationSeconds: j.MaxDurationSeconds,
+		CallbackMode:       j.CallbackMode,
 	}
 }
 
+// AssetURLSigner generates a client-facing URL for a stored object key.
+// It is satisfied by *r2.Client.
+type AssetURLSigner interface {
+	AssetURL(ctx context.Context, key string) (string, error)
+}
+
+// ToResponseWithSigner converts a Job to a JobResponse, generating asset URLs from the
+// stored R2 keys so presigned URLs never expire in the database. Jobs written before
+// keys were stored keep their persisted URLs.
+func (j *Job) ToResponseWithSigner(ctx context.Context, signer AssetURLSigner) *JobResponse {
+	resp := j.ToResponse()
+	resp.VideoURL = resolveAssetURL(ctx, signer, j.VideoKey, j.VideoURL)
+	resp.AudioURL = resolveAssetURL(ctx, signer, j.AudioKey, j.AudioURL)
+	resp.ImageURL = resolveAssetURL(ctx, signer, j.ImageKey, j.ImageURL)
+	resp.ThumbnailURL = resolveAssetURL(ctx, signer, j.ThumbnailKey, nil)
+	return resp
+}
+
+// ToSharedResponse converts a Job to its public share view. Only the title, video and
+// timing are exposed; the concept, prompts and owner stay private.
+func (j *Job) ToSharedResponse(ctx context.Context, signer AssetURLSigner) *SharedJobResponse {
+	resp := &SharedJobResponse{
+		VideoURL:  resolveAssetURL(ctx, signer, j.VideoKey, j.VideoURL),
+		CreatedAt: j.CreatedAt,
+	}
+	if j.SongPrompt != nil {
+		resp.Title = j.SongPrompt.Title
+	}
+	if song := j.SelectedSong(); song != nil {
+		resp.Duration = song.Duration
+		if resp.Title == "" {
+			resp.Title = song.Title
+		}
+	}
+	return resp
+}
+
+// SelectedSong returns the generated song chosen for the video, or nil before selection.
+func (j *Job) SelectedSong() *GeneratedSong {
+	if j.SelectedSongID == nil {
+		return nil
+	}
+	for i := range j.GeneratedSongs {
+		if j.GeneratedSongs[i].ID == *j.SelectedSongID {
+			return &j.GeneratedSongs[i]
+		}
+	}
+	return nil
+}
+
+// HasVideo returns true if the job has a rendered video, either as an R2 key or a legacy URL.
+func (j *Job) HasVideo() bool {
+	return (j.VideoKey != nil && *j.VideoKey != "") || (j.VideoURL != nil && *j.VideoURL != "")
+}
+
+// resolveAssetURL returns a URL for key, falling back to storedURL when there is no key
+// or the URL cannot be generated.
+func resolveAssetURL(ctx context.Context, signer AssetURLSigner, key, storedURL *string) *string {
+	if signer == nil || key == nil || *key == "" {
+		return storedURL
+	}
+	url, err := signer.AssetURL(ctx, *key)
+	if err != nil || url == "" {
+		return storedURL
+	}
+	return &url
+}
+
 // IsTerminal returns true if the job is in a terminal state (completed or failed).
 func (j *Job) IsTerminal() bool {
-	return j.Status == StatusCompleted || j.Status == StatusFailed
+	return j.Status == StatusCompleted || j.Status == StatusFailed || j.IsDeleted()
 }
 
-// CanRetry returns true if the job can be retried (only failed jobs can be retried).
+// JobRestoreWindow is how long a soft-deleted job can be restored before it is purged.
+const JobRestoreWindow = 30 * 24 * time.Hour
+
+// IsDeleted returns true if the user deleted the job. Deleted jobs are terminal, so
+// a late webhook or task never resumes one.
+func (j *Job) IsDeleted() bool {
+	return j.DeletedAt != nil
+}
+
+// PendingImageCandidates returns the number of image candidates still awaiting a result.
+func (j *Job) PendingImageCandidates() int {
+	pending := 0
+	for _, img := range j.GeneratedImages {
+		if img.Status == ImageCandidatePending {
+			pending++
+		}
+	}
+	return pending
+}
+
+// SuccessfulImageCandidates returns the image candidates that completed with an image URL.
+func (j *Job) SuccessfulImageCandidates() []GeneratedImage {
+	images := make([]GeneratedImage, 0, len(j.GeneratedImages))
+	for _, img := range j.GeneratedImages {
+		if img.Status == ImageCandidateSuccess && img.ImageURL != "" {
+			images = append(images, img)
+		}
+	}
+	return images
+}
+
+// MergeGeneratedSongs returns the songs already stored for a job merged with
+// those from a later Suno callback. Songs are matched by ID; an incoming song
+// replaces the stored one because later callbacks carry final audio URLs.
+func MergeGeneratedSongs(existing, incoming []GeneratedSong) []GeneratedSong {
+	merged := make([]GeneratedSong, 0, len(existing)+len(incoming))
+	index := make(map[string]int, len(existing)+len(incoming))
+	for _, songs := range [][]GeneratedSong{existing, incoming} {
+		for _, song := range songs {
+			if i, ok := index[song.ID]; ok {
+				merged[i] = song
+				continue
+			}
+			index[song.ID] = len(merged)
+			merged = append(merged, song)
+		}
+	}
+	return merged
+}
+
+// IsCancelled returns true if the job was cancelled by the user.
+func (j *Job) IsCancelled() bool {
+	return j.CancelledAt != nil
+}
+
+// CanRetry returns true if the job can be retried: it failed and was not cancelled by the user.
 func (j *Job) CanRetry() bool {
-	return j.Status == StatusFailed
+	return j.Status == StatusFailed && j.CancelledAt == nil
+}
+
+// JobStorageState is what the R2 asset cleaner needs to know about a job that
+// owns stored objects. UpdatedAt of a failed job is when it failed.
+type JobStorageState struct {
+	ID        uuid.UUID
+	Status    string
+	UpdatedAt time.Time
+}
+
+// JobWriteExtras is bookkeeping folded into the write that stores a task's result,
+// so a task updates the job once instead of once per record. Zero fields are skipped.
+type JobWriteExtras struct {
+	Agent           string      // Prompt type of the agent that produced AgentOutput; empty records no agent
+	AgentOutput     AgentOutput // Its Model is also recorded in agent_models
+	CompletedStages []string    // Pipeline stages the write completes
+	WorkerID        string      // Worker instance recorded with the completed stages
 }
@@ -0,0 +1,132 @@
+package models
+
+// Pipeline steps a failed job can be retried from, named after their tasks.
+const (
+	RetryFromAnalyze    = "analyze_concept"
+	RetryFromMusic      = "generate_music"
+	RetryFromSelectSong = "select_song"
+	RetryFromImage      = "generate_image"
+	RetryFromVideo      = "process_video"
+)
+
+// retryStatus is the status a job is reset to when retried from each step: the
+// status the step's task expects to find.
+var retryStatus = map[string]string{
+	RetryFromAnalyze:    StatusPending,
+	RetryFromMusic:      StatusAnalyzing,
+	RetryFromSelectSong: StatusSelectingSong,
+	RetryFromImage:      StatusGeneratingImage,
+	RetryFromVideo:      StatusProcessingVideo,
+}
+
+// RetryStatus returns the status a job retried from step restarts in, or "" for
+// an unknown step.
+func RetryStatus(step string) string {
+	return retryStatus[step]
+}
+
+// retryFirstStage is the first timed stage each retry step re-runs. The song
+// selection is timed as part of the music stage.
+var retryFirstStage = map[string]string{
+	RetryFromAnalyze:    StageAnalyze,
+	RetryFromMusic:      StageMusic,
+	RetryFromSelectSong: StageMusic,
+	RetryFromImage:      StageImage,
+	RetryFromVideo:      StageVideo,
+}
+
+// RetriedStages returns the timed stages a retry from step re-runs, whose
+// timings are cleared so they time the new run. Nil for an unknown step.
+func RetriedStages(step string) []string {
+	first, ok := retryFirstStage[step]
+	if !ok {
+		return nil
+	}
+	for i, stage := range PipelineStages {
+		if stage == first {
+			return append([]string(nil), PipelineStages[i:]...)
+		}
+	}
+	return nil
+}
+
+// Job error codes, stored with the error message of failures the pipeline can
+// classify so clients can react without parsing messages.
+const (
+	JobErrorNoPlayableSongs  = "NO_PLAYABLE_SONGS"
+	JobErrorAudioUnavailable = "AUDIO_UNAVAILABLE"
+	JobErrorOutputTruncated  = "LLM_OUTPUT_TRUNCATED"
+	JobErrorUnexpectedMedia  = "UNEXPECTED_MEDIA"
+	JobErrorMediaTooLarge    = "MEDIA_TOO_LARGE"
+)
+
+// Messages of the classified job failures.
+const (
+	NoPlayableSongsMessage  = "Suno returned no playable tracks (they were silent, shorter than 10 seconds or had no audio). Please retry the job to generate new songs."
+	AudioUnavailableMessage = "The selected song's audio could not be downloaded from Suno. Please retry the job to generate new songs."
+	OutputTruncatedMessage  = "The AI model hit its output limit before finishing its answer. Please retry with a different model, or raise max_tokens for this agent."
+	UnexpectedMediaMessage  = "The song audio or cover image could not be used: its URL returned something other than a supported audio (MP3, M4A, OGG) or image (PNG, JPEG, WebP) file, such as an error page."
+	MediaTooLargeMessage    = "The song audio or cover image is larger than the worker accepts."
+)
+
+// JobFailure describes why a job failed. Code and RetryFrom are optional; without
+// RetryFrom a retry resumes from the first step whose output is missing.
+type JobFailure struct {
+	Message   string
+	Code      string
+	RetryFrom string
+}
+
+// RetryStep returns the pipeline step a retry of the job starts from: the step
+// recorded with the failure, else the first step whose output is missing.
+func (j *Job) RetryStep() string {
+	if j.RetryFrom != nil && RetryStatus(*j.RetryFrom) != "" {
+		return *j.RetryFrom
+	}
+	switch {
+	case j.SongPrompt == nil:
+		return RetryFromAnalyze
+	case j.SelectedSongID == nil && len(j.GeneratedSongs) == 0:
+		return RetryFromMusic
+	case j.SelectedSongID == nil || j.AudioURL == nil:
+		return RetryFromSelectSong
+	case j.ImageURL == nil && j.ImageKey == nil:
+		return RetryFromImage
+	default:
+		// The rendered video is not kept after a failed upload, so uploads restart from the render
+		return RetryFromVideo
+	}
+}
+
+// FailedStage returns the pipeline stage a failed job stopped in: the first stage
+// whose output is missing, with the render and the upload told apart by whether
+// the upload had started. Empty unless the job failed.
+func (j *Job) FailedStage() string {
+	if j.Status != StatusFailed {
+		return ""
+	}
+	switch {
+	case j.SongPrompt == nil:
+		return StageAnalyze
+	case j.SelectedSongID == nil || j.AudioURL == nil:
+		return StageMusic
+	case j.ImageURL == nil && j.ImageKey == nil:
+		return StageImage
+	}
+	if timing, ok := j.StageTimings[StageUpload]; ok && timing.StartedAt != nil {
+		return StageUpload
+	}
+	return StageVideo
+}
+
+// IsResumable reports whether a retry of the job would keep work it completed,
+// i.e. it can be retried and would not start over from the concept analysis.
+func (j *Job) IsResumable() bool {
+	return j.CanRetry() && j.RetryStep() != RetryFromAnalyze
+}
+
+// RetryJobResponse is the result of retrying a job.
+type RetryJobResponse struct {
+	Job       *JobResponse `json:"job"`
+	RetryFrom string       `json:"retry_from"` // One of the RetryFrom* steps
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRetriedStages(t *testing.T) {
+	tests := []struct {
+		step string
+		want []string
+	}{
+		{RetryFromAnalyze, PipelineStages},
+		{RetryFromMusic, []string{StageMusic, StageImage, StageVideo, StageUpload, StageVideoUpload}},
+		{RetryFromSelectSong, []string{StageMusic, StageImage, StageVideo, StageUpload, StageVideoUpload}},
+		{RetryFromImage, []string{StageImage, StageVideo, StageUpload, StageVideoUpload}},
+		{RetryFromVideo, []string{StageVideo, StageUpload, StageVideoUpload}},
+		{"unknown", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.step, func(t *testing.T) {
+			if got := RetriedStages(tt.step); !slices.Equal(got, tt.want) {
+				t.Errorf("RetriedStages(%s) = %v, want %v", tt.step, got, tt.want)
+			}
+		})
+	}
+
+	// Every retry step clears its own stage
+	for step := range retryStatus {
+		if len(RetriedStages(step)) == 0 {
+			t.Errorf("retry step %s clears no stage timings", step)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schedule frequencies.
+const (
+	ScheduleDaily  = "daily"
+	ScheduleWeekly = "weekly"
+)
+
+// MaxJobSchedulesPerUser caps how many schedules a single user can keep.
+const MaxJobSchedulesPerUser = 10
+
+// scheduleTimeLayout is the format of JobSchedule.TimeOfDay.
+const scheduleTimeLayout = "15:04"
+
+// JobSchedule creates a job from the same concept (and optional template) every
+// day or every week at a local time of day.
+type JobSchedule struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	Concept    string     `json:"concept" db:"concept"`
+	TemplateID *uuid.UUID `json:"template_id,omitempty" db:"template_id"`
+	Frequency  string     `json:"frequency" db:"frequency"`
+	TimeOfDay  string     `json:"time_of_day" db:"time_of_day"`   // HH:MM in Timezone
+	Weekday    *int       `json:"weekday,omitempty" db:"weekday"` // 0 = Sunday; weekly schedules only
+	Timezone   string     `json:"timezone" db:"timezone"`
+	Enabled    bool       `json:"enabled" db:"enabled"`
+	// DisabledReason explains why the scheduler turned the schedule off.
+	DisabledReason *string    `json:"disabled_reason,omitempty" db:"disabled_reason"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty" db:"last_run_at"`
+	LastJobID      *uuid.UUID `json:"last_job_id,omitempty" db:"last_job_id"`
+	NextRunAt      time.Time  `json:"next_run_at" db:"next_run_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// JobScheduleInput is the request body for creating or replacing a schedule.
+type JobScheduleInput struct {
+	Concept    string     `json:"concept"`
+	TemplateID *uuid.UUID `json:"template_id,omitempty"`
+	Frequency  string     `json:"frequency"`
+	TimeOfDay  string     `json:"time_of_day"`
+	Weekday    *int       `json:"weekday,omitempty"`
+	Timezone   string     `json:"timezone"`
+	Enabled    *bool      `json:"enabled,omitempty"` // nil = enabled
+}
+
+// ValidateSchedule checks the timing fields of a schedule and returns
+// validation details keyed by field name.
+func ValidateSchedule(frequency, timeOfDay string, weekday *int, timezone string) map[string]string {
+	details := make(map[string]string)
+
+	switch frequency {
+	case ScheduleDaily:
+	case ScheduleWeekly:
+		if weekday == nil || *weekday < 0 || *weekday > 6 {
+			details["weekday"] = "weekday must be between 0 (Sunday) and 6 (Saturday) for weekly schedules"
+		}
+	default:
+		details["frequency"] = "frequency must be daily or weekly"
+	}
+
+	if _, err := time.Parse(scheduleTimeLayout, timeOfDay); err != nil {
+		details["time_of_day"] = "time_of_day must be HH:MM (24-hour)"
+	}
+	if _, err := time.LoadLocation(timezone); err != nil || timezone == "" {
+		details["timezone"] = "timezone must be an IANA name such as Asia/Bangkok"
+	}
+
+	return details
+}
+
+// NextRun returns the first run time strictly after the given instant.
+func (s *JobSchedule) NextRun(after time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timezone %q: %w", s.Timezone, err)
+	}
+	clock, err := time.Parse(scheduleTimeLayout, s.TimeOfDay)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time_of_day %q: %w", s.TimeOfDay, err)
+	}
+
+	local := after.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+
+	if s.Frequency == ScheduleWeekly && s.Weekday != nil {
+		next = next.AddDate(0, 0, (*s.Weekday-int(next.Weekday())+7)%7)
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next.UTC(), nil
+	}
+
+	if !next.After(after) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.UTC(), nil
+}
@@ -0,0 +1,48 @@
+package models
+
+// jobTransitions is the pipeline graph: the statuses a job in each status may move
+// to. Active statuses lead to themselves because a retried or resumed task
+// re-enters the status it failed in, and every active status can fail. A failed
+// job only leaves failed when it is retried (see RetryStatus); a completed job
+// never changes status again.
+var jobTransitions = map[string][]string{
+	StatusPending:          {StatusAnalyzing, StatusFailed},
+	StatusAnalyzing:        {StatusAnalyzing, StatusGeneratingMusic, StatusFailed},
+	StatusGeneratingMusic:  {StatusGeneratingMusic, StatusSelectingSong, StatusFailed},
+	StatusSelectingSong:    {StatusSelectingSong, StatusGeneratingImage, StatusFailed},
+	StatusGeneratingImage:  {StatusGeneratingImage, StatusProcessingVideo, StatusFailed},
+	StatusProcessingVideo:  {StatusProcessingVideo, StatusUploading, StatusFailed},
+	StatusUploading:        {StatusUploading, StatusUploadingYouTube, StatusCompleted, StatusFailed},
+	StatusUploadingYouTube: {StatusUploadingYouTube, StatusCompleted, StatusFailed},
+	StatusCompleted:        {},
+	StatusFailed: {
+		StatusPending,
+		StatusAnalyzing,
+		StatusSelectingSong,
+		StatusGeneratingImage,
+		StatusProcessingVideo,
+	},
+}
+
+// CanTransition reports whether a job may move from status from to status to.
+// Unknown statuses cannot transition at all.
+func CanTransition(from, to string) bool {
+	for _, s := range jobTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionSources returns the statuses from which a job may move to status, for
+// writes that guard on the current status in SQL.
+func TransitionSources(status string) []string {
+	var sources []string
+	for _, from := range AllStatuses {
+		if CanTransition(from, status) {
+			sources = append(sources, from)
+		}
+	}
+	return sources
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestCanTransitionMatrix checks every (from, to) pair of statuses against the
+// pipeline graph written out by hand, so a change to jobTransitions has to be
+// made here too.
+func TestCanTransitionMatrix(t *testing.T) {
+	allowed := map[string][]string{
+		StatusPending:          {StatusAnalyzing, StatusFailed},
+		StatusAnalyzing:        {StatusAnalyzing, StatusGeneratingMusic, StatusFailed},
+		StatusGeneratingMusic:  {StatusGeneratingMusic, StatusSelectingSong, StatusFailed},
+		StatusSelectingSong:    {StatusSelectingSong, StatusGeneratingImage, StatusFailed},
+		StatusGeneratingImage:  {StatusGeneratingImage, StatusProcessingVideo, StatusFailed},
+		StatusProcessingVideo:  {StatusProcessingVideo, StatusUploading, StatusFailed},
+		StatusUploading:        {StatusUploading, StatusUploadingYouTube, StatusCompleted, StatusFailed},
+		StatusUploadingYouTube: {StatusUploadingYouTube, StatusCompleted, StatusFailed},
+		StatusCompleted:        nil,
+		StatusFailed:           {StatusPending, StatusAnalyzing, StatusSelectingSong, StatusGeneratingImage, StatusProcessingVideo},
+	}
+	if len(allowed) != len(AllStatuses) {
+		t.Fatalf("matrix covers %d statuses, AllStatuses has %d", len(allowed), len(AllStatuses))
+	}
+
+	for _, from := range AllStatuses {
+		for _, to := range AllStatuses {
+			want := slices.Contains(allowed[from], to)
+			if got := CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestCanTransitionUnknownStatus(t *testing.T) {
+	for _, status := range AllStatuses {
+		if CanTransition("cancelled", status) {
+			t.Errorf("CanTransition(cancelled, %s) = true for an unknown source", status)
+		}
+		if CanTransition(status, "cancelled") {
+			t.Errorf("CanTransition(%s, cancelled) = true for an unknown target", status)
+		}
+	}
+}
+
+// TestFailedOnlyLeadsToRetryStatuses checks that a failed job only leaves failed
+// for a status a retry resets it to.
+func TestFailedOnlyLeadsToRetryStatuses(t *testing.T) {
+	var retryTargets []string
+	for _, step := range []string{RetryFromAnalyze, RetryFromMusic, RetryFromSelectSong, RetryFromImage, RetryFromVideo} {
+		status := RetryStatus(step)
+		if status == "" {
+			t.Fatalf("RetryStatus(%s) is empty", step)
+		}
+		if !CanTransition(StatusFailed, status) {
+			t.Errorf("a job retried from %s cannot move from failed to %s", step, status)
+		}
+		retryTargets = append(retryTargets, status)
+	}
+
+	for _, to := range AllStatuses {
+		if CanTransition(StatusFailed, to) && !slices.Contains(retryTargets, to) {
+			t.Errorf("failed may move to %s, which no retry step resets to", to)
+		}
+	}
+}
+
+func TestTransitionSources(t *testing.T) {
+	for _, to := range AllStatuses {
+		sources := TransitionSources(to)
+		for _, from := range AllStatuses {
+			if got, want := slices.Contains(sources, from), CanTransition(from, to); got != want {
+				t.Errorf("TransitionSources(%s) contains %s = %v, want %v", to, from, got, want)
+			}
+		}
+	}
+	if sources := TransitionSources(StatusCompleted); slices.Contains(sources, StatusFailed) {
+		t.Error("a failed job may complete without being retried")
+	}
+}
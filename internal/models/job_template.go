@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxJobTemplatesPerUser caps how many templates a single user can keep.
+const MaxJobTemplatesPerUser = 20
+
+// JobTemplate is a user's saved preset of job settings. Only the concept changes
+// between jobs created from the same template.
+type JobTemplate struct {
+	ID              uuid.UUID     `json:"id" db:"id"`
+	UserID          uuid.UUID     `json:"user_id" db:"user_id"`
+	Name            string        `json:"name" db:"name"`
+	Model           *string       `json:"model,omitempty" db:"llm_model"`
+	ImageCandidates *int          `json:"image_candidates,omitempty" db:"image_candidates"`
+	AspectRatio     *string       `json:"aspect_ratio,omitempty" db:"aspect_ratio"`
+	PromptOverrides *AgentPrompts `json:"prompt_overrides,omitempty" db:"prompt_overrides"`
+	CreatedAt       time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// JobTemplateInput is the request body for creating or replacing a job template.
+type JobTemplateInput struct {
+	Name            string        `json:"name"`
+	Model           *string       `json:"model,omitempty"`
+	ImageCandidates *int          `json:"image_candidates,omitempty"`
+	AspectRatio     *string       `json:"aspect_ratio,omitempty"`
+	PromptOverrides *AgentPrompts `json:"prompt_overrides,omitempty"`
+}
+
+// Apply fills the fields of input that the request left unset from the template.
+// Fields set explicitly in the request win.
+func (t *JobTemplate) Apply(input CreateJobInput) CreateJobInput {
+	if input.Model == nil || *input.Model == "" {
+		input.Model = t.Model
+	}
+	if input.ImageCandidates == nil {
+		input.ImageCandidates = t.ImageCandidates
+	}
+	if input.AspectRatio == nil {
+		input.AspectRatio = t.AspectRatio
+	}
+	input.PromptOverrides = t.PromptOverrides
+	return input
+}
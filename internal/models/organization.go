@@ -0,0 +1,111 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organization roles. Owners manage the organization's keys and invitations and
+// can modify every job of the organization; members can only view them.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleMember = "member"
+)
+
+// IsValidOrgRole returns true if role is a known organization role.
+func IsValidOrgRole(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleMember
+}
+
+// MaxOrganizationNameLength bounds an organization's name.
+const MaxOrganizationNameLength = 100
+
+// Organization is a shared workspace. Members without their own provider keys
+// run jobs on the organization's keys.
+type Organization struct {
+	ID               uuid.UUID `json:"id" db:"id"`
+	Name             string    `json:"name" db:"name"`
+	OpenRouterAPIKey *string   `json:"-" db:"openrouter_api_key"` // Encrypted, never expose in JSON
+	KIEAPIKey        *string   `json:"-" db:"kie_api_key"`        // Encrypted, never expose in JSON
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// OrganizationMember is a user's membership in an organization.
+type OrganizationMember struct {
+	OrgID     uuid.UUID `json:"org_id" db:"org_id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Email     string    `json:"email" db:"email"`
+	Name      *string   `json:"name,omitempty" db:"name"`
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// IsOwner returns true if the member owns the organization.
+func (m *OrganizationMember) IsOwner() bool {
+	return m.Role == OrgRoleOwner
+}
+
+// InvitationExpiry is how long an invitation link stays valid.
+const InvitationExpiry = 7 * 24 * time.Hour
+
+// OrganizationInvitation invites an email address to an organization. The
+// invitee accepts it with the token emailed to them, while signed in with that
+// email, before it expires or an owner revokes it.
+type OrganizationInvitation struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	OrgID      uuid.UUID  `json:"org_id" db:"org_id"`
+	Email      string     `json:"email" db:"email"`
+	Role       string     `json:"role" db:"role"`
+	InvitedBy  *uuid.UUID `json:"invited_by,omitempty" db:"invited_by"`
+	TokenHash  string     `json:"-" db:"token_hash"` // SHA-256 of the emailed token, never expose in JSON
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty" db:"accepted_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// OrganizationSecrets holds an organization's encrypted keys, used when
+// re-encrypting them with a new key.
+type OrganizationSecrets struct {
+	OrgID            uuid.UUID
+	OpenRouterAPIKey *string
+	KIEAPIKey        *string
+}
+
+// CreateOrganizationInput is the request body for creating an organization.
+type CreateOrganizationInput struct {
+	Name string `json:"name"`
+}
+
+// InviteMemberInput is the request body for inviting a user to an organization.
+type InviteMemberInput struct {
+	Email string `json:"email"`
+	Role  string `json:"role,omitempty"` // owner or member (default)
+}
+
+// AcceptInvitationInput is the request body for accepting an invitation.
+type AcceptInvitationInput struct {
+	Token string `json:"token"`
+}
+
+// UpdateOrganizationAPIKeysInput sets the organization's shared keys. A nil key is
+// left unchanged and an empty one is removed.
+type UpdateOrganizationAPIKeysInput struct {
+	OpenRouterAPIKey *string `json:"openrouter_api_key"`
+	KIEAPIKey        *string `json:"kie_api_key"`
+}
+
+// OrganizationResponse is the caller's organization, with their role in it.
+// Invitations are only listed for owners.
+type OrganizationResponse struct {
+	ID               uuid.UUID                 `json:"id"`
+	Name             string                    `json:"name"`
+	Role             string                    `json:"role"`
+	HasOpenRouterKey bool                      `json:"has_openrouter_key"`
+	HasKIEKey        bool                      `json:"has_kie_key"`
+	Members          []*OrganizationMember     `json:"members"`
+	Invitations      []*OrganizationInvitation `json:"invitations,omitempty"`
+	CreatedAt        time.Time                 `json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PendingTask is an outbox entry for a task that could not be enqueued.
+// The outbox worker re-enqueues it with backoff until it succeeds. Tasks of a
+// job have a JobID; user-level tasks have a UserID instead.
+type PendingTask struct {
+	ID             uuid.UUID       `json:"id"`
+	JobID          *uuid.UUID      `json:"job_id,omitempty"`
+	UserID         *uuid.UUID      `json:"user_id,omitempty"`
+	TaskType       string          `json:"task_type"`
+	TaskID         *string         `json:"task_id,omitempty"` // asynq TaskID used for deduplication, if any
+	Payload        json.RawMessage `json:"payload"`
+	ProcessAt      *time.Time      `json:"process_at,omitempty"` // Enqueue options re-applied on every attempt; nil uses asynq's default
+	Queue          *string         `json:"queue,omitempty"`
+	MaxRetry       *int            `json:"max_retry,omitempty"`
+	TimeoutSeconds *int            `json:"timeout_seconds,omitempty"`
+	Attempts       int             `json:"attempts"`
+	LastError      *string         `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
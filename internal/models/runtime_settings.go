@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Keys of the runtime_settings rows.
+const (
+	SettingJobIntakePaused = "job_intake_paused"
+	SettingBannerMessage   = "banner_message"
+)
+
+// MaxBannerMessageLength is the longest maintenance banner, in characters.
+const MaxBannerMessageLength = 500
+
+// RuntimeSettings are the settings admins change without a deploy. Unset keys
+// keep their zero values.
+type RuntimeSettings struct {
+	// JobIntakePaused refuses new jobs (single, bulk and scheduled); jobs already
+	// created keep processing.
+	JobIntakePaused bool `json:"job_intake_paused"`
+	// BannerMessage is shown by frontends, e.g. before a provider maintenance window.
+	BannerMessage string     `json:"banner_message"`
+	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+}
+
+// MaintenanceNotice is the banner surfaced by GET /status and GET /auth/me.
+type MaintenanceNotice struct {
+	Message         string `json:"message"`
+	JobIntakePaused bool   `json:"job_intake_paused"`
+}
+
+// Notice returns the banner to show, or nil when there is no message and job
+// intake is open.
+func (s *RuntimeSettings) Notice() *MaintenanceNotice {
+	if s == nil || (s.BannerMessage == "" && !s.JobIntakePaused) {
+		return nil
+	}
+	return &MaintenanceNotice{
+		Message:         s.BannerMessage,
+		JobIntakePaused: s.JobIntakePaused,
+	}
+}
+
+// UpdateRuntimeSettingsInput represents an admin update of the runtime settings.
+// Fields left nil keep their current value; an empty banner_message removes it.
+type UpdateRuntimeSettingsInput struct {
+	JobIntakePaused *bool   `json:"job_intake_paused"`
+	BannerMessage   *string `json:"banner_message"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// External services whose call errors are counted for the public status page.
+const (
+	ProviderOpenRouter = "openrouter"
+	ProviderKIE        = "kie"
+	ProviderR2         = "r2"
+)
+
+// StatusProviders lists the external services reported on the status page.
+var StatusProviders = []string{ProviderOpenRouter, ProviderKIE, ProviderR2}
+
+// Provider status values, derived from the service's recent error rate.
+const (
+	ProviderOperational = "operational"
+	ProviderDegraded    = "degraded"
+	ProviderDown        = "down"
+	ProviderUnknown     = "unknown" // Too few recent calls to tell
+)
+
+// Queue depth buckets, relative to the worker concurrency.
+const (
+	QueueDepthLow    = "low"
+	QueueDepthMedium = "medium"
+	QueueDepthHigh   = "high"
+)
+
+// ServiceStatus is the public, aggregate health of the generator. It carries no
+// per-user data, so it can be cached by a CDN.
+type ServiceStatus struct {
+	// PipelineOK is false when recent jobs have failed and none completed.
+	PipelineOK bool `json:"pipeline_ok"`
+	// Providers maps each of StatusProviders to a provider status.
+	Providers map[string]string `json:"providers"`
+	// QueueDepth is low, medium or high; empty when the queue could not be read.
+	QueueDepth string    `json:"queue_depth,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Maintenance is the admin's maintenance banner; omitted when there is none.
+	Maintenance *MaintenanceNotice `json:"maintenance,omitempty"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Pipeline stages timed on each job. They match the stage labels of the
+// ugc_pipeline_stage_duration_seconds metric.
+const (
+	StageAnalyze = "analyze"
+	StageMusic   = "music"
+	StageImage   = "image"
+	StageVideo   = "video"
+	StageUpload  = "upload"
+)
+
+// StageVideoUpload times the transfer of the video to R2, which is part of
+// StageUpload. It has no metric label of its own.
+const StageVideoUpload = "video_upload"
+
+// PipelineStages lists the timed stages in pipeline order.
+var PipelineStages = []string{StageAnalyze, StageMusic, StageImage, StageVideo, StageUpload, StageVideoUpload}
+
+// Stage timing events, stored as the keys of a StageTiming.
+const (
+	StageEventStarted   = "started_at"
+	StageEventCompleted = "completed_at"
+)
+
+// StageTiming is when one pipeline stage started and completed. A stage whose
+// task is retried keeps its first start, so the duration includes the task
+// retries; retrying the failed job clears the stages it re-runs. WorkerID is the
+// worker instance that recorded the latest event.
+type StageTiming struct {
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	WorkerID    string     `json:"worker_id,omitempty"`
+}
+
+// StageDuration is the API view of a stage timing. DurationSeconds is set once
+// the stage has completed.
+type StageDuration struct {
+	Stage           string     `json:"stage"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	DurationSeconds *float64   `json:"duration_seconds,omitempty"`
+	WorkerID        string     `json:"worker_id,omitempty"`
+}
+
+// StageDurationStats is the duration distribution of one stage across jobs.
+type StageDurationStats struct {
+	Stage      string  `json:"stage"`
+	Jobs       int     `json:"jobs"`
+	P50Seconds float64 `json:"p50_seconds"`
+	P95Seconds float64 `json:"p95_seconds"`
+}
+
+// StageDurations returns the timings of the stages the job has started, in
+// pipeline order.
+func (j *Job) StageDurations() []StageDuration {
+	if len(j.StageTimings) == 0 {
+		return nil
+	}
+
+	durations := make([]StageDuration, 0, len(j.StageTimings))
+	for _, stage := range PipelineStages {
+		timing, ok := j.StageTimings[stage]
+		if !ok || timing.StartedAt == nil {
+			continue
+		}
+		d := StageDuration{Stage: stage, StartedAt: timing.StartedAt, CompletedAt: timing.CompletedAt, WorkerID: timing.WorkerID}
+		if timing.CompletedAt != nil && !timing.CompletedAt.Before(*timing.StartedAt) {
+			seconds := timing.CompletedAt.Sub(*timing.StartedAt).Seconds()
+			d.DurationSeconds = &seconds
+		}
+		durations = append(durations, d)
+	}
+	return durations
+}
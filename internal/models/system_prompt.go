@@ -6,6 +6,23 @@ import (
 	"github.com/google/uuid"
 )
 
+// Prompt types, one per agent
+const (
+	PromptTypeSongConcept   = "song_concept"
+	PromptTypeSongSelector  = "song_selector"
+	PromptTypeImageConcept  = "image_concept"
+	PromptTypeImageSelector = "image_selector"
+)
+
+// IsValidPromptType returns true if promptType names a known agent prompt.
+func IsValidPromptType(promptType string) bool {
+	switch promptType {
+	case PromptTypeSongConcept, PromptTypeSongSelector, PromptTypeImageConcept, PromptTypeImageSelector:
+		return true
+	}
+	return false
+}
+
 // SystemPrompt represents a system-wide default prompt stored in DB
 type SystemPrompt struct {
 	ID            uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
@@ -21,15 +38,27 @@ func (SystemPrompt) TableName() string {
 	return "system_prompts"
 }
 
+// SystemPromptRevision is the content a system prompt had before an update.
+// AuthoredBy wrote that content; ReplacedBy made the update that archived it.
+type SystemPromptRevision struct {
+	ID            uuid.UUID  `json:"id"`
+	PromptType    string     `json:"prompt_type"`
+	PromptContent string     `json:"prompt_content"`
+	AuthoredBy    *uuid.UUID `json:"authored_by"`
+	ReplacedBy    *uuid.UUID `json:"replaced_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
 // UpdateSystemPromptInput represents the input for updating a system prompt
 type UpdateSystemPromptInput struct {
-	PromptType    string `json:"prompt_type" validate:"required,oneof=song_concept song_selector image_concept"`
+	PromptType    string `json:"prompt_type" validate:"required,oneof=song_concept song_selector image_concept image_selector"`
 	PromptContent string `json:"prompt_content" validate:"required,min=100,max=15000"`
 }
 
 // SystemPromptsResponse represents all system prompts
 type SystemPromptsResponse struct {
-	SongConcept  SystemPrompt `json:"song_concept"`
-	SongSelector SystemPrompt `json:"song_selector"`
-	ImageConcept SystemPrompt `json:"image_concept"`
+	SongConcept   SystemPrompt `json:"song_concept"`
+	SongSelector  SystemPrompt `json:"song_selector"`
+	ImageConcept  SystemPrompt `json:"image_concept"`
+	ImageSelector SystemPrompt `json:"image_selector"`
 }
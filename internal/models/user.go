@@ -6,22 +6,50 @@ import (
 	"github.com/google/uuid"
 )
 
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// IsValidRole returns true if role is a known user role.
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleAdmin
+}
+
 // User represents a user in the system
 type User struct {
-	ID                 uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	Email              string    `json:"email" gorm:"uniqueIndex;not null"`
-	PasswordHash       string    `json:"-" gorm:"not null"`
-	Name               *string   `json:"name"`
-	Role               string    `json:"role" gorm:"default:'user';not null"` // 'user' or 'admin'
-	OpenRouterModel    string    `json:"openrouter_model" gorm:"default:''"`
-	OpenRouterAPIKey   *string   `json:"-"` // Encrypted, never expose in JSON
-	KIEAPIKey          *string   `json:"-"` // Encrypted, never expose in JSON
-	SongConceptPrompt   *string   `json:"-" gorm:"column:song_concept_prompt"`  // Custom system prompt
-	SongSelectorPrompt  *string   `json:"-" gorm:"column:song_selector_prompt"` // Custom system prompt
-	ImageConceptPrompt  *string   `json:"-" gorm:"column:image_concept_prompt"` // Custom system prompt
-	YouTubeRefreshToken *string   `json:"-"`                                    // Encrypted, never expose in JSON
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `json:"updated_at"`
+	ID                  uuid.UUID   `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Email               string      `json:"email" gorm:"uniqueIndex;not null"`
+	PasswordHash        string      `json:"-" gorm:"not null"`
+	Name                *string     `json:"name"`
+	Role                string      `json:"role" gorm:"default:'user';not null"` // 'user' or 'admin'
+	OpenRouterModel     string      `json:"openrouter_model" gorm:"default:''"`
+	SongConceptModel    *string     `json:"song_concept_model"`                    // Overrides OpenRouterModel for the song concept agent
+	SongSelectorModel   *string     `json:"song_selector_model"`                   // Overrides OpenRouterModel for the song selector agent
+	ImageConceptModel   *string     `json:"image_concept_model"`                   // Overrides OpenRouterModel for the image concept agent
+	DefaultSunoModel    *string     `json:"default_suno_model"`                    // Suno model for jobs that don't choose one; nil uses V5
+	OpenRouterAPIKey    *string     `json:"-"`                                     // Encrypted, never expose in JSON
+	KIEAPIKey           *string     `json:"-"`                                     // Encrypted, never expose in JSON
+	HasOpenRouterKey    bool        `json:"-"`                                     // OpenRouterAPIKey is set; maintained by the database
+	HasKIEKey           bool        `json:"-"`                                     // KIEAPIKey is set; maintained by the database
+	SongConceptPrompt   *string     `json:"-" gorm:"column:song_concept_prompt"`   // Custom system prompt
+	SongSelectorPrompt  *string     `json:"-" gorm:"column:song_selector_prompt"`  // Custom system prompt
+	ImageConceptPrompt  *string     `json:"-" gorm:"column:image_concept_prompt"`  // Custom system prompt
+	ImageSelectorPrompt *string     `json:"-" gorm:"column:image_selector_prompt"` // Custom system prompt
+	YouTubeRefreshToken *string     `json:"-"`                                     // Encrypted, never expose in JSON
+	NotifyEmail         bool        `json:"notify_email"`                          // Email the user when their jobs complete or fail
+	Locale              *string     `json:"locale"`                                // Preferred language of API messages ("en" or "th"); nil uses Accept-Language
+	JobDefaults         JobDefaults `json:"-"`                                     // Settings for new jobs that leave them unset
+	Disabled            bool        `json:"disabled"`                              // Disabled by an admin; cannot log in
+	DeletedAt           *time.Time  `json:"-"`                                     // Set when the account is deleted; data cleanup runs async
+	OrgID               *uuid.UUID  `json:"org_id"`                                // Organization the user belongs to; nil for personal accounts
+	CreatedAt           time.Time   `json:"created_at"`
+	UpdatedAt           time.Time   `json:"updated_at"`
+
+	// OpenRouterMonthlyTokenLimit stops new jobs once the user's LLM calls used this
+	// many OpenRouter tokens in the calendar month (UTC); nil is unlimited.
+	OpenRouterMonthlyTokenLimit *int64 `json:"openrouter_monthly_token_limit"`
 }
 
 // CreateUserInput represents the input for user registration
@@ -29,6 +57,30 @@ type CreateUserInput struct {
 	Email    string  `json:"email" validate:"required,email"`
 	Password string  `json:"password" validate:"required,min=8"`
 	Name     *string `json:"name"`
+	// CaptchaToken is the Turnstile token solved by the client; required when captcha is enabled.
+	CaptchaToken string `json:"captcha_token,omitempty"`
+}
+
+// DeleteAccountInput represents the input for deleting the caller's account
+type DeleteAccountInput struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// ChangePasswordInput represents the input for changing the caller's password
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8"`
+}
+
+// ForgotPasswordInput represents the input for requesting a password reset email
+type ForgotPasswordInput struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordInput represents the input for resetting a password with a reset token
+type ResetPasswordInput struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
 }
 
 // LoginInput represents the input for user login
@@ -41,12 +93,24 @@ type LoginInput struct {
 type UpdateUserInput struct {
 	Name            *string `json:"name"`
 	OpenRouterModel *string `json:"openrouter_model"`
+	// Per-agent overrides; an empty string clears the override
+	SongConceptModel  *string `json:"song_concept_model"`
+	SongSelectorModel *string `json:"song_selector_model"`
+	ImageConceptModel *string `json:"image_concept_model"`
+	DefaultSunoModel  *string `json:"default_suno_model"` // One of the Suno models (e.g. "V5"); an empty string clears it
+	NotifyEmail       *bool   `json:"notify_email"`       // Opt in to job completion/failure emails
+	Locale            *string `json:"locale"`             // "en" or "th"; an empty string falls back to Accept-Language
+	// JobDefaults replaces the user's job defaults; {} clears them
+	JobDefaults *JobDefaults `json:"job_defaults"`
+	// OpenRouterMonthlyTokenLimit sets the monthly OpenRouter token budget; 0 removes it
+	OpenRouterMonthlyTokenLimit *int64 `json:"openrouter_monthly_token_limit"`
 }
 
 // UpdateAPIKeysInput represents the input for updating user API keys
 type UpdateAPIKeysInput struct {
 	OpenRouterAPIKey *string `json:"openrouter_api_key"`
 	KIEAPIKey        *string `json:"kie_api_key"`
+	Validate         bool    `json:"validate"` // Check new keys against their providers before saving
 }
 
 // APIKeysStatusResponse represents the API keys status (not actual keys)
@@ -58,28 +122,150 @@ type APIKeysStatusResponse struct {
 
 // UserResponse represents the user data returned in API responses
 type UserResponse struct {
-	ID              uuid.UUID `json:"id"`
-	Email           string    `json:"email"`
-	Name            *string   `json:"name"`
-	Role            string    `json:"role"`
-	OpenRouterModel string    `json:"openrouter_model"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID                uuid.UUID   `json:"id"`
+	Email             string      `json:"email"`
+	Name              *string     `json:"name"`
+	Role              string      `json:"role"`
+	OpenRouterModel   string      `json:"openrouter_model"`
+	SongConceptModel  *string     `json:"song_concept_model"`
+	SongSelectorModel *string     `json:"song_selector_model"`
+	ImageConceptModel *string     `json:"image_concept_model"`
+	DefaultSunoModel  *string     `json:"default_suno_model"`
+	NotifyEmail       bool        `json:"notify_email"`
+	Locale            *string     `json:"locale"`
+	JobDefaults       JobDefaults `json:"job_defaults"`
+	OrgID             *uuid.UUID  `json:"org_id,omitempty"`
+	CreatedAt         time.Time   `json:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at"`
+
+	OpenRouterMonthlyTokenLimit *int64 `json:"openrouter_monthly_token_limit"`
+	// Maintenance is the admins' maintenance banner; set only by GET /auth/me
+	Maintenance *MaintenanceNotice `json:"maintenance,omitempty"`
+}
+
+// JobDefaults are a user's settings for new jobs. They fill what the request and
+// its template leave unset; the server defaults apply to whatever is still unset.
+type JobDefaults struct {
+	ImageCandidates *int          `json:"image_candidates,omitempty"`
+	AspectRatio     *string       `json:"aspect_ratio,omitempty"`
+	VideoOptions    *VideoOptions `json:"video_options,omitempty"`
+}
+
+// Apply fills the fields of input left unset from the defaults. Video options
+// are merged field by field, so a request setting only the preset keeps the
+// user's fade-out.
+func (d JobDefaults) Apply(input CreateJobInput) CreateJobInput {
+	if input.ImageCandidates == nil {
+		input.ImageCandidates = d.ImageCandidates
+	}
+	if input.AspectRatio == nil {
+		input.AspectRatio = d.AspectRatio
+	}
+	if d.VideoOptions != nil {
+		if input.VideoOptions == nil {
+			opts := *d.VideoOptions
+			input.VideoOptions = &opts
+		} else {
+			opts := *input.VideoOptions
+			if opts.Preset == nil {
+				opts.Preset = d.VideoOptions.Preset
+			}
+			if opts.NormalizeAudio == nil {
+				opts.NormalizeAudio = d.VideoOptions.NormalizeAudio
+			}
+			if opts.FadeOutSeconds == nil {
+				opts.FadeOutSeconds = d.VideoOptions.FadeOutSeconds
+			}
+			input.VideoOptions = &opts
+		}
+	}
+	return input
+}
+
+// IsDeleted returns true if the account has been deleted.
+func (u *User) IsDeleted() bool {
+	return u.DeletedAt != nil
+}
+
+// IsAdmin returns true if the user has the admin role.
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
 }
 
 // ToResponse converts a User to UserResponse (excludes sensitive data)
 func (u *User) ToResponse() UserResponse {
 	return UserResponse{
-		ID:              u.ID,
-		Email:           u.Email,
-		Name:            u.Name,
-		Role:            u.Role,
-		OpenRouterModel: u.OpenRouterModel,
-		CreatedAt:       u.CreatedAt,
-		UpdatedAt:       u.UpdatedAt,
+		ID:                u.ID,
+		Email:             u.Email,
+		Name:              u.Name,
+		Role:              u.Role,
+		OpenRouterModel:   u.OpenRouterModel,
+		SongConceptModel:  u.SongConceptModel,
+		SongSelectorModel: u.SongSelectorModel,
+		ImageConceptModel: u.ImageConceptModel,
+		DefaultSunoModel:  u.DefaultSunoModel,
+		NotifyEmail:       u.NotifyEmail,
+		Locale:            u.Locale,
+		JobDefaults:       u.JobDefaults,
+		OrgID:             u.OrgID,
+		CreatedAt:         u.CreatedAt,
+		UpdatedAt:         u.UpdatedAt,
+
+		OpenRouterMonthlyTokenLimit: u.OpenRouterMonthlyTokenLimit,
 	}
 }
 
+// AgentModel returns the user's model override for the given prompt type, or nil if unset.
+func (u *User) AgentModel(promptType string) *string {
+	switch promptType {
+	case PromptTypeSongConcept:
+		return u.SongConceptModel
+	case PromptTypeSongSelector:
+		return u.SongSelectorModel
+	case PromptTypeImageConcept:
+		return u.ImageConceptModel
+	}
+	return nil
+}
+
+// UserFilter narrows an admin user listing.
+type UserFilter struct {
+	Query string // case-insensitive substring match on email
+}
+
+// AdminUserResponse represents a user as seen by admins
+type AdminUserResponse struct {
+	UserResponse
+	Disabled  bool             `json:"disabled"`
+	JobCounts map[string]int64 `json:"job_counts,omitempty"` // Jobs per status; only on single-user lookups
+	Spend     *SpendSummary    `json:"spend,omitempty"`      // Estimated KIE credits this calendar month
+}
+
+// ToAdminResponse converts a User to AdminUserResponse
+func (u *User) ToAdminResponse() AdminUserResponse {
+	return AdminUserResponse{
+		UserResponse: u.ToResponse(),
+		Disabled:     u.Disabled,
+	}
+}
+
+// UpdateUserAdminInput represents an admin update of a user's role, disabled flag
+// or OpenRouter token budget
+type UpdateUserAdminInput struct {
+	Role     *string `json:"role" validate:"omitempty,oneof=user admin"`
+	Disabled *bool   `json:"disabled"`
+	// OpenRouterMonthlyTokenLimit sets the user's monthly token budget; 0 removes it
+	OpenRouterMonthlyTokenLimit *int64 `json:"openrouter_monthly_token_limit"`
+}
+
+// UserSecrets holds a user's encrypted secrets, used when re-encrypting them with a new key
+type UserSecrets struct {
+	UserID              uuid.UUID
+	OpenRouterAPIKey    *string
+	KIEAPIKey           *string
+	YouTubeRefreshToken *string
+}
+
 // TableName specifies the table name for GORM
 func (User) TableName() string {
 	return "users"
@@ -87,26 +273,65 @@ func (User) TableName() string {
 
 // AgentPromptsResponse represents the user's custom prompts and defaults
 type AgentPromptsResponse struct {
-	Prompts  AgentPrompts        `json:"prompts"`
-	Defaults AgentDefaultPrompts `json:"defaults"`
+	Prompts       AgentPrompts                `json:"prompts"`
+	Defaults      AgentDefaultPrompts         `json:"defaults"`
+	Params        map[string]GenerationParams `json:"params"`         // The user's overrides, keyed by agent type
+	DefaultParams map[string]GenerationParams `json:"default_params"` // Keyed by agent type
 }
 
 // AgentPrompts contains the user's custom prompts (nullable)
 type AgentPrompts struct {
-	SongConceptPrompt  *string `json:"song_concept_prompt"`
-	SongSelectorPrompt *string `json:"song_selector_prompt"`
-	ImageConceptPrompt *string `json:"image_concept_prompt"`
+	SongConceptPrompt   *string `json:"song_concept_prompt"`
+	SongSelectorPrompt  *string `json:"song_selector_prompt"`
+	ImageConceptPrompt  *string `json:"image_concept_prompt"`
+	ImageSelectorPrompt *string `json:"image_selector_prompt"`
+}
+
+// GenerationParams are the LLM sampling parameters of one agent. Nil fields use
+// the agent's default.
+type GenerationParams struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+}
+
+// IsEmpty returns true if no parameter is set.
+func (p GenerationParams) IsEmpty() bool {
+	return p.Temperature == nil && p.MaxTokens == nil
+}
+
+// ForType returns the custom prompt for the given prompt type, or nil if unset.
+func (p *AgentPrompts) ForType(promptType string) *string {
+	switch promptType {
+	case PromptTypeSongConcept:
+		return p.SongConceptPrompt
+	case PromptTypeSongSelector:
+		return p.SongSelectorPrompt
+	case PromptTypeImageConcept:
+		return p.ImageConceptPrompt
+	case PromptTypeImageSelector:
+		return p.ImageSelectorPrompt
+	}
+	return nil
 }
 
 // AgentDefaultPrompts contains the default system prompts
 type AgentDefaultPrompts struct {
-	SongConcept  string `json:"song_concept"`
-	SongSelector string `json:"song_selector"`
-	ImageConcept string `json:"image_concept"`
+	SongConcept   string `json:"song_concept"`
+	SongSelector  string `json:"song_selector"`
+	ImageConcept  string `json:"image_concept"`
+	ImageSelector string `json:"image_selector"`
 }
 
 // UpdateAgentPromptInput represents the input for updating a single agent prompt
 type UpdateAgentPromptInput struct {
-	AgentType string  `json:"agent_type" validate:"required,oneof=song_concept song_selector image_concept"`
+	AgentType string  `json:"agent_type" validate:"required,oneof=song_concept song_selector image_concept image_selector"`
 	Prompt    *string `json:"prompt"` // nil = reset to default
 }
+
+// UpdateAgentParamsInput represents the input for overriding one agent's generation
+// parameters. Omitted fields use the agent's default; omitting both resets it.
+type UpdateAgentParamsInput struct {
+	AgentType   string   `json:"agent_type" validate:"required,oneof=song_concept song_selector image_concept image_selector"`
+	Temperature *float64 `json:"temperature"` // 0-2
+	MaxTokens   *int     `json:"max_tokens"`  // 1-8000
+}
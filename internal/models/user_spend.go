@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Generation kinds recorded in user spend. SpendKindLLM entries record the
+// OpenRouter tokens of one LLM call and no KIE credits.
+const (
+	SpendKindMusic = "music"
+	SpendKindImage = "image"
+	SpendKindLLM   = "llm"
+)
+
+// UserSpend is the estimated KIE credit cost of one generation task, or the
+// OpenRouter tokens of one LLM call.
+type UserSpend struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	JobID     *uuid.UUID `json:"job_id,omitempty" db:"job_id"`
+	Kind      string     `json:"kind" db:"kind"`             // SpendKindMusic or SpendKindImage
+	KeySource string     `json:"key_source" db:"key_source"` // KeySourceUser or KeySourcePlatform
+	Credits   int        `json:"credits" db:"credits"`
+	Tokens    int        `json:"tokens" db:"tokens"` // OpenRouter tokens (prompt and completion); SpendKindLLM only
+	// ProviderTaskID is the KIE task the credits were spent on; a task's spend is recorded once.
+	ProviderTaskID *string   `json:"provider_task_id,omitempty" db:"provider_task_id"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// SpendSummary totals a user's estimated KIE credits and OpenRouter tokens since
+// the start of a period.
+type SpendSummary struct {
+	Since              time.Time `json:"since"`
+	KIECredits         int       `json:"kie_credits"`          // Every generation, on any key
+	PlatformKIECredits int       `json:"platform_kie_credits"` // Generations on the platform key; counted against the monthly cap
+	OpenRouterTokens   int64     `json:"openrouter_tokens"`    // Every LLM call, on any key; counted against the user's token budget
+}
+
+// SpendPeriodStart returns the start of the spend period containing t: the first
+// day of its month, in UTC.
+func SpendPeriodStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// SpendPeriodEnd returns the start of the spend period after the one containing t,
+// when monthly caps and budgets reset.
+func SpendPeriodEnd(t time.Time) time.Time {
+	return SpendPeriodStart(t).AddDate(0, 1, 0)
+}
+
+// PlatformQuotaReservation reserves platform key usage for new jobs, so that it
+// counts against the user's quotas before the jobs run.
+type PlatformQuotaReservation struct {
+	ID             uuid.UUID `json:"id"`
+	UserID         uuid.UUID `json:"user_id"`
+	OpenRouterJobs int       `json:"openrouter_jobs"` // Jobs on the platform OpenRouter key
+	KIECredits     int       `json:"kie_credits"`     // Estimated KIE credits of jobs on the platform KIE key
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// PlatformQuotaLimits are the quotas a reservation is checked against.
+type PlatformQuotaLimits struct {
+	OpenRouterJobs  int       // Platform OpenRouter jobs allowed since OpenRouterSince
+	OpenRouterSince time.Time // Start of the rolling daily window
+	KIECredits      int       // Platform KIE credits allowed since KIESince
+	KIESince        time.Time // Start of the spend period
+}
+
+// PlatformQuotaUsage is a user's platform key usage before a reservation.
+type PlatformQuotaUsage struct {
+	OpenRouterJobs int
+	// KIECredits is the larger of the recorded platform spend and the reserved
+	// credits, so jobs that have not recorded their spend yet count at their estimate
+	KIECredits int
+}
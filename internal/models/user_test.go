@@ -0,0 +1,164 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// TestJobSettingsPrecedence checks the layers of a new job's settings: the
+// request wins over its template, the template over the user's job defaults,
+// and the server defaults apply to whatever is still unset.
+func TestJobSettingsPrecedence(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	strPtr := func(v string) *string { return &v }
+	boolPtr := func(v bool) *bool { return &v }
+
+	template := &JobTemplate{ImageCandidates: intPtr(3), AspectRatio: strPtr("9:16")}
+	defaults := JobDefaults{
+		ImageCandidates: intPtr(2),
+		AspectRatio:     strPtr("1:1"),
+		VideoOptions:    &VideoOptions{Preset: strPtr("small"), NormalizeAudio: boolPtr(false), FadeOutSeconds: intPtr(5)},
+	}
+
+	tests := []struct {
+		name            string
+		request         CreateJobInput
+		template        *JobTemplate
+		defaults        JobDefaults
+		wantCandidates  *int
+		wantAspectRatio *string
+		wantPreset      string
+		wantNormalize   bool
+		wantFadeOut     time.Duration
+	}{
+		{
+			name:          "server defaults only",
+			wantNormalize: true,
+			wantFadeOut:   DefaultFadeOutSeconds * time.Second,
+		},
+		{
+			name:            "user defaults",
+			defaults:        defaults,
+			wantCandidates:  intPtr(2),
+			wantAspectRatio: strPtr("1:1"),
+			wantPreset:      "small",
+			wantFadeOut:     5 * time.Second,
+		},
+		{
+			name:            "template over user defaults",
+			template:        template,
+			defaults:        defaults,
+			wantCandidates:  intPtr(3),
+			wantAspectRatio: strPtr("9:16"),
+			wantPreset:      "small",
+			wantFadeOut:     5 * time.Second,
+		},
+		{
+			name:            "request over template",
+			request:         CreateJobInput{ImageCandidates: intPtr(4), AspectRatio: strPtr("16:9")},
+			template:        template,
+			defaults:        defaults,
+			wantCandidates:  intPtr(4),
+			wantAspectRatio: strPtr("16:9"),
+			wantPreset:      "small",
+			wantFadeOut:     5 * time.Second,
+		},
+		{
+			name:            "template without user defaults",
+			template:        template,
+			wantCandidates:  intPtr(3),
+			wantAspectRatio: strPtr("9:16"),
+			wantNormalize:   true,
+			wantFadeOut:     DefaultFadeOutSeconds * time.Second,
+		},
+		{
+			// Video options merge by field: the request's preset, the user's fade-out
+			name:            "request video options over user defaults by field",
+			request:         CreateJobInput{VideoOptions: &VideoOptions{Preset: strPtr("high"), NormalizeAudio: boolPtr(true)}},
+			defaults:        defaults,
+			wantCandidates:  intPtr(2),
+			wantAspectRatio: strPtr("1:1"),
+			wantPreset:      "high",
+			wantNormalize:   true,
+			wantFadeOut:     5 * time.Second,
+		},
+		{
+			// A zero fade-out disables the fade rather than falling through
+			name:            "zero fade-out in the request",
+			request:         CreateJobInput{VideoOptions: &VideoOptions{FadeOutSeconds: intPtr(0)}},
+			defaults:        defaults,
+			wantCandidates:  intPtr(2),
+			wantAspectRatio: strPtr("1:1"),
+			wantPreset:      "small",
+		},
+		{
+			name:          "request video options without user defaults",
+			request:       CreateJobInput{VideoOptions: &VideoOptions{FadeOutSeconds: intPtr(8)}},
+			wantNormalize: true,
+			wantFadeOut:   8 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.request
+			if tt.template != nil {
+				input = tt.template.Apply(input)
+			}
+			input = tt.defaults.Apply(input)
+
+			if !equalPtr(input.ImageCandidates, tt.wantCandidates) {
+				t.Errorf("image candidates = %v, want %v", deref(input.ImageCandidates), deref(tt.wantCandidates))
+			}
+			if !equalPtr(input.AspectRatio, tt.wantAspectRatio) {
+				t.Errorf("aspect ratio = %v, want %v", deref(input.AspectRatio), deref(tt.wantAspectRatio))
+			}
+			if got := input.VideoOptions.PresetName(); got != tt.wantPreset {
+				t.Errorf("preset = %q, want %q", got, tt.wantPreset)
+			}
+			if got := input.VideoOptions.ShouldNormalizeAudio(); got != tt.wantNormalize {
+				t.Errorf("normalize audio = %v, want %v", got, tt.wantNormalize)
+			}
+			if got := input.VideoOptions.FadeOut(); got != tt.wantFadeOut {
+				t.Errorf("fade-out = %v, want %v", got, tt.wantFadeOut)
+			}
+		})
+	}
+}
+
+// TestJobDefaultsApplyCopiesVideoOptions checks that a job never shares its
+// video options with the user's defaults, so changing one leaves the other.
+func TestJobDefaultsApplyCopiesVideoOptions(t *testing.T) {
+	fadeOut := 5
+	defaults := JobDefaults{VideoOptions: &VideoOptions{FadeOutSeconds: &fadeOut}}
+
+	input := defaults.Apply(CreateJobInput{})
+	if input.VideoOptions == defaults.VideoOptions {
+		t.Fatal("Apply() shared the defaults' video options with the input")
+	}
+	preset := "high"
+	input.VideoOptions.Preset = &preset
+	if defaults.VideoOptions.Preset != nil {
+		t.Error("changing the job's video options changed the user's defaults")
+	}
+
+	request := &VideoOptions{}
+	input = defaults.Apply(CreateJobInput{VideoOptions: request})
+	if input.VideoOptions == request || request.FadeOutSeconds != nil {
+		t.Error("Apply() filled the request's own video options in place")
+	}
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func deref[T any](p *T) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// User webhook events.
+const (
+	WebhookEventJobCompleted = "job.completed"
+	WebhookEventJobFailed    = "job.failed"
+)
+
+// UserWebhookEvents lists every event a user webhook can subscribe to.
+var UserWebhookEvents = []string{WebhookEventJobCompleted, WebhookEventJobFailed}
+
+// MaxUserWebhooksPerUser caps how many webhooks a single user can register.
+const MaxUserWebhooksPerUser = 5
+
+// UserWebhook is an endpoint of the user's own system that is notified when
+// their jobs finish. Payloads are signed with Secret (HMAC-SHA256).
+type UserWebhook struct {
+	ID      uuid.UUID `json:"id" db:"id"`
+	UserID  uuid.UUID `json:"user_id" db:"user_id"`
+	URL     string    `json:"url" db:"url"`
+	Secret  string    `json:"-" db:"secret"` // encrypted; only returned once on creation
+	Events  []string  `json:"events" db:"events"`
+	Enabled bool      `json:"enabled" db:"enabled"`
+	// Delivery statistics, updated after every delivery attempt
+	DeliveryCount  int        `json:"delivery_count" db:"delivery_count"`
+	FailureCount   int        `json:"failure_count" db:"failure_count"`
+	LastDeliveryAt *time.Time `json:"last_delivery_at,omitempty" db:"last_delivery_at"`
+	LastStatusCode *int       `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      *string    `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Subscribes reports whether the webhook is enabled and receives event.
+func (w *UserWebhook) Subscribes(event string) bool {
+	if !w.Enabled {
+		return false
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// UserWebhookInput is the request body for creating or replacing a user webhook.
+type UserWebhookInput struct {
+	URL     string   `json:"url"`
+	Events  []string `json:"events,omitempty"`  // empty = all events
+	Enabled *bool    `json:"enabled,omitempty"` // nil = enabled
+}
+
+// CreatedUserWebhook is returned when a webhook is created; it is the only
+// response that includes the signing secret.
+type CreatedUserWebhook struct {
+	*UserWebhook
+	Secret string `json:"secret"`
+}
+
+// UserWebhookPayload is the JSON body POSTed to a user webhook.
+type UserWebhookPayload struct {
+	Event        string    `json:"event"`
+	JobID        uuid.UUID `json:"job_id"`
+	Status       string    `json:"status"`
+	VideoURL     *string   `json:"video_url,omitempty"`
+	ErrorMessage *string   `json:"error_message,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// JobWebhookEvent returns the user webhook event for a job status, or "" if the
+// status does not notify (only completed and failed jobs do).
+func JobWebhookEvent(status string) string {
+	switch status {
+	case StatusCompleted:
+		return WebhookEventJobCompleted
+	case StatusFailed:
+		return WebhookEventJobFailed
+	}
+	return ""
+}
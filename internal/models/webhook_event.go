@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook event kinds.
+const (
+	// WebhookEventCapture is a raw callback stored for debugging when capture is enabled.
+	WebhookEventCapture = "capture"
+	// WebhookEventRetry is a parsed callback whose processing failed transiently;
+	// the worker re-applies it and sets ProcessedAt once it succeeds.
+	WebhookEventRetry = "retry"
+)
+
+// WebhookEvent is a stored webhook callback.
+type WebhookEvent struct {
+	ID            uuid.UUID         `json:"id"`
+	Kind          string            `json:"kind"`
+	Source        string            `json:"source"`
+	JobID         *uuid.UUID        `json:"job_id,omitempty"`
+	StatusCode    int               `json:"status_code"`
+	Headers       map[string]string `json:"headers"`
+	Body          string            `json:"body"`
+	BodyTruncated bool              `json:"body_truncated"`
+	ParseError    *string           `json:"parse_error,omitempty"`
+	ProcessedAt   *time.Time        `json:"processed_at,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/database"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// AuditLogRepository stores the audit log.
+type AuditLogRepository interface {
+	Create(ctx context.Context, entry *models.AuditLog) error
+	List(ctx context.Context, filter models.AuditLogFilter, page, perPage int) ([]*models.AuditLog, int64, error)
+}
+
+type auditLogRepository struct {
+	db *database.DB
+}
+
+// NewAuditLogRepository creates a new AuditLogRepository instance.
+func NewAuditLogRepository(db *database.DB) AuditLogRepository {
+	return &auditLogRepository{db: db}
+}
+
+// Create inserts an audit log entry.
+func (r *auditLogRepository) Create(ctx context.Context, entry *models.AuditLog) error {
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	if entry.Metadata == nil {
+		entry.Metadata = map[string]interface{}{}
+	}
+
+	metadata, err := json.Marshal(entry.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit log metadata: %w", err)
+	}
+
+	query := `
+		INSERT INTO audit_logs (id, user_id, action, target, metadata, ip)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at
+	`
+
+	err = r.db.Pool().QueryRow(ctx, query,
+		entry.ID, entry.UserID, entry.Action, entry.Target, metadata, entry.IP,
+	).Scan(&entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	return nil
+}
+
+// List returns a page of audit log entries matching filter, newest first, and the total count.
+func (r *auditLogRepository) List(ctx context.Context, filter models.AuditLogFilter, page, perPage int) ([]*models.AuditLog, int64, error) {
+	where := `WHERE TRUE`
+	args := []interface{}{}
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		where += fmt.Sprintf(` AND user_id = $%d`, len(args))
+	}
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		where += fmt.Sprintf(` AND action = $%d`, len(args))
+	}
+	if filter.CreatedAfter != nil {
+		args = append(args, *filter.CreatedAfter)
+		where += fmt.Sprintf(` AND created_at >= $%d`, len(args))
+	}
+	if filter.CreatedBefore != nil {
+		args = append(args, *filter.CreatedBefore)
+		where += fmt.Sprintf(` AND created_at < $%d`, len(args))
+	}
+
+	var total int64
+	if err := r.db.Pool().QueryRow(ctx, `SELECT COUNT(*) FROM audit_logs `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
+	}
+
+	args = append(args, perPage, (page-1)*perPage)
+	query := fmt.Sprintf(`
+		SELECT id, user_id, action, target, metadata, ip, created_at
+		FROM audit_logs
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args))
+
+	rows, err := r.db.Pool().Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*models.AuditLog, 0)
+	for rows.Next() {
+		entry := &models.AuditLog{}
+		var metadata []byte
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.UserID,
+			&entry.Action,
+			&entry.Target,
+			&metadata,
+			&entry.IP,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		if err := json.Unmarshal(metadata, &entry.Metadata); err != nil {
+			return nil, 0, fmt.Errorf("failed to unmarshal audit log metadata: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating audit logs: %w", err)
+	}
+
+	return entries, total, nil
+}
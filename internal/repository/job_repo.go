@@ -6,10 +6,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/jaochai/ugc/internal/database"
 	"github.com/jaochai/ugc/internal/models"
@@ -18,29 +22,102 @@ import (
 // ErrJobNotFound is returned when a job is not found.
 var ErrJobNotFound = errors.New("job not found")
 
+// ErrJobCancelled is returned when a write targets a job that was cancelled by the user.
+var ErrJobCancelled = errors.New("job cancelled")
+
 // ErrStatusConflict is returned when a concurrent modification is detected.
 var ErrStatusConflict = errors.New("job status conflict: concurrent modification detected")
 
+// ErrInvalidStatusTransition is returned when a write would move a job between two
+// statuses the pipeline does not connect (see models.CanTransition).
+var ErrInvalidStatusTransition = errors.New("invalid job status transition")
+
+// jobColumns are the columns scanJob reads, in order.
+const jobColumns = `id, user_id, status, concept, llm_model,
+	song_prompt, suno_task_id, generated_songs, selected_song_id,
+	image_prompt, nano_task_id, audio_url, image_url, video_url,
+	youtube_url, youtube_video_id, youtube_error,
+	image_candidates, generated_images,
+	error_message, cancelled_at, created_at, updated_at, version,
+	video_key, audio_key, image_key, aspect_ratio, agent_models, prompt_overrides, share_token, shared_at,
+	image_source, source_image_url, video_options, thumbnail_key, openrouter_key_source, kie_key_source, agent_outputs,
+	stage_timings, suno_model, error_code, retry_from, video_metadata, tags, deleted_at, org_id, image_sanitize_attempts,
+	keep_all_tracks, tracks, max_duration_seconds, callback_mode`
+
+// jobListItemColumns are the columns ListItemsByUserID reads: summary fields only,
+// with the title extracted from song_prompt server-side.
+const jobListItemColumns = `id, status, LEFT(concept, 256), song_prompt->>'title',
+	video_url, video_key, thumbnail_key, tags, created_at, updated_at, deleted_at`
+
+// checkTransition returns ErrInvalidStatusTransition unless a job may move from
+// status from to status to.
+func checkTransition(from, to string) error {
+	if !models.CanTransition(from, to) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, from, to)
+	}
+	return nil
+}
+
 // JobRepository defines the interface for job data access.
 type JobRepository interface {
 	Create(ctx context.Context, job *models.Job) error
+	CreateBatch(ctx context.Context, jobs []*models.Job) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error)
-	GetByUserID(ctx context.Context, userID uuid.UUID, page, perPage int) ([]*models.Job, int64, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, filter models.JobFilter, page, perPage int) ([]*models.Job, int64, error)
+	ListItemsByUserID(ctx context.Context, userID uuid.UUID, filter models.JobFilter, page, perPage int) ([]*models.JobListItem, int64, error)
+	CountByStatus(ctx context.Context) (map[string]int64, error)
+	CountByStatusForUser(ctx context.Context, userID uuid.UUID) (map[string]int64, error)
+	AverageCompletionDuration(ctx context.Context, since time.Time, limit int) (time.Duration, error)
+	CountFinishedSince(ctx context.Context, since time.Time) (completed, failed int64, err error)
+	ListStaleActive(ctx context.Context, updatedBefore time.Time, afterID uuid.UUID, limit int) ([]uuid.UUID, error)
+	ListStalePending(ctx context.Context, pendingBefore time.Time, afterID uuid.UUID, limit int) ([]uuid.UUID, error)
+	FailStalePending(ctx context.Context, id uuid.UUID, pendingBefore time.Time, errorMessage string) (bool, error)
 	GetBySunoTaskID(ctx context.Context, taskID string) (*models.Job, error)
 	GetByNanoTaskID(ctx context.Context, taskID string) (*models.Job, error)
+	GetByShareToken(ctx context.Context, token string) (*models.Job, error)
+	SetShareToken(ctx context.Context, id uuid.UUID, token *string) error
+	UpdateTags(ctx context.Context, id uuid.UUID, tags []string) error
 	Update(ctx context.Context, job *models.Job) error
 	UpdateStatus(ctx context.Context, id uuid.UUID, status string) error
 	UpdateWithError(ctx context.Context, id uuid.UUID, errorMessage string) error
+	UpdateWithFailure(ctx context.Context, id uuid.UUID, failure models.JobFailure) error
+	ResetForRetry(ctx context.Context, id uuid.UUID, step, openRouterKeySource, kieKeySource string) error
+	Cancel(ctx context.Context, id uuid.UUID, errorMessage string) error
+	CancelActiveByUserID(ctx context.Context, userID uuid.UUID, errorMessage string) (int64, error)
+	ListIDsByUserID(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+	Restore(ctx context.Context, id uuid.UUID, deletedAfter time.Time) error
+	ListDeletedBefore(ctx context.Context, deletedBefore time.Time, limit int) ([]uuid.UUID, error)
 
 	// Atomic update methods — use WHERE status = expectedStatus to prevent TOCTOU races
+	TransitionStatusAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, newStatus string) error
+	StartStageAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, newStatus string, stage string, workerID string) error
+	UpdateConceptAnalysisAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, prompt *models.SongPrompt, llmModel string, extras models.JobWriteExtras) error
+	UpdateSunoTaskAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, newStatus string) error
 	UpdateSongPromptAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, prompt *models.SongPrompt, newStatus string) error
-	UpdateGeneratedSongsAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, songs []models.GeneratedSong, newStatus string) error
-	UpdateSelectedSongAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, songID string, audioURL string, newStatus string) error
-	UpdateImagePromptAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, prompt *models.ImagePrompt) error
-	UpdateImageURLAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, imageURL string, newStatus string) error
+	UpdateGeneratedSongsAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, songs []models.GeneratedSong, newStatus string, extras models.JobWriteExtras) error
+	UpdateSelectedSongAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, songID string, audioURL string, newStatus string, extras models.JobWriteExtras) error
+	UpdateImagePromptAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, prompt *models.ImagePrompt, extras models.JobWriteExtras) error
+	UpdateImageURLAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, imageURL string, newStatus string, extras models.JobWriteExtras) error
+	UpdateGeneratedImagesAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, images []models.GeneratedImage) error
+	UpdateNanoTasksAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, images []models.GeneratedImage) error
+	// RestartImageGenerationAtomic clears the image prompt and tasks of a job in
+	// generating_image and counts a sanitize attempt, if the job has made exactly
+	// expectedAttempts so far.
+	RestartImageGenerationAtomic(ctx context.Context, id uuid.UUID, expectedAttempts int) error
+	UpdateImageCandidateAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, imageURL string, candidateStatus string) ([]models.GeneratedImage, error)
 	UpdateVideoURLAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, videoURL string, newStatus string) error
+	UpdateVideoKeyAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, videoKey string, newStatus string, extras models.JobWriteExtras) error
+	SetUserImageAtomic(ctx context.Context, id uuid.UUID, expectedStatuses []string, imageKey string, imageURL string, imageSource string) error
+	ClearImageSourceAtomic(ctx context.Context, id uuid.UUID, expectedStatus string) error
+	UpdateThumbnailKey(ctx context.Context, id uuid.UUID, thumbnailKey string) error
+	UpdateTracks(ctx context.Context, id uuid.UUID, tracks []models.JobTrack) error
+	UpdateVideoMetadata(ctx context.Context, id uuid.UUID, metadata *models.VideoMetadata, extras models.JobWriteExtras) error
 	UpdateYouTubeResult(ctx context.Context, id uuid.UUID, youtubeURL, youtubeVideoID, youtubeError *string, newStatus string) error
+	RecordStageTime(ctx context.Context, id uuid.UUID, stage string, event string, at time.Time, workerID string) error
+	StageDurationStats(ctx context.Context, since time.Time) ([]models.StageDurationStats, error)
+	GetStorageStates(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]models.JobStorageState, error)
 }
 
 // jobRepository implements JobRepository using PostgreSQL.
@@ -55,6 +132,33 @@ func NewJobRepository(db *database.DB) JobRepository {
 
 // Create inserts a new job into the database.
 func (r *jobRepository) Create(ctx context.Context, job *models.Job) error {
+	return insertJob(ctx, r.db.Pool(), job)
+}
+
+// CreateBatch inserts several jobs in a single transaction; either all jobs are
+// created or none are.
+func (r *jobRepository) CreateBatch(ctx context.Context, jobs []*models.Job) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		for _, job := range jobs {
+			if err := insertJob(ctx, tx, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// jobExecer is the subset of pgxpool.Pool and pgx.Tx used to insert jobs.
+type jobExecer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// insertJob inserts a job using the given executor, which may be the pool or a transaction.
+func insertJob(ctx context.Context, exec jobExecer, job *models.Job) error {
 	songPromptJSON, err := marshalJSONB(job.SongPrompt)
 	if err != nil {
 		return fmt.Errorf("failed to marshal song_prompt: %w", err)
@@ -70,19 +174,44 @@ func (r *jobRepository) Create(ctx context.Context, job *models.Job) error {
 		return fmt.Errorf("failed to marshal image_prompt: %w", err)
 	}
 
+	generatedImagesJSON, err := marshalJSONB(job.GeneratedImages)
+	if err != nil {
+		return fmt.Errorf("failed to marshal generated_images: %w", err)
+	}
+
+	promptOverridesJSON, err := marshalJSONB(job.PromptOverrides)
+	if err != nil {
+		return fmt.Errorf("failed to marshal prompt_overrides: %w", err)
+	}
+
+	videoOptionsJSON, err := marshalJSONB(job.VideoOptions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal video_options: %w", err)
+	}
+
 	query := `
 		INSERT INTO jobs (
 			id, user_id, status, concept, llm_model,
 			song_prompt, suno_task_id, generated_songs, selected_song_id,
 			image_prompt, nano_task_id, audio_url, image_url, video_url,
 			youtube_url, youtube_video_id, youtube_error,
-			error_message, created_at, updated_at
+			image_candidates, generated_images,
+			error_message, created_at, updated_at,
+			video_key, audio_key, image_key, aspect_ratio, prompt_overrides,
+			image_source, source_image_url, video_options, openrouter_key_source, kie_key_source,
+			suno_model, tags, org_id, keep_all_tracks, max_duration_seconds,
+			callback_mode
 		) VALUES (
 			$1, $2, $3, $4, $5,
 			$6, $7, $8, $9,
 			$10, $11, $12, $13, $14,
 			$15, $16, $17,
-			$18, $19, $20
+			$18, $19,
+			$20, $21, $22,
+			$23, $24, $25, $26, $27,
+			$28, $29, $30, $31, $32,
+			$33, $34, $35, $36, $37,
+			$38
 		)
 	`
 
@@ -92,8 +221,15 @@ func (r *jobRepository) Create(ctx context.Context, job *models.Job) error {
 	}
 	job.CreatedAt = now
 	job.UpdatedAt = now
+	job.Version = 1
+	if job.Tags == nil {
+		job.Tags = []string{}
+	}
+	if job.CallbackMode == "" {
+		job.CallbackMode = models.CallbackModeAuto
+	}
 
-	_, err = r.db.Pool().Exec(ctx, query,
+	_, err = exec.Exec(ctx, query,
 		job.ID,
 		job.UserID,
 		job.Status,
@@ -111,9 +247,27 @@ func (r *jobRepository) Create(ctx context.Context, job *models.Job) error {
 		job.YouTubeURL,
 		job.YouTubeVideoID,
 		job.YouTubeError,
+		job.ImageCandidates,
+		generatedImagesJSON,
 		job.ErrorMessage,
 		job.CreatedAt,
 		job.UpdatedAt,
+		job.VideoKey,
+		job.AudioKey,
+		job.ImageKey,
+		job.AspectRatio,
+		promptOverridesJSON,
+		job.ImageSource,
+		job.SourceImageURL,
+		videoOptionsJSON,
+		job.OpenRouterKeySource,
+		job.KIEKeySource,
+		job.SunoModel,
+		job.Tags,
+		job.OrgID,
+		job.KeepAllTracks,
+		job.MaxDurationSeconds,
+		job.CallbackMode,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create job: %w", err)
@@ -125,12 +279,7 @@ func (r *jobRepository) Create(ctx context.Context, job *models.Job) error {
 // GetByID retrieves a job by its ID.
 func (r *jobRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
 	query := `
-		SELECT
-			id, user_id, status, concept, llm_model,
-			song_prompt, suno_task_id, generated_songs, selected_song_id,
-			image_prompt, nano_task_id, audio_url, image_url, video_url,
-			youtube_url, youtube_video_id, youtube_error,
-			error_message, created_at, updated_at
+		SELECT ` + jobColumns + `
 		FROM jobs
 		WHERE id = $1
 	`
@@ -147,15 +296,73 @@ func (r *jobRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Job,
 	return job, nil
 }
 
+// GetByShareToken retrieves a job by its public share token. Jobs of deleted
+// accounts are not found, even before their data cleanup removes them.
+func (r *jobRepository) GetByShareToken(ctx context.Context, token string) (*models.Job, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE share_token = $1 AND deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM users WHERE users.id = jobs.user_id AND users.deleted_at IS NULL)
+	`
+
+	job, err := scanJob(r.db.Pool().QueryRow(ctx, query, token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get job by share token: %w", err)
+	}
+
+	return job, nil
+}
+
+// UpdateTags replaces a job's tags.
+func (r *jobRepository) UpdateTags(ctx context.Context, id uuid.UUID, tags []string) error {
+	query := `
+		UPDATE jobs
+		SET tags = $2, updated_at = NOW(), version = version + 1
+		WHERE id = $1
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, tags)
+	if err != nil {
+		return fmt.Errorf("failed to update tags: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// SetShareToken sets or, with a nil token, revokes a job's share link.
+func (r *jobRepository) SetShareToken(ctx context.Context, id uuid.UUID, token *string) error {
+	query := `
+		UPDATE jobs
+		SET share_token = $2,
+			shared_at = CASE WHEN $2::text IS NULL THEN NULL ELSE NOW() END,
+			updated_at = NOW(), version = version + 1
+		WHERE id = $1
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, token)
+	if err != nil {
+		return fmt.Errorf("failed to set share token: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
 // GetBySunoTaskID retrieves a job by its Suno task ID.
 func (r *jobRepository) GetBySunoTaskID(ctx context.Context, taskID string) (*models.Job, error) {
 	query := `
-		SELECT
-			id, user_id, status, concept, llm_model,
-			song_prompt, suno_task_id, generated_songs, selected_song_id,
-			image_prompt, nano_task_id, audio_url, image_url, video_url,
-			youtube_url, youtube_video_id, youtube_error,
-			error_message, created_at, updated_at
+		SELECT ` + jobColumns + `
 		FROM jobs
 		WHERE suno_task_id = $1
 	`
@@ -173,16 +380,14 @@ func (r *jobRepository) GetBySunoTaskID(ctx context.Context, taskID string) (*mo
 }
 
 // GetByNanoTaskID retrieves a job by its Nano task ID.
+// Matches either the selected nano_task_id or any image candidate task ID.
 func (r *jobRepository) GetByNanoTaskID(ctx context.Context, taskID string) (*models.Job, error) {
 	query := `
-		SELECT
-			id, user_id, status, concept, llm_model,
-			song_prompt, suno_task_id, generated_songs, selected_song_id,
-			image_prompt, nano_task_id, audio_url, image_url, video_url,
-			youtube_url, youtube_video_id, youtube_error,
-			error_message, created_at, updated_at
+		SELECT ` + jobColumns + `
 		FROM jobs
 		WHERE nano_task_id = $1
+			OR generated_images @> jsonb_build_array(jsonb_build_object('task_id', $1::text))
+		LIMIT 1
 	`
 
 	row := r.db.Pool().QueryRow(ctx, query, taskID)
@@ -197,8 +402,8 @@ func (r *jobRepository) GetByNanoTaskID(ctx context.Context, taskID string) (*mo
 	return job, nil
 }
 
-// GetByUserID retrieves jobs for a user with pagination.
-func (r *jobRepository) GetByUserID(ctx context.Context, userID uuid.UUID, page, perPage int) ([]*models.Job, int64, error) {
+// GetByUserID retrieves jobs for a user with filtering, sorting and pagination.
+func (r *jobRepository) GetByUserID(ctx context.Context, userID uuid.UUID, filter models.JobFilter, page, perPage int) ([]*models.Job, int64, error) {
 	// Calculate offset
 	if page < 1 {
 		page = 1
@@ -208,29 +413,26 @@ func (r *jobRepository) GetByUserID(ctx context.Context, userID uuid.UUID, page,
 	}
 	offset := (page - 1) * perPage
 
-	// Get total count
-	countQuery := `SELECT COUNT(*) FROM jobs WHERE user_id = $1`
+	where, args := buildJobFilter(userID, filter)
+
+	// Get total count (reflects filters)
+	countQuery := `SELECT COUNT(*) FROM jobs WHERE ` + where
 	var total int64
-	err := r.db.Pool().QueryRow(ctx, countQuery, userID).Scan(&total)
+	err := r.db.Pool().QueryRow(ctx, countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to count jobs: %w", err)
 	}
 
 	// Get jobs with pagination
-	query := `
-		SELECT
-			id, user_id, status, concept, llm_model,
-			song_prompt, suno_task_id, generated_songs, selected_song_id,
-			image_prompt, nano_task_id, audio_url, image_url, video_url,
-			youtube_url, youtube_video_id, youtube_error,
-			error_message, created_at, updated_at
+	query := fmt.Sprintf(`
+		SELECT `+jobColumns+`
 		FROM jobs
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
-	`
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+	`, where, jobOrderBy(filter), len(args)+1, len(args)+2)
 
-	rows, err := r.db.Pool().Query(ctx, query, userID, perPage, offset)
+	rows, err := r.db.Pool().Query(ctx, query, append(args, perPage, offset)...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to query jobs: %w", err)
 	}
@@ -252,7 +454,369 @@ func (r *jobRepository) GetByUserID(ctx context.Context, userID uuid.UUID, page,
 	return jobs, total, nil
 }
 
+// ListItemsByUserID retrieves lightweight job summaries for a user with filtering, sorting and pagination.
+// Only summary columns are read; the large JSONB fields (song_prompt lyrics, generated songs/images)
+// are never loaded, apart from the title extracted server-side.
+func (r *jobRepository) ListItemsByUserID(ctx context.Context, userID uuid.UUID, filter models.JobFilter, page, perPage int) ([]*models.JobListItem, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 10
+	}
+	offset := (page - 1) * perPage
+
+	where, args := buildJobFilter(userID, filter)
+
+	countQuery := `SELECT COUNT(*) FROM jobs WHERE ` + where
+	var total int64
+	if err := r.db.Pool().QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count jobs: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT `+jobListItemColumns+`
+		FROM jobs
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+	`, where, jobOrderBy(filter), len(args)+1, len(args)+2)
+
+	rows, err := r.db.Pool().Query(ctx, query, append(args, perPage, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query job list items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]*models.JobListItem, 0)
+	for rows.Next() {
+		var (
+			id                     uuid.UUID
+			status, concept        string
+			title, videoURL        *string
+			videoKey, thumbnailKey *string
+			tags                   []string
+			createdAt, updatedAt   time.Time
+			deletedAt              *time.Time
+		)
+		if err := rows.Scan(&id, &status, &concept, &title, &videoURL, &videoKey, &thumbnailKey, &tags, &createdAt, &updatedAt, &deletedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan job list item: %w", err)
+		}
+		item := models.NewJobListItem(id, status, concept, title, videoURL, videoKey, createdAt, updatedAt)
+		item.ThumbnailKey = thumbnailKey
+		item.Tags = tags
+		item.DeletedAt = deletedAt
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating job list items: %w", err)
+	}
+
+	return items, total, nil
+}
+
+// CountByStatus returns the number of jobs in each status.
+// Statuses with no jobs are absent from the result.
+func (r *jobRepository) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	rows, err := r.db.Pool().Query(ctx, `SELECT status, COUNT(*) FROM jobs GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count jobs by status: %w", err)
+	}
+	return scanStatusCounts(rows)
+}
+
+// CountByStatusForUser returns the number of jobs of userID in each status.
+func (r *jobRepository) CountByStatusForUser(ctx context.Context, userID uuid.UUID) (map[string]int64, error) {
+	rows, err := r.db.Pool().Query(ctx, `SELECT status, COUNT(*) FROM jobs WHERE user_id = $1 GROUP BY status`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count user jobs by status: %w", err)
+	}
+	return scanStatusCounts(rows)
+}
+
+// CountFinishedSince returns how many jobs completed and failed since since.
+// Finishing is approximated by updated_at, as in AverageCompletionDuration.
+func (r *jobRepository) CountFinishedSince(ctx context.Context, since time.Time) (completed, failed int64, err error) {
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE status = $1),
+			COUNT(*) FILTER (WHERE status = $2)
+		FROM jobs
+		WHERE status IN ($1, $2) AND updated_at >= $3
+	`
+
+	if err := r.db.Pool().QueryRow(ctx, query, models.StatusCompleted, models.StatusFailed, since).Scan(&completed, &failed); err != nil {
+		return 0, 0, fmt.Errorf("failed to count finished jobs: %w", err)
+	}
+	return completed, failed, nil
+}
+
+// AverageCompletionDuration returns how long the latest limit jobs completed since
+// since took from creation to completion, on average, or 0 if there are none.
+// Completion is approximated by updated_at, which later writes (e.g. sharing) can move.
+func (r *jobRepository) AverageCompletionDuration(ctx context.Context, since time.Time, limit int) (time.Duration, error) {
+	query := `
+		SELECT COALESCE(EXTRACT(EPOCH FROM AVG(updated_at - created_at)), 0)
+		FROM (
+			SELECT created_at, updated_at
+			FROM jobs
+			WHERE status = $1 AND created_at >= $2
+			ORDER BY created_at DESC
+			LIMIT $3
+		) recent
+	`
+
+	var seconds float64
+	if err := r.db.Pool().QueryRow(ctx, query, models.StatusCompleted, since, limit).Scan(&seconds); err != nil {
+		return 0, fmt.Errorf("failed to average job completion duration: %w", err)
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// scanStatusCounts reads (status, count) rows into a map and closes rows.
+func scanStatusCounts(rows pgx.Rows) (map[string]int64, error) {
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var status string
+		var count int64
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan job status count: %w", err)
+		}
+		counts[status] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating job status counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// ListStaleActive returns IDs of non-terminal jobs not updated since updatedBefore,
+// i.e. jobs whose next task may have been lost. Results are ordered by ID and start
+// after afterID (uuid.Nil for the first page), so callers can page through all of them.
+func (r *jobRepository) ListStaleActive(ctx context.Context, updatedBefore time.Time, afterID uuid.UUID, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT id FROM jobs
+		WHERE status NOT IN ($1, $2) AND cancelled_at IS NULL AND deleted_at IS NULL
+			AND updated_at < $3 AND id > $4
+		ORDER BY id
+		LIMIT $5
+	`
+
+	rows, err := r.db.Pool().Query(ctx, query, models.StatusCompleted, models.StatusFailed, updatedBefore, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stale active jobs: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan stale active job: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stale active jobs: %w", err)
+	}
+
+	return ids, nil
+}
+
+// GetStorageStates returns the status and last update of the given jobs, keyed by
+// job ID. Jobs that no longer exist are missing from the map.
+func (r *jobRepository) GetStorageStates(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]models.JobStorageState, error) {
+	states := make(map[uuid.UUID]models.JobStorageState, len(ids))
+	if len(ids) == 0 {
+		return states, nil
+	}
+
+	query := `SELECT id, status, updated_at FROM jobs WHERE id = ANY($1)`
+
+	rows, err := r.db.Pool().Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job storage states: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var state models.JobStorageState
+		if err := rows.Scan(&state.ID, &state.Status, &state.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan job storage state: %w", err)
+		}
+		states[state.ID] = state
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating job storage states: %w", err)
+	}
+
+	return states, nil
+}
+
+// ListStalePending returns IDs of jobs pending since before pendingBefore, ordered
+// by ID and starting after afterID (uuid.Nil for the first page).
+func (r *jobRepository) ListStalePending(ctx context.Context, pendingBefore time.Time, afterID uuid.UUID, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT id FROM jobs
+		WHERE status = $1 AND cancelled_at IS NULL AND updated_at < $2 AND id > $3
+		ORDER BY id
+		LIMIT $4
+	`
+
+	rows, err := r.db.Pool().Query(ctx, query, models.StatusPending, pendingBefore, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stale pending jobs: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan stale pending job: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stale pending jobs: %w", err)
+	}
+
+	return ids, nil
+}
+
+// FailStalePending marks a job failed if it is still pending since before
+// pendingBefore. Returns false when the job has started or was cancelled meanwhile.
+func (r *jobRepository) FailStalePending(ctx context.Context, id uuid.UUID, pendingBefore time.Time, errorMessage string) (bool, error) {
+	query := `
+		UPDATE jobs SET
+			status = $1,
+			error_message = $2,
+			updated_at = NOW(),
+			version = version + 1
+		WHERE id = $3 AND status = $4 AND cancelled_at IS NULL AND updated_at < $5
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, models.StatusFailed, errorMessage, id, models.StatusPending, pendingBefore)
+	if err != nil {
+		return false, fmt.Errorf("failed to fail stale pending job: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// buildJobFilter builds a parameterized WHERE clause for a user's job listing, or
+// for their organization's when filter.OrgID is set.
+// Only placeholders carry user input; the clause text is fixed.
+func buildJobFilter(userID uuid.UUID, filter models.JobFilter) (string, []interface{}) {
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{userID}
+	if filter.OrgID != nil {
+		conditions[0] = "org_id = $1"
+		args[0] = *filter.OrgID
+	}
+
+	if filter.IncludeDeleted {
+		// Jobs past the restore window are only waiting to be purged
+		args = append(args, time.Now().Add(-models.JobRestoreWindow))
+		conditions = append(conditions, fmt.Sprintf("(deleted_at IS NULL OR deleted_at > $%d)", len(args)))
+	} else {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	if len(filter.Statuses) > 0 {
+		args = append(args, filter.Statuses)
+		conditions = append(conditions, fmt.Sprintf("status = ANY($%d)", len(args)))
+	}
+	if filter.CreatedAfter != nil {
+		args = append(args, *filter.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.CreatedBefore != nil {
+		args = append(args, *filter.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if filter.Query != "" {
+		if tsQuery, ok := conceptTSQuery(filter.Query); ok {
+			args = append(args, tsQuery)
+			conditions = append(conditions, fmt.Sprintf("concept_tsv @@ to_tsquery('simple', $%d)", len(args)))
+		} else {
+			args = append(args, "%"+escapeLike(filter.Query)+"%")
+			conditions = append(conditions, fmt.Sprintf("concept ILIKE $%d", len(args)))
+		}
+	}
+	if len(filter.Tags) > 0 {
+		args = append(args, filter.Tags)
+		conditions = append(conditions, fmt.Sprintf("tags @> $%d", len(args)))
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// jobOrderBy returns the ORDER BY clause from whitelisted sort fields.
+func jobOrderBy(filter models.JobFilter) string {
+	column := "created_at"
+	if filter.SortBy == models.JobSortUpdatedAt {
+		column = "updated_at"
+	}
+	direction := "DESC"
+	if filter.SortOrder == models.SortAsc {
+		direction = "ASC"
+	}
+	// Tie-break on id for stable pagination
+	return fmt.Sprintf("%s %s, id %s", column, direction, direction)
+}
+
+// minFullTextQueryLength is the shortest query, in characters, searched with the
+// concept_tsv index; shorter ones are too unselective and use ILIKE.
+const minFullTextQueryLength = 3
+
+// conceptTSQuery builds a prefix tsquery matching every word of query, e.g.
+// "beach wed" becomes "beach:* & wed:*". It returns false when the query should
+// use ILIKE instead: very short queries and Thai ones, since Thai is written
+// without spaces and the simple configuration indexes a whole run as one word, so
+// only a substring match finds a Thai word inside it.
+func conceptTSQuery(query string) (string, bool) {
+	if utf8.RuneCountInString(query) < minFullTextQueryLength {
+		return "", false
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+	if len(words) == 0 {
+		return "", false
+	}
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		if strings.IndexFunc(word, func(r rune) bool { return unicode.Is(unicode.Thai, r) }) >= 0 {
+			return "", false
+		}
+		// Words are letters, digits and marks only, so they carry no tsquery operators
+		terms = append(terms, word+":*")
+	}
+	return strings.Join(terms, " & "), true
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
 // Update updates all fields of a job.
+// Pipeline code must use the status-guarded atomic methods instead; a full-row write
+// from a stale copy can overwrite concurrent webhook or cancellation updates.
+// The write is guarded by job.Version: ErrStatusConflict means the row changed since it
+// was loaded and the caller must re-fetch. On success job.Version is advanced.
+// Returns ErrJobCancelled if the job was cancelled, so stale task state never overwrites a cancellation.
 func (r *jobRepository) Update(ctx context.Context, job *models.Job) error {
 	songPromptJSON, err := marshalJSONB(job.SongPrompt)
 	if err != nil {
@@ -269,6 +833,11 @@ func (r *jobRepository) Update(ctx context.Context, job *models.Job) error {
 		return fmt.Errorf("failed to marshal image_prompt: %w", err)
 	}
 
+	generatedImagesJSON, err := marshalJSONB(job.GeneratedImages)
+	if err != nil {
+		return fmt.Errorf("failed to marshal generated_images: %w", err)
+	}
+
 	query := `
 		UPDATE jobs SET
 			status = $2,
@@ -286,12 +855,20 @@ func (r *jobRepository) Update(ctx context.Context, job *models.Job) error {
 			youtube_url = $14,
 			youtube_video_id = $15,
 			youtube_error = $16,
-			error_message = $17,
-			updated_at = $18
-		WHERE id = $1
+			image_candidates = $17,
+			generated_images = $18,
+			error_message = $19,
+			video_key = $20,
+			audio_key = $21,
+			image_key = $22,
+			aspect_ratio = $23,
+			updated_at = $24,
+			version = version + 1
+		WHERE id = $1 AND version = $25 AND cancelled_at IS NULL
+			AND (status = $2 OR status = ANY($26))
 	`
 
-	job.UpdatedAt = time.Now().UTC()
+	updatedAt := time.Now().UTC()
 
 	result, err := r.db.Pool().Exec(ctx, query,
 		job.ID,
@@ -310,117 +887,751 @@ func (r *jobRepository) Update(ctx context.Context, job *models.Job) error {
 		job.YouTubeURL,
 		job.YouTubeVideoID,
 		job.YouTubeError,
+		job.ImageCandidates,
+		generatedImagesJSON,
 		job.ErrorMessage,
-		job.UpdatedAt,
+		job.VideoKey,
+		job.AudioKey,
+		job.ImageKey,
+		job.AspectRatio,
+		updatedAt,
+		job.Version,
+		models.TransitionSources(job.Status),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to update job: %w", err)
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		// Distinguish "not found", "cancelled while the task was running", "illegal
+		// transition" and "stale version"
+		var cancelled bool
+		var status string
+		err := r.db.Pool().QueryRow(ctx, `SELECT cancelled_at IS NOT NULL, status FROM jobs WHERE id = $1`, job.ID).Scan(&cancelled, &status)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrJobNotFound
+			}
+			return fmt.Errorf("failed to check job cancellation: %w", err)
+		}
+		if cancelled {
+			return ErrJobCancelled
+		}
+		if status != job.Status {
+			if err := checkTransition(status, job.Status); err != nil {
+				return err
+			}
+		}
+		return ErrStatusConflict
+	}
+
+	job.UpdatedAt = updatedAt
+	job.Version++
+	return nil
+}
+
+// UpdateStatus updates only the status of a job.
+// Guards against overwriting terminal states (completed/failed), which return
+// ErrStatusConflict, and against moves the pipeline does not make, which return
+// ErrInvalidStatusTransition.
+func (r *jobRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
+	query := `
+		UPDATE jobs SET
+			status = $2,
+			updated_at = $3,
+			version = version + 1
+		WHERE id = $1 AND status NOT IN ($4, $5) AND status = ANY($6)
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, status, time.Now().UTC(), models.StatusCompleted, models.StatusFailed,
+		models.TransitionSources(status))
+	if err != nil {
+		return fmt.Errorf("failed to update job status: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		// Distinguish "not found", "already terminal" and "illegal transition"
+		var current string
+		err := r.db.Pool().QueryRow(ctx, `SELECT status FROM jobs WHERE id = $1`, id).Scan(&current)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrJobNotFound
+			}
+			return fmt.Errorf("failed to check job status: %w", err)
+		}
+		if current == models.StatusCompleted || current == models.StatusFailed {
+			return ErrStatusConflict
+		}
+		return checkTransition(current, status)
+	}
+
+	return nil
+}
+
+// UpdateWithError updates the job status to failed and sets the error message.
+// Guards against overwriting terminal states (completed/failed).
+func (r *jobRepository) UpdateWithError(ctx context.Context, id uuid.UUID, errorMessage string) error {
+	return r.UpdateWithFailure(ctx, id, models.JobFailure{Message: errorMessage})
+}
+
+// UpdateWithFailure updates the job status to failed with the failure's message,
+// error code and retry step. Guards against overwriting terminal states (completed/failed).
+func (r *jobRepository) UpdateWithFailure(ctx context.Context, id uuid.UUID, failure models.JobFailure) error {
+	query := `
+		UPDATE jobs SET
+			status = $2,
+			error_message = $3,
+			error_code = NULLIF($7, ''),
+			retry_from = NULLIF($8, ''),
+			updated_at = $4,
+			version = version + 1
+		WHERE id = $1 AND status NOT IN ($5, $6)
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, models.StatusFailed, failure.Message, time.Now().UTC(),
+		models.StatusCompleted, models.StatusFailed, failure.Code, failure.RetryFrom)
+	if err != nil {
+		return fmt.Errorf("failed to update job with error: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		// Check if job exists to distinguish "not found" from "already terminal"
+		var exists bool
+		err := r.db.Pool().QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM jobs WHERE id = $1)`, id).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check job existence: %w", err)
+		}
+		if !exists {
+			return ErrJobNotFound
+		}
+		return ErrStatusConflict
+	}
+
+	return nil
+}
+
+// Cancel marks the job as failed with the given message and sets the cancelled_at flag.
+// Guards against overwriting terminal states (completed/failed).
+func (r *jobRepository) Cancel(ctx context.Context, id uuid.UUID, errorMessage string) error {
+	query := `
+		UPDATE jobs SET
+			status = $2,
+			error_message = $3,
+			cancelled_at = $4,
+			updated_at = $4,
+			version = version + 1
+		WHERE id = $1 AND status NOT IN ($5, $6)
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, models.StatusFailed, errorMessage, time.Now().UTC(), models.StatusCompleted, models.StatusFailed)
+	if err != nil {
+		return fmt.Errorf("failed to cancel job: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		// Check if job exists to distinguish "not found" from "already terminal"
+		var exists bool
+		err := r.db.Pool().QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM jobs WHERE id = $1)`, id).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check job existence: %w", err)
+		}
+		if !exists {
+			return ErrJobNotFound
+		}
+		return ErrStatusConflict
+	}
+
+	return nil
+}
+
+// ResetForRetry moves a failed, uncancelled job back to the status step expects
+// and clears the failure and the timings of the stages it re-runs. Retrying the
+// analysis or the music also clears the songs, so stale Suno tracks are not merged
+// with the new ones. Non-empty key sources replace the job's. Returns
+// ErrStatusConflict if the job is not failed or was cancelled.
+func (r *jobRepository) ResetForRetry(ctx context.Context, id uuid.UUID, step, openRouterKeySource, kieKeySource string) error {
+	status := models.RetryStatus(step)
+	if status == "" {
+		return fmt.Errorf("unknown retry step %q", step)
+	}
+	if err := checkTransition(models.StatusFailed, status); err != nil {
+		return err
+	}
+	clearSongs := step == models.RetryFromAnalyze || step == models.RetryFromMusic
+
+	query := `
+		UPDATE jobs SET
+			status = $2,
+			error_message = NULL,
+			error_code = NULL,
+			retry_from = NULL,
+			suno_task_id = CASE WHEN $3 THEN NULL ELSE suno_task_id END,
+			generated_songs = CASE WHEN $3 THEN NULL ELSE generated_songs END,
+			selected_song_id = CASE WHEN $3 THEN NULL ELSE selected_song_id END,
+			audio_url = CASE WHEN $3 THEN NULL ELSE audio_url END,
+			stage_timings = stage_timings - $6::text[],
+			openrouter_key_source = COALESCE(NULLIF($7, ''), openrouter_key_source),
+			kie_key_source = COALESCE(NULLIF($8, ''), kie_key_source),
+			updated_at = $4,
+			version = version + 1
+		WHERE id = $1 AND status = $5 AND cancelled_at IS NULL AND deleted_at IS NULL
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, status, clearSongs, time.Now().UTC(), models.StatusFailed,
+		models.RetriedStages(step), openRouterKeySource, kieKeySource)
+	if err != nil {
+		return fmt.Errorf("failed to reset job for retry: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrStatusConflict
+	}
+	return nil
+}
+
+// CancelActiveByUserID cancels every non-terminal job owned by userID and
+// returns the number of jobs cancelled.
+func (r *jobRepository) CancelActiveByUserID(ctx context.Context, userID uuid.UUID, errorMessage string) (int64, error) {
+	query := `
+		UPDATE jobs SET
+			status = $2,
+			error_message = $3,
+			cancelled_at = $4,
+			updated_at = $4,
+			version = version + 1
+		WHERE user_id = $1 AND status NOT IN ($5, $6)
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, userID, models.StatusFailed, errorMessage, time.Now().UTC(), models.StatusCompleted, models.StatusFailed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cancel user jobs: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// ListIDsByUserID returns the IDs of all jobs owned by userID.
+func (r *jobRepository) ListIDsByUserID(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Pool().Query(ctx, `SELECT id FROM jobs WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user job ids: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan job id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user job ids: %w", err)
+	}
+
+	return ids, nil
+}
+
+// Delete removes a job from the database.
+func (r *jobRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM jobs WHERE id = $1`
+
+	result, err := r.db.Pool().Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete job: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// SoftDelete marks a job as deleted. Only terminal jobs can be deleted, so the
+// status guard of every pipeline write keeps a deleted job from being resumed.
+// Deleting an already deleted job keeps the original deletion time.
+func (r *jobRepository) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE jobs
+		SET deleted_at = COALESCE(deleted_at, NOW()), updated_at = NOW(), version = version + 1
+		WHERE id = $1
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to soft delete job: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
+
+// Restore clears the deletion of a job deleted after deletedAfter. It returns
+// ErrStatusConflict when the job is not deleted or was deleted earlier.
+func (r *jobRepository) Restore(ctx context.Context, id uuid.UUID, deletedAfter time.Time) error {
+	query := `
+		UPDATE jobs
+		SET deleted_at = NULL, updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at > $2
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, deletedAfter)
+	if err != nil {
+		return fmt.Errorf("failed to restore job: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrStatusConflict
+	}
+
+	return nil
+}
+
+// ListDeletedBefore returns up to limit jobs soft-deleted before deletedBefore,
+// oldest deletion first.
+func (r *jobRepository) ListDeletedBefore(ctx context.Context, deletedBefore time.Time, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT id FROM jobs
+		WHERE deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2
+	`
+
+	rows, err := r.db.Pool().Query(ctx, query, deletedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted jobs: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan deleted job: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deleted jobs: %w", err)
+	}
+
+	return ids, nil
+}
+
+// TransitionStatusAtomic atomically moves the job from expectedStatus to newStatus.
+func (r *jobRepository) TransitionStatusAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, newStatus string) error {
+	if err := checkTransition(expectedStatus, newStatus); err != nil {
+		return err
+	}
+	query := `
+		UPDATE jobs SET
+			status = $2,
+			updated_at = $3,
+			version = version + 1
+		WHERE id = $1 AND status = $4
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, newStatus, time.Now().UTC(), expectedStatus)
+	if err != nil {
+		return fmt.Errorf("failed to transition job status: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrStatusConflict
+	}
+	return nil
+}
+
+// StartStageAtomic transitions the job from expectedStatus to newStatus and records
+// the start of stage in the same write. expectedStatus may equal newStatus to only
+// guard the status. As with RecordStageTime, a retried stage keeps its first start.
+func (r *jobRepository) StartStageAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, newStatus string, stage string, workerID string) error {
+	if err := checkTransition(expectedStatus, newStatus); err != nil {
+		return err
+	}
+	query := `
+		UPDATE jobs SET
+			status = $2,
+			updated_at = $3,
+			version = version + 1,
+			stage_timings = COALESCE(stage_timings, '{}'::jsonb) || jsonb_build_object($4::text,
+				COALESCE(stage_timings->$4, '{}'::jsonb) || jsonb_build_object('started_at',
+					COALESCE(stage_timings->$4->'started_at', to_jsonb($3::timestamptz)))
+				|| CASE WHEN $6 = '' THEN '{}'::jsonb ELSE jsonb_build_object('worker_id', $6::text) END)
+		WHERE id = $1 AND status = $5
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, newStatus, time.Now().UTC(), stage, expectedStatus, workerID)
+	if err != nil {
+		return fmt.Errorf("failed to start job stage: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrStatusConflict
+	}
+	return nil
+}
+
+// UpdateConceptAnalysisAtomic atomically stores the song prompt and the LLM model that produced it
+// with status guard (no status transition), along with extras.
+func (r *jobRepository) UpdateConceptAnalysisAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, prompt *models.SongPrompt, llmModel string, extras models.JobWriteExtras) error {
+	promptJSON, err := marshalJSONB(prompt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal song_prompt: %w", err)
+	}
+
+	args := []interface{}{id, promptJSON, llmModel, time.Now().UTC(), expectedStatus}
+	extrasSQL, args, err := jobWriteExtras(extras, args)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE jobs SET
+			song_prompt = $2,
+			llm_model = $3,
+			updated_at = $4,
+			version = version + 1` + extrasSQL + `
+		WHERE id = $1 AND status = $5
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update concept analysis: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrStatusConflict
+	}
+	return nil
+}
+
+// UpdateSunoTaskAtomic atomically records the Suno task ID and transitions status.
+// A job that already has a Suno task is left as it is, so a concurrent duplicate
+// of the music task cannot replace it.
+func (r *jobRepository) UpdateSunoTaskAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, newStatus string) error {
+	if err := checkTransition(expectedStatus, newStatus); err != nil {
+		return err
+	}
+	query := `
+		UPDATE jobs SET
+			suno_task_id = $2,
+			status = $3,
+			updated_at = $4,
+			version = version + 1
+		WHERE id = $1 AND status = $5 AND suno_task_id IS NULL
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, taskID, newStatus, time.Now().UTC(), expectedStatus)
+	if err != nil {
+		return fmt.Errorf("failed to update suno task id: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrStatusConflict
+	}
+	return nil
+}
+
+// UpdateSongPromptAtomic atomically updates song prompt and transitions status.
+func (r *jobRepository) UpdateSongPromptAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, prompt *models.SongPrompt, newStatus string) error {
+	if err := checkTransition(expectedStatus, newStatus); err != nil {
+		return err
+	}
+	promptJSON, err := marshalJSONB(prompt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal song_prompt: %w", err)
+	}
+
+	query := `
+		UPDATE jobs SET
+			song_prompt = $2,
+			status = $3,
+			updated_at = $4,
+			version = version + 1
+		WHERE id = $1 AND status = $5
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, promptJSON, newStatus, time.Now().UTC(), expectedStatus)
+	if err != nil {
+		return fmt.Errorf("failed to update song prompt: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrStatusConflict
+	}
+	return nil
+}
+
+// UpdateGeneratedSongsAtomic atomically updates generated songs, task ID, and transitions status,
+// along with extras.
+func (r *jobRepository) UpdateGeneratedSongsAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, songs []models.GeneratedSong, newStatus string, extras models.JobWriteExtras) error {
+	if err := checkTransition(expectedStatus, newStatus); err != nil {
+		return err
+	}
+	songsJSON, err := marshalJSONB(songs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal generated_songs: %w", err)
+	}
+
+	args := []interface{}{id, taskID, songsJSON, newStatus, time.Now().UTC(), expectedStatus}
+	extrasSQL, args, err := jobWriteExtras(extras, args)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE jobs SET
+			suno_task_id = $2,
+			generated_songs = $3,
+			status = $4,
+			updated_at = $5,
+			version = version + 1` + extrasSQL + `
+		WHERE id = $1 AND status = $6
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update generated songs: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrStatusConflict
+	}
+	return nil
+}
+
+// UpdateSelectedSongAtomic atomically updates selected song, audio URL, and transitions status,
+// along with extras.
+func (r *jobRepository) UpdateSelectedSongAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, songID string, audioURL string, newStatus string, extras models.JobWriteExtras) error {
+	if err := checkTransition(expectedStatus, newStatus); err != nil {
+		return err
+	}
+	args := []interface{}{id, songID, audioURL, newStatus, time.Now().UTC(), expectedStatus}
+	extrasSQL, args, err := jobWriteExtras(extras, args)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE jobs SET
+			selected_song_id = $2,
+			audio_url = $3,
+			status = $4,
+			updated_at = $5,
+			version = version + 1` + extrasSQL + `
+		WHERE id = $1 AND status = $6
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update selected song: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrStatusConflict
+	}
+	return nil
+}
+
+// UpdateImagePromptAtomic atomically updates the image prompt with status guard (no status transition),
+// along with extras.
+func (r *jobRepository) UpdateImagePromptAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, prompt *models.ImagePrompt, extras models.JobWriteExtras) error {
+	promptJSON, err := marshalJSONB(prompt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal image_prompt: %w", err)
+	}
+
+	args := []interface{}{id, promptJSON, time.Now().UTC(), expectedStatus}
+	extrasSQL, args, err := jobWriteExtras(extras, args)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE jobs SET
+			image_prompt = $2,
+			updated_at = $3,
+			version = version + 1` + extrasSQL + `
+		WHERE id = $1 AND status = $4
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update image prompt: %w", err)
 	}
-
 	if result.RowsAffected() == 0 {
-		return ErrJobNotFound
+		return ErrStatusConflict
 	}
-
 	return nil
 }
 
-// UpdateStatus updates only the status of a job.
-// Guards against overwriting terminal states (completed/failed).
-func (r *jobRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
+// UpdateImageURLAtomic atomically updates image URL, task ID, and transitions status, along with extras.
+func (r *jobRepository) UpdateImageURLAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, imageURL string, newStatus string, extras models.JobWriteExtras) error {
+	if err := checkTransition(expectedStatus, newStatus); err != nil {
+		return err
+	}
+	args := []interface{}{id, taskID, imageURL, newStatus, time.Now().UTC(), expectedStatus}
+	extrasSQL, args, err := jobWriteExtras(extras, args)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE jobs SET
-			status = $2,
-			updated_at = $3
-		WHERE id = $1 AND status NOT IN ($4, $5)
+			nano_task_id = $2,
+			image_url = $3,
+			status = $4,
+			updated_at = $5,
+			version = version + 1` + extrasSQL + `
+		WHERE id = $1 AND status = $6
 	`
 
-	result, err := r.db.Pool().Exec(ctx, query, id, status, time.Now().UTC(), models.StatusCompleted, models.StatusFailed)
+	result, err := r.db.Pool().Exec(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to update job status: %w", err)
+		return fmt.Errorf("failed to update image URL: %w", err)
 	}
-
 	if result.RowsAffected() == 0 {
-		// Check if job exists to distinguish "not found" from "already terminal"
-		var exists bool
-		err := r.db.Pool().QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM jobs WHERE id = $1)`, id).Scan(&exists)
-		if err != nil {
-			return fmt.Errorf("failed to check job existence: %w", err)
-		}
-		if !exists {
-			return ErrJobNotFound
-		}
 		return ErrStatusConflict
 	}
-
 	return nil
 }
 
-// UpdateWithError updates the job status to failed and sets the error message.
-// Guards against overwriting terminal states (completed/failed).
-func (r *jobRepository) UpdateWithError(ctx context.Context, id uuid.UUID, errorMessage string) error {
+// UpdateGeneratedImagesAtomic atomically replaces the image candidates with status guard (no status transition).
+func (r *jobRepository) UpdateGeneratedImagesAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, images []models.GeneratedImage) error {
+	imagesJSON, err := marshalJSONB(images)
+	if err != nil {
+		return fmt.Errorf("failed to marshal generated_images: %w", err)
+	}
+
 	query := `
 		UPDATE jobs SET
-			status = $2,
-			error_message = $3,
-			updated_at = $4
-		WHERE id = $1 AND status NOT IN ($5, $6)
+			generated_images = $2,
+			updated_at = $3,
+			version = version + 1
+		WHERE id = $1 AND status = $4
 	`
 
-	result, err := r.db.Pool().Exec(ctx, query, id, models.StatusFailed, errorMessage, time.Now().UTC(), models.StatusCompleted, models.StatusFailed)
+	result, err := r.db.Pool().Exec(ctx, query, id, imagesJSON, time.Now().UTC(), expectedStatus)
 	if err != nil {
-		return fmt.Errorf("failed to update job with error: %w", err)
+		return fmt.Errorf("failed to update generated images: %w", err)
 	}
-
 	if result.RowsAffected() == 0 {
-		// Check if job exists to distinguish "not found" from "already terminal"
-		var exists bool
-		err := r.db.Pool().QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM jobs WHERE id = $1)`, id).Scan(&exists)
-		if err != nil {
-			return fmt.Errorf("failed to check job existence: %w", err)
-		}
-		if !exists {
-			return ErrJobNotFound
-		}
 		return ErrStatusConflict
 	}
-
 	return nil
 }
 
-// Delete removes a job from the database.
-func (r *jobRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM jobs WHERE id = $1`
+// RestartImageGenerationAtomic clears the image prompt, NanoBanana task and image
+// candidates of a job in generating_image, so the generate image task starts over,
+// and counts the sanitize attempt. The attempt guard makes concurrent callbacks for
+// the same refusal restart the job once.
+func (r *jobRepository) RestartImageGenerationAtomic(ctx context.Context, id uuid.UUID, expectedAttempts int) error {
+	query := `
+		UPDATE jobs SET
+			image_prompt = NULL,
+			nano_task_id = NULL,
+			generated_images = NULL,
+			image_sanitize_attempts = image_sanitize_attempts + 1,
+			updated_at = $2,
+			version = version + 1
+		WHERE id = $1 AND status = $3 AND image_sanitize_attempts = $4
+	`
 
-	result, err := r.db.Pool().Exec(ctx, query, id)
+	result, err := r.db.Pool().Exec(ctx, query, id, time.Now().UTC(), models.StatusGeneratingImage, expectedAttempts)
 	if err != nil {
-		return fmt.Errorf("failed to delete job: %w", err)
+		return fmt.Errorf("failed to restart image generation: %w", err)
 	}
-
 	if result.RowsAffected() == 0 {
-		return ErrJobNotFound
+		return ErrStatusConflict
+	}
+	return nil
+}
+
+// UpdateNanoTasksAtomic atomically stores the image candidate tasks and the NanoBanana task ID
+// with status guard (no status transition).
+func (r *jobRepository) UpdateNanoTasksAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, images []models.GeneratedImage) error {
+	imagesJSON, err := marshalJSONB(images)
+	if err != nil {
+		return fmt.Errorf("failed to marshal generated_images: %w", err)
 	}
 
+	query := `
+		UPDATE jobs SET
+			nano_task_id = $2,
+			generated_images = $3,
+			updated_at = $4,
+			version = version + 1
+		WHERE id = $1 AND status = $5
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, taskID, imagesJSON, time.Now().UTC(), expectedStatus)
+	if err != nil {
+		return fmt.Errorf("failed to update nano task ids: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrStatusConflict
+	}
 	return nil
 }
 
-// UpdateSongPromptAtomic atomically updates song prompt and transitions status.
-func (r *jobRepository) UpdateSongPromptAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, prompt *models.SongPrompt, newStatus string) error {
-	promptJSON, err := marshalJSONB(prompt)
+// UpdateImageCandidateAtomic atomically records the result of a single image candidate task
+// and returns the updated candidate list. The row lock serializes concurrent callbacks,
+// so exactly one caller observes the final candidate without a pending status.
+func (r *jobRepository) UpdateImageCandidateAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, imageURL string, candidateStatus string) ([]models.GeneratedImage, error) {
+	query := `
+		UPDATE jobs SET
+			generated_images = (
+				SELECT jsonb_agg(
+					CASE WHEN elem->>'task_id' = $2
+						THEN elem || jsonb_build_object('image_url', $3::text, 'status', $4::text)
+						ELSE elem
+					END
+					ORDER BY ord
+				)
+				FROM jsonb_array_elements(generated_images) WITH ORDINALITY AS t(elem, ord)
+			),
+			updated_at = $5,
+			version = version + 1
+		WHERE id = $1 AND status = $6
+			AND generated_images @> jsonb_build_array(jsonb_build_object('task_id', $2::text, 'status', $7::text))
+		RETURNING generated_images
+	`
+
+	var imagesJSON []byte
+	err := r.db.Pool().QueryRow(ctx, query,
+		id, taskID, imageURL, candidateStatus, time.Now().UTC(), expectedStatus, models.ImageCandidatePending,
+	).Scan(&imagesJSON)
 	if err != nil {
-		return fmt.Errorf("failed to marshal song_prompt: %w", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrStatusConflict
+		}
+		return nil, fmt.Errorf("failed to update image candidate: %w", err)
+	}
+
+	var images []models.GeneratedImage
+	if err := unmarshalJSONB(imagesJSON, &images); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal generated_images: %w", err)
 	}
+	return images, nil
+}
 
+// UpdateVideoURLAtomic atomically updates video URL and transitions status.
+func (r *jobRepository) UpdateVideoURLAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, videoURL string, newStatus string) error {
+	if err := checkTransition(expectedStatus, newStatus); err != nil {
+		return err
+	}
 	query := `
 		UPDATE jobs SET
-			song_prompt = $2,
+			video_url = $2,
 			status = $3,
-			updated_at = $4
+			updated_at = $4,
+			version = version + 1
 		WHERE id = $1 AND status = $5
 	`
 
-	result, err := r.db.Pool().Exec(ctx, query, id, promptJSON, newStatus, time.Now().UTC(), expectedStatus)
+	result, err := r.db.Pool().Exec(ctx, query, id, videoURL, newStatus, time.Now().UTC(), expectedStatus)
 	if err != nil {
-		return fmt.Errorf("failed to update song prompt: %w", err)
+		return fmt.Errorf("failed to update video URL: %w", err)
 	}
 	if result.RowsAffected() == 0 {
 		return ErrStatusConflict
@@ -428,25 +1639,31 @@ func (r *jobRepository) UpdateSongPromptAtomic(ctx context.Context, id uuid.UUID
 	return nil
 }
 
-// UpdateGeneratedSongsAtomic atomically updates generated songs, task ID, and transitions status.
-func (r *jobRepository) UpdateGeneratedSongsAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, songs []models.GeneratedSong, newStatus string) error {
-	songsJSON, err := marshalJSONB(songs)
+// UpdateVideoKeyAtomic atomically stores the R2 key of the rendered video and transitions status,
+// along with extras. video_url is cleared since URLs are now generated from the key when the job is read.
+func (r *jobRepository) UpdateVideoKeyAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, videoKey string, newStatus string, extras models.JobWriteExtras) error {
+	if err := checkTransition(expectedStatus, newStatus); err != nil {
+		return err
+	}
+	args := []interface{}{id, videoKey, newStatus, time.Now().UTC(), expectedStatus}
+	extrasSQL, args, err := jobWriteExtras(extras, args)
 	if err != nil {
-		return fmt.Errorf("failed to marshal generated_songs: %w", err)
+		return err
 	}
 
 	query := `
 		UPDATE jobs SET
-			suno_task_id = $2,
-			generated_songs = $3,
-			status = $4,
-			updated_at = $5
-		WHERE id = $1 AND status = $6
+			video_key = $2,
+			video_url = NULL,
+			status = $3,
+			updated_at = $4,
+			version = version + 1` + extrasSQL + `
+		WHERE id = $1 AND status = $5
 	`
 
-	result, err := r.db.Pool().Exec(ctx, query, id, taskID, songsJSON, newStatus, time.Now().UTC(), expectedStatus)
+	result, err := r.db.Pool().Exec(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to update generated songs: %w", err)
+		return fmt.Errorf("failed to update video key: %w", err)
 	}
 	if result.RowsAffected() == 0 {
 		return ErrStatusConflict
@@ -454,65 +1671,103 @@ func (r *jobRepository) UpdateGeneratedSongsAtomic(ctx context.Context, id uuid.
 	return nil
 }
 
-// UpdateSelectedSongAtomic atomically updates selected song, audio URL, and transitions status.
-func (r *jobRepository) UpdateSelectedSongAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, songID string, audioURL string, newStatus string) error {
+// UpdateThumbnailKey stores the R2 key of the job's video thumbnail.
+// The thumbnail is optional, so it does not guard or change the status.
+func (r *jobRepository) UpdateThumbnailKey(ctx context.Context, id uuid.UUID, thumbnailKey string) error {
 	query := `
 		UPDATE jobs SET
-			selected_song_id = $2,
-			audio_url = $3,
-			status = $4,
-			updated_at = $5
-		WHERE id = $1 AND status = $6
+			thumbnail_key = $2,
+			updated_at = $3,
+			version = version + 1
+		WHERE id = $1
 	`
 
-	result, err := r.db.Pool().Exec(ctx, query, id, songID, audioURL, newStatus, time.Now().UTC(), expectedStatus)
+	result, err := r.db.Pool().Exec(ctx, query, id, thumbnailKey, time.Now().UTC())
 	if err != nil {
-		return fmt.Errorf("failed to update selected song: %w", err)
+		return fmt.Errorf("failed to update thumbnail key: %w", err)
 	}
 	if result.RowsAffected() == 0 {
-		return ErrStatusConflict
+		return ErrJobNotFound
 	}
 	return nil
 }
 
-// UpdateImagePromptAtomic atomically updates the image prompt with status guard (no status transition).
-func (r *jobRepository) UpdateImagePromptAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, prompt *models.ImagePrompt) error {
-	promptJSON, err := marshalJSONB(prompt)
+// UpdateTracks stores the tracks copied to R2 for a job created with keep_all_tracks.
+// Like the thumbnail the tracks are optional, so it does not guard or change the status.
+func (r *jobRepository) UpdateTracks(ctx context.Context, id uuid.UUID, tracks []models.JobTrack) error {
+	tracksJSON, err := marshalJSONB(tracks)
 	if err != nil {
-		return fmt.Errorf("failed to marshal image_prompt: %w", err)
+		return fmt.Errorf("failed to marshal tracks: %w", err)
 	}
 
 	query := `
 		UPDATE jobs SET
-			image_prompt = $2,
-			updated_at = $3
-		WHERE id = $1 AND status = $4
+			tracks = $2,
+			updated_at = $3,
+			version = version + 1
+		WHERE id = $1
 	`
 
-	result, err := r.db.Pool().Exec(ctx, query, id, promptJSON, time.Now().UTC(), expectedStatus)
+	result, err := r.db.Pool().Exec(ctx, query, id, tracksJSON, time.Now().UTC())
 	if err != nil {
-		return fmt.Errorf("failed to update image prompt: %w", err)
+		return fmt.Errorf("failed to update tracks: %w", err)
 	}
 	if result.RowsAffected() == 0 {
-		return ErrStatusConflict
+		return ErrJobNotFound
 	}
 	return nil
 }
 
-// UpdateImageURLAtomic atomically updates image URL, task ID, and transitions status.
-func (r *jobRepository) UpdateImageURLAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, taskID string, imageURL string, newStatus string) error {
+// UpdateVideoMetadata stores the codec, bitrate and size of the rendered video, along with extras.
+// Like the thumbnail it is informational, so it does not guard or change the status.
+func (r *jobRepository) UpdateVideoMetadata(ctx context.Context, id uuid.UUID, metadata *models.VideoMetadata, extras models.JobWriteExtras) error {
+	metadataJSON, err := marshalJSONB(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal video_metadata: %w", err)
+	}
+
+	args := []interface{}{id, metadataJSON, time.Now().UTC()}
+	extrasSQL, args, err := jobWriteExtras(extras, args)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE jobs SET
-			nano_task_id = $2,
+			video_metadata = $2,
+			updated_at = $3,
+			version = version + 1` + extrasSQL + `
+		WHERE id = $1
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update video metadata: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// SetUserImageAtomic stores an image that was not generated (already in R2 under imageKey)
+// and records where it came from (models.ImageSourceUser or models.ImageSourceSuno),
+// so image generation is skipped.
+// Only applies while the job is in one of expectedStatuses and not cancelled.
+func (r *jobRepository) SetUserImageAtomic(ctx context.Context, id uuid.UUID, expectedStatuses []string, imageKey string, imageURL string, imageSource string) error {
+	query := `
+		UPDATE jobs SET
+			image_key = $2,
 			image_url = $3,
-			status = $4,
-			updated_at = $5
-		WHERE id = $1 AND status = $6
+			image_source = $4,
+			updated_at = $5,
+			version = version + 1
+		WHERE id = $1 AND status = ANY($6) AND cancelled_at IS NULL
 	`
 
-	result, err := r.db.Pool().Exec(ctx, query, id, taskID, imageURL, newStatus, time.Now().UTC(), expectedStatus)
+	result, err := r.db.Pool().Exec(ctx, query, id, imageKey, imageURL, imageSource, time.Now().UTC(), expectedStatuses)
 	if err != nil {
-		return fmt.Errorf("failed to update image URL: %w", err)
+		return fmt.Errorf("failed to set user image: %w", err)
 	}
 	if result.RowsAffected() == 0 {
 		return ErrStatusConflict
@@ -520,19 +1775,22 @@ func (r *jobRepository) UpdateImageURLAtomic(ctx context.Context, id uuid.UUID,
 	return nil
 }
 
-// UpdateVideoURLAtomic atomically updates video URL and transitions status.
-func (r *jobRepository) UpdateVideoURLAtomic(ctx context.Context, id uuid.UUID, expectedStatus string, videoURL string, newStatus string) error {
+// ClearImageSourceAtomic resets the job's image source so the image is generated,
+// used when the Suno cover art cannot be used.
+// Only applies while the job is in expectedStatus and not cancelled.
+func (r *jobRepository) ClearImageSourceAtomic(ctx context.Context, id uuid.UUID, expectedStatus string) error {
 	query := `
 		UPDATE jobs SET
-			video_url = $2,
-			status = $3,
-			updated_at = $4
-		WHERE id = $1 AND status = $5
+			image_source = NULL,
+			source_image_url = NULL,
+			updated_at = $3,
+			version = version + 1
+		WHERE id = $1 AND status = $2 AND cancelled_at IS NULL
 	`
 
-	result, err := r.db.Pool().Exec(ctx, query, id, videoURL, newStatus, time.Now().UTC(), expectedStatus)
+	result, err := r.db.Pool().Exec(ctx, query, id, expectedStatus, time.Now().UTC())
 	if err != nil {
-		return fmt.Errorf("failed to update video URL: %w", err)
+		return fmt.Errorf("failed to clear image source: %w", err)
 	}
 	if result.RowsAffected() == 0 {
 		return ErrStatusConflict
@@ -555,6 +1813,10 @@ func marshalJSONB(v interface{}) ([]byte, error) {
 		if len(val) == 0 {
 			return nil, nil
 		}
+	case []models.GeneratedImage:
+		if len(val) == 0 {
+			return nil, nil
+		}
 	}
 
 	data, err := json.Marshal(v)
@@ -576,7 +1838,7 @@ func unmarshalJSONB(data []byte, v interface{}) error {
 // scanJob scans a single row into a Job struct.
 func scanJob(row pgx.Row) (*models.Job, error) {
 	var job models.Job
-	var songPromptJSON, generatedSongsJSON, imagePromptJSON []byte
+	var songPromptJSON, generatedSongsJSON, imagePromptJSON, generatedImagesJSON, agentModelsJSON, promptOverridesJSON, videoOptionsJSON, agentOutputsJSON, stageTimingsJSON, videoMetadataJSON, tracksJSON []byte
 
 	err := row.Scan(
 		&job.ID,
@@ -596,9 +1858,41 @@ func scanJob(row pgx.Row) (*models.Job, error) {
 		&job.YouTubeURL,
 		&job.YouTubeVideoID,
 		&job.YouTubeError,
+		&job.ImageCandidates,
+		&generatedImagesJSON,
 		&job.ErrorMessage,
+		&job.CancelledAt,
 		&job.CreatedAt,
 		&job.UpdatedAt,
+		&job.Version,
+		&job.VideoKey,
+		&job.AudioKey,
+		&job.ImageKey,
+		&job.AspectRatio,
+		&agentModelsJSON,
+		&promptOverridesJSON,
+		&job.ShareToken,
+		&job.SharedAt,
+		&job.ImageSource,
+		&job.SourceImageURL,
+		&videoOptionsJSON,
+		&job.ThumbnailKey,
+		&job.OpenRouterKeySource,
+		&job.KIEKeySource,
+		&agentOutputsJSON,
+		&stageTimingsJSON,
+		&job.SunoModel,
+		&job.ErrorCode,
+		&job.RetryFrom,
+		&videoMetadataJSON,
+		&job.Tags,
+		&job.DeletedAt,
+		&job.OrgID,
+		&job.ImageSanitizeAttempts,
+		&job.KeepAllTracks,
+		&tracksJSON,
+		&job.MaxDurationSeconds,
+		&job.CallbackMode,
 	)
 	if err != nil {
 		return nil, err
@@ -629,10 +1923,47 @@ func scanJob(row pgx.Row) (*models.Job, error) {
 		job.ImagePrompt = &ip
 	}
 
+	if len(generatedImagesJSON) > 0 {
+		var gi []models.GeneratedImage
+		if err := unmarshalJSONB(generatedImagesJSON, &gi); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal generated_images: %w", err)
+		}
+		job.GeneratedImages = gi
+	}
+
+	if err := unmarshalJSONB(agentModelsJSON, &job.AgentModels); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal agent_models: %w", err)
+	}
+
+	if err := unmarshalJSONB(agentOutputsJSON, &job.AgentOutputs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal agent_outputs: %w", err)
+	}
+	if err := unmarshalJSONB(stageTimingsJSON, &job.StageTimings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stage_timings: %w", err)
+	}
+
+	if err := unmarshalJSONB(promptOverridesJSON, &job.PromptOverrides); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal prompt_overrides: %w", err)
+	}
+
+	if err := unmarshalJSONB(videoOptionsJSON, &job.VideoOptions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal video_options: %w", err)
+	}
+
+	if err := unmarshalJSONB(videoMetadataJSON, &job.VideoMetadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal video_metadata: %w", err)
+	}
+
+	if err := unmarshalJSONB(tracksJSON, &job.Tracks); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tracks: %w", err)
+	}
+
 	return &job, nil
 }
 
 // UpdateYouTubeResult updates YouTube-related fields and transitions to a new status.
+// Returns ErrStatusConflict if the job cannot move to newStatus, e.g. because it
+// was cancelled during the upload.
 func (r *jobRepository) UpdateYouTubeResult(ctx context.Context, id uuid.UUID, youtubeURL, youtubeVideoID, youtubeError *string, newStatus string) error {
 	query := `
 		UPDATE jobs SET
@@ -640,16 +1971,26 @@ func (r *jobRepository) UpdateYouTubeResult(ctx context.Context, id uuid.UUID, y
 			youtube_video_id = $3,
 			youtube_error = $4,
 			status = $5,
-			updated_at = $6
-		WHERE id = $1
+			updated_at = $6,
+			version = version + 1
+		WHERE id = $1 AND status = ANY($7)
 	`
 
-	result, err := r.db.Pool().Exec(ctx, query, id, youtubeURL, youtubeVideoID, youtubeError, newStatus, time.Now().UTC())
+	result, err := r.db.Pool().Exec(ctx, query, id, youtubeURL, youtubeVideoID, youtubeError, newStatus, time.Now().UTC(),
+		models.TransitionSources(newStatus))
 	if err != nil {
 		return fmt.Errorf("failed to update YouTube result: %w", err)
 	}
 	if result.RowsAffected() == 0 {
-		return ErrJobNotFound
+		var exists bool
+		err := r.db.Pool().QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM jobs WHERE id = $1)`, id).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check job existence: %w", err)
+		}
+		if !exists {
+			return ErrJobNotFound
+		}
+		return ErrStatusConflict
 	}
 	return nil
 }
@@ -657,7 +1998,7 @@ func (r *jobRepository) UpdateYouTubeResult(ctx context.Context, id uuid.UUID, y
 // scanJobFromRows scans a row from pgx.Rows into a Job struct.
 func scanJobFromRows(rows pgx.Rows) (*models.Job, error) {
 	var job models.Job
-	var songPromptJSON, generatedSongsJSON, imagePromptJSON []byte
+	var songPromptJSON, generatedSongsJSON, imagePromptJSON, generatedImagesJSON, agentModelsJSON, promptOverridesJSON, videoOptionsJSON, agentOutputsJSON, stageTimingsJSON, videoMetadataJSON, tracksJSON []byte
 
 	err := rows.Scan(
 		&job.ID,
@@ -677,9 +2018,41 @@ func scanJobFromRows(rows pgx.Rows) (*models.Job, error) {
 		&job.YouTubeURL,
 		&job.YouTubeVideoID,
 		&job.YouTubeError,
+		&job.ImageCandidates,
+		&generatedImagesJSON,
 		&job.ErrorMessage,
+		&job.CancelledAt,
 		&job.CreatedAt,
 		&job.UpdatedAt,
+		&job.Version,
+		&job.VideoKey,
+		&job.AudioKey,
+		&job.ImageKey,
+		&job.AspectRatio,
+		&agentModelsJSON,
+		&promptOverridesJSON,
+		&job.ShareToken,
+		&job.SharedAt,
+		&job.ImageSource,
+		&job.SourceImageURL,
+		&videoOptionsJSON,
+		&job.ThumbnailKey,
+		&job.OpenRouterKeySource,
+		&job.KIEKeySource,
+		&agentOutputsJSON,
+		&stageTimingsJSON,
+		&job.SunoModel,
+		&job.ErrorCode,
+		&job.RetryFrom,
+		&videoMetadataJSON,
+		&job.Tags,
+		&job.DeletedAt,
+		&job.OrgID,
+		&job.ImageSanitizeAttempts,
+		&job.KeepAllTracks,
+		&tracksJSON,
+		&job.MaxDurationSeconds,
+		&job.CallbackMode,
 	)
 	if err != nil {
 		return nil, err
@@ -710,5 +2083,169 @@ func scanJobFromRows(rows pgx.Rows) (*models.Job, error) {
 		job.ImagePrompt = &ip
 	}
 
+	if len(generatedImagesJSON) > 0 {
+		var gi []models.GeneratedImage
+		if err := unmarshalJSONB(generatedImagesJSON, &gi); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal generated_images: %w", err)
+		}
+		job.GeneratedImages = gi
+	}
+
+	if err := unmarshalJSONB(agentModelsJSON, &job.AgentModels); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal agent_models: %w", err)
+	}
+
+	if err := unmarshalJSONB(agentOutputsJSON, &job.AgentOutputs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal agent_outputs: %w", err)
+	}
+	if err := unmarshalJSONB(stageTimingsJSON, &job.StageTimings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stage_timings: %w", err)
+	}
+
+	if err := unmarshalJSONB(promptOverridesJSON, &job.PromptOverrides); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal prompt_overrides: %w", err)
+	}
+
+	if err := unmarshalJSONB(videoOptionsJSON, &job.VideoOptions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal video_options: %w", err)
+	}
+
+	if err := unmarshalJSONB(videoMetadataJSON, &job.VideoMetadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal video_metadata: %w", err)
+	}
+
+	if err := unmarshalJSONB(tracksJSON, &job.Tracks); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tracks: %w", err)
+	}
+
 	return &job, nil
 }
+
+// jobWriteExtras returns the SET clauses applying extras to a job UPDATE whose
+// arguments are args, and args with the clauses' arguments appended. The clauses
+// are empty when extras is. Agent records replace an earlier record of the same
+// agent, with the output's text fields capped first; completed stages keep their
+// start time.
+func jobWriteExtras(extras models.JobWriteExtras, args []interface{}) (string, []interface{}, error) {
+	var clauses []string
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if extras.Agent != "" {
+		output := extras.AgentOutput
+		output.Truncate()
+		outputJSON, err := json.Marshal(output)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to marshal agent output: %w", err)
+		}
+
+		agent := arg(extras.Agent)
+		clauses = append(clauses,
+			fmt.Sprintf("agent_models = COALESCE(agent_models, '{}'::jsonb) || jsonb_build_object(%s::text, %s::text)", agent, arg(output.Model)),
+			fmt.Sprintf("agent_outputs = COALESCE(agent_outputs, '{}'::jsonb) || jsonb_build_object(%s::text, %s::jsonb)", agent, arg(outputJSON)),
+		)
+	}
+
+	if len(extras.CompletedStages) > 0 {
+		timing := map[string]interface{}{models.StageEventCompleted: time.Now().UTC()}
+		if extras.WorkerID != "" {
+			timing["worker_id"] = extras.WorkerID
+		}
+		timingJSON, err := json.Marshal(timing)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to marshal stage timing: %w", err)
+		}
+
+		patch := arg(timingJSON)
+		stages := make([]string, 0, len(extras.CompletedStages))
+		for _, stage := range extras.CompletedStages {
+			s := arg(stage)
+			stages = append(stages, fmt.Sprintf("%s::text, COALESCE(stage_timings->(%s::text), '{}'::jsonb) || %s::jsonb", s, s, patch))
+		}
+		clauses = append(clauses, "stage_timings = COALESCE(stage_timings, '{}'::jsonb) || jsonb_build_object("+strings.Join(stages, ", ")+")")
+	}
+
+	if len(clauses) == 0 {
+		return "", args, nil
+	}
+	return ",\n\t\t\t" + strings.Join(clauses, ",\n\t\t\t"), args, nil
+}
+
+// RecordStageTime stores when a pipeline stage started or completed (event is
+// models.StageEventStarted or models.StageEventCompleted). A stage keeps its first
+// start time, so a retried stage is measured from its first attempt, while the
+// completion time is always replaced. workerID, when set, records the worker
+// instance of the latest event. It does not bump version or check status.
+func (r *jobRepository) RecordStageTime(ctx context.Context, id uuid.UUID, stage string, event string, at time.Time, workerID string) error {
+	query := `
+		UPDATE jobs SET
+			stage_timings = COALESCE(stage_timings, '{}'::jsonb) || jsonb_build_object($2::text,
+				COALESCE(stage_timings->$2, '{}'::jsonb) || jsonb_build_object($3::text,
+					CASE WHEN $5 THEN to_jsonb($4::timestamptz)
+					ELSE COALESCE(stage_timings->$2->$3, to_jsonb($4::timestamptz)) END)
+				|| CASE WHEN $6 = '' THEN '{}'::jsonb ELSE jsonb_build_object('worker_id', $6::text) END)
+		WHERE id = $1
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, stage, event, at, event == models.StageEventCompleted, workerID)
+	if err != nil {
+		return fmt.Errorf("failed to record stage time: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// StageDurationStats returns the median and 95th percentile duration of each
+// pipeline stage over the completed stages of jobs created since since.
+func (r *jobRepository) StageDurationStats(ctx context.Context, since time.Time) ([]models.StageDurationStats, error) {
+	query := `
+		SELECT stage.key,
+			COUNT(*),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY d.seconds),
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY d.seconds)
+		FROM jobs
+		CROSS JOIN LATERAL jsonb_each(stage_timings) AS stage
+		CROSS JOIN LATERAL (
+			SELECT EXTRACT(EPOCH FROM (stage.value->>'completed_at')::timestamptz - (stage.value->>'started_at')::timestamptz)::float8 AS seconds
+		) AS d
+		WHERE created_at >= $1
+			AND stage_timings IS NOT NULL
+			AND stage.value ? 'started_at'
+			AND stage.value ? 'completed_at'
+			AND d.seconds >= 0
+		GROUP BY stage.key
+	`
+
+	rows, err := r.db.Pool().Query(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stage durations: %w", err)
+	}
+	defer rows.Close()
+
+	byStage := make(map[string]models.StageDurationStats)
+	for rows.Next() {
+		var stats models.StageDurationStats
+		if err := rows.Scan(&stats.Stage, &stats.Jobs, &stats.P50Seconds, &stats.P95Seconds); err != nil {
+			return nil, fmt.Errorf("failed to scan stage durations: %w", err)
+		}
+		byStage[stats.Stage] = stats
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate stage durations: %w", err)
+	}
+
+	// Report every stage in pipeline order, including those without data
+	result := make([]models.StageDurationStats, 0, len(models.PipelineStages))
+	for _, stage := range models.PipelineStages {
+		stats, ok := byStage[stage]
+		if !ok {
+			stats = models.StageDurationStats{Stage: stage}
+		}
+		result = append(result, stats)
+	}
+	return result, nil
+}
@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+)
+
+func TestBuildJobFilter(t *testing.T) {
+	userID := uuid.New()
+	orgID := uuid.New()
+	after := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		filter   models.JobFilter
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "no filter",
+			filter:   models.JobFilter{},
+			wantSQL:  "user_id = $1 AND deleted_at IS NULL",
+			wantArgs: []interface{}{userID},
+		},
+		{
+			name:     "statuses",
+			filter:   models.JobFilter{Statuses: []string{models.StatusFailed, models.StatusCompleted}},
+			wantSQL:  "user_id = $1 AND deleted_at IS NULL AND status = ANY($2)",
+			wantArgs: []interface{}{userID, []string{models.StatusFailed, models.StatusCompleted}},
+		},
+		{
+			name:     "date range",
+			filter:   models.JobFilter{CreatedAfter: &after, CreatedBefore: &before},
+			wantSQL:  "user_id = $1 AND deleted_at IS NULL AND created_at >= $2 AND created_at < $3",
+			wantArgs: []interface{}{userID, after, before},
+		},
+		{
+			name:     "created after only",
+			filter:   models.JobFilter{CreatedAfter: &after},
+			wantSQL:  "user_id = $1 AND deleted_at IS NULL AND created_at >= $2",
+			wantArgs: []interface{}{userID, after},
+		},
+		{
+			name:     "english query uses the full-text index",
+			filter:   models.JobFilter{Query: "Beach wed"},
+			wantSQL:  "user_id = $1 AND deleted_at IS NULL AND concept_tsv @@ to_tsquery('simple', $2)",
+			wantArgs: []interface{}{userID, "beach:* & wed:*"},
+		},
+		{
+			name:     "tsquery operators are dropped",
+			filter:   models.JobFilter{Query: "rock & !roll | (jazz):*"},
+			wantSQL:  "user_id = $1 AND deleted_at IS NULL AND concept_tsv @@ to_tsquery('simple', $2)",
+			wantArgs: []interface{}{userID, "rock:* & roll:* & jazz:*"},
+		},
+		{
+			name:     "thai query uses ILIKE",
+			filter:   models.JobFilter{Query: "ทะเล"},
+			wantSQL:  "user_id = $1 AND deleted_at IS NULL AND concept ILIKE $2",
+			wantArgs: []interface{}{userID, "%ทะเล%"},
+		},
+		{
+			name:     "mixed thai and english query uses ILIKE",
+			filter:   models.JobFilter{Query: "เพลง pop"},
+			wantSQL:  "user_id = $1 AND deleted_at IS NULL AND concept ILIKE $2",
+			wantArgs: []interface{}{userID, "%เพลง pop%"},
+		},
+		{
+			name:     "short query uses ILIKE with wildcards escaped",
+			filter:   models.JobFilter{Query: "5%"},
+			wantSQL:  "user_id = $1 AND deleted_at IS NULL AND concept ILIKE $2",
+			wantArgs: []interface{}{userID, `%5\%%`},
+		},
+		{
+			name:     "punctuation-only query uses ILIKE",
+			filter:   models.JobFilter{Query: "!?_"},
+			wantSQL:  "user_id = $1 AND deleted_at IS NULL AND concept ILIKE $2",
+			wantArgs: []interface{}{userID, `%!?\_%`},
+		},
+		{
+			name:     "tags",
+			filter:   models.JobFilter{Tags: []string{"wedding", "thai"}},
+			wantSQL:  "user_id = $1 AND deleted_at IS NULL AND tags @> $2",
+			wantArgs: []interface{}{userID, []string{"wedding", "thai"}},
+		},
+		{
+			name:     "organization scope",
+			filter:   models.JobFilter{OrgID: &orgID},
+			wantSQL:  "org_id = $1 AND deleted_at IS NULL",
+			wantArgs: []interface{}{orgID},
+		},
+		{
+			name: "every filter numbers its placeholders in order",
+			filter: models.JobFilter{
+				OrgID:         &orgID,
+				Statuses:      []string{models.StatusPending},
+				CreatedAfter:  &after,
+				CreatedBefore: &before,
+				Query:         "sunset",
+				Tags:          []string{"beach"},
+			},
+			wantSQL: "org_id = $1 AND deleted_at IS NULL AND status = ANY($2) AND created_at >= $3 AND created_at < $4" +
+				" AND concept_tsv @@ to_tsquery('simple', $5) AND tags @> $6",
+			wantArgs: []interface{}{orgID, []string{models.StatusPending}, after, before, "sunset:*", []string{"beach"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args := buildJobFilter(userID, tt.filter)
+			if sql != tt.wantSQL {
+				t.Errorf("SQL = %q, want %q", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestBuildJobFilterIncludeDeleted(t *testing.T) {
+	userID := uuid.New()
+
+	sql, args := buildJobFilter(userID, models.JobFilter{IncludeDeleted: true, Tags: []string{"beach"}})
+	if want := "user_id = $1 AND (deleted_at IS NULL OR deleted_at > $2) AND tags @> $3"; sql != want {
+		t.Fatalf("SQL = %q, want %q", sql, want)
+	}
+	if len(args) != 3 {
+		t.Fatalf("got %d args, want 3", len(args))
+	}
+	cutoff, ok := args[1].(time.Time)
+	if !ok {
+		t.Fatalf("restore cutoff arg = %#v, want a time", args[1])
+	}
+	if want := time.Now().Add(-models.JobRestoreWindow); cutoff.Sub(want).Abs() > time.Minute {
+		t.Errorf("restore cutoff = %v, want about %v", cutoff, want)
+	}
+}
+
+func TestConceptTSQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string // Empty when the query falls back to ILIKE
+	}{
+		{query: "beach", want: "beach:*"},
+		{query: "Beach  WEDDING", want: "beach:* & wedding:*"},
+		{query: "sunset-2024", want: "sunset:* & 2024:*"},
+		{query: "café", want: "café:*"},
+		{query: "rock'n'roll", want: "rock:* & n:* & roll:*"},
+		{query: "a:* | b", want: "a:* & b:*"},
+		// Thai is written without spaces, so a Thai word is rarely a whole indexed word
+		{query: "ทะเล"},
+		{query: "เพลงรักฤดูฝน"},
+		{query: "๒๕๖๗"},
+		{query: "wedding ริมทะเล"},
+		{query: "น้ำ"},
+		// Too short or without words
+		{query: "be"},
+		{query: "ฝน"},
+		{query: "..."},
+		{query: "   "},
+	}
+
+	for _, tt := range tests {
+		got, ok := conceptTSQuery(tt.query)
+		if ok != (tt.want != "") || got != tt.want {
+			t.Errorf("conceptTSQuery(%q) = %q, %v; want %q, %v", tt.query, got, ok, tt.want, tt.want != "")
+		}
+	}
+}
+
+// sqlColumns splits a SELECT column list on its top-level commas.
+func sqlColumns(list string) []string {
+	var columns []string
+	depth, start := 0, 0
+	for i, r := range list {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				columns = append(columns, strings.TrimSpace(list[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(columns, strings.TrimSpace(list[start:]))
+}
+
+// TestJobListItemColumns compares the columns the job list reads with those of a
+// full job: a subset, without the large JSONB fields, and a fraction of the width.
+func TestJobListItemColumns(t *testing.T) {
+	columnName := regexp.MustCompile(`^(?:[A-Z]+\()?([a-z_]+)`)
+	full := make(map[string]bool)
+	for _, column := range sqlColumns(jobColumns) {
+		full[column] = true
+	}
+	listed := sqlColumns(jobListItemColumns)
+
+	large := []string{
+		"song_prompt", "generated_songs", "image_prompt", "generated_images", "agent_models", "prompt_overrides",
+		"video_options", "agent_outputs", "stage_timings", "video_metadata", "tracks",
+	}
+	for _, column := range listed {
+		name := columnName.FindStringSubmatch(column)[1]
+		if !full[name] {
+			t.Errorf("list column %q reads %s, which is not a job column", column, name)
+		}
+		// The title is the only part of a large field the list needs
+		if slices.Contains(large, name) && column != "song_prompt->>'title'" {
+			t.Errorf("list column %q reads the large field %s", column, name)
+		}
+	}
+	if !slices.Contains(listed, "LEFT(concept, 256)") {
+		t.Errorf("list columns %v, want the concept truncated", listed)
+	}
+	if len(listed)*4 > len(full) {
+		t.Errorf("list reads %d of %d job columns, want at most a quarter", len(listed), len(full))
+	}
+}
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/jaochai/ugc/internal/database"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// ErrJobScheduleNotFound is returned when a job schedule does not exist.
+var ErrJobScheduleNotFound = errors.New("job schedule not found")
+
+// ErrJobScheduleLimitReached is returned when a user already has the maximum number of schedules.
+var ErrJobScheduleLimitReached = errors.New("job schedule limit reached")
+
+// JobScheduleRepository defines the interface for job schedule data access.
+type JobScheduleRepository interface {
+	Create(ctx context.Context, schedule *models.JobSchedule, limit int) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.JobSchedule, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]*models.JobSchedule, error)
+	Update(ctx context.Context, schedule *models.JobSchedule) error
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// Scheduler methods
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*models.JobSchedule, error)
+	Claim(ctx context.Context, id uuid.UUID, expectedNextRun, nextRun time.Time) (bool, error)
+	RecordJob(ctx context.Context, id uuid.UUID, jobID uuid.UUID) error
+	Disable(ctx context.Context, id uuid.UUID, reason string) error
+}
+
+const jobScheduleColumns = `id, user_id, concept, template_id, frequency, time_of_day, weekday, timezone,
+	enabled, disabled_reason, last_run_at, last_job_id, next_run_at, created_at, updated_at`
+
+type jobScheduleRepository struct {
+	db *database.DB
+}
+
+// NewJobScheduleRepository creates a new JobScheduleRepository instance.
+func NewJobScheduleRepository(db *database.DB) JobScheduleRepository {
+	return &jobScheduleRepository{db: db}
+}
+
+// Create inserts a schedule unless the user already has limit schedules.
+func (r *jobScheduleRepository) Create(ctx context.Context, schedule *models.JobSchedule, limit int) error {
+	if schedule.ID == uuid.Nil {
+		schedule.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO job_schedules (id, user_id, concept, template_id, frequency, time_of_day, weekday, timezone, enabled, next_run_at)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+		WHERE (SELECT COUNT(*) FROM job_schedules WHERE user_id = $2) < $11
+		RETURNING created_at, updated_at
+	`
+
+	err := r.db.Pool().QueryRow(ctx, query,
+		schedule.ID, schedule.UserID, schedule.Concept, schedule.TemplateID, schedule.Frequency,
+		schedule.TimeOfDay, schedule.Weekday, schedule.Timezone, schedule.Enabled, schedule.NextRunAt, limit,
+	).Scan(&schedule.CreatedAt, &schedule.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrJobScheduleLimitReached
+		}
+		return fmt.Errorf("failed to create job schedule: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a job schedule by ID.
+func (r *jobScheduleRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.JobSchedule, error) {
+	query := `SELECT ` + jobScheduleColumns + ` FROM job_schedules WHERE id = $1`
+
+	schedule, err := scanJobSchedule(r.db.Pool().QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrJobScheduleNotFound
+		}
+		return nil, fmt.Errorf("failed to get job schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+// ListByUserID returns all schedules owned by a user, newest first.
+func (r *jobScheduleRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*models.JobSchedule, error) {
+	query := `SELECT ` + jobScheduleColumns + ` FROM job_schedules WHERE user_id = $1 ORDER BY created_at DESC`
+	return r.query(ctx, query, userID)
+}
+
+// Update replaces a schedule's concept, timing and enabled flag.
+// Re-enabling a schedule clears the reason it was disabled.
+func (r *jobScheduleRepository) Update(ctx context.Context, schedule *models.JobSchedule) error {
+	query := `
+		UPDATE job_schedules
+		SET concept = $2, template_id = $3, frequency = $4, time_of_day = $5, weekday = $6,
+			timezone = $7, enabled = $8, next_run_at = $9,
+			disabled_reason = CASE WHEN $8 THEN NULL ELSE disabled_reason END,
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING disabled_reason, updated_at
+	`
+
+	err := r.db.Pool().QueryRow(ctx, query,
+		schedule.ID, schedule.Concept, schedule.TemplateID, schedule.Frequency, schedule.TimeOfDay,
+		schedule.Weekday, schedule.Timezone, schedule.Enabled, schedule.NextRunAt,
+	).Scan(&schedule.DisabledReason, &schedule.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrJobScheduleNotFound
+		}
+		return fmt.Errorf("failed to update job schedule: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a job schedule.
+func (r *jobScheduleRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Pool().Exec(ctx, `DELETE FROM job_schedules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete job schedule: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrJobScheduleNotFound
+	}
+
+	return nil
+}
+
+// ListDue returns enabled schedules whose next run is at or before now, oldest first.
+func (r *jobScheduleRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*models.JobSchedule, error) {
+	query := `
+		SELECT ` + jobScheduleColumns + `
+		FROM job_schedules
+		WHERE enabled AND next_run_at <= $1
+		ORDER BY next_run_at
+		LIMIT $2
+	`
+	return r.query(ctx, query, now, limit)
+}
+
+// Claim advances a due schedule to its next run. It returns false when another
+// scheduler already claimed this run (next_run_at no longer matches) or the
+// schedule was disabled meanwhile, so each run creates at most one job.
+func (r *jobScheduleRepository) Claim(ctx context.Context, id uuid.UUID, expectedNextRun, nextRun time.Time) (bool, error) {
+	query := `
+		UPDATE job_schedules
+		SET next_run_at = $3, last_run_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND next_run_at = $2 AND enabled
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, expectedNextRun, nextRun)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim job schedule: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// RecordJob stores the job created by the latest run.
+func (r *jobScheduleRepository) RecordJob(ctx context.Context, id uuid.UUID, jobID uuid.UUID) error {
+	_, err := r.db.Pool().Exec(ctx, `UPDATE job_schedules SET last_job_id = $2 WHERE id = $1`, id, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to record job schedule run: %w", err)
+	}
+	return nil
+}
+
+// Disable turns a schedule off and stores why.
+func (r *jobScheduleRepository) Disable(ctx context.Context, id uuid.UUID, reason string) error {
+	query := `
+		UPDATE job_schedules
+		SET enabled = false, disabled_reason = $2, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	if _, err := r.db.Pool().Exec(ctx, query, id, reason); err != nil {
+		return fmt.Errorf("failed to disable job schedule: %w", err)
+	}
+	return nil
+}
+
+// query runs a query selecting jobScheduleColumns and scans every row.
+func (r *jobScheduleRepository) query(ctx context.Context, query string, args ...any) ([]*models.JobSchedule, error) {
+	rows, err := r.db.Pool().Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := make([]*models.JobSchedule, 0)
+	for rows.Next() {
+		schedule, err := scanJobSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job schedule: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating job schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// scanJobSchedule scans a row selected with jobScheduleColumns.
+func scanJobSchedule(row pgx.Row) (*models.JobSchedule, error) {
+	var schedule models.JobSchedule
+	err := row.Scan(
+		&schedule.ID,
+		&schedule.UserID,
+		&schedule.Concept,
+		&schedule.TemplateID,
+		&schedule.Frequency,
+		&schedule.TimeOfDay,
+		&schedule.Weekday,
+		&schedule.Timezone,
+		&schedule.Enabled,
+		&schedule.DisabledReason,
+		&schedule.LastRunAt,
+		&schedule.LastJobID,
+		&schedule.NextRunAt,
+		&schedule.CreatedAt,
+		&schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
@@ -0,0 +1,101 @@
+package repository_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// TestJobSearch lists jobs by concept query and tags against the concept_tsv
+// index and the ILIKE fallback, with Thai concepts written without spaces. It
+// needs TEST_DATABASE_URL.
+func TestJobSearch(t *testing.T) {
+	db := testutil.NewDB(t)
+	ctx := context.Background()
+	users := repository.NewUserRepository(db)
+	jobRepo := repository.NewJobRepository(db)
+
+	user := &models.User{ID: uuid.New(), Email: "search-" + uuid.NewString() + "@example.com", PasswordHash: "unused"}
+	other := &models.User{ID: uuid.New(), Email: "search-" + uuid.NewString() + "@example.com", PasswordHash: "unused"}
+	for _, u := range []*models.User{user, other} {
+		if err := users.Create(ctx, u); err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+	}
+
+	// Concepts by name; the simple configuration indexes each Thai run as one word
+	concepts := map[string]struct {
+		concept string
+		tags    []string
+	}{
+		"thai-beach": {concept: "งานแต่งงานริมทะเลตอนพระอาทิตย์ตก", tags: []string{"wedding", "acoustic"}},
+		"thai-rain":  {concept: "เพลงรักฤดูฝนในกรุงเทพ", tags: []string{"acoustic"}},
+		"english":    {concept: "Beach Wedding at sunset", tags: []string{"wedding"}},
+		"mixed":      {concept: "เพลง pop สำหรับงานแต่ง 100% happy"},
+		"office":     {concept: "100 happy songs for the office"},
+	}
+	ids := make(map[uuid.UUID]string)
+	for name, c := range concepts {
+		job := &models.Job{UserID: user.ID, Concept: c.concept, Tags: c.tags, Status: models.StatusCompleted}
+		if err := jobRepo.Create(ctx, job); err != nil {
+			t.Fatalf("failed to create job: %v", err)
+		}
+		ids[job.ID] = name
+	}
+	// Another user's matching job is never listed
+	if err := jobRepo.Create(ctx, &models.Job{UserID: other.ID, Concept: "งานแต่งงานริมทะเล", Tags: []string{"wedding"}, Status: models.StatusCompleted}); err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter models.JobFilter
+		want   []string
+	}{
+		// Thai words sit inside longer indexed runs, so only ILIKE finds them
+		{name: "thai word inside a run", filter: models.JobFilter{Query: "ทะเล"}, want: []string{"thai-beach"}},
+		{name: "thai word in several concepts", filter: models.JobFilter{Query: "งานแต่ง"}, want: []string{"mixed", "thai-beach"}},
+		{name: "thai word with a vowel below", filter: models.JobFilter{Query: "ฤดูฝน"}, want: []string{"thai-rain"}},
+		{name: "single thai character", filter: models.JobFilter{Query: "ฝ"}, want: []string{"thai-rain"}},
+		{name: "thai and english", filter: models.JobFilter{Query: "เพลง pop"}, want: []string{"mixed"}},
+		{name: "thai not present", filter: models.JobFilter{Query: "ภูเขา"}},
+		// English words use the index with prefix matching, ignoring case and order
+		{name: "english prefix", filter: models.JobFilter{Query: "wed"}, want: []string{"english"}},
+		{name: "english words in any order", filter: models.JobFilter{Query: "SUNSET beach"}, want: []string{"english"}},
+		{name: "english words all required", filter: models.JobFilter{Query: "beach office"}},
+		{name: "english word inside a thai concept", filter: models.JobFilter{Query: "pop"}, want: []string{"mixed"}},
+		// Short queries use ILIKE, which also ignores case
+		{name: "short english substring", filter: models.JobFilter{Query: "Be"}, want: []string{"english"}},
+		{name: "percent matched literally", filter: models.JobFilter{Query: "0%"}, want: []string{"mixed"}},
+		// Tags are ANDed with each other and with the query
+		{name: "one tag", filter: models.JobFilter{Tags: []string{"wedding"}}, want: []string{"english", "thai-beach"}},
+		{name: "every tag", filter: models.JobFilter{Tags: []string{"wedding", "acoustic"}}, want: []string{"thai-beach"}},
+		{name: "thai query and tag", filter: models.JobFilter{Query: "เพลง", Tags: []string{"acoustic"}}, want: []string{"thai-rain"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, total, err := jobRepo.ListItemsByUserID(ctx, user.ID, tt.filter, 1, 20)
+			if err != nil {
+				t.Fatalf("ListItemsByUserID() error = %v", err)
+			}
+			got := make([]string, 0, len(items))
+			for _, item := range items {
+				got = append(got, ids[item.ID])
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("listed %v, want %v", got, tt.want)
+			}
+			if total != int64(len(tt.want)) {
+				t.Errorf("total = %d, want %d", total, len(tt.want))
+			}
+		})
+	}
+}
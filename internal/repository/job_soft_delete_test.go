@@ -0,0 +1,96 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// TestJobSoftDeleteAndRestore deletes a job, restores it within the restore
+// window, then ages a second deletion past the window and checks that it can no
+// longer be restored and is listed for purging. It needs TEST_DATABASE_URL.
+func TestJobSoftDeleteAndRestore(t *testing.T) {
+	db := testutil.NewDB(t)
+	ctx := context.Background()
+
+	user := &models.User{ID: uuid.New(), Email: "soft-delete-" + uuid.NewString() + "@example.com", PasswordHash: "unused"}
+	if err := repository.NewUserRepository(db).Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	jobRepo := repository.NewJobRepository(db)
+	job := &models.Job{UserID: user.ID, Concept: "city lights at night", Status: models.StatusCompleted}
+	if err := jobRepo.Create(ctx, job); err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+
+	// listed returns the IDs of the user's listed jobs
+	listed := func(includeDeleted bool) []uuid.UUID {
+		t.Helper()
+		items, _, err := jobRepo.ListItemsByUserID(ctx, user.ID, models.JobFilter{IncludeDeleted: includeDeleted}, 1, 20)
+		if err != nil {
+			t.Fatalf("ListItemsByUserID() error = %v", err)
+		}
+		ids := make([]uuid.UUID, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+	restoreCutoff := func() time.Time { return time.Now().Add(-models.JobRestoreWindow) }
+
+	// Deleted: hidden by default, listed with include_deleted
+	if err := jobRepo.SoftDelete(ctx, job.ID); err != nil {
+		t.Fatalf("SoftDelete() error = %v", err)
+	}
+	if ids := listed(false); len(ids) != 0 {
+		t.Errorf("default list = %v, want the deleted job hidden", ids)
+	}
+	if ids := listed(true); !slices.Equal(ids, []uuid.UUID{job.ID}) {
+		t.Errorf("list with deleted jobs = %v, want %s", ids, job.ID)
+	}
+
+	// Within the window: restored
+	if err := jobRepo.Restore(ctx, job.ID, restoreCutoff()); err != nil {
+		t.Fatalf("Restore() within the window error = %v", err)
+	}
+	stored, err := jobRepo.GetByID(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("failed to reload job: %v", err)
+	}
+	if stored.IsDeleted() {
+		t.Errorf("restored job has deleted_at %v", stored.DeletedAt)
+	}
+	if err := jobRepo.Restore(ctx, job.ID, restoreCutoff()); !errors.Is(err, repository.ErrStatusConflict) {
+		t.Errorf("Restore() of a job that is not deleted error = %v, want ErrStatusConflict", err)
+	}
+
+	// Past the window: not restorable, not listed, waiting to be purged
+	if err := jobRepo.SoftDelete(ctx, job.ID); err != nil {
+		t.Fatalf("SoftDelete() error = %v", err)
+	}
+	if _, err := db.Pool().Exec(ctx, `UPDATE jobs SET deleted_at = $2 WHERE id = $1`,
+		job.ID, time.Now().Add(-models.JobRestoreWindow-24*time.Hour)); err != nil {
+		t.Fatalf("failed to age the deletion: %v", err)
+	}
+	if err := jobRepo.Restore(ctx, job.ID, restoreCutoff()); !errors.Is(err, repository.ErrStatusConflict) {
+		t.Errorf("Restore() past the window error = %v, want ErrStatusConflict", err)
+	}
+	if ids := listed(true); len(ids) != 0 {
+		t.Errorf("list with deleted jobs = %v, want the expired job hidden", ids)
+	}
+	purgeable, err := jobRepo.ListDeletedBefore(ctx, restoreCutoff(), 10)
+	if err != nil {
+		t.Fatalf("ListDeletedBefore() error = %v", err)
+	}
+	if !slices.Equal(purgeable, []uuid.UUID{job.ID}) {
+		t.Errorf("ListDeletedBefore() = %v, want %s", purgeable, job.ID)
+	}
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/jaochai/ugc/internal/database"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// ErrJobTemplateNotFound is returned when a job template does not exist.
+var ErrJobTemplateNotFound = errors.New("job template not found")
+
+// ErrJobTemplateLimitReached is returned when a user already has the maximum number of templates.
+var ErrJobTemplateLimitReached = errors.New("job template limit reached")
+
+// JobTemplateRepository defines the interface for job template data access.
+type JobTemplateRepository interface {
+	Create(ctx context.Context, template *models.JobTemplate, limit int) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.JobTemplate, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]*models.JobTemplate, error)
+	Update(ctx context.Context, template *models.JobTemplate) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+const jobTemplateColumns = `id, user_id, name, llm_model, image_candidates, aspect_ratio, prompt_overrides, created_at, updated_at`
+
+type jobTemplateRepository struct {
+	db *database.DB
+}
+
+// NewJobTemplateRepository creates a new JobTemplateRepository instance.
+func NewJobTemplateRepository(db *database.DB) JobTemplateRepository {
+	return &jobTemplateRepository{db: db}
+}
+
+// Create inserts a template unless the user already has limit templates.
+// The owner's user row is locked while counting, so concurrent creates cannot exceed the limit.
+func (r *jobTemplateRepository) Create(ctx context.Context, template *models.JobTemplate, limit int) error {
+	if template.ID == uuid.Nil {
+		template.ID = uuid.New()
+	}
+
+	overrides, err := marshalJSONB(template.PromptOverrides)
+	if err != nil {
+		return fmt.Errorf("failed to marshal prompt_overrides: %w", err)
+	}
+
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, template.UserID); err != nil {
+			return fmt.Errorf("failed to lock template owner: %w", err)
+		}
+
+		query := `
+			INSERT INTO job_templates (id, user_id, name, llm_model, image_candidates, aspect_ratio, prompt_overrides)
+			SELECT $1, $2, $3, $4, $5, $6, $7
+			WHERE (SELECT COUNT(*) FROM job_templates WHERE user_id = $2) < $8
+			RETURNING created_at, updated_at
+		`
+
+		err := tx.QueryRow(ctx, query,
+			template.ID, template.UserID, template.Name, template.Model,
+			template.ImageCandidates, template.AspectRatio, overrides, limit,
+		).Scan(&template.CreatedAt, &template.UpdatedAt)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrJobTemplateLimitReached
+			}
+			return fmt.Errorf("failed to create job template: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// GetByID retrieves a job template by ID.
+func (r *jobTemplateRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.JobTemplate, error) {
+	query := `SELECT ` + jobTemplateColumns + ` FROM job_templates WHERE id = $1`
+
+	template, err := scanJobTemplate(r.db.Pool().QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrJobTemplateNotFound
+		}
+		return nil, fmt.Errorf("failed to get job template: %w", err)
+	}
+
+	return template, nil
+}
+
+// ListByUserID returns all templates owned by a user, newest first.
+func (r *jobTemplateRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*models.JobTemplate, error) {
+	query := `SELECT ` + jobTemplateColumns + ` FROM job_templates WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.Pool().Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := make([]*models.JobTemplate, 0)
+	for rows.Next() {
+		template, err := scanJobTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job template: %w", err)
+		}
+		templates = append(templates, template)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating job templates: %w", err)
+	}
+
+	return templates, nil
+}
+
+// Update replaces a template's name and settings.
+func (r *jobTemplateRepository) Update(ctx context.Context, template *models.JobTemplate) error {
+	overrides, err := marshalJSONB(template.PromptOverrides)
+	if err != nil {
+		return fmt.Errorf("failed to marshal prompt_overrides: %w", err)
+	}
+
+	query := `
+		UPDATE job_templates
+		SET name = $2, llm_model = $3, image_candidates = $4, aspect_ratio = $5,
+			prompt_overrides = $6, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at
+	`
+
+	err = r.db.Pool().QueryRow(ctx, query,
+		template.ID, template.Name, template.Model, template.ImageCandidates,
+		template.AspectRatio, overrides,
+	).Scan(&template.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrJobTemplateNotFound
+		}
+		return fmt.Errorf("failed to update job template: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a job template. Jobs created from it keep their copied settings.
+func (r *jobTemplateRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Pool().Exec(ctx, `DELETE FROM job_templates WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete job template: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrJobTemplateNotFound
+	}
+
+	return nil
+}
+
+// scanJobTemplate scans a row selected with jobTemplateColumns.
+func scanJobTemplate(row pgx.Row) (*models.JobTemplate, error) {
+	var template models.JobTemplate
+	var overrides []byte
+
+	err := row.Scan(
+		&template.ID,
+		&template.UserID,
+		&template.Name,
+		&template.Model,
+		&template.ImageCandidates,
+		&template.AspectRatio,
+		&overrides,
+		&template.CreatedAt,
+		&template.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unmarshalJSONB(overrides, &template.PromptOverrides); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal prompt_overrides: %w", err)
+	}
+
+	return &template, nil
+}
@@ -0,0 +1,135 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// TestJobTemplateRepository round-trips a template with prompt overrides through
+// create, get, list, update and delete. It needs TEST_DATABASE_URL.
+func TestJobTemplateRepository(t *testing.T) {
+	db := testutil.NewDB(t)
+	ctx := context.Background()
+	users := repository.NewUserRepository(db)
+	repo := repository.NewJobTemplateRepository(db)
+
+	owner := &models.User{ID: uuid.New(), Email: "template-" + uuid.NewString() + "@example.com", PasswordHash: "unused"}
+	other := &models.User{ID: uuid.New(), Email: "template-" + uuid.NewString() + "@example.com", PasswordHash: "unused"}
+	for _, user := range []*models.User{owner, other} {
+		if err := users.Create(ctx, user); err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+	}
+
+	model, candidates, ratio, prompt := "google/gemini-2.5-flash", 2, "9:16", "เขียนเพลงป๊อปภาษาไทย"
+	template := &models.JobTemplate{
+		UserID:          owner.ID,
+		Name:            "ป๊อปกลางคืน",
+		Model:           &model,
+		ImageCandidates: &candidates,
+		AspectRatio:     &ratio,
+		PromptOverrides: &models.AgentPrompts{SongConceptPrompt: &prompt},
+	}
+	if err := repo.Create(ctx, template, models.MaxJobTemplatesPerUser); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := repo.Create(ctx, &models.JobTemplate{UserID: other.ID, Name: "other"}, models.MaxJobTemplatesPerUser); err != nil {
+		t.Fatalf("Create() for another user error = %v", err)
+	}
+
+	stored, err := repo.GetByID(ctx, template.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if stored.Name != template.Name || *stored.Model != model || *stored.ImageCandidates != candidates || *stored.AspectRatio != ratio {
+		t.Errorf("stored template = %+v, want %+v", stored, template)
+	}
+	if p := stored.PromptOverrides; p == nil || p.SongConceptPrompt == nil || *p.SongConceptPrompt != prompt || p.ImageConceptPrompt != nil {
+		t.Errorf("stored prompt overrides = %+v, want only the song concept prompt", p)
+	}
+
+	listed, err := repo.ListByUserID(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("ListByUserID() error = %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != template.ID {
+		t.Errorf("ListByUserID() = %d templates, want only the owner's", len(listed))
+	}
+
+	stored.Name, stored.Model, stored.PromptOverrides = "city pop", nil, nil
+	if err := repo.Update(ctx, stored); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	updated, err := repo.GetByID(ctx, template.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if updated.Name != "city pop" || updated.Model != nil || updated.PromptOverrides != nil || *updated.AspectRatio != ratio {
+		t.Errorf("updated template = %+v, want the name changed and the model and overrides cleared", updated)
+	}
+
+	if err := repo.Delete(ctx, template.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := repo.GetByID(ctx, template.ID); !errors.Is(err, repository.ErrJobTemplateNotFound) {
+		t.Errorf("GetByID() after Delete() error = %v, want ErrJobTemplateNotFound", err)
+	}
+	if err := repo.Update(ctx, updated); !errors.Is(err, repository.ErrJobTemplateNotFound) {
+		t.Errorf("Update() after Delete() error = %v, want ErrJobTemplateNotFound", err)
+	}
+	if err := repo.Delete(ctx, template.ID); !errors.Is(err, repository.ErrJobTemplateNotFound) {
+		t.Errorf("Delete() twice error = %v, want ErrJobTemplateNotFound", err)
+	}
+}
+
+// TestJobTemplateLimit creates more templates than the limit at once and checks
+// that exactly the limit is stored. It needs TEST_DATABASE_URL.
+func TestJobTemplateLimit(t *testing.T) {
+	db := testutil.NewDB(t)
+	ctx := context.Background()
+	repo := repository.NewJobTemplateRepository(db)
+
+	owner := &models.User{ID: uuid.New(), Email: "template-limit-" + uuid.NewString() + "@example.com", PasswordHash: "unused"}
+	if err := repository.NewUserRepository(db).Create(ctx, owner); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	const limit, attempts = 5, 12
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.Create(ctx, &models.JobTemplate{UserID: owner.ID, Name: "preset"}, limit)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, repository.ErrJobTemplateLimitReached):
+			t.Errorf("Create() error = %v, want nil or ErrJobTemplateLimitReached", err)
+		}
+	}
+
+	listed, err := repo.ListByUserID(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("ListByUserID() error = %v", err)
+	}
+	if created != limit || len(listed) != limit {
+		t.Errorf("created %d, stored %d templates, want %d", created, len(listed), limit)
+	}
+}
@@ -0,0 +1,94 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// TestJobUpdateRejectsStaleVersion interleaves two writers that loaded the same
+// version of a job and checks that the second write is refused rather than
+// overwriting the first, and succeeds once it re-fetches. It needs
+// TEST_DATABASE_URL.
+func TestJobUpdateRejectsStaleVersion(t *testing.T) {
+	db := testutil.NewDB(t)
+	ctx := context.Background()
+
+	user := &models.User{ID: uuid.New(), Email: "version-" + uuid.NewString() + "@example.com", PasswordHash: "unused"}
+	if err := repository.NewUserRepository(db).Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	jobRepo := repository.NewJobRepository(db)
+	job := &models.Job{UserID: user.ID, Concept: "city lights at night", Status: models.StatusPending}
+	if err := jobRepo.Create(ctx, job); err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+	load := func() *models.Job {
+		t.Helper()
+		loaded, err := jobRepo.GetByID(ctx, job.ID)
+		if err != nil {
+			t.Fatalf("failed to load job: %v", err)
+		}
+		return loaded
+	}
+
+	first, second := load(), load()
+	first.Status = models.StatusAnalyzing
+	if err := jobRepo.Update(ctx, first); err != nil {
+		t.Fatalf("first Update() error = %v", err)
+	}
+	second.Concept = "rain on a tin roof"
+	if err := jobRepo.Update(ctx, second); !errors.Is(err, repository.ErrStatusConflict) {
+		t.Fatalf("Update() of a stale version error = %v, want ErrStatusConflict", err)
+	}
+	stored := load()
+	if stored.Status != models.StatusAnalyzing || stored.Concept != job.Concept {
+		t.Fatalf("stored job = %s %q, want the first write kept (%s %q)", stored.Status, stored.Concept, models.StatusAnalyzing, job.Concept)
+	}
+	if stored.Version != first.Version {
+		t.Errorf("stored version = %d, want %d", stored.Version, first.Version)
+	}
+
+	// Re-fetched, the second write applies on top of the first
+	stored.Concept = "rain on a tin roof"
+	if err := jobRepo.Update(ctx, stored); err != nil {
+		t.Fatalf("Update() after re-fetching error = %v", err)
+	}
+	if reloaded := load(); reloaded.Status != models.StatusAnalyzing || reloaded.Concept != "rain on a tin roof" {
+		t.Errorf("stored job = %s %q, want both writes", reloaded.Status, reloaded.Concept)
+	}
+
+	// Concurrent writers of the same version: exactly one wins
+	writers := []*models.Job{load(), load(), load()}
+	errs := make([]error, len(writers))
+	var wg sync.WaitGroup
+	for i, writer := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			message := "writer " + uuid.NewString()
+			writer.ErrorMessage = &message
+			errs[i] = jobRepo.Update(ctx, writer)
+		}()
+	}
+	wg.Wait()
+	var won int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, repository.ErrStatusConflict):
+			t.Fatalf("concurrent Update() error = %v", err)
+		}
+	}
+	if won != 1 {
+		t.Errorf("%d concurrent writers of one version succeeded, want 1: %v", won, errs)
+	}
+}
@@ -0,0 +1,413 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/jaochai/ugc/internal/database"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// Organization errors.
+var (
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrNotOrgMember         = errors.New("user is not a member of an organization")
+	ErrAlreadyOrgMember     = errors.New("user already belongs to an organization")
+	ErrInvitationExists     = errors.New("a pending invitation for this email already exists")
+	ErrInvitationNotFound   = errors.New("organization invitation not found")
+	ErrInvitationExpired    = errors.New("organization invitation has expired")
+	// ErrInvitationEmailMismatch is returned when the accepting user's email is
+	// not the invited one.
+	ErrInvitationEmailMismatch = errors.New("organization invitation is for another email")
+)
+
+// OrganizationRepository defines the interface for organization data access.
+type OrganizationRepository interface {
+	// Create inserts the organization with ownerID as its owner.
+	Create(ctx context.Context, org *models.Organization, ownerID uuid.UUID) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Organization, error)
+	// GetMembership returns the user's membership, or ErrNotOrgMember.
+	GetMembership(ctx context.Context, userID uuid.UUID) (*models.OrganizationMember, error)
+	ListMembers(ctx context.Context, orgID uuid.UUID) ([]*models.OrganizationMember, error)
+	CreateInvitation(ctx context.Context, invitation *models.OrganizationInvitation) error
+	ListPendingInvitations(ctx context.Context, orgID uuid.UUID) ([]*models.OrganizationInvitation, error)
+	// AcceptInvitation makes the user a member of the organization of the pending
+	// invitation with tokenHash. It returns ErrInvitationNotFound, ErrInvitationExpired,
+	// ErrInvitationEmailMismatch or ErrAlreadyOrgMember when it cannot be accepted.
+	AcceptInvitation(ctx context.Context, userID uuid.UUID, tokenHash string) (*models.OrganizationMember, error)
+	// RevokeInvitation revokes a pending invitation, or returns ErrInvitationNotFound.
+	RevokeInvitation(ctx context.Context, orgID, invitationID uuid.UUID) error
+	UpdateAPIKeys(ctx context.Context, orgID uuid.UUID, openRouterKey, kieKey *string) error
+	// GetAPIKeysForUser returns the encrypted keys of the user's organization;
+	// both are nil when the user belongs to none.
+	GetAPIKeysForUser(ctx context.Context, userID uuid.UUID) (openRouterKey, kieKey *string, err error)
+	ListSecrets(ctx context.Context, afterID uuid.UUID, limit int) ([]*models.OrganizationSecrets, error)
+	ReplaceSecrets(ctx context.Context, current, updated *models.OrganizationSecrets) (bool, error)
+}
+
+type organizationRepository struct {
+	db *database.DB
+}
+
+// NewOrganizationRepository creates a new OrganizationRepository instance.
+func NewOrganizationRepository(db *database.DB) OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+// Create inserts the organization and the owner's membership in one transaction.
+func (r *organizationRepository) Create(ctx context.Context, org *models.Organization, ownerID uuid.UUID) error {
+	if org.ID == uuid.Nil {
+		org.ID = uuid.New()
+	}
+
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO organizations (id, name) VALUES ($1, $2)
+			RETURNING created_at, updated_at
+		`, org.ID, org.Name).Scan(&org.CreatedAt, &org.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create organization: %w", err)
+		}
+
+		return addMember(ctx, tx, org.ID, ownerID, models.OrgRoleOwner)
+	})
+}
+
+// addMember inserts a membership and points users.org_id at the organization.
+func addMember(ctx context.Context, tx pgx.Tx, orgID, userID uuid.UUID, role string) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO organization_members (org_id, user_id, role) VALUES ($1, $2, $3)
+	`, orgID, userID, role)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrAlreadyOrgMember
+		}
+		return fmt.Errorf("failed to add organization member: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE users SET org_id = $1, updated_at = NOW() WHERE id = $2`, orgID, userID); err != nil {
+		return fmt.Errorf("failed to set user organization: %w", err)
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// GetByID retrieves an organization by ID.
+func (r *organizationRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Organization, error) {
+	query := `
+		SELECT id, name, openrouter_api_key, kie_api_key, created_at, updated_at
+		FROM organizations
+		WHERE id = $1
+	`
+
+	org := &models.Organization{}
+	err := r.db.Pool().QueryRow(ctx, query, id).Scan(
+		&org.ID, &org.Name, &org.OpenRouterAPIKey, &org.KIEAPIKey, &org.CreatedAt, &org.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return org, nil
+}
+
+// GetMembership returns the user's membership.
+func (r *organizationRepository) GetMembership(ctx context.Context, userID uuid.UUID) (*models.OrganizationMember, error) {
+	query := `
+		SELECT m.org_id, m.user_id, u.email, u.name, m.role, m.created_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.user_id = $1
+	`
+
+	m := &models.OrganizationMember{}
+	err := r.db.Pool().QueryRow(ctx, query, userID).Scan(&m.OrgID, &m.UserID, &m.Email, &m.Name, &m.Role, &m.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotOrgMember
+		}
+		return nil, fmt.Errorf("failed to get organization membership: %w", err)
+	}
+
+	return m, nil
+}
+
+// ListMembers returns the organization's members, owners first.
+func (r *organizationRepository) ListMembers(ctx context.Context, orgID uuid.UUID) ([]*models.OrganizationMember, error) {
+	query := `
+		SELECT m.org_id, m.user_id, u.email, u.name, m.role, m.created_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.org_id = $1 AND u.deleted_at IS NULL
+		ORDER BY m.role = 'owner' DESC, m.created_at
+	`
+
+	rows, err := r.db.Pool().Query(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization members: %w", err)
+	}
+	defer rows.Close()
+
+	members := make([]*models.OrganizationMember, 0)
+	for rows.Next() {
+		m := &models.OrganizationMember{}
+		if err := rows.Scan(&m.OrgID, &m.UserID, &m.Email, &m.Name, &m.Role, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan organization member: %w", err)
+		}
+		members = append(members, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organization members: %w", err)
+	}
+
+	return members, nil
+}
+
+// CreateInvitation inserts a pending invitation. An expired invitation for the
+// same email is revoked first so it does not block the new one.
+func (r *organizationRepository) CreateInvitation(ctx context.Context, invitation *models.OrganizationInvitation) error {
+	if invitation.ID == uuid.Nil {
+		invitation.ID = uuid.New()
+	}
+
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE organization_invitations SET revoked_at = NOW()
+			WHERE org_id = $1 AND LOWER(email) = LOWER($2)
+				AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= NOW()
+		`, invitation.OrgID, invitation.Email)
+		if err != nil {
+			return fmt.Errorf("failed to revoke expired organization invitations: %w", err)
+		}
+
+		err = tx.QueryRow(ctx, `
+			INSERT INTO organization_invitations (id, org_id, email, role, invited_by, token_hash, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING created_at
+		`, invitation.ID, invitation.OrgID, invitation.Email, invitation.Role, invitation.InvitedBy,
+			invitation.TokenHash, invitation.ExpiresAt,
+		).Scan(&invitation.CreatedAt)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrInvitationExists
+			}
+			return fmt.Errorf("failed to create organization invitation: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListPendingInvitations returns the organization's invitations that were
+// neither accepted nor revoked, newest first. Expired ones are included so
+// owners can see and revoke them.
+func (r *organizationRepository) ListPendingInvitations(ctx context.Context, orgID uuid.UUID) ([]*models.OrganizationInvitation, error) {
+	query := `
+		SELECT id, org_id, email, role, invited_by, expires_at, accepted_at, revoked_at, created_at
+		FROM organization_invitations
+		WHERE org_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Pool().Query(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := make([]*models.OrganizationInvitation, 0)
+	for rows.Next() {
+		inv := &models.OrganizationInvitation{}
+		if err := rows.Scan(&inv.ID, &inv.OrgID, &inv.Email, &inv.Role, &inv.InvitedBy,
+			&inv.ExpiresAt, &inv.AcceptedAt, &inv.RevokedAt, &inv.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan organization invitation: %w", err)
+		}
+		invitations = append(invitations, inv)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organization invitations: %w", err)
+	}
+
+	return invitations, nil
+}
+
+// AcceptInvitation accepts the pending invitation with tokenHash for the user.
+func (r *organizationRepository) AcceptInvitation(ctx context.Context, userID uuid.UUID, tokenHash string) (*models.OrganizationMember, error) {
+	var member *models.OrganizationMember
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		var invitationID, orgID uuid.UUID
+		var email, role string
+		var expiresAt time.Time
+		err := tx.QueryRow(ctx, `
+			SELECT id, org_id, email, role, expires_at
+			FROM organization_invitations
+			WHERE token_hash = $1 AND accepted_at IS NULL AND revoked_at IS NULL
+			FOR UPDATE
+		`, tokenHash).Scan(&invitationID, &orgID, &email, &role, &expiresAt)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvitationNotFound
+			}
+			return fmt.Errorf("failed to find organization invitation: %w", err)
+		}
+		if !time.Now().Before(expiresAt) {
+			return ErrInvitationExpired
+		}
+
+		var emailMatches bool
+		err = tx.QueryRow(ctx, `
+			SELECT LOWER(email) = LOWER($2) FROM users WHERE id = $1 AND deleted_at IS NULL
+		`, userID, email).Scan(&emailMatches)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("failed to check invited email: %w", err)
+		}
+		if !emailMatches {
+			return ErrInvitationEmailMismatch
+		}
+
+		if err := addMember(ctx, tx, orgID, userID, role); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE organization_invitations SET accepted_at = $1 WHERE id = $2`, time.Now().UTC(), invitationID); err != nil {
+			return fmt.Errorf("failed to accept organization invitation: %w", err)
+		}
+
+		member = &models.OrganizationMember{OrgID: orgID, UserID: userID, Email: email, Role: role}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// RevokeInvitation revokes a pending invitation of the organization.
+func (r *organizationRepository) RevokeInvitation(ctx context.Context, orgID, invitationID uuid.UUID) error {
+	result, err := r.db.Pool().Exec(ctx, `
+		UPDATE organization_invitations SET revoked_at = NOW()
+		WHERE id = $1 AND org_id = $2 AND accepted_at IS NULL AND revoked_at IS NULL
+	`, invitationID, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke organization invitation: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// UpdateAPIKeys replaces the organization's encrypted keys; nil clears a key.
+func (r *organizationRepository) UpdateAPIKeys(ctx context.Context, orgID uuid.UUID, openRouterKey, kieKey *string) error {
+	query := `
+		UPDATE organizations
+		SET openrouter_api_key = $2, kie_api_key = $3, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, orgID, openRouterKey, kieKey)
+	if err != nil {
+		return fmt.Errorf("failed to update organization API keys: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrOrganizationNotFound
+	}
+
+	return nil
+}
+
+// GetAPIKeysForUser returns the encrypted keys of the user's organization.
+func (r *organizationRepository) GetAPIKeysForUser(ctx context.Context, userID uuid.UUID) (openRouterKey, kieKey *string, err error) {
+	query := `
+		SELECT o.openrouter_api_key, o.kie_api_key
+		FROM users u
+		JOIN organizations o ON o.id = u.org_id
+		WHERE u.id = $1
+	`
+
+	err = r.db.Pool().QueryRow(ctx, query, userID).Scan(&openRouterKey, &kieKey)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get organization API keys: %w", err)
+	}
+
+	return openRouterKey, kieKey, nil
+}
+
+// ListSecrets returns up to limit organizations with at least one encrypted key,
+// ordered by ID, starting after afterID. Pass uuid.Nil to start from the beginning.
+func (r *organizationRepository) ListSecrets(ctx context.Context, afterID uuid.UUID, limit int) ([]*models.OrganizationSecrets, error) {
+	query := `
+		SELECT id, openrouter_api_key, kie_api_key
+		FROM organizations
+		WHERE id > $1
+		  AND (openrouter_api_key IS NOT NULL OR kie_api_key IS NOT NULL)
+		ORDER BY id
+		LIMIT $2
+	`
+
+	rows, err := r.db.Pool().Query(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization secrets: %w", err)
+	}
+	defer rows.Close()
+
+	secrets := make([]*models.OrganizationSecrets, 0)
+	for rows.Next() {
+		s := &models.OrganizationSecrets{}
+		if err := rows.Scan(&s.OrgID, &s.OpenRouterAPIKey, &s.KIEAPIKey); err != nil {
+			return nil, fmt.Errorf("failed to scan organization secrets: %w", err)
+		}
+		secrets = append(secrets, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organization secrets: %w", err)
+	}
+
+	return secrets, nil
+}
+
+// ReplaceSecrets writes updated keys only if the stored values still equal current,
+// so a key an owner changed concurrently is never overwritten. Returns false if they differ.
+func (r *organizationRepository) ReplaceSecrets(ctx context.Context, current, updated *models.OrganizationSecrets) (bool, error) {
+	query := `
+		UPDATE organizations
+		SET openrouter_api_key = $4, kie_api_key = $5
+		WHERE id = $1
+		  AND openrouter_api_key IS NOT DISTINCT FROM $2
+		  AND kie_api_key IS NOT DISTINCT FROM $3
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query,
+		current.OrgID,
+		current.OpenRouterAPIKey,
+		current.KIEAPIKey,
+		updated.OpenRouterAPIKey,
+		updated.KIEAPIKey,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to replace organization secrets: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
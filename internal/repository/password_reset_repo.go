@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/jaochai/ugc/internal/database"
+)
+
+// ErrPasswordResetNotFound is returned when a reset token is unknown, expired or already used.
+var ErrPasswordResetNotFound = errors.New("password reset not found")
+
+// PasswordResetRepository stores single-use password reset tokens.
+type PasswordResetRepository interface {
+	Create(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) error
+	Consume(ctx context.Context, tokenHash string) (uuid.UUID, error)
+}
+
+type passwordResetRepository struct {
+	db *database.DB
+}
+
+// NewPasswordResetRepository creates a new PasswordResetRepository instance.
+func NewPasswordResetRepository(db *database.DB) PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+// Create records a reset token hash for userID.
+func (r *passwordResetRepository) Create(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO password_resets (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`
+
+	if _, err := r.db.Pool().Exec(ctx, query, userID, tokenHash, expiresAt); err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	return nil
+}
+
+// Consume marks the reset token as used and returns its user ID. It returns
+// ErrPasswordResetNotFound if the token is unknown, expired or already used,
+// so a token can be consumed at most once.
+func (r *passwordResetRepository) Consume(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	query := `
+		UPDATE password_resets
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+
+	var userID uuid.UUID
+	err := r.db.Pool().QueryRow(ctx, query, tokenHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, ErrPasswordResetNotFound
+		}
+		return uuid.Nil, fmt.Errorf("failed to consume password reset: %w", err)
+	}
+
+	return userID, nil
+}
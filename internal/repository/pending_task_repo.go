@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/database"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// pendingTaskClaimLease is how long a claimed outbox entry stays hidden from other drainers.
+const pendingTaskClaimLease = time.Minute
+
+// PendingTaskRepository defines the interface for outbox task data access.
+type PendingTaskRepository interface {
+	Create(ctx context.Context, task *models.PendingTask) error
+	ClaimDue(ctx context.Context, limit int) ([]*models.PendingTask, error)
+	Reschedule(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type pendingTaskRepository struct {
+	db *database.DB
+}
+
+// NewPendingTaskRepository creates a new PendingTaskRepository instance.
+func NewPendingTaskRepository(db *database.DB) PendingTaskRepository {
+	return &pendingTaskRepository{db: db}
+}
+
+// Create inserts a new outbox entry due immediately.
+func (r *pendingTaskRepository) Create(ctx context.Context, task *models.PendingTask) error {
+	query := `
+		INSERT INTO pending_tasks (job_id, user_id, task_type, task_id, payload, last_error, process_at, queue, max_retry, timeout_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, attempts, next_attempt_at, created_at
+	`
+
+	err := r.db.Pool().QueryRow(ctx, query,
+		task.JobID,
+		task.UserID,
+		task.TaskType,
+		task.TaskID,
+		task.Payload,
+		task.LastError,
+		task.ProcessAt,
+		task.Queue,
+		task.MaxRetry,
+		task.TimeoutSeconds,
+	).Scan(&task.ID, &task.Attempts, &task.NextAttemptAt, &task.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create pending task: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimDue returns up to limit due entries, incrementing their attempt count and
+// pushing next_attempt_at forward by a lease so concurrent drainers skip them.
+func (r *pendingTaskRepository) ClaimDue(ctx context.Context, limit int) ([]*models.PendingTask, error) {
+	query := `
+		UPDATE pending_tasks
+		SET attempts = attempts + 1,
+			next_attempt_at = NOW() + $2::interval
+		WHERE id IN (
+			SELECT id FROM pending_tasks
+			WHERE next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, job_id, user_id, task_type, task_id, payload, attempts, last_error, next_attempt_at, created_at,
+			process_at, queue, max_retry, timeout_seconds
+	`
+
+	rows, err := r.db.Pool().Query(ctx, query, limit, pendingTaskClaimLease.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim pending tasks: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := make([]*models.PendingTask, 0)
+	for rows.Next() {
+		task := &models.PendingTask{}
+		if err := rows.Scan(
+			&task.ID,
+			&task.JobID,
+			&task.UserID,
+			&task.TaskType,
+			&task.TaskID,
+			&task.Payload,
+			&task.Attempts,
+			&task.LastError,
+			&task.NextAttemptAt,
+			&task.CreatedAt,
+			&task.ProcessAt,
+			&task.Queue,
+			&task.MaxRetry,
+			&task.TimeoutSeconds,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan pending task: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pending tasks: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// Reschedule records a failed attempt and sets the next attempt time.
+func (r *pendingTaskRepository) Reschedule(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	query := `UPDATE pending_tasks SET next_attempt_at = $2, last_error = $3 WHERE id = $1`
+
+	if _, err := r.db.Pool().Exec(ctx, query, id, nextAttemptAt, lastError); err != nil {
+		return fmt.Errorf("failed to reschedule pending task: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes an outbox entry once its task is enqueued.
+func (r *pendingTaskRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.db.Pool().Exec(ctx, `DELETE FROM pending_tasks WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete pending task: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/jaochai/ugc/internal/database"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// ErrPlatformQuotaExceeded is returned when a reservation would take a user over
+// a platform key quota.
+var ErrPlatformQuotaExceeded = errors.New("platform key quota exceeded")
+
+// PlatformQuotaRepository defines the interface for platform key quota reservations.
+type PlatformQuotaRepository interface {
+	// Reserve records reservation unless it takes the user over limits, in which
+	// case it returns ErrPlatformQuotaExceeded. Concurrent reservations of a user
+	// are serialized. The usage before the reservation is returned either way.
+	Reserve(ctx context.Context, reservation *models.PlatformQuotaReservation, limits models.PlatformQuotaLimits) (*models.PlatformQuotaUsage, error)
+	// Release returns part of a reservation, e.g. for jobs that were not created.
+	Release(ctx context.Context, id uuid.UUID, openRouterJobs, kieCredits int) error
+}
+
+type platformQuotaRepository struct {
+	db *database.DB
+}
+
+// NewPlatformQuotaRepository creates a new PlatformQuotaRepository instance.
+func NewPlatformQuotaRepository(db *database.DB) PlatformQuotaRepository {
+	return &platformQuotaRepository{db: db}
+}
+
+// Reserve locks the user's quota row, sums their usage and inserts the
+// reservation in one transaction.
+func (r *platformQuotaRepository) Reserve(ctx context.Context, reservation *models.PlatformQuotaReservation, limits models.PlatformQuotaLimits) (*models.PlatformQuotaUsage, error) {
+	if reservation.ID == uuid.Nil {
+		reservation.ID = uuid.New()
+	}
+
+	usage := &models.PlatformQuotaUsage{}
+	err := r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `INSERT INTO platform_quota_locks (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING`, reservation.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to create platform quota lock: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM platform_quota_locks WHERE user_id = $1 FOR UPDATE`, reservation.UserID); err != nil {
+			return fmt.Errorf("failed to lock platform quota: %w", err)
+		}
+
+		var reservedKIECredits, spentKIECredits int
+		err = tx.QueryRow(ctx, `
+			SELECT
+				COALESCE(SUM(openrouter_jobs) FILTER (WHERE created_at >= $2), 0),
+				COALESCE(SUM(kie_credits) FILTER (WHERE created_at >= $3), 0)
+			FROM platform_quota_reservations
+			WHERE user_id = $1 AND created_at >= LEAST($2, $3)
+		`, reservation.UserID, limits.OpenRouterSince, limits.KIESince).Scan(&usage.OpenRouterJobs, &reservedKIECredits)
+		if err != nil {
+			return fmt.Errorf("failed to sum platform quota reservations: %w", err)
+		}
+		err = tx.QueryRow(ctx, `
+			SELECT COALESCE(SUM(credits), 0)
+			FROM user_spend
+			WHERE user_id = $1 AND key_source = $2 AND created_at >= $3
+		`, reservation.UserID, models.KeySourcePlatform, limits.KIESince).Scan(&spentKIECredits)
+		if err != nil {
+			return fmt.Errorf("failed to sum platform spend: %w", err)
+		}
+		usage.KIECredits = max(spentKIECredits, reservedKIECredits)
+
+		if reservation.OpenRouterJobs > 0 && usage.OpenRouterJobs+reservation.OpenRouterJobs > limits.OpenRouterJobs {
+			return ErrPlatformQuotaExceeded
+		}
+		if reservation.KIECredits > 0 && usage.KIECredits+reservation.KIECredits > limits.KIECredits {
+			return ErrPlatformQuotaExceeded
+		}
+
+		err = tx.QueryRow(ctx, `
+			INSERT INTO platform_quota_reservations (id, user_id, openrouter_jobs, kie_credits)
+			VALUES ($1, $2, $3, $4)
+			RETURNING created_at
+		`, reservation.ID, reservation.UserID, reservation.OpenRouterJobs, reservation.KIECredits).Scan(&reservation.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create platform quota reservation: %w", err)
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// Release lowers a reservation's amounts, never below zero.
+func (r *platformQuotaRepository) Release(ctx context.Context, id uuid.UUID, openRouterJobs, kieCredits int) error {
+	_, err := r.db.Pool().Exec(ctx, `
+		UPDATE platform_quota_reservations
+		SET openrouter_jobs = GREATEST(openrouter_jobs - $2, 0),
+			kie_credits = GREATEST(kie_credits - $3, 0)
+		WHERE id = $1
+	`, id, openRouterJobs, kieCredits)
+	if err != nil {
+		return fmt.Errorf("failed to release platform quota reservation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// TestPlatformQuotaReserve checks that reservations are refused past the quota,
+// that concurrent reservations of a user cannot both take the last credits, and
+// that a task's spend counts once however often it is recorded. It needs
+// TEST_DATABASE_URL.
+func TestPlatformQuotaReserve(t *testing.T) {
+	db := testutil.NewDB(t)
+	ctx := context.Background()
+
+	user := &models.User{ID: uuid.New(), Email: "quota-" + uuid.NewString() + "@example.com", PasswordHash: "unused"}
+	if err := repository.NewUserRepository(db).Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	quotaRepo := repository.NewPlatformQuotaRepository(db)
+	spendRepo := repository.NewUserSpendRepository(db)
+	since := time.Now().Add(-time.Hour)
+	limits := models.PlatformQuotaLimits{OpenRouterJobs: 10, OpenRouterSince: since, KIECredits: 30, KIESince: since}
+	reserve := func(credits int) (*models.PlatformQuotaUsage, error) {
+		return quotaRepo.Reserve(ctx, &models.PlatformQuotaReservation{UserID: user.ID, KIECredits: credits}, limits)
+	}
+
+	// Two concurrent reservations of 20 credits: one fits, the other does not
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = reserve(20)
+		}()
+	}
+	wg.Wait()
+	var exceeded int
+	for _, err := range errs {
+		switch {
+		case errors.Is(err, repository.ErrPlatformQuotaExceeded):
+			exceeded++
+		case err != nil:
+			t.Fatalf("Reserve() error = %v", err)
+		}
+	}
+	if exceeded != 1 {
+		t.Fatalf("concurrent reservations refused %d times, want 1: %v", exceeded, errs)
+	}
+
+	// Spend recorded for the same task twice counts once
+	taskID := "suno-" + uuid.NewString()
+	for range 2 {
+		spend := &models.UserSpend{UserID: user.ID, Kind: models.SpendKindMusic, KeySource: models.KeySourcePlatform, Credits: 25, ProviderTaskID: &taskID}
+		if err := spendRepo.Record(ctx, spend); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	usage, err := reserve(5)
+	if err != nil {
+		t.Fatalf("Reserve() within the quota error = %v", err)
+	}
+	if usage.KIECredits != 25 {
+		t.Errorf("usage before reservation = %d credits, want the task's 25 counted once", usage.KIECredits)
+	}
+
+	// 25 spent and 25 reserved: 5 credits left
+	if _, err := reserve(6); !errors.Is(err, repository.ErrPlatformQuotaExceeded) {
+		t.Errorf("Reserve() past the quota error = %v, want ErrPlatformQuotaExceeded", err)
+	}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jaochai/ugc/internal/database"
+)
+
+// ProcessedWebhookRepository records which webhook callbacks have been handled.
+type ProcessedWebhookRepository interface {
+	MarkProcessed(ctx context.Context, source, taskID, callbackType string) (bool, error)
+	Unmark(ctx context.Context, source, taskID, callbackType string) error
+}
+
+type processedWebhookRepository struct {
+	db *database.DB
+}
+
+// NewProcessedWebhookRepository creates a new ProcessedWebhookRepository instance.
+func NewProcessedWebhookRepository(db *database.DB) ProcessedWebhookRepository {
+	return &processedWebhookRepository{db: db}
+}
+
+// MarkProcessed records a callback. It returns false if the same callback
+// (source, task ID and callback type) was already recorded.
+func (r *processedWebhookRepository) MarkProcessed(ctx context.Context, source, taskID, callbackType string) (bool, error) {
+	query := `
+		INSERT INTO processed_webhooks (source, task_id, callback_type)
+		VALUES ($1, $2, $3)
+		ON CONFLICT ON CONSTRAINT processed_webhooks_unique DO NOTHING
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, source, taskID, callbackType)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark webhook processed: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+// Unmark removes a callback record so a retry of a failed callback is processed again.
+func (r *processedWebhookRepository) Unmark(ctx context.Context, source, taskID, callbackType string) error {
+	query := `DELETE FROM processed_webhooks WHERE source = $1 AND task_id = $2 AND callback_type = $3`
+
+	if _, err := r.db.Pool().Exec(ctx, query, source, taskID, callbackType); err != nil {
+		return fmt.Errorf("failed to unmark webhook processed: %w", err)
+	}
+
+	return nil
+}
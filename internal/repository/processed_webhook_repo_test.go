@@ -0,0 +1,59 @@
+package repository_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// TestMarkProcessedConcurrently records the same callback from several goroutines
+// and checks that exactly one of them claims it. It needs TEST_DATABASE_URL.
+func TestMarkProcessedConcurrently(t *testing.T) {
+	db := testutil.NewDB(t)
+	ctx := context.Background()
+	repo := repository.NewProcessedWebhookRepository(db)
+	taskID := "suno-" + uuid.NewString()
+
+	claimed := make([]bool, 5)
+	var wg sync.WaitGroup
+	for i := range claimed {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			first, err := repo.MarkProcessed(ctx, "suno", taskID, "complete")
+			if err != nil {
+				t.Errorf("MarkProcessed() error = %v", err)
+			}
+			claimed[i] = first
+		}()
+	}
+	wg.Wait()
+
+	var claims int
+	for _, first := range claimed {
+		if first {
+			claims++
+		}
+	}
+	if claims != 1 {
+		t.Fatalf("callback claimed %d times, want once", claims)
+	}
+
+	// Another callback type of the same task is a different callback
+	if first, err := repo.MarkProcessed(ctx, "suno", taskID, "first"); err != nil || !first {
+		t.Errorf("MarkProcessed() of another callback type = %v, %v, want claimed", first, err)
+	}
+
+	// Unmarked, a failed callback's retry is claimed again
+	if err := repo.Unmark(ctx, "suno", taskID, "complete"); err != nil {
+		t.Fatalf("Unmark() error = %v", err)
+	}
+	if first, err := repo.MarkProcessed(ctx, "suno", taskID, "complete"); err != nil || !first {
+		t.Errorf("MarkProcessed() after Unmark = %v, %v, want claimed", first, err)
+	}
+}
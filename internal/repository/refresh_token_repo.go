@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/jaochai/ugc/internal/database"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// ErrRefreshTokenNotFound is returned when a refresh token hash is unknown.
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+// ErrRefreshTokenRevoked is returned when rotating a token that was already revoked.
+var ErrRefreshTokenRevoked = errors.New("refresh token revoked")
+
+// RefreshTokenRepository stores hashed refresh tokens.
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	Rotate(ctx context.Context, oldID uuid.UUID, next *models.RefreshToken) error
+	Revoke(ctx context.Context, id uuid.UUID) error
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+type refreshTokenRepository struct {
+	db *database.DB
+}
+
+// NewRefreshTokenRepository creates a new RefreshTokenRepository instance.
+func NewRefreshTokenRepository(db *database.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+const insertRefreshTokenQuery = `
+	INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, user_agent, ip_address, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING created_at
+`
+
+// Create inserts a new refresh token.
+func (r *refreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	if token.ID == uuid.Nil {
+		token.ID = uuid.New()
+	}
+
+	err := r.db.Pool().QueryRow(ctx, insertRefreshTokenQuery,
+		token.ID, token.UserID, token.FamilyID, token.TokenHash, token.UserAgent, token.IPAddress, token.ExpiresAt,
+	).Scan(&token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// GetByHash retrieves a refresh token by the hash of its value, including revoked and expired tokens.
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, token_hash, user_agent, ip_address, expires_at, revoked_at, replaced_by, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	token := &models.RefreshToken{}
+	err := r.db.Pool().QueryRow(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.UserAgent,
+		&token.IPAddress,
+		&token.ExpiresAt,
+		&token.RevokedAt,
+		&token.ReplacedBy,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+// Rotate revokes oldID and inserts next in one transaction. It returns
+// ErrRefreshTokenRevoked if oldID was already revoked, e.g. by a concurrent
+// refresh with the same token.
+func (r *refreshTokenRepository) Rotate(ctx context.Context, oldID uuid.UUID, next *models.RefreshToken) error {
+	if next.ID == uuid.Nil {
+		next.ID = uuid.New()
+	}
+
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, insertRefreshTokenQuery,
+			next.ID, next.UserID, next.FamilyID, next.TokenHash, next.UserAgent, next.IPAddress, next.ExpiresAt,
+		).Scan(&next.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create refresh token: %w", err)
+		}
+
+		result, err := tx.Exec(ctx, `
+			UPDATE refresh_tokens
+			SET revoked_at = NOW(), replaced_by = $2
+			WHERE id = $1 AND revoked_at IS NULL
+		`, oldID, next.ID)
+		if err != nil {
+			return fmt.Errorf("failed to revoke refresh token: %w", err)
+		}
+		if result.RowsAffected() == 0 {
+			return ErrRefreshTokenRevoked
+		}
+
+		return nil
+	})
+}
+
+// Revoke revokes a single refresh token. Revoking an already revoked token is a no-op.
+func (r *refreshTokenRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+
+	if _, err := r.db.Pool().Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeFamily revokes every token issued from the same login.
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+
+	if _, err := r.db.Pool().Exec(ctx, query, familyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAllForUser revokes every refresh token of a user, signing out all devices.
+func (r *refreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+
+	if _, err := r.db.Pool().Exec(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to revoke user refresh tokens: %w", err)
+	}
+
+	return nil
+}
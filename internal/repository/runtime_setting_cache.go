@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// DefaultRuntimeSettingsCacheTTL is how long cached runtime settings are served
+// before re-reading the DB. Other API instances see an update within this time.
+const DefaultRuntimeSettingsCacheTTL = 10 * time.Second
+
+// cachedRuntimeSettingRepository caches the runtime settings in memory for a
+// short TTL. Every job creation checks them, so reads are served from memory;
+// updates go through to the wrapped repository and invalidate this instance's cache.
+type cachedRuntimeSettingRepository struct {
+	inner RuntimeSettingRepository
+	ttl   time.Duration
+
+	mu       sync.RWMutex
+	settings *models.RuntimeSettings
+	expiry   time.Time
+	// generation is bumped on invalidation so a read that started before an
+	// update cannot repopulate the cache with the old value.
+	generation uint64
+}
+
+// NewCachedRuntimeSettingRepository wraps inner with an in-memory cache.
+// A non-positive ttl uses DefaultRuntimeSettingsCacheTTL.
+func NewCachedRuntimeSettingRepository(inner RuntimeSettingRepository, ttl time.Duration) RuntimeSettingRepository {
+	if ttl <= 0 {
+		ttl = DefaultRuntimeSettingsCacheTTL
+	}
+	return &cachedRuntimeSettingRepository{
+		inner: inner,
+		ttl:   ttl,
+	}
+}
+
+// Get returns the cached settings, loading them from the wrapped repository on
+// miss or expiry. Errors are never cached.
+func (r *cachedRuntimeSettingRepository) Get(ctx context.Context) (*models.RuntimeSettings, error) {
+	r.mu.RLock()
+	cached, expiry := r.settings, r.expiry
+	generation := r.generation
+	r.mu.RUnlock()
+	if cached != nil && time.Now().Before(expiry) {
+		settings := *cached
+		return &settings, nil
+	}
+
+	settings, err := r.inner.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if r.generation == generation {
+		copied := *settings
+		r.settings = &copied
+		r.expiry = time.Now().Add(r.ttl)
+	}
+	r.mu.Unlock()
+
+	return settings, nil
+}
+
+// Update writes through to the wrapped repository and invalidates the cache.
+func (r *cachedRuntimeSettingRepository) Update(ctx context.Context, input models.UpdateRuntimeSettingsInput, updatedBy uuid.UUID) error {
+	err := r.inner.Update(ctx, input, updatedBy)
+	r.Invalidate()
+	return err
+}
+
+// Invalidate drops the cached settings.
+func (r *cachedRuntimeSettingRepository) Invalidate() {
+	r.mu.Lock()
+	r.settings = nil
+	r.generation++
+	r.mu.Unlock()
+}
@@ -0,0 +1,112 @@
+package repository_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+func TestCachedRuntimeSettingsReloadAfterExpiry(t *testing.T) {
+	ctx := context.Background()
+	inner := testutil.NewFakeRuntimeSettingRepository(models.RuntimeSettings{BannerMessage: "maintenance at 22:00"})
+	cache := repository.NewCachedRuntimeSettingRepository(inner, 50*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		settings, err := cache.Get(ctx)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if settings.BannerMessage != "maintenance at 22:00" {
+			t.Fatalf("banner = %q, want the stored one", settings.BannerMessage)
+		}
+	}
+	if got := inner.Gets(); got != 1 {
+		t.Fatalf("inner reads before expiry = %d, want 1", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := cache.Get(ctx); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := inner.Gets(); got != 2 {
+		t.Errorf("inner reads after expiry = %d, want 2", got)
+	}
+}
+
+func TestCachedRuntimeSettingsUpdateInvalidates(t *testing.T) {
+	ctx := context.Background()
+	inner := testutil.NewFakeRuntimeSettingRepository(models.RuntimeSettings{})
+	cache := repository.NewCachedRuntimeSettingRepository(inner, time.Hour)
+
+	if settings, err := cache.Get(ctx); err != nil || settings.JobIntakePaused {
+		t.Fatalf("Get = %+v, %v; want intake open", settings, err)
+	}
+
+	paused := true
+	if err := cache.Update(ctx, models.UpdateRuntimeSettingsInput{JobIntakePaused: &paused}, uuid.New()); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	settings, err := cache.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !settings.JobIntakePaused {
+		t.Error("Get after Update returned the cached settings, want intake paused")
+	}
+	if got := inner.Gets(); got != 2 {
+		t.Errorf("inner reads = %d, want 2", got)
+	}
+}
+
+// TestCachedRuntimeSettingsRacedReadDoesNotRepopulate holds a read of the old
+// settings in flight across an Update; the read must not cache what it loaded.
+func TestCachedRuntimeSettingsRacedReadDoesNotRepopulate(t *testing.T) {
+	ctx := context.Background()
+	inner := testutil.NewFakeRuntimeSettingRepository(models.RuntimeSettings{})
+	cache := repository.NewCachedRuntimeSettingRepository(inner, time.Hour)
+
+	loaded := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	inner.OnGet = func() {
+		once.Do(func() {
+			close(loaded)
+			<-release
+		})
+	}
+
+	raced := make(chan *models.RuntimeSettings)
+	go func() {
+		settings, err := cache.Get(ctx)
+		if err != nil {
+			t.Errorf("raced Get: %v", err)
+		}
+		raced <- settings
+	}()
+
+	<-loaded
+	paused := true
+	if err := cache.Update(ctx, models.UpdateRuntimeSettingsInput{JobIntakePaused: &paused}, uuid.New()); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	close(release)
+	if settings := <-raced; settings == nil || settings.JobIntakePaused {
+		t.Fatalf("raced Get = %+v, want the settings from before the update", settings)
+	}
+
+	settings, err := cache.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !settings.JobIntakePaused {
+		t.Error("the raced read repopulated the cache with the settings from before the update")
+	}
+}
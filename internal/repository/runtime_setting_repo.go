@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/database"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// RuntimeSettingRepository defines the interface for runtime setting data access.
+type RuntimeSettingRepository interface {
+	// Get returns the current settings; keys without a row keep their zero values.
+	Get(ctx context.Context) (*models.RuntimeSettings, error)
+	// Update writes the fields set in input in one statement.
+	Update(ctx context.Context, input models.UpdateRuntimeSettingsInput, updatedBy uuid.UUID) error
+}
+
+type runtimeSettingRepository struct {
+	db *database.DB
+}
+
+// NewRuntimeSettingRepository creates a new RuntimeSettingRepository instance.
+func NewRuntimeSettingRepository(db *database.DB) RuntimeSettingRepository {
+	return &runtimeSettingRepository{db: db}
+}
+
+// Get reads every runtime setting row into RuntimeSettings. Unknown keys are ignored.
+func (r *runtimeSettingRepository) Get(ctx context.Context) (*models.RuntimeSettings, error) {
+	query := `SELECT key, value, updated_at FROM runtime_settings`
+
+	rows, err := r.db.Pool().Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get runtime settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := &models.RuntimeSettings{}
+	for rows.Next() {
+		var key, value string
+		var updatedAt time.Time
+		if err := rows.Scan(&key, &value, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan runtime setting: %w", err)
+		}
+
+		switch key {
+		case models.SettingJobIntakePaused:
+			settings.JobIntakePaused, _ = strconv.ParseBool(value)
+		case models.SettingBannerMessage:
+			settings.BannerMessage = value
+		default:
+			continue
+		}
+		if settings.UpdatedAt == nil || updatedAt.After(*settings.UpdatedAt) {
+			settings.UpdatedAt = &updatedAt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate runtime settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// Update upserts the settings set in input.
+func (r *runtimeSettingRepository) Update(ctx context.Context, input models.UpdateRuntimeSettingsInput, updatedBy uuid.UUID) error {
+	var keys, values []string
+	if input.JobIntakePaused != nil {
+		keys = append(keys, models.SettingJobIntakePaused)
+		values = append(values, strconv.FormatBool(*input.JobIntakePaused))
+	}
+	if input.BannerMessage != nil {
+		keys = append(keys, models.SettingBannerMessage)
+		values = append(values, *input.BannerMessage)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO runtime_settings (key, value, updated_by, updated_at)
+		SELECT key, value, $3, NOW()
+		FROM unnest($1::text[], $2::text[]) AS s(key, value)
+		ON CONFLICT (key) DO UPDATE
+		SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+	`
+
+	if _, err := r.db.Pool().Exec(ctx, query, keys, values, updatedBy); err != nil {
+		return fmt.Errorf("failed to update runtime settings: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// DefaultSystemPromptCacheTTL is how long cached system prompts are served before re-reading the DB.
+const DefaultSystemPromptCacheTTL = 5 * time.Minute
+
+// SystemPromptCacheInvalidator is implemented by system prompt repositories that cache reads.
+type SystemPromptCacheInvalidator interface {
+	Invalidate()
+}
+
+// cachedSystemPromptRepository caches system prompts in memory for a fixed TTL.
+// Prompts change rarely but are read by every agent in every task, so reads are
+// served from memory and only fall back to the wrapped repository on miss or expiry.
+// Writes go through to the wrapped repository and invalidate the cache.
+type cachedSystemPromptRepository struct {
+	inner SystemPromptRepository
+	ttl   time.Duration
+
+	mu        sync.RWMutex
+	byType    map[string]cachedSystemPrompt
+	all       []models.SystemPrompt
+	allExpiry time.Time
+	// generation is bumped on invalidation so a read that started before an
+	// update cannot repopulate the cache with the old value.
+	generation uint64
+}
+
+type cachedSystemPrompt struct {
+	prompt models.SystemPrompt
+	expiry time.Time
+}
+
+// NewCachedSystemPromptRepository wraps inner with an in-memory cache.
+// A non-positive ttl uses DefaultSystemPromptCacheTTL.
+func NewCachedSystemPromptRepository(inner SystemPromptRepository, ttl time.Duration) SystemPromptRepository {
+	if ttl <= 0 {
+		ttl = DefaultSystemPromptCacheTTL
+	}
+	return &cachedSystemPromptRepository{
+		inner:  inner,
+		ttl:    ttl,
+		byType: make(map[string]cachedSystemPrompt),
+	}
+}
+
+// GetByType returns the cached prompt for promptType, loading it from the wrapped repository on miss.
+// Errors (including ErrSystemPromptNotFound) are never cached.
+func (r *cachedSystemPromptRepository) GetByType(ctx context.Context, promptType string) (*models.SystemPrompt, error) {
+	r.mu.RLock()
+	entry, ok := r.byType[promptType]
+	generation := r.generation
+	r.mu.RUnlock()
+	if ok && time.Now().Before(entry.expiry) {
+		prompt := entry.prompt
+		return &prompt, nil
+	}
+
+	prompt, err := r.inner.GetByType(ctx, promptType)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if r.generation == generation {
+		r.byType[promptType] = cachedSystemPrompt{prompt: *prompt, expiry: time.Now().Add(r.ttl)}
+	}
+	r.mu.Unlock()
+
+	return prompt, nil
+}
+
+// GetAll returns all cached prompts, loading them from the wrapped repository on miss.
+func (r *cachedSystemPromptRepository) GetAll(ctx context.Context) ([]models.SystemPrompt, error) {
+	r.mu.RLock()
+	all, expiry := r.all, r.allExpiry
+	generation := r.generation
+	r.mu.RUnlock()
+	if all != nil && time.Now().Before(expiry) {
+		return append([]models.SystemPrompt(nil), all...), nil
+	}
+
+	prompts, err := r.inner.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if r.generation == generation {
+		r.all = append([]models.SystemPrompt{}, prompts...)
+		r.allExpiry = time.Now().Add(r.ttl)
+	}
+	r.mu.Unlock()
+
+	return prompts, nil
+}
+
+// Update writes through to the wrapped repository and invalidates the cache.
+func (r *cachedSystemPromptRepository) Update(ctx context.Context, promptType string, content string, updatedBy uuid.UUID) error {
+	err := r.inner.Update(ctx, promptType, content, updatedBy)
+	r.Invalidate()
+	return err
+}
+
+// ListRevisions reads through to the wrapped repository; revisions are not cached.
+func (r *cachedSystemPromptRepository) ListRevisions(ctx context.Context, promptType string, page, perPage int) ([]models.SystemPromptRevision, int64, error) {
+	return r.inner.ListRevisions(ctx, promptType, page, perPage)
+}
+
+// GetRevision reads through to the wrapped repository; revisions are not cached.
+func (r *cachedSystemPromptRepository) GetRevision(ctx context.Context, promptType string, revisionID uuid.UUID) (*models.SystemPromptRevision, error) {
+	return r.inner.GetRevision(ctx, promptType, revisionID)
+}
+
+// Invalidate drops all cached prompts so the next read hits the database.
+func (r *cachedSystemPromptRepository) Invalidate() {
+	r.mu.Lock()
+	r.byType = make(map[string]cachedSystemPrompt)
+	r.all = nil
+	r.allExpiry = time.Time{}
+	r.generation++
+	r.mu.Unlock()
+}
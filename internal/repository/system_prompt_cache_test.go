@@ -0,0 +1,177 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// getPrompt reads promptType through repo and returns its content.
+func getPrompt(t *testing.T, repo repository.SystemPromptRepository, promptType string) string {
+	t.Helper()
+	prompt, err := repo.GetByType(context.Background(), promptType)
+	if err != nil {
+		t.Fatalf("GetByType(%s): %v", promptType, err)
+	}
+	return prompt.PromptContent
+}
+
+// TestCachedSystemPromptsStaleUntilExpiry changes a prompt behind the cache's
+// back, as another process would, and checks the old content is served until
+// the TTL passes.
+func TestCachedSystemPromptsStaleUntilExpiry(t *testing.T) {
+	ctx := context.Background()
+	inner := testutil.NewFakeSystemPromptRepository(map[string]string{models.PromptTypeSongConcept: "v1"})
+	cache := repository.NewCachedSystemPromptRepository(inner, 50*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		if got := getPrompt(t, cache, models.PromptTypeSongConcept); got != "v1" {
+			t.Fatalf("content = %q, want v1", got)
+		}
+	}
+	if got := inner.Reads(); got != 1 {
+		t.Fatalf("inner reads before expiry = %d, want 1", got)
+	}
+
+	if err := inner.Update(ctx, models.PromptTypeSongConcept, "v2", uuid.New()); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := getPrompt(t, cache, models.PromptTypeSongConcept); got != "v1" {
+		t.Errorf("content within the TTL = %q, want the cached v1", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if got := getPrompt(t, cache, models.PromptTypeSongConcept); got != "v2" {
+		t.Errorf("content after expiry = %q, want v2", got)
+	}
+	if got := inner.Reads(); got != 2 {
+		t.Errorf("inner reads after expiry = %d, want 2", got)
+	}
+}
+
+// TestCachedSystemPromptsInvalidation checks that both an Update through the
+// cache and an explicit Invalidate, as the admin handler calls, drop the cached
+// prompt and the cached list.
+func TestCachedSystemPromptsInvalidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, inner *testutil.FakeSystemPromptRepository, cache repository.SystemPromptRepository)
+	}{
+		{
+			name: "update",
+			change: func(t *testing.T, inner *testutil.FakeSystemPromptRepository, cache repository.SystemPromptRepository) {
+				if err := cache.Update(context.Background(), models.PromptTypeSongConcept, "v2", uuid.New()); err != nil {
+					t.Fatalf("Update: %v", err)
+				}
+			},
+		},
+		{
+			name: "invalidate",
+			change: func(t *testing.T, inner *testutil.FakeSystemPromptRepository, cache repository.SystemPromptRepository) {
+				if err := inner.Update(context.Background(), models.PromptTypeSongConcept, "v2", uuid.New()); err != nil {
+					t.Fatalf("Update: %v", err)
+				}
+				invalidator, ok := cache.(repository.SystemPromptCacheInvalidator)
+				if !ok {
+					t.Fatal("the cached repository does not implement SystemPromptCacheInvalidator")
+				}
+				invalidator.Invalidate()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			inner := testutil.NewFakeSystemPromptRepository(map[string]string{models.PromptTypeSongConcept: "v1"})
+			cache := repository.NewCachedSystemPromptRepository(inner, time.Hour)
+
+			getPrompt(t, cache, models.PromptTypeSongConcept)
+			if _, err := cache.GetAll(ctx); err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+
+			tt.change(t, inner, cache)
+
+			if got := getPrompt(t, cache, models.PromptTypeSongConcept); got != "v2" {
+				t.Errorf("GetByType after the change = %q, want v2", got)
+			}
+			all, err := cache.GetAll(ctx)
+			if err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+			if len(all) != 1 || all[0].PromptContent != "v2" {
+				t.Errorf("GetAll after the change = %+v, want only v2", all)
+			}
+			if got := inner.Reads(); got != 4 {
+				t.Errorf("inner reads = %d, want 4", got)
+			}
+		})
+	}
+}
+
+// TestCachedSystemPromptsRacedReadDoesNotRepopulate holds a read of the old
+// prompt in flight across an Update; the read must not cache what it loaded.
+func TestCachedSystemPromptsRacedReadDoesNotRepopulate(t *testing.T) {
+	ctx := context.Background()
+	inner := testutil.NewFakeSystemPromptRepository(map[string]string{models.PromptTypeImageConcept: "v1"})
+	cache := repository.NewCachedSystemPromptRepository(inner, time.Hour)
+
+	loaded := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	inner.OnGetByType = func() {
+		once.Do(func() {
+			close(loaded)
+			<-release
+		})
+	}
+
+	raced := make(chan string)
+	go func() {
+		prompt, err := cache.GetByType(ctx, models.PromptTypeImageConcept)
+		if err != nil {
+			t.Errorf("raced GetByType: %v", err)
+			raced <- ""
+			return
+		}
+		raced <- prompt.PromptContent
+	}()
+
+	<-loaded
+	if err := cache.Update(ctx, models.PromptTypeImageConcept, "v2", uuid.New()); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	close(release)
+	if got := <-raced; got != "v1" {
+		t.Fatalf("raced GetByType = %q, want the v1 from before the update", got)
+	}
+
+	if got := getPrompt(t, cache, models.PromptTypeImageConcept); got != "v2" {
+		t.Errorf("content = %q: the raced read repopulated the cache with v1", got)
+	}
+}
+
+// TestCachedSystemPromptsNotFoundNotCached checks that a miss is read through
+// every time, so a prompt seeded later is picked up without waiting for the TTL.
+func TestCachedSystemPromptsNotFoundNotCached(t *testing.T) {
+	inner := testutil.NewFakeSystemPromptRepository(nil)
+	cache := repository.NewCachedSystemPromptRepository(inner, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.GetByType(context.Background(), models.PromptTypeSongSelector); !errors.Is(err, repository.ErrSystemPromptNotFound) {
+			t.Fatalf("GetByType error = %v, want ErrSystemPromptNotFound", err)
+		}
+	}
+	if got := inner.Reads(); got != 2 {
+		t.Errorf("inner reads = %d, want 2", got)
+	}
+}
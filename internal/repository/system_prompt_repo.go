@@ -15,11 +15,16 @@ import (
 // ErrSystemPromptNotFound is returned when a system prompt is not found.
 var ErrSystemPromptNotFound = errors.New("system prompt not found")
 
+// ErrSystemPromptRevisionNotFound is returned when a revision does not exist for the prompt type.
+var ErrSystemPromptRevisionNotFound = errors.New("system prompt revision not found")
+
 // SystemPromptRepository defines the interface for system prompt data access.
 type SystemPromptRepository interface {
 	GetByType(ctx context.Context, promptType string) (*models.SystemPrompt, error)
 	GetAll(ctx context.Context) ([]models.SystemPrompt, error)
 	Update(ctx context.Context, promptType string, content string, updatedBy uuid.UUID) error
+	ListRevisions(ctx context.Context, promptType string, page, perPage int) ([]models.SystemPromptRevision, int64, error)
+	GetRevision(ctx context.Context, promptType string, revisionID uuid.UUID) (*models.SystemPromptRevision, error)
 }
 
 type systemPromptRepository struct {
@@ -96,22 +101,116 @@ func (r *systemPromptRepository) GetAll(ctx context.Context) ([]models.SystemPro
 	return prompts, nil
 }
 
-// Update updates a system prompt's content.
+// Update updates a system prompt's content. The previous content is stored as a
+// revision in the same transaction; an update that does not change the content
+// records no revision.
 func (r *systemPromptRepository) Update(ctx context.Context, promptType string, content string, updatedBy uuid.UUID) error {
+	return r.db.WithTx(ctx, func(tx pgx.Tx) error {
+		var current string
+		var authoredBy *uuid.UUID
+		err := tx.QueryRow(ctx,
+			`SELECT prompt_content, updated_by FROM system_prompts WHERE prompt_type = $1 FOR UPDATE`,
+			promptType,
+		).Scan(&current, &authoredBy)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrSystemPromptNotFound
+			}
+			return fmt.Errorf("failed to lock system prompt: %w", err)
+		}
+
+		if current != content {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO system_prompt_revisions (prompt_type, prompt_content, authored_by, replaced_by)
+				VALUES ($1, $2, $3, $4)
+			`, promptType, current, authoredBy, updatedBy)
+			if err != nil {
+				return fmt.Errorf("failed to insert system prompt revision: %w", err)
+			}
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE system_prompts
+			SET prompt_content = $2, updated_by = $3, updated_at = NOW()
+			WHERE prompt_type = $1
+		`, promptType, content, updatedBy)
+		if err != nil {
+			return fmt.Errorf("failed to update system prompt: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// ListRevisions returns a page of a prompt's previous versions, newest first.
+func (r *systemPromptRepository) ListRevisions(ctx context.Context, promptType string, page, perPage int) ([]models.SystemPromptRevision, int64, error) {
+	var total int64
+	if err := r.db.Pool().QueryRow(ctx,
+		`SELECT COUNT(*) FROM system_prompt_revisions WHERE prompt_type = $1`, promptType,
+	).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count system prompt revisions: %w", err)
+	}
+
 	query := `
-		UPDATE system_prompts
-		SET prompt_content = $2, updated_by = $3, updated_at = NOW()
+		SELECT id, prompt_type, prompt_content, authored_by, replaced_by, created_at
+		FROM system_prompt_revisions
 		WHERE prompt_type = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
 	`
 
-	result, err := r.db.Pool().Exec(ctx, query, promptType, content, updatedBy)
+	rows, err := r.db.Pool().Query(ctx, query, promptType, perPage, (page-1)*perPage)
 	if err != nil {
-		return fmt.Errorf("failed to update system prompt: %w", err)
+		return nil, 0, fmt.Errorf("failed to list system prompt revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := make([]models.SystemPromptRevision, 0)
+	for rows.Next() {
+		var revision models.SystemPromptRevision
+		if err := rows.Scan(
+			&revision.ID,
+			&revision.PromptType,
+			&revision.PromptContent,
+			&revision.AuthoredBy,
+			&revision.ReplacedBy,
+			&revision.CreatedAt,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan system prompt revision: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating system prompt revisions: %w", err)
 	}
 
-	if result.RowsAffected() == 0 {
-		return ErrSystemPromptNotFound
+	return revisions, total, nil
+}
+
+// GetRevision retrieves a revision of the given prompt type.
+func (r *systemPromptRepository) GetRevision(ctx context.Context, promptType string, revisionID uuid.UUID) (*models.SystemPromptRevision, error) {
+	query := `
+		SELECT id, prompt_type, prompt_content, authored_by, replaced_by, created_at
+		FROM system_prompt_revisions
+		WHERE id = $1 AND prompt_type = $2
+	`
+
+	revision := &models.SystemPromptRevision{}
+	err := r.db.Pool().QueryRow(ctx, query, revisionID, promptType).Scan(
+		&revision.ID,
+		&revision.PromptType,
+		&revision.PromptContent,
+		&revision.AuthoredBy,
+		&revision.ReplacedBy,
+		&revision.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSystemPromptRevisionNotFound
+		}
+		return nil, fmt.Errorf("failed to get system prompt revision: %w", err)
 	}
 
-	return nil
+	return revision, nil
 }
@@ -0,0 +1,76 @@
+package repository_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// thaiPrompt has tone marks, above and below vowels, Thai digits, mixed scripts
+// and surrounding whitespace, all of which must survive storage unchanged.
+const thaiPrompt = "  คุณคือโปรดิวเซอร์เพลงไทย ใช้วรรณยุกต์ ่ ้ ๊ ๋ และสระ ิ ี ึ ื ุ ู ำ ให้ถูกต้อง\r\n" +
+	"เขียนเนื้อเพลง ๑๒๓ ท่อน <Verse> & \"Chorus\" ภาษา %s\n\t"
+
+// TestSystemPromptRollbackRestoresContent updates a prompt away from Thai
+// content and back through its revision, and checks that the restored content
+// is byte-identical. It needs TEST_DATABASE_URL.
+func TestSystemPromptRollbackRestoresContent(t *testing.T) {
+	db := testutil.NewDB(t)
+	ctx := context.Background()
+
+	admin := &models.User{ID: uuid.New(), Email: "prompt-admin-" + uuid.NewString() + "@example.com", PasswordHash: "unused"}
+	if err := repository.NewUserRepository(db).Create(ctx, admin); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	repo := repository.NewSystemPromptRepository(db)
+	promptType := models.PromptTypeSongConcept
+	replacement := strings.Repeat("An English prompt that replaces the Thai one. ", 3)
+
+	if err := repo.Update(ctx, promptType, thaiPrompt, admin.ID); err != nil {
+		t.Fatalf("Update to the Thai prompt: %v", err)
+	}
+	if err := repo.Update(ctx, promptType, replacement, admin.ID); err != nil {
+		t.Fatalf("Update to the replacement: %v", err)
+	}
+
+	revisions, total, err := repo.ListRevisions(ctx, promptType, 1, 1)
+	if err != nil {
+		t.Fatalf("ListRevisions: %v", err)
+	}
+	if total != 2 || len(revisions) != 1 {
+		t.Fatalf("ListRevisions = %d of %d, want the newest of 2", len(revisions), total)
+	}
+	revision, err := repo.GetRevision(ctx, promptType, revisions[0].ID)
+	if err != nil {
+		t.Fatalf("GetRevision: %v", err)
+	}
+	if revision.PromptContent != thaiPrompt {
+		t.Fatalf("revision content = %q, want %q", revision.PromptContent, thaiPrompt)
+	}
+
+	if err := repo.Update(ctx, promptType, revision.PromptContent, admin.ID); err != nil {
+		t.Fatalf("Update to the revision: %v", err)
+	}
+	prompt, err := repo.GetByType(ctx, promptType)
+	if err != nil {
+		t.Fatalf("GetByType: %v", err)
+	}
+	if prompt.PromptContent != thaiPrompt {
+		t.Errorf("restored content = %q, want %q", prompt.PromptContent, thaiPrompt)
+	}
+
+	// The rollback archived the replacement, so it can be undone
+	revisions, total, err = repo.ListRevisions(ctx, promptType, 1, 1)
+	if err != nil {
+		t.Fatalf("ListRevisions: %v", err)
+	}
+	if total != 3 || revisions[0].PromptContent != replacement {
+		t.Errorf("newest of %d revisions = %q, want the replacement of 3", total, revisions[0].PromptContent)
+	}
+}
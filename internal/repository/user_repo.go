@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -15,6 +17,9 @@ import (
 // ErrUserNotFound is returned when a user is not found in the database.
 var ErrUserNotFound = errors.New("user not found")
 
+// ErrLastAdmin is returned when a change would leave no active admin.
+var ErrLastAdmin = errors.New("cannot remove the last active admin")
+
 // UserRepository defines the interface for user data access operations.
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
@@ -22,11 +27,25 @@ type UserRepository interface {
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	Update(ctx context.Context, user *models.User) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+	ListDeletedBefore(ctx context.Context, deletedBefore time.Time, limit int) ([]uuid.UUID, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	List(ctx context.Context, filter models.UserFilter, page, perPage int) ([]*models.User, int64, error)
+	SetRole(ctx context.Context, id uuid.UUID, role string) error
+	SetDisabled(ctx context.Context, id uuid.UUID, disabled bool) error
+	SetOpenRouterTokenLimit(ctx context.Context, id uuid.UUID, limit *int64) error
 	UpdateAPIKeys(ctx context.Context, userID uuid.UUID, openRouterKey, kieKey *string) error
 	GetAPIKeys(ctx context.Context, userID uuid.UUID) (openRouterKey, kieKey *string, err error)
+	GetAPIKeysStatus(ctx context.Context, userID uuid.UUID) (*models.APIKeysStatusResponse, error)
 	DeleteAPIKeys(ctx context.Context, userID uuid.UUID) error
 	UpdateYouTubeToken(ctx context.Context, userID uuid.UUID, encryptedToken *string) error
 	GetYouTubeToken(ctx context.Context, userID uuid.UUID) (*string, error)
+	GetPrompts(ctx context.Context, userID uuid.UUID) (*models.AgentPrompts, error)
+	UpdatePrompt(ctx context.Context, userID uuid.UUID, promptType string, prompt *string) error
+	GetAgentParams(ctx context.Context, userID uuid.UUID) (map[string]models.GenerationParams, error)
+	UpdateAgentParams(ctx context.Context, userID uuid.UUID, promptType string, params models.GenerationParams) error
+	ListSecrets(ctx context.Context, afterID uuid.UUID, limit int) ([]*models.UserSecrets, error)
+	ReplaceSecrets(ctx context.Context, current, updated *models.UserSecrets) (bool, error)
 }
 
 // userRepository implements UserRepository using pgx.
@@ -43,7 +62,7 @@ func NewUserRepository(db *database.DB) UserRepository {
 func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 	// Set default role if not specified
 	if user.Role == "" {
-		user.Role = "user"
+		user.Role = models.RoleUser
 	}
 
 	query := `
@@ -73,12 +92,15 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 // GetByID retrieves a user by their ID.
 func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	query := `
-		SELECT id, email, password_hash, name, role, openrouter_model, openrouter_api_key, kie_api_key, youtube_refresh_token, created_at, updated_at
+		SELECT id, email, password_hash, name, role, openrouter_model, song_concept_model, song_selector_model, image_concept_model,
+			default_suno_model, openrouter_api_key, kie_api_key, has_openrouter_key, has_kie_key, youtube_refresh_token, notify_email, locale, job_defaults, disabled, deleted_at, created_at, updated_at, org_id,
+			openrouter_monthly_token_limit
 		FROM users
 		WHERE id = $1
 	`
 
 	user := &models.User{}
+	var jobDefaultsJSON []byte
 	err := r.db.Pool().QueryRow(ctx, query, id).Scan(
 		&user.ID,
 		&user.Email,
@@ -86,11 +108,24 @@ func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Use
 		&user.Name,
 		&user.Role,
 		&user.OpenRouterModel,
+		&user.SongConceptModel,
+		&user.SongSelectorModel,
+		&user.ImageConceptModel,
+		&user.DefaultSunoModel,
 		&user.OpenRouterAPIKey,
 		&user.KIEAPIKey,
+		&user.HasOpenRouterKey,
+		&user.HasKIEKey,
 		&user.YouTubeRefreshToken,
+		&user.NotifyEmail,
+		&user.Locale,
+		&jobDefaultsJSON,
+		&user.Disabled,
+		&user.DeletedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.OrgID,
+		&user.OpenRouterMonthlyTokenLimit,
 	)
 
 	if err != nil {
@@ -99,19 +134,29 @@ func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Use
 		}
 		return nil, fmt.Errorf("failed to get user by id: %w", err)
 	}
+	if err := unmarshalJSONB(jobDefaultsJSON, &user.JobDefaults); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job defaults: %w", err)
+	}
 
 	return user, nil
 }
 
-// GetByEmail retrieves a user by their email address.
+// GetByEmail retrieves a user by their email address, ignoring case so accounts
+// registered before emails were normalized can still log in. An exact match wins
+// if addresses differing only in case exist.
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
-		SELECT id, email, password_hash, name, role, openrouter_model, openrouter_api_key, kie_api_key, youtube_refresh_token, created_at, updated_at
+		SELECT id, email, password_hash, name, role, openrouter_model, song_concept_model, song_selector_model, image_concept_model,
+			default_suno_model, openrouter_api_key, kie_api_key, has_openrouter_key, has_kie_key, youtube_refresh_token, notify_email, locale, job_defaults, disabled, deleted_at, created_at, updated_at, org_id,
+			openrouter_monthly_token_limit
 		FROM users
-		WHERE email = $1
+		WHERE LOWER(email) = LOWER($1)
+		ORDER BY email = $1 DESC
+		LIMIT 1
 	`
 
 	user := &models.User{}
+	var jobDefaultsJSON []byte
 	err := r.db.Pool().QueryRow(ctx, query, email).Scan(
 		&user.ID,
 		&user.Email,
@@ -119,11 +164,24 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.
 		&user.Name,
 		&user.Role,
 		&user.OpenRouterModel,
+		&user.SongConceptModel,
+		&user.SongSelectorModel,
+		&user.ImageConceptModel,
+		&user.DefaultSunoModel,
 		&user.OpenRouterAPIKey,
 		&user.KIEAPIKey,
+		&user.HasOpenRouterKey,
+		&user.HasKIEKey,
 		&user.YouTubeRefreshToken,
+		&user.NotifyEmail,
+		&user.Locale,
+		&jobDefaultsJSON,
+		&user.Disabled,
+		&user.DeletedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.OrgID,
+		&user.OpenRouterMonthlyTokenLimit,
 	)
 
 	if err != nil {
@@ -132,6 +190,9 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.
 		}
 		return nil, fmt.Errorf("failed to get user by email: %w", err)
 	}
+	if err := unmarshalJSONB(jobDefaultsJSON, &user.JobDefaults); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job defaults: %w", err)
+	}
 
 	return user, nil
 }
@@ -140,11 +201,18 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.
 func (r *userRepository) Update(ctx context.Context, user *models.User) error {
 	query := `
 		UPDATE users
-		SET email = $2, password_hash = $3, name = $4, openrouter_model = $5, updated_at = NOW()
+		SET email = $2, password_hash = $3, name = $4, openrouter_model = $5,
+			song_concept_model = $6, song_selector_model = $7, image_concept_model = $8, notify_email = $9, locale = $10,
+			default_suno_model = $11, job_defaults = $12, openrouter_monthly_token_limit = $13, updated_at = NOW()
 		WHERE id = $1
 		RETURNING updated_at
 	`
 
+	jobDefaultsJSON, err := json.Marshal(user.JobDefaults)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job defaults: %w", err)
+	}
+
 	result, err := r.db.Pool().Exec(
 		ctx,
 		query,
@@ -153,6 +221,14 @@ func (r *userRepository) Update(ctx context.Context, user *models.User) error {
 		user.PasswordHash,
 		user.Name,
 		user.OpenRouterModel,
+		user.SongConceptModel,
+		user.SongSelectorModel,
+		user.ImageConceptModel,
+		user.NotifyEmail,
+		user.Locale,
+		user.DefaultSunoModel,
+		jobDefaultsJSON,
+		user.OpenRouterMonthlyTokenLimit,
 	)
 
 	if err != nil {
@@ -193,6 +269,212 @@ func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
+// SoftDelete marks a user as deleted. The row is kept until the data cleanup
+// task removes it. Deleting an already deleted user is a no-op.
+func (r *userRepository) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET deleted_at = COALESCE(deleted_at, NOW()), updated_at = NOW()
+		WHERE id = $1
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to soft delete user: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// ListDeletedBefore returns up to limit users soft-deleted before deletedBefore,
+// oldest first: accounts whose data cleanup has not finished.
+func (r *userRepository) ListDeletedBefore(ctx context.Context, deletedBefore time.Time, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT id FROM users
+		WHERE deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2
+	`
+
+	rows, err := r.db.Pool().Query(ctx, query, deletedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted users: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan deleted user: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deleted users: %w", err)
+	}
+
+	return ids, nil
+}
+
+// UpdatePassword replaces the password hash of a user.
+func (r *userRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $2, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, passwordHash)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// List returns a page of users that have not been deleted, newest first, and the total count.
+func (r *userRepository) List(ctx context.Context, filter models.UserFilter, page, perPage int) ([]*models.User, int64, error) {
+	where := `WHERE deleted_at IS NULL`
+	args := []interface{}{}
+	if filter.Query != "" {
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+		where += fmt.Sprintf(` AND email ILIKE $%d`, len(args))
+	}
+
+	var total int64
+	if err := r.db.Pool().QueryRow(ctx, `SELECT COUNT(*) FROM users `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	args = append(args, perPage, (page-1)*perPage)
+	query := fmt.Sprintf(`
+		SELECT id, email, name, role, openrouter_model, disabled, created_at, updated_at, openrouter_monthly_token_limit
+		FROM users
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args))
+
+	rows, err := r.db.Pool().Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		user := &models.User{}
+		if err := rows.Scan(
+			&user.ID,
+			&user.Email,
+			&user.Name,
+			&user.Role,
+			&user.OpenRouterModel,
+			&user.Disabled,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&user.OpenRouterMonthlyTokenLimit,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating users: %w", err)
+	}
+
+	return users, total, nil
+}
+
+// otherActiveAdminExists is a SQL condition that is true when an active admin other than $1 exists.
+const otherActiveAdminExists = `EXISTS (
+	SELECT 1 FROM users other
+	WHERE other.role = 'admin' AND NOT other.disabled AND other.deleted_at IS NULL AND other.id <> $1
+)`
+
+// SetRole changes a user's role. Demoting the last active admin returns ErrLastAdmin.
+func (r *userRepository) SetRole(ctx context.Context, id uuid.UUID, role string) error {
+	query := `
+		UPDATE users
+		SET role = $2, updated_at = NOW()
+		WHERE id = $1 AND ($2 = 'admin' OR role <> 'admin' OR disabled OR ` + otherActiveAdminExists + `)
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, role)
+	if err != nil {
+		return fmt.Errorf("failed to set user role: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return r.notFoundOrLastAdmin(ctx, id)
+	}
+
+	return nil
+}
+
+// SetDisabled disables or re-enables a user. Disabling the last active admin returns ErrLastAdmin.
+func (r *userRepository) SetDisabled(ctx context.Context, id uuid.UUID, disabled bool) error {
+	query := `
+		UPDATE users
+		SET disabled = $2, updated_at = NOW()
+		WHERE id = $1 AND (NOT $2 OR role <> 'admin' OR disabled OR ` + otherActiveAdminExists + `)
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, disabled)
+	if err != nil {
+		return fmt.Errorf("failed to set user disabled: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return r.notFoundOrLastAdmin(ctx, id)
+	}
+
+	return nil
+}
+
+// SetOpenRouterTokenLimit sets a user's monthly OpenRouter token budget; nil removes it.
+func (r *userRepository) SetOpenRouterTokenLimit(ctx context.Context, id uuid.UUID, limit *int64) error {
+	query := `
+		UPDATE users
+		SET openrouter_monthly_token_limit = $2, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query, id, limit)
+	if err != nil {
+		return fmt.Errorf("failed to set user token limit: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// notFoundOrLastAdmin explains why a guarded admin update matched no rows.
+func (r *userRepository) notFoundOrLastAdmin(ctx context.Context, id uuid.UUID) error {
+	var exists bool
+	if err := r.db.Pool().QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check user existence: %w", err)
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+	return ErrLastAdmin
+}
+
 // UpdateAPIKeys updates the encrypted API keys for a user.
 func (r *userRepository) UpdateAPIKeys(ctx context.Context, userID uuid.UUID, openRouterKey, kieKey *string) error {
 	query := `
@@ -232,6 +514,27 @@ func (r *userRepository) GetAPIKeys(ctx context.Context, userID uuid.UUID) (open
 	return openRouterKey, kieKey, nil
 }
 
+// GetAPIKeysStatus reports which API keys and tokens the user has set, without
+// reading the encrypted values.
+func (r *userRepository) GetAPIKeysStatus(ctx context.Context, userID uuid.UUID) (*models.APIKeysStatusResponse, error) {
+	query := `
+		SELECT has_openrouter_key, has_kie_key, COALESCE(youtube_refresh_token, '') <> ''
+		FROM users
+		WHERE id = $1
+	`
+
+	status := &models.APIKeysStatusResponse{}
+	err := r.db.Pool().QueryRow(ctx, query, userID).Scan(&status.HasOpenRouterKey, &status.HasKIEKey, &status.HasYouTube)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get API keys status: %w", err)
+	}
+
+	return status, nil
+}
+
 // DeleteAPIKeys removes the API keys for a user.
 func (r *userRepository) DeleteAPIKeys(ctx context.Context, userID uuid.UUID) error {
 	query := `
@@ -289,3 +592,175 @@ func (r *userRepository) GetYouTubeToken(ctx context.Context, userID uuid.UUID)
 	return token, nil
 }
 
+// GetPrompts retrieves the user's custom agent prompts.
+func (r *userRepository) GetPrompts(ctx context.Context, userID uuid.UUID) (*models.AgentPrompts, error) {
+	query := `
+		SELECT song_concept_prompt, song_selector_prompt, image_concept_prompt, image_selector_prompt
+		FROM users
+		WHERE id = $1
+	`
+
+	prompts := &models.AgentPrompts{}
+	err := r.db.Pool().QueryRow(ctx, query, userID).Scan(
+		&prompts.SongConceptPrompt,
+		&prompts.SongSelectorPrompt,
+		&prompts.ImageConceptPrompt,
+		&prompts.ImageSelectorPrompt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get prompts: %w", err)
+	}
+
+	return prompts, nil
+}
+
+// GetAgentParams retrieves the user's generation parameter overrides, keyed by prompt type.
+func (r *userRepository) GetAgentParams(ctx context.Context, userID uuid.UUID) (map[string]models.GenerationParams, error) {
+	var paramsJSON []byte
+	err := r.db.Pool().QueryRow(ctx, `SELECT agent_params FROM users WHERE id = $1`, userID).Scan(&paramsJSON)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get agent params: %w", err)
+	}
+
+	params := make(map[string]models.GenerationParams)
+	if err := unmarshalJSONB(paramsJSON, &params); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal agent params: %w", err)
+	}
+	return params, nil
+}
+
+// promptColumns maps prompt types to their users column.
+var promptColumns = map[string]string{
+	models.PromptTypeSongConcept:   "song_concept_prompt",
+	models.PromptTypeSongSelector:  "song_selector_prompt",
+	models.PromptTypeImageConcept:  "image_concept_prompt",
+	models.PromptTypeImageSelector: "image_selector_prompt",
+}
+
+// UpdatePrompt sets the user's custom prompt for one agent. Pass nil to reset to the default.
+func (r *userRepository) UpdatePrompt(ctx context.Context, userID uuid.UUID, promptType string, prompt *string) error {
+	column, ok := promptColumns[promptType]
+	if !ok {
+		return fmt.Errorf("unknown prompt type %q", promptType)
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE users
+		SET %s = $2, updated_at = NOW()
+		WHERE id = $1
+	`, column)
+
+	result, err := r.db.Pool().Exec(ctx, query, userID, prompt)
+	if err != nil {
+		return fmt.Errorf("failed to update prompt: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// UpdateAgentParams sets the user's generation parameter overrides for one agent.
+// Empty params remove the override.
+func (r *userRepository) UpdateAgentParams(ctx context.Context, userID uuid.UUID, promptType string, params models.GenerationParams) error {
+	query := `
+		UPDATE users
+		SET agent_params = agent_params || jsonb_build_object($2::text, $3::jsonb), updated_at = NOW()
+		WHERE id = $1
+	`
+	args := []interface{}{userID, promptType}
+	if params.IsEmpty() {
+		query = `
+			UPDATE users
+			SET agent_params = agent_params - $2, updated_at = NOW()
+			WHERE id = $1
+		`
+	} else {
+		paramsJSON, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to marshal agent params: %w", err)
+		}
+		args = append(args, paramsJSON)
+	}
+
+	result, err := r.db.Pool().Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update agent params: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// ListSecrets returns up to limit users with at least one encrypted secret, ordered by ID,
+// starting after afterID. Pass uuid.Nil to start from the beginning.
+func (r *userRepository) ListSecrets(ctx context.Context, afterID uuid.UUID, limit int) ([]*models.UserSecrets, error) {
+	query := `
+		SELECT id, openrouter_api_key, kie_api_key, youtube_refresh_token
+		FROM users
+		WHERE id > $1
+		  AND (openrouter_api_key IS NOT NULL OR kie_api_key IS NOT NULL OR youtube_refresh_token IS NOT NULL)
+		ORDER BY id
+		LIMIT $2
+	`
+
+	rows, err := r.db.Pool().Query(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user secrets: %w", err)
+	}
+	defer rows.Close()
+
+	secrets := make([]*models.UserSecrets, 0)
+	for rows.Next() {
+		s := &models.UserSecrets{}
+		if err := rows.Scan(&s.UserID, &s.OpenRouterAPIKey, &s.KIEAPIKey, &s.YouTubeRefreshToken); err != nil {
+			return nil, fmt.Errorf("failed to scan user secrets: %w", err)
+		}
+		secrets = append(secrets, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user secrets: %w", err)
+	}
+
+	return secrets, nil
+}
+
+// ReplaceSecrets writes updated secrets only if the stored values still equal current,
+// so a key the user changed concurrently is never overwritten. Returns false if they differ.
+func (r *userRepository) ReplaceSecrets(ctx context.Context, current, updated *models.UserSecrets) (bool, error) {
+	query := `
+		UPDATE users
+		SET openrouter_api_key = $5, kie_api_key = $6, youtube_refresh_token = $7
+		WHERE id = $1
+		  AND openrouter_api_key IS NOT DISTINCT FROM $2
+		  AND kie_api_key IS NOT DISTINCT FROM $3
+		  AND youtube_refresh_token IS NOT DISTINCT FROM $4
+	`
+
+	result, err := r.db.Pool().Exec(ctx, query,
+		current.UserID,
+		current.OpenRouterAPIKey,
+		current.KIEAPIKey,
+		current.YouTubeRefreshToken,
+		updated.OpenRouterAPIKey,
+		updated.KIEAPIKey,
+		updated.YouTubeRefreshToken,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to replace user secrets: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
@@ -0,0 +1,55 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// TestUserGetByEmailIgnoresCase looks up an account stored with a mixed-case
+// email, as registered before emails were lowercased, by the address in any
+// case, and checks that an exact match wins over one differing only in case.
+// It needs TEST_DATABASE_URL.
+func TestUserGetByEmailIgnoresCase(t *testing.T) {
+	db := testutil.NewDB(t)
+	ctx := context.Background()
+	users := repository.NewUserRepository(db)
+
+	local := "Legacy.User-" + uuid.NewString()
+	legacy := &models.User{ID: uuid.New(), Email: local + "@Example.com", PasswordHash: "unused"}
+	if err := users.Create(ctx, legacy); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	for _, email := range []string{legacy.Email, strings.ToLower(legacy.Email), strings.ToUpper(legacy.Email)} {
+		got, err := users.GetByEmail(ctx, email)
+		if err != nil {
+			t.Fatalf("GetByEmail(%q) error = %v", email, err)
+		}
+		if got.ID != legacy.ID || got.Email != legacy.Email {
+			t.Errorf("GetByEmail(%q) = %s %s, want %s %s", email, got.ID, got.Email, legacy.ID, legacy.Email)
+		}
+	}
+
+	// A lowercased duplicate of the legacy account is found by its exact address
+	lowered := &models.User{ID: uuid.New(), Email: strings.ToLower(legacy.Email), PasswordHash: "unused"}
+	if err := users.Create(ctx, lowered); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	for _, want := range []*models.User{legacy, lowered} {
+		if got, err := users.GetByEmail(ctx, want.Email); err != nil || got.ID != want.ID {
+			t.Errorf("GetByEmail(%q) = %v (%v), want the exact match %s", want.Email, got, err, want.ID)
+		}
+	}
+
+	if _, err := users.GetByEmail(ctx, "missing-"+uuid.NewString()+"@example.com"); !errors.Is(err, repository.ErrUserNotFound) {
+		t.Errorf("GetByEmail() of an unknown address error = %v, want ErrUserNotFound", err)
+	}
+}
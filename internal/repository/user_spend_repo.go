@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/jaochai/ugc/internal/database"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// UserSpendRepository defines the interface for user spend data access.
+type UserSpendRepository interface {
+	Record(ctx context.Context, spend *models.UserSpend) error
+	// SummarizeSince totals the spend of each user since since, in one aggregate
+	// query. Users without spend get a zero summary.
+	SummarizeSince(ctx context.Context, userIDs []uuid.UUID, since time.Time) (map[uuid.UUID]*models.SpendSummary, error)
+}
+
+type userSpendRepository struct {
+	db *database.DB
+}
+
+// NewUserSpendRepository creates a new UserSpendRepository instance.
+func NewUserSpendRepository(db *database.DB) UserSpendRepository {
+	return &userSpendRepository{db: db}
+}
+
+// Record inserts one spend entry. An entry for a provider task already recorded
+// is skipped, so retried tasks do not count the same generation twice.
+func (r *userSpendRepository) Record(ctx context.Context, spend *models.UserSpend) error {
+	if spend.ID == uuid.Nil {
+		spend.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO user_spend (id, user_id, job_id, kind, key_source, credits, tokens, provider_task_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (provider_task_id) WHERE provider_task_id IS NOT NULL DO NOTHING
+		RETURNING created_at
+	`
+
+	err := r.db.Pool().QueryRow(ctx, query,
+		spend.ID, spend.UserID, spend.JobID, spend.Kind, spend.KeySource, spend.Credits, spend.Tokens, spend.ProviderTaskID,
+	).Scan(&spend.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Already recorded
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record user spend: %w", err)
+	}
+
+	return nil
+}
+
+// SummarizeSince totals the spend of each of userIDs since since.
+func (r *userSpendRepository) SummarizeSince(ctx context.Context, userIDs []uuid.UUID, since time.Time) (map[uuid.UUID]*models.SpendSummary, error) {
+	summaries := make(map[uuid.UUID]*models.SpendSummary, len(userIDs))
+	for _, id := range userIDs {
+		summaries[id] = &models.SpendSummary{Since: since}
+	}
+	if len(userIDs) == 0 {
+		return summaries, nil
+	}
+
+	query := `
+		SELECT user_id,
+			COALESCE(SUM(credits), 0),
+			COALESCE(SUM(credits) FILTER (WHERE key_source = $3), 0),
+			COALESCE(SUM(tokens), 0)
+		FROM user_spend
+		WHERE user_id = ANY($1) AND created_at >= $2
+		GROUP BY user_id
+	`
+
+	rows, err := r.db.Pool().Query(ctx, query, userIDs, since, models.KeySourcePlatform)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize user spend: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID uuid.UUID
+		var total, platform int
+		var tokens int64
+		if err := rows.Scan(&userID, &total, &platform, &tokens); err != nil {
+			return nil, fmt.Errorf("failed to scan user spend: %w", err)
+		}
+		if summary, ok := summaries[userID]; ok {
+			summary.KIECredits = total
+			summary.PlatformKIECredits = platform
+			summary.OpenRouterTokens = tokens
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user spend: %w", err)
+	}
+
+	return summaries, nil
+}
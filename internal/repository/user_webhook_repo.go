@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/jaochai/ugc/internal/database"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// ErrUserWebhookNotFound is returned when a user webhook does not exist.
+var ErrUserWebhookNotFound = errors.New("user webhook not found")
+
+// ErrUserWebhookLimitReached is returned when a user already has the maximum number of webhooks.
+var ErrUserWebhookLimitReached = errors.New("user webhook limit reached")
+
+// UserWebhookRepository defines the interface for user webhook data access.
+type UserWebhookRepository interface {
+	Create(ctx context.Context, webhook *models.UserWebhook, limit int) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.UserWebhook, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]*models.UserWebhook, error)
+	Update(ctx context.Context, webhook *models.UserWebhook) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	RecordDelivery(ctx context.Context, id uuid.UUID, statusCode *int, deliveryErr *string) error
+}
+
+const userWebhookColumns = `id, user_id, url, secret, events, enabled, delivery_count, failure_count,
+	last_delivery_at, last_status_code, last_error, created_at, updated_at`
+
+type userWebhookRepository struct {
+	db *database.DB
+}
+
+// NewUserWebhookRepository creates a new UserWebhookRepository instance.
+func NewUserWebhookRepository(db *database.DB) UserWebhookRepository {
+	return &userWebhookRepository{db: db}
+}
+
+// Create inserts a webhook unless the user already has limit webhooks.
+func (r *userWebhookRepository) Create(ctx context.Context, webhook *models.UserWebhook, limit int) error {
+	if webhook.ID == uuid.Nil {
+		webhook.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO user_webhooks (id, user_id, url, secret, events, enabled)
+		SELECT $1, $2, $3, $4, $5, $6
+		WHERE (SELECT COUNT(*) FROM user_webhooks WHERE user_id = $2) < $7
+		RETURNING created_at, updated_at
+	`
+
+	err := r.db.Pool().QueryRow(ctx, query,
+		webhook.ID, webhook.UserID, webhook.URL, webhook.Secret, webhook.Events, webhook.Enabled, limit,
+	).Scan(&webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserWebhookLimitReached
+		}
+		return fmt.Errorf("failed to create user webhook: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a user webhook by ID.
+func (r *userWebhookRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.UserWebhook, error) {
+	query := `SELECT ` + userWebhookColumns + ` FROM user_webhooks WHERE id = $1`
+
+	webhook, err := scanUserWebhook(r.db.Pool().QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserWebhookNotFound
+		}
+		return nil, fmt.Errorf("failed to get user webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// ListByUserID returns all webhooks registered by a user, newest first.
+func (r *userWebhookRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*models.UserWebhook, error) {
+	query := `SELECT ` + userWebhookColumns + ` FROM user_webhooks WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.Pool().Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := make([]*models.UserWebhook, 0)
+	for rows.Next() {
+		webhook, err := scanUserWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// Update replaces a webhook's URL, events and enabled flag. The secret and
+// delivery statistics are kept.
+func (r *userWebhookRepository) Update(ctx context.Context, webhook *models.UserWebhook) error {
+	query := `
+		UPDATE user_webhooks
+		SET url = $2, events = $3, enabled = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at
+	`
+
+	err := r.db.Pool().QueryRow(ctx, query, webhook.ID, webhook.URL, webhook.Events, webhook.Enabled).
+		Scan(&webhook.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserWebhookNotFound
+		}
+		return fmt.Errorf("failed to update user webhook: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a user webhook.
+func (r *userWebhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Pool().Exec(ctx, `DELETE FROM user_webhooks WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user webhook: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrUserWebhookNotFound
+	}
+
+	return nil
+}
+
+// RecordDelivery stores the outcome of one delivery attempt. deliveryErr is nil
+// for a successful delivery; statusCode is nil when no response was received.
+func (r *userWebhookRepository) RecordDelivery(ctx context.Context, id uuid.UUID, statusCode *int, deliveryErr *string) error {
+	query := `
+		UPDATE user_webhooks
+		SET delivery_count = delivery_count + 1,
+			failure_count = failure_count + CASE WHEN $3::text IS NULL THEN 0 ELSE 1 END,
+			last_delivery_at = NOW(), last_status_code = $2, last_error = $3
+		WHERE id = $1
+	`
+
+	if _, err := r.db.Pool().Exec(ctx, query, id, statusCode, deliveryErr); err != nil {
+		return fmt.Errorf("failed to record user webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// scanUserWebhook scans a row selected with userWebhookColumns.
+func scanUserWebhook(row pgx.Row) (*models.UserWebhook, error) {
+	var webhook models.UserWebhook
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.UserID,
+		&webhook.URL,
+		&webhook.Secret,
+		&webhook.Events,
+		&webhook.Enabled,
+		&webhook.DeliveryCount,
+		&webhook.FailureCount,
+		&webhook.LastDeliveryAt,
+		&webhook.LastStatusCode,
+		&webhook.LastError,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
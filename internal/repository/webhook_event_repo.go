@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/jaochai/ugc/internal/database"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// ErrWebhookEventNotFound is returned when a webhook event does not exist.
+var ErrWebhookEventNotFound = errors.New("webhook event not found")
+
+// WebhookEventRepository stores captured webhook callbacks and callbacks awaiting retry.
+type WebhookEventRepository interface {
+	Create(ctx context.Context, event *models.WebhookEvent) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.WebhookEvent, error)
+	List(ctx context.Context, jobID *uuid.UUID, limit int) ([]*models.WebhookEvent, error)
+	MarkProcessed(ctx context.Context, id uuid.UUID) error
+	DeleteOlderThan(ctx context.Context, before time.Time) (int64, error)
+}
+
+const webhookEventColumns = `id, kind, source, job_id, status_code, headers, body, body_truncated, parse_error, processed_at, created_at`
+
+type webhookEventRepository struct {
+	db *database.DB
+}
+
+// NewWebhookEventRepository creates a new WebhookEventRepository instance.
+func NewWebhookEventRepository(db *database.DB) WebhookEventRepository {
+	return &webhookEventRepository{db: db}
+}
+
+// Create inserts a webhook event. Kind defaults to models.WebhookEventCapture.
+func (r *webhookEventRepository) Create(ctx context.Context, event *models.WebhookEvent) error {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.Kind == "" {
+		event.Kind = models.WebhookEventCapture
+	}
+
+	headers, err := json.Marshal(event.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event headers: %w", err)
+	}
+
+	query := `
+		INSERT INTO webhook_events (id, kind, source, job_id, status_code, headers, body, body_truncated, parse_error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at
+	`
+
+	err = r.db.Pool().QueryRow(ctx, query,
+		event.ID, event.Kind, event.Source, event.JobID, event.StatusCode, headers, event.Body, event.BodyTruncated, event.ParseError,
+	).Scan(&event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook event: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a webhook event by ID.
+func (r *webhookEventRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.WebhookEvent, error) {
+	query := `SELECT ` + webhookEventColumns + ` FROM webhook_events WHERE id = $1`
+
+	event, err := scanWebhookEvent(r.db.Pool().QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrWebhookEventNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook event: %w", err)
+	}
+
+	return event, nil
+}
+
+// List returns the most recent webhook events, newest first.
+// If jobID is non-nil only callbacks for that job are returned.
+func (r *webhookEventRepository) List(ctx context.Context, jobID *uuid.UUID, limit int) ([]*models.WebhookEvent, error) {
+	query := `
+		SELECT ` + webhookEventColumns + `
+		FROM webhook_events
+		WHERE $1::uuid IS NULL OR job_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Pool().Query(ctx, query, jobID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*models.WebhookEvent, 0)
+	for rows.Next() {
+		event, err := scanWebhookEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook events: %w", err)
+	}
+
+	return events, nil
+}
+
+// MarkProcessed records that a retried callback was applied successfully.
+func (r *webhookEventRepository) MarkProcessed(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.db.Pool().Exec(ctx, `UPDATE webhook_events SET processed_at = NOW() WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to mark webhook event processed: %w", err)
+	}
+	return nil
+}
+
+// DeleteOlderThan removes webhook events created before the given time.
+func (r *webhookEventRepository) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.Pool().Exec(ctx, `DELETE FROM webhook_events WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old webhook events: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// scanWebhookEvent scans a row selected with webhookEventColumns.
+func scanWebhookEvent(row pgx.Row) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+	var headers []byte
+	if err := row.Scan(
+		&event.ID, &event.Kind, &event.Source, &event.JobID, &event.StatusCode, &headers,
+		&event.Body, &event.BodyTruncated, &event.ParseError, &event.ProcessedAt, &event.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if len(headers) > 0 {
+		if err := json.Unmarshal(headers, &event.Headers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal webhook event headers: %w", err)
+		}
+	}
+	return &event, nil
+}
@@ -0,0 +1,95 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestNewCaptchaVerifierWithoutSecret(t *testing.T) {
+	verifier := NewCaptchaVerifier("")
+	if verifier.Enabled() {
+		t.Error("Enabled() = true without a secret, want false")
+	}
+	for _, token := range []string{"", "any-token"} {
+		if err := verifier.Verify(context.Background(), token, "203.0.113.7"); err != nil {
+			t.Errorf("Verify(%q) error = %v, want every request accepted", token, err)
+		}
+	}
+}
+
+func TestTurnstileVerifier(t *testing.T) {
+	tests := []struct {
+		name            string
+		token           string
+		remoteIP        string
+		status          int
+		body            string
+		wantErr         error // ErrCaptchaFailed for a rejected token
+		wantUnavailable bool  // The provider could not be asked
+		wantCalls       int
+	}{
+		{name: "solved", token: "solved-token", remoteIP: "203.0.113.7", status: http.StatusOK,
+			body: `{"success": true}`, wantCalls: 1},
+		{name: "without a client IP", token: "solved-token", status: http.StatusOK,
+			body: `{"success": true}`, wantCalls: 1},
+		{name: "rejected", token: "expired-token", status: http.StatusOK,
+			body: `{"success": false, "error-codes": ["timeout-or-duplicate"]}`, wantErr: ErrCaptchaFailed, wantCalls: 1},
+		{name: "missing token", token: "", wantErr: ErrCaptchaFailed},
+		{name: "provider error", token: "solved-token", status: http.StatusInternalServerError, wantUnavailable: true, wantCalls: 1},
+		{name: "unreadable response", token: "solved-token", status: http.StatusOK, body: "<html>", wantUnavailable: true, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			var form url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if err := r.ParseForm(); err != nil {
+					t.Errorf("failed to parse verification request: %v", err)
+				}
+				form = r.PostForm
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(server.Close)
+
+			verifier := NewCaptchaVerifier("turnstile-secret").(*turnstileVerifier)
+			verifier.verifyURL = server.URL
+			if !verifier.Enabled() {
+				t.Error("Enabled() = false with a secret, want true")
+			}
+
+			err := verifier.Verify(context.Background(), tt.token, tt.remoteIP)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantUnavailable:
+				if err == nil || errors.Is(err, ErrCaptchaFailed) {
+					t.Errorf("Verify() error = %v, want a provider error other than ErrCaptchaFailed", err)
+				}
+			case err != nil:
+				t.Errorf("Verify() error = %v", err)
+			}
+
+			if calls != tt.wantCalls {
+				t.Fatalf("siteverify called %d times, want %d", calls, tt.wantCalls)
+			}
+			if calls == 0 {
+				return
+			}
+			if form.Get("secret") != "turnstile-secret" || form.Get("response") != tt.token || form.Get("remoteip") != tt.remoteIP {
+				t.Errorf("siteverify form = %v, want the secret, token and client IP", form)
+			}
+			if _, ok := form["remoteip"]; ok != (tt.remoteIP != "") {
+				t.Errorf("remoteip sent = %v, want %v", ok, tt.remoteIP != "")
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package security
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// Email validation errors.
+var (
+	ErrInvalidEmail    = errors.New("invalid email format")
+	ErrDisposableEmail = errors.New("disposable email addresses are not allowed")
+)
+
+// maxEmailLength is the longest address accepted (RFC 5321 path limit).
+const maxEmailLength = 254
+
+// NormalizeEmail trims and lowercases an email address. Addresses are stored
+// and looked up in this form.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidateEmail checks that email is a bare address (no display name or angle
+// brackets) with a dotted domain.
+func ValidateEmail(email string) error {
+	if email == "" || len(email) > maxEmailLength {
+		return ErrInvalidEmail
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return ErrInvalidEmail
+	}
+
+	at := strings.LastIndex(email, "@")
+	domain := email[at+1:]
+	if at < 1 || !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return ErrInvalidEmail
+	}
+	return nil
+}
+
+// EmailDomainBlocklist rejects addresses from listed domains and their subdomains,
+// e.g. disposable email providers.
+type EmailDomainBlocklist struct {
+	domains map[string]bool
+}
+
+// NewEmailDomainBlocklist creates a blocklist of the given domains. An empty list blocks nothing.
+func NewEmailDomainBlocklist(domains []string) *EmailDomainBlocklist {
+	b := &EmailDomainBlocklist{domains: make(map[string]bool, len(domains))}
+	for _, domain := range domains {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" {
+			b.domains[domain] = true
+		}
+	}
+	return b
+}
+
+// Check returns ErrDisposableEmail if the domain of email, or any parent domain,
+// is blocked.
+func (b *EmailDomainBlocklist) Check(email string) error {
+	if b == nil || len(b.domains) == 0 {
+		return nil
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return nil
+	}
+	domain := NormalizeEmail(email[at+1:])
+	for {
+		if b.domains[domain] {
+			return ErrDisposableEmail
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			return nil
+		}
+		domain = domain[dot+1:]
+	}
+}
@@ -0,0 +1,90 @@
+package security
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := map[string]string{
+		"user@example.com":           "user@example.com",
+		"  Mixed.Case@Example.COM\n": "mixed.case@example.com",
+		"":                           "",
+	}
+	for email, want := range tests {
+		if got := NormalizeEmail(email); got != want {
+			t.Errorf("NormalizeEmail(%q) = %q, want %q", email, got, want)
+		}
+	}
+}
+
+func TestValidateEmail(t *testing.T) {
+	// maxEmailLength characters in all
+	longest := strings.Repeat("a", maxEmailLength-len("@example.com")) + "@example.com"
+
+	tests := []struct {
+		email string
+		valid bool
+	}{
+		{email: "user@example.com", valid: true},
+		{email: "first.last+tag@mail.example.co.th", valid: true},
+		{email: longest, valid: true},
+		{email: "a" + longest},
+		{email: ""},
+		{email: "user"},
+		{email: "@example.com"},
+		{email: "user@"},
+		{email: "user@localhost"},
+		{email: "user@.example.com"},
+		{email: "user@example.com."},
+		{email: "user@@example.com"},
+		{email: "two words@example.com"},
+		{email: "User <user@example.com>"},
+		{email: "<user@example.com>"},
+		{email: " user@example.com"},
+		{email: "a@b.com, c@d.com"},
+	}
+
+	for _, tt := range tests {
+		err := ValidateEmail(tt.email)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateEmail(%q) error = %v, want valid", tt.email, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidEmail) {
+			t.Errorf("ValidateEmail(%q) error = %v, want ErrInvalidEmail", tt.email, err)
+		}
+	}
+}
+
+func TestEmailDomainBlocklist(t *testing.T) {
+	blocklist := NewEmailDomainBlocklist([]string{" Mailinator.com ", ".tempmail.dev.", ""})
+
+	tests := []struct {
+		email   string
+		blocked bool
+	}{
+		{email: "user@mailinator.com", blocked: true},
+		{email: "user@MAILINATOR.COM", blocked: true},
+		{email: "user@eu.mailinator.com", blocked: true},
+		{email: "user@tempmail.dev", blocked: true},
+		{email: "user@example.com"},
+		{email: "user@notmailinator.com"},
+		{email: "user@mailinator.com.example.org"},
+		{email: "mailinator.com"},
+	}
+
+	for _, tt := range tests {
+		err := blocklist.Check(tt.email)
+		if tt.blocked != errors.Is(err, ErrDisposableEmail) || (!tt.blocked && err != nil) {
+			t.Errorf("Check(%q) error = %v, want blocked %v", tt.email, err, tt.blocked)
+		}
+	}
+
+	// No domains, or no blocklist at all, allow every address
+	for _, empty := range []*EmailDomainBlocklist{NewEmailDomainBlocklist(nil), nil} {
+		if err := empty.Check("user@mailinator.com"); err != nil {
+			t.Errorf("Check() with an empty blocklist error = %v, want nil", err)
+		}
+	}
+}
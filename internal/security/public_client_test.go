@@ -0,0 +1,73 @@
+package security
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsBlockedIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{ip: "127.0.0.1", blocked: true},
+		{ip: "10.1.2.3", blocked: true},
+		{ip: "172.16.0.1", blocked: true},
+		{ip: "192.168.1.1", blocked: true},
+		{ip: "169.254.169.254", blocked: true},
+		{ip: "0.0.0.0", blocked: true},
+		{ip: "100.64.0.1", blocked: true},
+		{ip: "100.127.255.255", blocked: true},
+		{ip: "224.0.0.1", blocked: true},
+		{ip: "::1", blocked: true},
+		{ip: "fd00::1", blocked: true},
+		{ip: "fe80::1", blocked: true},
+		{ip: "::ffff:127.0.0.1", blocked: true},
+		{ip: "::ffff:100.64.0.1", blocked: true},
+		{ip: "100.63.255.255", blocked: false},
+		{ip: "100.128.0.1", blocked: false},
+		{ip: "8.8.8.8", blocked: false},
+		{ip: "2001:4860:4860::8888", blocked: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isBlockedIP(net.ParseIP(tt.ip)); got != tt.blocked {
+				t.Errorf("isBlockedIP(%s) = %v, want %v", tt.ip, got, tt.blocked)
+			}
+		})
+	}
+}
+
+// TestPublicHTTPClientRefusesPrivateAddresses covers a host that passed
+// validation but resolves to an internal address when called: the check runs on
+// the dialled address, whatever name was requested.
+func TestPublicHTTPClientRefusesPrivateAddresses(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	client := NewPublicHTTPClient(5 * time.Second)
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	for _, url := range []string{server.URL, "http://localhost:" + port} {
+		resp, err := client.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			t.Fatalf("GET %s succeeded, want it refused", url)
+		}
+		if !errors.Is(err, ErrPrivateIPBlocked) {
+			t.Errorf("GET %s error = %v, want ErrPrivateIPBlocked", url, err)
+		}
+	}
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("server received %d requests, want 0", n)
+	}
+}
@@ -111,8 +111,30 @@ func (v *URLValidator) ValidateURL(rawURL string) error {
 	return nil
 }
 
+// ValidatePublicURL validates a user-supplied URL that the server will call, such
+// as a user's webhook endpoint. Any host is accepted, but the URL must use HTTPS
+// and must not resolve to a private or internal address.
+func ValidatePublicURL(rawURL string) error {
+	if rawURL == "" {
+		return ErrEmptyURL
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" {
+		return ErrInvalidURL
+	}
+
+	if parsed.Scheme != "https" {
+		return ErrHTTPSRequired
+	}
+
+	return checkNotPrivateIP(strings.ToLower(parsed.Hostname()))
+}
+
 // checkNotPrivateIP resolves the host and verifies none of the IPs are private/internal.
 // Fails closed: returns error if DNS resolution fails (prevents bypass via DNS failure).
+// The answer can change before the host is called, so clients calling user-supplied
+// URLs must also use NewPublicHTTPClient.
 func checkNotPrivateIP(host string) error {
 	ips, err := net.LookupHost(host)
 	if err != nil {
@@ -124,7 +146,7 @@ func checkNotPrivateIP(host string) error {
 		if ip == nil {
 			continue
 		}
-		if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		if isBlockedIP(ip) {
 			return ErrPrivateIPBlocked
 		}
 	}
@@ -0,0 +1,38 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// WebhookToken returns the token embedded in a job's KIE callback URLs:
+// hex(HMAC-SHA256(secret, jobID)). A leaked callback URL only authenticates
+// callbacks for that one job, and the secret itself never leaves the server.
+func WebhookToken(secret, jobID string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(jobID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// MaxWebhookTokenLength is the longest token the webhook middleware will look at;
+// longer ones are rejected before any hashing or comparison.
+const MaxWebhookTokenLength = 128
+
+// webhookTokenLength is the length of a WebhookToken: a hex-encoded SHA-256 MAC.
+const webhookTokenLength = sha256.Size * 2
+
+// IsWebhookTokenFormat reports whether token has the format WebhookToken produces:
+// 64 lowercase hex characters.
+func IsWebhookTokenFormat(token string) bool {
+	if len(token) != webhookTokenLength {
+		return false
+	}
+	for i := 0; i < len(token); i++ {
+		c := token[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/kie"
+)
+
+// maxKeyCheckBodySize limits the size of external API response bodies to prevent memory exhaustion
+const maxKeyCheckBodySize = 1024 // 1KB
+
+// keyCheckTimeout bounds each provider request made to check an API key.
+const keyCheckTimeout = 10 * time.Second
+
+// defaultOpenRouterBaseURL is the OpenRouter API used when none is configured.
+const defaultOpenRouterBaseURL = "https://openrouter.ai/api/v1"
+
+// unverifiedKeyMessage is returned when a provider neither accepted nor rejected a key.
+const unverifiedKeyMessage = "Could not verify the API key right now. Please try again later."
+
+// KeyCheckResult is the outcome of checking an API key against its provider.
+// Retryable is set when the key was neither accepted nor rejected, e.g. the
+// provider was unreachable or returned an error; checking again later may succeed.
+type KeyCheckResult struct {
+	Valid     bool   `json:"valid"`
+	Retryable bool   `json:"retryable"`
+	Message   string `json:"message"`
+}
+
+// APIKeyValidator checks user-supplied API keys against their providers.
+type APIKeyValidator interface {
+	CheckOpenRouterKey(ctx context.Context, apiKey string) KeyCheckResult
+	CheckKIEKey(ctx context.Context, apiKey string) KeyCheckResult
+}
+
+// apiKeyValidator implements APIKeyValidator with live provider requests.
+type apiKeyValidator struct {
+	httpClient        *http.Client
+	openRouterBaseURL string
+	kieBaseURL        string
+	logger            *zap.Logger
+}
+
+// NewAPIKeyValidator creates a new APIKeyValidator instance. Empty base URLs
+// use the public OpenRouter and KIE APIs.
+func NewAPIKeyValidator(openRouterBaseURL, kieBaseURL string, logger *zap.Logger) APIKeyValidator {
+	if openRouterBaseURL == "" {
+		openRouterBaseURL = defaultOpenRouterBaseURL
+	}
+	return &apiKeyValidator{
+		httpClient:        &http.Client{Timeout: keyCheckTimeout},
+		openRouterBaseURL: openRouterBaseURL,
+		kieBaseURL:        kieBaseURL,
+		logger:            logger,
+	}
+}
+
+// CheckOpenRouterKey checks the key against OpenRouter's key endpoint, which,
+// unlike the public model list, requires a valid key. Only 401 and 403 reject
+// the key; any other failure leaves it unverified.
+func (v *apiKeyValidator) CheckOpenRouterKey(ctx context.Context, apiKey string) KeyCheckResult {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.openRouterBaseURL+"/auth/key", nil)
+	if err != nil {
+		v.logger.Error("failed to create OpenRouter request", zap.Error(err))
+		return KeyCheckResult{Retryable: true, Message: unverifiedKeyMessage}
+	}
+
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		v.logger.Error("OpenRouter connection failed", zap.Error(err))
+		return KeyCheckResult{Retryable: true, Message: "Connection failed. Please check your network and try again."}
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return KeyCheckResult{Valid: true, Message: "Connection successful"}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return KeyCheckResult{Message: "Invalid API key"}
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxKeyCheckBodySize))
+	v.logger.Error("OpenRouter API error",
+		zap.Int("status_code", resp.StatusCode),
+		zap.String("body", string(body)))
+	return KeyCheckResult{Retryable: true, Message: unverifiedKeyMessage}
+}
+
+// CheckKIEKey tests the KIE API connection using the credits endpoint. Only 401
+// and 403 reject the key; any other failure leaves it unverified.
+func (v *apiKeyValidator) CheckKIEKey(ctx context.Context, apiKey string) KeyCheckResult {
+	credits, err := kie.NewAccountClient(apiKey, v.kieBaseURL).GetCredits(ctx)
+	if err == nil {
+		return KeyCheckResult{Valid: true, Message: fmt.Sprintf("Connection successful. Credits: %d", credits)}
+	}
+
+	var apiErr *kie.APIError
+	if !errors.As(err, &apiErr) {
+		v.logger.Error("KIE connection failed", zap.Error(err))
+		return KeyCheckResult{Retryable: true, Message: "Connection failed. Please check your network and try again."}
+	}
+
+	// Handle specific error codes per KIE API docs
+	switch apiErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return KeyCheckResult{Message: "Invalid API key"}
+
+	case http.StatusPaymentRequired: // 402 - Insufficient Credits
+		return KeyCheckResult{Retryable: true, Message: "Insufficient credits in your KIE account"}
+
+	case http.StatusTooManyRequests: // 429
+		return KeyCheckResult{Retryable: true, Message: "Rate limited. Please try again later."}
+
+	case 455: // KIE-specific: Service Unavailable
+		return KeyCheckResult{Retryable: true, Message: "KIE service temporarily unavailable. Please try again later."}
+
+	case 505: // KIE-specific: Feature Disabled
+		return KeyCheckResult{Retryable: true, Message: "This feature is disabled for your account"}
+
+	default:
+		v.logger.Error("KIE API error",
+			zap.Int("status_code", apiErr.StatusCode),
+			zap.String("body", apiErr.Message))
+		return KeyCheckResult{Retryable: true, Message: unverifiedKeyMessage}
+	}
+}
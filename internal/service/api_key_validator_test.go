@@ -0,0 +1,99 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/service"
+)
+
+// keyCheckServer answers every request with status and records the request path
+// and Authorization header.
+func keyCheckServer(t *testing.T, status int, body string) (*httptest.Server, *http.Request) {
+	t.Helper()
+	seen := &http.Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*seen = *r.Clone(context.Background())
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, seen
+}
+
+func TestCheckOpenRouterKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantValid     bool
+		wantRetryable bool
+	}{
+		{name: "accepted", status: http.StatusOK, wantValid: true},
+		{name: "unauthorized", status: http.StatusUnauthorized},
+		{name: "forbidden", status: http.StatusForbidden},
+		{name: "rate limited", status: http.StatusTooManyRequests, wantRetryable: true},
+		{name: "server error", status: http.StatusBadGateway, wantRetryable: true},
+		{name: "not found", status: http.StatusNotFound, wantRetryable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, seen := keyCheckServer(t, tt.status, `{"data":{}}`)
+			validator := service.NewAPIKeyValidator(server.URL+"/api/v1", "", zap.NewNop())
+
+			result := validator.CheckOpenRouterKey(context.Background(), "sk-or-test")
+			if result.Valid != tt.wantValid || result.Retryable != tt.wantRetryable {
+				t.Fatalf("result = %+v, want valid %v, retryable %v", result, tt.wantValid, tt.wantRetryable)
+			}
+			if seen.URL.Path != "/api/v1/auth/key" {
+				t.Errorf("checked %s, want /api/v1/auth/key", seen.URL.Path)
+			}
+			if got := seen.Header.Get("Authorization"); got != "Bearer sk-or-test" {
+				t.Errorf("Authorization = %q, want the key as bearer token", got)
+			}
+		})
+	}
+}
+
+func TestCheckOpenRouterKeyUnreachable(t *testing.T) {
+	server, _ := keyCheckServer(t, http.StatusOK, "")
+	server.Close()
+	validator := service.NewAPIKeyValidator(server.URL, "", zap.NewNop())
+
+	result := validator.CheckOpenRouterKey(context.Background(), "sk-or-test")
+	if result.Valid || !result.Retryable {
+		t.Fatalf("result = %+v, want an unverified, retryable result", result)
+	}
+}
+
+func TestCheckKIEKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantValid     bool
+		wantRetryable bool
+	}{
+		{name: "accepted", status: http.StatusOK, wantValid: true},
+		{name: "unauthorized", status: http.StatusUnauthorized},
+		{name: "forbidden", status: http.StatusForbidden},
+		{name: "insufficient credits", status: http.StatusPaymentRequired, wantRetryable: true},
+		{name: "service unavailable", status: 455, wantRetryable: true},
+		{name: "server error", status: http.StatusInternalServerError, wantRetryable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := keyCheckServer(t, tt.status, `{"code":200,"msg":"success","data":100}`)
+			validator := service.NewAPIKeyValidator("", server.URL, zap.NewNop())
+
+			result := validator.CheckKIEKey(context.Background(), "kie-test")
+			if result.Valid != tt.wantValid || result.Retryable != tt.wantRetryable {
+				t.Fatalf("result = %+v, want valid %v, retryable %v", result, tt.wantValid, tt.wantRetryable)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/logsanitize"
+	"github.com/jaochai/ugc/pkg/response"
+)
+
+// AuditEntry describes an action to record in the audit log.
+type AuditEntry struct {
+	UserID *uuid.UUID // nil when the actor is unknown, e.g. a failed login
+	Action string     // one of the models.AuditAction* constants
+	Target string     // what the action applied to, e.g. a prompt type; optional
+	// Metadata must not hold secrets; record keys as flags like "openrouter_key_set": true.
+	Metadata map[string]interface{}
+	IP       string
+}
+
+// AuditService records security-relevant actions.
+type AuditService interface {
+	// Log records an entry. It is best effort: a failure is logged and the
+	// action it describes still succeeds.
+	Log(ctx context.Context, entry AuditEntry)
+	List(ctx context.Context, filter models.AuditLogFilter, page, perPage int) ([]*models.AuditLog, *response.Meta, error)
+}
+
+// auditService implements AuditService.
+type auditService struct {
+	auditRepo repository.AuditLogRepository
+	logger    *zap.Logger
+}
+
+// NewAuditService creates a new AuditService instance.
+func NewAuditService(auditRepo repository.AuditLogRepository, logger *zap.Logger) AuditService {
+	return &auditService{
+		auditRepo: auditRepo,
+		logger:    logger,
+	}
+}
+
+// Log records an entry in the audit log.
+func (s *auditService) Log(ctx context.Context, entry AuditEntry) {
+	record := &models.AuditLog{
+		UserID:   entry.UserID,
+		Action:   entry.Action,
+		Metadata: sanitizeAuditMetadata(entry.Metadata),
+	}
+	if entry.Target != "" {
+		record.Target = &entry.Target
+	}
+	if entry.IP != "" {
+		record.IP = &entry.IP
+	}
+
+	// The audit record should survive the request being cancelled right after the action
+	if err := s.auditRepo.Create(context.WithoutCancel(ctx), record); err != nil {
+		fields := []zap.Field{zap.Error(err), zap.String("action", entry.Action)}
+		if entry.UserID != nil {
+			fields = append(fields, zap.String("user_id", entry.UserID.String()))
+		}
+		s.logger.Error("failed to write audit log", fields...)
+	}
+}
+
+// List returns a page of audit log entries, newest first.
+func (s *auditService) List(ctx context.Context, filter models.AuditLogFilter, page, perPage int) ([]*models.AuditLog, *response.Meta, error) {
+	entries, total, err := s.auditRepo.List(ctx, filter, page, perPage)
+	if err != nil {
+		s.logger.Error("failed to list audit logs", zap.Error(err))
+		return nil, nil, apperrors.NewInternalError(err)
+	}
+	return entries, response.NewMeta(page, perPage, total), nil
+}
+
+// sensitiveAuditWords mark metadata fields that could hold a secret.
+var sensitiveAuditWords = []string{"key", "token", "secret", "password"}
+
+// sanitizeAuditMetadata guards against secrets reaching the audit log: a field
+// whose name suggests a secret keeps only boolean values; anything else is redacted.
+func sanitizeAuditMetadata(metadata map[string]interface{}) map[string]interface{} {
+	sanitized := make(map[string]interface{}, len(metadata))
+	for name, value := range metadata {
+		if _, isFlag := value.(bool); !isFlag && isSensitiveAuditField(name) {
+			value = logsanitize.Redacted
+		}
+		sanitized[name] = value
+	}
+	return sanitized
+}
+
+// isSensitiveAuditField reports whether a metadata field name suggests a secret.
+func isSensitiveAuditField(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range sensitiveAuditWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
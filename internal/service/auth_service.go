@@ -2,8 +2,15 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"html"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -11,63 +18,152 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/jaochai/ugc/internal/email"
 	"github.com/jaochai/ugc/internal/models"
 	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/security"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+	"github.com/jaochai/ugc/pkg/logsanitize"
 )
 
-// Auth service errors
+// Auth service errors. They are AppErrors so handlers can return them directly
+// with their HTTP status and error code; errors.Is still matches each one.
 var (
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrEmailAlreadyExists = errors.New("email already exists")
-	ErrInvalidToken       = errors.New("invalid token")
-	ErrTokenExpired       = errors.New("token expired")
-	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidCredentials  = apperrors.NewUnauthorized("invalid email or password").WithCode(apperrors.CodeInvalidCredentials)
+	ErrEmailAlreadyExists  = apperrors.NewBadRequest("email already exists").WithCode(apperrors.CodeEmailAlreadyExists)
+	ErrInvalidToken        = apperrors.NewUnauthorized("invalid token").WithCode(apperrors.CodeInvalidToken)
+	ErrTokenExpired        = apperrors.NewUnauthorized("token expired").WithCode(apperrors.CodeTokenExpired)
+	ErrUserNotFound        = apperrors.NewNotFound("user not found").WithCode(apperrors.CodeUserNotFound)
+	ErrAccountDeleted      = apperrors.NewUnauthorized("account has been deleted").WithCode(apperrors.CodeAccountDeleted)
+	ErrInvalidPassword     = apperrors.NewBadRequest("invalid password").WithCode(apperrors.CodeInvalidCredentials)
+	ErrInvalidResetToken   = apperrors.NewBadRequest("invalid or expired reset token").WithCode(apperrors.CodeInvalidResetToken)
+	ErrInvalidRefreshToken = apperrors.NewUnauthorized("invalid or expired refresh token").WithCode(apperrors.CodeInvalidRefreshToken)
+	ErrAccountDisabled     = apperrors.NewForbidden("account has been disabled").WithCode(apperrors.CodeAccountDisabled)
+	ErrDisposableEmail     = apperrors.NewBadRequest("disposable email addresses are not allowed").WithCode(apperrors.CodeDisposableEmail)
 )
 
+// ErrAccountLocked returns the error for a login attempt on an address locked after
+// too many failed logins. retryAfter is included in details as retry_after_seconds.
+// The message is the same whether or not an account exists for the address.
+func ErrAccountLocked(retryAfter time.Duration) *apperrors.AppError {
+	seconds := int(retryAfter.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return apperrors.NewTooManyRequests("account temporarily locked after too many failed login attempts").
+		WithCode(apperrors.CodeAccountLocked).
+		WithDetails(map[string]string{"retry_after_seconds": strconv.Itoa(seconds)})
+}
+
+// Refresh token settings.
+const (
+	refreshTokenBytes  = 32
+	maxUserAgentLength = 512
+)
+
+// AuthConfig holds token settings for AuthService.
+type AuthConfig struct {
+	JWTSecret     string
+	AccessExpiry  time.Duration // Lifetime of access tokens
+	RefreshExpiry time.Duration // Lifetime of refresh tokens
+	FrontendURL   string        // Base URL for links in emails, e.g. password reset
+	// EmailBlocklist rejects registrations from disposable email domains; nil allows all.
+	EmailBlocklist *security.EmailDomainBlocklist
+}
+
+// AuthTokens is the token pair returned on login and refresh.
+type AuthTokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time // Access token expiry
+}
+
+// DeviceInfo describes the client a refresh token is issued to.
+type DeviceInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Short-lived token purposes. A token issued for one purpose is rejected for any other,
+// and tokens with a purpose are never accepted as access tokens.
+const (
+	TokenPurposeOAuthState    = "oauth_state"
+	TokenPurposePasswordReset = "password_reset"
+)
+
+// PasswordResetExpiry is how long a password reset link stays valid.
+const PasswordResetExpiry = 30 * time.Minute
+
 // Claims represents the JWT claims
 type Claims struct {
 	UserID uuid.UUID `json:"user_id"`
 	Email  string    `json:"email"`
 	Role   string    `json:"role"`
+	// Purpose is set on short-lived tokens; empty for access tokens
+	Purpose string `json:"purpose,omitempty"`
+	// Locale is the user's preferred message language, loaded by AuthenticateToken; not part of the token
+	Locale string `json:"-"`
 	jwt.RegisteredClaims
 }
 
 // AuthService defines the interface for authentication operations
 type AuthService interface {
 	Register(ctx context.Context, input models.CreateUserInput) (*models.User, error)
-	Login(ctx context.Context, input models.LoginInput) (string, *models.User, error)
+	Login(ctx context.Context, input models.LoginInput, device DeviceInfo) (*AuthTokens, *models.User, error)
 	ValidateToken(token string) (*Claims, error)
-	RefreshToken(token string) (string, error)
+	AuthenticateToken(ctx context.Context, token string) (*Claims, error)
+	RefreshToken(ctx context.Context, refreshToken string, device DeviceInfo) (*AuthTokens, error)
+	Logout(ctx context.Context, refreshToken string) error
 	GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error)
-	GenerateShortToken(userID uuid.UUID, expiry time.Duration) (string, error)
-	ValidateShortToken(tokenString string) (uuid.UUID, error)
+	GenerateShortToken(userID uuid.UUID, purpose string, expiry time.Duration) (string, error)
+	ValidateShortToken(tokenString string, purpose string) (uuid.UUID, error)
+	DeleteAccount(ctx context.Context, userID uuid.UUID, password string) error
+	ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
 }
 
 // authService implements AuthService
 type authService struct {
-	userRepo  repository.UserRepository
-	jwtSecret string
-	jwtExpiry time.Duration
-	logger    *zap.Logger
+	userRepo          repository.UserRepository
+	passwordResetRepo repository.PasswordResetRepository
+	refreshTokenRepo  repository.RefreshTokenRepository
+	mailer            email.Mailer
+	loginLimiter      LoginLimiter // nil disables the login lockout
+	cfg               AuthConfig
+	logger            *zap.Logger
 }
 
-// NewAuthService creates a new AuthService instance
+// NewAuthService creates a new AuthService instance. loginLimiter may be nil,
+// which disables the lockout after repeated failed logins.
 func NewAuthService(
 	userRepo repository.UserRepository,
-	jwtSecret string,
-	jwtExpiry time.Duration,
+	passwordResetRepo repository.PasswordResetRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+	mailer email.Mailer,
+	loginLimiter LoginLimiter,
+	cfg AuthConfig,
 	logger *zap.Logger,
 ) AuthService {
 	return &authService{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
-		jwtExpiry: jwtExpiry,
-		logger:    logger,
+		userRepo:          userRepo,
+		passwordResetRepo: passwordResetRepo,
+		refreshTokenRepo:  refreshTokenRepo,
+		mailer:            mailer,
+		loginLimiter:      loginLimiter,
+		cfg:               cfg,
+		logger:            logger,
 	}
 }
 
 // Register creates a new user account
 func (s *authService) Register(ctx context.Context, input models.CreateUserInput) (*models.User, error) {
+	// New accounts are stored with a normalized email; lookups are case-insensitive
+	input.Email = security.NormalizeEmail(input.Email)
+	if err := s.cfg.EmailBlocklist.Check(input.Email); err != nil {
+		return nil, ErrDisposableEmail
+	}
+
 	// Check if email already exists
 	existingUser, err := s.userRepo.GetByEmail(ctx, input.Email)
 	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
@@ -98,38 +194,102 @@ func (s *authService) Register(ctx context.Context, input models.CreateUserInput
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	s.logger.Info("user registered successfully", zap.String("email", user.Email), zap.String("user_id", user.ID.String()))
+	s.logger.Info("user registered successfully", zap.String("email", logsanitize.Email(user.Email)), zap.String("user_id", user.ID.String()))
 
 	return user, nil
 }
 
-// Login authenticates a user and returns a JWT token
-func (s *authService) Login(ctx context.Context, input models.LoginInput) (string, *models.User, error) {
+// Login authenticates a user and returns an access token and a new refresh token
+func (s *authService) Login(ctx context.Context, input models.LoginInput, device DeviceInfo) (*AuthTokens, *models.User, error) {
+	// Locked addresses are rejected before the password is checked
+	if err := s.checkLoginLock(ctx, input.Email); err != nil {
+		return nil, nil, err
+	}
+
 	// Find user by email
 	user, err := s.userRepo.GetByEmail(ctx, input.Email)
 	if err != nil {
 		if errors.Is(err, repository.ErrUserNotFound) {
-			return "", nil, ErrInvalidCredentials
+			return nil, nil, s.loginFailed(ctx, input.Email)
 		}
 		s.logger.Error("failed to get user by email", zap.Error(err))
-		return "", nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Deleted accounts cannot log in
+	if user.IsDeleted() {
+		return nil, nil, s.loginFailed(ctx, input.Email)
 	}
 
 	// Compare password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
-		return "", nil, ErrInvalidCredentials
+		return nil, nil, s.loginFailed(ctx, input.Email)
 	}
 
-	// Generate JWT token
-	token, err := s.generateToken(user)
+	if s.loginLimiter != nil {
+		if err := s.loginLimiter.Reset(ctx, input.Email); err != nil {
+			s.logger.Warn("failed to reset login failures", zap.Error(err))
+		}
+	}
+
+	// Only reveal that the account is disabled once the password is verified
+	if user.Disabled {
+		return nil, nil, ErrAccountDisabled
+	}
+
+	// Each login starts a new refresh token family
+	refresh, refreshToken, err := s.newRefreshToken(user.ID, uuid.New(), device)
 	if err != nil {
-		s.logger.Error("failed to generate token", zap.Error(err))
-		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+		return nil, nil, err
+	}
+	if err := s.refreshTokenRepo.Create(ctx, refresh); err != nil {
+		s.logger.Error("failed to store refresh token", zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	tokens, err := s.issueAccessToken(user, refreshToken)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	s.logger.Info("user logged in successfully", zap.String("email", user.Email), zap.String("user_id", user.ID.String()))
+	s.logger.Info("user logged in successfully", zap.String("email", logsanitize.Email(user.Email)), zap.String("user_id", user.ID.String()))
 
-	return token, user, nil
+	return tokens, user, nil
+}
+
+// checkLoginLock returns ErrAccountLocked while the address is locked. The limiter
+// fails open: if Redis is unavailable, logins are not blocked.
+func (s *authService) checkLoginLock(ctx context.Context, email string) error {
+	if s.loginLimiter == nil {
+		return nil
+	}
+	remaining, err := s.loginLimiter.Locked(ctx, email)
+	if err != nil {
+		s.logger.Warn("failed to check login lock", zap.Error(err))
+		return nil
+	}
+	if remaining > 0 {
+		return ErrAccountLocked(remaining)
+	}
+	return nil
+}
+
+// loginFailed records a failed login and returns the error to show the client:
+// ErrInvalidCredentials, or ErrAccountLocked when this failure locked the address.
+func (s *authService) loginFailed(ctx context.Context, email string) error {
+	if s.loginLimiter == nil {
+		return ErrInvalidCredentials
+	}
+	lockedFor, err := s.loginLimiter.RecordFailure(ctx, email)
+	if err != nil {
+		s.logger.Warn("failed to record login failure", zap.Error(err))
+		return ErrInvalidCredentials
+	}
+	if lockedFor > 0 {
+		s.logger.Warn("login locked after repeated failures", zap.Duration("lock_duration", lockedFor))
+		return ErrAccountLocked(lockedFor)
+	}
+	return ErrInvalidCredentials
 }
 
 // ValidateToken parses and validates a JWT token
@@ -141,7 +301,7 @@ func (s *authService) ValidateToken(tokenString string) (*Claims, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(s.jwtSecret), nil
+		return []byte(s.cfg.JWTSecret), nil
 	})
 
 	if err != nil {
@@ -155,48 +315,194 @@ func (s *authService) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, ErrInvalidToken
 	}
 
+	// Short-lived tokens (OAuth state, password reset) are not access tokens
+	if claims.Purpose != "" {
+		return nil, ErrInvalidToken
+	}
+
 	return claims, nil
 }
 
-// RefreshToken validates an existing token and generates a new one with fresh expiry
-func (s *authService) RefreshToken(tokenString string) (string, error) {
-	// Validate existing token
+// AuthenticateToken validates a JWT token and checks that its user still exists
+// and has not been deleted. Used for every authenticated request.
+func (s *authService) AuthenticateToken(ctx context.Context, tokenString string) (*Claims, error) {
 	claims, err := s.ValidateToken(tokenString)
 	if err != nil {
-		// Allow refresh for expired tokens (within reasonable time)
-		if !errors.Is(err, ErrTokenExpired) {
-			return "", err
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrAccountDeleted
 		}
-		// Re-parse to get claims even if expired
-		claims = &Claims{}
-		_, _ = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(s.jwtSecret), nil
-		})
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	// Create new token with fresh expiry
-	now := time.Now()
-	newClaims := &Claims{
-		UserID: claims.UserID,
-		Email:  claims.Email,
-		Role:   claims.Role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(s.jwtExpiry)),
-			IssuedAt:  jwt.NewNumericDate(now),
-			NotBefore: jwt.NewNumericDate(now),
-			Subject:   claims.UserID.String(),
-		},
+	if user.IsDeleted() {
+		return nil, ErrAccountDeleted
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	// Use the current role so role changes apply before the token expires
+	claims.Role = user.Role
+	if user.Locale != nil {
+		claims.Locale = *user.Locale
+	}
+
+	return claims, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. The presented token is revoked; presenting an already revoked
+// token is treated as theft and revokes every token issued from the same login.
+func (s *authService) RefreshToken(ctx context.Context, refreshToken string, device DeviceInfo) (*AuthTokens, error) {
+	stored, err := s.refreshTokenRepo.GetByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		s.logger.Error("failed to get refresh token", zap.Error(err))
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if stored.RevokedAt != nil {
+		s.revokeRefreshTokenFamily(ctx, stored)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if !stored.IsActive(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrAccountDeleted
+		}
+		s.logger.Error("failed to get user by id", zap.Error(err), zap.String("user_id", stored.UserID.String()))
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.IsDeleted() {
+		return nil, ErrAccountDeleted
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	next, nextToken, err := s.newRefreshToken(user.ID, stored.FamilyID, device)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.refreshTokenRepo.Rotate(ctx, stored.ID, next); err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenRevoked) {
+			// Lost a race with another refresh using the same token
+			s.revokeRefreshTokenFamily(ctx, stored)
+			return nil, ErrInvalidRefreshToken
+		}
+		s.logger.Error("failed to rotate refresh token", zap.Error(err))
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	tokens, err := s.issueAccessToken(user, nextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("token refreshed successfully", zap.String("user_id", user.ID.String()))
+
+	return tokens, nil
+}
+
+// Logout revokes a refresh token. Unknown or already revoked tokens are ignored.
+func (s *authService) Logout(ctx context.Context, refreshToken string) error {
+	stored, err := s.refreshTokenRepo.GetByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+			return nil
+		}
+		s.logger.Error("failed to get refresh token", zap.Error(err))
+		return fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if err := s.refreshTokenRepo.Revoke(ctx, stored.ID); err != nil {
+		s.logger.Error("failed to revoke refresh token", zap.Error(err))
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	s.logger.Info("user logged out", zap.String("user_id", stored.UserID.String()))
+
+	return nil
+}
+
+// revokeRefreshTokenFamily revokes every token issued from the same login as token.
+func (s *authService) revokeRefreshTokenFamily(ctx context.Context, token *models.RefreshToken) {
+	s.logger.Warn("refresh token reuse detected, revoking token family",
+		zap.String("user_id", token.UserID.String()),
+		zap.String("family_id", token.FamilyID.String()),
+	)
+	if err := s.refreshTokenRepo.RevokeFamily(ctx, token.FamilyID); err != nil {
+		s.logger.Error("failed to revoke refresh token family", zap.Error(err))
+	}
+}
+
+// revokeAllRefreshTokens signs the user out of every device. Failures are logged only.
+func (s *authService) revokeAllRefreshTokens(ctx context.Context, userID uuid.UUID) {
+	if err := s.refreshTokenRepo.RevokeAllForUser(ctx, userID); err != nil {
+		s.logger.Error("failed to revoke refresh tokens", zap.Error(err), zap.String("user_id", userID.String()))
 	}
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims)
-	tokenString, err = token.SignedString([]byte(s.jwtSecret))
+// newRefreshToken creates a random opaque refresh token and its stored record.
+func (s *authService) newRefreshToken(userID, familyID uuid.UUID, device DeviceInfo) (*models.RefreshToken, string, error) {
+	buf := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	record := &models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.cfg.RefreshExpiry),
+	}
+	if device.UserAgent != "" {
+		userAgent := truncate(device.UserAgent, maxUserAgentLength)
+		record.UserAgent = &userAgent
+	}
+	if device.IPAddress != "" {
+		record.IPAddress = &device.IPAddress
+	}
+
+	return record, token, nil
+}
+
+// issueAccessToken generates an access token for user and pairs it with refreshToken.
+func (s *authService) issueAccessToken(user *models.User, refreshToken string) (*AuthTokens, error) {
+	accessToken, expiresAt, err := s.generateToken(user)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+		s.logger.Error("failed to generate token", zap.Error(err))
+		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
-	s.logger.Info("token refreshed successfully", zap.String("user_id", claims.UserID.String()))
+	return &AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
 
-	return tokenString, nil
+// truncate shortens s to at most max bytes.
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
 }
 
 // GetUserByID retrieves a user by their ID
@@ -213,12 +519,186 @@ func (s *authService) GetUserByID(ctx context.Context, id uuid.UUID) (*models.Us
 	return user, nil
 }
 
-// GenerateShortToken creates a short-lived JWT for OAuth state parameter (CSRF protection).
-func (s *authService) GenerateShortToken(userID uuid.UUID, expiry time.Duration) (string, error) {
+// DeleteAccount re-checks the user's password and marks the account as deleted.
+// Tokens are rejected from then on; jobs, assets and secrets are removed by the
+// delete user data task.
+func (s *authService) DeleteAccount(ctx context.Context, userID uuid.UUID, password string) error {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.IsDeleted() {
+		return ErrAccountDeleted
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return ErrInvalidPassword
+	}
+
+	if err := s.userRepo.SoftDelete(ctx, userID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		s.logger.Error("failed to soft delete user", zap.Error(err), zap.String("user_id", userID.String()))
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	s.revokeAllRefreshTokens(ctx, userID)
+
+	s.logger.Info("account marked as deleted", zap.String("user_id", userID.String()))
+
+	return nil
+}
+
+// ChangePassword replaces the user's password after verifying the current one.
+func (s *authService) ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string) error {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)); err != nil {
+		return ErrInvalidPassword
+	}
+
+	if err := s.setPassword(ctx, userID, newPassword); err != nil {
+		return err
+	}
+
+	// Sign out other devices; the caller keeps its access token until it expires
+	s.revokeAllRefreshTokens(ctx, userID)
+
+	s.logger.Info("password changed", zap.String("user_id", userID.String()))
+
+	return nil
+}
+
+// RequestPasswordReset emails a single-use reset link to the account with the
+// given email. It returns nil for unknown or deleted accounts so callers cannot
+// probe which emails are registered.
+func (s *authService) RequestPasswordReset(ctx context.Context, emailAddr string) error {
+	user, err := s.userRepo.GetByEmail(ctx, emailAddr)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			s.logger.Info("password reset requested for unknown email")
+			return nil
+		}
+		s.logger.Error("failed to get user by email", zap.Error(err))
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.IsDeleted() {
+		return nil
+	}
+
+	token, claims, err := s.generateShortToken(user.ID, TokenPurposePasswordReset, PasswordResetExpiry)
+	if err != nil {
+		s.logger.Error("failed to generate password reset token", zap.Error(err))
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	// Only a hash of the token ID is stored; it marks the token as used once consumed
+	if err := s.passwordResetRepo.Create(ctx, user.ID, hashToken(claims.ID), claims.ExpiresAt.Time); err != nil {
+		s.logger.Error("failed to store password reset", zap.Error(err))
+		return fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	resetURL := s.cfg.FrontendURL + "/reset-password?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf(
+		`<p>A password reset was requested for your account.</p><p><a href="%s">Reset your password</a></p><p>The link expires in %d minutes. If you did not request this, ignore this email.</p>`,
+		html.EscapeString(resetURL), int(PasswordResetExpiry.Minutes()),
+	)
+	if err := s.mailer.Send(ctx, user.Email, "Reset your password", body); err != nil {
+		s.logger.Error("failed to send password reset email", zap.Error(err), zap.String("user_id", user.ID.String()))
+		return fmt.Errorf("failed to send reset email: %w", err)
+	}
+
+	s.logger.Info("password reset requested", zap.String("user_id", user.ID.String()))
+
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token. Each token can be used once.
+func (s *authService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	claims, err := s.parseShortToken(token, TokenPurposePasswordReset)
+	if err != nil || claims.ID == "" {
+		return ErrInvalidResetToken
+	}
+
+	userID, err := s.passwordResetRepo.Consume(ctx, hashToken(claims.ID))
+	if err != nil {
+		if errors.Is(err, repository.ErrPasswordResetNotFound) {
+			return ErrInvalidResetToken
+		}
+		s.logger.Error("failed to consume password reset", zap.Error(err))
+		return fmt.Errorf("failed to consume reset token: %w", err)
+	}
+	if userID != claims.UserID {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	if user.IsDeleted() {
+		return ErrInvalidResetToken
+	}
+
+	if err := s.setPassword(ctx, userID, newPassword); err != nil {
+		return err
+	}
+
+	s.revokeAllRefreshTokens(ctx, userID)
+
+	s.logger.Info("password reset completed", zap.String("user_id", userID.String()))
+
+	return nil
+}
+
+// setPassword hashes and stores a new password.
+func (s *authService) setPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.Error("failed to hash password", zap.Error(err))
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, userID, string(hashedPassword)); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		s.logger.Error("failed to update password", zap.Error(err), zap.String("user_id", userID.String()))
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return nil
+}
+
+// hashToken returns the hex SHA-256 of a token or token ID, as stored in the database.
+func hashToken(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateShortToken creates a short-lived JWT for a single purpose, e.g. the
+// OAuth state parameter (CSRF protection) or a password reset link.
+func (s *authService) GenerateShortToken(userID uuid.UUID, purpose string, expiry time.Duration) (string, error) {
+	token, _, err := s.generateShortToken(userID, purpose, expiry)
+	return token, err
+}
+
+// generateShortToken creates a short-lived JWT and also returns its unique token ID (jti).
+func (s *authService) generateShortToken(userID uuid.UUID, purpose string, expiry time.Duration) (string, *Claims, error) {
 	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
+		UserID:  userID,
+		Purpose: purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			Subject:   userID.String(),
@@ -226,40 +706,57 @@ func (s *authService) GenerateShortToken(userID uuid.UUID, expiry time.Duration)
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
+	signed, err := token.SignedString([]byte(s.cfg.JWTSecret))
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
-// ValidateShortToken validates a short-lived JWT and returns the user ID.
-func (s *authService) ValidateShortToken(tokenString string) (uuid.UUID, error) {
+// ValidateShortToken validates a short-lived JWT issued for purpose and returns the user ID.
+func (s *authService) ValidateShortToken(tokenString string, purpose string) (uuid.UUID, error) {
+	claims, err := s.parseShortToken(tokenString, purpose)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return claims.UserID, nil
+}
+
+// parseShortToken validates a short-lived JWT issued for purpose and returns its claims.
+func (s *authService) parseShortToken(tokenString string, purpose string) (*Claims, error) {
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(s.jwtSecret), nil
+		return []byte(s.cfg.JWTSecret), nil
 	})
 
 	if err != nil {
-		return uuid.Nil, ErrInvalidToken
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrInvalidToken
 	}
 
-	if !token.Valid {
-		return uuid.Nil, ErrInvalidToken
+	if !token.Valid || claims.Purpose != purpose {
+		return nil, ErrInvalidToken
 	}
 
-	return claims.UserID, nil
+	return claims, nil
 }
 
-// generateToken creates a new JWT token for the given user
-func (s *authService) generateToken(user *models.User) (string, error) {
+// generateToken creates a new access token for the given user and returns its expiry
+func (s *authService) generateToken(user *models.User) (string, time.Time, error) {
 	now := time.Now()
+	expiresAt := now.Add(s.cfg.AccessExpiry)
 	claims := &Claims{
 		UserID: user.ID,
 		Email:  user.Email,
 		Role:   user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(s.jwtExpiry)),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 			Subject:   user.ID.String(),
@@ -267,5 +764,9 @@ func (s *authService) generateToken(user *models.User) (string, error) {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
+	signed, err := token.SignedString([]byte(s.cfg.JWTSecret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
 }
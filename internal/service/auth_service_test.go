@@ -0,0 +1,318 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/security"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+const testPassword = "correct horse battery staple"
+
+// newRefreshTestService returns an auth service over in-memory users and
+// refresh tokens, and a user who can log in with testPassword.
+func newRefreshTestService(t *testing.T, refreshExpiry time.Duration) (service.AuthService, *testutil.FakeRefreshTokenRepository, *models.User) {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	user := &models.User{ID: uuid.New(), Email: "user@example.com", PasswordHash: string(hash), Role: models.RoleUser}
+	tokens := testutil.NewFakeRefreshTokenRepository()
+
+	authService := service.NewAuthService(testutil.NewFakeUserRepository(user), nil, tokens, nil, nil,
+		service.AuthConfig{
+			JWTSecret:     "test-jwt-secret-0123456789abcdef0123456789",
+			AccessExpiry:  15 * time.Minute,
+			RefreshExpiry: refreshExpiry,
+		}, zap.NewNop())
+	return authService, tokens, user
+}
+
+// login returns the refresh token of a new login.
+func login(t *testing.T, authService service.AuthService, user *models.User) string {
+	t.Helper()
+	tokens, _, err := authService.Login(context.Background(), models.LoginInput{Email: user.Email, Password: testPassword}, service.DeviceInfo{})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	return tokens.RefreshToken
+}
+
+// activeFamilies returns the number of active tokens per family.
+func activeFamilies(repo *testutil.FakeRefreshTokenRepository) map[uuid.UUID]int {
+	families := make(map[uuid.UUID]int)
+	for _, token := range repo.Tokens() {
+		if _, ok := families[token.FamilyID]; !ok {
+			families[token.FamilyID] = 0
+		}
+		if token.IsActive(time.Now()) {
+			families[token.FamilyID]++
+		}
+	}
+	return families
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	authService, repo, user := newRefreshTestService(t, time.Hour)
+	ctx := context.Background()
+
+	first := login(t, authService, user)
+	rotated, err := authService.RefreshToken(ctx, first, service.DeviceInfo{UserAgent: "test"})
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+	if rotated.RefreshToken == first || rotated.RefreshToken == "" || rotated.AccessToken == "" {
+		t.Fatalf("RefreshToken() = %+v, want a new token pair", rotated)
+	}
+
+	// The presented token is replaced by the new one in the same family
+	stored := repo.Tokens()
+	if len(stored) != 2 {
+		t.Fatalf("stored %d refresh tokens, want 2", len(stored))
+	}
+	var old, next models.RefreshToken
+	for _, token := range stored {
+		if token.RevokedAt != nil {
+			old = token
+		} else {
+			next = token
+		}
+	}
+	if old.ReplacedBy == nil || *old.ReplacedBy != next.ID {
+		t.Errorf("old token replaced by %v, want %s", old.ReplacedBy, next.ID)
+	}
+	if old.FamilyID != next.FamilyID {
+		t.Errorf("rotated token family = %s, want %s", next.FamilyID, old.FamilyID)
+	}
+
+	// The new token rotates in turn
+	if _, err := authService.RefreshToken(ctx, rotated.RefreshToken, service.DeviceInfo{}); err != nil {
+		t.Errorf("RefreshToken() of the rotated token error = %v", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	authService, repo, user := newRefreshTestService(t, time.Hour)
+	ctx := context.Background()
+
+	first := login(t, authService, user)
+	otherDevice := login(t, authService, user)
+	rotated, err := authService.RefreshToken(ctx, first, service.DeviceInfo{})
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+
+	// Replaying the rotated-out token signs the whole login out
+	if _, err := authService.RefreshToken(ctx, first, service.DeviceInfo{}); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Fatalf("RefreshToken() of a reused token error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := authService.RefreshToken(ctx, rotated.RefreshToken, service.DeviceInfo{}); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("RefreshToken() of the token issued before the reuse error = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// Other logins are not affected
+	if _, err := authService.RefreshToken(ctx, otherDevice, service.DeviceInfo{}); err != nil {
+		t.Errorf("RefreshToken() of another login error = %v", err)
+	}
+	revoked := 0
+	for _, active := range activeFamilies(repo) {
+		if active == 0 {
+			revoked++
+		}
+	}
+	if revoked != 1 {
+		t.Errorf("%d token families revoked, want 1", revoked)
+	}
+}
+
+// TestRefreshTokenConcurrentReuse checks that two refreshes racing with the same
+// token yield one new token pair at most, and revoke the family.
+func TestRefreshTokenConcurrentReuse(t *testing.T) {
+	authService, repo, user := newRefreshTestService(t, time.Hour)
+	first := login(t, authService, user)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = authService.RefreshToken(context.Background(), first, service.DeviceInfo{})
+		}()
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, service.ErrInvalidRefreshToken):
+			t.Errorf("RefreshToken() error = %v, want ErrInvalidRefreshToken", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d concurrent refreshes succeeded, want 1", succeeded)
+	}
+	for family, active := range activeFamilies(repo) {
+		if active != 0 {
+			t.Errorf("family %s has %d active tokens after reuse, want 0", family, active)
+		}
+	}
+}
+
+func TestRefreshTokenRejected(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("unknown token", func(t *testing.T) {
+		authService, _, _ := newRefreshTestService(t, time.Hour)
+		if _, err := authService.RefreshToken(ctx, "not-a-refresh-token", service.DeviceInfo{}); !errors.Is(err, service.ErrInvalidRefreshToken) {
+			t.Errorf("RefreshToken() error = %v, want ErrInvalidRefreshToken", err)
+		}
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		authService, repo, user := newRefreshTestService(t, -time.Minute)
+		expired := login(t, authService, user)
+		if _, err := authService.RefreshToken(ctx, expired, service.DeviceInfo{}); !errors.Is(err, service.ErrInvalidRefreshToken) {
+			t.Errorf("RefreshToken() error = %v, want ErrInvalidRefreshToken", err)
+		}
+		if tokens := repo.Tokens(); len(tokens) != 1 || tokens[0].RevokedAt != nil {
+			t.Errorf("tokens = %+v, want the expired token only, not revoked", tokens)
+		}
+	})
+
+	t.Run("deleted user", func(t *testing.T) {
+		authService, repo, user := newRefreshTestService(t, time.Hour)
+		refresh := login(t, authService, user)
+		deletedAt := time.Now()
+		user.DeletedAt = &deletedAt
+
+		if _, err := authService.RefreshToken(ctx, refresh, service.DeviceInfo{}); !errors.Is(err, service.ErrAccountDeleted) {
+			t.Errorf("RefreshToken() error = %v, want ErrAccountDeleted", err)
+		}
+		if tokens := repo.Tokens(); len(tokens) != 1 {
+			t.Errorf("stored %d refresh tokens, want no new token for a deleted user", len(tokens))
+		}
+	})
+
+	t.Run("logged out token", func(t *testing.T) {
+		authService, _, user := newRefreshTestService(t, time.Hour)
+		refresh := login(t, authService, user)
+		if err := authService.Logout(ctx, refresh); err != nil {
+			t.Fatalf("Logout() error = %v", err)
+		}
+		if _, err := authService.RefreshToken(ctx, refresh, service.DeviceInfo{}); !errors.Is(err, service.ErrInvalidRefreshToken) {
+			t.Errorf("RefreshToken() error = %v, want ErrInvalidRefreshToken", err)
+		}
+	})
+}
+
+// emailLookupRecorder records the addresses the auth service looks users up by.
+type emailLookupRecorder struct {
+	*testutil.FakeUserRepository
+	lookups []string
+}
+
+func (r *emailLookupRecorder) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.lookups = append(r.lookups, email)
+	return r.FakeUserRepository.GetByEmail(ctx, email)
+}
+
+// TestRegisterNormalizesEmail checks that a new account's email is lowercased
+// before the blocklist and the check for an existing account, so an address
+// differing only in case cannot register twice.
+func TestRegisterNormalizesEmail(t *testing.T) {
+	existing := &models.User{ID: uuid.New(), Email: "taken@example.com", Role: models.RoleUser}
+
+	tests := []struct {
+		name        string
+		email       string
+		wantErr     error
+		wantEmail   string // Email of the created account
+		wantLookups []string
+	}{
+		{name: "mixed case", email: "  New.User@Example.COM ", wantEmail: "new.user@example.com",
+			wantLookups: []string{"new.user@example.com"}},
+		{name: "existing address in another case", email: "TAKEN@Example.com", wantErr: service.ErrEmailAlreadyExists,
+			wantLookups: []string{"taken@example.com"}},
+		{name: "disposable domain", email: "someone@Mailinator.COM", wantErr: service.ErrDisposableEmail},
+		{name: "subdomain of a disposable domain", email: "someone@eu.mailinator.com", wantErr: service.ErrDisposableEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &emailLookupRecorder{FakeUserRepository: testutil.NewFakeUserRepository(existing)}
+			authService := service.NewAuthService(users, nil, nil, nil, nil, service.AuthConfig{
+				JWTSecret:      "test-jwt-secret-0123456789abcdef0123456789",
+				EmailBlocklist: security.NewEmailDomainBlocklist([]string{"mailinator.com"}),
+			}, zap.NewNop())
+
+			user, err := authService.Register(context.Background(), models.CreateUserInput{Email: tt.email, Password: testPassword})
+			if !slices.Equal(users.lookups, tt.wantLookups) {
+				t.Errorf("looked up %q, want %q", users.lookups, tt.wantLookups)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Register() error = %v, want %v", err, tt.wantErr)
+				}
+				if all, _, _ := users.List(context.Background(), models.UserFilter{}, 1, 10); len(all) != 1 {
+					t.Errorf("stored %d users, want only the existing one", len(all))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Register() error = %v", err)
+			}
+			stored, err := users.GetByID(context.Background(), user.ID)
+			if err != nil || stored.Email != tt.wantEmail {
+				t.Errorf("stored user = %+v (%v), want email %s", stored, err, tt.wantEmail)
+			}
+		})
+	}
+}
+
+// TestLoginWithMixedCaseEmail checks that an account registered before emails
+// were lowercased still logs in, whatever case the address is typed in.
+func TestLoginWithMixedCaseEmail(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	legacy := &models.User{ID: uuid.New(), Email: "Legacy.User@Example.com", PasswordHash: string(hash), Role: models.RoleUser}
+	authService := service.NewAuthService(testutil.NewFakeUserRepository(legacy), nil, testutil.NewFakeRefreshTokenRepository(), nil, nil,
+		service.AuthConfig{
+			JWTSecret:     "test-jwt-secret-0123456789abcdef0123456789",
+			AccessExpiry:  15 * time.Minute,
+			RefreshExpiry: time.Hour,
+		}, zap.NewNop())
+
+	for _, email := range []string{"Legacy.User@Example.com", "legacy.user@example.com", "LEGACY.USER@EXAMPLE.COM"} {
+		tokens, user, err := authService.Login(context.Background(), models.LoginInput{Email: email, Password: testPassword}, service.DeviceInfo{})
+		if err != nil {
+			t.Errorf("Login(%q) error = %v", email, err)
+			continue
+		}
+		if user.ID != legacy.ID || tokens.AccessToken == "" {
+			t.Errorf("Login(%q) = user %s, want %s with an access token", email, user.ID, legacy.ID)
+		}
+	}
+
+	_, _, err = authService.Login(context.Background(), models.LoginInput{Email: "legacy.user@example.com", Password: "wrong password"}, service.DeviceInfo{})
+	if !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Errorf("Login() with a wrong password error = %v, want ErrInvalidCredentials", err)
+	}
+}
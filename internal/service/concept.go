@@ -0,0 +1,65 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+)
+
+// Concept length limits, counted in characters (runes) so Thai concepts are
+// measured the way users see them.
+const (
+	MinConceptLength        = 5
+	DefaultMaxConceptLength = 2000
+)
+
+// NormalizeConcept prepares a concept for storage and prompts: newlines become
+// "\n", tabs become spaces, other control characters (including null bytes and
+// invalid UTF-8) are removed, and surrounding whitespace is trimmed.
+func NormalizeConcept(concept string) string {
+	concept = strings.ReplaceAll(concept, "\r\n", "\n")
+	concept = strings.ReplaceAll(concept, "\r", "\n")
+
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n':
+			return r
+		case r == '\t':
+			return ' '
+		case r == utf8.RuneError, unicode.IsControl(r), unicode.Is(unicode.Cf, r) && r != '\u200d':
+			// Cf covers zero-width and bidi marks; the zero-width joiner is kept for emoji
+			return -1
+		}
+		return r
+	}, concept))
+}
+
+// ValidateConcept checks the length of a normalized concept. maxLength <= 0
+// uses DefaultMaxConceptLength.
+func ValidateConcept(concept string, maxLength int) error {
+	if maxLength <= 0 {
+		maxLength = DefaultMaxConceptLength
+	}
+
+	length := utf8.RuneCountInString(concept)
+	switch {
+	case length == 0:
+		return apperrors.NewFieldError("concept", apperrors.FieldConceptRequired, "concept is required").
+			WithCode(apperrors.CodeInvalidConcept)
+	case length < MinConceptLength:
+		return apperrors.NewFieldError("concept", apperrors.FieldConceptTooShort,
+			fmt.Sprintf("concept must be at least %d characters", MinConceptLength)).
+			WithCode(apperrors.CodeInvalidConcept).
+			WithParams(map[string]string{"min": strconv.Itoa(MinConceptLength)})
+	case length > maxLength:
+		return apperrors.NewFieldError("concept", apperrors.FieldConceptTooLong,
+			fmt.Sprintf("concept must be at most %d characters (got %d)", maxLength, length)).
+			WithCode(apperrors.CodeInvalidConcept).
+			WithParams(map[string]string{"max": strconv.Itoa(maxLength), "length": strconv.Itoa(length)})
+	}
+	return nil
+}
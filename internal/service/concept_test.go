@@ -0,0 +1,126 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/service"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+)
+
+func TestNormalizeConcept(t *testing.T) {
+	tests := []struct {
+		name    string
+		concept string
+		want    string
+	}{
+		{name: "trimmed", concept: "  \n\tเพลงรักฤดูฝน \r\n", want: "เพลงรักฤดูฝน"},
+		{name: "newlines", concept: "ท่อนแรก\r\nท่อนสอง\rท่อนสาม", want: "ท่อนแรก\nท่อนสอง\nท่อนสาม"},
+		{name: "tabs", concept: "city\tpop", want: "city pop"},
+		{name: "null bytes and control characters", concept: "เพลง\x00ลูก\x07ทุ่ง\x1b[31m", want: "เพลงลูกทุ่ง[31m"},
+		{name: "invalid UTF-8", concept: "เพลง\xe0\xb8ป๊อป", want: "เพลงป๊อป"},
+		{name: "zero-width and bidi marks", concept: "เพลง​ป๊อป‮", want: "เพลงป๊อป"},
+		{name: "zero-width joiner kept", concept: "ครอบครัว 👨‍👩‍👧", want: "ครอบครัว 👨‍👩‍👧"},
+		// Tone marks and vowels above and below are combining characters, not controls
+		{name: "Thai combining marks kept", concept: "น้ำค้างที่ปลายหญ้า", want: "น้ำค้างที่ปลายหญ้า"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := service.NormalizeConcept(tt.concept); got != tt.want {
+				t.Errorf("NormalizeConcept(%q) = %q, want %q", tt.concept, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateConceptCountsThaiCharacters checks that length limits count Thai
+// characters, each three bytes in UTF-8, rather than bytes.
+func TestValidateConceptCountsThaiCharacters(t *testing.T) {
+	tests := []struct {
+		name       string
+		concept    string
+		maxLength  int
+		wantField  string // Empty when the concept is valid
+		wantLength string // The length reported with FieldConceptTooLong
+	}{
+		{name: "empty", concept: "", wantField: apperrors.FieldConceptRequired},
+		// 12 bytes but 4 characters
+		{name: "four Thai characters", concept: "เพลง", wantField: apperrors.FieldConceptTooShort},
+		{name: "five Thai characters", concept: "เพลงป", maxLength: 5},
+		{name: "seven Thai characters over a limit of five", concept: "น้ำค้าง", maxLength: 5,
+			wantField: apperrors.FieldConceptTooLong, wantLength: "7"},
+		{name: "default limit in Thai", concept: strings.Repeat("ฝ", service.DefaultMaxConceptLength)},
+		{name: "over the default limit in Thai", concept: strings.Repeat("ฝ", service.DefaultMaxConceptLength+1),
+			wantField: apperrors.FieldConceptTooLong, wantLength: "2001"},
+		{name: "configured limit in Thai", concept: strings.Repeat("ฝน", 50), maxLength: 100},
+		{name: "over the configured limit in Thai", concept: strings.Repeat("ฝน", 50) + "!", maxLength: 100,
+			wantField: apperrors.FieldConceptTooLong, wantLength: "101"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.ValidateConcept(tt.concept, tt.maxLength)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("ValidateConcept() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var appErr *apperrors.AppError
+			if !errors.As(err, &appErr) {
+				t.Fatalf("ValidateConcept() error = %v, want an AppError", err)
+			}
+			if appErr.ErrorCode != apperrors.CodeInvalidConcept || appErr.DetailCodes["concept"] != tt.wantField {
+				t.Errorf("ValidateConcept() = code %s detail codes %v, want %s on concept", appErr.ErrorCode, appErr.DetailCodes, tt.wantField)
+			}
+			if tt.wantLength != "" && (appErr.Params["length"] != tt.wantLength || !strings.Contains(appErr.Details["concept"], "got "+tt.wantLength)) {
+				t.Errorf("reported length = %q in %q, want %s characters", appErr.Params["length"], appErr.Details["concept"], tt.wantLength)
+			}
+		})
+	}
+}
+
+// createdJobRepo records the jobs JobService.Create stores.
+type createdJobRepo struct {
+	repository.JobRepository
+	created []*models.Job
+}
+
+func (r *createdJobRepo) Create(ctx context.Context, job *models.Job) error {
+	r.created = append(r.created, job)
+	return nil
+}
+
+// TestCreateJobNormalizesConcept checks that JobService.Create applies the
+// concept rules itself, for callers that bypass the HTTP handlers.
+func TestCreateJobNormalizesConcept(t *testing.T) {
+	repo := &createdJobRepo{}
+	jobService := service.NewJobService(repo, nil, nil, nil, 20, zap.NewNop())
+	user := &models.User{ID: uuid.New()}
+
+	// 15 characters after normalization, 45 bytes
+	job, err := jobService.Create(context.Background(), user, models.CreateJobInput{Concept: " \x00น้ำค้าง\r\nยามเช้า\t"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if want := "น้ำค้าง\nยามเช้า"; job.Concept != want || len(repo.created) != 1 {
+		t.Errorf("stored concept = %q, want %q", job.Concept, want)
+	}
+
+	_, err = jobService.Create(context.Background(), user, models.CreateJobInput{Concept: "น้ำค้างยามเช้าที่ปลายหญ้า"})
+	if code := apperrors.GetErrorCode(err); code != apperrors.CodeInvalidConcept {
+		t.Errorf("Create() of a concept over the limit error = %v, want code %s", err, apperrors.CodeInvalidConcept)
+	}
+	if len(repo.created) != 1 {
+		t.Errorf("%d jobs stored, want the concept over the limit rejected", len(repo.created))
+	}
+}
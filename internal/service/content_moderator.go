@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+)
+
+// Concept moderation modes.
+const (
+	ModerationOff     = "off"     // Concepts are not checked
+	ModerationLogOnly = "log"     // Flagged concepts are logged but accepted
+	ModerationEnforce = "enforce" // Flagged concepts are rejected
+)
+
+// Moderation categories reported when a concept is flagged.
+const (
+	ModerationCategoryHate              = "hate"
+	ModerationCategorySexualMinors      = "sexual_minors"
+	ModerationCategoryCopyrightedLyrics = "copyrighted_lyrics"
+)
+
+// moderationRule flags a concept when all of its patterns match.
+type moderationRule struct {
+	category string
+	patterns []*regexp.Regexp
+}
+
+// moderationRules is the local denylist. Rules are deliberately narrow: the goal is
+// to stop requests Suno will reject anyway before any credits are spent, not to
+// replace the provider's own filtering.
+var moderationRules = []moderationRule{
+	{
+		category: ModerationCategoryHate,
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`\b(kill|exterminate|eradicate|gas|lynch)\s+(all\s+)?(the\s+)?(jews|muslims|christians|blacks|whites|asians|gays|immigrants|refugees)\b`),
+		},
+	},
+	{
+		category: ModerationCategoryHate,
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`\b(heil hitler|white power|sieg heil|ethnic cleansing|race war|14 words)\b`),
+		},
+	},
+	{
+		category: ModerationCategorySexualMinors,
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`\b(child|children|kid|kids|minor|minors|underage|preteen|schoolgirl|schoolboy|loli|toddler)\b`),
+			regexp.MustCompile(`\b(sex|sexual|sexy|nude|naked|porn|erotic|explicit|seduce|seductive)\b`),
+		},
+	},
+	{
+		category: ModerationCategoryCopyrightedLyrics,
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`\b((exact|full|original|same|real|actual|official)\s+lyrics|lyrics\s+(of|from)\s+\S+\s+by|word\s+for\s+word|cover\s+(of|version))\b`),
+		},
+	},
+}
+
+// ContentModerator checks job concepts before the pipeline spends provider credits.
+type ContentModerator interface {
+	CheckConcept(ctx context.Context, concept string) error
+}
+
+// contentModerator implements ContentModerator with a local denylist.
+type contentModerator struct {
+	mode   string
+	logger *zap.Logger
+}
+
+// NewContentModerator creates a new ContentModerator instance.
+// Unknown modes behave like ModerationOff.
+func NewContentModerator(mode string, logger *zap.Logger) ContentModerator {
+	return &contentModerator{
+		mode:   mode,
+		logger: logger.Named("moderation"),
+	}
+}
+
+// CheckConcept returns a 400 error with CodeContentRejected when the concept is
+// flagged and the moderator enforces; otherwise it returns nil.
+func (m *contentModerator) CheckConcept(ctx context.Context, concept string) error {
+	if m.mode != ModerationLogOnly && m.mode != ModerationEnforce {
+		return nil
+	}
+
+	category, flagged := moderateText(concept)
+	if !flagged {
+		return nil
+	}
+
+	m.logger.Warn("concept flagged by moderation",
+		zap.String("category", category),
+		zap.String("mode", m.mode),
+		zap.Int("concept_length", utf8.RuneCountInString(concept)),
+	)
+
+	if m.mode != ModerationEnforce {
+		return nil
+	}
+
+	return apperrors.NewBadRequest("concept contains content that is not allowed").
+		WithCode(apperrors.CodeContentRejected).
+		WithDetails(map[string]string{"category": category})
+}
+
+// moderateText returns the category of the first rule matching text.
+func moderateText(text string) (string, bool) {
+	lower := strings.ToLower(text)
+	for _, rule := range moderationRules {
+		matched := true
+		for _, pattern := range rule.patterns {
+			if !pattern.MatchString(lower) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rule.category, true
+		}
+	}
+	return "", false
+}
@@ -5,59 +5,95 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
 // ErrInvalidCiphertext is returned when decryption fails due to invalid ciphertext.
 var ErrInvalidCiphertext = errors.New("invalid ciphertext")
 
+// keyIDSeparator separates the key ID prefix from the base64 ciphertext.
+// It is not part of the standard base64 alphabet, so unprefixed ciphertexts
+// written before key rotation was supported are still recognised.
+const keyIDSeparator = ":"
+
 // CryptoService defines the interface for encryption/decryption operations.
 type CryptoService interface {
 	Encrypt(plaintext string) (string, error)
 	Decrypt(ciphertext string) (string, error)
+	// NeedsReencrypt returns true if ciphertext was not produced with the primary key.
+	NeedsReencrypt(ciphertext string) bool
+}
+
+// encryptionKey is an AES-256 key and the ID stored in front of its ciphertexts.
+type encryptionKey struct {
+	id  string
+	key []byte
 }
 
 // cryptoService implements CryptoService using AES-256-GCM.
+// Encrypt always uses the primary key; legacy keys are only used to decrypt.
 type cryptoService struct {
-	key []byte
+	primary encryptionKey
+	keys    []encryptionKey // primary first, then legacy keys in configured order
 }
 
 // NewCryptoService creates a new CryptoService instance.
-// The key should be a base64-encoded 32-byte key for AES-256.
-func NewCryptoService(base64Key string) (CryptoService, error) {
+// Every key should be a base64-encoded 32-byte key for AES-256. legacyKeys are
+// previous primary keys that are still accepted for decryption during rotation.
+func NewCryptoService(base64Key string, legacyKeys ...string) (CryptoService, error) {
 	if base64Key == "" {
 		return nil, errors.New("encryption key is required")
 	}
 
+	primary, err := parseEncryptionKey(base64Key)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &cryptoService{primary: primary, keys: []encryptionKey{primary}}
+	for i, legacy := range legacyKeys {
+		key, err := parseEncryptionKey(legacy)
+		if err != nil {
+			return nil, fmt.Errorf("legacy key %d: %w", i+1, err)
+		}
+		s.keys = append(s.keys, key)
+	}
+
+	return s, nil
+}
+
+// parseEncryptionKey decodes a base64 AES-256 key and derives its key ID.
+func parseEncryptionKey(base64Key string) (encryptionKey, error) {
 	key, err := base64.StdEncoding.DecodeString(base64Key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode encryption key: %w", err)
+		return encryptionKey{}, fmt.Errorf("failed to decode encryption key: %w", err)
 	}
 
 	if len(key) != 32 {
-		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+		return encryptionKey{}, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
 	}
 
-	return &cryptoService{key: key}, nil
+	// The ID is a short fingerprint so it identifies the key without revealing it
+	sum := sha256.Sum256(key)
+	return encryptionKey{id: "k" + hex.EncodeToString(sum[:4]), key: key}, nil
 }
 
-// Encrypt encrypts the plaintext using AES-256-GCM and returns a base64-encoded ciphertext.
+// Encrypt encrypts the plaintext using AES-256-GCM with the primary key and
+// returns the key ID followed by the base64-encoded ciphertext.
 func (s *cryptoService) Encrypt(plaintext string) (string, error) {
 	if plaintext == "" {
 		return "", nil
 	}
 
-	block, err := aes.NewCipher(s.key)
+	gcm, err := newGCM(s.primary.key)
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return "", err
 	}
 
 	// Generate random nonce
@@ -69,30 +105,87 @@ func (s *cryptoService) Encrypt(plaintext string) (string, error) {
 	// Encrypt and prepend nonce to ciphertext
 	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
 
-	// Return base64-encoded ciphertext
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return s.primary.id + keyIDSeparator + base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
-// Decrypt decrypts the base64-encoded ciphertext and returns the plaintext.
+// Decrypt decrypts a ciphertext produced by Encrypt and returns the plaintext.
+// Ciphertexts without a key ID are tried against the primary key, then each legacy key.
 func (s *cryptoService) Decrypt(ciphertext string) (string, error) {
 	if ciphertext == "" {
 		return "", nil
 	}
 
+	keyID, encoded, prefixed := strings.Cut(ciphertext, keyIDSeparator)
+	if !prefixed {
+		encoded = ciphertext
+	}
+
 	// Decode base64
-	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	data, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
 		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
 	}
 
-	block, err := aes.NewCipher(s.key)
+	if prefixed {
+		key, ok := s.keyByID(keyID)
+		if !ok {
+			return "", ErrInvalidCiphertext
+		}
+		return decryptWithKey(key.key, data)
+	}
+
+	for _, key := range s.keys {
+		plaintext, err := decryptWithKey(key.key, data)
+		if err == nil {
+			return plaintext, nil
+		}
+		if !errors.Is(err, ErrInvalidCiphertext) {
+			return "", err
+		}
+	}
+
+	return "", ErrInvalidCiphertext
+}
+
+// NeedsReencrypt returns true if ciphertext was not produced with the primary key.
+func (s *cryptoService) NeedsReencrypt(ciphertext string) bool {
+	if ciphertext == "" {
+		return false
+	}
+	keyID, _, prefixed := strings.Cut(ciphertext, keyIDSeparator)
+	return !prefixed || keyID != s.primary.id
+}
+
+// keyByID returns the configured key with the given ID.
+func (s *cryptoService) keyByID(id string) (encryptionKey, bool) {
+	for _, key := range s.keys {
+		if key.id == id {
+			return key, true
+		}
+	}
+	return encryptionKey{}, false
+}
+
+// newGCM creates an AES-256-GCM cipher for key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
 
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return gcm, nil
+}
+
+// decryptWithKey decrypts nonce-prefixed AES-256-GCM data with key.
+func decryptWithKey(key, data []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
 	}
 
 	// Extract nonce from the beginning of the ciphertext
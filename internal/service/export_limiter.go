@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// exportSlotTTL expires a user's export counter if a server dies before
+// releasing its slots; every acquire extends it.
+const exportSlotTTL = time.Hour
+
+// ExportLimiter caps how many job exports a user can download at once.
+type ExportLimiter interface {
+	// Acquire takes one of the user's export slots; ok is false when all are in use.
+	Acquire(ctx context.Context, userID uuid.UUID) (ok bool, err error)
+	// Release frees a slot taken by Acquire.
+	Release(ctx context.Context, userID uuid.UUID) error
+}
+
+// redisExportLimiter implements ExportLimiter as a counting semaphore in Redis.
+type redisExportLimiter struct {
+	client    *redis.Client
+	keyPrefix string
+	max       int
+}
+
+// NewRedisExportLimiter creates an ExportLimiter allowing max concurrent exports per user.
+func NewRedisExportLimiter(client *redis.Client, keyPrefix string, max int) ExportLimiter {
+	return &redisExportLimiter{
+		client:    client,
+		keyPrefix: keyPrefix,
+		max:       max,
+	}
+}
+
+// Acquire increments the user's counter, undoing it when the limit is exceeded.
+func (l *redisExportLimiter) Acquire(ctx context.Context, userID uuid.UUID) (bool, error) {
+	key := l.key(userID)
+
+	pipe := l.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, exportSlotTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to acquire export slot: %w", err)
+	}
+
+	if incr.Val() > int64(l.max) {
+		if err := l.client.Decr(ctx, key).Err(); err != nil {
+			return false, fmt.Errorf("failed to undo export slot: %w", err)
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// Release decrements the user's counter.
+func (l *redisExportLimiter) Release(ctx context.Context, userID uuid.UUID) error {
+	if err := l.client.Decr(ctx, l.key(userID)).Err(); err != nil {
+		return fmt.Errorf("failed to release export slot: %w", err)
+	}
+	return nil
+}
+
+// key returns the Redis key counting the user's exports in progress.
+func (l *redisExportLimiter) key(userID uuid.UUID) string {
+	return fmt.Sprintf("%s:exports:%s", l.keyPrefix, userID)
+}
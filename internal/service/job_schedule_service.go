@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// JobScheduleService defines the interface for job schedule business logic.
+// Every method is scoped to the requesting user.
+type JobScheduleService interface {
+	Create(ctx context.Context, userID uuid.UUID, input models.JobScheduleInput) (*models.JobSchedule, error)
+	Get(ctx context.Context, userID uuid.UUID, scheduleID uuid.UUID) (*models.JobSchedule, error)
+	List(ctx context.Context, userID uuid.UUID) ([]*models.JobSchedule, error)
+	Update(ctx context.Context, userID uuid.UUID, scheduleID uuid.UUID, input models.JobScheduleInput) (*models.JobSchedule, error)
+	Delete(ctx context.Context, userID uuid.UUID, scheduleID uuid.UUID) error
+}
+
+// jobScheduleService implements JobScheduleService.
+type jobScheduleService struct {
+	scheduleRepo    repository.JobScheduleRepository
+	templateService JobTemplateService
+	logger          *zap.Logger
+}
+
+// NewJobScheduleService creates a new JobScheduleService instance.
+func NewJobScheduleService(scheduleRepo repository.JobScheduleRepository, templateService JobTemplateService, logger *zap.Logger) JobScheduleService {
+	return &jobScheduleService{
+		scheduleRepo:    scheduleRepo,
+		templateService: templateService,
+		logger:          logger,
+	}
+}
+
+// Create saves a new schedule, enforcing the per-user cap. The input must already
+// be validated; a referenced template must belong to the user.
+func (s *jobScheduleService) Create(ctx context.Context, userID uuid.UUID, input models.JobScheduleInput) (*models.JobSchedule, error) {
+	schedule := &models.JobSchedule{UserID: userID}
+	if err := s.applyInput(ctx, userID, schedule, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.scheduleRepo.Create(ctx, schedule, models.MaxJobSchedulesPerUser); err != nil {
+		if errors.Is(err, repository.ErrJobScheduleLimitReached) {
+			return nil, apperrors.NewConflict(fmt.Sprintf("you can keep at most %d schedules", models.MaxJobSchedulesPerUser)).
+				WithCode(apperrors.CodeScheduleLimitReached)
+		}
+		s.logger.Error("failed to create job schedule",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("job schedule created",
+		zap.String("schedule_id", schedule.ID.String()),
+		zap.String("user_id", userID.String()),
+		zap.Time("next_run_at", schedule.NextRunAt),
+	)
+
+	return schedule, nil
+}
+
+// Get retrieves a schedule and verifies ownership.
+func (s *jobScheduleService) Get(ctx context.Context, userID uuid.UUID, scheduleID uuid.UUID) (*models.JobSchedule, error) {
+	schedule, err := s.scheduleRepo.GetByID(ctx, scheduleID)
+	if err != nil {
+		if errors.Is(err, repository.ErrJobScheduleNotFound) {
+			return nil, apperrors.NewNotFound("schedule not found").WithCode(apperrors.CodeScheduleNotFound)
+		}
+		s.logger.Error("failed to get job schedule",
+			zap.Error(err),
+			zap.String("schedule_id", scheduleID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	// Verify ownership
+	if schedule.UserID != userID {
+		s.logger.Warn("unauthorized job schedule access attempt",
+			zap.String("schedule_id", scheduleID.String()),
+			zap.String("owner_id", schedule.UserID.String()),
+			zap.String("requester_id", userID.String()),
+		)
+		return nil, apperrors.NewForbidden("you do not have access to this schedule").WithCode(apperrors.CodeScheduleAccessDenied)
+	}
+
+	return schedule, nil
+}
+
+// List returns all of the user's schedules.
+func (s *jobScheduleService) List(ctx context.Context, userID uuid.UUID) ([]*models.JobSchedule, error) {
+	schedules, err := s.scheduleRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to list job schedules",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+	return schedules, nil
+}
+
+// Update replaces a schedule the user owns and recomputes its next run.
+func (s *jobScheduleService) Update(ctx context.Context, userID uuid.UUID, scheduleID uuid.UUID, input models.JobScheduleInput) (*models.JobSchedule, error) {
+	schedule, err := s.Get(ctx, userID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyInput(ctx, userID, schedule, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.scheduleRepo.Update(ctx, schedule); err != nil {
+		if errors.Is(err, repository.ErrJobScheduleNotFound) {
+			return nil, apperrors.NewNotFound("schedule not found").WithCode(apperrors.CodeScheduleNotFound)
+		}
+		s.logger.Error("failed to update job schedule",
+			zap.Error(err),
+			zap.String("schedule_id", scheduleID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	return schedule, nil
+}
+
+// Delete removes a schedule the user owns.
+func (s *jobScheduleService) Delete(ctx context.Context, userID uuid.UUID, scheduleID uuid.UUID) error {
+	if _, err := s.Get(ctx, userID, scheduleID); err != nil {
+		return err
+	}
+
+	if err := s.scheduleRepo.Delete(ctx, scheduleID); err != nil {
+		if errors.Is(err, repository.ErrJobScheduleNotFound) {
+			return apperrors.NewNotFound("schedule not found").WithCode(apperrors.CodeScheduleNotFound)
+		}
+		s.logger.Error("failed to delete job schedule",
+			zap.Error(err),
+			zap.String("schedule_id", scheduleID.String()),
+		)
+		return apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("job schedule deleted",
+		zap.String("schedule_id", scheduleID.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	return nil
+}
+
+// applyInput copies the editable fields of input onto schedule and computes the next run.
+func (s *jobScheduleService) applyInput(ctx context.Context, userID uuid.UUID, schedule *models.JobSchedule, input models.JobScheduleInput) error {
+	if input.TemplateID != nil {
+		if _, err := s.templateService.Get(ctx, userID, *input.TemplateID); err != nil {
+			return err
+		}
+	}
+
+	schedule.Concept = input.Concept
+	schedule.TemplateID = input.TemplateID
+	schedule.Frequency = input.Frequency
+	schedule.TimeOfDay = input.TimeOfDay
+	schedule.Weekday = nil
+	if input.Frequency == models.ScheduleWeekly {
+		schedule.Weekday = input.Weekday
+	}
+	schedule.Timezone = input.Timezone
+	schedule.Enabled = input.Enabled == nil || *input.Enabled
+
+	next, err := schedule.NextRun(time.Now())
+	if err != nil {
+		return apperrors.NewBadRequest(err.Error())
+	}
+	schedule.NextRunAt = next
+
+	return nil
+}
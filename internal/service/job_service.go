@@ -3,7 +3,13 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -17,52 +23,101 @@ import (
 
 // JobService defines the interface for job business logic.
 type JobService interface {
-	Create(ctx context.Context, userID uuid.UUID, input models.CreateJobInput, defaultModel string) (*models.Job, error)
+	Create(ctx context.Context, user *models.User, input models.CreateJobInput) (*models.Job, error)
+	CreateBatch(ctx context.Context, user *models.User, inputs []models.CreateJobInput) ([]*models.Job, error)
 	GetByID(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*models.Job, error)
-	List(ctx context.Context, userID uuid.UUID, page, perPage int) ([]*models.Job, *response.Meta, error)
+	GetVisible(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*models.Job, error)
+	List(ctx context.Context, userID uuid.UUID, filter models.JobFilter, page, perPage int) ([]*models.JobListItem, *response.Meta, error)
 	Cancel(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) error
+	Delete(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) error
+	Share(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*models.Job, error)
+	Unshare(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) error
+	SetUserImage(ctx context.Context, userID uuid.UUID, jobID uuid.UUID, imageKey string, imageURL string) (*models.Job, error)
+	GetShared(ctx context.Context, token string) (*models.Job, error)
 	UpdateStatus(ctx context.Context, jobID uuid.UUID, status string) error
 	UpdateSongPrompt(ctx context.Context, jobID uuid.UUID, prompt *models.SongPrompt) error
 	UpdateGeneratedSongs(ctx context.Context, jobID uuid.UUID, taskID string, songs []models.GeneratedSong) error
+	RecordEarlySongs(ctx context.Context, jobID uuid.UUID, taskID string, songs []models.GeneratedSong) error
 	UpdateSelectedSong(ctx context.Context, jobID uuid.UUID, songID string, audioURL string) error
 	UpdateImagePrompt(ctx context.Context, jobID uuid.UUID, prompt *models.ImagePrompt) error
 	UpdateImageURL(ctx context.Context, jobID uuid.UUID, taskID string, imageURL string) error
+	UpdateImageCandidate(ctx context.Context, jobID uuid.UUID, taskID string, imageURL string, candidateStatus string) ([]models.GeneratedImage, error)
+	RestartImageGeneration(ctx context.Context, job *models.Job) error
 	UpdateVideoURL(ctx context.Context, jobID uuid.UUID, videoURL string) error
 	MarkFailed(ctx context.Context, jobID uuid.UUID, errorMessage string) error
+	MarkFailure(ctx context.Context, jobID uuid.UUID, failure models.JobFailure) error
+	Retry(ctx context.Context, userID uuid.UUID, jobID uuid.UUID, keySources *ProviderKeySources) (*models.Job, string, error)
 	MarkCompleted(ctx context.Context, jobID uuid.UUID) error
 	UpdateYouTubeResult(ctx context.Context, jobID uuid.UUID, youtubeURL, youtubeVideoID, youtubeError *string) error
+	EstimatedDuration(ctx context.Context) time.Duration
+	UpdateTags(ctx context.Context, userID uuid.UUID, jobID uuid.UUID, tags []string) (*models.Job, error)
+	GetByIDIncludingDeleted(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*models.Job, error)
+	Restore(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*models.Job, error)
+}
+
+// Job duration estimate settings. The estimate averages recently completed jobs
+// and is cached because it is computed on every job creation.
+const (
+	durationEstimateWindow   = 7 * 24 * time.Hour
+	durationEstimateSample   = 100
+	durationEstimateCacheTTL = 5 * time.Minute
+)
+
+// JobNotifier is told when a job reaches a final status so the owner's webhooks
+// can be notified. Implemented by the worker package.
+type JobNotifier interface {
+	JobFinished(ctx context.Context, jobID uuid.UUID)
 }
 
 // jobService implements JobService.
 type jobService struct {
-	jobRepo repository.JobRepository
-	logger  *zap.Logger
+	jobRepo   repository.JobRepository
+	orgRepo   repository.OrganizationRepository
+	spendRepo repository.UserSpendRepository
+	notifier  JobNotifier
+	logger    *zap.Logger
+
+	maxConceptLength int
+
+	estimateMu     sync.Mutex
+	estimate       time.Duration
+	estimateExpiry time.Time
 }
 
-// NewJobService creates a new JobService instance.
-func NewJobService(jobRepo repository.JobRepository, logger *zap.Logger) JobService {
+// NewJobService creates a new JobService instance. notifier may be nil.
+// Concepts longer than maxConceptLength characters are rejected, and spendRepo
+// provides the token usage checked against users' OpenRouter token budgets.
+func NewJobService(jobRepo repository.JobRepository, orgRepo repository.OrganizationRepository, spendRepo repository.UserSpendRepository, notifier JobNotifier, maxConceptLength int, logger *zap.Logger) JobService {
 	return &jobService{
-		jobRepo: jobRepo,
-		logger:  logger,
+		jobRepo:          jobRepo,
+		orgRepo:          orgRepo,
+		spendRepo:        spendRepo,
+		notifier:         notifier,
+		logger:           logger,
+		maxConceptLength: maxConceptLength,
 	}
 }
 
-// Create creates a new job with pending status.
-func (s *jobService) Create(ctx context.Context, userID uuid.UUID, input models.CreateJobInput, defaultModel string) (*models.Job, error) {
-	// Determine which model to use
-	model := defaultModel
-	if input.Model != nil && *input.Model != "" {
-		model = *input.Model
+// Create creates a new job with pending status for user. Settings the input leaves
+// unset come from the user's job defaults.
+func (s *jobService) Create(ctx context.Context, user *models.User, input models.CreateJobInput) (*models.Job, error) {
+	userID := user.ID
+	// Normalized here too so that schedules and other non-HTTP callers get the same rules
+	input.Concept = NormalizeConcept(input.Concept)
+	if err := ValidateConcept(input.Concept, s.maxConceptLength); err != nil {
+		return nil, err
 	}
-
-	job := &models.Job{
-		ID:       uuid.New(),
-		UserID:   userID,
-		Status:   models.StatusPending,
-		Concept:  input.Concept,
-		LLMModel: model,
+	tags, err := NormalizeTags(input.Tags)
+	if err != nil {
+		return nil, err
+	}
+	input.Tags = tags
+	if err := s.checkTokenBudget(ctx, user); err != nil {
+		return nil, err
 	}
 
+	job := newPendingJob(user, input)
+
 	if err := s.jobRepo.Create(ctx, job); err != nil {
 		s.logger.Error("failed to create job",
 			zap.Error(err),
@@ -74,18 +129,170 @@ func (s *jobService) Create(ctx context.Context, userID uuid.UUID, input models.
 	s.logger.Info("job created",
 		zap.String("job_id", job.ID.String()),
 		zap.String("user_id", userID.String()),
-		zap.String("model", model),
+		zap.String("model", job.LLMModel),
 	)
 
 	return job, nil
 }
 
-// GetByID retrieves a job by ID and verifies ownership.
+// checkTokenBudget refuses new jobs once the user's LLM calls this month used up
+// their OpenRouter token budget. The error details carry the budget, the tokens
+// used and when the budget resets (the next calendar month, UTC).
+func (s *jobService) checkTokenBudget(ctx context.Context, user *models.User) error {
+	if user.OpenRouterMonthlyTokenLimit == nil || s.spendRepo == nil {
+		return nil
+	}
+
+	now := time.Now()
+	summaries, err := s.spendRepo.SummarizeSince(ctx, []uuid.UUID{user.ID}, models.SpendPeriodStart(now))
+	if err != nil {
+		s.logger.Error("failed to summarize user spend",
+			zap.Error(err),
+			zap.String("user_id", user.ID.String()),
+		)
+		return apperrors.NewInternalError(err)
+	}
+
+	limit := *user.OpenRouterMonthlyTokenLimit
+	used := summaries[user.ID].OpenRouterTokens
+	if used < limit {
+		return nil
+	}
+
+	resetsAt := models.SpendPeriodEnd(now).Format(time.RFC3339)
+	details := map[string]string{
+		"limit":     strconv.FormatInt(limit, 10),
+		"used":      strconv.FormatInt(used, 10),
+		"resets_at": resetsAt,
+	}
+	return apperrors.NewTooManyRequests(fmt.Sprintf(
+		"your monthly OpenRouter token budget of %d tokens is used up (%d used); it resets at %s",
+		limit, used, resetsAt,
+	)).WithCode(apperrors.CodeTokenBudgetExceeded).WithDetails(details).WithParams(details)
+}
+
+// CreateBatch creates one pending job per input for user in a single transaction.
+func (s *jobService) CreateBatch(ctx context.Context, user *models.User, inputs []models.CreateJobInput) ([]*models.Job, error) {
+	userID := user.ID
+	jobs := make([]*models.Job, 0, len(inputs))
+	for _, input := range inputs {
+		input.Concept = NormalizeConcept(input.Concept)
+		if err := ValidateConcept(input.Concept, s.maxConceptLength); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, newPendingJob(user, input))
+	}
+	if err := s.checkTokenBudget(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if err := s.jobRepo.CreateBatch(ctx, jobs); err != nil {
+		s.logger.Error("failed to create job batch",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+			zap.Int("count", len(jobs)),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("job batch created",
+		zap.String("user_id", userID.String()),
+		zap.Int("count", len(jobs)),
+	)
+
+	return jobs, nil
+}
+
+// newPendingJob builds a pending job from creation input, falling back to the
+// user's job defaults and default model for settings the input leaves unset.
+func newPendingJob(user *models.User, input models.CreateJobInput) *models.Job {
+	input = user.JobDefaults.Apply(input)
+
+	model := user.OpenRouterModel
+	if input.Model != nil && *input.Model != "" {
+		model = *input.Model
+	}
+
+	job := &models.Job{
+		ID:              uuid.New(),
+		UserID:          user.ID,
+		Status:          models.StatusPending,
+		Concept:         input.Concept,
+		LLMModel:        model,
+		ImageCandidates: input.ImageCandidates,
+		AspectRatio:     input.AspectRatio,
+		PromptOverrides: input.PromptOverrides,
+		VideoOptions:    input.VideoOptions,
+		SunoModel:       input.SunoModel,
+		Tags:            input.Tags,
+		OrgID:           input.OrgID,
+		KeepAllTracks:   input.KeepAllTracks,
+
+		MaxDurationSeconds: input.MaxDurationSeconds,
+
+		OpenRouterKeySource: input.OpenRouterKeySource,
+		KIEKeySource:        input.KIEKeySource,
+	}
+	if job.OpenRouterKeySource == "" {
+		job.OpenRouterKeySource = models.KeySourceUser
+	}
+	if job.KIEKeySource == "" {
+		job.KIEKeySource = models.KeySourceUser
+	}
+	job.CallbackMode = models.CallbackModeAuto
+	if input.CallbackMode != nil {
+		job.CallbackMode = *input.CallbackMode
+	}
+	if input.ImageURL != nil && *input.ImageURL != "" {
+		source := models.ImageSourceUser
+		job.ImageSource = &source
+		job.SourceImageURL = input.ImageURL
+	} else if input.ImageSource != nil {
+		source := *input.ImageSource
+		job.ImageSource = &source
+	}
+	return job
+}
+
+// GetByID retrieves a job by ID and verifies the user may change it: its creator
+// or an owner of the organization it belongs to. Deleted jobs are not found.
 func (s *jobService) GetByID(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*models.Job, error) {
+	job, err := s.GetByIDIncludingDeleted(ctx, userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.IsDeleted() {
+		return nil, apperrors.NewNotFound("job not found").WithCode(apperrors.CodeJobNotFound)
+	}
+	return job, nil
+}
+
+// GetVisible retrieves a job the user may read: like GetByID, but any member of
+// the job's organization has read-only access.
+func (s *jobService) GetVisible(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*models.Job, error) {
+	job, err := s.getJob(ctx, userID, jobID, false)
+	if err != nil {
+		return nil, err
+	}
+	if job.IsDeleted() {
+		return nil, apperrors.NewNotFound("job not found").WithCode(apperrors.CodeJobNotFound)
+	}
+	return job, nil
+}
+
+// GetByIDIncludingDeleted retrieves a job like GetByID, but also returns it when
+// the user deleted it.
+func (s *jobService) GetByIDIncludingDeleted(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*models.Job, error) {
+	return s.getJob(ctx, userID, jobID, true)
+}
+
+// getJob loads a job and checks the user's access to it; write limits organization
+// access to owners.
+func (s *jobService) getJob(ctx context.Context, userID uuid.UUID, jobID uuid.UUID, write bool) (*models.Job, error) {
 	job, err := s.jobRepo.GetByID(ctx, jobID)
 	if err != nil {
 		if errors.Is(err, repository.ErrJobNotFound) {
-			return nil, apperrors.NewNotFound("job not found")
+			return nil, apperrors.NewNotFound("job not found").WithCode(apperrors.CodeJobNotFound)
 		}
 		s.logger.Error("failed to get job",
 			zap.Error(err),
@@ -95,20 +302,76 @@ func (s *jobService) GetByID(ctx context.Context, userID uuid.UUID, jobID uuid.U
 	}
 
 	// Verify ownership
-	if job.UserID != userID {
+	allowed, err := s.canAccess(ctx, userID, job, write)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
 		s.logger.Warn("unauthorized job access attempt",
 			zap.String("job_id", jobID.String()),
 			zap.String("owner_id", job.UserID.String()),
 			zap.String("requester_id", userID.String()),
 		)
-		return nil, apperrors.NewForbidden("you do not have access to this job")
+		return nil, apperrors.NewForbidden("you do not have access to this job").WithCode(apperrors.CodeJobAccessDenied)
 	}
 
 	return job, nil
 }
 
-// List retrieves paginated jobs for a user.
-func (s *jobService) List(ctx context.Context, userID uuid.UUID, page, perPage int) ([]*models.Job, *response.Meta, error) {
+// canAccess reports whether the user may read (or, with write, change) the job.
+// Besides its creator, members of the job's organization can read it and owners
+// can also change it.
+func (s *jobService) canAccess(ctx context.Context, userID uuid.UUID, job *models.Job, write bool) (bool, error) {
+	if job.UserID == userID {
+		return true, nil
+	}
+	if job.OrgID == nil {
+		return false, nil
+	}
+
+	membership, err := s.membership(ctx, userID)
+	if err != nil || membership == nil {
+		return false, err
+	}
+	if membership.OrgID != *job.OrgID {
+		return false, nil
+	}
+	return !write || membership.IsOwner(), nil
+}
+
+// membership returns the user's organization membership, or nil for personal accounts.
+func (s *jobService) membership(ctx context.Context, userID uuid.UUID) (*models.OrganizationMember, error) {
+	membership, err := s.orgRepo.GetMembership(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotOrgMember) {
+			return nil, nil
+		}
+		s.logger.Error("failed to get organization membership",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+	return membership, nil
+}
+
+// List retrieves paginated jobs for a user, or for their organization when
+// filter.Scope is models.JobScopeOrg. Only organization owners see its deleted jobs.
+func (s *jobService) List(ctx context.Context, userID uuid.UUID, filter models.JobFilter, page, perPage int) ([]*models.JobListItem, *response.Meta, error) {
+	if filter.Scope == models.JobScopeOrg {
+		membership, err := s.membership(ctx, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if membership == nil {
+			return nil, nil, apperrors.NewNotFound("you do not belong to an organization").WithCode(apperrors.CodeOrgNotFound)
+		}
+		filter.OrgID = &membership.OrgID
+		if !membership.IsOwner() {
+			filter.IncludeDeleted = false
+		}
+	}
+
 	// Set defaults
 	if page < 1 {
 		page = 1
@@ -120,7 +383,7 @@ func (s *jobService) List(ctx context.Context, userID uuid.UUID, page, perPage i
 		perPage = 100
 	}
 
-	jobs, total, err := s.jobRepo.GetByUserID(ctx, userID, page, perPage)
+	jobs, total, err := s.jobRepo.ListItemsByUserID(ctx, userID, filter, page, perPage)
 	if err != nil {
 		s.logger.Error("failed to list jobs",
 			zap.Error(err),
@@ -144,17 +407,17 @@ func (s *jobService) Cancel(ctx context.Context, userID uuid.UUID, jobID uuid.UU
 
 	// Check if job can be cancelled
 	if job.IsTerminal() {
-		return apperrors.NewBadRequest("cannot cancel a job that is already completed or failed")
+		return apperrors.NewBadRequest("cannot cancel a job that is already completed or failed").WithCode(apperrors.CodeJobNotCancellable)
 	}
 
 	// Update status to failed with cancellation message
-	if err := s.jobRepo.UpdateWithError(ctx, jobID, "job cancelled by user"); err != nil {
+	if err := s.jobRepo.Cancel(ctx, jobID, "job cancelled by user"); err != nil {
 		if errors.Is(err, repository.ErrJobNotFound) {
-			return apperrors.NewNotFound("job not found")
+			return apperrors.NewNotFound("job not found").WithCode(apperrors.CodeJobNotFound)
 		}
 		if errors.Is(err, repository.ErrStatusConflict) {
 			// Job reached terminal state between our check and the update
-			return apperrors.NewBadRequest("cannot cancel a job that is already completed or failed")
+			return apperrors.NewBadRequest("cannot cancel a job that is already completed or failed").WithCode(apperrors.CodeJobNotCancellable)
 		}
 		s.logger.Error("failed to cancel job",
 			zap.Error(err),
@@ -171,11 +434,264 @@ func (s *jobService) Cancel(ctx context.Context, userID uuid.UUID, jobID uuid.UU
 	return nil
 }
 
+// Retry restarts a failed job from the step returned by Job.RetryStep on the key
+// sources checked by ProviderKeyService.RequireKeys, and returns the reset job and
+// that step; the caller enqueues the step's task.
+func (s *jobService) Retry(ctx context.Context, userID uuid.UUID, jobID uuid.UUID, keySources *ProviderKeySources) (*models.Job, string, error) {
+	job, err := s.GetByID(ctx, userID, jobID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !job.CanRetry() {
+		return nil, "", apperrors.NewBadRequest("only failed jobs that were not cancelled can be retried").WithCode(apperrors.CodeJobNotRetryable)
+	}
+
+	step := job.RetryStep()
+	if err := s.jobRepo.ResetForRetry(ctx, jobID, step, keySources.OpenRouter, keySources.KIE); err != nil {
+		if errors.Is(err, repository.ErrStatusConflict) {
+			// Retried concurrently, or cancelled after our check
+			return nil, "", apperrors.NewConflict("job status conflict: concurrent modification detected").WithCode(apperrors.CodeJobStatusConflict)
+		}
+		s.logger.Error("failed to reset job for retry",
+			zap.Error(err),
+			zap.String("job_id", jobID.String()),
+		)
+		return nil, "", apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("job retried",
+		zap.String("job_id", jobID.String()),
+		zap.String("user_id", userID.String()),
+		zap.String("retry_from", step),
+	)
+
+	job, err = s.jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		return nil, "", apperrors.NewInternalError(err)
+	}
+	return job, step, nil
+}
+
+// Delete soft-deletes a terminal job owned by the user. It can be restored for
+// models.JobRestoreWindow, after which the DeletedJobPurger removes it and its assets.
+// Running jobs must be cancelled first so no task handler writes to a deleted job.
+func (s *jobService) Delete(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) error {
+	// First verify ownership
+	job, err := s.GetByID(ctx, userID, jobID)
+	if err != nil {
+		return err
+	}
+
+	if !job.IsTerminal() {
+		return apperrors.NewConflict("cannot delete a running job; cancel it first").WithCode(apperrors.CodeJobRunning)
+	}
+
+	if err := s.jobRepo.SoftDelete(ctx, jobID); err != nil {
+		if errors.Is(err, repository.ErrJobNotFound) {
+			return apperrors.NewNotFound("job not found").WithCode(apperrors.CodeJobNotFound)
+		}
+		s.logger.Error("failed to delete job",
+			zap.Error(err),
+			zap.String("job_id", jobID.String()),
+		)
+		return apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("job deleted",
+		zap.String("job_id", jobID.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	return nil
+}
+
+// Restore undoes the deletion of a job deleted less than models.JobRestoreWindow ago.
+func (s *jobService) Restore(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*models.Job, error) {
+	job, err := s.GetByIDIncludingDeleted(ctx, userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !job.IsDeleted() {
+		return nil, apperrors.NewConflict("job is not deleted").WithCode(apperrors.CodeJobNotDeleted)
+	}
+	deletedAfter := time.Now().Add(-models.JobRestoreWindow)
+	if job.DeletedAt.Before(deletedAfter) {
+		return nil, apperrors.NewConflict("the restore window of this job has passed").WithCode(apperrors.CodeJobRestoreExpired)
+	}
+
+	if err := s.jobRepo.Restore(ctx, jobID, deletedAfter); err != nil {
+		if errors.Is(err, repository.ErrStatusConflict) {
+			// Restored or purged concurrently
+			return nil, apperrors.NewConflict("job is not deleted").WithCode(apperrors.CodeJobNotDeleted)
+		}
+		s.logger.Error("failed to restore job",
+			zap.Error(err),
+			zap.String("job_id", jobID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("job restored",
+		zap.String("job_id", jobID.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	return s.GetByID(ctx, userID, jobID)
+}
+
+// shareTokenBytes is the amount of randomness in a share token.
+const shareTokenBytes = 24
+
+// Share enables the job's public share link and returns the job with its token.
+// Sharing an already shared job keeps the existing token.
+func (s *jobService) Share(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*models.Job, error) {
+	job, err := s.GetByID(ctx, userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Status != models.StatusCompleted || !job.HasVideo() {
+		return nil, apperrors.NewConflict("only completed jobs can be shared").WithCode(apperrors.CodeJobNotCompleted)
+	}
+	if job.ShareToken != nil {
+		return job, nil
+	}
+
+	// Random rather than derived from the job ID so links cannot be guessed
+	buf := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, apperrors.NewInternalError(fmt.Errorf("failed to generate share token: %w", err))
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	if err := s.jobRepo.SetShareToken(ctx, jobID, &token); err != nil {
+		s.logger.Error("failed to share job",
+			zap.Error(err),
+			zap.String("job_id", jobID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("job shared",
+		zap.String("job_id", jobID.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	return s.GetByID(ctx, userID, jobID)
+}
+
+// UpdateTags replaces the tags of one of the user's jobs.
+func (s *jobService) UpdateTags(ctx context.Context, userID uuid.UUID, jobID uuid.UUID, tags []string) (*models.Job, error) {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.GetByID(ctx, userID, jobID); err != nil {
+		return nil, err
+	}
+
+	if err := s.jobRepo.UpdateTags(ctx, jobID, tags); err != nil {
+		if errors.Is(err, repository.ErrJobNotFound) {
+			return nil, apperrors.NewNotFound("job not found").WithCode(apperrors.CodeJobNotFound)
+		}
+		s.logger.Error("failed to update job tags",
+			zap.Error(err),
+			zap.String("job_id", jobID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	return s.GetByID(ctx, userID, jobID)
+}
+
+// SetUserImage records an image the user uploaded to R2 as the job's cover, so the
+// pipeline skips image generation. Only allowed before image generation starts.
+func (s *jobService) SetUserImage(ctx context.Context, userID uuid.UUID, jobID uuid.UUID, imageKey string, imageURL string) (*models.Job, error) {
+	job, err := s.GetByID(ctx, userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !job.AcceptsUserImage() {
+		return nil, apperrors.NewConflict("the image can only be set before image generation starts").WithCode(apperrors.CodeJobStatusConflict)
+	}
+
+	if err := s.jobRepo.SetUserImageAtomic(ctx, jobID, models.ImageUploadStatuses, imageKey, imageURL, models.ImageSourceUser); err != nil {
+		if errors.Is(err, repository.ErrStatusConflict) {
+			return nil, apperrors.NewConflict("the image can only be set before image generation starts").WithCode(apperrors.CodeJobStatusConflict)
+		}
+		s.logger.Error("failed to set user image",
+			zap.Error(err),
+			zap.String("job_id", jobID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("user image set",
+		zap.String("job_id", jobID.String()),
+		zap.String("user_id", userID.String()),
+		zap.String("image_key", imageKey),
+	)
+
+	return s.GetByID(ctx, userID, jobID)
+}
+
+// Unshare revokes the job's public share link. Revoking an unshared job is a no-op.
+func (s *jobService) Unshare(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) error {
+	job, err := s.GetByID(ctx, userID, jobID)
+	if err != nil {
+		return err
+	}
+	if job.ShareToken == nil {
+		return nil
+	}
+
+	if err := s.jobRepo.SetShareToken(ctx, jobID, nil); err != nil {
+		s.logger.Error("failed to unshare job",
+			zap.Error(err),
+			zap.String("job_id", jobID.String()),
+		)
+		return apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("job share revoked",
+		zap.String("job_id", jobID.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	return nil
+}
+
+// GetShared returns the completed job behind a share token. Unknown, revoked and
+// no longer completed jobs all look the same to the caller: not found.
+func (s *jobService) GetShared(ctx context.Context, token string) (*models.Job, error) {
+	job, err := s.jobRepo.GetByShareToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, repository.ErrJobNotFound) {
+			return nil, apperrors.NewNotFound("shared job not found").WithCode(apperrors.CodeJobNotFound)
+		}
+		s.logger.Error("failed to get shared job", zap.Error(err))
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	if job.Status != models.StatusCompleted || !job.HasVideo() {
+		return nil, apperrors.NewNotFound("shared job not found").WithCode(apperrors.CodeJobNotFound)
+	}
+
+	return job, nil
+}
+
 // UpdateStatus updates the status of a job.
 func (s *jobService) UpdateStatus(ctx context.Context, jobID uuid.UUID, status string) error {
 	if err := s.jobRepo.UpdateStatus(ctx, jobID, status); err != nil {
 		if errors.Is(err, repository.ErrJobNotFound) {
-			return apperrors.NewNotFound("job not found")
+			return apperrors.NewNotFound("job not found").WithCode(apperrors.CodeJobNotFound)
+		}
+		if errors.Is(err, repository.ErrStatusConflict) || errors.Is(err, repository.ErrInvalidStatusTransition) {
+			return apperrors.NewConflict(fmt.Sprintf("job cannot move to status %s", status)).WithCode(apperrors.CodeJobStatusConflict)
 		}
 		s.logger.Error("failed to update job status",
 			zap.Error(err),
@@ -197,7 +713,7 @@ func (s *jobService) UpdateStatus(ctx context.Context, jobID uuid.UUID, status s
 func (s *jobService) UpdateSongPrompt(ctx context.Context, jobID uuid.UUID, prompt *models.SongPrompt) error {
 	if err := s.jobRepo.UpdateSongPromptAtomic(ctx, jobID, models.StatusAnalyzing, prompt, models.StatusGeneratingMusic); err != nil {
 		if errors.Is(err, repository.ErrStatusConflict) {
-			return apperrors.NewConflict("job status conflict: concurrent modification detected")
+			return apperrors.NewConflict("job status conflict: concurrent modification detected").WithCode(apperrors.CodeJobStatusConflict)
 		}
 		s.logger.Error("failed to update song prompt",
 			zap.Error(err),
@@ -215,9 +731,11 @@ func (s *jobService) UpdateSongPrompt(ctx context.Context, jobID uuid.UUID, prom
 
 // UpdateGeneratedSongs updates the generated songs and task ID for a job.
 func (s *jobService) UpdateGeneratedSongs(ctx context.Context, jobID uuid.UUID, taskID string, songs []models.GeneratedSong) error {
-	if err := s.jobRepo.UpdateGeneratedSongsAtomic(ctx, jobID, models.StatusGeneratingMusic, taskID, songs, models.StatusSelectingSong); err != nil {
+	// Callbacks and polled results end the music stage when the songs arrive
+	extras := models.JobWriteExtras{CompletedStages: []string{models.StageMusic}}
+	if err := s.jobRepo.UpdateGeneratedSongsAtomic(ctx, jobID, models.StatusGeneratingMusic, taskID, songs, models.StatusSelectingSong, extras); err != nil {
 		if errors.Is(err, repository.ErrStatusConflict) {
-			return apperrors.NewConflict("job status conflict: concurrent modification detected")
+			return apperrors.NewConflict("job status conflict: concurrent modification detected").WithCode(apperrors.CodeJobStatusConflict)
 		}
 		s.logger.Error("failed to update generated songs",
 			zap.Error(err),
@@ -235,11 +753,34 @@ func (s *jobService) UpdateGeneratedSongs(ctx context.Context, jobID uuid.UUID,
 	return nil
 }
 
+// RecordEarlySongs stores the songs of a Suno "first" callback without leaving
+// generating_music, so the "complete" callback can still add the remaining tracks.
+func (s *jobService) RecordEarlySongs(ctx context.Context, jobID uuid.UUID, taskID string, songs []models.GeneratedSong) error {
+	if err := s.jobRepo.UpdateGeneratedSongsAtomic(ctx, jobID, models.StatusGeneratingMusic, taskID, songs, models.StatusGeneratingMusic, models.JobWriteExtras{}); err != nil {
+		if errors.Is(err, repository.ErrStatusConflict) {
+			return apperrors.NewConflict("job status conflict: concurrent modification detected").WithCode(apperrors.CodeJobStatusConflict)
+		}
+		s.logger.Error("failed to record early songs",
+			zap.Error(err),
+			zap.String("job_id", jobID.String()),
+		)
+		return apperrors.NewInternalError(err)
+	}
+
+	s.logger.Debug("early songs recorded",
+		zap.String("job_id", jobID.String()),
+		zap.String("task_id", taskID),
+		zap.Int("song_count", len(songs)),
+	)
+
+	return nil
+}
+
 // UpdateSelectedSong updates the selected song ID and audio URL.
 func (s *jobService) UpdateSelectedSong(ctx context.Context, jobID uuid.UUID, songID string, audioURL string) error {
-	if err := s.jobRepo.UpdateSelectedSongAtomic(ctx, jobID, models.StatusSelectingSong, songID, audioURL, models.StatusGeneratingImage); err != nil {
+	if err := s.jobRepo.UpdateSelectedSongAtomic(ctx, jobID, models.StatusSelectingSong, songID, audioURL, models.StatusGeneratingImage, models.JobWriteExtras{}); err != nil {
 		if errors.Is(err, repository.ErrStatusConflict) {
-			return apperrors.NewConflict("job status conflict: concurrent modification detected")
+			return apperrors.NewConflict("job status conflict: concurrent modification detected").WithCode(apperrors.CodeJobStatusConflict)
 		}
 		s.logger.Error("failed to update selected song",
 			zap.Error(err),
@@ -258,9 +799,9 @@ func (s *jobService) UpdateSelectedSong(ctx context.Context, jobID uuid.UUID, so
 
 // UpdateImagePrompt updates the image prompt for a job.
 func (s *jobService) UpdateImagePrompt(ctx context.Context, jobID uuid.UUID, prompt *models.ImagePrompt) error {
-	if err := s.jobRepo.UpdateImagePromptAtomic(ctx, jobID, models.StatusGeneratingImage, prompt); err != nil {
+	if err := s.jobRepo.UpdateImagePromptAtomic(ctx, jobID, models.StatusGeneratingImage, prompt, models.JobWriteExtras{}); err != nil {
 		if errors.Is(err, repository.ErrStatusConflict) {
-			return apperrors.NewConflict("job status conflict: concurrent modification detected")
+			return apperrors.NewConflict("job status conflict: concurrent modification detected").WithCode(apperrors.CodeJobStatusConflict)
 		}
 		s.logger.Error("failed to update image prompt",
 			zap.Error(err),
@@ -278,9 +819,9 @@ func (s *jobService) UpdateImagePrompt(ctx context.Context, jobID uuid.UUID, pro
 
 // UpdateImageURL updates the image URL and task ID for a job.
 func (s *jobService) UpdateImageURL(ctx context.Context, jobID uuid.UUID, taskID string, imageURL string) error {
-	if err := s.jobRepo.UpdateImageURLAtomic(ctx, jobID, models.StatusGeneratingImage, taskID, imageURL, models.StatusProcessingVideo); err != nil {
+	if err := s.jobRepo.UpdateImageURLAtomic(ctx, jobID, models.StatusGeneratingImage, taskID, imageURL, models.StatusProcessingVideo, models.JobWriteExtras{}); err != nil {
 		if errors.Is(err, repository.ErrStatusConflict) {
-			return apperrors.NewConflict("job status conflict: concurrent modification detected")
+			return apperrors.NewConflict("job status conflict: concurrent modification detected").WithCode(apperrors.CodeJobStatusConflict)
 		}
 		s.logger.Error("failed to update image URL",
 			zap.Error(err),
@@ -297,11 +838,35 @@ func (s *jobService) UpdateImageURL(ctx context.Context, jobID uuid.UUID, taskID
 	return nil
 }
 
+// UpdateImageCandidate records the result of one image candidate task and returns all candidates.
+// Returns a conflict error if the job left generating_image or the candidate was already recorded.
+func (s *jobService) UpdateImageCandidate(ctx context.Context, jobID uuid.UUID, taskID string, imageURL string, candidateStatus string) ([]models.GeneratedImage, error) {
+	images, err := s.jobRepo.UpdateImageCandidateAtomic(ctx, jobID, models.StatusGeneratingImage, taskID, imageURL, candidateStatus)
+	if err != nil {
+		if errors.Is(err, repository.ErrStatusConflict) {
+			return nil, apperrors.NewConflict("image candidate already processed or job status changed").WithCode(apperrors.CodeJobStatusConflict)
+		}
+		s.logger.Error("failed to update image candidate",
+			zap.Error(err),
+			zap.String("job_id", jobID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	s.logger.Debug("image candidate updated",
+		zap.String("job_id", jobID.String()),
+		zap.String("task_id", taskID),
+		zap.String("candidate_status", candidateStatus),
+	)
+
+	return images, nil
+}
+
 // UpdateVideoURL updates the video URL for a job.
 func (s *jobService) UpdateVideoURL(ctx context.Context, jobID uuid.UUID, videoURL string) error {
 	if err := s.jobRepo.UpdateVideoURLAtomic(ctx, jobID, models.StatusProcessingVideo, videoURL, models.StatusUploading); err != nil {
 		if errors.Is(err, repository.ErrStatusConflict) {
-			return apperrors.NewConflict("job status conflict: concurrent modification detected")
+			return apperrors.NewConflict("job status conflict: concurrent modification detected").WithCode(apperrors.CodeJobStatusConflict)
 		}
 		s.logger.Error("failed to update video URL",
 			zap.Error(err),
@@ -317,12 +882,42 @@ func (s *jobService) UpdateVideoURL(ctx context.Context, jobID uuid.UUID, videoU
 	return nil
 }
 
+// RestartImageGeneration clears the job's image prompt and tasks after the image
+// model refused the prompt under its content policy, counting a sanitize attempt.
+// The caller enqueues the generate image task. Returns a conflict error if the job
+// left generating_image or was already restarted for this refusal.
+func (s *jobService) RestartImageGeneration(ctx context.Context, job *models.Job) error {
+	if err := s.jobRepo.RestartImageGenerationAtomic(ctx, job.ID, job.ImageSanitizeAttempts); err != nil {
+		if errors.Is(err, repository.ErrStatusConflict) {
+			return apperrors.NewConflict("image generation already restarted or job status changed").WithCode(apperrors.CodeJobStatusConflict)
+		}
+		s.logger.Error("failed to restart image generation",
+			zap.Error(err),
+			zap.String("job_id", job.ID.String()),
+		)
+		return apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("image generation restarted with a sanitized prompt",
+		zap.String("job_id", job.ID.String()),
+		zap.Int("attempt", job.ImageSanitizeAttempts+1),
+	)
+	return nil
+}
+
 // MarkFailed marks a job as failed with an error message.
 // If the job is already in a terminal state (completed/failed), this is a no-op.
 func (s *jobService) MarkFailed(ctx context.Context, jobID uuid.UUID, errorMessage string) error {
-	if err := s.jobRepo.UpdateWithError(ctx, jobID, errorMessage); err != nil {
+	return s.MarkFailure(ctx, jobID, models.JobFailure{Message: errorMessage})
+}
+
+// MarkFailure marks a job as failed with a classified failure.
+// If the job is already in a terminal state (completed/failed), this is a no-op.
+func (s *jobService) MarkFailure(ctx context.Context, jobID uuid.UUID, failure models.JobFailure) error {
+	errorMessage := failure.Message
+	if err := s.jobRepo.UpdateWithFailure(ctx, jobID, failure); err != nil {
 		if errors.Is(err, repository.ErrJobNotFound) {
-			return apperrors.NewNotFound("job not found")
+			return apperrors.NewNotFound("job not found").WithCode(apperrors.CodeJobNotFound)
 		}
 		if errors.Is(err, repository.ErrStatusConflict) {
 			// Job is already in a terminal state — this is expected when
@@ -343,7 +938,9 @@ func (s *jobService) MarkFailed(ctx context.Context, jobID uuid.UUID, errorMessa
 	s.logger.Info("job marked as failed",
 		zap.String("job_id", jobID.String()),
 		zap.String("error_message", errorMessage),
+		zap.String("error_code", failure.Code),
 	)
+	s.notifyFinished(ctx, jobID)
 
 	return nil
 }
@@ -353,7 +950,7 @@ func (s *jobService) MarkFailed(ctx context.Context, jobID uuid.UUID, errorMessa
 func (s *jobService) MarkCompleted(ctx context.Context, jobID uuid.UUID) error {
 	if err := s.jobRepo.UpdateStatus(ctx, jobID, models.StatusCompleted); err != nil {
 		if errors.Is(err, repository.ErrJobNotFound) {
-			return apperrors.NewNotFound("job not found")
+			return apperrors.NewNotFound("job not found").WithCode(apperrors.CodeJobNotFound)
 		}
 		if errors.Is(err, repository.ErrStatusConflict) {
 			// Job is already in a terminal state — idempotent
@@ -372,6 +969,7 @@ func (s *jobService) MarkCompleted(ctx context.Context, jobID uuid.UUID) error {
 	s.logger.Info("job completed",
 		zap.String("job_id", jobID.String()),
 	)
+	s.notifyFinished(ctx, jobID)
 
 	return nil
 }
@@ -380,7 +978,7 @@ func (s *jobService) MarkCompleted(ctx context.Context, jobID uuid.UUID) error {
 func (s *jobService) UpdateYouTubeResult(ctx context.Context, jobID uuid.UUID, youtubeURL, youtubeVideoID, youtubeError *string) error {
 	if err := s.jobRepo.UpdateYouTubeResult(ctx, jobID, youtubeURL, youtubeVideoID, youtubeError, models.StatusCompleted); err != nil {
 		if errors.Is(err, repository.ErrJobNotFound) {
-			return apperrors.NewNotFound("job not found")
+			return apperrors.NewNotFound("job not found").WithCode(apperrors.CodeJobNotFound)
 		}
 		s.logger.Error("failed to update YouTube result",
 			zap.Error(err),
@@ -392,6 +990,39 @@ func (s *jobService) UpdateYouTubeResult(ctx context.Context, jobID uuid.UUID, y
 	s.logger.Info("YouTube result updated",
 		zap.String("job_id", jobID.String()),
 	)
+	s.notifyFinished(ctx, jobID)
 
 	return nil
 }
+
+// notifyFinished tells the notifier, if any, that jobID reached a final status.
+func (s *jobService) notifyFinished(ctx context.Context, jobID uuid.UUID) {
+	if s.notifier != nil {
+		s.notifier.JobFinished(ctx, jobID)
+	}
+}
+
+// EstimatedDuration returns how long a new job is expected to take, based on the
+// average of recently completed jobs. It returns 0 when there is no recent data.
+// A failed lookup keeps serving the previous estimate.
+func (s *jobService) EstimatedDuration(ctx context.Context) time.Duration {
+	s.estimateMu.Lock()
+	defer s.estimateMu.Unlock()
+
+	now := time.Now()
+	if now.Before(s.estimateExpiry) {
+		return s.estimate
+	}
+
+	estimate, err := s.jobRepo.AverageCompletionDuration(ctx, now.Add(-durationEstimateWindow), durationEstimateSample)
+	if err != nil {
+		s.logger.Warn("failed to compute job duration estimate", zap.Error(err))
+		// Retry on the next call after a short pause instead of hitting the DB every request
+		s.estimateExpiry = now.Add(time.Minute)
+		return s.estimate
+	}
+
+	s.estimate = estimate
+	s.estimateExpiry = now.Add(durationEstimateCacheTTL)
+	return s.estimate
+}
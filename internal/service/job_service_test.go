@@ -0,0 +1,147 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/service"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+)
+
+// deletedJobRepo holds one job and applies Restore the way the SQL does: only
+// when the job was deleted after the cutoff.
+type deletedJobRepo struct {
+	repository.JobRepository
+	job      models.Job
+	restores int
+}
+
+func (r *deletedJobRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	if id != r.job.ID {
+		return nil, repository.ErrJobNotFound
+	}
+	job := r.job
+	return &job, nil
+}
+
+func (r *deletedJobRepo) Restore(ctx context.Context, id uuid.UUID, deletedAfter time.Time) error {
+	r.restores++
+	if r.job.DeletedAt == nil || !r.job.DeletedAt.After(deletedAfter) {
+		return repository.ErrStatusConflict
+	}
+	r.job.DeletedAt = nil
+	return nil
+}
+
+// TestRestoreJob checks that a deleted job can be restored within the restore
+// window and not after it.
+func TestRestoreJob(t *testing.T) {
+	ago := func(d time.Duration) *time.Time {
+		at := time.Now().Add(-d)
+		return &at
+	}
+
+	tests := []struct {
+		name      string
+		deletedAt *time.Time
+		wantCode  string // Empty when the job is restored
+	}{
+		{name: "deleted an hour ago", deletedAt: ago(time.Hour)},
+		{name: "deleted a day before the window ends", deletedAt: ago(models.JobRestoreWindow - 24*time.Hour)},
+		{name: "deleted a day past the window", deletedAt: ago(models.JobRestoreWindow + 24*time.Hour), wantCode: apperrors.CodeJobRestoreExpired},
+		{name: "not deleted", wantCode: apperrors.CodeJobNotDeleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			repo := &deletedJobRepo{job: models.Job{
+				ID:        uuid.New(),
+				UserID:    userID,
+				Status:    models.StatusCompleted,
+				DeletedAt: tt.deletedAt,
+			}}
+			jobService := service.NewJobService(repo, nil, nil, nil, 0, zap.NewNop())
+
+			job, err := jobService.Restore(context.Background(), userID, repo.job.ID)
+			if tt.wantCode != "" {
+				if code := apperrors.GetErrorCode(err); code != tt.wantCode {
+					t.Fatalf("Restore() error = %v (code %s), want code %s", err, code, tt.wantCode)
+				}
+				if repo.job.DeletedAt != tt.deletedAt {
+					t.Error("a job that could not be restored lost its deletion time")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Restore() error = %v", err)
+			}
+			if job.IsDeleted() || repo.job.DeletedAt != nil {
+				t.Errorf("restored job still deleted: returned %v, stored %v", job.DeletedAt, repo.job.DeletedAt)
+			}
+		})
+	}
+}
+
+// TestRestoreJobOfAnotherUser checks that only the job's owner can restore it.
+func TestRestoreJobOfAnotherUser(t *testing.T) {
+	deletedAt := time.Now().Add(-time.Hour)
+	repo := &deletedJobRepo{job: models.Job{ID: uuid.New(), UserID: uuid.New(), Status: models.StatusFailed, DeletedAt: &deletedAt}}
+	jobService := service.NewJobService(repo, nil, nil, nil, 0, zap.NewNop())
+
+	_, err := jobService.Restore(context.Background(), uuid.New(), repo.job.ID)
+	if code := apperrors.GetErrorCode(err); code != apperrors.CodeJobAccessDenied {
+		t.Fatalf("Restore() error = %v (code %s), want code %s", err, code, apperrors.CodeJobAccessDenied)
+	}
+	if repo.restores != 0 {
+		t.Error("another user's job was restored")
+	}
+}
+
+// TestCreateJobAppliesUserDefaults checks that JobService.Create fills the
+// settings a request leaves unset from the user's job defaults, and that a
+// setting in the request wins.
+func TestCreateJobAppliesUserDefaults(t *testing.T) {
+	candidates, ratio, fadeOut := 2, "1:1", 5
+	user := &models.User{
+		ID:              uuid.New(),
+		OpenRouterModel: "google/gemini-2.5-flash",
+		JobDefaults: models.JobDefaults{
+			ImageCandidates: &candidates,
+			AspectRatio:     &ratio,
+			VideoOptions:    &models.VideoOptions{FadeOutSeconds: &fadeOut},
+		},
+	}
+	requestRatio := "16:9"
+	input := models.CreateJobInput{Concept: "เพลงรักฤดูฝน", AspectRatio: &requestRatio}
+
+	repo := &createdJobRepo{}
+	jobService := service.NewJobService(repo, nil, nil, nil, 0, zap.NewNop())
+	job, err := jobService.Create(context.Background(), user, input)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if job.ImageCandidates == nil || *job.ImageCandidates != candidates {
+		t.Errorf("image candidates = %v, want the user's %d", job.ImageCandidates, candidates)
+	}
+	if job.AspectRatio == nil || *job.AspectRatio != requestRatio {
+		t.Errorf("aspect ratio = %v, want the request's %s", job.AspectRatio, requestRatio)
+	}
+	if got := job.VideoOptions.FadeOut(); got != 5*time.Second {
+		t.Errorf("fade-out = %v, want the user's 5s", got)
+	}
+	if job.LLMModel != user.OpenRouterModel {
+		t.Errorf("model = %q, want the user's %q", job.LLMModel, user.OpenRouterModel)
+	}
+	// The defaults are copied, not shared with the job
+	if job.VideoOptions == user.JobDefaults.VideoOptions {
+		t.Error("job shares its video options with the user's defaults")
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// JobTemplateService defines the interface for job template business logic.
+// Every method is scoped to the requesting user.
+type JobTemplateService interface {
+	Create(ctx context.Context, userID uuid.UUID, input models.JobTemplateInput) (*models.JobTemplate, error)
+	Get(ctx context.Context, userID uuid.UUID, templateID uuid.UUID) (*models.JobTemplate, error)
+	List(ctx context.Context, userID uuid.UUID) ([]*models.JobTemplate, error)
+	Update(ctx context.Context, userID uuid.UUID, templateID uuid.UUID, input models.JobTemplateInput) (*models.JobTemplate, error)
+	Delete(ctx context.Context, userID uuid.UUID, templateID uuid.UUID) error
+}
+
+// jobTemplateService implements JobTemplateService.
+type jobTemplateService struct {
+	templateRepo repository.JobTemplateRepository
+	logger       *zap.Logger
+}
+
+// NewJobTemplateService creates a new JobTemplateService instance.
+func NewJobTemplateService(templateRepo repository.JobTemplateRepository, logger *zap.Logger) JobTemplateService {
+	return &jobTemplateService{
+		templateRepo: templateRepo,
+		logger:       logger,
+	}
+}
+
+// Create saves a new template, enforcing the per-user cap.
+func (s *jobTemplateService) Create(ctx context.Context, userID uuid.UUID, input models.JobTemplateInput) (*models.JobTemplate, error) {
+	template := &models.JobTemplate{UserID: userID}
+	applyTemplateInput(template, input)
+
+	if err := s.templateRepo.Create(ctx, template, models.MaxJobTemplatesPerUser); err != nil {
+		if errors.Is(err, repository.ErrJobTemplateLimitReached) {
+			return nil, apperrors.NewConflict(fmt.Sprintf("you can keep at most %d templates", models.MaxJobTemplatesPerUser)).
+				WithCode(apperrors.CodeTemplateLimitReached)
+		}
+		s.logger.Error("failed to create job template",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("job template created",
+		zap.String("template_id", template.ID.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	return template, nil
+}
+
+// Get retrieves a template and verifies ownership.
+func (s *jobTemplateService) Get(ctx context.Context, userID uuid.UUID, templateID uuid.UUID) (*models.JobTemplate, error) {
+	template, err := s.templateRepo.GetByID(ctx, templateID)
+	if err != nil {
+		if errors.Is(err, repository.ErrJobTemplateNotFound) {
+			return nil, apperrors.NewNotFound("template not found").WithCode(apperrors.CodeTemplateNotFound)
+		}
+		s.logger.Error("failed to get job template",
+			zap.Error(err),
+			zap.String("template_id", templateID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	// Verify ownership
+	if template.UserID != userID {
+		s.logger.Warn("unauthorized job template access attempt",
+			zap.String("template_id", templateID.String()),
+			zap.String("owner_id", template.UserID.String()),
+			zap.String("requester_id", userID.String()),
+		)
+		return nil, apperrors.NewForbidden("you do not have access to this template").WithCode(apperrors.CodeTemplateAccessDenied)
+	}
+
+	return template, nil
+}
+
+// List returns all of the user's templates.
+func (s *jobTemplateService) List(ctx context.Context, userID uuid.UUID) ([]*models.JobTemplate, error) {
+	templates, err := s.templateRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to list job templates",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+	return templates, nil
+}
+
+// Update replaces the name and settings of a template the user owns.
+func (s *jobTemplateService) Update(ctx context.Context, userID uuid.UUID, templateID uuid.UUID, input models.JobTemplateInput) (*models.JobTemplate, error) {
+	template, err := s.Get(ctx, userID, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	applyTemplateInput(template, input)
+
+	if err := s.templateRepo.Update(ctx, template); err != nil {
+		if errors.Is(err, repository.ErrJobTemplateNotFound) {
+			return nil, apperrors.NewNotFound("template not found").WithCode(apperrors.CodeTemplateNotFound)
+		}
+		s.logger.Error("failed to update job template",
+			zap.Error(err),
+			zap.String("template_id", templateID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	return template, nil
+}
+
+// Delete removes a template the user owns.
+func (s *jobTemplateService) Delete(ctx context.Context, userID uuid.UUID, templateID uuid.UUID) error {
+	if _, err := s.Get(ctx, userID, templateID); err != nil {
+		return err
+	}
+
+	if err := s.templateRepo.Delete(ctx, templateID); err != nil {
+		if errors.Is(err, repository.ErrJobTemplateNotFound) {
+			return apperrors.NewNotFound("template not found").WithCode(apperrors.CodeTemplateNotFound)
+		}
+		s.logger.Error("failed to delete job template",
+			zap.Error(err),
+			zap.String("template_id", templateID.String()),
+		)
+		return apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("job template deleted",
+		zap.String("template_id", templateID.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	return nil
+}
+
+// applyTemplateInput copies the editable fields of input onto template.
+func applyTemplateInput(template *models.JobTemplate, input models.JobTemplateInput) {
+	template.Name = input.Name
+	template.Model = input.Model
+	template.ImageCandidates = input.ImageCandidates
+	template.AspectRatio = input.AspectRatio
+	template.PromptOverrides = input.PromptOverrides
+}
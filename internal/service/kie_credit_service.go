@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/kie"
+	"github.com/jaochai/ugc/internal/models"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+)
+
+// ErrInsufficientKIECredits is returned when a job is created with no KIE credits left.
+var ErrInsufficientKIECredits = apperrors.NewPaymentRequired("your KIE account has no credits left. Please top up before creating a job.").
+	WithCode(apperrors.CodeInsufficientCredits)
+
+// KIECreditsConfig holds settings for KIECreditService.
+type KIECreditsConfig struct {
+	BaseURL      string        // KIE API base URL; empty uses the public API
+	LowThreshold int           // Balances below this are logged as low
+	CacheTTL     time.Duration // How long a balance is cached per user
+}
+
+// KIECredits is a user's KIE credit balance.
+type KIECredits struct {
+	Credits int  `json:"credits"`
+	Low     bool `json:"low"` // Below the configured warning threshold
+}
+
+// KIECreditService reads users' KIE credit balances.
+type KIECreditService interface {
+	// GetCredits returns the user's balance, cached for CacheTTL.
+	GetCredits(ctx context.Context, user *models.User) (*KIECredits, error)
+	// CheckJobCredits rejects job creation when the user has no credits left.
+	// It fails open: when the balance cannot be read, the job is allowed.
+	CheckJobCredits(ctx context.Context, user *models.User) error
+}
+
+// kieCreditService implements KIECreditService.
+type kieCreditService struct {
+	cryptoService CryptoService
+	redisClient   *redis.Client // nil disables caching
+	cfg           KIECreditsConfig
+	logger        *zap.Logger
+}
+
+// NewKIECreditService creates a new KIECreditService instance. redisClient may be nil.
+func NewKIECreditService(cryptoService CryptoService, redisClient *redis.Client, cfg KIECreditsConfig, logger *zap.Logger) KIECreditService {
+	return &kieCreditService{
+		cryptoService: cryptoService,
+		redisClient:   redisClient,
+		cfg:           cfg,
+		logger:        logger,
+	}
+}
+
+// GetCredits returns the user's KIE credit balance.
+func (s *kieCreditService) GetCredits(ctx context.Context, user *models.User) (*KIECredits, error) {
+	if user.KIEAPIKey == nil || *user.KIEAPIKey == "" {
+		return nil, apperrors.NewBadRequest("KIE API key is required. Please configure in Settings.").
+			WithCode(apperrors.CodeMissingKIEKey)
+	}
+	apiKey, err := s.cryptoService.Decrypt(*user.KIEAPIKey)
+	if err != nil || apiKey == "" {
+		s.logger.Warn("failed to decrypt KIE API key", zap.Error(err))
+		return nil, apperrors.NewBadRequest("KIE API key is required. Please configure in Settings.").
+			WithCode(apperrors.CodeMissingKIEKey)
+	}
+
+	cacheKey := s.cacheKey(user, apiKey)
+	if credits, ok := s.cached(ctx, cacheKey); ok {
+		return s.result(credits), nil
+	}
+
+	credits, err := kie.NewAccountClient(apiKey, s.cfg.BaseURL).GetCredits(ctx)
+	if err != nil {
+		var apiErr *kie.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+			return nil, apperrors.NewBadRequest("Invalid KIE API key").WithCode(apperrors.CodeInvalidAPIKey)
+		}
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusPaymentRequired {
+			credits = 0
+		} else {
+			return nil, apperrors.NewBadGateway("failed to get KIE credits").
+				WithCode(apperrors.CodeUpstreamUnavailable).
+				WithError(err)
+		}
+	}
+
+	if s.redisClient != nil {
+		if err := s.redisClient.Set(ctx, cacheKey, credits, s.cfg.CacheTTL).Err(); err != nil {
+			s.logger.Warn("failed to cache KIE credits", zap.Error(err))
+		}
+	}
+
+	return s.result(credits), nil
+}
+
+// CheckJobCredits rejects job creation at zero credits and logs low balances.
+func (s *kieCreditService) CheckJobCredits(ctx context.Context, user *models.User) error {
+	credits, err := s.GetCredits(ctx, user)
+	if err != nil {
+		s.logger.Warn("failed to check KIE credits, allowing job",
+			zap.Error(err),
+			zap.String("user_id", user.ID.String()),
+		)
+		return nil
+	}
+
+	if credits.Credits <= 0 {
+		return ErrInsufficientKIECredits
+	}
+	if credits.Low {
+		s.logger.Warn("user KIE credits are low",
+			zap.String("user_id", user.ID.String()),
+			zap.Int("credits", credits.Credits),
+		)
+	}
+	return nil
+}
+
+// result wraps a balance with its low flag.
+func (s *kieCreditService) result(credits int) *KIECredits {
+	return &KIECredits{
+		Credits: credits,
+		Low:     credits < s.cfg.LowThreshold,
+	}
+}
+
+// cached returns a cached balance, if any.
+func (s *kieCreditService) cached(ctx context.Context, key string) (int, bool) {
+	if s.redisClient == nil {
+		return 0, false
+	}
+	value, err := s.redisClient.Get(ctx, key).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			s.logger.Warn("failed to read cached KIE credits", zap.Error(err))
+		}
+		return 0, false
+	}
+	credits, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return credits, true
+}
+
+// cacheKey includes a hash of the API key so changing the key drops the cached balance.
+func (s *kieCreditService) cacheKey(user *models.User, apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return fmt.Sprintf("ugc:kie-credits:%s:%s", user.ID.String(), hex.EncodeToString(sum[:8]))
+}
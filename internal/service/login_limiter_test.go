@@ -0,0 +1,111 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/jaochai/ugc/internal/service"
+)
+
+func newTestLoginLimiter(t *testing.T) (service.LoginLimiter, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	return service.NewRedisLoginLimiter(client, "test", service.LoginLockoutConfig{
+		MaxFailures: 3,
+		Window:      10 * time.Minute,
+		Duration:    15 * time.Minute,
+	}), mr
+}
+
+func TestLoginLimiter_LocksAfterMaxFailures(t *testing.T) {
+	limiter, _ := newTestLoginLimiter(t)
+	ctx := context.Background()
+
+	for i := 1; i < 3; i++ {
+		lock, err := limiter.RecordFailure(ctx, "user@example.com")
+		if err != nil {
+			t.Fatalf("RecordFailure %d: %v", i, err)
+		}
+		if lock != 0 {
+			t.Fatalf("failure %d locked the address for %v", i, lock)
+		}
+	}
+
+	lock, err := limiter.RecordFailure(ctx, "USER@example.com ")
+	if err != nil {
+		t.Fatalf("RecordFailure: %v", err)
+	}
+	if lock != 15*time.Minute {
+		t.Fatalf("third failure locked for %v, want 15m", lock)
+	}
+
+	remaining, err := limiter.Locked(ctx, "user@example.com")
+	if err != nil {
+		t.Fatalf("Locked: %v", err)
+	}
+	if remaining <= 0 || remaining > 15*time.Minute {
+		t.Fatalf("Locked = %v, want up to 15m", remaining)
+	}
+}
+
+func TestLoginLimiter_FirstFailureStartsWindow(t *testing.T) {
+	limiter, mr := newTestLoginLimiter(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.RecordFailure(ctx, "user@example.com"); err != nil {
+			t.Fatalf("RecordFailure: %v", err)
+		}
+	}
+
+	var counted int
+	for _, key := range mr.Keys() {
+		if ttl := mr.TTL(key); ttl <= 0 || ttl > 10*time.Minute {
+			t.Fatalf("failure counter %s has TTL %v, want the 10m window", key, ttl)
+		}
+		counted++
+	}
+	if counted != 1 {
+		t.Fatalf("got %d keys, want one failure counter", counted)
+	}
+
+	// Failures outside the window are forgotten
+	mr.FastForward(11 * time.Minute)
+	lock, err := limiter.RecordFailure(ctx, "user@example.com")
+	if err != nil {
+		t.Fatalf("RecordFailure: %v", err)
+	}
+	if lock != 0 {
+		t.Fatalf("failure after the window locked the address for %v", lock)
+	}
+}
+
+func TestLoginLimiter_ResetClearsFailures(t *testing.T) {
+	limiter, _ := newTestLoginLimiter(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.RecordFailure(ctx, "user@example.com"); err != nil {
+			t.Fatalf("RecordFailure: %v", err)
+		}
+	}
+	if err := limiter.Reset(ctx, "user@example.com"); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+
+	lock, err := limiter.RecordFailure(ctx, "user@example.com")
+	if err != nil {
+		t.Fatalf("RecordFailure: %v", err)
+	}
+	if lock != 0 {
+		t.Fatalf("failure after Reset locked the address for %v", lock)
+	}
+}
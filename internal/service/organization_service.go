@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/email"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/security"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+)
+
+// OrganizationService defines the interface for organization business logic.
+// Every method is scoped to the requesting user's own organization.
+type OrganizationService interface {
+	Create(ctx context.Context, userID uuid.UUID, input models.CreateOrganizationInput) (*models.OrganizationResponse, error)
+	Get(ctx context.Context, userID uuid.UUID) (*models.OrganizationResponse, error)
+	// Invite records an invitation and emails its accept link to the invitee.
+	Invite(ctx context.Context, userID uuid.UUID, input models.InviteMemberInput) (*models.OrganizationInvitation, error)
+	// AcceptInvitation adds the user to the organization of the invitation with
+	// token. The user must be signed in with the invited email.
+	AcceptInvitation(ctx context.Context, userID uuid.UUID, token string) (*models.OrganizationResponse, error)
+	RevokeInvitation(ctx context.Context, userID, invitationID uuid.UUID) error
+	UpdateAPIKeys(ctx context.Context, userID uuid.UUID, input models.UpdateOrganizationAPIKeysInput) (*models.OrganizationResponse, error)
+}
+
+// invitationTokenBytes is the size of the random invitation token.
+const invitationTokenBytes = 32
+
+// organizationService implements OrganizationService.
+type organizationService struct {
+	orgRepo       repository.OrganizationRepository
+	cryptoService CryptoService
+	mailer        email.Mailer
+	frontendURL   string
+	logger        *zap.Logger
+}
+
+// NewOrganizationService creates a new OrganizationService instance. Invitation
+// links point at frontendURL.
+func NewOrganizationService(orgRepo repository.OrganizationRepository, cryptoService CryptoService, mailer email.Mailer, frontendURL string, logger *zap.Logger) OrganizationService {
+	return &organizationService{
+		orgRepo:       orgRepo,
+		cryptoService: cryptoService,
+		mailer:        mailer,
+		frontendURL:   frontendURL,
+		logger:        logger,
+	}
+}
+
+// Create creates an organization owned by the user.
+func (s *organizationService) Create(ctx context.Context, userID uuid.UUID, input models.CreateOrganizationInput) (*models.OrganizationResponse, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" || utf8.RuneCountInString(name) > models.MaxOrganizationNameLength {
+		return nil, apperrors.NewValidationError(map[string]string{
+			"name": fmt.Sprintf("name is required and must be at most %d characters", models.MaxOrganizationNameLength),
+		})
+	}
+
+	org := &models.Organization{Name: name}
+	if err := s.orgRepo.Create(ctx, org, userID); err != nil {
+		if errors.Is(err, repository.ErrAlreadyOrgMember) {
+			return nil, apperrors.NewConflict("you already belong to an organization").WithCode(apperrors.CodeAlreadyInOrg)
+		}
+		s.logger.Error("failed to create organization",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("organization created",
+		zap.String("org_id", org.ID.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	return s.Get(ctx, userID)
+}
+
+// Get returns the user's organization with its members.
+func (s *organizationService) Get(ctx context.Context, userID uuid.UUID) (*models.OrganizationResponse, error) {
+	membership, err := s.membership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := s.orgRepo.GetByID(ctx, membership.OrgID)
+	if err != nil {
+		return nil, s.internal("failed to get organization", err, membership.OrgID)
+	}
+	members, err := s.orgRepo.ListMembers(ctx, org.ID)
+	if err != nil {
+		return nil, s.internal("failed to list organization members", err, org.ID)
+	}
+
+	resp := &models.OrganizationResponse{
+		ID:               org.ID,
+		Name:             org.Name,
+		Role:             membership.Role,
+		HasOpenRouterKey: org.OpenRouterAPIKey != nil && *org.OpenRouterAPIKey != "",
+		HasKIEKey:        org.KIEAPIKey != nil && *org.KIEAPIKey != "",
+		Members:          members,
+		CreatedAt:        org.CreatedAt,
+	}
+	if membership.IsOwner() {
+		if resp.Invitations, err = s.orgRepo.ListPendingInvitations(ctx, org.ID); err != nil {
+			return nil, s.internal("failed to list organization invitations", err, org.ID)
+		}
+	}
+	return resp, nil
+}
+
+// Invite records a pending invitation for an email address and emails it a
+// single-use accept link. Only owners can invite.
+func (s *organizationService) Invite(ctx context.Context, userID uuid.UUID, input models.InviteMemberInput) (*models.OrganizationInvitation, error) {
+	membership, err := s.ownerMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	email := security.NormalizeEmail(input.Email)
+	if !strings.Contains(email, "@") {
+		return nil, apperrors.NewValidationError(map[string]string{"email": "email must be a valid email address"})
+	}
+	role := input.Role
+	if role == "" {
+		role = models.OrgRoleMember
+	}
+	if !models.IsValidOrgRole(role) {
+		return nil, apperrors.NewValidationError(map[string]string{"role": "role must be owner or member"})
+	}
+
+	buf := make([]byte, invitationTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, s.internal("failed to generate invitation token", err, membership.OrgID)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	// Only a hash of the token is stored; the invitee gets the token itself by email
+	invitation := &models.OrganizationInvitation{
+		OrgID:     membership.OrgID,
+		Email:     email,
+		Role:      role,
+		InvitedBy: &userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(models.InvitationExpiry).UTC(),
+	}
+	if err := s.orgRepo.CreateInvitation(ctx, invitation); err != nil {
+		if errors.Is(err, repository.ErrInvitationExists) {
+			return nil, apperrors.NewConflict("this email already has a pending invitation").WithCode(apperrors.CodeInvitationExists)
+		}
+		return nil, s.internal("failed to create organization invitation", err, membership.OrgID)
+	}
+
+	if err := s.sendInvitation(ctx, invitation, token); err != nil {
+		// Revoke it so the owner can invite the address again
+		if revokeErr := s.orgRepo.RevokeInvitation(ctx, membership.OrgID, invitation.ID); revokeErr != nil {
+			s.logger.Error("failed to revoke unsent organization invitation",
+				zap.Error(revokeErr),
+				zap.String("invitation_id", invitation.ID.String()),
+			)
+		}
+		return nil, s.internal("failed to send organization invitation", err, membership.OrgID)
+	}
+
+	s.logger.Info("organization invitation created",
+		zap.String("org_id", membership.OrgID.String()),
+		zap.String("invitation_id", invitation.ID.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	return invitation, nil
+}
+
+// sendInvitation emails the invitation's accept link.
+func (s *organizationService) sendInvitation(ctx context.Context, invitation *models.OrganizationInvitation, token string) error {
+	org, err := s.orgRepo.GetByID(ctx, invitation.OrgID)
+	if err != nil {
+		return fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	acceptURL := s.frontendURL + "/invitations/accept?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf(
+		`<p>You were invited to join <strong>%s</strong>.</p><p><a href="%s">Accept the invitation</a></p><p>Sign in or register with this email address to accept. The link expires in %d days. If you did not expect this, ignore this email.</p>`,
+		html.EscapeString(org.Name), html.EscapeString(acceptURL), int(models.InvitationExpiry.Hours()/24),
+	)
+	return s.mailer.Send(ctx, invitation.Email, "You were invited to "+org.Name, body)
+}
+
+// AcceptInvitation accepts the invitation with token for the user.
+func (s *organizationService) AcceptInvitation(ctx context.Context, userID uuid.UUID, token string) (*models.OrganizationResponse, error) {
+	if token == "" {
+		return nil, apperrors.NewValidationError(map[string]string{"token": "token is required"})
+	}
+
+	member, err := s.orgRepo.AcceptInvitation(ctx, userID, hashToken(token))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvitationNotFound):
+			return nil, apperrors.NewNotFound("invitation not found or no longer valid").WithCode(apperrors.CodeInvitationNotFound)
+		case errors.Is(err, repository.ErrInvitationExpired):
+			return nil, apperrors.NewBadRequest("invitation has expired").WithCode(apperrors.CodeInvitationExpired)
+		case errors.Is(err, repository.ErrInvitationEmailMismatch):
+			return nil, apperrors.NewForbidden("this invitation was sent to another email address").WithCode(apperrors.CodeInvitationEmailMismatch)
+		case errors.Is(err, repository.ErrAlreadyOrgMember):
+			return nil, apperrors.NewConflict("you already belong to an organization").WithCode(apperrors.CodeAlreadyInOrg)
+		}
+		s.logger.Error("failed to accept organization invitation",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("organization invitation accepted",
+		zap.String("org_id", member.OrgID.String()),
+		zap.String("user_id", userID.String()),
+		zap.String("role", member.Role),
+	)
+
+	return s.Get(ctx, userID)
+}
+
+// RevokeInvitation revokes a pending invitation of the user's organization.
+// Only owners can revoke.
+func (s *organizationService) RevokeInvitation(ctx context.Context, userID, invitationID uuid.UUID) error {
+	membership, err := s.ownerMembership(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.orgRepo.RevokeInvitation(ctx, membership.OrgID, invitationID); err != nil {
+		if errors.Is(err, repository.ErrInvitationNotFound) {
+			return apperrors.NewNotFound("invitation not found").WithCode(apperrors.CodeInvitationNotFound)
+		}
+		return s.internal("failed to revoke organization invitation", err, membership.OrgID)
+	}
+
+	s.logger.Info("organization invitation revoked",
+		zap.String("org_id", membership.OrgID.String()),
+		zap.String("invitation_id", invitationID.String()),
+		zap.String("user_id", userID.String()),
+	)
+	return nil
+}
+
+// UpdateAPIKeys encrypts and stores the organization's shared keys. Only owners
+// can change them.
+func (s *organizationService) UpdateAPIKeys(ctx context.Context, userID uuid.UUID, input models.UpdateOrganizationAPIKeysInput) (*models.OrganizationResponse, error) {
+	membership, err := s.ownerMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := s.orgRepo.GetByID(ctx, membership.OrgID)
+	if err != nil {
+		return nil, s.internal("failed to get organization", err, membership.OrgID)
+	}
+
+	openRouterKey, err := s.encryptKey(input.OpenRouterAPIKey, org.OpenRouterAPIKey)
+	if err != nil {
+		return nil, s.internal("failed to encrypt OpenRouter API key", err, org.ID)
+	}
+	kieKey, err := s.encryptKey(input.KIEAPIKey, org.KIEAPIKey)
+	if err != nil {
+		return nil, s.internal("failed to encrypt KIE API key", err, org.ID)
+	}
+
+	if err := s.orgRepo.UpdateAPIKeys(ctx, org.ID, openRouterKey, kieKey); err != nil {
+		return nil, s.internal("failed to update organization API keys", err, org.ID)
+	}
+
+	s.logger.Info("organization API keys updated",
+		zap.String("org_id", org.ID.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	return s.Get(ctx, userID)
+}
+
+// encryptKey returns the encrypted form of key: current when key is nil, nil
+// when it is empty (removing it).
+func (s *organizationService) encryptKey(key, current *string) (*string, error) {
+	if key == nil {
+		return current, nil
+	}
+	if *key == "" {
+		return nil, nil
+	}
+	encrypted, err := s.cryptoService.Encrypt(*key)
+	if err != nil {
+		return nil, err
+	}
+	return &encrypted, nil
+}
+
+// membership returns the user's membership, as a not found error for users
+// without an organization.
+func (s *organizationService) membership(ctx context.Context, userID uuid.UUID) (*models.OrganizationMember, error) {
+	membership, err := s.orgRepo.GetMembership(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotOrgMember) {
+			return nil, apperrors.NewNotFound("you do not belong to an organization").WithCode(apperrors.CodeOrgNotFound)
+		}
+		s.logger.Error("failed to get organization membership",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+	return membership, nil
+}
+
+// ownerMembership is membership for actions only owners may take.
+func (s *organizationService) ownerMembership(ctx context.Context, userID uuid.UUID) (*models.OrganizationMember, error) {
+	membership, err := s.membership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !membership.IsOwner() {
+		return nil, apperrors.NewForbidden("only organization owners can do this").WithCode(apperrors.CodeOrgOwnerRequired)
+	}
+	return membership, nil
+}
+
+// internal logs err and wraps it as an internal error.
+func (s *organizationService) internal(msg string, err error, orgID uuid.UUID) error {
+	s.logger.Error(msg,
+		zap.Error(err),
+		zap.String("org_id", orgID.String()),
+	)
+	return apperrors.NewInternalError(err)
+}
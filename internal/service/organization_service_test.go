@@ -0,0 +1,250 @@
+package service_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/testutil"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+)
+
+var acceptLinkPattern = regexp.MustCompile(`href="(https://app\.example\.com/invitations/accept\?token=[^"]+)"`)
+
+// orgFixture is an organization with an owner, over in-memory storage.
+type orgFixture struct {
+	service service.OrganizationService
+	repo    *testutil.FakeOrganizationRepository
+	mailer  *testutil.FakeMailer
+	owner   *models.User
+	org     *models.Organization
+}
+
+// newOrgFixture creates an organization owned by a new user. users are known to
+// the repository so they can accept invitations.
+func newOrgFixture(t *testing.T, users ...*models.User) *orgFixture {
+	t.Helper()
+
+	owner := &models.User{ID: uuid.New(), Email: "owner@example.com"}
+	repo := testutil.NewFakeOrganizationRepository(append(users, owner)...)
+	org := &models.Organization{Name: "Studio"}
+	if err := repo.Create(context.Background(), org, owner.ID); err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+
+	mailer := &testutil.FakeMailer{}
+	return &orgFixture{
+		service: service.NewOrganizationService(repo, nil, mailer, "https://app.example.com", zap.NewNop()),
+		repo:    repo,
+		mailer:  mailer,
+		owner:   owner,
+		org:     org,
+	}
+}
+
+// invite invites email and returns the token from the emailed link.
+func (f *orgFixture) invite(t *testing.T, email string) string {
+	t.Helper()
+
+	before := len(f.mailer.Sent())
+	if _, err := f.service.Invite(context.Background(), f.owner.ID, models.InviteMemberInput{Email: email}); err != nil {
+		t.Fatalf("Invite(%s) error = %v", email, err)
+	}
+	sent := f.mailer.Sent()
+	if len(sent) != before+1 {
+		t.Fatalf("Invite sent %d emails, want 1", len(sent)-before)
+	}
+	if !strings.EqualFold(sent[len(sent)-1].To, email) {
+		t.Fatalf("invitation emailed to %q, want %q", sent[len(sent)-1].To, email)
+	}
+
+	match := acceptLinkPattern.FindStringSubmatch(sent[len(sent)-1].HTMLBody)
+	if match == nil {
+		t.Fatalf("invitation email has no accept link: %s", sent[len(sent)-1].HTMLBody)
+	}
+	link, err := url.Parse(match[1])
+	if err != nil {
+		t.Fatalf("invalid accept link %q: %v", match[1], err)
+	}
+	return link.Query().Get("token")
+}
+
+// wantAppError fails unless err is an AppError with status and, unless empty,
+// errorCode.
+func wantAppError(t *testing.T, err error, status int, errorCode string) {
+	t.Helper()
+
+	var appErr *apperrors.AppError
+	if !errors.As(err, &appErr) {
+		t.Fatalf("error = %v, want %d %s", err, status, errorCode)
+	}
+	if appErr.Code != status || (errorCode != "" && appErr.ErrorCode != errorCode) {
+		t.Fatalf("error = %d %s (%v), want %d %s", appErr.Code, appErr.ErrorCode, appErr, status, errorCode)
+	}
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	invitee := &models.User{ID: uuid.New(), Email: "invitee@example.com"}
+	other := &models.User{ID: uuid.New(), Email: "other@example.com"}
+	f := newOrgFixture(t, invitee, other)
+	ctx := context.Background()
+
+	token := f.invite(t, "Invitee@Example.com")
+	if token == "" {
+		t.Fatal("accept link has an empty token")
+	}
+
+	// Only the invited address can use the link
+	_, err := f.service.AcceptInvitation(ctx, other.ID, token)
+	wantAppError(t, err, 403, apperrors.CodeInvitationEmailMismatch)
+	if _, err := f.repo.GetMembership(ctx, other.ID); err == nil {
+		t.Fatal("user with another email joined the organization")
+	}
+
+	org, err := f.service.AcceptInvitation(ctx, invitee.ID, token)
+	if err != nil {
+		t.Fatalf("AcceptInvitation() error = %v", err)
+	}
+	if org.ID != f.org.ID || org.Role != models.OrgRoleMember {
+		t.Fatalf("joined organization %s as %s, want %s as member", org.ID, org.Role, f.org.ID)
+	}
+
+	// The token is single-use
+	_, err = f.service.AcceptInvitation(ctx, invitee.ID, token)
+	wantAppError(t, err, 404, apperrors.CodeInvitationNotFound)
+}
+
+func TestAcceptInvitationRejected(t *testing.T) {
+	invitee := &models.User{ID: uuid.New(), Email: "invitee@example.com"}
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		token      func(t *testing.T, f *orgFixture) string
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "empty token",
+			token:      func(t *testing.T, f *orgFixture) string { return "" },
+			wantStatus: 400,
+			wantCode:   apperrors.CodeValidationFailed,
+		},
+		{
+			name:       "unknown token",
+			token:      func(t *testing.T, f *orgFixture) string { return "not-a-token" },
+			wantStatus: 404,
+			wantCode:   apperrors.CodeInvitationNotFound,
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T, f *orgFixture) string {
+				token := "expired-token"
+				sum := sha256.Sum256([]byte(token))
+				err := f.repo.CreateInvitation(ctx, &models.OrganizationInvitation{
+					OrgID:     f.org.ID,
+					Email:     invitee.Email,
+					Role:      models.OrgRoleMember,
+					TokenHash: hex.EncodeToString(sum[:]),
+					ExpiresAt: time.Now().Add(-time.Minute),
+				})
+				if err != nil {
+					t.Fatalf("failed to create invitation: %v", err)
+				}
+				return token
+			},
+			wantStatus: 400,
+			wantCode:   apperrors.CodeInvitationExpired,
+		},
+		{
+			name: "revoked",
+			token: func(t *testing.T, f *orgFixture) string {
+				token := f.invite(t, invitee.Email)
+				invitations, _ := f.repo.ListPendingInvitations(ctx, f.org.ID)
+				if err := f.service.RevokeInvitation(ctx, f.owner.ID, invitations[0].ID); err != nil {
+					t.Fatalf("RevokeInvitation() error = %v", err)
+				}
+				return token
+			},
+			wantStatus: 404,
+			wantCode:   apperrors.CodeInvitationNotFound,
+		},
+		{
+			name: "already in an organization",
+			token: func(t *testing.T, f *orgFixture) string {
+				token := f.invite(t, invitee.Email)
+				if err := f.repo.Create(ctx, &models.Organization{Name: "Own"}, invitee.ID); err != nil {
+					t.Fatalf("failed to create organization: %v", err)
+				}
+				return token
+			},
+			wantStatus: 409,
+			wantCode:   apperrors.CodeAlreadyInOrg,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOrgFixture(t, invitee)
+			token := tt.token(t, f)
+
+			_, err := f.service.AcceptInvitation(ctx, invitee.ID, token)
+			wantAppError(t, err, tt.wantStatus, tt.wantCode)
+			if member, err := f.repo.GetMembership(ctx, invitee.ID); err == nil && member.OrgID == f.org.ID {
+				t.Fatal("invitee joined the organization")
+			}
+		})
+	}
+}
+
+func TestRevokeInvitationOwnersOnly(t *testing.T) {
+	member := &models.User{ID: uuid.New(), Email: "member@example.com"}
+	f := newOrgFixture(t, member)
+	ctx := context.Background()
+
+	if _, err := f.service.AcceptInvitation(ctx, member.ID, f.invite(t, member.Email)); err != nil {
+		t.Fatalf("AcceptInvitation() error = %v", err)
+	}
+	f.invite(t, "pending@example.com")
+	invitations, _ := f.repo.ListPendingInvitations(ctx, f.org.ID)
+
+	err := f.service.RevokeInvitation(ctx, member.ID, invitations[0].ID)
+	wantAppError(t, err, 403, apperrors.CodeOrgOwnerRequired)
+
+	err = f.service.RevokeInvitation(ctx, f.owner.ID, uuid.New())
+	wantAppError(t, err, 404, apperrors.CodeInvitationNotFound)
+
+	if err := f.service.RevokeInvitation(ctx, f.owner.ID, invitations[0].ID); err != nil {
+		t.Fatalf("RevokeInvitation() by owner error = %v", err)
+	}
+	if pending, _ := f.repo.ListPendingInvitations(ctx, f.org.ID); len(pending) != 0 {
+		t.Fatalf("%d invitations still pending after revoke", len(pending))
+	}
+}
+
+func TestInviteRevokedWhenEmailFails(t *testing.T) {
+	f := newOrgFixture(t)
+	ctx := context.Background()
+	f.mailer.Err = errors.New("smtp down")
+
+	_, err := f.service.Invite(ctx, f.owner.ID, models.InviteMemberInput{Email: "invitee@example.com"})
+	wantAppError(t, err, 500, "")
+	if pending, _ := f.repo.ListPendingInvitations(ctx, f.org.ID); len(pending) != 0 {
+		t.Fatalf("%d invitations pending after the email failed, want 0", len(pending))
+	}
+
+	// The address can be invited again once email works
+	f.mailer.Err = nil
+	f.invite(t, "invitee@example.com")
+}
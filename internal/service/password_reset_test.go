@@ -0,0 +1,159 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+const newTestPassword = "a brand new passphrase"
+
+// resetLinkToken extracts the token of the reset link in an email body.
+var resetLinkToken = regexp.MustCompile(`reset-password\?token=([^"&]+)`)
+
+// newResetTestService returns an auth service with in-memory password resets and
+// mail, and a user who can log in with testPassword.
+func newResetTestService(t *testing.T) (service.AuthService, *testutil.FakePasswordResetRepository, *testutil.FakeMailer, *models.User) {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	user := &models.User{ID: uuid.New(), Email: "user@example.com", PasswordHash: string(hash), Role: models.RoleUser}
+	resets := testutil.NewFakePasswordResetRepository()
+	mailer := &testutil.FakeMailer{}
+
+	authService := service.NewAuthService(testutil.NewFakeUserRepository(user), resets, testutil.NewFakeRefreshTokenRepository(), mailer, nil,
+		service.AuthConfig{
+			JWTSecret:     "test-jwt-secret-0123456789abcdef0123456789",
+			AccessExpiry:  15 * time.Minute,
+			RefreshExpiry: time.Hour,
+			FrontendURL:   "https://app.example.com",
+		}, zap.NewNop())
+	return authService, resets, mailer, user
+}
+
+// requestResetToken requests a reset for user and returns the token from the email.
+func requestResetToken(t *testing.T, authService service.AuthService, mailer *testutil.FakeMailer, user *models.User) string {
+	t.Helper()
+
+	if err := authService.RequestPasswordReset(context.Background(), user.Email); err != nil {
+		t.Fatalf("RequestPasswordReset() error = %v", err)
+	}
+	messages := mailer.Sent()
+	if len(messages) == 0 {
+		t.Fatal("no reset email was sent")
+	}
+	match := resetLinkToken.FindStringSubmatch(messages[len(messages)-1].HTMLBody)
+	if match == nil {
+		t.Fatalf("reset email has no link: %s", messages[len(messages)-1].HTMLBody)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatalf("failed to unescape token: %v", err)
+	}
+	return token
+}
+
+func TestResetPasswordTokenIsSingleUse(t *testing.T) {
+	authService, _, mailer, user := newResetTestService(t)
+	ctx := context.Background()
+	token := requestResetToken(t, authService, mailer, user)
+
+	if err := authService.ResetPassword(ctx, token, newTestPassword); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	if _, _, err := authService.Login(ctx, models.LoginInput{Email: user.Email, Password: newTestPassword}, service.DeviceInfo{}); err != nil {
+		t.Fatalf("Login() with the new password error = %v", err)
+	}
+
+	err := authService.ResetPassword(ctx, token, "yet another passphrase")
+	if !errors.Is(err, service.ErrInvalidResetToken) {
+		t.Fatalf("reusing the token: error = %v, want ErrInvalidResetToken", err)
+	}
+	if _, _, err := authService.Login(ctx, models.LoginInput{Email: user.Email, Password: newTestPassword}, service.DeviceInfo{}); err != nil {
+		t.Fatalf("reused token changed the password: Login() error = %v", err)
+	}
+}
+
+func TestResetPasswordRejectsExpiredTokens(t *testing.T) {
+	t.Run("expired in the database", func(t *testing.T) {
+		authService, resets, mailer, user := newResetTestService(t)
+		token := requestResetToken(t, authService, mailer, user)
+		resets.ExpireAll()
+
+		err := authService.ResetPassword(context.Background(), token, newTestPassword)
+		if !errors.Is(err, service.ErrInvalidResetToken) {
+			t.Fatalf("error = %v, want ErrInvalidResetToken", err)
+		}
+	})
+
+	t.Run("expired JWT", func(t *testing.T) {
+		authService, _, _, user := newResetTestService(t)
+		token, err := authService.GenerateShortToken(user.ID, service.TokenPurposePasswordReset, -time.Minute)
+		if err != nil {
+			t.Fatalf("GenerateShortToken() error = %v", err)
+		}
+
+		err = authService.ResetPassword(context.Background(), token, newTestPassword)
+		if !errors.Is(err, service.ErrInvalidResetToken) {
+			t.Fatalf("error = %v, want ErrInvalidResetToken", err)
+		}
+	})
+
+	t.Run("token for another purpose", func(t *testing.T) {
+		authService, _, _, user := newResetTestService(t)
+		token, err := authService.GenerateShortToken(user.ID, service.TokenPurposeOAuthState, time.Minute)
+		if err != nil {
+			t.Fatalf("GenerateShortToken() error = %v", err)
+		}
+
+		err = authService.ResetPassword(context.Background(), token, newTestPassword)
+		if !errors.Is(err, service.ErrInvalidResetToken) {
+			t.Fatalf("error = %v, want ErrInvalidResetToken", err)
+		}
+	})
+}
+
+func TestResetPasswordReplacesTheOldPassword(t *testing.T) {
+	authService, _, mailer, user := newResetTestService(t)
+	ctx := context.Background()
+	token := requestResetToken(t, authService, mailer, user)
+
+	if err := authService.ResetPassword(ctx, token, newTestPassword); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+
+	_, _, err := authService.Login(ctx, models.LoginInput{Email: user.Email, Password: testPassword}, service.DeviceInfo{})
+	if !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Fatalf("Login() with the old password error = %v, want ErrInvalidCredentials", err)
+	}
+
+	err = authService.ChangePassword(ctx, user.ID, testPassword, "another passphrase")
+	if !errors.Is(err, service.ErrInvalidPassword) {
+		t.Fatalf("ChangePassword() with the old password error = %v, want ErrInvalidPassword", err)
+	}
+}
+
+func TestRequestPasswordResetIgnoresUnknownEmails(t *testing.T) {
+	authService, _, mailer, _ := newResetTestService(t)
+
+	if err := authService.RequestPasswordReset(context.Background(), "nobody@example.com"); err != nil {
+		t.Fatalf("RequestPasswordReset() error = %v, want nil", err)
+	}
+	if messages := mailer.Sent(); len(messages) != 0 {
+		t.Fatalf("sent %d emails for an unknown address, want 0", len(messages))
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+)
+
+// platformKeyQuotaWindow is the rolling window of the platform OpenRouter key quota.
+const platformKeyQuotaWindow = 24 * time.Hour
+
+// ProviderKeyConfig holds settings for ProviderKeyService.
+type ProviderKeyConfig struct {
+	// AllowPlatformOpenRouterKey lets users without an OpenRouter key run jobs on the platform key
+	AllowPlatformOpenRouterKey bool
+	// PlatformDailyJobs caps the jobs a user can create on the platform key per 24 hours
+	PlatformDailyJobs int
+
+	// AllowPlatformKIEKey lets users without a KIE key run jobs on the platform key
+	AllowPlatformKIEKey bool
+	// PlatformMonthlyKIECredits caps the estimated KIE credits a user can spend on the platform key per month
+	PlatformMonthlyKIECredits int
+	// JobKIECredits is the estimated KIE credits of one job (a music and an image task)
+	JobKIECredits int
+}
+
+// ProviderKeySources records which key each provider's calls use for new jobs:
+// models.KeySourceUser, models.KeySourceOrganization or models.KeySourcePlatform.
+type ProviderKeySources struct {
+	OpenRouter string
+	KIE        string
+
+	// reservation is the platform quota reserved for the jobs, if any
+	reservation *models.PlatformQuotaReservation
+}
+
+// ProviderKeyService checks which provider API keys a user's new jobs run on.
+type ProviderKeyService interface {
+	// RequireKeys checks that the user can create count new jobs and returns the
+	// key sources to record on them.
+	RequireKeys(ctx context.Context, user *models.User, count int) (*ProviderKeySources, error)
+	// ReleaseKeys returns the platform quota RequireKeys reserved for unused of the
+	// jobs, e.g. when creating them failed.
+	ReleaseKeys(ctx context.Context, sources *ProviderKeySources, unused int)
+}
+
+// providerKeyService implements ProviderKeyService.
+type providerKeyService struct {
+	quotaRepo repository.PlatformQuotaRepository
+	orgRepo   repository.OrganizationRepository
+	cfg       ProviderKeyConfig
+	logger    *zap.Logger
+}
+
+// NewProviderKeyService creates a new ProviderKeyService instance.
+func NewProviderKeyService(
+	quotaRepo repository.PlatformQuotaRepository,
+	orgRepo repository.OrganizationRepository,
+	cfg ProviderKeyConfig,
+	logger *zap.Logger,
+) ProviderKeyService {
+	return &providerKeyService{
+		quotaRepo: quotaRepo,
+		orgRepo:   orgRepo,
+		cfg:       cfg,
+		logger:    logger,
+	}
+}
+
+// RequireKeys checks that the user has usable OpenRouter and KIE API keys. Every
+// job needs both, whether it is created over the API or by a schedule. Without
+// their own key, jobs fall back to their organization's key, then to the platform
+// key of that provider when allowed, within the user's platform quota, which is
+// reserved for the jobs. Keys are never stored empty, so their presence is
+// checked without decrypting them.
+func (s *providerKeyService) RequireKeys(ctx context.Context, user *models.User, count int) (*ProviderKeySources, error) {
+	sources := &ProviderKeySources{OpenRouter: models.KeySourceUser, KIE: models.KeySourceUser}
+
+	hasOpenRouterKey := user.HasOpenRouterKey
+	hasKIEKey := user.HasKIEKey
+
+	var orgOpenRouterKey, orgKIEKey *string
+	if user.OrgID != nil && (!hasOpenRouterKey || !hasKIEKey) {
+		var err error
+		orgOpenRouterKey, orgKIEKey, err = s.orgRepo.GetAPIKeysForUser(ctx, user.ID)
+		if err != nil {
+			s.logger.Error("failed to get organization API keys",
+				zap.Error(err),
+				zap.String("user_id", user.ID.String()),
+			)
+			return nil, apperrors.NewInternalError(err)
+		}
+	}
+
+	if !hasOpenRouterKey {
+		if isKeySet(orgOpenRouterKey) {
+			sources.OpenRouter = models.KeySourceOrganization
+		} else if !s.cfg.AllowPlatformOpenRouterKey {
+			return nil, apperrors.NewBadRequest("OpenRouter API key is required. Please configure in Settings.").
+				WithCode(apperrors.CodeMissingOpenRouterKey)
+		} else {
+			sources.OpenRouter = models.KeySourcePlatform
+		}
+	}
+
+	if !hasKIEKey {
+		if isKeySet(orgKIEKey) {
+			sources.KIE = models.KeySourceOrganization
+		} else if !s.cfg.AllowPlatformKIEKey {
+			return nil, apperrors.NewBadRequest("KIE API key is required. Please configure in Settings.").
+				WithCode(apperrors.CodeMissingKIEKey)
+		} else {
+			sources.KIE = models.KeySourcePlatform
+		}
+	}
+
+	if err := s.reservePlatformQuota(ctx, user, sources, count); err != nil {
+		return nil, err
+	}
+
+	return sources, nil
+}
+
+// reservePlatformQuota reserves the platform quota of count new jobs on sources,
+// rejecting them when they would take the user over PlatformDailyJobs OpenRouter
+// jobs or PlatformMonthlyKIECredits KIE credits, so one user cannot drain the
+// shared keys. Reserving under a per-user lock keeps concurrent requests from
+// passing the same check.
+func (s *providerKeyService) reservePlatformQuota(ctx context.Context, user *models.User, sources *ProviderKeySources, count int) error {
+	reservation := &models.PlatformQuotaReservation{UserID: user.ID}
+	if sources.OpenRouter == models.KeySourcePlatform {
+		reservation.OpenRouterJobs = count
+	}
+	if sources.KIE == models.KeySourcePlatform {
+		reservation.KIECredits = count * s.cfg.JobKIECredits
+	}
+	if reservation.OpenRouterJobs == 0 && reservation.KIECredits == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	usage, err := s.quotaRepo.Reserve(ctx, reservation, models.PlatformQuotaLimits{
+		OpenRouterJobs:  s.cfg.PlatformDailyJobs,
+		OpenRouterSince: now.Add(-platformKeyQuotaWindow),
+		KIECredits:      s.cfg.PlatformMonthlyKIECredits,
+		KIESince:        models.SpendPeriodStart(now),
+	})
+	if err != nil {
+		if !errors.Is(err, repository.ErrPlatformQuotaExceeded) {
+			s.logger.Error("failed to reserve platform key quota",
+				zap.Error(err),
+				zap.String("user_id", user.ID.String()),
+			)
+			return apperrors.NewInternalError(err)
+		}
+
+		if reservation.OpenRouterJobs > 0 && usage.OpenRouterJobs+reservation.OpenRouterJobs > s.cfg.PlatformDailyJobs {
+			remaining := max(s.cfg.PlatformDailyJobs-usage.OpenRouterJobs, 0)
+			return apperrors.NewTooManyRequests(fmt.Sprintf(
+				"without your own OpenRouter API key you can create %d jobs per day (%d left). Add a key in Settings to remove the limit.",
+				s.cfg.PlatformDailyJobs, remaining,
+			)).WithCode(apperrors.CodeQuotaExceeded)
+		}
+		remaining := max(s.cfg.PlatformMonthlyKIECredits-usage.KIECredits, 0)
+		return apperrors.NewTooManyRequests(fmt.Sprintf(
+			"without your own KIE API key you can spend %d KIE credits per month (%d left, about %d per job). Add a key in Settings to remove the limit.",
+			s.cfg.PlatformMonthlyKIECredits, remaining, s.cfg.JobKIECredits,
+		)).WithCode(apperrors.CodeQuotaExceeded)
+	}
+
+	sources.reservation = reservation
+	return nil
+}
+
+// ReleaseKeys returns the platform quota reserved for unused jobs. Failures are
+// only logged: the reservation then expires with its quota window.
+func (s *providerKeyService) ReleaseKeys(ctx context.Context, sources *ProviderKeySources, unused int) {
+	if sources == nil || sources.reservation == nil || unused <= 0 {
+		return
+	}
+
+	reservation := sources.reservation
+	var openRouterJobs, kieCredits int
+	if reservation.OpenRouterJobs > 0 {
+		openRouterJobs = unused
+	}
+	if reservation.KIECredits > 0 {
+		kieCredits = unused * s.cfg.JobKIECredits
+	}
+	if err := s.quotaRepo.Release(ctx, reservation.ID, openRouterJobs, kieCredits); err != nil {
+		s.logger.Error("failed to release platform key quota",
+			zap.Error(err),
+			zap.String("user_id", reservation.UserID.String()),
+			zap.String("reservation_id", reservation.ID.String()),
+		)
+	}
+}
+
+// isKeySet reports whether an encrypted API key is set.
+func isKeySet(encrypted *string) bool {
+	return encrypted != nil && *encrypted != ""
+}
@@ -0,0 +1,85 @@
+package service_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/testutil"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+)
+
+func newProviderKeyService(quota *testutil.FakePlatformQuotaRepository) service.ProviderKeyService {
+	return service.NewProviderKeyService(quota, testutil.NewFakeOrganizationRepository(), service.ProviderKeyConfig{
+		AllowPlatformOpenRouterKey: true,
+		PlatformDailyJobs:          3,
+		AllowPlatformKIEKey:        true,
+		PlatformMonthlyKIECredits:  100,
+		JobKIECredits:              20,
+	}, zap.NewNop())
+}
+
+func TestRequireKeysReservesPlatformQuotaConcurrently(t *testing.T) {
+	quota := testutil.NewFakePlatformQuotaRepository()
+	svc := newProviderKeyService(quota)
+	user := &models.User{ID: uuid.New(), HasKIEKey: true}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = svc.RequireKeys(context.Background(), user, 1)
+		}()
+	}
+	wg.Wait()
+
+	accepted := 0
+	for _, err := range errs {
+		if err == nil {
+			accepted++
+			continue
+		}
+		wantAppError(t, err, 429, apperrors.CodeQuotaExceeded)
+	}
+	if accepted != 3 {
+		t.Fatalf("%d concurrent jobs passed a quota of 3", accepted)
+	}
+	if usage := quota.Usage(user.ID); usage.OpenRouterJobs != 3 || usage.KIECredits != 0 {
+		t.Fatalf("usage = %+v, want 3 OpenRouter jobs and no KIE credits", usage)
+	}
+}
+
+func TestReleaseKeysReturnsUnusedQuota(t *testing.T) {
+	quota := testutil.NewFakePlatformQuotaRepository()
+	svc := newProviderKeyService(quota)
+	user := &models.User{ID: uuid.New()}
+	ctx := context.Background()
+
+	sources, err := svc.RequireKeys(ctx, user, 3)
+	if err != nil {
+		t.Fatalf("RequireKeys() error = %v", err)
+	}
+	if sources.OpenRouter != models.KeySourcePlatform || sources.KIE != models.KeySourcePlatform {
+		t.Fatalf("sources = %+v, want platform keys", sources)
+	}
+
+	// Two of the three jobs were not created
+	svc.ReleaseKeys(ctx, sources, 2)
+	if usage := quota.Usage(user.ID); usage.OpenRouterJobs != 1 || usage.KIECredits != 20 {
+		t.Fatalf("usage after release = %+v, want 1 job and 20 credits", usage)
+	}
+
+	// 20 credits are reserved, so 4 more jobs fit the 100 credit quota but 5 do not
+	_, err = svc.RequireKeys(ctx, &models.User{ID: user.ID, HasOpenRouterKey: true}, 5)
+	wantAppError(t, err, 429, apperrors.CodeQuotaExceeded)
+	if _, err := svc.RequireKeys(ctx, &models.User{ID: user.ID, HasOpenRouterKey: true}, 4); err != nil {
+		t.Fatalf("RequireKeys() within the KIE quota error = %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// providerStatsRetention is how long the per-minute call counters are kept, the
+// longest window Counts can report on.
+const providerStatsRetention = time.Hour
+
+// ProviderCallCounts is the number of calls to an external service and how many failed.
+type ProviderCallCounts struct {
+	Calls  int64
+	Errors int64
+}
+
+// ProviderStats counts calls to the external services and their errors, shared
+// by the workers that make the calls and the API that reports on them.
+type ProviderStats interface {
+	// Record counts one call to provider, failed when err is non-nil.
+	Record(ctx context.Context, provider string, err error) error
+	// Counts returns the calls to each of providers in the last window, at most an hour.
+	Counts(ctx context.Context, providers []string, window time.Duration) (map[string]ProviderCallCounts, error)
+}
+
+// redisProviderStats implements ProviderStats with a Redis hash per provider and minute.
+type redisProviderStats struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisProviderStats creates a ProviderStats stored in Redis.
+func NewRedisProviderStats(client *redis.Client, keyPrefix string) ProviderStats {
+	return &redisProviderStats{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// Record increments the current minute's calls and, on error, errors of provider.
+func (s *redisProviderStats) Record(ctx context.Context, provider string, err error) error {
+	key := s.key(provider, time.Now())
+
+	pipe := s.client.TxPipeline()
+	pipe.HIncrBy(ctx, key, "calls", 1)
+	if err != nil {
+		pipe.HIncrBy(ctx, key, "errors", 1)
+	}
+	pipe.Expire(ctx, key, providerStatsRetention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record provider call: %w", err)
+	}
+	return nil
+}
+
+// Counts sums the minute counters of each provider over window.
+func (s *redisProviderStats) Counts(ctx context.Context, providers []string, window time.Duration) (map[string]ProviderCallCounts, error) {
+	window = min(window, providerStatsRetention)
+	minutes := int(window / time.Minute)
+	now := time.Now()
+
+	pipe := s.client.Pipeline()
+	cmds := make(map[string][]*redis.SliceCmd, len(providers))
+	for _, provider := range providers {
+		for i := 0; i < minutes; i++ {
+			key := s.key(provider, now.Add(-time.Duration(i)*time.Minute))
+			cmds[provider] = append(cmds[provider], pipe.HMGet(ctx, key, "calls", "errors"))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to read provider calls: %w", err)
+	}
+
+	counts := make(map[string]ProviderCallCounts, len(providers))
+	for provider, minuteCmds := range cmds {
+		var total ProviderCallCounts
+		for _, cmd := range minuteCmds {
+			values := cmd.Val()
+			total.Calls += counterValue(values, 0)
+			total.Errors += counterValue(values, 1)
+		}
+		counts[provider] = total
+	}
+	return counts, nil
+}
+
+// key returns the Redis key counting the calls to provider in the minute of t.
+func (s *redisProviderStats) key(provider string, t time.Time) string {
+	return fmt.Sprintf("%s:provider_calls:%s:%d", s.keyPrefix, provider, t.Unix()/60)
+}
+
+// counterValue returns values[i] of an HMGET as a number, 0 when unset.
+func counterValue(values []interface{}, i int) int64 {
+	if i >= len(values) {
+		return 0
+	}
+	s, ok := values[i].(string)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// defaultIntakePausedMessage is the error message of a paused job intake without a banner.
+const defaultIntakePausedMessage = "new jobs are paused for maintenance, please try again later"
+
+// RuntimeSettingsService defines the interface for the settings admins change at
+// runtime: the maintenance banner and the job intake pause.
+type RuntimeSettingsService interface {
+	Get(ctx context.Context) (*models.RuntimeSettings, error)
+	Update(ctx context.Context, adminID uuid.UUID, input models.UpdateRuntimeSettingsInput) (*models.RuntimeSettings, error)
+	// Notice returns the maintenance banner to show, or nil when there is none or
+	// the settings cannot be read.
+	Notice(ctx context.Context) *models.MaintenanceNotice
+	// CheckJobIntake returns a 503 JOB_INTAKE_PAUSED error, carrying the banner,
+	// while job intake is paused. Unreadable settings leave intake open.
+	CheckJobIntake(ctx context.Context) error
+}
+
+// runtimeSettingsService implements RuntimeSettingsService.
+type runtimeSettingsService struct {
+	settingRepo repository.RuntimeSettingRepository
+	logger      *zap.Logger
+}
+
+// NewRuntimeSettingsService creates a new RuntimeSettingsService instance.
+// settingRepo is expected to cache reads, since every job creation checks them.
+func NewRuntimeSettingsService(settingRepo repository.RuntimeSettingRepository, logger *zap.Logger) RuntimeSettingsService {
+	return &runtimeSettingsService{
+		settingRepo: settingRepo,
+		logger:      logger,
+	}
+}
+
+// Get returns the current runtime settings.
+func (s *runtimeSettingsService) Get(ctx context.Context) (*models.RuntimeSettings, error) {
+	settings, err := s.settingRepo.Get(ctx)
+	if err != nil {
+		s.logger.Error("failed to get runtime settings", zap.Error(err))
+		return nil, apperrors.NewInternalError(err)
+	}
+	return settings, nil
+}
+
+// Update validates and saves the fields set in input, then returns the new settings.
+func (s *runtimeSettingsService) Update(ctx context.Context, adminID uuid.UUID, input models.UpdateRuntimeSettingsInput) (*models.RuntimeSettings, error) {
+	if input.JobIntakePaused == nil && input.BannerMessage == nil {
+		return nil, apperrors.NewBadRequest("job_intake_paused or banner_message is required")
+	}
+	if input.BannerMessage != nil {
+		message := strings.TrimSpace(*input.BannerMessage)
+		if len([]rune(message)) > models.MaxBannerMessageLength {
+			return nil, apperrors.NewValidationError(map[string]string{
+				"banner_message": "must be 500 characters or less",
+			})
+		}
+		input.BannerMessage = &message
+	}
+
+	if err := s.settingRepo.Update(ctx, input, adminID); err != nil {
+		s.logger.Error("failed to update runtime settings",
+			zap.Error(err),
+			zap.String("admin_id", adminID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("runtime settings updated",
+		zap.String("admin_id", adminID.String()),
+		zap.Any("job_intake_paused", input.JobIntakePaused),
+		zap.Bool("banner_message_set", input.BannerMessage != nil && *input.BannerMessage != ""),
+	)
+
+	return s.Get(ctx)
+}
+
+// Notice returns the maintenance banner to show, if any.
+func (s *runtimeSettingsService) Notice(ctx context.Context) *models.MaintenanceNotice {
+	settings, err := s.settingRepo.Get(ctx)
+	if err != nil {
+		s.logger.Warn("failed to get runtime settings for the maintenance banner", zap.Error(err))
+		return nil
+	}
+	return settings.Notice()
+}
+
+// CheckJobIntake refuses new jobs while job intake is paused.
+func (s *runtimeSettingsService) CheckJobIntake(ctx context.Context) error {
+	settings, err := s.settingRepo.Get(ctx)
+	if err != nil {
+		// A settings outage must not stop job creation
+		s.logger.Warn("failed to get runtime settings, leaving job intake open", zap.Error(err))
+		return nil
+	}
+	if !settings.JobIntakePaused {
+		return nil
+	}
+
+	message := settings.BannerMessage
+	if message == "" {
+		message = defaultIntakePausedMessage
+	}
+	// Translated messages replace the banner, so it is also returned as a detail
+	return apperrors.NewServiceUnavailable(message).
+		WithCode(apperrors.CodeJobIntakePaused).
+		WithDetails(map[string]string{"banner_message": settings.BannerMessage})
+}
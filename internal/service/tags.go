@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+)
+
+// Job tag limits, with the tag length counted in characters (runes).
+const (
+	MaxJobTags      = 10
+	MaxJobTagLength = 30
+)
+
+// NormalizeTags trims and lowercases tags, dropping empty and duplicate ones, and
+// checks the limits. The result is never nil, so it can be stored as-is.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > MaxJobTagLength {
+			return nil, apperrors.NewFieldError("tags", apperrors.FieldTagTooLong,
+				fmt.Sprintf("tags must be at most %d characters", MaxJobTagLength)).
+				WithParams(map[string]string{"max": strconv.Itoa(MaxJobTagLength)})
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) > MaxJobTags {
+		return nil, apperrors.NewFieldError("tags", apperrors.FieldTooManyTags,
+			fmt.Sprintf("at most %d tags are allowed", MaxJobTags)).
+			WithParams(map[string]string{"max": strconv.Itoa(MaxJobTags)})
+	}
+	return normalized, nil
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// userWebhookSecretBytes is the length of a generated webhook signing secret.
+const userWebhookSecretBytes = 32
+
+// UserWebhookService defines the interface for the user's outbound webhook settings.
+// Every method is scoped to the requesting user.
+type UserWebhookService interface {
+	Create(ctx context.Context, userID uuid.UUID, input models.UserWebhookInput) (*models.CreatedUserWebhook, error)
+	Get(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) (*models.UserWebhook, error)
+	List(ctx context.Context, userID uuid.UUID) ([]*models.UserWebhook, error)
+	Update(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID, input models.UserWebhookInput) (*models.UserWebhook, error)
+	Delete(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) error
+}
+
+// userWebhookService implements UserWebhookService.
+type userWebhookService struct {
+	webhookRepo   repository.UserWebhookRepository
+	cryptoService CryptoService
+	logger        *zap.Logger
+}
+
+// NewUserWebhookService creates a new UserWebhookService instance.
+func NewUserWebhookService(webhookRepo repository.UserWebhookRepository, cryptoService CryptoService, logger *zap.Logger) UserWebhookService {
+	return &userWebhookService{
+		webhookRepo:   webhookRepo,
+		cryptoService: cryptoService,
+		logger:        logger,
+	}
+}
+
+// Create registers a webhook with a new signing secret, enforcing the per-user cap.
+// The input must already be validated. The secret is stored encrypted and only
+// returned in this response.
+func (s *userWebhookService) Create(ctx context.Context, userID uuid.UUID, input models.UserWebhookInput) (*models.CreatedUserWebhook, error) {
+	buf := make([]byte, userWebhookSecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, apperrors.NewInternalError(fmt.Errorf("failed to generate webhook secret: %w", err))
+	}
+	secret := hex.EncodeToString(buf)
+
+	encrypted, err := s.cryptoService.Encrypt(secret)
+	if err != nil {
+		return nil, apperrors.NewInternalError(fmt.Errorf("failed to encrypt webhook secret: %w", err))
+	}
+
+	webhook := &models.UserWebhook{UserID: userID, Secret: encrypted}
+	applyUserWebhookInput(webhook, input)
+
+	if err := s.webhookRepo.Create(ctx, webhook, models.MaxUserWebhooksPerUser); err != nil {
+		if errors.Is(err, repository.ErrUserWebhookLimitReached) {
+			return nil, apperrors.NewConflict(fmt.Sprintf("you can register at most %d webhooks", models.MaxUserWebhooksPerUser)).
+				WithCode(apperrors.CodeWebhookLimitReached)
+		}
+		s.logger.Error("failed to create user webhook",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("user webhook created",
+		zap.String("webhook_id", webhook.ID.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	return &models.CreatedUserWebhook{UserWebhook: webhook, Secret: secret}, nil
+}
+
+// Get retrieves a webhook and verifies ownership.
+func (s *userWebhookService) Get(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) (*models.UserWebhook, error) {
+	webhook, err := s.webhookRepo.GetByID(ctx, webhookID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserWebhookNotFound) {
+			return nil, apperrors.NewNotFound("webhook not found").WithCode(apperrors.CodeWebhookNotFound)
+		}
+		s.logger.Error("failed to get user webhook",
+			zap.Error(err),
+			zap.String("webhook_id", webhookID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	// Verify ownership
+	if webhook.UserID != userID {
+		s.logger.Warn("unauthorized user webhook access attempt",
+			zap.String("webhook_id", webhookID.String()),
+			zap.String("owner_id", webhook.UserID.String()),
+			zap.String("requester_id", userID.String()),
+		)
+		return nil, apperrors.NewForbidden("you do not have access to this webhook").WithCode(apperrors.CodeWebhookAccessDenied)
+	}
+
+	return webhook, nil
+}
+
+// List returns all of the user's webhooks with their delivery statistics.
+func (s *userWebhookService) List(ctx context.Context, userID uuid.UUID) ([]*models.UserWebhook, error) {
+	webhooks, err := s.webhookRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to list user webhooks",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+	return webhooks, nil
+}
+
+// Update replaces the URL, events and enabled flag of a webhook the user owns.
+func (s *userWebhookService) Update(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID, input models.UserWebhookInput) (*models.UserWebhook, error) {
+	webhook, err := s.Get(ctx, userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	applyUserWebhookInput(webhook, input)
+
+	if err := s.webhookRepo.Update(ctx, webhook); err != nil {
+		if errors.Is(err, repository.ErrUserWebhookNotFound) {
+			return nil, apperrors.NewNotFound("webhook not found").WithCode(apperrors.CodeWebhookNotFound)
+		}
+		s.logger.Error("failed to update user webhook",
+			zap.Error(err),
+			zap.String("webhook_id", webhookID.String()),
+		)
+		return nil, apperrors.NewInternalError(err)
+	}
+
+	return webhook, nil
+}
+
+// Delete removes a webhook the user owns.
+func (s *userWebhookService) Delete(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) error {
+	if _, err := s.Get(ctx, userID, webhookID); err != nil {
+		return err
+	}
+
+	if err := s.webhookRepo.Delete(ctx, webhookID); err != nil {
+		if errors.Is(err, repository.ErrUserWebhookNotFound) {
+			return apperrors.NewNotFound("webhook not found").WithCode(apperrors.CodeWebhookNotFound)
+		}
+		s.logger.Error("failed to delete user webhook",
+			zap.Error(err),
+			zap.String("webhook_id", webhookID.String()),
+		)
+		return apperrors.NewInternalError(err)
+	}
+
+	s.logger.Info("user webhook deleted",
+		zap.String("webhook_id", webhookID.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	return nil
+}
+
+// applyUserWebhookInput copies the editable fields of input onto webhook.
+// No events subscribes the webhook to every event.
+func applyUserWebhookInput(webhook *models.UserWebhook, input models.UserWebhookInput) {
+	webhook.URL = input.URL
+	webhook.Events = input.Events
+	if len(webhook.Events) == 0 {
+		webhook.Events = append([]string(nil), models.UserWebhookEvents...)
+	}
+	webhook.Enabled = input.Enabled == nil || *input.Enabled
+}
@@ -0,0 +1,234 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jaochai/ugc/internal/external/kie"
+	"github.com/jaochai/ugc/internal/external/openrouter"
+)
+
+// FakeChatClient is an in-memory openrouter.ChatClient for tests that inject
+// clients (agents, tasks.Dependencies.NewChatClient) instead of pointing them at
+// FakeOpenRouter. It answers from a queue of responses and records the requests.
+type FakeChatClient struct {
+	mu        sync.Mutex
+	responses []string
+	errs      []error
+	Requests  []openrouter.ChatRequest
+
+	// Usage is reported by every successful Chat response
+	Usage openrouter.Usage
+}
+
+// NewFakeChatClient returns a FakeChatClient answering with responses in order.
+func NewFakeChatClient(responses ...string) *FakeChatClient {
+	return &FakeChatClient{responses: responses}
+}
+
+// FailNext makes the next requests fail with errs, in order, before any queued responses.
+func (f *FakeChatClient) FailNext(errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errs = append(f.errs, errs...)
+}
+
+// Chat returns the next queued response as the only choice.
+func (f *FakeChatClient) Chat(ctx context.Context, req openrouter.ChatRequest) (*openrouter.ChatResponse, error) {
+	content, err := f.next(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &openrouter.ChatResponse{Model: req.Model, Usage: f.Usage}
+	resp.Choices = append(resp.Choices, openrouter.Choice{
+		Message:      openrouter.Message{Role: "assistant", Content: content},
+		FinishReason: "stop",
+	})
+	return resp, nil
+}
+
+// Complete returns the next queued response.
+func (f *FakeChatClient) Complete(ctx context.Context, req openrouter.ChatRequest) (string, error) {
+	return f.next(req)
+}
+
+// ChatWithModel returns the next queued response.
+func (f *FakeChatClient) ChatWithModel(ctx context.Context, model string, systemPrompt string, userPrompt string) (string, error) {
+	return f.next(openrouter.ChatRequest{
+		Model: model,
+		Messages: []openrouter.Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: userPrompt},
+		},
+	})
+}
+
+// next records req and returns the next queued error or response.
+func (f *FakeChatClient) next(req openrouter.ChatRequest) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Requests = append(f.Requests, req)
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return "", err
+	}
+	if len(f.responses) == 0 {
+		return "", errors.New("fake chat client: no response queued")
+	}
+	content := f.responses[0]
+	f.responses = f.responses[1:]
+	return content, nil
+}
+
+// FakeMusicClient is a kie.MusicClient whose tasks complete immediately with Songs.
+type FakeMusicClient struct {
+	mu       sync.Mutex
+	Songs    []kie.SongData
+	Err      error // Returned by every call when set
+	Requests []kie.GenerateRequest
+}
+
+// Generate records req and returns a new task ID.
+func (f *FakeMusicClient) Generate(ctx context.Context, req kie.GenerateRequest) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return "", f.Err
+	}
+	f.Requests = append(f.Requests, req)
+	return fmt.Sprintf("suno-task-%d", len(f.Requests)), nil
+}
+
+// GetTask returns a successful task with Songs.
+func (f *FakeMusicClient) GetTask(ctx context.Context, taskId string) (*kie.TaskResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	resp := &kie.TaskResponse{Code: 200}
+	resp.Data.TaskId = taskId
+	resp.Data.Status = kie.StatusSuccess
+	resp.Data.Response.SunoData = f.Songs
+	return resp, nil
+}
+
+// WaitForCompletion returns the task without waiting.
+func (f *FakeMusicClient) WaitForCompletion(ctx context.Context, taskId string, timeout time.Duration) (*kie.TaskResponse, error) {
+	return f.GetTask(ctx, taskId)
+}
+
+// FakeImageClient is a kie.ImageClient whose tasks complete immediately with ImageURL.
+type FakeImageClient struct {
+	mu       sync.Mutex
+	ImageURL string
+	Err      error // Returned by every call when set
+	Requests []kie.CreateTaskRequest
+
+	// FailTasks is how many of the first tasks created fail with FailCode and
+	// FailMsg, e.g. to refuse a prompt under the content policy
+	FailTasks int
+	FailCode  string
+	FailMsg   string
+}
+
+// CreateTask records req and returns a new task ID.
+func (f *FakeImageClient) CreateTask(ctx context.Context, req kie.CreateTaskRequest) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return "", f.Err
+	}
+	f.Requests = append(f.Requests, req)
+	return fmt.Sprintf("nano-task-%d", len(f.Requests)), nil
+}
+
+// GetTask returns the task: failed if it is one of the first FailTasks tasks,
+// otherwise successful with ImageURL as its result.
+func (f *FakeImageClient) GetTask(ctx context.Context, taskId string) (*kie.TaskStatusResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	resp := &kie.TaskStatusResponse{Code: 200}
+	resp.Data.TaskId = taskId
+	var n int
+	if _, err := fmt.Sscanf(taskId, "nano-task-%d", &n); err == nil && n <= f.FailTasks {
+		resp.Data.State = kie.StateFail
+		resp.Data.FailCode = f.FailCode
+		resp.Data.FailMsg = f.FailMsg
+		return resp, nil
+	}
+	resp.Data.State = kie.StateSuccess
+	resp.Data.ResultJson = fmt.Sprintf(`{"resultUrls":[%q]}`, f.ImageURL)
+	return resp, nil
+}
+
+// WaitForCompletion returns the task without waiting, with an error if it
+// failed, as NanoBananaClient does.
+func (f *FakeImageClient) WaitForCompletion(ctx context.Context, taskId string, timeout time.Duration) (*kie.TaskStatusResponse, error) {
+	resp, err := f.GetTask(ctx, taskId)
+	if err == nil && resp.Data.State == kie.StateFail {
+		return resp, fmt.Errorf("task failed: %s (code: %s)", resp.Data.FailMsg, resp.Data.FailCode)
+	}
+	return resp, err
+}
+
+// GetImageUrl returns ImageURL.
+func (f *FakeImageClient) GetImageUrl(status *kie.TaskStatusResponse) (string, error) {
+	if f.ImageURL == "" {
+		return "", errors.New("fake image client: no image URL set")
+	}
+	return f.ImageURL, nil
+}
+
+var (
+	_ openrouter.ChatClient = (*FakeChatClient)(nil)
+	_ kie.MusicClient       = (*FakeMusicClient)(nil)
+	_ kie.ImageClient       = (*FakeImageClient)(nil)
+)
+
+// SentEmail is an email sent through FakeMailer.
+type SentEmail struct {
+	To       string
+	Subject  string
+	HTMLBody string
+}
+
+// FakeMailer is an email.Mailer that records what it sends. Err, when set, is
+// returned instead of sending.
+type FakeMailer struct {
+	Err error
+
+	mu   sync.Mutex
+	sent []SentEmail
+}
+
+// Send records the email.
+func (f *FakeMailer) Send(ctx context.Context, to, subject, htmlBody string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.sent = append(f.sent, SentEmail{To: to, Subject: subject, HTMLBody: htmlBody})
+	return nil
+}
+
+// Sent returns the emails sent so far.
+func (f *FakeMailer) Sent() []SentEmail {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]SentEmail(nil), f.sent...)
+}
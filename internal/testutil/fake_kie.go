@@ -0,0 +1,249 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaochai/ugc/internal/external/kie"
+)
+
+// FakeKIE emulates the KIE endpoints used by the pipeline: Suno generate and
+// record-info, NanoBanana createTask and recordInfo, and the credit balance.
+// Tasks finish on their first status poll unless a state is scripted. Generated
+// songs and images point at files the server itself serves, so they can be
+// downloaded by ffmpeg.Processor.
+type FakeKIE struct {
+	Server *httptest.Server
+
+	mu          sync.Mutex
+	credits     int
+	nextID      int
+	sunoTasks   map[string]kie.GenerateRequest
+	sunoStatus  string // Status reported by record-info; kie.StatusSuccess by default
+	sunoError   string
+	imageTasks  map[string]kie.CreateTaskRequest
+	imageState  string // State reported by recordInfo; kie.StateSuccess by default
+	imageFail   string
+	createError int // HTTP status returned by create endpoints when non-zero
+}
+
+// NewFakeKIE starts a fake KIE server, closed when the test ends. Use URL() as
+// the KIE base URL.
+func NewFakeKIE(t testing.TB) *FakeKIE {
+	t.Helper()
+
+	f := &FakeKIE{
+		credits:    1000,
+		sunoTasks:  make(map[string]kie.GenerateRequest),
+		sunoStatus: kie.StatusSuccess,
+		imageTasks: make(map[string]kie.CreateTaskRequest),
+		imageState: kie.StateSuccess,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/generate", f.handleGenerate)
+	mux.HandleFunc("/api/v1/generate/record-info", f.handleSunoRecord)
+	mux.HandleFunc("/api/v1/jobs/createTask", f.handleCreateTask)
+	mux.HandleFunc("/api/v1/jobs/recordInfo", f.handleImageRecord)
+	mux.HandleFunc("/api/v1/chat/credit", f.handleCredits)
+	mux.HandleFunc("/files/", f.handleFile)
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Server.Close)
+	return f
+}
+
+// URL returns the base URL to use as KIE_BASE_URL.
+func (f *FakeKIE) URL() string {
+	return f.Server.URL
+}
+
+// SetCredits sets the balance returned by the credit endpoint.
+func (f *FakeKIE) SetCredits(credits int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.credits = credits
+}
+
+// SetSunoStatus sets the status and error message reported for Suno tasks,
+// e.g. kie.StatusPending to keep them running or kie.StatusSensitiveWordError.
+func (f *FakeKIE) SetSunoStatus(status, errorMessage string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sunoStatus, f.sunoError = status, errorMessage
+}
+
+// SetImageState sets the state and failure message reported for image tasks.
+func (f *FakeKIE) SetImageState(state, failMsg string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.imageState, f.imageFail = state, failMsg
+}
+
+// FailCreate makes the generate and createTask endpoints return status; zero
+// restores normal behavior.
+func (f *FakeKIE) FailCreate(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.createError = status
+}
+
+// SunoRequests returns the Suno generate requests received so far.
+func (f *FakeKIE) SunoRequests() []kie.GenerateRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reqs := make([]kie.GenerateRequest, 0, len(f.sunoTasks))
+	for _, req := range f.sunoTasks {
+		reqs = append(reqs, req)
+	}
+	return reqs
+}
+
+// newTaskID returns a unique task ID. Callers hold f.mu.
+func (f *FakeKIE) newTaskID(prefix string) string {
+	f.nextID++
+	return fmt.Sprintf("%s-%d", prefix, f.nextID)
+}
+
+func (f *FakeKIE) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	var req kie.GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"code": 400, "msg": err.Error()})
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.createError != 0 {
+		writeJSON(w, f.createError, map[string]any{"code": f.createError, "msg": "fake kie error"})
+		return
+	}
+
+	taskID := f.newTaskID("suno")
+	f.sunoTasks[taskID] = req
+	var resp kie.GenerateResponse
+	resp.Code = 200
+	resp.Msg = "success"
+	resp.Data.TaskId = taskID
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (f *FakeKIE) handleSunoRecord(w http.ResponseWriter, r *http.Request) {
+	taskID := r.URL.Query().Get("taskId")
+
+	f.mu.Lock()
+	req, ok := f.sunoTasks[taskID]
+	status, errorMessage := f.sunoStatus, f.sunoError
+	f.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]any{"code": 404, "msg": "task not found"})
+		return
+	}
+
+	var resp kie.TaskResponse
+	resp.Code = 200
+	resp.Msg = "success"
+	resp.Data.TaskId = taskID
+	resp.Data.Status = status
+	resp.Data.ErrorMessage = errorMessage
+	if status == kie.StatusSuccess || status == kie.StatusFirstSuccess {
+		// Suno always returns two variations of a song
+		for i := 1; i <= 2; i++ {
+			resp.Data.Response.SunoData = append(resp.Data.Response.SunoData, kie.SongData{
+				Id:       fmt.Sprintf("%s-song-%d", taskID, i),
+				AudioUrl: fmt.Sprintf("%s/files/%s-%d.mp3", f.URL(), taskID, i),
+				ImageUrl: fmt.Sprintf("%s/files/%s-%d.png", f.URL(), taskID, i),
+				Title:    req.Title,
+				Prompt:   req.Prompt,
+				Tags:     req.Style,
+				Duration: 120 + float64(i),
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (f *FakeKIE) handleCreateTask(w http.ResponseWriter, r *http.Request) {
+	var req kie.CreateTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"code": 400, "msg": err.Error()})
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.createError != 0 {
+		writeJSON(w, f.createError, map[string]any{"code": f.createError, "msg": "fake kie error"})
+		return
+	}
+
+	taskID := f.newTaskID("image")
+	f.imageTasks[taskID] = req
+	var resp kie.CreateTaskResponse
+	resp.Code = 200
+	resp.Data.TaskId = taskID
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (f *FakeKIE) handleImageRecord(w http.ResponseWriter, r *http.Request) {
+	taskID := r.URL.Query().Get("taskId")
+
+	f.mu.Lock()
+	req, ok := f.imageTasks[taskID]
+	state, failMsg := f.imageState, f.imageFail
+	f.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]any{"code": 404, "message": "task not found"})
+		return
+	}
+
+	var resp kie.TaskStatusResponse
+	resp.Code = 200
+	resp.Message = "success"
+	resp.Data.TaskId = taskID
+	resp.Data.Model = req.Model
+	resp.Data.State = state
+	resp.Data.FailMsg = failMsg
+	if state == kie.StateSuccess {
+		result, _ := json.Marshal(kie.ResultUrls{ResultUrls: []string{fmt.Sprintf("%s/files/%s.png", f.URL(), taskID)}})
+		resp.Data.ResultJson = string(result)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (f *FakeKIE) handleCredits(w http.ResponseWriter, _ *http.Request) {
+	f.mu.Lock()
+	credits := f.credits
+	f.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"code": 200, "msg": "success", "data": credits})
+}
+
+// fakeFileSize is the size of served files, above the worker's minimum audio size.
+const fakeFileSize = 64 << 10
+
+// handleFile serves placeholder audio and images. They start with an MP3 or PNG
+// signature, so the render's download checks accept them, but the ffmpeg stub
+// does not decode them. HEAD and range requests are supported.
+func (f *FakeKIE) handleFile(w http.ResponseWriter, r *http.Request) {
+	var head []byte
+	switch path.Ext(r.URL.Path) {
+	case ".mp3":
+		w.Header().Set("Content-Type", "audio/mpeg")
+		head = []byte("ID3\x04\x00\x00\x00\x00\x00\x00")
+	case ".png":
+		w.Header().Set("Content-Type", "image/png")
+		head = []byte("\x89PNG\r\n\x1a\n")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	data := make([]byte, fakeFileSize)
+	copy(data, head)
+	http.ServeContent(w, r, path.Base(r.URL.Path), time.Time{}, bytes.NewReader(data))
+}
@@ -0,0 +1,117 @@
+package testutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/jaochai/ugc/internal/external/openrouter"
+)
+
+// ChatResponder returns the assistant message for a chat request, or an error
+// status (e.g. 401, 429) with an empty reply when status is not 200.
+type ChatResponder func(req openrouter.ChatRequest) (reply string, status int)
+
+// FakeOpenRouter emulates the OpenRouter chat completions endpoint. Replies
+// are queued with Reply or computed by a responder set with Respond.
+type FakeOpenRouter struct {
+	Server *httptest.Server
+
+	mu        sync.Mutex
+	replies   []string
+	responder ChatResponder
+	requests  []openrouter.ChatRequest
+}
+
+// NewFakeOpenRouter starts a fake OpenRouter server, closed when the test ends.
+// Point clients at it with openrouter.WithBaseURL(fake.URL()).
+func NewFakeOpenRouter(t testing.TB) *FakeOpenRouter {
+	t.Helper()
+
+	f := &FakeOpenRouter{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat/completions", f.handleChat)
+	mux.HandleFunc("/models", f.handleModels)
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Server.Close)
+	return f
+}
+
+// URL returns the base URL to pass to openrouter.WithBaseURL.
+func (f *FakeOpenRouter) URL() string {
+	return f.Server.URL
+}
+
+// Reply queues assistant messages returned by the next chat requests, in order.
+func (f *FakeOpenRouter) Reply(replies ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.replies = append(f.replies, replies...)
+}
+
+// Respond sets the responder used once the queued replies run out.
+func (f *FakeOpenRouter) Respond(responder ChatResponder) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responder = responder
+}
+
+// Requests returns the chat requests received so far.
+func (f *FakeOpenRouter) Requests() []openrouter.ChatRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]openrouter.ChatRequest(nil), f.requests...)
+}
+
+func (f *FakeOpenRouter) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req openrouter.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": map[string]string{"message": err.Error()}})
+		return
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	reply, status := "", http.StatusOK
+	switch {
+	case len(f.replies) > 0:
+		reply, f.replies = f.replies[0], f.replies[1:]
+	case f.responder != nil:
+		reply, status = f.responder(req)
+	default:
+		status = http.StatusServiceUnavailable
+	}
+	f.mu.Unlock()
+
+	if status != http.StatusOK {
+		writeJSON(w, status, map[string]any{"error": map[string]string{"message": "fake openrouter error", "code": http.StatusText(status)}})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, openrouter.ChatResponse{
+		ID:     "fake-completion",
+		Object: "chat.completion",
+		Model:  req.Model,
+		Choices: []openrouter.Choice{{
+			Message:      openrouter.Message{Role: "assistant", Content: reply},
+			FinishReason: "stop",
+		}},
+	})
+}
+
+func (f *FakeOpenRouter) handleModels(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, openrouter.ModelsResponse{Data: []openrouter.Model{{
+		ID:            "anthropic/claude-3.5-sonnet",
+		Name:          "Claude 3.5 Sonnet",
+		ContextLength: 200000,
+		Architecture:  openrouter.ModelArchitecture{Modality: "text->text"},
+	}}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,221 @@
+package testutil
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// FakeOrganizationRepository is an in-memory repository.OrganizationRepository
+// covering organizations, memberships, invitations and keys, with the same checks
+// as the SQL. Other methods panic.
+type FakeOrganizationRepository struct {
+	repository.OrganizationRepository
+
+	mu          sync.Mutex
+	emails      map[uuid.UUID]string
+	orgs        map[uuid.UUID]*models.Organization
+	members     map[uuid.UUID]*models.OrganizationMember
+	invitations []*models.OrganizationInvitation
+}
+
+// NewFakeOrganizationRepository returns a FakeOrganizationRepository that knows
+// the emails of users.
+func NewFakeOrganizationRepository(users ...*models.User) *FakeOrganizationRepository {
+	f := &FakeOrganizationRepository{
+		emails:  make(map[uuid.UUID]string),
+		orgs:    make(map[uuid.UUID]*models.Organization),
+		members: make(map[uuid.UUID]*models.OrganizationMember),
+	}
+	for _, user := range users {
+		f.emails[user.ID] = user.Email
+	}
+	return f
+}
+
+// Create stores the organization with ownerID as its owner.
+func (f *FakeOrganizationRepository) Create(ctx context.Context, org *models.Organization, ownerID uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if org.ID == uuid.Nil {
+		org.ID = uuid.New()
+	}
+	if err := f.addMember(org.ID, ownerID, models.OrgRoleOwner); err != nil {
+		return err
+	}
+	org.CreatedAt = time.Now()
+	copied := *org
+	f.orgs[org.ID] = &copied
+	return nil
+}
+
+// GetByID returns a copy of the organization with id.
+func (f *FakeOrganizationRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Organization, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	org, ok := f.orgs[id]
+	if !ok {
+		return nil, repository.ErrOrganizationNotFound
+	}
+	copied := *org
+	return &copied, nil
+}
+
+// GetMembership returns a copy of the user's membership.
+func (f *FakeOrganizationRepository) GetMembership(ctx context.Context, userID uuid.UUID) (*models.OrganizationMember, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	member, ok := f.members[userID]
+	if !ok {
+		return nil, repository.ErrNotOrgMember
+	}
+	copied := *member
+	return &copied, nil
+}
+
+// ListMembers returns copies of the organization's members.
+func (f *FakeOrganizationRepository) ListMembers(ctx context.Context, orgID uuid.UUID) ([]*models.OrganizationMember, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	members := make([]*models.OrganizationMember, 0)
+	for _, member := range f.members {
+		if member.OrgID == orgID {
+			copied := *member
+			members = append(members, &copied)
+		}
+	}
+	return members, nil
+}
+
+// CreateInvitation stores a pending invitation, revoking an expired one for the
+// same email first.
+func (f *FakeOrganizationRepository) CreateInvitation(ctx context.Context, invitation *models.OrganizationInvitation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for _, inv := range f.pending(invitation.OrgID) {
+		if !strings.EqualFold(inv.Email, invitation.Email) {
+			continue
+		}
+		if inv.ExpiresAt.After(now) {
+			return repository.ErrInvitationExists
+		}
+		inv.RevokedAt = &now
+	}
+	if invitation.ID == uuid.Nil {
+		invitation.ID = uuid.New()
+	}
+	invitation.CreatedAt = now
+	copied := *invitation
+	f.invitations = append(f.invitations, &copied)
+	return nil
+}
+
+// ListPendingInvitations returns copies of the organization's invitations that
+// were neither accepted nor revoked.
+func (f *FakeOrganizationRepository) ListPendingInvitations(ctx context.Context, orgID uuid.UUID) ([]*models.OrganizationInvitation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	invitations := make([]*models.OrganizationInvitation, 0)
+	for _, inv := range f.pending(orgID) {
+		copied := *inv
+		invitations = append(invitations, &copied)
+	}
+	return invitations, nil
+}
+
+// AcceptInvitation accepts the pending invitation with tokenHash for the user.
+func (f *FakeOrganizationRepository) AcceptInvitation(ctx context.Context, userID uuid.UUID, tokenHash string) (*models.OrganizationMember, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, inv := range f.invitations {
+		if inv.TokenHash != tokenHash || inv.AcceptedAt != nil || inv.RevokedAt != nil {
+			continue
+		}
+		if !time.Now().Before(inv.ExpiresAt) {
+			return nil, repository.ErrInvitationExpired
+		}
+		if !strings.EqualFold(f.emails[userID], inv.Email) {
+			return nil, repository.ErrInvitationEmailMismatch
+		}
+		if err := f.addMember(inv.OrgID, userID, inv.Role); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		inv.AcceptedAt = &now
+		copied := *f.members[userID]
+		return &copied, nil
+	}
+	return nil, repository.ErrInvitationNotFound
+}
+
+// RevokeInvitation revokes a pending invitation of the organization.
+func (f *FakeOrganizationRepository) RevokeInvitation(ctx context.Context, orgID, invitationID uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, inv := range f.pending(orgID) {
+		if inv.ID == invitationID {
+			now := time.Now()
+			inv.RevokedAt = &now
+			return nil
+		}
+	}
+	return repository.ErrInvitationNotFound
+}
+
+// UpdateAPIKeys replaces the organization's encrypted keys.
+func (f *FakeOrganizationRepository) UpdateAPIKeys(ctx context.Context, orgID uuid.UUID, openRouterKey, kieKey *string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	org, ok := f.orgs[orgID]
+	if !ok {
+		return repository.ErrOrganizationNotFound
+	}
+	org.OpenRouterAPIKey, org.KIEAPIKey = openRouterKey, kieKey
+	return nil
+}
+
+// GetAPIKeysForUser returns the encrypted keys of the user's organization.
+func (f *FakeOrganizationRepository) GetAPIKeysForUser(ctx context.Context, userID uuid.UUID) (openRouterKey, kieKey *string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	member, ok := f.members[userID]
+	if !ok {
+		return nil, nil, nil
+	}
+	org := f.orgs[member.OrgID]
+	return org.OpenRouterAPIKey, org.KIEAPIKey, nil
+}
+
+// pending returns the organization's invitations that were neither accepted nor
+// revoked. f.mu must be held.
+func (f *FakeOrganizationRepository) pending(orgID uuid.UUID) []*models.OrganizationInvitation {
+	var pending []*models.OrganizationInvitation
+	for _, inv := range f.invitations {
+		if inv.OrgID == orgID && inv.AcceptedAt == nil && inv.RevokedAt == nil {
+			pending = append(pending, inv)
+		}
+	}
+	return pending
+}
+
+// addMember adds a membership unless the user already has one. f.mu must be held.
+func (f *FakeOrganizationRepository) addMember(orgID, userID uuid.UUID, role string) error {
+	if _, ok := f.members[userID]; ok {
+		return repository.ErrAlreadyOrgMember
+	}
+	f.members[userID] = &models.OrganizationMember{
+		OrgID:     orgID,
+		UserID:    userID,
+		Email:     f.emails[userID],
+		Role:      role,
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package testutil
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+)
+
+// fakeR2Bucket is the bucket name of the client returned by FakeR2.Config.
+const fakeR2Bucket = "ugc-test"
+
+// FakeR2 emulates the S3 object endpoints of R2: PUT, GET, HEAD and DELETE of
+// an object, and multipart uploads. Objects are kept in memory.
+type FakeR2 struct {
+	Server *httptest.Server
+
+	// FailPart, when set, makes the upload of that part number fail with a
+	// non-retryable error.
+	FailPart int
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads []*FakeR2Upload
+}
+
+// FakeR2Upload is a multipart upload received by FakeR2.
+type FakeR2Upload struct {
+	ID  string
+	Key string
+	// PartNumbers lists the uploaded parts in the order they arrived
+	PartNumbers []int
+	Completed   bool
+	Aborted     bool
+
+	parts map[int][]byte
+}
+
+// NewFakeR2 starts a fake R2 server, closed when the test ends. Create clients
+// with r2.NewClient(ctx, fake.Config()).
+func NewFakeR2(t testing.TB) *FakeR2 {
+	t.Helper()
+
+	f := &FakeR2{objects: make(map[string][]byte)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handleObject))
+	t.Cleanup(f.Server.Close)
+	return f
+}
+
+// Config returns an r2.Config pointing at the fake server.
+func (f *FakeR2) Config() r2.Config {
+	return r2.Config{
+		AccountID:       "test-account",
+		AccessKeyID:     "test-access-key",
+		SecretAccessKey: "test-secret-key",
+		BucketName:      fakeR2Bucket,
+		Endpoint:        f.Server.URL,
+	}
+}
+
+// Object returns the stored object with key and whether it exists.
+func (f *FakeR2) Object(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[key]
+	return data, ok
+}
+
+// Uploads returns a copy of the multipart uploads started so far, oldest first.
+func (f *FakeR2) Uploads() []FakeR2Upload {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	uploads := make([]FakeR2Upload, len(f.uploads))
+	for i, u := range f.uploads {
+		uploads[i] = *u
+		uploads[i].PartNumbers = slices.Clone(u.PartNumbers)
+		uploads[i].parts = nil
+	}
+	return uploads
+}
+
+func (f *FakeR2) handleObject(w http.ResponseWriter, r *http.Request) {
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+fakeR2Bucket+"/")
+	if !ok || key == "" {
+		http.Error(w, "unknown bucket", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := r.URL.Query()
+	if query.Has("uploads") || query.Has("uploadId") {
+		f.handleMultipart(w, r, key)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = data
+		w.Header().Set("ETag", `"fake-etag"`)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, key, time.Time{}, bytes.NewReader(data))
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not supported by the fake", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMultipart serves CreateMultipartUpload, UploadPart,
+// CompleteMultipartUpload and AbortMultipartUpload. Like S3, completing an
+// upload requires its parts listed in ascending order with their ETags.
+func (f *FakeR2) handleMultipart(w http.ResponseWriter, r *http.Request, key string) {
+	query := r.URL.Query()
+	if query.Has("uploads") {
+		upload := &FakeR2Upload{ID: fmt.Sprintf("upload-%d", len(f.uploads)+1), Key: key, parts: make(map[int][]byte)}
+		f.uploads = append(f.uploads, upload)
+		writeS3XML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: fakeR2Bucket, Key: key, UploadId: upload.ID})
+		return
+	}
+
+	var upload *FakeR2Upload
+	for _, u := range f.uploads {
+		if u.ID == query.Get("uploadId") && u.Key == key && !u.Completed && !u.Aborted {
+			upload = u
+		}
+	}
+	if upload == nil {
+		writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		partNumber, err := strconv.Atoi(query.Get("partNumber"))
+		if err != nil || partNumber < 1 {
+			writeS3Error(w, http.StatusBadRequest, "InvalidArgument")
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		upload.PartNumbers = append(upload.PartNumbers, partNumber)
+		if partNumber == f.FailPart {
+			writeS3Error(w, http.StatusForbidden, "AccessDenied")
+			return
+		}
+		upload.parts[partNumber] = data
+		w.Header().Set("ETag", fakeR2ETag(data))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPost:
+		var complete struct {
+			Parts []struct {
+				ETag       string
+				PartNumber int
+			} `xml:"Part"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&complete); err != nil || len(complete.Parts) == 0 {
+			writeS3Error(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		var object []byte
+		for i, part := range complete.Parts {
+			if i > 0 && part.PartNumber <= complete.Parts[i-1].PartNumber {
+				writeS3Error(w, http.StatusBadRequest, "InvalidPartOrder")
+				return
+			}
+			data, ok := upload.parts[part.PartNumber]
+			if !ok || part.ETag != fakeR2ETag(data) {
+				writeS3Error(w, http.StatusBadRequest, "InvalidPart")
+				return
+			}
+			object = append(object, data...)
+		}
+		upload.Completed = true
+		f.objects[key] = object
+		writeS3XML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: fakeR2Bucket, Key: key, ETag: fakeR2ETag(object)})
+	case http.MethodDelete:
+		upload.Aborted = true
+		upload.parts = nil
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not supported by the fake", http.StatusMethodNotAllowed)
+	}
+}
+
+// fakeR2ETag returns the quoted MD5 ETag S3 gives data.
+func fakeR2ETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeS3XML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s from the fake</Message></Error>", code, code)
+}
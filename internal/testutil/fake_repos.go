@@ -0,0 +1,368 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// FakeRuntimeSettingRepository is an in-memory repository.RuntimeSettingRepository
+// that counts reads.
+type FakeRuntimeSettingRepository struct {
+	mu       sync.Mutex
+	settings models.RuntimeSettings
+	gets     int
+
+	// OnGet, when set, runs in every Get after the settings are read, e.g. to
+	// hold a read of the old settings in flight while the test updates them.
+	OnGet func()
+}
+
+// NewFakeRuntimeSettingRepository returns a FakeRuntimeSettingRepository holding settings.
+func NewFakeRuntimeSettingRepository(settings models.RuntimeSettings) *FakeRuntimeSettingRepository {
+	return &FakeRuntimeSettingRepository{settings: settings}
+}
+
+// Get returns a copy of the stored settings.
+func (f *FakeRuntimeSettingRepository) Get(ctx context.Context) (*models.RuntimeSettings, error) {
+	f.mu.Lock()
+	f.gets++
+	settings := f.settings
+	onGet := f.OnGet
+	f.mu.Unlock()
+
+	if onGet != nil {
+		onGet()
+	}
+	return &settings, nil
+}
+
+// Update stores the fields set in input.
+func (f *FakeRuntimeSettingRepository) Update(ctx context.Context, input models.UpdateRuntimeSettingsInput, updatedBy uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if input.JobIntakePaused != nil {
+		f.settings.JobIntakePaused = *input.JobIntakePaused
+	}
+	if input.BannerMessage != nil {
+		f.settings.BannerMessage = *input.BannerMessage
+	}
+	now := time.Now()
+	f.settings.UpdatedAt = &now
+	return nil
+}
+
+// Gets returns how many times Get was called.
+func (f *FakeRuntimeSettingRepository) Gets() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.gets
+}
+
+// FakeJobScheduleRepository is a repository.JobScheduleRepository with no due
+// schedules that counts ListDue calls. Other methods panic.
+type FakeJobScheduleRepository struct {
+	repository.JobScheduleRepository
+
+	mu       sync.Mutex
+	listDues int
+}
+
+// ListDue returns no schedules.
+func (f *FakeJobScheduleRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*models.JobSchedule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listDues++
+	return nil, nil
+}
+
+// ListDues returns how many times ListDue was called.
+func (f *FakeJobScheduleRepository) ListDues() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.listDues
+}
+
+// HookedJobRepository wraps a repository.JobRepository and runs a hook after a
+// job is loaded, e.g. to change the job while a callback holds a stale copy.
+type HookedJobRepository struct {
+	repository.JobRepository
+
+	// AfterGetBySunoTaskID, when set, runs after GetBySunoTaskID loads a job
+	// and before the job is returned.
+	AfterGetBySunoTaskID func(job *models.Job)
+}
+
+// GetBySunoTaskID loads the job from the wrapped repository, then runs AfterGetBySunoTaskID.
+func (r *HookedJobRepository) GetBySunoTaskID(ctx context.Context, taskID string) (*models.Job, error) {
+	job, err := r.JobRepository.GetBySunoTaskID(ctx, taskID)
+	if err == nil && r.AfterGetBySunoTaskID != nil {
+		r.AfterGetBySunoTaskID(job)
+	}
+	return job, err
+}
+
+// FakePendingTaskRepository is an in-memory repository.PendingTaskRepository.
+type FakePendingTaskRepository struct {
+	mu    sync.Mutex
+	tasks map[uuid.UUID]*models.PendingTask
+}
+
+// NewFakePendingTaskRepository returns an empty FakePendingTaskRepository.
+func NewFakePendingTaskRepository() *FakePendingTaskRepository {
+	return &FakePendingTaskRepository{tasks: make(map[uuid.UUID]*models.PendingTask)}
+}
+
+// Create stores a copy of task, due immediately.
+func (f *FakePendingTaskRepository) Create(ctx context.Context, task *models.PendingTask) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	task.ID = uuid.New()
+	task.NextAttemptAt = time.Now()
+	task.CreatedAt = task.NextAttemptAt
+	copied := *task
+	f.tasks[task.ID] = &copied
+	return nil
+}
+
+// ClaimDue returns copies of up to limit due tasks, incrementing their attempts.
+// Unlike the SQL it does not lease them.
+func (f *FakePendingTaskRepository) ClaimDue(ctx context.Context, limit int) ([]*models.PendingTask, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	claimed := make([]*models.PendingTask, 0)
+	for _, task := range f.tasks {
+		if len(claimed) == limit {
+			break
+		}
+		if task.NextAttemptAt.After(now) {
+			continue
+		}
+		task.Attempts++
+		copied := *task
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+// Reschedule records a failed attempt.
+func (f *FakePendingTaskRepository) Reschedule(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if task, ok := f.tasks[id]; ok {
+		task.NextAttemptAt = nextAttemptAt
+		task.LastError = &lastError
+	}
+	return nil
+}
+
+// Delete removes a task.
+func (f *FakePendingTaskRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.tasks, id)
+	return nil
+}
+
+// Tasks returns copies of the stored tasks.
+func (f *FakePendingTaskRepository) Tasks() []*models.PendingTask {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tasks := make([]*models.PendingTask, 0, len(f.tasks))
+	for _, task := range f.tasks {
+		copied := *task
+		tasks = append(tasks, &copied)
+	}
+	return tasks
+}
+
+// MakeDue makes every stored task due now.
+func (f *FakePendingTaskRepository) MakeDue() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, task := range f.tasks {
+		task.NextAttemptAt = time.Now()
+	}
+}
+
+// FakePlatformQuotaRepository is an in-memory repository.PlatformQuotaRepository.
+// Like the real one it serializes reservations, and it ignores platform spend and
+// the quota windows.
+type FakePlatformQuotaRepository struct {
+	mu           sync.Mutex
+	reservations map[uuid.UUID]*models.PlatformQuotaReservation
+}
+
+// NewFakePlatformQuotaRepository returns an empty FakePlatformQuotaRepository.
+func NewFakePlatformQuotaRepository() *FakePlatformQuotaRepository {
+	return &FakePlatformQuotaRepository{reservations: make(map[uuid.UUID]*models.PlatformQuotaReservation)}
+}
+
+// Reserve records reservation unless it takes the user over limits.
+func (f *FakePlatformQuotaRepository) Reserve(ctx context.Context, reservation *models.PlatformQuotaReservation, limits models.PlatformQuotaLimits) (*models.PlatformQuotaUsage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	usage := f.usage(reservation.UserID)
+	if reservation.OpenRouterJobs > 0 && usage.OpenRouterJobs+reservation.OpenRouterJobs > limits.OpenRouterJobs {
+		return usage, repository.ErrPlatformQuotaExceeded
+	}
+	if reservation.KIECredits > 0 && usage.KIECredits+reservation.KIECredits > limits.KIECredits {
+		return usage, repository.ErrPlatformQuotaExceeded
+	}
+
+	if reservation.ID == uuid.Nil {
+		reservation.ID = uuid.New()
+	}
+	stored := *reservation
+	f.reservations[stored.ID] = &stored
+	return usage, nil
+}
+
+// Release returns part of a reservation.
+func (f *FakePlatformQuotaRepository) Release(ctx context.Context, id uuid.UUID, openRouterJobs, kieCredits int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r, ok := f.reservations[id]; ok {
+		r.OpenRouterJobs = max(r.OpenRouterJobs-openRouterJobs, 0)
+		r.KIECredits = max(r.KIECredits-kieCredits, 0)
+	}
+	return nil
+}
+
+// Usage returns the quota userID has reserved.
+func (f *FakePlatformQuotaRepository) Usage(userID uuid.UUID) models.PlatformQuotaUsage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return *f.usage(userID)
+}
+
+func (f *FakePlatformQuotaRepository) usage(userID uuid.UUID) *models.PlatformQuotaUsage {
+	usage := &models.PlatformQuotaUsage{}
+	for _, r := range f.reservations {
+		if r.UserID == userID {
+			usage.OpenRouterJobs += r.OpenRouterJobs
+			usage.KIECredits += r.KIECredits
+		}
+	}
+	return usage
+}
+
+// FakeSystemPromptRepository is an in-memory repository.SystemPromptRepository
+// that counts reads and keeps revisions the way the database does.
+type FakeSystemPromptRepository struct {
+	mu        sync.Mutex
+	prompts   map[string]models.SystemPrompt
+	revisions []models.SystemPromptRevision // Oldest first
+	reads     int
+
+	// OnGetByType, when set, runs in every GetByType after the prompt is read,
+	// e.g. to hold a read of the old prompt in flight while the test updates it.
+	OnGetByType func()
+}
+
+// NewFakeSystemPromptRepository returns a FakeSystemPromptRepository holding
+// contents by prompt type.
+func NewFakeSystemPromptRepository(contents map[string]string) *FakeSystemPromptRepository {
+	f := &FakeSystemPromptRepository{prompts: make(map[string]models.SystemPrompt)}
+	for promptType, content := range contents {
+		f.prompts[promptType] = models.SystemPrompt{ID: uuid.New(), PromptType: promptType, PromptContent: content}
+	}
+	return f
+}
+
+// GetByType returns a copy of the prompt, or repository.ErrSystemPromptNotFound.
+func (f *FakeSystemPromptRepository) GetByType(ctx context.Context, promptType string) (*models.SystemPrompt, error) {
+	f.mu.Lock()
+	f.reads++
+	prompt, ok := f.prompts[promptType]
+	onGet := f.OnGetByType
+	f.mu.Unlock()
+
+	if onGet != nil {
+		onGet()
+	}
+	if !ok {
+		return nil, repository.ErrSystemPromptNotFound
+	}
+	return &prompt, nil
+}
+
+// GetAll returns copies of all prompts.
+func (f *FakeSystemPromptRepository) GetAll(ctx context.Context) ([]models.SystemPrompt, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reads++
+	prompts := make([]models.SystemPrompt, 0, len(f.prompts))
+	for _, prompt := range f.prompts {
+		prompts = append(prompts, prompt)
+	}
+	return prompts, nil
+}
+
+// Update replaces the prompt's content, recording the previous content as a
+// revision when it changes.
+func (f *FakeSystemPromptRepository) Update(ctx context.Context, promptType string, content string, updatedBy uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	prompt, ok := f.prompts[promptType]
+	if !ok {
+		return repository.ErrSystemPromptNotFound
+	}
+	if prompt.PromptContent != content {
+		f.revisions = append(f.revisions, models.SystemPromptRevision{
+			ID:            uuid.New(),
+			PromptType:    promptType,
+			PromptContent: prompt.PromptContent,
+			AuthoredBy:    prompt.UpdatedBy,
+			ReplacedBy:    &updatedBy,
+			CreatedAt:     time.Now(),
+		})
+	}
+	prompt.PromptContent = content
+	prompt.UpdatedBy = &updatedBy
+	prompt.UpdatedAt = time.Now()
+	f.prompts[promptType] = prompt
+	return nil
+}
+
+// ListRevisions returns a page of the prompt's revisions, newest first.
+func (f *FakeSystemPromptRepository) ListRevisions(ctx context.Context, promptType string, page, perPage int) ([]models.SystemPromptRevision, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var revisions []models.SystemPromptRevision
+	for i := len(f.revisions) - 1; i >= 0; i-- {
+		if f.revisions[i].PromptType == promptType {
+			revisions = append(revisions, f.revisions[i])
+		}
+	}
+	total := int64(len(revisions))
+	start := min((page-1)*perPage, len(revisions))
+	return revisions[start:min(start+perPage, len(revisions))], total, nil
+}
+
+// GetRevision returns one of the prompt's revisions, or repository.ErrSystemPromptRevisionNotFound.
+func (f *FakeSystemPromptRepository) GetRevision(ctx context.Context, promptType string, revisionID uuid.UUID) (*models.SystemPromptRevision, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, revision := range f.revisions {
+		if revision.ID == revisionID && revision.PromptType == promptType {
+			return &revision, nil
+		}
+	}
+	return nil, repository.ErrSystemPromptRevisionNotFound
+}
+
+// Reads returns how many times GetByType and GetAll were called.
+func (f *FakeSystemPromptRepository) Reads() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reads
+}
@@ -0,0 +1,353 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// FakeUserRepository is an in-memory repository.UserRepository covering
+// registration, the lookups and the admin updates, with the same last-admin
+// guard as the SQL. Other methods panic.
+type FakeUserRepository struct {
+	repository.UserRepository
+
+	mu    sync.Mutex
+	users map[uuid.UUID]*models.User
+}
+
+// NewFakeUserRepository returns a FakeUserRepository holding users.
+func NewFakeUserRepository(users ...*models.User) *FakeUserRepository {
+	f := &FakeUserRepository{users: make(map[uuid.UUID]*models.User)}
+	for _, user := range users {
+		f.users[user.ID] = user
+	}
+	return f
+}
+
+// GetByID returns a copy of the user with id, deleted or not.
+func (f *FakeUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[id]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+// Create stores a copy of user, as a user by default. An email already stored
+// with the same case fails like the unique constraint does.
+func (f *FakeUserRepository) Create(ctx context.Context, user *models.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, existing := range f.users {
+		if existing.Email == user.Email {
+			return fmt.Errorf("failed to create user: duplicate email %s", user.Email)
+		}
+	}
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	user.CreatedAt, user.UpdatedAt = time.Now(), time.Now()
+	copied := *user
+	f.users[user.ID] = &copied
+	return nil
+}
+
+// GetByEmail returns a copy of the user with email, ignoring case like the SQL
+// lookup. An exact match wins over one differing only in case.
+func (f *FakeUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found *models.User
+	for _, user := range f.users {
+		if user.Email == email {
+			found = user
+			break
+		}
+		if strings.EqualFold(user.Email, email) {
+			found = user
+		}
+	}
+	if found == nil {
+		return nil, repository.ErrUserNotFound
+	}
+	copied := *found
+	return &copied, nil
+}
+
+// UpdatePassword replaces the password hash of a user.
+func (f *FakeUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[id]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	user.PasswordHash = passwordHash
+	return nil
+}
+
+// ListDeletedBefore returns the IDs of the users deleted before deletedBefore,
+// in no particular order.
+func (f *FakeUserRepository) ListDeletedBefore(ctx context.Context, deletedBefore time.Time, limit int) ([]uuid.UUID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := make([]uuid.UUID, 0)
+	for _, user := range f.users {
+		if user.DeletedAt != nil && user.DeletedAt.Before(deletedBefore) && len(ids) < limit {
+			ids = append(ids, user.ID)
+		}
+	}
+	return ids, nil
+}
+
+// List returns the users that are not deleted, in no particular order; the
+// filter and page are ignored.
+func (f *FakeUserRepository) List(ctx context.Context, filter models.UserFilter, page, perPage int) ([]*models.User, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var users []*models.User
+	for _, user := range f.users {
+		if !user.IsDeleted() {
+			copied := *user
+			users = append(users, &copied)
+		}
+	}
+	return users, int64(len(users)), nil
+}
+
+// SetRole changes a user's role. Demoting the last active admin returns
+// repository.ErrLastAdmin.
+func (f *FakeUserRepository) SetRole(ctx context.Context, id uuid.UUID, role string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[id]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	if role != models.RoleAdmin && f.isLastActiveAdmin(user) {
+		return repository.ErrLastAdmin
+	}
+	user.Role = role
+	return nil
+}
+
+// SetDisabled disables or re-enables a user. Disabling the last active admin
+// returns repository.ErrLastAdmin.
+func (f *FakeUserRepository) SetDisabled(ctx context.Context, id uuid.UUID, disabled bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[id]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	if disabled && f.isLastActiveAdmin(user) {
+		return repository.ErrLastAdmin
+	}
+	user.Disabled = disabled
+	return nil
+}
+
+// isLastActiveAdmin reports whether user is an active admin and no other is.
+func (f *FakeUserRepository) isLastActiveAdmin(user *models.User) bool {
+	if user.Role != models.RoleAdmin || user.Disabled {
+		return false
+	}
+	for _, other := range f.users {
+		if other.ID != user.ID && other.Role == models.RoleAdmin && !other.Disabled && !other.IsDeleted() {
+			return false
+		}
+	}
+	return true
+}
+
+// FakeUserSpendRepository is a repository.UserSpendRepository with no recorded
+// spend. Other methods panic.
+type FakeUserSpendRepository struct {
+	repository.UserSpendRepository
+}
+
+// SummarizeSince returns no spend for anyone.
+func (FakeUserSpendRepository) SummarizeSince(ctx context.Context, userIDs []uuid.UUID, since time.Time) (map[uuid.UUID]*models.SpendSummary, error) {
+	return map[uuid.UUID]*models.SpendSummary{}, nil
+}
+
+// FakeRefreshTokenRepository is an in-memory repository.RefreshTokenRepository
+// with the same rotation semantics as the SQL.
+type FakeRefreshTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]*models.RefreshToken
+}
+
+// NewFakeRefreshTokenRepository returns an empty FakeRefreshTokenRepository.
+func NewFakeRefreshTokenRepository() *FakeRefreshTokenRepository {
+	return &FakeRefreshTokenRepository{tokens: make(map[uuid.UUID]*models.RefreshToken)}
+}
+
+// Create stores token.
+func (f *FakeRefreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if token.ID == uuid.Nil {
+		token.ID = uuid.New()
+	}
+	token.CreatedAt = time.Now()
+	copied := *token
+	f.tokens[token.ID] = &copied
+	return nil
+}
+
+// GetByHash returns a copy of the token with tokenHash, revoked or not.
+func (f *FakeRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, token := range f.tokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrRefreshTokenNotFound
+}
+
+// Rotate revokes oldID and stores next. It returns
+// repository.ErrRefreshTokenRevoked, storing nothing, if oldID was already revoked.
+func (f *FakeRefreshTokenRepository) Rotate(ctx context.Context, oldID uuid.UUID, next *models.RefreshToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	old, ok := f.tokens[oldID]
+	if !ok || old.RevokedAt != nil {
+		return repository.ErrRefreshTokenRevoked
+	}
+	if next.ID == uuid.Nil {
+		next.ID = uuid.New()
+	}
+	now := time.Now()
+	next.CreatedAt = now
+	copied := *next
+	f.tokens[next.ID] = &copied
+	old.RevokedAt = &now
+	old.ReplacedBy = &copied.ID
+	return nil
+}
+
+// Revoke revokes the token with id.
+func (f *FakeRefreshTokenRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	return f.revokeWhere(func(token *models.RefreshToken) bool { return token.ID == id })
+}
+
+// RevokeFamily revokes every token of familyID.
+func (f *FakeRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	return f.revokeWhere(func(token *models.RefreshToken) bool { return token.FamilyID == familyID })
+}
+
+// RevokeAllForUser revokes every token of userID.
+func (f *FakeRefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	return f.revokeWhere(func(token *models.RefreshToken) bool { return token.UserID == userID })
+}
+
+// revokeWhere revokes the active tokens matching match.
+func (f *FakeRefreshTokenRepository) revokeWhere(match func(*models.RefreshToken) bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for _, token := range f.tokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// Tokens returns copies of every stored token.
+func (f *FakeRefreshTokenRepository) Tokens() []models.RefreshToken {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tokens := make([]models.RefreshToken, 0, len(f.tokens))
+	for _, token := range f.tokens {
+		tokens = append(tokens, *token)
+	}
+	return tokens
+}
+
+// FakePasswordResetRepository is an in-memory repository.PasswordResetRepository
+// with the same single-use and expiry rules as the SQL.
+type FakePasswordResetRepository struct {
+	mu     sync.Mutex
+	resets map[string]*fakePasswordReset
+}
+
+type fakePasswordReset struct {
+	userID    uuid.UUID
+	expiresAt time.Time
+	used      bool
+}
+
+// NewFakePasswordResetRepository returns an empty FakePasswordResetRepository.
+func NewFakePasswordResetRepository() *FakePasswordResetRepository {
+	return &FakePasswordResetRepository{resets: make(map[string]*fakePasswordReset)}
+}
+
+// Create records a reset token hash for userID.
+func (f *FakePasswordResetRepository) Create(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resets[tokenHash] = &fakePasswordReset{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+// Consume marks the reset token as used and returns its user ID, or
+// repository.ErrPasswordResetNotFound if it is unknown, expired or used.
+func (f *FakePasswordResetRepository) Consume(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reset, ok := f.resets[tokenHash]
+	if !ok || reset.used || !reset.expiresAt.After(time.Now()) {
+		return uuid.Nil, repository.ErrPasswordResetNotFound
+	}
+	reset.used = true
+	return reset.userID, nil
+}
+
+// ExpireAll moves the expiry of every stored token into the past.
+func (f *FakePasswordResetRepository) ExpireAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, reset := range f.resets {
+		reset.expiresAt = time.Now().Add(-time.Second)
+	}
+}
+
+// AccessToken signs an access token for user with secret, as the auth service
+// issues on login, valid for an hour.
+func AccessToken(t testing.TB, secret string, user *models.User) string {
+	t.Helper()
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": user.ID.String(),
+		"email":   user.Email,
+		"role":    user.Role,
+		"exp":     now.Add(time.Hour).Unix(),
+		"iat":     now.Unix(),
+		"nbf":     now.Unix(),
+		"sub":     user.ID.String(),
+	})
+	signed, err := token.SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("failed to sign access token: %v", err)
+	}
+	return signed
+}
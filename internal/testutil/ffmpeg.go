@@ -0,0 +1,77 @@
+package testutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ffmpegStubFormat appends its arguments as one line to the call log and writes
+// a small placeholder file to its last argument, which is the output path in
+// every command ffmpeg.Processor runs.
+const ffmpegStubFormat = `#!/bin/sh
+printf '%%s\n' "$*" >> '%s'
+for last; do :; done
+printf 'fake video\n' > "$last"
+`
+
+// ffprobeStubFormat prints a fixed duration in seconds, as
+// "ffprobe -show_entries format=duration" does.
+const ffprobeStubFormat = `#!/bin/sh
+echo %.3f
+`
+
+// FakeFFmpeg is the ffmpeg stub installed by InstallFakeFFmpeg.
+type FakeFFmpeg struct {
+	logPath string
+}
+
+// InstallFakeFFmpeg puts ffmpeg and ffprobe stubs first on PATH for the rest of
+// the test, so ffmpeg.Processor runs without real encodes. ffprobe reports
+// duration for every file.
+func InstallFakeFFmpeg(t testing.TB, duration time.Duration) *FakeFFmpeg {
+	t.Helper()
+
+	dir := t.TempDir()
+	fake := &FakeFFmpeg{logPath: filepath.Join(dir, "ffmpeg-calls.log")}
+	stubs := map[string]string{
+		"ffmpeg":  fmt.Sprintf(ffmpegStubFormat, fake.logPath),
+		"ffprobe": fmt.Sprintf(ffprobeStubFormat, duration.Seconds()),
+	}
+	for name, script := range stubs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatalf("failed to write %s stub: %v", name, err)
+		}
+	}
+
+	t.Setenv("PATH", strings.Join([]string{dir, os.Getenv("PATH")}, string(os.PathListSeparator)))
+	return fake
+}
+
+// Calls returns the arguments of every ffmpeg run so far, one space-joined line
+// per run, in order.
+func (f *FakeFFmpeg) Calls(t testing.TB) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(f.logPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("failed to read ffmpeg call log: %v", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// Reset forgets the calls made so far.
+func (f *FakeFFmpeg) Reset(t testing.TB) {
+	t.Helper()
+
+	if err := os.Remove(f.logPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("failed to reset ffmpeg call log: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+// Package testutil provides the pieces needed to run the job pipeline outside
+// production: a migrated Postgres database, a Redis-backed asynq client,
+// fake OpenRouter and KIE servers with scriptable responses, an in-memory R2
+// bucket, and an ffmpeg stub on PATH. It also holds the in-memory provider clients (FakeChatClient,
+// FakeMusicClient, FakeImageClient) for unit tests that inject clients, and
+// in-memory repositories for the few unit tests that need them; all test
+// fakes live here rather than in a separate package.
+//
+// Postgres comes from TEST_DATABASE_URL, e.g. a local container; NewDB skips the
+// calling test when it is unset. Redis is an in-process miniredis.
+package testutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/database"
+)
+
+// EnvDatabaseURL names the Postgres used by NewDB.
+const EnvDatabaseURL = "TEST_DATABASE_URL"
+
+// setupTimeout bounds connecting to and migrating the test services.
+const setupTimeout = 30 * time.Second
+
+// NewDB creates a schema of its own in TEST_DATABASE_URL, runs the migrations
+// in it and drops it when the test ends, so tests never touch each other's rows
+// or any data already in the database. The test is skipped when
+// TEST_DATABASE_URL is unset.
+func NewDB(t testing.TB) *database.DB {
+	t.Helper()
+	return newDB(t, database.DefaultPoolConfig())
+}
+
+// NewRecordedDB is NewDB with every statement run after the migrations
+// recorded, e.g. to count the writes a handler makes.
+func NewRecordedDB(t testing.TB) (*database.DB, *StatementRecorder) {
+	t.Helper()
+
+	recorder := &StatementRecorder{}
+	cfg := database.DefaultPoolConfig()
+	cfg.Tracer = recorder
+	db := newDB(t, cfg)
+	recorder.Reset()
+	return db, recorder
+}
+
+// newDB implements NewDB, connecting to the test schema with cfg.
+func newDB(t testing.TB, cfg database.PoolConfig) *database.DB {
+	t.Helper()
+
+	baseURL := os.Getenv(EnvDatabaseURL)
+	if baseURL == "" {
+		t.Skipf("%s is not set", EnvDatabaseURL)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), setupTimeout)
+	defer cancel()
+
+	admin, err := database.NewWithConfig(ctx, baseURL, database.DefaultPoolConfig(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	schema := "test_" + randomHex(t, 8)
+	if _, err := admin.Pool().Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close()
+		t.Fatalf("failed to create test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Pool().Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("failed to drop test schema %s: %v", schema, err)
+		}
+		admin.Close()
+	})
+
+	schemaURL, err := withSearchPath(baseURL, schema)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", EnvDatabaseURL, err)
+	}
+	db, err := database.NewWithConfig(ctx, schemaURL, cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to connect to test schema: %v", err)
+	}
+	// Registered after the schema cleanup, so it runs first
+	t.Cleanup(db.Close)
+
+	if err := database.NewMigrator(db, zap.NewNop()).Migrate(ctx); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return db
+}
+
+// StatementRecorder is a pgx.QueryTracer recording the SQL of every statement.
+type StatementRecorder struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+// TraceQueryStart records the statement.
+func (r *StatementRecorder) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, strings.Join(strings.Fields(data.SQL), " "))
+	return ctx
+}
+
+// TraceQueryEnd does nothing; failed statements are recorded too.
+func (r *StatementRecorder) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+}
+
+// Statements returns the SQL recorded since the last Reset, oldest first, with
+// whitespace collapsed to single spaces.
+func (r *StatementRecorder) Statements() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.statements)
+}
+
+// Reset forgets the recorded statements.
+func (r *StatementRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = nil
+}
+
+// withSearchPath returns databaseURL with its search_path set to schema. It
+// accepts both URL and keyword/value connection strings.
+func withSearchPath(databaseURL, schema string) (string, error) {
+	if !strings.HasPrefix(databaseURL, "postgres://") && !strings.HasPrefix(databaseURL, "postgresql://") {
+		return databaseURL + " search_path=" + schema, nil
+	}
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(t testing.TB, n int) string {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("failed to generate random name: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// NewAsynqClient starts a miniredis server for the test and returns an asynq
+// client for it and its URL, for creating a worker server. The server is
+// private to the test and stopped when it ends.
+func NewAsynqClient(t testing.TB) (*asynq.Client, string) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	redisURL := "redis://" + mr.Addr()
+
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client, redisURL
+}
+
+// RunTask runs task through handler inline, without a worker server, the way
+// asynq would call it.
+func RunTask(ctx context.Context, handler asynq.Handler, taskType string, payload []byte) error {
+	return handler.ProcessTask(ctx, asynq.NewTask(taskType, payload))
+}
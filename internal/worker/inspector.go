@@ -0,0 +1,304 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Task states accepted by QueueInspector.ListTasks.
+const (
+	TaskStatePending   = "pending"
+	TaskStateActive    = "active"
+	TaskStateScheduled = "scheduled"
+	TaskStateRetry     = "retry"
+	TaskStateArchived  = "archived"
+	TaskStateCompleted = "completed"
+)
+
+// inspectorTypeScanLimit bounds how many tasks ListTasks reads to fill a page
+// when filtering by task type, which asynq cannot do server-side.
+const inspectorTypeScanLimit = 2000
+
+// Queue inspection errors.
+var (
+	ErrInvalidTaskState = errors.New("invalid task state")
+	ErrTaskNotFound     = errors.New("task not found")
+)
+
+// QueueStats is the number of tasks in each state of one queue.
+type QueueStats struct {
+	Queue     string `json:"queue"`
+	Pending   int    `json:"pending"`
+	Active    int    `json:"active"`
+	Scheduled int    `json:"scheduled"`
+	Retry     int    `json:"retry"`
+	Archived  int    `json:"archived"`
+	Completed int    `json:"completed"`
+	// Processed and Failed count today's tasks; they reset daily.
+	Processed      int   `json:"processed_today"`
+	Failed         int   `json:"failed_today"`
+	Paused         bool  `json:"paused"`
+	LatencySeconds int64 `json:"latency_seconds"` // Age of the oldest pending task
+}
+
+// TaskSummary describes one queued task. JobID is decoded from the payload when present.
+type TaskSummary struct {
+	ID            string     `json:"id"`
+	Queue         string     `json:"queue"`
+	Type          string     `json:"type"`
+	State         string     `json:"state"`
+	JobID         *string    `json:"job_id,omitempty"`
+	Retried       int        `json:"retried"`
+	MaxRetry      int        `json:"max_retry"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastFailedAt  *time.Time `json:"last_failed_at,omitempty"`
+	NextProcessAt *time.Time `json:"next_process_at,omitempty"`
+}
+
+// QueueInspector reads queue statistics and manages individual tasks, so admins
+// can review failed tasks without redis-cli.
+type QueueInspector interface {
+	// Queues returns the stats of every queue.
+	Queues(ctx context.Context) ([]QueueStats, error)
+	// ListTasks returns a page (starting at 1) of tasks in state, across all queues
+	// when queue is empty. taskType, when set, keeps only tasks of that type.
+	ListTasks(ctx context.Context, queue, state, taskType string, page, pageSize int) ([]TaskSummary, error)
+	// RetryTask runs a scheduled, retry or archived task now. queue may be empty.
+	RetryTask(ctx context.Context, queue, id string) error
+	// DeleteTask removes a task that is not active. queue may be empty.
+	DeleteTask(ctx context.Context, queue, id string) error
+	// PendingTaskIDs returns the IDs of up to limit pending tasks in queue, in the
+	// order they will be processed.
+	PendingTaskIDs(ctx context.Context, queue string, limit int) ([]string, error)
+	// TaskExists reports whether any queue holds a task with one of ids, in any state.
+	TaskExists(ctx context.Context, ids ...string) (bool, error)
+	Close() error
+}
+
+// queueInspector implements QueueInspector with asynq.Inspector.
+type queueInspector struct {
+	inspector *asynq.Inspector
+}
+
+// NewQueueInspector creates a new QueueInspector for the Redis server at redisURL.
+func NewQueueInspector(redisURL string) (QueueInspector, error) {
+	redisOpt, err := asynq.ParseRedisURI(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse redis URL: %w", err)
+	}
+	return &queueInspector{inspector: asynq.NewInspector(redisOpt)}, nil
+}
+
+// Queues returns the stats of every queue.
+func (q *queueInspector) Queues(ctx context.Context) ([]QueueStats, error) {
+	names, err := q.inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+
+	stats := make([]QueueStats, 0, len(names))
+	for _, name := range names {
+		info, err := q.inspector.GetQueueInfo(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get queue %s info: %w", name, err)
+		}
+		stats = append(stats, QueueStats{
+			Queue:          info.Queue,
+			Pending:        info.Pending,
+			Active:         info.Active,
+			Scheduled:      info.Scheduled,
+			Retry:          info.Retry,
+			Archived:       info.Archived,
+			Completed:      info.Completed,
+			Processed:      info.Processed,
+			Failed:         info.Failed,
+			Paused:         info.Paused,
+			LatencySeconds: int64(info.Latency / time.Second),
+		})
+	}
+	return stats, nil
+}
+
+// ListTasks returns a page of tasks in state.
+func (q *queueInspector) ListTasks(ctx context.Context, queue, state, taskType string, page, pageSize int) ([]TaskSummary, error) {
+	list, err := q.lister(state)
+	if err != nil {
+		return nil, err
+	}
+
+	queues := []string{queue}
+	if queue == "" {
+		if queues, err = q.inspector.Queues(); err != nil {
+			return nil, fmt.Errorf("failed to list queues: %w", err)
+		}
+	}
+
+	// Collect matching tasks up to the end of the requested page, then slice it out
+	want := page * pageSize
+	matched := make([]TaskSummary, 0, want)
+	for _, name := range queues {
+		for scanPage, scanned := 1, 0; len(matched) < want && scanned < inspectorTypeScanLimit; scanPage++ {
+			infos, err := list(name, asynq.Page(scanPage), asynq.PageSize(max(pageSize, 100)))
+			if err != nil {
+				if errors.Is(err, asynq.ErrQueueNotFound) {
+					break
+				}
+				return nil, fmt.Errorf("failed to list %s tasks in queue %s: %w", state, name, err)
+			}
+			for _, info := range infos {
+				if taskType == "" || info.Type == taskType {
+					matched = append(matched, summarizeTask(info))
+				}
+			}
+			scanned += len(infos)
+			if len(infos) < max(pageSize, 100) {
+				break
+			}
+		}
+	}
+
+	start := (page - 1) * pageSize
+	if start >= len(matched) {
+		return []TaskSummary{}, nil
+	}
+	return matched[start:min(start+pageSize, len(matched))], nil
+}
+
+// lister returns the asynq list function for state.
+func (q *queueInspector) lister(state string) (func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error), error) {
+	switch state {
+	case TaskStatePending:
+		return q.inspector.ListPendingTasks, nil
+	case TaskStateActive:
+		return q.inspector.ListActiveTasks, nil
+	case TaskStateScheduled:
+		return q.inspector.ListScheduledTasks, nil
+	case TaskStateRetry:
+		return q.inspector.ListRetryTasks, nil
+	case TaskStateArchived:
+		return q.inspector.ListArchivedTasks, nil
+	case TaskStateCompleted:
+		return q.inspector.ListCompletedTasks, nil
+	}
+	return nil, ErrInvalidTaskState
+}
+
+// RetryTask runs a scheduled, retry or archived task now.
+func (q *queueInspector) RetryTask(ctx context.Context, queue, id string) error {
+	return q.withTaskQueue(queue, id, q.inspector.RunTask)
+}
+
+// DeleteTask removes a task that is not active.
+func (q *queueInspector) DeleteTask(ctx context.Context, queue, id string) error {
+	return q.withTaskQueue(queue, id, q.inspector.DeleteTask)
+}
+
+// withTaskQueue calls fn with the queue holding task id, searching every queue
+// when queue is empty. Missing tasks and queues are reported as ErrTaskNotFound.
+func (q *queueInspector) withTaskQueue(queue, id string, fn func(queue, id string) error) error {
+	if queue == "" {
+		queues, err := q.inspector.Queues()
+		if err != nil {
+			return fmt.Errorf("failed to list queues: %w", err)
+		}
+		for _, name := range queues {
+			if _, err := q.inspector.GetTaskInfo(name, id); err == nil {
+				queue = name
+				break
+			}
+		}
+		if queue == "" {
+			return ErrTaskNotFound
+		}
+	}
+
+	if err := fn(queue, id); err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+			return ErrTaskNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// PendingTaskIDs returns the IDs of the first limit pending tasks in queue.
+func (q *queueInspector) PendingTaskIDs(ctx context.Context, queue string, limit int) ([]string, error) {
+	const pageSize = 500
+
+	ids := make([]string, 0, pageSize)
+	for page := 1; len(ids) < limit; page++ {
+		infos, err := q.inspector.ListPendingTasks(queue, asynq.Page(page), asynq.PageSize(pageSize))
+		if err != nil {
+			if errors.Is(err, asynq.ErrQueueNotFound) {
+				break
+			}
+			return nil, fmt.Errorf("failed to list pending tasks in queue %s: %w", queue, err)
+		}
+		for _, info := range infos {
+			ids = append(ids, info.ID)
+		}
+		if len(infos) < pageSize {
+			break
+		}
+	}
+	return ids[:min(len(ids), limit)], nil
+}
+
+// TaskExists reports whether a task with one of ids exists in any queue.
+func (q *queueInspector) TaskExists(ctx context.Context, ids ...string) (bool, error) {
+	queues, err := q.inspector.Queues()
+	if err != nil {
+		return false, fmt.Errorf("failed to list queues: %w", err)
+	}
+
+	for _, name := range queues {
+		for _, id := range ids {
+			_, err := q.inspector.GetTaskInfo(name, id)
+			if err == nil {
+				return true, nil
+			}
+			if !errors.Is(err, asynq.ErrTaskNotFound) && !errors.Is(err, asynq.ErrQueueNotFound) {
+				return false, fmt.Errorf("failed to get task %s in queue %s: %w", id, name, err)
+			}
+		}
+	}
+	return false, nil
+}
+
+// Close closes the inspector's Redis connection.
+func (q *queueInspector) Close() error {
+	return q.inspector.Close()
+}
+
+// summarizeTask converts an asynq task to a TaskSummary, decoding the job ID
+// that most task payloads carry.
+func summarizeTask(info *asynq.TaskInfo) TaskSummary {
+	summary := TaskSummary{
+		ID:        info.ID,
+		Queue:     info.Queue,
+		Type:      info.Type,
+		State:     info.State.String(),
+		Retried:   info.Retried,
+		MaxRetry:  info.MaxRetry,
+		LastError: info.LastErr,
+	}
+	if !info.LastFailedAt.IsZero() {
+		summary.LastFailedAt = &info.LastFailedAt
+	}
+	if !info.NextProcessAt.IsZero() {
+		summary.NextProcessAt = &info.NextProcessAt
+	}
+
+	var payload struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.Unmarshal(info.Payload, &payload); err == nil && payload.JobID != "" {
+		summary.JobID = &payload.JobID
+	}
+	return summary
+}
@@ -0,0 +1,220 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/jaochai/ugc/internal/testutil"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// queuedTasks is the queue state set up by newQueuedTasks.
+type queuedTasks struct {
+	inspector QueueInspector
+	// archived holds the IDs of the archived process_video tasks, in the order
+	// asynq lists them, and their job IDs
+	archived     []string
+	archivedJobs map[string]string
+	pending      string // A pending analyze_concept task in the default queue
+	lowArchived  string // An archived delete_user task in the low queue, without a job ID
+}
+
+// newQueuedTasks enqueues tasks in the default and low queues of a private
+// Redis and archives some of them, as if they had run out of retries.
+func newQueuedTasks(t *testing.T) *queuedTasks {
+	t.Helper()
+
+	client, redisURL := testutil.NewAsynqClient(t)
+	inspector, err := NewQueueInspector(redisURL)
+	if err != nil {
+		t.Fatalf("failed to create inspector: %v", err)
+	}
+	t.Cleanup(func() { inspector.Close() })
+	archive := inspector.(*queueInspector).inspector.ArchiveTask
+
+	q := &queuedTasks{inspector: inspector, archivedJobs: make(map[string]string)}
+	enqueue := func(task *asynq.Task, err error, opts ...asynq.Option) *asynq.TaskInfo {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+		info, err := client.Enqueue(task, opts...)
+		if err != nil {
+			t.Fatalf("failed to enqueue %s: %v", task.Type(), err)
+		}
+		return info
+	}
+
+	for i := 0; i < 3; i++ {
+		jobID := uuid.New()
+		info := enqueue(NewProcessVideoTask(jobID, ""))
+		if err := archive(info.Queue, info.ID); err != nil {
+			t.Fatalf("failed to archive %s: %v", info.ID, err)
+		}
+		q.archived = append(q.archived, info.ID)
+		q.archivedJobs[info.ID] = jobID.String()
+	}
+	q.pending = enqueue(NewAnalyzeConceptTask(uuid.New(), "")).ID
+	userID := uuid.New()
+	task, err := NewDeleteUserDataTask(userID, "")
+	low := enqueue(task, err, DeleteUserDataTaskOptions(userID)...)
+	if err := archive(low.Queue, low.ID); err != nil {
+		t.Fatalf("failed to archive %s: %v", low.ID, err)
+	}
+	q.lowArchived = low.ID
+
+	// Archived tasks are listed most recently archived first
+	archived, err := inspector.ListTasks(context.Background(), "default", TaskStateArchived, "", 1, 10)
+	if err != nil {
+		t.Fatalf("ListTasks() error = %v", err)
+	}
+	q.archived = q.archived[:0]
+	for _, task := range archived {
+		q.archived = append(q.archived, task.ID)
+	}
+	return q
+}
+
+func TestQueueInspectorQueues(t *testing.T) {
+	q := newQueuedTasks(t)
+
+	stats, err := q.inspector.Queues(context.Background())
+	if err != nil {
+		t.Fatalf("Queues() error = %v", err)
+	}
+	byQueue := make(map[string]QueueStats)
+	for _, s := range stats {
+		byQueue[s.Queue] = s
+	}
+
+	if s := byQueue["default"]; s.Pending != 1 || s.Archived != 3 || s.Active != 0 || s.Retry != 0 {
+		t.Errorf("default queue = %+v, want 1 pending and 3 archived", s)
+	}
+	if s := byQueue["low"]; s.Pending != 0 || s.Archived != 1 {
+		t.Errorf("low queue = %+v, want 1 archived", s)
+	}
+}
+
+func TestQueueInspectorListTasks(t *testing.T) {
+	q := newQueuedTasks(t)
+	ctx := context.Background()
+
+	ids := func(summaries []TaskSummary) []string {
+		out := make([]string, len(summaries))
+		for i, s := range summaries {
+			out[i] = s.ID
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		queue    string
+		state    string
+		taskType string
+		page     int
+		pageSize int
+		want     []string
+		anyOrder bool // Queues are listed in no fixed order
+	}{
+		{name: "archived in every queue", state: TaskStateArchived, page: 1, pageSize: 10,
+			want: append(slices.Clone(q.archived), q.lowArchived), anyOrder: true},
+		{name: "archived of one type", state: TaskStateArchived, taskType: tasks.TypeProcessVideo, page: 1, pageSize: 10,
+			want: q.archived},
+		{name: "archived in one queue", queue: "low", state: TaskStateArchived, page: 1, pageSize: 10,
+			want: []string{q.lowArchived}},
+		{name: "first page", state: TaskStateArchived, taskType: tasks.TypeProcessVideo, page: 1, pageSize: 2,
+			want: q.archived[:2]},
+		{name: "second page", state: TaskStateArchived, taskType: tasks.TypeProcessVideo, page: 2, pageSize: 2,
+			want: q.archived[2:]},
+		{name: "past the last page", state: TaskStateArchived, taskType: tasks.TypeProcessVideo, page: 3, pageSize: 2,
+			want: []string{}},
+		{name: "pending", state: TaskStatePending, page: 1, pageSize: 10,
+			want: []string{q.pending}},
+		{name: "unknown queue", queue: "critical", state: TaskStateArchived, page: 1, pageSize: 10,
+			want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := q.inspector.ListTasks(ctx, tt.queue, tt.state, tt.taskType, tt.page, tt.pageSize)
+			if err != nil {
+				t.Fatalf("ListTasks() error = %v", err)
+			}
+			gotIDs, want := ids(got), tt.want
+			if tt.anyOrder {
+				gotIDs, want = slices.Sorted(slices.Values(gotIDs)), slices.Sorted(slices.Values(want))
+			}
+			if !slices.Equal(gotIDs, want) {
+				t.Errorf("ListTasks() = %v, want %v", ids(got), tt.want)
+			}
+		})
+	}
+
+	summaries, err := q.inspector.ListTasks(ctx, "", TaskStateArchived, "", 1, 10)
+	if err != nil {
+		t.Fatalf("ListTasks() error = %v", err)
+	}
+	for _, s := range summaries {
+		if s.State != TaskStateArchived {
+			t.Errorf("task %s state = %s, want archived", s.ID, s.State)
+		}
+		if s.ID == q.lowArchived {
+			if s.JobID != nil {
+				t.Errorf("delete_user task has job ID %s, want none", *s.JobID)
+			}
+			continue
+		}
+		if s.JobID == nil || *s.JobID != q.archivedJobs[s.ID] {
+			t.Errorf("task %s job ID = %v, want %s decoded from the payload", s.ID, s.JobID, q.archivedJobs[s.ID])
+		}
+	}
+
+	if _, err := q.inspector.ListTasks(ctx, "", "dead", "", 1, 10); !errors.Is(err, ErrInvalidTaskState) {
+		t.Errorf("ListTasks() with an unknown state error = %v, want ErrInvalidTaskState", err)
+	}
+}
+
+func TestQueueInspectorRetryAndDeleteTask(t *testing.T) {
+	q := newQueuedTasks(t)
+	ctx := context.Background()
+
+	// Found without naming the queue
+	if err := q.inspector.RetryTask(ctx, "", q.lowArchived); err != nil {
+		t.Fatalf("RetryTask() error = %v", err)
+	}
+	pending, err := q.inspector.ListTasks(ctx, "low", TaskStatePending, "", 1, 10)
+	if err != nil {
+		t.Fatalf("ListTasks() error = %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != q.lowArchived {
+		t.Errorf("low queue pending = %v, want the retried task", pending)
+	}
+
+	if err := q.inspector.DeleteTask(ctx, "default", q.archived[0]); err != nil {
+		t.Fatalf("DeleteTask() error = %v", err)
+	}
+	if exists, err := q.inspector.TaskExists(ctx, q.archived[0]); err != nil || exists {
+		t.Errorf("TaskExists() after DeleteTask() = %v, %v; want false", exists, err)
+	}
+	if exists, err := q.inspector.TaskExists(ctx, "missing", q.archived[1]); err != nil || !exists {
+		t.Errorf("TaskExists() of a remaining task = %v, %v; want true", exists, err)
+	}
+
+	for name, action := range map[string]func(ctx context.Context, queue, id string) error{
+		"RetryTask":  q.inspector.RetryTask,
+		"DeleteTask": q.inspector.DeleteTask,
+	} {
+		if err := action(ctx, "", "missing"); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("%s() of an unknown task error = %v, want ErrTaskNotFound", name, err)
+		}
+		if err := action(ctx, "critical", q.archived[1]); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("%s() in an unknown queue error = %v, want ErrTaskNotFound", name, err)
+		}
+	}
+}
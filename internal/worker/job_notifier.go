@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// JobNotifier enqueues user webhook deliveries and the opt-in notification email
+// when a job finishes. It is called
+// by JobService and the task handlers right after a job reaches a final status.
+type JobNotifier struct {
+	jobRepo     repository.JobRepository
+	userRepo    repository.UserRepository
+	webhookRepo repository.UserWebhookRepository
+	outbox      *Outbox
+	logger      *zap.Logger
+}
+
+// NewJobNotifier creates a new JobNotifier instance.
+func NewJobNotifier(
+	jobRepo repository.JobRepository,
+	userRepo repository.UserRepository,
+	webhookRepo repository.UserWebhookRepository,
+	outbox *Outbox,
+	logger *zap.Logger,
+) *JobNotifier {
+	return &JobNotifier{
+		jobRepo:     jobRepo,
+		userRepo:    userRepo,
+		webhookRepo: webhookRepo,
+		outbox:      outbox,
+		logger:      logger.Named("job_notifier"),
+	}
+}
+
+// JobFinished enqueues one delivery per webhook of the job's owner subscribed to
+// the job's final status, and an email if the owner opted in. Jobs that are not completed or failed (e.g. cancelled)
+// notify nobody. Failures are logged; notification never affects the job.
+func (n *JobNotifier) JobFinished(ctx context.Context, jobID uuid.UUID) {
+	logger := n.logger.With(zap.String("job_id", jobID.String()))
+
+	job, err := n.jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		logger.Warn("failed to load job for user notification", zap.Error(err))
+		return
+	}
+
+	event := models.JobWebhookEvent(job.Status)
+	if event == "" {
+		return
+	}
+
+	traceID := tasks.TraceIDFromContext(ctx)
+	n.enqueueEmail(ctx, job, event, traceID, logger)
+
+	webhooks, err := n.webhookRepo.ListByUserID(ctx, job.UserID)
+	if err != nil {
+		logger.Warn("failed to list user webhooks", zap.Error(err))
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(event) {
+			continue
+		}
+
+		task, err := NewNotifyUserTask(job.ID, webhook.ID, event, traceID)
+		if err != nil {
+			logger.Error("failed to create notify user task", zap.Error(err))
+			continue
+		}
+		if err := n.outbox.Enqueue(ctx, task, job.ID, notifyUserTaskOptions(job.ID, webhook.ID, event)...); err != nil && !isDuplicateTaskError(err) {
+			logger.Error("failed to enqueue notify user task",
+				zap.String("webhook_id", webhook.ID.String()),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// enqueueEmail enqueues the notification email if the job's owner opted in.
+func (n *JobNotifier) enqueueEmail(ctx context.Context, job *models.Job, event, traceID string, logger *zap.Logger) {
+	user, err := n.userRepo.GetByID(ctx, job.UserID)
+	if err != nil {
+		logger.Warn("failed to load user for email notification", zap.Error(err))
+		return
+	}
+	if !user.NotifyEmail {
+		return
+	}
+
+	task, err := NewSendEmailTask(job.ID, event, traceID)
+	if err != nil {
+		logger.Error("failed to create send email task", zap.Error(err))
+		return
+	}
+	if err := n.outbox.Enqueue(ctx, task, job.ID, sendEmailTaskOptions(job.ID, event)...); err != nil && !isDuplicateTaskError(err) {
+		logger.Error("failed to enqueue send email task", zap.Error(err))
+	}
+}
@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// purgeBatchSize is how many deleted jobs are purged per pass.
+const purgeBatchSize = 100
+
+// jobAssetStore is the part of *r2.Client the purger uses.
+type jobAssetStore interface {
+	ListJobObjectKeys(ctx context.Context, jobID string) ([]string, error)
+	DeleteMany(ctx context.Context, keys []string) (int, error)
+}
+
+// DeletedJobPurger permanently removes jobs soft-deleted more than
+// models.JobRestoreWindow ago, along with their R2 assets.
+type DeletedJobPurger struct {
+	jobRepo  repository.JobRepository
+	r2Client jobAssetStore // nil without R2
+	logger   *zap.Logger
+}
+
+// NewDeletedJobPurger creates a new DeletedJobPurger instance. r2Client may be nil,
+// in which case only the rows are removed and the R2AssetCleaner picks up the assets.
+func NewDeletedJobPurger(jobRepo repository.JobRepository, r2Client *r2.Client, logger *zap.Logger) *DeletedJobPurger {
+	p := &DeletedJobPurger{
+		jobRepo: jobRepo,
+		logger:  logger.Named("deleted_job_purger"),
+	}
+	if r2Client != nil {
+		p.r2Client = r2Client
+	}
+	return p
+}
+
+// Run purges once at startup and then every interval until ctx is done.
+func (p *DeletedJobPurger) Run(ctx context.Context, interval time.Duration) {
+	p.Purge(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Purge(ctx)
+		}
+	}
+}
+
+// Purge performs a single pass. Assets are deleted before the row, so a failed
+// pass leaves the job to be retried rather than orphaning its assets.
+func (p *DeletedJobPurger) Purge(ctx context.Context) {
+	ids, err := p.jobRepo.ListDeletedBefore(ctx, time.Now().Add(-models.JobRestoreWindow), purgeBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			p.logger.Error("failed to list deleted jobs", zap.Error(err))
+		}
+		return
+	}
+
+	purged := 0
+	for _, id := range ids {
+		if p.r2Client != nil {
+			keys, err := p.r2Client.ListJobObjectKeys(ctx, id.String())
+			if err == nil {
+				_, err = p.r2Client.DeleteMany(ctx, keys)
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					p.logger.Error("failed to delete assets of deleted job", zap.String("job_id", id.String()), zap.Error(err))
+				}
+				continue
+			}
+		}
+		if err := p.jobRepo.Delete(ctx, id); err != nil {
+			if ctx.Err() == nil {
+				p.logger.Error("failed to purge deleted job", zap.String("job_id", id.String()), zap.Error(err))
+			}
+			continue
+		}
+		purged++
+	}
+
+	if purged > 0 {
+		p.logger.Info("purged deleted jobs", zap.Int("count", purged))
+	}
+}
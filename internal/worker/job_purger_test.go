@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// deletedJobRepo holds soft-deleted jobs by deletion time and records purges.
+type deletedJobRepo struct {
+	repository.JobRepository
+	deletedAt map[uuid.UUID]time.Time
+	purged    []uuid.UUID
+}
+
+func (r *deletedJobRepo) ListDeletedBefore(ctx context.Context, deletedBefore time.Time, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for id, at := range r.deletedAt {
+		if at.Before(deletedBefore) && len(ids) < limit {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *deletedJobRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	delete(r.deletedAt, id)
+	r.purged = append(r.purged, id)
+	return nil
+}
+
+// jobAssetStub lists the fixed keys of every job and records deletions.
+type jobAssetStub struct {
+	deleteErr error
+	deleted   []string
+}
+
+func (s *jobAssetStub) ListJobObjectKeys(ctx context.Context, jobID string) ([]string, error) {
+	return r2.JobObjectKeys(jobID), nil
+}
+
+func (s *jobAssetStub) DeleteMany(ctx context.Context, keys []string) (int, error) {
+	if s.deleteErr != nil {
+		return 0, s.deleteErr
+	}
+	s.deleted = append(s.deleted, keys...)
+	return len(keys), nil
+}
+
+// TestDeletedJobPurger checks that only jobs deleted before the restore window
+// are purged, with their assets, and that a job whose assets could not be
+// deleted keeps its row for the next pass.
+func TestDeletedJobPurger(t *testing.T) {
+	now := time.Now()
+	expired := uuid.New()
+	restorable := uuid.New()
+
+	tests := []struct {
+		name       string
+		deleteErr  error
+		wantPurged []uuid.UUID
+	}{
+		{name: "past the window", wantPurged: []uuid.UUID{expired}},
+		{name: "assets not deleted", deleteErr: errors.New("r2 unavailable")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &deletedJobRepo{deletedAt: map[uuid.UUID]time.Time{
+				expired:    now.Add(-models.JobRestoreWindow - time.Hour),
+				restorable: now.Add(-models.JobRestoreWindow + time.Hour),
+			}}
+			store := &jobAssetStub{deleteErr: tt.deleteErr}
+			purger := &DeletedJobPurger{jobRepo: repo, r2Client: store, logger: zap.NewNop()}
+
+			purger.Purge(context.Background())
+
+			if !slices.Equal(repo.purged, tt.wantPurged) {
+				t.Errorf("purged %v, want %v", repo.purged, tt.wantPurged)
+			}
+			if _, ok := repo.deletedAt[restorable]; !ok {
+				t.Error("a job still within the restore window was purged")
+			}
+			if tt.deleteErr != nil {
+				return
+			}
+			if want := r2.JobObjectKeys(expired.String()); !slices.Equal(store.deleted, want) {
+				t.Errorf("deleted assets %v, want %v", store.deleted, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// Outbox settings.
+const (
+	outboxBatchSize   = 50
+	outboxMaxAttempts = 20
+	outboxBaseBackoff = 5 * time.Second
+	outboxMaxBackoff  = 10 * time.Minute
+)
+
+// taskEnqueuer enqueues tasks; *asynq.Client implements it.
+type taskEnqueuer interface {
+	EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+}
+
+// Outbox enqueues tasks, falling back to the pending_tasks table when the queue
+// is unavailable so that work already paid for at a provider is not lost.
+type Outbox struct {
+	client          taskEnqueuer
+	pendingTaskRepo repository.PendingTaskRepository
+	jobRepo         repository.JobRepository
+	logger          *zap.Logger
+}
+
+// NewOutbox creates a new Outbox instance.
+func NewOutbox(
+	client *asynq.Client,
+	pendingTaskRepo repository.PendingTaskRepository,
+	jobRepo repository.JobRepository,
+	logger *zap.Logger,
+) *Outbox {
+	return &Outbox{
+		client:          client,
+		pendingTaskRepo: pendingTaskRepo,
+		jobRepo:         jobRepo,
+		logger:          logger.Named("outbox"),
+	}
+}
+
+// Enqueue enqueues task for jobID with opts. If the queue rejects it, the task is
+// stored in the outbox and nil is returned; the drain loop enqueues it later.
+// Deduplication conflicts (asynq.ErrTaskIDConflict, asynq.ErrDuplicateTask) are
+// returned unchanged so callers can treat them as already enqueued.
+//
+// asynq does not expose the options given to asynq.NewTask, so options that
+// must survive the outbox (delays, queue, retries, timeout, a TaskID other than
+// tasks.DedupTaskID) have to be passed here.
+func (o *Outbox) Enqueue(ctx context.Context, task *asynq.Task, jobID uuid.UUID, opts ...asynq.Option) error {
+	pending := &models.PendingTask{JobID: &jobID}
+	if taskID := tasks.DedupTaskID(task.Type(), jobID); taskID != "" {
+		pending.TaskID = &taskID
+	}
+	return o.enqueue(ctx, task, pending, opts, zap.String("job_id", jobID.String()))
+}
+
+// EnqueueForUser is Enqueue for a user-level task, which has no job.
+func (o *Outbox) EnqueueForUser(ctx context.Context, task *asynq.Task, userID uuid.UUID, opts ...asynq.Option) error {
+	return o.enqueue(ctx, task, &models.PendingTask{UserID: &userID}, opts, zap.String("user_id", userID.String()))
+}
+
+// enqueue enqueues task with opts, storing it as pending if the queue rejects it.
+func (o *Outbox) enqueue(ctx context.Context, task *asynq.Task, pending *models.PendingTask, opts []asynq.Option, owner zap.Field) error {
+	_, err := o.client.EnqueueContext(ctx, task, opts...)
+	if err == nil || isDuplicateTaskError(err) {
+		return err
+	}
+
+	lastError := err.Error()
+	pending.TaskType = task.Type()
+	pending.Payload = task.Payload()
+	pending.LastError = &lastError
+	for _, dropped := range setPendingTaskOptions(pending, opts, time.Now()) {
+		o.logger.Warn("outbox does not keep enqueue option",
+			zap.String("task_type", task.Type()),
+			zap.String("option", dropped),
+		)
+	}
+
+	if storeErr := o.pendingTaskRepo.Create(ctx, pending); storeErr != nil {
+		return fmt.Errorf("failed to enqueue task: %v; failed to store in outbox: %w", err, storeErr)
+	}
+
+	o.logger.Warn("enqueue failed, task stored in outbox",
+		owner,
+		zap.String("task_type", task.Type()),
+		zap.Error(err),
+	)
+	return nil
+}
+
+// Run drains the outbox every interval until ctx is done.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.drain(ctx)
+		}
+	}
+}
+
+// drain re-enqueues all due outbox entries.
+func (o *Outbox) drain(ctx context.Context) {
+	pending, err := o.pendingTaskRepo.ClaimDue(ctx, outboxBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			o.logger.Error("failed to claim outbox tasks", zap.Error(err))
+		}
+		return
+	}
+
+	for _, p := range pending {
+		o.retry(ctx, p)
+	}
+}
+
+// retry enqueues a single outbox entry, rescheduling it with backoff on failure.
+func (o *Outbox) retry(ctx context.Context, p *models.PendingTask) {
+	logger := o.logger.With(
+		zap.String("task_type", p.TaskType),
+		zap.Int("attempts", p.Attempts),
+	)
+	if p.JobID != nil {
+		logger = logger.With(zap.String("job_id", p.JobID.String()))
+	}
+	if p.UserID != nil {
+		logger = logger.With(zap.String("user_id", p.UserID.String()))
+	}
+
+	_, err := o.client.EnqueueContext(ctx, asynq.NewTask(p.TaskType, p.Payload), pendingTaskOptions(p)...)
+	if err == nil || isDuplicateTaskError(err) {
+		if err := o.pendingTaskRepo.Delete(ctx, p.ID); err != nil {
+			logger.Error("failed to delete drained outbox task", zap.Error(err))
+			return
+		}
+		logger.Info("outbox task enqueued")
+		return
+	}
+
+	if p.Attempts >= outboxMaxAttempts {
+		logger.Error("giving up on outbox task", zap.Error(err))
+		if delErr := o.pendingTaskRepo.Delete(ctx, p.ID); delErr != nil {
+			logger.Error("failed to delete abandoned outbox task", zap.Error(delErr))
+		}
+		if p.JobID == nil {
+			// User-level tasks are re-enqueued by their own sweeps
+			return
+		}
+		msg := fmt.Sprintf("failed to enqueue %s after %d attempts", p.TaskType, p.Attempts)
+		if failErr := o.jobRepo.UpdateWithError(ctx, *p.JobID, msg); failErr != nil && !errors.Is(failErr, repository.ErrStatusConflict) {
+			logger.Error("failed to mark job as failed", zap.Error(failErr))
+		}
+		return
+	}
+
+	next := time.Now().Add(outboxBackoff(p.Attempts))
+	if err := o.pendingTaskRepo.Reschedule(ctx, p.ID, next, err.Error()); err != nil {
+		logger.Error("failed to reschedule outbox task", zap.Error(err))
+		return
+	}
+	logger.Warn("outbox task enqueue failed, rescheduled", zap.Time("next_attempt_at", next), zap.Error(err))
+}
+
+// setPendingTaskOptions stores opts on p, resolving ProcessIn against now. A
+// TaskID option replaces the default one. It returns the options it cannot
+// store, which are dropped.
+func setPendingTaskOptions(p *models.PendingTask, opts []asynq.Option, now time.Time) (dropped []string) {
+	for _, opt := range opts {
+		switch opt.Type() {
+		case asynq.TaskIDOpt:
+			taskID := opt.Value().(string)
+			p.TaskID = &taskID
+		case asynq.QueueOpt:
+			queue := opt.Value().(string)
+			p.Queue = &queue
+		case asynq.MaxRetryOpt:
+			maxRetry := opt.Value().(int)
+			p.MaxRetry = &maxRetry
+		case asynq.TimeoutOpt:
+			seconds := int(math.Ceil(opt.Value().(time.Duration).Seconds()))
+			p.TimeoutSeconds = &seconds
+		case asynq.ProcessAtOpt:
+			processAt := opt.Value().(time.Time)
+			p.ProcessAt = &processAt
+		case asynq.ProcessInOpt:
+			processAt := now.Add(opt.Value().(time.Duration))
+			p.ProcessAt = &processAt
+		default:
+			dropped = append(dropped, opt.String())
+		}
+	}
+	return dropped
+}
+
+// pendingTaskOptions returns the enqueue options stored on p. A ProcessAt in the
+// past enqueues the task immediately.
+func pendingTaskOptions(p *models.PendingTask) []asynq.Option {
+	var opts []asynq.Option
+	if p.TaskID != nil {
+		opts = append(opts, asynq.TaskID(*p.TaskID))
+	}
+	if p.Queue != nil {
+		opts = append(opts, asynq.Queue(*p.Queue))
+	}
+	if p.MaxRetry != nil {
+		opts = append(opts, asynq.MaxRetry(*p.MaxRetry))
+	}
+	if p.TimeoutSeconds != nil {
+		opts = append(opts, asynq.Timeout(time.Duration(*p.TimeoutSeconds)*time.Second))
+	}
+	if p.ProcessAt != nil {
+		opts = append(opts, asynq.ProcessAt(*p.ProcessAt))
+	}
+	return opts
+}
+
+// outboxBackoff returns the exponential delay before the next attempt.
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxBaseBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	return backoff
+}
+
+// isDuplicateTaskError reports whether err means the task is already enqueued.
+func isDuplicateTaskError(err error) bool {
+	return errors.Is(err, asynq.ErrTaskIDConflict) || errors.Is(err, asynq.ErrDuplicateTask)
+}
@@ -0,0 +1,178 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/testutil"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// stubEnqueuer records enqueues and fails them while err is set.
+type stubEnqueuer struct {
+	mu    sync.Mutex
+	err   error
+	calls [][]asynq.Option
+}
+
+func (s *stubEnqueuer) EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, opts)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &asynq.TaskInfo{Type: task.Type()}, nil
+}
+
+func (s *stubEnqueuer) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// lastOptions returns the options of the last enqueue by option type.
+func (s *stubEnqueuer) lastOptions(t *testing.T) map[asynq.OptionType]interface{} {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.calls) == 0 {
+		t.Fatal("nothing was enqueued")
+	}
+	opts := make(map[asynq.OptionType]interface{})
+	for _, opt := range s.calls[len(s.calls)-1] {
+		opts[opt.Type()] = opt.Value()
+	}
+	return opts
+}
+
+func newTestOutbox(client taskEnqueuer, repo *testutil.FakePendingTaskRepository) *Outbox {
+	return &Outbox{client: client, pendingTaskRepo: repo, logger: zap.NewNop()}
+}
+
+func TestOutboxKeepsEnqueueOptions(t *testing.T) {
+	ctx := context.Background()
+	client := &stubEnqueuer{err: errors.New("redis: connection refused")}
+	repo := testutil.NewFakePendingTaskRepository()
+	outbox := newTestOutbox(client, repo)
+
+	jobID, webhookID := uuid.New(), uuid.New()
+	task, err := NewNotifyUserTask(jobID, webhookID, "job.completed", "")
+	if err != nil {
+		t.Fatalf("NewNotifyUserTask() error = %v", err)
+	}
+	opts := append(notifyUserTaskOptions(jobID, webhookID, "job.completed"),
+		asynq.Queue("low"),
+		asynq.Timeout(90*time.Second),
+		asynq.ProcessIn(10*time.Minute),
+	)
+
+	before := time.Now()
+	if err := outbox.Enqueue(ctx, task, jobID, opts...); err != nil {
+		t.Fatalf("Enqueue() error = %v, want the task stored in the outbox", err)
+	}
+
+	stored := repo.Tasks()
+	if len(stored) != 1 {
+		t.Fatalf("outbox holds %d tasks, want 1", len(stored))
+	}
+	p := stored[0]
+	wantTaskID := "notify-user-" + webhookID.String() + "-" + jobID.String() + "-job.completed"
+	if p.TaskID == nil || *p.TaskID != wantTaskID {
+		t.Errorf("stored task_id = %v, want %s", p.TaskID, wantTaskID)
+	}
+	if p.Queue == nil || *p.Queue != "low" {
+		t.Errorf("stored queue = %v, want low", p.Queue)
+	}
+	if p.MaxRetry == nil || *p.MaxRetry != notifyUserMaxRetry {
+		t.Errorf("stored max_retry = %v, want %d", p.MaxRetry, notifyUserMaxRetry)
+	}
+	if p.TimeoutSeconds == nil || *p.TimeoutSeconds != 90 {
+		t.Errorf("stored timeout_seconds = %v, want 90", p.TimeoutSeconds)
+	}
+	if p.ProcessAt == nil || p.ProcessAt.Before(before.Add(10*time.Minute)) || p.ProcessAt.After(time.Now().Add(10*time.Minute)) {
+		t.Fatalf("stored process_at = %v, want 10 minutes after the enqueue", p.ProcessAt)
+	}
+
+	// A failed retry keeps the options for the next one
+	outbox.drain(ctx)
+	if stored := repo.Tasks(); len(stored) != 1 || stored[0].ProcessAt == nil || !stored[0].ProcessAt.Equal(*p.ProcessAt) {
+		t.Fatalf("after a failed retry the outbox holds %+v, want the task with its options", stored)
+	}
+
+	client.setErr(nil)
+	repo.MakeDue()
+	outbox.drain(ctx)
+	if stored := repo.Tasks(); len(stored) != 0 {
+		t.Fatalf("outbox holds %d tasks after a successful retry, want 0", len(stored))
+	}
+
+	got := client.lastOptions(t)
+	if got[asynq.TaskIDOpt] != wantTaskID {
+		t.Errorf("retried with TaskID %v, want %s", got[asynq.TaskIDOpt], wantTaskID)
+	}
+	if got[asynq.QueueOpt] != "low" {
+		t.Errorf("retried on queue %v, want low", got[asynq.QueueOpt])
+	}
+	if got[asynq.MaxRetryOpt] != notifyUserMaxRetry {
+		t.Errorf("retried with MaxRetry %v, want %d", got[asynq.MaxRetryOpt], notifyUserMaxRetry)
+	}
+	if got[asynq.TimeoutOpt] != 90*time.Second {
+		t.Errorf("retried with Timeout %v, want 90s", got[asynq.TimeoutOpt])
+	}
+	// The delay counts from the original enqueue, not from the retry
+	if processAt, _ := got[asynq.ProcessAtOpt].(time.Time); !processAt.Equal(*p.ProcessAt) {
+		t.Errorf("retried with ProcessAt %v, want %v", got[asynq.ProcessAtOpt], *p.ProcessAt)
+	}
+}
+
+func TestOutboxDefaultsToDedupTaskID(t *testing.T) {
+	ctx := context.Background()
+	client := &stubEnqueuer{err: errors.New("redis: connection refused")}
+	repo := testutil.NewFakePendingTaskRepository()
+	outbox := newTestOutbox(client, repo)
+
+	jobID := uuid.New()
+	task, err := NewAnalyzeConceptTask(jobID, "")
+	if err != nil {
+		t.Fatalf("NewAnalyzeConceptTask() error = %v", err)
+	}
+	if err := outbox.Enqueue(ctx, task, jobID); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	client.setErr(nil)
+	outbox.drain(ctx)
+
+	got := client.lastOptions(t)
+	if want := tasks.DedupTaskID(tasks.TypeAnalyzeConcept, jobID); got[asynq.TaskIDOpt] != want {
+		t.Errorf("retried with TaskID %v, want %s", got[asynq.TaskIDOpt], want)
+	}
+	for _, optType := range []asynq.OptionType{asynq.QueueOpt, asynq.MaxRetryOpt, asynq.TimeoutOpt, asynq.ProcessAtOpt} {
+		if value, ok := got[optType]; ok {
+			t.Errorf("retried with option %v = %v, want asynq's default", optType, value)
+		}
+	}
+}
+
+func TestOutboxDuplicateIsNotStored(t *testing.T) {
+	client := &stubEnqueuer{err: asynq.ErrTaskIDConflict}
+	repo := testutil.NewFakePendingTaskRepository()
+	outbox := newTestOutbox(client, repo)
+
+	jobID := uuid.New()
+	task, _ := NewAnalyzeConceptTask(jobID, "")
+	if err := outbox.Enqueue(context.Background(), task, jobID); !errors.Is(err, asynq.ErrTaskIDConflict) {
+		t.Fatalf("Enqueue() error = %v, want ErrTaskIDConflict", err)
+	}
+	if stored := repo.Tasks(); len(stored) != 0 {
+		t.Fatalf("outbox holds %d tasks after a duplicate, want 0", len(stored))
+	}
+}
@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// Queue position settings. Job polling hits the estimator on every request, so
+// the pending list and the stage duration are read from caches.
+const (
+	// analyzeTaskQueue is the queue the analyze concept task is enqueued on.
+	analyzeTaskQueue = "default"
+	// queueSnapshotTTL is how long a snapshot of the pending tasks is reused.
+	queueSnapshotTTL = 5 * time.Second
+	// queueSnapshotLimit bounds the pending tasks read per snapshot; jobs further
+	// back get no position.
+	queueSnapshotLimit = inspectorTypeScanLimit
+	// analyzeDurationWindow is how far back the analyze stage duration is measured.
+	analyzeDurationWindow = 24 * time.Hour
+	// analyzeDurationTTL is how long the analyze stage duration is reused.
+	analyzeDurationTTL = 5 * time.Minute
+)
+
+// QueuePosition is where a pending job's analyze task is in its queue.
+type QueuePosition struct {
+	// Position is the number of tasks ahead of the job's task.
+	Position int
+	// EstimatedStart is when the task should start; 0 when there is no duration data.
+	EstimatedStart time.Duration
+}
+
+// QueuePositionEstimator finds pending jobs in the task queue by their
+// deterministic analyze TaskID and estimates when they start, from the median
+// analyze stage duration and the worker concurrency.
+type QueuePositionEstimator struct {
+	inspector   QueueInspector
+	jobRepo     repository.JobRepository
+	concurrency int
+	logger      *zap.Logger
+
+	mu              sync.Mutex
+	positions       map[string]int // Task ID to index in the pending list
+	positionsExpiry time.Time
+	analyze         time.Duration
+	analyzeExpiry   time.Time
+}
+
+// NewQueuePositionEstimator creates a new QueuePositionEstimator. concurrency is
+// the number of tasks the workers process at once.
+func NewQueuePositionEstimator(inspector QueueInspector, jobRepo repository.JobRepository, concurrency int, logger *zap.Logger) *QueuePositionEstimator {
+	return &QueuePositionEstimator{
+		inspector:   inspector,
+		jobRepo:     jobRepo,
+		concurrency: max(concurrency, 1),
+		logger:      logger.Named("queue_position"),
+	}
+}
+
+// Estimate returns the queue position of the pending job jobID. ok is false when
+// its task is not pending, e.g. it is still in the outbox or already running, or
+// the queue could not be read.
+func (e *QueuePositionEstimator) Estimate(ctx context.Context, jobID uuid.UUID) (QueuePosition, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	if !now.Before(e.positionsExpiry) {
+		ids, err := e.inspector.PendingTaskIDs(ctx, analyzeTaskQueue, queueSnapshotLimit)
+		if err != nil {
+			e.logger.Warn("failed to read pending tasks", zap.Error(err))
+			// Keep serving the previous snapshot until the next refresh
+			e.positionsExpiry = now.Add(queueSnapshotTTL)
+			return e.lookup(ctx, jobID, now)
+		}
+		e.positions = make(map[string]int, len(ids))
+		for i, id := range ids {
+			e.positions[id] = i
+		}
+		e.positionsExpiry = now.Add(queueSnapshotTTL)
+	}
+	return e.lookup(ctx, jobID, now)
+}
+
+// lookup finds jobID in the current snapshot. It must be called with mu held.
+func (e *QueuePositionEstimator) lookup(ctx context.Context, jobID uuid.UUID, now time.Time) (QueuePosition, bool) {
+	position, ok := e.positions[tasks.DedupTaskID(tasks.TypeAnalyzeConcept, jobID)]
+	if !ok {
+		return QueuePosition{}, false
+	}
+
+	// Every worker slot takes one task ahead per analyze duration
+	analyze := e.analyzeDuration(ctx, now)
+	return QueuePosition{
+		Position:       position,
+		EstimatedStart: time.Duration(position) * analyze / time.Duration(e.concurrency),
+	}, true
+}
+
+// analyzeDuration returns the cached median analyze stage duration, or 0 when
+// there is no recent data. It must be called with mu held.
+func (e *QueuePositionEstimator) analyzeDuration(ctx context.Context, now time.Time) time.Duration {
+	if now.Before(e.analyzeExpiry) {
+		return e.analyze
+	}
+
+	stats, err := e.jobRepo.StageDurationStats(ctx, now.Add(-analyzeDurationWindow))
+	if err != nil {
+		e.logger.Warn("failed to compute analyze stage duration", zap.Error(err))
+		// Retry on a later call after a short pause instead of hitting the DB every request
+		e.analyzeExpiry = now.Add(time.Minute)
+		return e.analyze
+	}
+
+	e.analyze = 0
+	for _, s := range stats {
+		if s.Stage == models.StageAnalyze {
+			e.analyze = time.Duration(s.P50Seconds * float64(time.Second))
+		}
+	}
+	e.analyzeExpiry = now.Add(analyzeDurationTTL)
+	return e.analyze
+}
@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// pendingInspector serves a fixed pending list of the analyze queue and counts
+// the reads. Other QueueInspector methods panic.
+type pendingInspector struct {
+	QueueInspector
+	ids   []string
+	err   error
+	reads int
+}
+
+func (i *pendingInspector) PendingTaskIDs(ctx context.Context, queue string, limit int) ([]string, error) {
+	i.reads++
+	if queue != analyzeTaskQueue {
+		return nil, errors.New("unexpected queue " + queue)
+	}
+	if i.err != nil {
+		return nil, i.err
+	}
+	return i.ids[:min(limit, len(i.ids))], nil
+}
+
+// stageStatsRepo serves the stage duration stats and counts the reads.
+type stageStatsRepo struct {
+	repository.JobRepository
+	stats []models.StageDurationStats
+	err   error
+	reads int
+}
+
+func (r *stageStatsRepo) StageDurationStats(ctx context.Context, since time.Time) ([]models.StageDurationStats, error) {
+	r.reads++
+	return r.stats, r.err
+}
+
+// queuedJobs returns n job IDs and the pending list of their analyze tasks, in order.
+func queuedJobs(n int) ([]uuid.UUID, []string) {
+	jobs := make([]uuid.UUID, n)
+	ids := make([]string, n)
+	for i := range jobs {
+		jobs[i] = uuid.New()
+		ids[i] = tasks.DedupTaskID(tasks.TypeAnalyzeConcept, jobs[i])
+	}
+	return jobs, ids
+}
+
+func TestQueuePositionEstimate(t *testing.T) {
+	jobs, ids := queuedJobs(5)
+	// Other tasks in the queue count as work ahead too
+	ids = append([]string{"job:send_email:1"}, ids...)
+	stats := []models.StageDurationStats{
+		{Stage: models.StageMusic, Jobs: 10, P50Seconds: 90},
+		{Stage: models.StageAnalyze, Jobs: 10, P50Seconds: 12},
+	}
+
+	tests := []struct {
+		name        string
+		jobID       uuid.UUID
+		concurrency int
+		stats       []models.StageDurationStats
+		want        QueuePosition
+		wantOK      bool
+	}{
+		{name: "first analyze task", jobID: jobs[0], concurrency: 1, stats: stats,
+			want: QueuePosition{Position: 1, EstimatedStart: 12 * time.Second}, wantOK: true},
+		{name: "last analyze task", jobID: jobs[4], concurrency: 1, stats: stats,
+			want: QueuePosition{Position: 5, EstimatedStart: time.Minute}, wantOK: true},
+		{name: "shared by the worker slots", jobID: jobs[4], concurrency: 4, stats: stats,
+			want: QueuePosition{Position: 5, EstimatedStart: 15 * time.Second}, wantOK: true},
+		{name: "zero concurrency counts as one", jobID: jobs[1], concurrency: 0, stats: stats,
+			want: QueuePosition{Position: 2, EstimatedStart: 24 * time.Second}, wantOK: true},
+		{name: "no analyze durations", jobID: jobs[2], concurrency: 2, stats: stats[:1],
+			want: QueuePosition{Position: 3}, wantOK: true},
+		{name: "not in the queue", jobID: uuid.New(), concurrency: 1, stats: stats},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspector := &pendingInspector{ids: ids}
+			estimator := NewQueuePositionEstimator(inspector, &stageStatsRepo{stats: tt.stats}, tt.concurrency, zap.NewNop())
+
+			got, ok := estimator.Estimate(context.Background(), tt.jobID)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("Estimate() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// TestQueuePositionCache checks that job polling reuses the pending list for a
+// few seconds and the analyze duration for longer, and that a failed read keeps
+// the previous snapshot.
+func TestQueuePositionCache(t *testing.T) {
+	jobs, ids := queuedJobs(3)
+	inspector := &pendingInspector{ids: ids}
+	repo := &stageStatsRepo{stats: []models.StageDurationStats{{Stage: models.StageAnalyze, P50Seconds: 10}}}
+	estimator := NewQueuePositionEstimator(inspector, repo, 1, zap.NewNop())
+	ctx := context.Background()
+
+	for _, jobID := range append(jobs, uuid.New()) {
+		estimator.Estimate(ctx, jobID)
+	}
+	if inspector.reads != 1 || repo.reads != 1 {
+		t.Fatalf("queue read %d times and durations %d times within the TTL, want once each", inspector.reads, repo.reads)
+	}
+
+	// The first job started; the next snapshot moves the others up
+	inspector.ids = ids[1:]
+	if got, _ := estimator.Estimate(ctx, jobs[2]); got.Position != 2 {
+		t.Errorf("position within the TTL = %d, want the cached 2", got.Position)
+	}
+	estimator.positionsExpiry = time.Now().Add(-time.Second)
+	if got, _ := estimator.Estimate(ctx, jobs[2]); got.Position != 1 || got.EstimatedStart != 10*time.Second {
+		t.Errorf("Estimate() after the TTL = %+v, want position 1 in 10s", got)
+	}
+	if inspector.reads != 2 || repo.reads != 1 {
+		t.Errorf("queue read %d times and durations %d times, want 2 and the cached 1", inspector.reads, repo.reads)
+	}
+
+	// A failed read serves the previous snapshot and waits a TTL before retrying
+	inspector.err = errors.New("redis: connection refused")
+	estimator.positionsExpiry = time.Now().Add(-time.Second)
+	if got, ok := estimator.Estimate(ctx, jobs[2]); !ok || got.Position != 1 {
+		t.Errorf("Estimate() with the queue unreadable = %+v, %v, want the previous position 1", got, ok)
+	}
+	estimator.Estimate(ctx, jobs[2])
+	if inspector.reads != 3 {
+		t.Errorf("queue read %d times after a failure, want 3", inspector.reads)
+	}
+
+	// Durations are read again once their TTL passes
+	estimator.analyzeExpiry = time.Now().Add(-time.Second)
+	estimator.Estimate(ctx, jobs[2])
+	if repo.reads != 2 {
+		t.Errorf("durations read %d times after the TTL, want 2", repo.reads)
+	}
+}
@@ -0,0 +1,204 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/metrics"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// orphanGracePeriod skips objects uploaded recently, so a pass never races the
+// upload of a job's assets.
+const orphanGracePeriod = time.Hour
+
+// missingBreakerMinJobs is how many jobs a pass must list before their missing
+// ratio can abort it, so a few orphans are still cleaned up.
+const missingBreakerMinJobs = 10
+
+// Reasons an R2 cleanup pass is aborted, reported by metrics.R2CleanupAborted.
+const (
+	cleanupAbortNoJobs      = "no_jobs"
+	cleanupAbortMissingJobs = "missing_jobs"
+)
+
+// r2ObjectStore is the part of *r2.Client the cleaner uses.
+type r2ObjectStore interface {
+	List(ctx context.Context, prefix string) ([]r2.Object, error)
+	DeleteMany(ctx context.Context, keys []string) (int, error)
+}
+
+// R2CleanerConfig controls which objects the R2AssetCleaner removes.
+type R2CleanerConfig struct {
+	// FailedRetention is how long the assets of failed jobs are kept.
+	FailedRetention time.Duration
+	// MaxDeletes caps the objects removed per pass, so a bad database read cannot
+	// empty the bucket. Objects over the cap are left for later passes.
+	MaxDeletes int
+	// DryRun logs the objects that would be removed without deleting them.
+	DryRun bool
+	// MaxMissingRatio is the share of listed jobs that may be missing from the
+	// database. Above it the read is assumed to be wrong (an empty or restored
+	// database, the wrong DATABASE_URL) and the pass deletes nothing.
+	MaxMissingRatio float64
+}
+
+// R2AssetCleaner deletes job assets in R2 whose job no longer exists or failed
+// more than FailedRetention ago.
+type R2AssetCleaner struct {
+	r2Client r2ObjectStore
+	jobRepo  repository.JobRepository
+	cfg      R2CleanerConfig
+	metrics  *metrics.Metrics
+	logger   *zap.Logger
+}
+
+// NewR2AssetCleaner creates a new R2AssetCleaner instance. m may be nil.
+func NewR2AssetCleaner(r2Client *r2.Client, jobRepo repository.JobRepository, cfg R2CleanerConfig, m *metrics.Metrics, logger *zap.Logger) *R2AssetCleaner {
+	return &R2AssetCleaner{
+		r2Client: r2Client,
+		jobRepo:  jobRepo,
+		cfg:      cfg,
+		metrics:  m,
+		logger:   logger.Named("r2_asset_cleaner"),
+	}
+}
+
+// Run cleans up once at startup and then every interval until ctx is done.
+func (c *R2AssetCleaner) Run(ctx context.Context, interval time.Duration) {
+	c.Cleanup(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Cleanup(ctx)
+		}
+	}
+}
+
+// Cleanup performs a single cleanup pass over every job asset prefix.
+func (c *R2AssetCleaner) Cleanup(ctx context.Context) {
+	prefixes := []string{r2.UserImagePrefix()}
+	for _, asset := range r2.JobAssetTypes() {
+		prefixes = append(prefixes, r2.JobAssetPrefix(asset))
+	}
+
+	// Group the keys by the job that owns them
+	keysByJob := make(map[uuid.UUID][]string)
+	uploadedBefore := time.Now().Add(-orphanGracePeriod)
+	for _, prefix := range prefixes {
+		objects, err := c.r2Client.List(ctx, prefix)
+		if err != nil {
+			if ctx.Err() == nil {
+				c.logger.Error("failed to list R2 objects", zap.String("prefix", prefix), zap.Error(err))
+			}
+			return
+		}
+		for _, obj := range objects {
+			if obj.LastModified.After(uploadedBefore) {
+				continue
+			}
+			rawID, ok := r2.JobIDFromKey(obj.Key)
+			if !ok {
+				continue
+			}
+			jobID, err := uuid.Parse(rawID)
+			if err != nil {
+				continue
+			}
+			keysByJob[jobID] = append(keysByJob[jobID], obj.Key)
+		}
+	}
+	if len(keysByJob) == 0 {
+		return
+	}
+
+	jobIDs := make([]uuid.UUID, 0, len(keysByJob))
+	for id := range keysByJob {
+		jobIDs = append(jobIDs, id)
+	}
+	states, err := c.jobRepo.GetStorageStates(ctx, jobIDs)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.logger.Error("failed to load job states", zap.Error(err))
+		}
+		return
+	}
+
+	if c.tripBreaker(len(jobIDs), len(jobIDs)-len(states)) {
+		return
+	}
+
+	failedBefore := time.Now().Add(-c.cfg.FailedRetention)
+	var expired []string
+	for _, id := range jobIDs {
+		state, exists := states[id]
+		if exists && (state.Status != models.StatusFailed || state.UpdatedAt.After(failedBefore)) {
+			continue
+		}
+		expired = append(expired, keysByJob[id]...)
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	if len(expired) > c.cfg.MaxDeletes {
+		c.logger.Warn("expired R2 objects exceed the per-run cap, deleting the first batch",
+			zap.Int("expired", len(expired)),
+			zap.Int("max_deletes", c.cfg.MaxDeletes),
+		)
+		expired = expired[:c.cfg.MaxDeletes]
+	}
+
+	if c.cfg.DryRun {
+		for _, key := range expired {
+			c.logger.Info("dry run: would delete R2 object", zap.String("key", key))
+		}
+		c.logger.Info("dry run: R2 cleanup pass finished", zap.Int("would_delete", len(expired)))
+		return
+	}
+
+	deleted, err := c.r2Client.DeleteMany(ctx, expired)
+	if err != nil && ctx.Err() == nil {
+		c.logger.Error("failed to delete expired R2 objects", zap.Int("deleted", deleted), zap.Error(err))
+		return
+	}
+	if deleted > 0 {
+		c.logger.Info("deleted expired R2 objects", zap.Int("count", deleted))
+	}
+}
+
+// tripBreaker reports whether a pass that listed the assets of listed jobs, of
+// which missing are not in the database, must delete nothing. A database that
+// knows none of the jobs, or too few of them, more likely returned a wrong read
+// than lost its jobs, so the pass is logged and counted for alerting instead.
+func (c *R2AssetCleaner) tripBreaker(listed, missing int) bool {
+	reason := ""
+	switch {
+	case missing == listed:
+		reason = cleanupAbortNoJobs
+	case listed >= missingBreakerMinJobs && float64(missing) > c.cfg.MaxMissingRatio*float64(listed):
+		reason = cleanupAbortMissingJobs
+	default:
+		return false
+	}
+
+	c.logger.Error("aborting R2 cleanup pass: the database is missing too many of the listed jobs",
+		zap.String("reason", reason),
+		zap.Int("listed_jobs", listed),
+		zap.Int("missing_jobs", missing),
+		zap.Float64("max_missing_ratio", c.cfg.MaxMissingRatio),
+	)
+	c.metrics.R2CleanupAborted(reason)
+	return true
+}
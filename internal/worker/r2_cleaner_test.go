@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/metrics"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// stubObjectStore serves a fixed set of objects and records deletions.
+type stubObjectStore struct {
+	objects []r2.Object
+	deleted []string
+}
+
+func (s *stubObjectStore) List(ctx context.Context, prefix string) ([]r2.Object, error) {
+	var objects []r2.Object
+	for _, obj := range s.objects {
+		if strings.HasPrefix(obj.Key, prefix) {
+			objects = append(objects, obj)
+		}
+	}
+	return objects, nil
+}
+
+func (s *stubObjectStore) DeleteMany(ctx context.Context, keys []string) (int, error) {
+	s.deleted = append(s.deleted, keys...)
+	return len(keys), nil
+}
+
+// storageStateRepo returns the storage states of the jobs it knows.
+type storageStateRepo struct {
+	repository.JobRepository
+	states map[uuid.UUID]models.JobStorageState
+}
+
+func (r *storageStateRepo) GetStorageStates(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]models.JobStorageState, error) {
+	states := make(map[uuid.UUID]models.JobStorageState)
+	for _, id := range ids {
+		if state, ok := r.states[id]; ok {
+			states[id] = state
+		}
+	}
+	return states, nil
+}
+
+func TestR2AssetCleanerBreaker(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+
+	tests := []struct {
+		name        string
+		stored      int // jobs in the database, completed
+		orphans     int // jobs missing from the database
+		wantDeleted int
+		wantAborted string
+	}{
+		{name: "a few orphans are deleted", stored: 9, orphans: 1, wantDeleted: 1},
+		{name: "orphans within the ratio are deleted", stored: 10, orphans: 10, wantDeleted: 10},
+		{name: "too many orphans abort", stored: 9, orphans: 11, wantAborted: cleanupAbortMissingJobs},
+		{name: "an empty read aborts", stored: 0, orphans: 30, wantAborted: cleanupAbortNoJobs},
+		{name: "a single orphan with no stored job aborts", stored: 0, orphans: 1, wantAborted: cleanupAbortNoJobs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &stubObjectStore{}
+			repo := &storageStateRepo{states: make(map[uuid.UUID]models.JobStorageState)}
+			addJob := func() uuid.UUID {
+				id := uuid.New()
+				key, _ := r2.JobAssetKey(r2.AssetVideo, id.String())
+				store.objects = append(store.objects, r2.Object{Key: key, LastModified: old})
+				return id
+			}
+			for range tt.stored {
+				id := addJob()
+				repo.states[id] = models.JobStorageState{ID: id, Status: models.StatusCompleted, UpdatedAt: old}
+			}
+			for range tt.orphans {
+				addJob()
+			}
+
+			reg := prometheus.NewRegistry()
+			cleaner := &R2AssetCleaner{
+				r2Client: store,
+				jobRepo:  repo,
+				cfg:      R2CleanerConfig{FailedRetention: 24 * time.Hour, MaxDeletes: 100, MaxMissingRatio: 0.5},
+				metrics:  metrics.New(reg),
+				logger:   zap.NewNop(),
+			}
+			cleaner.Cleanup(context.Background())
+
+			if len(store.deleted) != tt.wantDeleted {
+				t.Errorf("deleted %d objects, want %d", len(store.deleted), tt.wantDeleted)
+			}
+			for _, key := range store.deleted {
+				rawID, _ := r2.JobIDFromKey(key)
+				if _, stored := repo.states[uuid.MustParse(rawID)]; stored {
+					t.Errorf("deleted %s of a completed job", key)
+				}
+			}
+
+			for _, reason := range []string{cleanupAbortNoJobs, cleanupAbortMissingJobs} {
+				want := 0.0
+				if reason == tt.wantAborted {
+					want = 1
+				}
+				if got := abortedPasses(t, reg, reason); got != want {
+					t.Errorf("aborted passes with reason %s = %v, want %v", reason, got, want)
+				}
+			}
+		})
+	}
+}
+
+// abortedPasses returns the ugc_r2_cleanup_aborted_total count of reason.
+func abortedPasses(t *testing.T, reg *prometheus.Registry, reason string) float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "ugc_r2_cleanup_aborted_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "reason" && label.GetValue() == reason {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,208 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// Job reconciliation settings.
+const (
+	// staleJobAge is how long a job may go without an update before its next task
+	// is checked. It covers the gap between a handler's write and its enqueue.
+	staleJobAge = time.Minute
+	// abandonedPendingJobAge is how long a job may stay pending before it is marked
+	// failed, unless its analyze task is still queued behind a backlog.
+	abandonedPendingJobAge = time.Hour
+	reconcileBatchSize     = 100
+)
+
+// TaskFinder reports whether a task with one of the given TaskIDs exists, in any state.
+type TaskFinder interface {
+	TaskExists(ctx context.Context, ids ...string) (bool, error)
+}
+
+// JobReconciler re-enqueues the next task of jobs that have none, e.g. when the
+// process crashed between inserting a job and enqueueing its first task, or when
+// Redis lost the queue. The next task follows from the job's status and stored
+// artifacts (tasks.ResumePointFor); every pipeline task has a deterministic TaskID,
+// so re-enqueueing is a no-op while the original task is still queued.
+type JobReconciler struct {
+	jobRepo repository.JobRepository
+	client  *asynq.Client
+	finder  TaskFinder
+	logger  *zap.Logger
+}
+
+// NewJobReconciler creates a new JobReconciler instance.
+func NewJobReconciler(jobRepo repository.JobRepository, client *asynq.Client, finder TaskFinder, logger *zap.Logger) *JobReconciler {
+	return &JobReconciler{
+		jobRepo: jobRepo,
+		client:  client,
+		finder:  finder,
+		logger:  logger.Named("reconciler"),
+	}
+}
+
+// Run reconciles once at startup and then every interval until ctx is done.
+func (r *JobReconciler) Run(ctx context.Context, interval time.Duration) {
+	r.Reconcile(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Reconcile(ctx)
+		}
+	}
+}
+
+// Reconcile performs a single reconciliation pass and returns the number of
+// tasks re-enqueued.
+func (r *JobReconciler) Reconcile(ctx context.Context) int {
+	now := time.Now()
+
+	if failed := r.failAbandonedPending(ctx, now.Add(-abandonedPendingJobAge)); failed > 0 {
+		r.logger.Warn("marked abandoned pending jobs as failed", zap.Int("count", failed))
+	}
+
+	enqueued := 0
+	afterID := uuid.Nil
+	for {
+		ids, err := r.jobRepo.ListStaleActive(ctx, now.Add(-staleJobAge), afterID, reconcileBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Error("failed to list stale active jobs", zap.Error(err))
+			}
+			break
+		}
+
+		for _, id := range ids {
+			if r.reconcileJob(ctx, id) {
+				enqueued++
+			}
+		}
+
+		if len(ids) < reconcileBatchSize || ctx.Err() != nil {
+			break
+		}
+		afterID = ids[len(ids)-1]
+	}
+
+	if enqueued > 0 {
+		r.logger.Warn("re-enqueued tasks for stranded jobs", zap.Int("count", enqueued))
+	}
+	return enqueued
+}
+
+// failAbandonedPending marks jobs pending since before pendingBefore as failed
+// when no analyze task exists for them; a job whose task is still waiting in a
+// backed-up queue is left to start. Returns the number of jobs failed.
+func (r *JobReconciler) failAbandonedPending(ctx context.Context, pendingBefore time.Time) int {
+	failed := 0
+	afterID := uuid.Nil
+	for {
+		ids, err := r.jobRepo.ListStalePending(ctx, pendingBefore, afterID, reconcileBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Error("failed to list abandoned pending jobs", zap.Error(err))
+			}
+			return failed
+		}
+
+		for _, id := range ids {
+			logger := r.logger.With(zap.String("job_id", id.String()))
+
+			exists, err := r.finder.TaskExists(ctx, tasks.DedupTaskID(tasks.TypeAnalyzeConcept, id))
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Error("failed to check for queued analyze task", zap.Error(err))
+				}
+				continue
+			}
+			if exists {
+				continue
+			}
+
+			ok, err := r.jobRepo.FailStalePending(ctx, id, pendingBefore, "job was never started")
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Error("failed to fail abandoned pending job", zap.Error(err))
+				}
+				continue
+			}
+			if ok {
+				failed++
+			}
+		}
+
+		if len(ids) < reconcileBatchSize || ctx.Err() != nil {
+			return failed
+		}
+		afterID = ids[len(ids)-1]
+	}
+}
+
+// reconcileJob re-enqueues the next task of one job if it has none, reporting
+// whether a task was enqueued.
+func (r *JobReconciler) reconcileJob(ctx context.Context, id uuid.UUID) bool {
+	logger := r.logger.With(zap.String("job_id", id.String()))
+
+	// Reload the job: it may have moved on since it was listed
+	job, err := r.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		if !errors.Is(err, repository.ErrJobNotFound) && ctx.Err() == nil {
+			logger.Error("failed to load job", zap.Error(err))
+		}
+		return false
+	}
+
+	if job.IsTerminal() || job.CancelledAt != nil || time.Since(job.UpdatedAt) < staleJobAge {
+		return false
+	}
+	point, ok := tasks.ResumePointFor(job)
+	if !ok {
+		return false
+	}
+	logger = logger.With(zap.String("status", job.Status), zap.String("task_type", point.TaskType))
+
+	exists, err := r.finder.TaskExists(ctx, point.TaskIDs...)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error("failed to check for queued task", zap.Error(err))
+		}
+		return false
+	}
+	if exists {
+		return false
+	}
+
+	task, err := point.Task(job.ID, "")
+	if err != nil {
+		logger.Error("failed to create task", zap.Error(err))
+		return false
+	}
+
+	if _, err := r.client.EnqueueContext(ctx, task); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			// Enqueued by its handler since the check
+			return false
+		}
+		logger.Error("failed to re-enqueue task", zap.Error(err))
+		return false
+	}
+
+	logger.Warn("re-enqueued missing task for stranded job")
+	return true
+}
@@ -0,0 +1,176 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// stalePendingRepo lists its pending jobs and records which ones were failed.
+type stalePendingRepo struct {
+	repository.JobRepository
+	pending []uuid.UUID
+	failed  []uuid.UUID
+}
+
+func (r *stalePendingRepo) ListStalePending(ctx context.Context, pendingBefore time.Time, afterID uuid.UUID, limit int) ([]uuid.UUID, error) {
+	if afterID != uuid.Nil {
+		return nil, nil
+	}
+	return r.pending, nil
+}
+
+func (r *stalePendingRepo) FailStalePending(ctx context.Context, id uuid.UUID, pendingBefore time.Time, errorMessage string) (bool, error) {
+	r.failed = append(r.failed, id)
+	return true, nil
+}
+
+// stubTaskFinder reports the TaskIDs it holds as existing.
+type stubTaskFinder struct {
+	ids map[string]bool
+	err error
+}
+
+func (f *stubTaskFinder) TaskExists(ctx context.Context, ids ...string) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	for _, id := range ids {
+		if f.ids[id] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestFailAbandonedPendingKeepsQueuedJobs(t *testing.T) {
+	queued, lost := uuid.New(), uuid.New()
+	repo := &stalePendingRepo{pending: []uuid.UUID{queued, lost}}
+	finder := &stubTaskFinder{ids: map[string]bool{tasks.DedupTaskID(tasks.TypeAnalyzeConcept, queued): true}}
+	r := NewJobReconciler(repo, nil, finder, zap.NewNop())
+
+	if failed := r.failAbandonedPending(context.Background(), time.Now()); failed != 1 {
+		t.Fatalf("failed %d jobs, want 1", failed)
+	}
+	if len(repo.failed) != 1 || repo.failed[0] != lost {
+		t.Fatalf("failed jobs %v, want only the one without an analyze task (%s)", repo.failed, lost)
+	}
+}
+
+func TestFailAbandonedPendingSkipsJobsWhenTheQueueIsUnreadable(t *testing.T) {
+	repo := &stalePendingRepo{pending: []uuid.UUID{uuid.New()}}
+	finder := &stubTaskFinder{err: errors.New("redis: connection refused")}
+	r := NewJobReconciler(repo, nil, finder, zap.NewNop())
+
+	if failed := r.failAbandonedPending(context.Background(), time.Now()); failed != 0 {
+		t.Fatalf("failed %d jobs with Redis down, want 0", failed)
+	}
+	if len(repo.failed) != 0 {
+		t.Fatalf("failed jobs %v with Redis down, want none", repo.failed)
+	}
+}
+
+// staleJobsRepo lists its jobs as stale and active, whatever their status, the
+// way a job that moved on after being listed would be.
+type staleJobsRepo struct {
+	repository.JobRepository
+	jobs []models.Job
+}
+
+func (r *staleJobsRepo) ListStalePending(ctx context.Context, pendingBefore time.Time, afterID uuid.UUID, limit int) ([]uuid.UUID, error) {
+	return nil, nil
+}
+
+func (r *staleJobsRepo) ListStaleActive(ctx context.Context, updatedBefore time.Time, afterID uuid.UUID, limit int) ([]uuid.UUID, error) {
+	if afterID != uuid.Nil {
+		return nil, nil
+	}
+	ids := make([]uuid.UUID, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		ids = append(ids, job.ID)
+	}
+	return ids, nil
+}
+
+func (r *staleJobsRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	for _, job := range r.jobs {
+		if job.ID == id {
+			return &job, nil
+		}
+	}
+	return nil, repository.ErrJobNotFound
+}
+
+// TestReconcileWipedQueue runs the reconciler twice against an empty Redis and
+// checks that every non-terminal job gets exactly one task and terminal jobs none.
+func TestReconcileWipedQueue(t *testing.T) {
+	ctx := context.Background()
+	client, redisURL := testutil.NewAsynqClient(t)
+	inspector, err := NewQueueInspector(redisURL)
+	if err != nil {
+		t.Fatalf("failed to create inspector: %v", err)
+	}
+	t.Cleanup(func() { inspector.Close() })
+
+	sunoTaskID := "suno-task-1"
+	updatedAt := time.Now().Add(-time.Hour)
+	job := func(status string) models.Job {
+		return models.Job{ID: uuid.New(), Status: status, UpdatedAt: updatedAt}
+	}
+	analyzed := job(models.StatusAnalyzing)
+	analyzed.SongPrompt = &models.SongPrompt{Prompt: "[Verse]\nแสงไฟ", Style: "thai pop"}
+	generating := job(models.StatusGeneratingMusic)
+	generating.SunoTaskID = &sunoTaskID
+	active := []models.Job{
+		job(models.StatusPending),
+		job(models.StatusAnalyzing),
+		analyzed,
+		generating,
+		job(models.StatusSelectingSong),
+		job(models.StatusGeneratingImage),
+		job(models.StatusProcessingVideo),
+		job(models.StatusUploadingYouTube),
+	}
+	terminal := []models.Job{job(models.StatusCompleted), job(models.StatusFailed)}
+	repo := &staleJobsRepo{jobs: append(slices.Clone(active), terminal...)}
+	r := NewJobReconciler(repo, client, inspector, zap.NewNop())
+
+	if enqueued := r.Reconcile(ctx); enqueued != len(active) {
+		t.Errorf("first pass enqueued %d tasks, want %d", enqueued, len(active))
+	}
+	if enqueued := r.Reconcile(ctx); enqueued != 0 {
+		t.Errorf("second pass enqueued %d tasks, want 0", enqueued)
+	}
+
+	queued, err := inspector.ListTasks(ctx, "", TaskStatePending, "", 1, 100)
+	if err != nil {
+		t.Fatalf("ListTasks() error = %v", err)
+	}
+	tasksPerJob := make(map[string]int)
+	for _, task := range queued {
+		if task.JobID == nil {
+			t.Fatalf("task %s has no job ID", task.ID)
+		}
+		tasksPerJob[*task.JobID]++
+	}
+	for _, job := range active {
+		if n := tasksPerJob[job.ID.String()]; n != 1 {
+			t.Errorf("%s job has %d tasks, want 1", job.Status, n)
+		}
+	}
+	for _, job := range terminal {
+		if n := tasksPerJob[job.ID.String()]; n != 0 {
+			t.Errorf("%s job has %d tasks, want none", job.Status, n)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Worker instance liveness settings.
+const (
+	// HeartbeatInterval is how often a worker refreshes its last_seen time.
+	HeartbeatInterval = 30 * time.Second
+	// instanceStaleAfter is how long after its last heartbeat an instance is reported dead.
+	instanceStaleAfter = 3 * HeartbeatInterval
+	// instancePruneAfter drops instances that crashed without deregistering.
+	instancePruneAfter = 24 * time.Hour
+)
+
+// Instance describes one running worker process. Alive is computed from LastSeen
+// when instances are listed.
+type Instance struct {
+	ID        string    `json:"id"`
+	Hostname  string    `json:"hostname"`
+	PID       int       `json:"pid"`
+	Version   string    `json:"version"`
+	StartedAt time.Time `json:"started_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	Alive     bool      `json:"alive"`
+}
+
+// NewInstance describes the current process as a worker instance with a fresh ID.
+func NewInstance(version string) *Instance {
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	return &Instance{
+		ID:        uuid.NewString(),
+		Hostname:  hostname,
+		PID:       os.Getpid(),
+		Version:   version,
+		StartedAt: now,
+		LastSeen:  now,
+	}
+}
+
+// InstanceRegistry tracks the running worker instances for operators.
+// Single-instance setups without Redis run without one.
+type InstanceRegistry interface {
+	// Register records the instance, or refreshes it when already registered.
+	Register(ctx context.Context, instance *Instance) error
+	// Deregister removes the instance on graceful shutdown.
+	Deregister(ctx context.Context, id string) error
+	// List returns the known instances, oldest first, with liveness computed.
+	List(ctx context.Context) ([]*Instance, error)
+}
+
+// redisInstanceRegistry implements InstanceRegistry as a Redis hash of instance
+// ID to JSON-encoded Instance.
+type redisInstanceRegistry struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisInstanceRegistry creates an InstanceRegistry stored under keyPrefix.
+func NewRedisInstanceRegistry(client *redis.Client, keyPrefix string) InstanceRegistry {
+	return &redisInstanceRegistry{
+		client: client,
+		key:    keyPrefix + ":workers",
+	}
+}
+
+// Register stores the instance with LastSeen set to now.
+func (r *redisInstanceRegistry) Register(ctx context.Context, instance *Instance) error {
+	instance.LastSeen = time.Now()
+	data, err := json.Marshal(instance)
+	if err != nil {
+		return fmt.Errorf("failed to marshal worker instance: %w", err)
+	}
+	if err := r.client.HSet(ctx, r.key, instance.ID, data).Err(); err != nil {
+		return fmt.Errorf("failed to register worker instance: %w", err)
+	}
+	return nil
+}
+
+// Deregister removes the instance.
+func (r *redisInstanceRegistry) Deregister(ctx context.Context, id string) error {
+	if err := r.client.HDel(ctx, r.key, id).Err(); err != nil {
+		return fmt.Errorf("failed to deregister worker instance: %w", err)
+	}
+	return nil
+}
+
+// List returns all instances, removing those not seen for instancePruneAfter.
+func (r *redisInstanceRegistry) List(ctx context.Context) ([]*Instance, error) {
+	entries, err := r.client.HGetAll(ctx, r.key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worker instances: %w", err)
+	}
+
+	now := time.Now()
+	instances := make([]*Instance, 0, len(entries))
+	var pruned []string
+	for id, data := range entries {
+		var instance Instance
+		if err := json.Unmarshal([]byte(data), &instance); err != nil || now.Sub(instance.LastSeen) > instancePruneAfter {
+			pruned = append(pruned, id)
+			continue
+		}
+		instance.Alive = now.Sub(instance.LastSeen) <= instanceStaleAfter
+		instances = append(instances, &instance)
+	}
+
+	if len(pruned) > 0 {
+		// Best-effort: a failed prune is retried on the next listing
+		_ = r.client.HDel(ctx, r.key, pruned...).Err()
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].StartedAt.Before(instances[j].StartedAt)
+	})
+	return instances, nil
+}
+
+// Heartbeat keeps one instance registered while the worker runs.
+type Heartbeat struct {
+	registry InstanceRegistry
+	instance *Instance
+	logger   *zap.Logger
+}
+
+// NewHeartbeat creates a new Heartbeat for instance.
+func NewHeartbeat(registry InstanceRegistry, instance *Instance, logger *zap.Logger) *Heartbeat {
+	return &Heartbeat{
+		registry: registry,
+		instance: instance,
+		logger:   logger.Named("heartbeat"),
+	}
+}
+
+// Run registers the instance and refreshes it every interval until ctx is done.
+func (h *Heartbeat) Run(ctx context.Context, interval time.Duration) {
+	h.beat(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.beat(ctx)
+		}
+	}
+}
+
+// beat refreshes the instance's registration. Failures are only logged; the
+// instance shows as dead until the next successful beat.
+func (h *Heartbeat) beat(ctx context.Context) {
+	if err := h.registry.Register(ctx, h.instance); err != nil && ctx.Err() == nil {
+		h.logger.Warn("failed to refresh worker registration", zap.Error(err))
+	}
+}
+
+// Deregister removes the instance from the registry.
+func (h *Heartbeat) Deregister(ctx context.Context) {
+	if err := h.registry.Deregister(ctx, h.instance.ID); err != nil {
+		h.logger.Warn("failed to deregister worker", zap.Error(err))
+		return
+	}
+	h.logger.Info("worker deregistered", zap.String("worker_id", h.instance.ID))
+}
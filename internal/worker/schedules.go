@@ -0,0 +1,236 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/service"
+	apperrors "github.com/jaochai/ugc/pkg/errors"
+)
+
+// Job scheduler settings.
+const (
+	scheduleBatchSize = 50
+	schedulerLockKey  = "ugc:scheduler:lock"
+)
+
+// JobScheduler turns due job schedules into normal jobs.
+//
+// Every worker instance runs the loop; a short Redis lock keeps instances from
+// scanning at the same time, and Claim guarantees each scheduled run creates at
+// most one job even without Redis. A run is claimed before its job is created, so
+// a transient failure skips that run rather than firing twice. Failures that will
+// not fix themselves (missing API keys, disabled account) disable the schedule
+// with the reason stored for the user. While job intake is paused no schedule
+// runs; due schedules run once when it resumes.
+type JobScheduler struct {
+	scheduleRepo    repository.JobScheduleRepository
+	userRepo        repository.UserRepository
+	templateService service.JobTemplateService
+	jobService      service.JobService
+	keyService      service.ProviderKeyService
+	settingsService service.RuntimeSettingsService
+	outbox          *Outbox
+	redisClient     *redis.Client
+	logger          *zap.Logger
+}
+
+// NewJobScheduler creates a new JobScheduler instance. redisClient may be nil.
+func NewJobScheduler(
+	scheduleRepo repository.JobScheduleRepository,
+	userRepo repository.UserRepository,
+	templateService service.JobTemplateService,
+	jobService service.JobService,
+	keyService service.ProviderKeyService,
+	settingsService service.RuntimeSettingsService,
+	outbox *Outbox,
+	redisClient *redis.Client,
+	logger *zap.Logger,
+) *JobScheduler {
+	return &JobScheduler{
+		scheduleRepo:    scheduleRepo,
+		userRepo:        userRepo,
+		templateService: templateService,
+		jobService:      jobService,
+		keyService:      keyService,
+		settingsService: settingsService,
+		outbox:          outbox,
+		redisClient:     redisClient,
+		logger:          logger.Named("scheduler"),
+	}
+}
+
+// Run checks for due schedules every interval until ctx is done.
+func (s *JobScheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.acquireLock(ctx, interval/2) {
+				s.RunDue(ctx)
+			}
+		}
+	}
+}
+
+// acquireLock reports whether this instance should scan now. The lock is left to
+// expire so other instances skip the rest of the window.
+func (s *JobScheduler) acquireLock(ctx context.Context, ttl time.Duration) bool {
+	if s.redisClient == nil {
+		return true
+	}
+
+	acquired, err := s.redisClient.SetNX(ctx, schedulerLockKey, uuid.NewString(), ttl).Result()
+	if err != nil {
+		// Claim still prevents double runs, so scan anyway
+		s.logger.Warn("failed to acquire scheduler lock", zap.Error(err))
+		return true
+	}
+	return acquired
+}
+
+// RunDue creates jobs for every schedule that is due.
+func (s *JobScheduler) RunDue(ctx context.Context) {
+	if err := s.settingsService.CheckJobIntake(ctx); err != nil {
+		s.logger.Info("job intake is paused, leaving due schedules for later")
+		return
+	}
+
+	now := time.Now()
+
+	schedules, err := s.scheduleRepo.ListDue(ctx, now, scheduleBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error("failed to list due schedules", zap.Error(err))
+		}
+		return
+	}
+
+	for _, schedule := range schedules {
+		s.runSchedule(ctx, schedule, now)
+	}
+}
+
+// runSchedule claims one due schedule and creates its job.
+func (s *JobScheduler) runSchedule(ctx context.Context, schedule *models.JobSchedule, now time.Time) {
+	logger := s.logger.With(
+		zap.String("schedule_id", schedule.ID.String()),
+		zap.String("user_id", schedule.UserID.String()),
+	)
+
+	next, err := schedule.NextRun(now)
+	if err != nil {
+		s.disable(ctx, schedule, fmt.Sprintf("invalid schedule: %v", err), logger)
+		return
+	}
+
+	claimed, err := s.scheduleRepo.Claim(ctx, schedule.ID, schedule.NextRunAt, next)
+	if err != nil {
+		logger.Error("failed to claim schedule", zap.Error(err))
+		return
+	}
+	if !claimed {
+		logger.Debug("schedule already claimed by another scheduler")
+		return
+	}
+
+	job, err := s.createJob(ctx, schedule)
+	if err != nil {
+		if isPermanentScheduleError(err) {
+			s.disable(ctx, schedule, err.Error(), logger)
+			return
+		}
+		logger.Error("failed to create scheduled job, skipping this run", zap.Error(err))
+		return
+	}
+
+	if err := s.scheduleRepo.RecordJob(ctx, schedule.ID, job.ID); err != nil {
+		logger.Warn("failed to record scheduled job", zap.Error(err))
+	}
+
+	logger.Info("scheduled job created",
+		zap.String("job_id", job.ID.String()),
+		zap.Time("next_run_at", next),
+	)
+}
+
+// createJob creates and enqueues the job for one scheduled run.
+func (s *JobScheduler) createJob(ctx context.Context, schedule *models.JobSchedule) (*models.Job, error) {
+	user, err := s.userRepo.GetByID(ctx, schedule.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	if user.Disabled {
+		return nil, apperrors.NewForbidden("account is disabled")
+	}
+	keySources, err := s.keyService.RequireKeys(ctx, user, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	input := models.CreateJobInput{Concept: schedule.Concept}
+	if schedule.TemplateID != nil {
+		template, err := s.templateService.Get(ctx, schedule.UserID, *schedule.TemplateID)
+		if err != nil {
+			s.keyService.ReleaseKeys(ctx, keySources, 1)
+			return nil, err
+		}
+		input = template.Apply(input)
+	}
+	input.OpenRouterKeySource = keySources.OpenRouter
+	input.KIEKeySource = keySources.KIE
+	input.OrgID = user.OrgID
+
+	job, err := s.jobService.Create(ctx, user, input)
+	if err != nil {
+		s.keyService.ReleaseKeys(ctx, keySources, 1)
+		return nil, err
+	}
+
+	task, err := NewAnalyzeConceptTask(job.ID, "")
+	if err != nil {
+		_ = s.jobService.MarkFailed(ctx, job.ID, "failed to enqueue analyze task")
+		return nil, fmt.Errorf("failed to create analyze concept task: %w", err)
+	}
+	if err := s.outbox.Enqueue(ctx, task, job.ID); err != nil && !isDuplicateTaskError(err) {
+		// The job stays pending; the job reconciler re-enqueues it
+		s.logger.Error("failed to enqueue scheduled job, leaving it for reconciliation",
+			zap.String("job_id", job.ID.String()),
+			zap.Error(err),
+		)
+	}
+
+	return job, nil
+}
+
+// disable turns off a schedule that cannot run and stores why.
+func (s *JobScheduler) disable(ctx context.Context, schedule *models.JobSchedule, reason string, logger *zap.Logger) {
+	if err := s.scheduleRepo.Disable(ctx, schedule.ID, reason); err != nil {
+		logger.Error("failed to disable schedule", zap.String("reason", reason), zap.Error(err))
+		return
+	}
+	logger.Warn("schedule disabled", zap.String("reason", reason))
+}
+
+// isPermanentScheduleError reports whether a job creation failure needs the user to
+// act (a 4xx app error such as a missing API key or deleted template) rather than
+// being a transient server-side problem. An exhausted daily quota clears by itself.
+func isPermanentScheduleError(err error) bool {
+	status := apperrors.HTTPStatus(err)
+	if status == http.StatusTooManyRequests {
+		return false
+	}
+	return status >= http.StatusBadRequest && status < http.StatusInternalServerError
+}
@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+func TestRunDueSkipsWhileJobIntakePaused(t *testing.T) {
+	tests := []struct {
+		name         string
+		paused       bool
+		wantListDues int
+	}{
+		{name: "intake open", paused: false, wantListDues: 1},
+		{name: "intake paused", paused: true, wantListDues: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := zap.NewNop()
+			settings := testutil.NewFakeRuntimeSettingRepository(models.RuntimeSettings{
+				JobIntakePaused: tt.paused,
+				BannerMessage:   "provider maintenance",
+			})
+			scheduleRepo := &testutil.FakeJobScheduleRepository{}
+			scheduler := NewJobScheduler(scheduleRepo, nil, nil, nil, nil,
+				service.NewRuntimeSettingsService(settings, logger), nil, nil, logger)
+
+			scheduler.RunDue(context.Background())
+
+			if got := scheduleRepo.ListDues(); got != tt.wantListDues {
+				t.Errorf("ListDue called %d times, want %d", got, tt.wantListDues)
+			}
+		})
+	}
+}
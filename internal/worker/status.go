@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/service"
+)
+
+// Status page settings.
+const (
+	// StatusCacheTTL is how long an aggregated status is served before it is
+	// recomputed; the status endpoint tells CDNs to cache it as long.
+	StatusCacheTTL = time.Minute
+	// statusWindow is how far back job outcomes and provider errors are counted.
+	statusWindow = 15 * time.Minute
+	// providerMinCalls is the fewest calls in statusWindow a provider status is derived from.
+	providerMinCalls = 5
+	// providerDownRate and providerDegradedRate are the error rates from which a
+	// provider is reported down or degraded.
+	providerDownRate     = 0.5
+	providerDegradedRate = 0.2
+	// queueMediumFactor and queueHighFactor, times the worker concurrency, are the
+	// pending task counts from which the queue depth is medium or high.
+	queueMediumFactor = 1
+	queueHighFactor   = 4
+)
+
+// StatusAggregator computes the public service status from recent job outcomes,
+// provider error rates and the queue depth, caching it for StatusCacheTTL so
+// the unauthenticated endpoint cannot load the database.
+type StatusAggregator struct {
+	jobRepo       repository.JobRepository
+	inspector     QueueInspector
+	providerStats service.ProviderStats
+	concurrency   int
+	logger        *zap.Logger
+
+	mu     sync.Mutex
+	status *models.ServiceStatus
+	expiry time.Time
+}
+
+// NewStatusAggregator creates a new StatusAggregator. inspector and
+// providerStats may be nil, leaving the queue depth empty and the providers
+// unknown. concurrency is the number of tasks the workers process at once.
+func NewStatusAggregator(jobRepo repository.JobRepository, inspector QueueInspector, providerStats service.ProviderStats, concurrency int, logger *zap.Logger) *StatusAggregator {
+	return &StatusAggregator{
+		jobRepo:       jobRepo,
+		inspector:     inspector,
+		providerStats: providerStats,
+		concurrency:   max(concurrency, 1),
+		logger:        logger.Named("status"),
+	}
+}
+
+// Status returns the cached service status, recomputing it when it has expired.
+// Parts that cannot be read are reported as unknown rather than failing the call.
+func (a *StatusAggregator) Status(ctx context.Context) models.ServiceStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if a.status != nil && now.Before(a.expiry) {
+		return *a.status
+	}
+
+	status := &models.ServiceStatus{
+		PipelineOK: a.pipelineOK(ctx, now),
+		Providers:  a.providers(ctx),
+		QueueDepth: a.queueDepth(ctx),
+		UpdatedAt:  now.UTC(),
+	}
+	a.status = status
+	a.expiry = now.Add(StatusCacheTTL)
+	return *status
+}
+
+// pipelineOK reports whether jobs are getting through: false when jobs failed
+// in statusWindow and none completed, or the jobs cannot be counted.
+func (a *StatusAggregator) pipelineOK(ctx context.Context, now time.Time) bool {
+	completed, failed, err := a.jobRepo.CountFinishedSince(ctx, now.Add(-statusWindow))
+	if err != nil {
+		a.logger.Warn("failed to count finished jobs", zap.Error(err))
+		return false
+	}
+	return completed > 0 || failed == 0
+}
+
+// providers returns the status of each of models.StatusProviders from its error rate.
+func (a *StatusAggregator) providers(ctx context.Context) map[string]string {
+	statuses := make(map[string]string, len(models.StatusProviders))
+	for _, provider := range models.StatusProviders {
+		statuses[provider] = models.ProviderUnknown
+	}
+	if a.providerStats == nil {
+		return statuses
+	}
+
+	counts, err := a.providerStats.Counts(ctx, models.StatusProviders, statusWindow)
+	if err != nil {
+		a.logger.Warn("failed to read provider calls", zap.Error(err))
+		return statuses
+	}
+	for provider, c := range counts {
+		if c.Calls < providerMinCalls {
+			continue
+		}
+		rate := float64(c.Errors) / float64(c.Calls)
+		switch {
+		case rate >= providerDownRate:
+			statuses[provider] = models.ProviderDown
+		case rate >= providerDegradedRate:
+			statuses[provider] = models.ProviderDegraded
+		default:
+			statuses[provider] = models.ProviderOperational
+		}
+	}
+	return statuses
+}
+
+// queueDepth buckets the pending tasks of all queues, or returns "" when they cannot be read.
+func (a *StatusAggregator) queueDepth(ctx context.Context) string {
+	if a.inspector == nil {
+		return ""
+	}
+
+	queues, err := a.inspector.Queues(ctx)
+	if err != nil {
+		a.logger.Warn("failed to read queue stats", zap.Error(err))
+		return ""
+	}
+	pending := 0
+	for _, q := range queues {
+		pending += q.Pending
+	}
+
+	switch {
+	case pending >= queueHighFactor*a.concurrency:
+		return models.QueueDepthHigh
+	case pending >= queueMediumFactor*a.concurrency:
+		return models.QueueDepthMedium
+	default:
+		return models.QueueDepthLow
+	}
+}
@@ -0,0 +1,192 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// Synchronous run limits. A run holds its HTTP request open, so it gets far less
+// time than a job on the workers.
+const (
+	// syncTaskTimeout bounds each task, including the provider polling inside it.
+	syncTaskTimeout = 5 * time.Minute
+	// SyncRunTimeout bounds the whole pipeline.
+	SyncRunTimeout = 15 * time.Minute
+	// syncMaxTasks stops a run whose handlers keep enqueueing tasks.
+	syncMaxTasks = 50
+)
+
+// Synchronous run events.
+const (
+	SyncEventCreated      = "created"
+	SyncEventTaskStarted  = "task_started"
+	SyncEventTaskFinished = "task_finished"
+	SyncEventDone         = "done"
+	SyncEventError        = "error"
+)
+
+// SyncEvent is one line of a synchronous run's NDJSON progress stream.
+type SyncEvent struct {
+	Event string `json:"event"`
+	// Task is the task type of task events.
+	Task string `json:"task,omitempty"`
+	// Status is the job's status after a finished task.
+	Status     string `json:"status,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Job is the final job of done and error events.
+	Job *models.JobResponse `json:"job,omitempty"`
+}
+
+// SyncRunner runs a job's whole pipeline inside the calling goroutine, for local
+// development and integration tests without workers. The tasks go through the
+// same handlers and middleware as on the workers; only the queue is replaced by
+// an in-memory one and providers are always polled, since no callback can reach
+// a run that is waiting inline.
+type SyncRunner struct {
+	deps   tasks.Dependencies
+	logger *zap.Logger
+}
+
+// NewSyncRunner creates a SyncRunner from the dependencies the workers use.
+func NewSyncRunner(deps *tasks.Dependencies, logger *zap.Logger) *SyncRunner {
+	return &SyncRunner{
+		deps:   *deps,
+		logger: logger.Named("sync_runner"),
+	}
+}
+
+// Run processes jobID from concept analysis until no task is left, calling emit
+// before and after every task. A task that returns an error stops the run and
+// fails the job if its handler did not; the error is returned after the error event.
+func (r *SyncRunner) Run(ctx context.Context, jobID uuid.UUID, traceID string, emit func(SyncEvent)) error {
+	ctx, cancel := context.WithTimeout(ctx, SyncRunTimeout)
+	defer cancel()
+
+	queue := &inlineQueue{}
+	deps := r.deps
+	deps.AsynqClient = queue
+	deps.WebhookBaseURL = ""
+
+	mux := asynq.NewServeMux()
+	mux.Use(traceMiddleware)
+	mux.Use(userContextMiddleware)
+	registerTaskHandlers(mux, &deps)
+
+	first, err := NewAnalyzeConceptTask(jobID, traceID)
+	if err != nil {
+		return fmt.Errorf("failed to create analyze concept task: %w", err)
+	}
+	if _, err := queue.Enqueue(first); err != nil {
+		return err
+	}
+
+	logger := r.logger.With(zap.String("job_id", jobID.String()), zap.String("trace_id", traceID))
+	for n := 0; ; n++ {
+		task := queue.next()
+		if task == nil {
+			break
+		}
+		if n == syncMaxTasks {
+			return r.stop(ctx, jobID, fmt.Errorf("run exceeded %d tasks", syncMaxTasks), emit)
+		}
+
+		emit(SyncEvent{Event: SyncEventTaskStarted, Task: task.Type()})
+		start := time.Now()
+		taskCtx, cancelTask := context.WithTimeout(ctx, syncTaskTimeout)
+		err := mux.ProcessTask(taskCtx, task)
+		cancelTask()
+
+		finished := SyncEvent{Event: SyncEventTaskFinished, Task: task.Type(), DurationMS: time.Since(start).Milliseconds()}
+		if job, getErr := deps.JobRepo.GetByID(context.WithoutCancel(ctx), jobID); getErr == nil {
+			finished.Status = job.Status
+		}
+		if err != nil {
+			finished.Error = err.Error()
+		}
+		emit(finished)
+
+		if err != nil {
+			logger.Warn("synchronous task failed", zap.String("type", task.Type()), zap.Error(err))
+			return r.stop(ctx, jobID, err, emit)
+		}
+	}
+
+	job, err := deps.JobRepo.GetByID(context.WithoutCancel(ctx), jobID)
+	if err != nil {
+		return fmt.Errorf("failed to get job: %w", err)
+	}
+	emit(SyncEvent{Event: SyncEventDone, Status: job.Status, Job: job.ToResponse()})
+	return nil
+}
+
+// stop fails jobID unless its handler already ended it, then emits the error event.
+func (r *SyncRunner) stop(ctx context.Context, jobID uuid.UUID, cause error, emit func(SyncEvent)) error {
+	// The run's deadline may be what stopped it; the job must still be updated
+	ctx = context.WithoutCancel(ctx)
+
+	event := SyncEvent{Event: SyncEventError, Error: cause.Error()}
+	job, err := r.deps.JobRepo.GetByID(ctx, jobID)
+	if err == nil && !job.IsTerminal() {
+		failure := models.JobFailure{Message: "synchronous run stopped: " + cause.Error()}
+		if err := r.deps.JobRepo.UpdateWithFailure(ctx, jobID, failure); err != nil {
+			r.logger.Error("failed to mark synchronous job as failed", zap.String("job_id", jobID.String()), zap.Error(err))
+		}
+		job, err = r.deps.JobRepo.GetByID(ctx, jobID)
+	}
+	if err == nil {
+		event.Status = job.Status
+		event.Job = job.ToResponse()
+	}
+	emit(event)
+
+	if errors.Is(cause, asynq.SkipRetry) {
+		return cause
+	}
+	return fmt.Errorf("synchronous run failed: %w", cause)
+}
+
+// inlineQueue is the tasks.TaskEnqueuer of a synchronous run. Tasks run in the
+// order they are enqueued, immediately; scheduling options are ignored.
+type inlineQueue struct {
+	mu    sync.Mutex
+	tasks []*asynq.Task
+}
+
+// Enqueue appends task to the queue.
+func (q *inlineQueue) Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.tasks = append(q.tasks, task)
+	return &asynq.TaskInfo{
+		ID:      uuid.NewString(),
+		Queue:   "inline",
+		Type:    task.Type(),
+		Payload: task.Payload(),
+		State:   asynq.TaskStatePending,
+	}, nil
+}
+
+// next removes and returns the oldest task, or nil when the queue is empty.
+func (q *inlineQueue) next() *asynq.Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.tasks) == 0 {
+		return nil
+	}
+	task := q.tasks[0]
+	q.tasks = q.tasks[1:]
+	return task
+}
@@ -2,87 +2,264 @@
 package worker
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/worker/tasks"
 )
 
 // NewAnalyzeConceptTask creates a new analyze concept task.
-func NewAnalyzeConceptTask(jobID uuid.UUID) (*asynq.Task, error) {
-	payload := TaskPayload{
-		JobID: jobID,
+// Uses TaskID for deduplication so a job is never analyzed twice concurrently.
+func NewAnalyzeConceptTask(jobID uuid.UUID, traceID string) (*asynq.Task, error) {
+	payload := tasks.TaskPayload{
+		JobID:   jobID,
+		TraceID: traceID,
 	}
-	payloadBytes, err := json.Marshal(payload)
+	payloadBytes, err := payload.Marshal()
 	if err != nil {
 		return nil, err
 	}
-	return asynq.NewTask(TypeAnalyzeConcept, payloadBytes), nil
+	// TaskID lets the job reconciler re-enqueue idempotently
+	return asynq.NewTask(tasks.TypeAnalyzeConcept, payloadBytes, asynq.TaskID(tasks.DedupTaskID(tasks.TypeAnalyzeConcept, jobID))), nil
 }
 
 // NewGenerateMusicTask creates a new generate music task.
-func NewGenerateMusicTask(jobID uuid.UUID) (*asynq.Task, error) {
-	payload := TaskPayload{
-		JobID: jobID,
+// Uses TaskID so the job reconciler can tell whether it is still queued.
+func NewGenerateMusicTask(jobID uuid.UUID, traceID string) (*asynq.Task, error) {
+	payload := tasks.TaskPayload{
+		JobID:   jobID,
+		TraceID: traceID,
 	}
-	payloadBytes, err := json.Marshal(payload)
+	payloadBytes, err := payload.Marshal()
 	if err != nil {
 		return nil, err
 	}
-	return asynq.NewTask(TypeGenerateMusic, payloadBytes), nil
+	return asynq.NewTask(tasks.TypeGenerateMusic, payloadBytes, asynq.TaskID(tasks.DedupTaskID(tasks.TypeGenerateMusic, jobID))), nil
 }
 
 // NewSelectSongTask creates a new select song task.
 // Uses TaskID for deduplication to prevent duplicate processing from webhook retries.
-func NewSelectSongTask(jobID uuid.UUID) (*asynq.Task, error) {
-	payload := TaskPayload{
-		JobID: jobID,
+func NewSelectSongTask(jobID uuid.UUID, traceID string) (*asynq.Task, error) {
+	payload := tasks.TaskPayload{
+		JobID:   jobID,
+		TraceID: traceID,
 	}
-	payloadBytes, err := json.Marshal(payload)
+	payloadBytes, err := payload.Marshal()
 	if err != nil {
 		return nil, err
 	}
 	// TaskID ensures only one select song task can be enqueued per job
-	taskID := fmt.Sprintf("select-song-%s", jobID.String())
-	return asynq.NewTask(TypeSelectSong, payloadBytes, asynq.TaskID(taskID)), nil
+	return asynq.NewTask(tasks.TypeSelectSong, payloadBytes, asynq.TaskID(tasks.DedupTaskID(tasks.TypeSelectSong, jobID))), nil
+}
+
+// NewFinalizeSongsTask creates a task that starts song selection unless Suno's
+// "complete" callback moves the job on first. Enqueue it with asynq.ProcessIn
+// set to the grace period.
+// TaskID ensures only one finalize task is scheduled per job.
+func NewFinalizeSongsTask(jobID uuid.UUID, traceID string) (*asynq.Task, error) {
+	payload := tasks.TaskPayload{
+		JobID:   jobID,
+		TraceID: traceID,
+	}
+	payloadBytes, err := payload.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(tasks.TypeFinalizeSongs, payloadBytes, asynq.TaskID(tasks.DedupTaskID(tasks.TypeFinalizeSongs, jobID))), nil
 }
 
 // NewGenerateImageTask creates a new generate image task.
-func NewGenerateImageTask(jobID uuid.UUID) (*asynq.Task, error) {
-	payload := TaskPayload{
-		JobID: jobID,
+// Uses TaskID so the job reconciler can tell whether it is still queued.
+func NewGenerateImageTask(jobID uuid.UUID, traceID string) (*asynq.Task, error) {
+	payload := tasks.TaskPayload{
+		JobID:   jobID,
+		TraceID: traceID,
 	}
-	payloadBytes, err := json.Marshal(payload)
+	payloadBytes, err := payload.Marshal()
 	if err != nil {
 		return nil, err
 	}
-	return asynq.NewTask(TypeGenerateImage, payloadBytes), nil
+	return asynq.NewTask(tasks.TypeGenerateImage, payloadBytes, asynq.TaskID(tasks.DedupTaskID(tasks.TypeGenerateImage, jobID))), nil
+}
+
+// NewSelectImageTask creates a new select image task.
+// Uses TaskID for deduplication so only the callback completing the last image candidate advances the job.
+func NewSelectImageTask(jobID uuid.UUID, traceID string) (*asynq.Task, error) {
+	payload := tasks.TaskPayload{
+		JobID:   jobID,
+		TraceID: traceID,
+	}
+	payloadBytes, err := payload.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	// TaskID ensures only one select image task can be enqueued per job
+	return asynq.NewTask(tasks.TypeSelectImage, payloadBytes, asynq.TaskID(tasks.DedupTaskID(tasks.TypeSelectImage, jobID))), nil
 }
 
 // NewProcessVideoTask creates a new process video task.
 // Uses TaskID for deduplication to prevent duplicate processing from webhook retries.
-func NewProcessVideoTask(jobID uuid.UUID) (*asynq.Task, error) {
-	payload := TaskPayload{
-		JobID: jobID,
+func NewProcessVideoTask(jobID uuid.UUID, traceID string) (*asynq.Task, error) {
+	payload := tasks.TaskPayload{
+		JobID:   jobID,
+		TraceID: traceID,
 	}
-	payloadBytes, err := json.Marshal(payload)
+	payloadBytes, err := payload.Marshal()
 	if err != nil {
 		return nil, err
 	}
 	// TaskID ensures only one process video task can be enqueued per job
-	taskID := fmt.Sprintf("process-video-%s", jobID.String())
-	return asynq.NewTask(TypeProcessVideo, payloadBytes, asynq.TaskID(taskID)), nil
+	return asynq.NewTask(tasks.TypeProcessVideo, payloadBytes, asynq.TaskID(tasks.DedupTaskID(tasks.TypeProcessVideo, jobID))), nil
+}
+
+// NewRetryTask creates the task that restarts a retried job from step, one of
+// the models.RetryFrom* constants.
+func NewRetryTask(step string, jobID uuid.UUID, traceID string) (*asynq.Task, error) {
+	switch step {
+	case models.RetryFromAnalyze:
+		return NewAnalyzeConceptTask(jobID, traceID)
+	case models.RetryFromMusic:
+		return NewGenerateMusicTask(jobID, traceID)
+	case models.RetryFromSelectSong:
+		return NewSelectSongTask(jobID, traceID)
+	case models.RetryFromImage:
+		return NewGenerateImageTask(jobID, traceID)
+	case models.RetryFromVideo:
+		return NewProcessVideoTask(jobID, traceID)
+	}
+	return nil, fmt.Errorf("unknown retry step %q", step)
+}
+
+// NewDeleteUserDataTask creates a task that removes a deleted user's jobs, assets and secrets.
+// Enqueue it with DeleteUserDataTaskOptions.
+func NewDeleteUserDataTask(userID uuid.UUID, traceID string) (*asynq.Task, error) {
+	payload := tasks.UserTaskPayload{
+		UserID:  userID,
+		TraceID: traceID,
+	}
+	payloadBytes, err := payload.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(tasks.TypeDeleteUserData, payloadBytes), nil
+}
+
+// DeleteUserDataTaskOptions returns the enqueue options of a delete user data task.
+// TaskID makes repeated deletion requests and sweeps enqueue a single cleanup.
+func DeleteUserDataTaskOptions(userID uuid.UUID) []asynq.Option {
+	return []asynq.Option{
+		asynq.TaskID("delete-user-data-" + userID.String()),
+		asynq.Queue("low"),
+	}
+}
+
+// reencryptSecretsTaskID is the TaskID of the re-encryption task; only one may be queued at a time.
+const reencryptSecretsTaskID = "reencrypt-secrets"
+
+// NewReencryptSecretsTask creates a task that rewrites every stored secret with the primary encryption key.
+func NewReencryptSecretsTask(requestedBy uuid.UUID, traceID string) (*asynq.Task, error) {
+	payload := tasks.MaintenanceTaskPayload{
+		RequestedBy: requestedBy,
+		TraceID:     traceID,
+	}
+	payloadBytes, err := payload.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(tasks.TypeReencryptSecrets, payloadBytes,
+		asynq.TaskID(reencryptSecretsTaskID),
+		asynq.Queue("low"),
+	), nil
+}
+
+// reprocessWebhookMaxRetry bounds how often a deferred webhook callback is re-applied.
+// With asynq's exponential backoff this covers several hours of outage.
+const reprocessWebhookMaxRetry = 12
+
+// NewReprocessWebhookTask creates a task that re-applies the stored webhook callback eventID.
+func NewReprocessWebhookTask(eventID uuid.UUID, traceID string) (*asynq.Task, error) {
+	payload := tasks.WebhookTaskPayload{
+		EventID: eventID,
+		TraceID: traceID,
+	}
+	payloadBytes, err := payload.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(tasks.TypeReprocessWebhook, payloadBytes,
+		asynq.TaskID(fmt.Sprintf("reprocess-webhook-%s", eventID.String())),
+		asynq.MaxRetry(reprocessWebhookMaxRetry),
+	), nil
 }
 
 // NewUploadAssetsTask creates a new upload assets task.
-func NewUploadAssetsTask(jobID uuid.UUID) (*asynq.Task, error) {
-	payload := TaskPayload{
-		JobID: jobID,
+// Uses TaskID so the job reconciler can tell whether it is still queued.
+func NewUploadAssetsTask(jobID uuid.UUID, traceID string) (*asynq.Task, error) {
+	payload := tasks.TaskPayload{
+		JobID:   jobID,
+		TraceID: traceID,
 	}
-	payloadBytes, err := json.Marshal(payload)
+	payloadBytes, err := payload.Marshal()
 	if err != nil {
 		return nil, err
 	}
-	return asynq.NewTask(TypeUploadAssets, payloadBytes), nil
+	return asynq.NewTask(tasks.TypeUploadAssets, payloadBytes, asynq.TaskID(tasks.DedupTaskID(tasks.TypeUploadAssets, jobID))), nil
+}
+
+// notifyUserMaxRetry is how often a failed user webhook delivery is retried.
+const notifyUserMaxRetry = 3
+
+// NewNotifyUserTask creates a task that delivers event for jobID to one user
+// webhook. Enqueue it with notifyUserTaskOptions.
+func NewNotifyUserTask(jobID, webhookID uuid.UUID, event, traceID string) (*asynq.Task, error) {
+	payload := tasks.NotifyUserPayload{
+		JobID:     jobID,
+		WebhookID: webhookID,
+		Event:     event,
+		TraceID:   traceID,
+	}
+	payloadBytes, err := payload.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(tasks.TypeNotifyUser, payloadBytes), nil
+}
+
+// notifyUserTaskOptions returns the enqueue options of a notify user task.
+// TaskID ensures each webhook is notified at most once per job event.
+func notifyUserTaskOptions(jobID, webhookID uuid.UUID, event string) []asynq.Option {
+	return []asynq.Option{
+		asynq.TaskID(fmt.Sprintf("notify-user-%s-%s-%s", webhookID.String(), jobID.String(), event)),
+		asynq.MaxRetry(notifyUserMaxRetry),
+	}
+}
+
+// sendEmailMaxRetry is how often a failed notification email is retried.
+const sendEmailMaxRetry = 3
+
+// NewSendEmailTask creates a task that emails the owner of jobID about event.
+// Enqueue it with sendEmailTaskOptions.
+func NewSendEmailTask(jobID uuid.UUID, event, traceID string) (*asynq.Task, error) {
+	payload := tasks.SendEmailPayload{
+		JobID:   jobID,
+		Event:   event,
+		TraceID: traceID,
+	}
+	payloadBytes, err := payload.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(tasks.TypeSendEmail, payloadBytes), nil
+}
+
+// sendEmailTaskOptions returns the enqueue options of a send email task.
+// TaskID ensures the owner is emailed at most once per job event.
+func sendEmailTaskOptions(jobID uuid.UUID, event string) []asynq.Option {
+	return []asynq.Option{
+		asynq.TaskID(fmt.Sprintf("send-email-%s-%s", jobID.String(), event)),
+		asynq.MaxRetry(sendEmailMaxRetry),
+	}
 }
@@ -0,0 +1,91 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Audio availability check settings. A 10 second MP3 is well over minAudioBytes,
+// so anything smaller is an error page or an empty file.
+const (
+	audioCheckTimeout = 15 * time.Second
+	minAudioBytes     = 32 << 10
+)
+
+// errAudioUnavailable is returned when a song's audio URL does not serve a usable file.
+var errAudioUnavailable = errors.New("audio unavailable")
+
+// audioCheckClient does not follow redirects: the URL was validated, its redirect
+// targets were not.
+var audioCheckClient = &http.Client{
+	Timeout: audioCheckTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// checkAudioURL verifies that audioURL is on an allowed host and serves a file of
+// at least minAudioBytes, so FFmpeg is not started on a dead Suno link. A HEAD
+// request is tried first; hosts that reject HEAD or omit the size get a one-byte
+// ranged GET, whose Content-Range carries the full size. A file whose size is
+// still unknown after both is accepted, since the host did serve it.
+func checkAudioURL(ctx context.Context, deps *Dependencies, audioURL string) error {
+	if deps.AudioURLValidator != nil {
+		if err := deps.AudioURLValidator.ValidateURL(audioURL); err != nil {
+			return fmt.Errorf("%w: %v", errAudioUnavailable, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, audioCheckTimeout)
+	defer cancel()
+
+	size, err := audioSize(ctx, http.MethodHead, audioURL)
+	if err != nil || size < 0 {
+		size, err = audioSize(ctx, http.MethodGet, audioURL)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errAudioUnavailable, err)
+	}
+	if size >= 0 && size < minAudioBytes {
+		return fmt.Errorf("%w: file is only %d bytes", errAudioUnavailable, size)
+	}
+	return nil
+}
+
+// audioSize requests audioURL with method and returns the file size, or -1 when
+// the response does not state it. GET requests ask for the first byte only.
+func audioSize(ctx context.Context, method, audioURL string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, method, audioURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("invalid audio URL: %w", err)
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	resp, err := audioCheckClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.ContentLength, nil
+	case http.StatusPartialContent:
+		// Content-Range: bytes 0-0/12345
+		if _, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/"); ok {
+			if size, err := strconv.ParseInt(total, 10, 64); err == nil {
+				return size, nil
+			}
+		}
+		return -1, nil
+	default:
+		return 0, fmt.Errorf("audio URL returned status %d", resp.StatusCode)
+	}
+}
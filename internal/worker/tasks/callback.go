@@ -0,0 +1,46 @@
+package tasks
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/security"
+)
+
+// KIE services whose callbacks the webhook handler accepts; the kind segment of a callback URL.
+const (
+	callbackKindSuno = "suno"
+	callbackKindNano = "nano"
+)
+
+// callbackBaseURL returns the base URL of the provider callbacks for job, or ""
+// when the job is polled: always with callback_mode poll, and with auto or
+// webhook when the worker has no WEBHOOK_BASE_URL.
+func callbackBaseURL(deps *Dependencies, job *models.Job, logger *zap.Logger) string {
+	switch job.CallbackMode {
+	case models.CallbackModePoll:
+		return ""
+	case models.CallbackModeWebhook:
+		if deps.WebhookBaseURL == "" {
+			logger.Warn("job asks for webhook callbacks but WEBHOOK_BASE_URL is not set, polling instead")
+		}
+	}
+	return deps.WebhookBaseURL
+}
+
+// buildCallbackURL returns the URL KIE calls back when a job's task of kind finishes:
+// {base}/api/v1/webhooks/{token}/{kind}/{job_id}, matching RegisterRoutes in
+// webhook_handler.go. The token only authenticates callbacks for this job; its
+// format is the one the webhook middleware checks (security.IsWebhookTokenFormat).
+// Returns "" when webhooks are not configured.
+func buildCallbackURL(base, secret, kind string, jobID uuid.UUID) string {
+	if base == "" || secret == "" {
+		return ""
+	}
+	token := security.WebhookToken(secret, jobID.String())
+	return strings.TrimRight(base, "/") + "/api/v1/webhooks/" + url.PathEscape(token) + "/" + kind + "/" + url.PathEscape(jobID.String())
+}
@@ -0,0 +1,66 @@
+package tasks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/middleware"
+)
+
+// TestCallbackURLPassesWebhookAuth checks that the URLs handed to KIE are
+// accepted by the webhook middleware on the routes of WebhookHandler, and only
+// for their own job.
+func TestCallbackURLPassesWebhookAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const secret = "webhook-secret-0123456789abcdef"
+
+	router := gin.New()
+	authenticated := router.Group("/api/v1/webhooks/:token", middleware.WebhookAuthMiddleware(middleware.WebhookAuthConfig{
+		Secret: secret,
+		Logger: zap.NewNop(),
+	}))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	authenticated.POST("/"+callbackKindSuno+"/:job_id", ok)
+	authenticated.POST("/"+callbackKindNano+"/:job_id", ok)
+
+	post := func(rawURL string) int {
+		parsed, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatalf("invalid callback URL %q: %v", rawURL, err)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, parsed.RequestURI(), strings.NewReader(`{}`)))
+		return rec.Code
+	}
+
+	jobID, otherJobID := uuid.New(), uuid.New()
+	for _, kind := range []string{callbackKindSuno, callbackKindNano} {
+		callbackURL := buildCallbackURL("https://api.example.com/", secret, kind, jobID)
+		if !strings.HasPrefix(callbackURL, "https://api.example.com/api/v1/webhooks/") {
+			t.Errorf("callback URL = %q, want it under the base URL", callbackURL)
+		}
+		if code := post(callbackURL); code != http.StatusOK {
+			t.Errorf("%s callback URL status = %d, want 200", kind, code)
+		}
+
+		// The token of one job does not authenticate another
+		otherJob := strings.Replace(callbackURL, jobID.String(), otherJobID.String(), 1)
+		if code := post(otherJob); code != http.StatusNotFound {
+			t.Errorf("%s callback URL for another job status = %d, want 404", kind, code)
+		}
+	}
+
+	if got := buildCallbackURL("", secret, callbackKindSuno, jobID); got != "" {
+		t.Errorf("callback URL without a base = %q, want empty", got)
+	}
+	if got := buildCallbackURL("https://api.example.com", "", callbackKindSuno, jobID); got != "" {
+		t.Errorf("callback URL without a secret = %q, want empty", got)
+	}
+}
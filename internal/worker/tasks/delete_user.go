@@ -0,0 +1,110 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// HandleDeleteUserData creates a handler for the delete user data task.
+// This handler:
+// 1. Loads the user (must be soft-deleted)
+// 2. Cancels the user's active jobs
+// 3. Deletes the user's R2 objects (best-effort)
+// 4. Wipes encrypted API keys and the YouTube token
+// 5. Deletes the user row; jobs are removed by ON DELETE CASCADE
+func HandleDeleteUserData(deps *Dependencies) asynq.HandlerFunc {
+	return func(ctx context.Context, task *asynq.Task) error {
+		logger := deps.Logger.With(zap.String("task_type", TypeDeleteUserData))
+
+		// Parse payload
+		payload, err := UnmarshalUserTaskPayload(task.Payload())
+		if err != nil {
+			logger.Error("failed to unmarshal task payload", zap.Error(err))
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+
+		logger = logger.With(zap.String("user_id", payload.UserID.String()))
+		if payload.TraceID != "" {
+			logger = logger.With(zap.String("trace_id", payload.TraceID))
+		}
+		logger.Info("starting delete user data task")
+
+		user, err := deps.UserRepo.GetByID(ctx, payload.UserID)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				logger.Info("user already deleted, skipping task")
+				return nil
+			}
+			return fmt.Errorf("failed to load user: %w", err)
+		}
+
+		// Never remove data of an account that was not deleted by its owner
+		if !user.IsDeleted() {
+			logger.Warn("user is not marked as deleted, skipping task")
+			return nil
+		}
+
+		// Stop in-flight pipeline tasks before removing their assets
+		cancelled, err := deps.JobRepo.CancelActiveByUserID(ctx, user.ID, "account deleted")
+		if err != nil {
+			return fmt.Errorf("failed to cancel jobs: %w", err)
+		}
+		if cancelled > 0 {
+			logger.Info("cancelled active jobs", zap.Int64("count", cancelled))
+		}
+
+		jobIDs, err := deps.JobRepo.ListIDsByUserID(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list jobs: %w", err)
+		}
+
+		if deps.R2Client != nil {
+			deleteJobAssets(ctx, deps, jobIDs, logger)
+		} else if len(jobIDs) > 0 {
+			logger.Warn("R2 not configured, skipping asset cleanup", zap.Int("jobs", len(jobIDs)))
+		}
+
+		if err := deps.UserRepo.DeleteAPIKeys(ctx, user.ID); err != nil {
+			return fmt.Errorf("failed to wipe API keys: %w", err)
+		}
+		if err := deps.UserRepo.UpdateYouTubeToken(ctx, user.ID, nil); err != nil {
+			return fmt.Errorf("failed to wipe YouTube token: %w", err)
+		}
+
+		if err := deps.UserRepo.Delete(ctx, user.ID); err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+
+		logger.Info("user data deleted", zap.Int("jobs", len(jobIDs)))
+		return nil
+	}
+}
+
+// deleteJobAssets deletes every known R2 asset of the given jobs.
+// Failures are logged and skipped so one bad object does not block account deletion.
+func deleteJobAssets(ctx context.Context, deps *Dependencies, jobIDs []uuid.UUID, logger *zap.Logger) {
+	for _, jobID := range jobIDs {
+		keys, err := deps.R2Client.ListJobObjectKeys(ctx, jobID.String())
+		if err != nil {
+			logger.Warn("failed to list kept tracks", zap.String("job_id", jobID.String()), zap.Error(err))
+			keys = r2.JobObjectKeys(jobID.String())
+		}
+		for _, key := range keys {
+			if err := deps.R2Client.Delete(ctx, key); err != nil {
+				logger.Warn("failed to delete R2 object",
+					zap.String("job_id", jobID.String()),
+					zap.String("key", key),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// HandleFinalizeSongs creates a handler for the finalize songs task.
+// It is scheduled when Suno's "first" callback arrives and runs after a grace
+// period. If the "complete" callback has not moved the job on by then, the songs
+// recorded so far are used for selection.
+// This handler:
+// 1. Loads the job (no-op unless still generating_music with recorded songs)
+// 2. Transitions the job to selecting_song
+// 3. Enqueues TypeSelectSong
+func HandleFinalizeSongs(deps *Dependencies) asynq.HandlerFunc {
+	return func(ctx context.Context, task *asynq.Task) error {
+		logger := deps.Logger.With(zap.String("task_type", TypeFinalizeSongs))
+
+		// Parse payload
+		payload, err := UnmarshalTaskPayload(task.Payload())
+		if err != nil {
+			logger.Error("failed to unmarshal task payload", zap.Error(err))
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+
+		logger = logger.With(payload.LogFields()...)
+
+		// Load job
+		job, err := deps.JobRepo.GetByID(ctx, payload.JobID)
+		if err != nil {
+			logger.Error("failed to load job", zap.Error(err))
+			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to load job: %v", err))
+		}
+
+		// The complete callback (or a failure) already moved the job on
+		if job.Status != models.StatusGeneratingMusic {
+			logger.Debug("songs already finalized", zap.String("status", job.Status))
+			return nil
+		}
+
+		if len(job.GeneratedSongs) == 0 || job.SunoTaskID == nil {
+			logger.Warn("no songs recorded yet, waiting for complete callback")
+			return nil
+		}
+
+		logger.Info("complete callback not received within grace period, selecting from recorded songs",
+			zap.Int("song_count", len(job.GeneratedSongs)),
+		)
+
+		err = deps.JobRepo.UpdateGeneratedSongsAtomic(ctx, payload.JobID, models.StatusGeneratingMusic,
+			*job.SunoTaskID, job.GeneratedSongs, models.StatusSelectingSong, stageExtras(deps, models.StageMusic))
+		if err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to finalize generated songs", logger)
+		}
+
+		// Enqueue next task: select song
+		nextPayload, _ := (&TaskPayload{JobID: payload.JobID, TraceID: payload.TraceID}).Marshal()
+		nextTask := asynq.NewTask(TypeSelectSong, nextPayload, asynq.TaskID(DedupTaskID(TypeSelectSong, payload.JobID)))
+		if _, err := deps.AsynqClient.Enqueue(nextTask); err != nil {
+			if errors.Is(err, asynq.ErrTaskIDConflict) {
+				logger.Warn("select song task already enqueued")
+				return nil
+			}
+			logger.Error("failed to enqueue select song task", zap.Error(err))
+			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to enqueue next task: %v", err))
+		}
+
+		logger.Info("enqueued select song task")
+		return nil
+	}
+}
@@ -3,6 +3,8 @@ package tasks
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -14,41 +16,278 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/jaochai/ugc/internal/agents"
+	"github.com/jaochai/ugc/internal/email"
 	"github.com/jaochai/ugc/internal/external/kie"
 	"github.com/jaochai/ugc/internal/external/openrouter"
 	"github.com/jaochai/ugc/internal/external/r2"
 	ytclient "github.com/jaochai/ugc/internal/external/youtube"
 	"github.com/jaochai/ugc/internal/ffmpeg"
+	"github.com/jaochai/ugc/internal/metrics"
 	"github.com/jaochai/ugc/internal/models"
 	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/security"
 )
 
-// CryptoService interface for decrypting API keys.
+// CryptoService interface for decrypting API keys and re-encrypting them after key rotation.
 type CryptoService interface {
+	Encrypt(plaintext string) (string, error)
 	Decrypt(ciphertext string) (string, error)
+	NeedsReencrypt(ciphertext string) bool
+}
+
+// WebhookReprocessor re-applies a stored webhook callback whose first processing
+// attempt failed transiently, and applies provider task results found by polling
+// as if their callback had arrived. Implemented by the webhook handler package.
+type WebhookReprocessor interface {
+	ReprocessEvent(ctx context.Context, eventID uuid.UUID, traceID string) error
+	ApplySunoTask(ctx context.Context, resp *kie.TaskResponse, traceID string) error
+	ApplyNanoTask(ctx context.Context, resp *kie.TaskStatusResponse, traceID string) error
+}
+
+// JobNotifier is told when a job reaches a final status so the owner's webhooks
+// can be notified. Implemented by the worker package.
+type JobNotifier interface {
+	JobFinished(ctx context.Context, jobID uuid.UUID)
+}
+
+// TaskEnqueuer enqueues the next task of a job. Implemented by *asynq.Client and,
+// for synchronous runs, by the in-memory queue of worker.SyncRunner.
+type TaskEnqueuer interface {
+	Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
 }
 
 // Dependencies holds all external dependencies required by task handlers.
 type Dependencies struct {
-	JobRepo          repository.JobRepository
-	UserRepo         repository.UserRepository
-	SystemPromptRepo repository.SystemPromptRepository
-	CryptoService    CryptoService
-	R2Client         *r2.Client
-	FFmpegProcessor  *ffmpeg.Processor
-	YouTubeClient    *ytclient.Client
-	AsynqClient      *asynq.Client
-	Logger           *zap.Logger
-	WebhookBaseURL   string // Base URL for webhooks, empty to disable
-	WebhookSecret    string // Secret token for webhook authentication
-	KIEBaseURL       string // Base URL for KIE API
+	JobRepo           repository.JobRepository
+	UserRepo          repository.UserRepository
+	SystemPromptRepo  repository.SystemPromptRepository
+	CryptoService     CryptoService
+	R2Client          *r2.Client
+	FFmpegProcessor   *ffmpeg.Processor
+	YouTubeClient     *ytclient.Client
+	AsynqClient       TaskEnqueuer
+	Logger            *zap.Logger
+	WorkerID          string           // Instance ID of this worker, recorded with stage timings
+	WebhookBaseURL    string           // Base URL for webhooks, empty to use polling
+	WebhookSecret     string           // Secret token for webhook authentication
+	KIEBaseURL        string           // Base URL for KIE API
+	OpenRouterBaseURL string           // Base URL for the OpenRouter API; empty uses the default
+	ImageCandidates   int              // Default number of image candidates per job
+	VideoPreset       string           // Encoding preset for jobs that do not pick one
+	Metrics           *metrics.Metrics // Optional task instrumentation; nil disables it
+	UserWebhookRepo   repository.UserWebhookRepository
+	JobNotifier       JobNotifier // Notifies user webhooks and emails of finished jobs; nil disables it
+	Mailer            email.Mailer
+
+	// Videos longer than VideoSegmentThreshold render in resumable VideoSegmentLength
+	// pieces under VideoWorkDir/{job_id}; a zero threshold renders in one pass.
+	VideoSegmentThreshold time.Duration
+	VideoSegmentLength    time.Duration
+	VideoWorkDir          string
+	// VideoMaxAudioBytes and VideoMaxImageBytes cap the render's downloads; zero uses the ffmpeg defaults
+	VideoMaxAudioBytes int64
+	VideoMaxImageBytes int64
+
+	WebhookReprocessor WebhookReprocessor // Re-applies deferred webhook callbacks and polled results
+
+	// AudioURLValidator restricts the song audio checked before rendering to the media hosts; nil skips the host check
+	AudioURLValidator *security.URLValidator
+
+	// PlatformOpenRouterKey is used by jobs created with the platform OpenRouter key source
+	PlatformOpenRouterKey string
+	// PlatformKIEKey is used by jobs created with the platform KIE key source
+	PlatformKIEKey string
+
+	// OrganizationRepo supplies the organization keys of users without their own; nil disables the fallback
+	OrganizationRepo repository.OrganizationRepository
+
+	// SpendRepo records the estimated KIE credits of each generation task and the
+	// OpenRouter tokens of each LLM call; nil disables tracking
+	SpendRepo       repository.UserSpendRepository
+	MusicCreditCost int // Estimated KIE credits of one Suno generation
+	ImageCreditCost int // Estimated KIE credits of one image task
+
+	// Provider client factories, called with the API key of each job's user. Nil
+	// factories create the HTTP clients for OpenRouterBaseURL and KIEBaseURL; tests
+	// set them to return fakes.
+	NewChatClient  func(apiKey string) openrouter.ChatClient
+	NewMusicClient func(apiKey string) kie.MusicClient
+	NewImageClient func(apiKey string) kie.ImageClient
+
+	// ProviderStats counts provider calls and errors for GET /api/v1/status; nil disables it
+	ProviderStats ProviderRecorder
+}
+
+// newOpenRouterClient creates an OpenRouter client for apiKey using the configured
+// base URL. The tokens of its calls are recorded as job's user spend.
+func newOpenRouterClient(deps *Dependencies, job *models.Job, apiKey string) openrouter.ChatClient {
+	var client openrouter.ChatClient
+	if deps.NewChatClient != nil {
+		client = deps.NewChatClient(apiKey)
+	} else {
+		var opts []openrouter.ClientOption
+		if deps.OpenRouterBaseURL != "" {
+			opts = append(opts, openrouter.WithBaseURL(deps.OpenRouterBaseURL))
+		}
+		client = openrouter.NewClient(apiKey, opts...)
+	}
+
+	client = &meteredChatClient{ChatClient: client, deps: deps, job: job}
+	if deps.ProviderStats != nil {
+		client = &recordingChatClient{ChatClient: client, deps: deps}
+	}
+	return client
+}
+
+// newMusicClient creates a Suno client for apiKey using the configured base URL.
+func newMusicClient(deps *Dependencies, apiKey string) kie.MusicClient {
+	var client kie.MusicClient = kie.NewSunoClient(apiKey, deps.KIEBaseURL)
+	if deps.NewMusicClient != nil {
+		client = deps.NewMusicClient(apiKey)
+	}
+	if deps.ProviderStats != nil {
+		client = &recordingMusicClient{MusicClient: client, deps: deps}
+	}
+	return client
+}
+
+// newImageClient creates a NanoBanana client for apiKey using the configured base URL.
+func newImageClient(deps *Dependencies, apiKey string) kie.ImageClient {
+	var client kie.ImageClient = kie.NewNanoBananaClient(apiKey, deps.KIEBaseURL)
+	if deps.NewImageClient != nil {
+		client = deps.NewImageClient(apiKey)
+	}
+	if deps.ProviderStats != nil {
+		client = &recordingImageClient{ImageClient: client, deps: deps}
+	}
+	return client
 }
 
 // DefaultLLMModel is the default model to use if user hasn't configured one.
 const DefaultLLMModel = "anthropic/claude-3.5-sonnet"
 
-// getEffectivePrompt returns the system default prompt from DB.
-func getEffectivePrompt(ctx context.Context, deps *Dependencies, promptType string) *string {
+// resolveAgentModel returns the LLM model an agent should use, in order of precedence:
+// the user's per-agent override, the job's model (the user's default when the job was
+// created), the user's current default, then DefaultLLMModel. user may be nil.
+func resolveAgentModel(user *models.User, job *models.Job, promptType string) string {
+	if user != nil {
+		if override := user.AgentModel(promptType); override != nil && *override != "" {
+			return *override
+		}
+	}
+	return defaultJobModel(user, job)
+}
+
+// defaultJobModel returns the job's model, falling back to the user's default and DefaultLLMModel.
+func defaultJobModel(user *models.User, job *models.Job) string {
+	if job.LLMModel != "" {
+		return job.LLMModel
+	}
+	if user != nil && user.OpenRouterModel != "" {
+		return user.OpenRouterModel
+	}
+	return DefaultLLMModel
+}
+
+// resolveSunoModel returns the Suno model for a job: the job's own choice, the
+// user's default, then kie.DefaultModel. user may be nil.
+func resolveSunoModel(user *models.User, job *models.Job) string {
+	var jobModel, userModel string
+	if job.SunoModel != nil {
+		jobModel = *job.SunoModel
+	}
+	if user != nil && user.DefaultSunoModel != nil {
+		userModel = *user.DefaultSunoModel
+	}
+	return kie.ResolveModel(jobModel, userModel)
+}
+
+// agentExtras records which model an agent used and what it produced, so its
+// decision can be explained later, with the write that stores its result. raw is
+// marshaled as the agent's JSON output. completedStages are the pipeline stages
+// the write completes.
+func agentExtras(deps *Dependencies, promptType, model string, params models.GenerationParams, reasoning, summary string, raw any, completedStages ...string) models.JobWriteExtras {
+	output := models.AgentOutput{
+		Model:     model,
+		Params:    &params,
+		Reasoning: reasoning,
+		Summary:   summary,
+		CreatedAt: time.Now(),
+	}
+	if rawJSON, err := json.Marshal(raw); err == nil {
+		output.RawOutput = string(rawJSON)
+	}
+
+	extras := stageExtras(deps, completedStages...)
+	extras.Agent = promptType
+	extras.AgentOutput = output
+	return extras
+}
+
+// stageExtras records the completion of stages with the write that completes them.
+func stageExtras(deps *Dependencies, stages ...string) models.JobWriteExtras {
+	return models.JobWriteExtras{CompletedStages: stages, WorkerID: deps.WorkerID}
+}
+
+// recordStageTime stores when a pipeline stage started or completed on the job.
+// Timings are informational, so failures are only logged.
+func recordStageTime(ctx context.Context, deps *Dependencies, jobID uuid.UUID, stage, event string, logger *zap.Logger) {
+	if err := deps.JobRepo.RecordStageTime(ctx, jobID, stage, event, time.Now(), deps.WorkerID); err != nil {
+		logger.Warn("failed to record stage time",
+			zap.String("stage", stage),
+			zap.String("event", event),
+			zap.Error(err),
+		)
+	}
+}
+
+// songConceptSummary describes a song concept without its lyrics.
+func songConceptSummary(output *agents.SongConceptOutput) string {
+	summary := fmt.Sprintf("%q, %s", output.Title, output.Style)
+	if output.Instrumental {
+		summary += ", instrumental"
+	}
+	return summary
+}
+
+// getEffectivePrompt returns the prompt an agent should use, in order of precedence:
+// the job's template override, the user's custom prompt, then the system default from DB.
+// nil means the agent's hardcoded default. A stored prompt that fails the guardrails is skipped.
+func getEffectivePrompt(ctx context.Context, deps *Dependencies, job *models.Job, promptType string) *string {
+	userID := job.UserID
+	if job.PromptOverrides != nil {
+		if override := job.PromptOverrides.ForType(promptType); override != nil && *override != "" {
+			if err := agents.ValidateCustomPrompt(*override); err != nil {
+				deps.Logger.Warn("ignoring template prompt override that fails guardrails",
+					zap.String("job_id", job.ID.String()),
+					zap.String("prompt_type", promptType),
+					zap.Error(err),
+				)
+			} else {
+				return override
+			}
+		}
+	}
+
+	prompts, err := deps.UserRepo.GetPrompts(ctx, userID)
+	if err != nil {
+		deps.Logger.Warn("failed to get user prompts, using system default",
+			zap.String("user_id", userID.String()),
+			zap.String("prompt_type", promptType),
+			zap.Error(err),
+		)
+	} else if custom := prompts.ForType(promptType); custom != nil && *custom != "" {
+		if err := agents.ValidateCustomPrompt(*custom); err != nil {
+			deps.Logger.Warn("ignoring custom prompt that fails guardrails",
+				zap.String("user_id", userID.String()),
+				zap.String("prompt_type", promptType),
+				zap.Error(err),
+			)
+		} else {
+			return custom
+		}
+	}
+
 	systemPrompt, err := deps.SystemPromptRepo.GetByType(ctx, promptType)
 	if err != nil {
 		deps.Logger.Warn("failed to get system prompt from DB, using hardcoded default",
@@ -61,28 +300,19 @@ func getEffectivePrompt(ctx context.Context, deps *Dependencies, promptType stri
 	return &systemPrompt.PromptContent
 }
 
-// getUserAPIKeys retrieves and decrypts the user's API keys.
-func getUserAPIKeys(ctx context.Context, deps *Dependencies, userID uuid.UUID) (openRouterKey, kieKey string, err error) {
-	encOpenRouterKey, encKIEKey, err := deps.UserRepo.GetAPIKeys(ctx, userID)
+// getGenerationParams returns the temperature and max_tokens for an agent: the
+// user's overrides on top of the agent's defaults. Failing to load the overrides
+// falls back to the defaults.
+func getGenerationParams(ctx context.Context, deps *Dependencies, userID uuid.UUID, promptType string) models.GenerationParams {
+	overrides, err := deps.UserRepo.GetAgentParams(ctx, userID)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to get API keys: %w", err)
-	}
-
-	if encOpenRouterKey != nil && *encOpenRouterKey != "" {
-		openRouterKey, err = deps.CryptoService.Decrypt(*encOpenRouterKey)
-		if err != nil {
-			return "", "", fmt.Errorf("failed to decrypt OpenRouter API key: %w", err)
-		}
-	}
-
-	if encKIEKey != nil && *encKIEKey != "" {
-		kieKey, err = deps.CryptoService.Decrypt(*encKIEKey)
-		if err != nil {
-			return "", "", fmt.Errorf("failed to decrypt KIE API key: %w", err)
-		}
+		deps.Logger.Warn("failed to get user agent params, using defaults",
+			zap.String("user_id", userID.String()),
+			zap.String("prompt_type", promptType),
+			zap.Error(err),
+		)
 	}
-
-	return openRouterKey, kieKey, nil
+	return agents.EffectiveGenerationParams(promptType, overrides[promptType])
 }
 
 // HandleAnalyzeConcept creates a handler for the analyze concept task.
@@ -104,7 +334,7 @@ func HandleAnalyzeConcept(deps *Dependencies) asynq.HandlerFunc {
 			return fmt.Errorf("failed to unmarshal payload: %w", err)
 		}
 
-		logger = logger.With(zap.String("job_id", payload.JobID.String()))
+		logger = logger.With(payload.LogFields()...)
 		logger.Info("starting analyze concept task")
 
 		// Load job from database
@@ -114,63 +344,70 @@ func HandleAnalyzeConcept(deps *Dependencies) asynq.HandlerFunc {
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to load job: %v", err))
 		}
 
+		// Stop if the job was cancelled or already finished
+		if job.IsTerminal() {
+			logger.Info("job is terminal, skipping task", zap.String("status", job.Status))
+			return nil
+		}
+
+		// A re-enqueued analyze task must not restart a job that already moved on
+		if job.Status != models.StatusPending && job.Status != models.StatusAnalyzing {
+			logger.Info("job already analyzed, skipping task", zap.String("status", job.Status))
+			return nil
+		}
+
 		// Update job status to analyzing
-		job.Status = models.StatusAnalyzing
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
+		if err := startStage(ctx, deps, job, models.StatusAnalyzing, models.StageAnalyze); err != nil {
+			if errors.Is(err, repository.ErrStatusConflict) {
+				logger.Info("job status changed concurrently, stopping task")
+				return nil
+			}
 			logger.Error("failed to update job status", zap.Error(err))
 			return fmt.Errorf("failed to update job status: %w", err)
 		}
 
-		// Load user to get LLM model preference
-		user, err := deps.UserRepo.GetByID(ctx, job.UserID)
+		// Load user for their LLM model preference and API keys
+		uc, err := LoadUserContext(ctx, deps, job)
 		if err != nil {
 			logger.Error("failed to load user", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to load user: %v", err))
-		}
-
-		// Get user's API keys
-		openRouterKey, _, err := getUserAPIKeys(ctx, deps, job.UserID)
-		if err != nil {
-			logger.Error("failed to get user API keys", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to get API keys: %v", err))
+			return markJobFailed(ctx, deps, payload.JobID, err.Error())
 		}
-		if openRouterKey == "" {
+		if uc.OpenRouterKey == "" {
 			logger.Error("user has no OpenRouter API key")
 			return markJobFailed(ctx, deps, payload.JobID, "user has no OpenRouter API key configured")
 		}
 
 		// Determine which LLM model to use
-		llmModel := user.OpenRouterModel
-		if llmModel == "" {
-			llmModel = DefaultLLMModel
-		}
+		llmModel := uc.AgentModel(job, models.PromptTypeSongConcept)
 
-		// Get effective prompt from system defaults
-		effectivePrompt := getEffectivePrompt(ctx, deps, "song_concept")
+		// Get effective prompt: user's custom prompt, then system default
+		effectivePrompt := getEffectivePrompt(ctx, deps, job, models.PromptTypeSongConcept)
 
 		// Create per-user OpenRouter client and SongConceptAgent
-		openRouterClient := openrouter.NewClient(openRouterKey)
+		openRouterClient := newOpenRouterClient(deps, job, uc.OpenRouterKey)
 		agent := agents.NewSongConceptAgentWithPrompt(openRouterClient, llmModel, logger, effectivePrompt)
+		agent.SetGenerationParams(getGenerationParams(ctx, deps, job.UserID, models.PromptTypeSongConcept))
 
-		// Analyze concept
+		// Analyze concept for the job's Suno model, whose limits the prompt must fit
+		sunoModel := uc.SunoModel(job)
 		input := agents.SongConceptInput{
-			Concept:  job.Concept,
-			Language: "Thai", // Default to Thai
+			Concept:   job.Concept,
+			Language:  "Thai", // Default to Thai
+			SunoModel: sunoModel,
 		}
 
 		output, err := agent.Analyze(ctx, input)
 		if err != nil {
 			logger.Error("failed to analyze concept", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to analyze concept: %v", err))
+			return markJobFailure(ctx, deps, payload.JobID, agentFailure(err, "failed to analyze concept"))
 		}
+		extras := agentExtras(deps, models.PromptTypeSongConcept, llmModel, agent.GenerationParams(), "",
+			songConceptSummary(output), output, models.StageAnalyze)
 
-		// Update job with song_prompt
-		// Note: Model is hardcoded to "V5" in ToSongPrompt()
-		job.SongPrompt = output.ToSongPrompt()
-		job.LLMModel = llmModel
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
-			logger.Error("failed to update job with song prompt", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to update job: %v", err))
+		// Update job with song_prompt; llm_model keeps the job-wide default, not the agent override
+		err = deps.JobRepo.UpdateConceptAnalysisAtomic(ctx, payload.JobID, models.StatusAnalyzing, output.ToSongPrompt(sunoModel), defaultJobModel(uc.User, job), extras)
+		if err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job with song prompt", logger)
 		}
 
 		logger.Info("concept analysis complete",
@@ -178,10 +415,19 @@ func HandleAnalyzeConcept(deps *Dependencies) asynq.HandlerFunc {
 			zap.String("style", output.Style),
 		)
 
+		// Skip the next stage if the job was cancelled meanwhile
+		if isJobStopped(ctx, deps, payload.JobID, logger) {
+			return nil
+		}
+
 		// Enqueue next task: generate music
-		nextPayload, _ := (&TaskPayload{JobID: payload.JobID}).Marshal()
-		nextTask := asynq.NewTask(TypeGenerateMusic, nextPayload)
+		nextPayload, _ := (&TaskPayload{JobID: payload.JobID, TraceID: payload.TraceID}).Marshal()
+		nextTask := asynq.NewTask(TypeGenerateMusic, nextPayload, asynq.TaskID(DedupTaskID(TypeGenerateMusic, payload.JobID)))
 		if _, err := deps.AsynqClient.Enqueue(nextTask); err != nil {
+			if errors.Is(err, asynq.ErrTaskIDConflict) {
+				logger.Warn("generate music task already enqueued")
+				return nil
+			}
 			logger.Error("failed to enqueue generate music task", zap.Error(err))
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to enqueue next task: %v", err))
 		}
@@ -196,7 +442,7 @@ func HandleAnalyzeConcept(deps *Dependencies) asynq.HandlerFunc {
 // 1. Loads the job
 // 2. Calls SunoClient.Generate() with song_prompt
 // 3. Updates the job with suno_task_id and status = generating_music
-// 4. If webhook is configured, returns nil (webhook will trigger next task)
+// 4. If webhook is configured, schedules a fallback poll and returns nil (webhook will trigger next task)
 // 5. Otherwise polls for completion and updates job with generated songs
 func HandleGenerateMusic(deps *Dependencies) asynq.HandlerFunc {
 	return func(ctx context.Context, task *asynq.Task) error {
@@ -209,7 +455,7 @@ func HandleGenerateMusic(deps *Dependencies) asynq.HandlerFunc {
 			return fmt.Errorf("failed to unmarshal payload: %w", err)
 		}
 
-		logger = logger.With(zap.String("job_id", payload.JobID.String()))
+		logger = logger.With(payload.LogFields()...)
 		logger.Info("starting generate music task")
 
 		// Load job
@@ -219,6 +465,18 @@ func HandleGenerateMusic(deps *Dependencies) asynq.HandlerFunc {
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to load job: %v", err))
 		}
 
+		// Stop if the job was cancelled or already finished
+		if job.IsTerminal() {
+			logger.Info("job is terminal, skipping task", zap.String("status", job.Status))
+			return nil
+		}
+
+		// A retried or re-enqueued music task must not start a second, billed Suno generation
+		if job.Status != models.StatusAnalyzing && (job.Status != models.StatusGeneratingMusic || job.SunoTaskID != nil) {
+			logger.Info("music generation already started, skipping task", zap.String("status", job.Status))
+			return nil
+		}
+
 		// Verify song_prompt exists
 		if job.SongPrompt == nil {
 			logger.Error("job missing song_prompt")
@@ -226,54 +484,60 @@ func HandleGenerateMusic(deps *Dependencies) asynq.HandlerFunc {
 		}
 
 		// Get user's KIE API key
-		_, kieKey, err := getUserAPIKeys(ctx, deps, job.UserID)
+		uc, err := LoadUserContext(ctx, deps, job)
 		if err != nil {
 			logger.Error("failed to get user API keys", zap.Error(err))
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to get API keys: %v", err))
 		}
-		if kieKey == "" {
+		if uc.KIEKey == "" {
 			logger.Error("user has no KIE API key")
 			return markJobFailed(ctx, deps, payload.JobID, "user has no KIE API key configured")
 		}
 
 		// Create per-user Suno client
-		sunoClient := kie.NewSunoClient(kieKey, deps.KIEBaseURL)
+		sunoClient := newMusicClient(deps, uc.KIEKey)
 
-		// Build Suno generate request
+		// Build Suno generate request; prompts saved before the model was chosen per job may lack one
 		req := kie.GenerateRequest{
 			Prompt:       job.SongPrompt.Prompt,
 			CustomMode:   true,
 			Instrumental: job.SongPrompt.Instrumental,
-			Model:        job.SongPrompt.Model,
+			Model:        kie.ResolveModel(job.SongPrompt.Model, resolveSunoModel(nil, job)),
 			Style:        job.SongPrompt.Style,
 			Title:        job.SongPrompt.Title,
 		}
-
-		// Add webhook URL if configured
-		// Route: /api/v1/webhooks/:token/suno/:job_id (matches RegisterRoutes in webhook_handler.go)
-		if deps.WebhookBaseURL != "" && deps.WebhookSecret != "" {
-			req.CallBackUrl = fmt.Sprintf("%s/api/v1/webhooks/%s/suno/%s", deps.WebhookBaseURL, deps.WebhookSecret, payload.JobID.String())
+		if err := kie.LimitsFor(req.Model).Validate(req.Prompt, req.Style, req.Title); err != nil {
+			logger.Error("song prompt exceeds Suno model limits", zap.String("suno_model", req.Model), zap.Error(err))
+			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("song prompt does not fit Suno %s: %v", req.Model, err))
 		}
 
-		// Call Suno API to start generation
+		// Add webhook URL unless the job is polled
+		callbackBase := callbackBaseURL(deps, job, logger)
+		req.CallBackUrl = buildCallbackURL(callbackBase, deps.WebhookSecret, callbackKindSuno, payload.JobID)
+
+		// Call Suno API to start generation; the music stage runs until the songs arrive
+		recordStageTime(ctx, deps, payload.JobID, models.StageMusic, models.StageEventStarted, logger)
 		taskID, err := sunoClient.Generate(ctx, req)
 		if err != nil {
 			logger.Error("failed to generate music", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to generate music: %v", err))
+			return failMusicGeneration(ctx, deps, payload.JobID, err, "failed to generate music")
 		}
 
 		logger.Info("music generation started", zap.String("suno_task_id", taskID))
 
 		// Update job with suno_task_id and status
-		job.SunoTaskID = &taskID
-		job.Status = models.StatusGeneratingMusic
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
-			logger.Error("failed to update job with suno task id", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to update job: %v", err))
+		err = deps.JobRepo.UpdateSunoTaskAtomic(ctx, payload.JobID, job.Status, taskID, models.StatusGeneratingMusic)
+		if err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job with suno task id", logger)
 		}
+		recordSpend(ctx, deps, job, models.SpendKindMusic, taskID, deps.MusicCreditCost, logger)
 
-		// If webhook is configured, return and let webhook handle completion
-		if deps.WebhookBaseURL != "" {
+		// If webhook is configured, return and let webhook handle completion.
+		// A delayed poll covers callbacks KIE fails to deliver.
+		if callbackBase != "" {
+			if err := enqueueMusicPoll(deps, PollTaskPayload{JobID: payload.JobID, TraceID: payload.TraceID, TaskID: taskID}); err != nil {
+				logger.Warn("failed to enqueue music poll fallback", zap.Error(err))
+			}
 			logger.Info("webhook configured, waiting for callback")
 			return nil
 		}
@@ -283,33 +547,61 @@ func HandleGenerateMusic(deps *Dependencies) asynq.HandlerFunc {
 		taskResp, err := sunoClient.WaitForCompletion(ctx, taskID, 10*time.Minute)
 		if err != nil {
 			logger.Error("music generation failed or timed out", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("music generation failed: %v", err))
+			return failMusicGeneration(ctx, deps, payload.JobID, err, "music generation failed")
 		}
 
-		// Convert songs to models.GeneratedSong (using new response structure)
-		generatedSongs := make([]models.GeneratedSong, len(taskResp.Data.Response.SunoData))
-		for i, song := range taskResp.Data.Response.SunoData {
-			generatedSongs[i] = models.GeneratedSong{
+		// Convert songs to models.GeneratedSong, dropping silent or empty tracks
+		generatedSongs := make([]models.GeneratedSong, 0, len(taskResp.Data.Response.SunoData))
+		for _, song := range taskResp.Data.Response.SunoData {
+			generated := models.GeneratedSong{
 				ID:       song.Id,
 				AudioURL: song.AudioUrl,
 				Title:    song.Title,
 				Duration: song.Duration,
+				ImageURL: song.ImageUrl,
+			}
+			if !generated.IsPlayable() {
+				logger.Warn("skipping song shorter than the minimum duration",
+					zap.String("song_id", song.Id),
+					zap.Float64("duration", song.Duration),
+				)
+				continue
 			}
+			generatedSongs = append(generatedSongs, generated)
+		}
+		if len(generatedSongs) == 0 {
+			logger.Error("music generation returned no playable songs",
+				zap.Int("total_songs", len(taskResp.Data.Response.SunoData)),
+			)
+			return markJobFailure(ctx, deps, payload.JobID, models.JobFailure{
+				Message:   models.NoPlayableSongsMessage,
+				Code:      models.JobErrorNoPlayableSongs,
+				RetryFrom: models.RetryFromMusic,
+			})
 		}
 
 		// Update job with generated songs
-		job.GeneratedSongs = generatedSongs
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
-			logger.Error("failed to update job with generated songs", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to update job: %v", err))
+		err = deps.JobRepo.UpdateGeneratedSongsAtomic(ctx, payload.JobID, models.StatusGeneratingMusic,
+			taskID, generatedSongs, models.StatusSelectingSong, stageExtras(deps, models.StageMusic))
+		if err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job with generated songs", logger)
 		}
 
 		logger.Info("music generation complete", zap.Int("song_count", len(generatedSongs)))
 
+		// Skip the next stage if the job was cancelled meanwhile
+		if isJobStopped(ctx, deps, payload.JobID, logger) {
+			return nil
+		}
+
 		// Enqueue next task: select song
-		nextPayload, _ := (&TaskPayload{JobID: payload.JobID}).Marshal()
-		nextTask := asynq.NewTask(TypeSelectSong, nextPayload)
+		nextPayload, _ := (&TaskPayload{JobID: payload.JobID, TraceID: payload.TraceID}).Marshal()
+		nextTask := asynq.NewTask(TypeSelectSong, nextPayload, asynq.TaskID(DedupTaskID(TypeSelectSong, payload.JobID)))
 		if _, err := deps.AsynqClient.Enqueue(nextTask); err != nil {
+			if errors.Is(err, asynq.ErrTaskIDConflict) {
+				logger.Warn("select song task already enqueued")
+				return nil
+			}
 			logger.Error("failed to enqueue select song task", zap.Error(err))
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to enqueue next task: %v", err))
 		}
@@ -337,7 +629,7 @@ func HandleSelectSong(deps *Dependencies) asynq.HandlerFunc {
 			return fmt.Errorf("failed to unmarshal payload: %w", err)
 		}
 
-		logger = logger.With(zap.String("job_id", payload.JobID.String()))
+		logger = logger.With(payload.LogFields()...)
 		logger.Info("starting select song task")
 
 		// Load job
@@ -347,6 +639,18 @@ func HandleSelectSong(deps *Dependencies) asynq.HandlerFunc {
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to load job: %v", err))
 		}
 
+		// Stop if the job was cancelled or already finished
+		if job.IsTerminal() {
+			logger.Info("job is terminal, skipping task", zap.String("status", job.Status))
+			return nil
+		}
+
+		// A duplicate select song task must not move back a job that already selected its song
+		if job.Status != models.StatusGeneratingMusic && job.Status != models.StatusSelectingSong {
+			logger.Info("song already selected, skipping task", zap.String("status", job.Status))
+			return nil
+		}
+
 		// Verify generated_songs exists
 		if len(job.GeneratedSongs) == 0 {
 			logger.Error("job has no generated songs")
@@ -354,34 +658,31 @@ func HandleSelectSong(deps *Dependencies) asynq.HandlerFunc {
 		}
 
 		// Update status
-		job.Status = models.StatusSelectingSong
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
-			logger.Error("failed to update job status", zap.Error(err))
+		if err := advanceStatus(ctx, deps, job, models.StatusSelectingSong); err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job status", logger)
 		}
 
 		// Get user's OpenRouter API key
-		openRouterKey, _, err := getUserAPIKeys(ctx, deps, job.UserID)
+		uc, err := LoadUserContext(ctx, deps, job)
 		if err != nil {
 			logger.Error("failed to get user API keys", zap.Error(err))
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to get API keys: %v", err))
 		}
-		if openRouterKey == "" {
+		if uc.OpenRouterKey == "" {
 			logger.Error("user has no OpenRouter API key")
 			return markJobFailed(ctx, deps, payload.JobID, "user has no OpenRouter API key configured")
 		}
 
 		// Determine LLM model
-		llmModel := job.LLMModel
-		if llmModel == "" {
-			llmModel = DefaultLLMModel
-		}
+		llmModel := uc.AgentModel(job, models.PromptTypeSongSelector)
 
-		// Get effective prompt from system defaults
-		effectivePrompt := getEffectivePrompt(ctx, deps, "song_selector")
+		// Get effective prompt: user's custom prompt, then system default
+		effectivePrompt := getEffectivePrompt(ctx, deps, job, models.PromptTypeSongSelector)
 
 		// Create per-user OpenRouter client and SongSelectorAgent
-		openRouterClient := openrouter.NewClient(openRouterKey)
+		openRouterClient := newOpenRouterClient(deps, job, uc.OpenRouterKey)
 		agent := agents.NewSongSelectorAgentWithPrompt(openRouterClient, llmModel, logger, effectivePrompt)
+		agent.SetGenerationParams(getGenerationParams(ctx, deps, job.UserID, models.PromptTypeSongSelector))
 
 		// Build song candidates
 		candidates := make([]agents.SongCandidate, len(job.GeneratedSongs))
@@ -396,14 +697,15 @@ func HandleSelectSong(deps *Dependencies) asynq.HandlerFunc {
 
 		// Select best song
 		input := agents.SongSelectorInput{
-			OriginalConcept: job.Concept,
-			Songs:           candidates,
+			OriginalConcept:    job.Concept,
+			Songs:              candidates,
+			MaxDurationSeconds: job.MaxDuration().Seconds(),
 		}
 
 		output, err := agent.Select(ctx, input)
 		if err != nil {
 			logger.Error("failed to select song", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to select song: %v", err))
+			return markJobFailure(ctx, deps, payload.JobID, agentFailure(err, "failed to select song"))
 		}
 
 		// Find selected song's audio URL
@@ -422,11 +724,12 @@ func HandleSelectSong(deps *Dependencies) asynq.HandlerFunc {
 		}
 
 		// Update job with selected song
-		job.SelectedSongID = &output.SelectedSongID
-		job.AudioURL = &selectedAudioURL
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
-			logger.Error("failed to update job with selected song", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to update job: %v", err))
+		err = deps.JobRepo.UpdateSelectedSongAtomic(ctx, payload.JobID, models.StatusSelectingSong,
+			output.SelectedSongID, selectedAudioURL, models.StatusGeneratingImage,
+			agentExtras(deps, models.PromptTypeSongSelector, llmModel, agent.GenerationParams(), output.Reasoning,
+				"selected song "+output.SelectedSongID, output))
+		if err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job with selected song", logger)
 		}
 
 		logger.Info("song selected",
@@ -434,10 +737,19 @@ func HandleSelectSong(deps *Dependencies) asynq.HandlerFunc {
 			zap.String("reasoning", output.Reasoning),
 		)
 
+		// Skip the next stage if the job was cancelled meanwhile
+		if isJobStopped(ctx, deps, payload.JobID, logger) {
+			return nil
+		}
+
 		// Enqueue next task: generate image
-		nextPayload, _ := (&TaskPayload{JobID: payload.JobID}).Marshal()
-		nextTask := asynq.NewTask(TypeGenerateImage, nextPayload)
+		nextPayload, _ := (&TaskPayload{JobID: payload.JobID, TraceID: payload.TraceID}).Marshal()
+		nextTask := asynq.NewTask(TypeGenerateImage, nextPayload, asynq.TaskID(DedupTaskID(TypeGenerateImage, payload.JobID)))
 		if _, err := deps.AsynqClient.Enqueue(nextTask); err != nil {
+			if errors.Is(err, asynq.ErrTaskIDConflict) {
+				logger.Warn("generate image task already enqueued")
+				return nil
+			}
 			logger.Error("failed to enqueue generate image task", zap.Error(err))
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to enqueue next task: %v", err))
 		}
@@ -449,15 +761,19 @@ func HandleSelectSong(deps *Dependencies) asynq.HandlerFunc {
 
 // HandleGenerateImage creates a handler for the generate image task.
 // This handler:
-// 1. Loads the job
+// 1. Loads the job; jobs with a user-supplied image skip to process_video
 // 2. Creates an ImageConceptAgent
 // 3. Generates the image prompt
 // 4. Updates the job with image_prompt
-// 5. Calls NanoBananaClient.CreateTask()
-// 6. Updates the job with nano_task_id
-// 7. If webhook is configured, returns nil; otherwise polls for completion
+// 5. Calls NanoBananaClient.CreateTask() once per image candidate
+// 6. Updates the job with generated_images (one pending entry per task)
+// 7. If webhook is configured, returns nil; otherwise polls each candidate and enqueues TypeSelectImage
+//
+// When the image model refuses every candidate under its content policy, the
+// handler runs once more with a prompt that avoids real people and brands.
 func HandleGenerateImage(deps *Dependencies) asynq.HandlerFunc {
-	return func(ctx context.Context, task *asynq.Task) error {
+	var handle asynq.HandlerFunc
+	handle = func(ctx context.Context, task *asynq.Task) error {
 		logger := deps.Logger.With(zap.String("task_type", TypeGenerateImage))
 
 		// Parse payload
@@ -467,7 +783,7 @@ func HandleGenerateImage(deps *Dependencies) asynq.HandlerFunc {
 			return fmt.Errorf("failed to unmarshal payload: %w", err)
 		}
 
-		logger = logger.With(zap.String("job_id", payload.JobID.String()))
+		logger = logger.With(payload.LogFields()...)
 		logger.Info("starting generate image task")
 
 		// Load job
@@ -477,39 +793,54 @@ func HandleGenerateImage(deps *Dependencies) asynq.HandlerFunc {
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to load job: %v", err))
 		}
 
+		// Stop if the job was cancelled or already finished
+		if job.IsTerminal() {
+			logger.Info("job is terminal, skipping task", zap.String("status", job.Status))
+			return nil
+		}
+
 		// Update status
-		job.Status = models.StatusGeneratingImage
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
-			logger.Error("failed to update job status", zap.Error(err))
+		if err := startStage(ctx, deps, job, models.StatusGeneratingImage, models.StageImage); err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job status", logger)
+		}
+
+		// The user supplied the image; no generation needed
+		if job.HasUserImage() {
+			return useUserImage(ctx, deps, job, payload, logger)
+		}
+
+		// Use the selected track's cover art; falls through to generation when it cannot be used
+		if job.UsesSunoImage() {
+			if handled, err := useSunoImage(ctx, deps, job, payload, logger); handled {
+				return err
+			}
 		}
 
 		// Get user's API keys
-		openRouterKey, kieKey, err := getUserAPIKeys(ctx, deps, job.UserID)
+		uc, err := LoadUserContext(ctx, deps, job)
 		if err != nil {
 			logger.Error("failed to get user API keys", zap.Error(err))
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to get API keys: %v", err))
 		}
-		if openRouterKey == "" {
+		if uc.OpenRouterKey == "" {
 			logger.Error("user has no OpenRouter API key")
 			return markJobFailed(ctx, deps, payload.JobID, "user has no OpenRouter API key configured")
 		}
-		if kieKey == "" {
+		if uc.KIEKey == "" {
 			logger.Error("user has no KIE API key")
 			return markJobFailed(ctx, deps, payload.JobID, "user has no KIE API key configured")
 		}
 
 		// Determine LLM model
-		llmModel := job.LLMModel
-		if llmModel == "" {
-			llmModel = DefaultLLMModel
-		}
+		llmModel := uc.AgentModel(job, models.PromptTypeImageConcept)
 
-		// Get effective prompt from system defaults
-		effectivePrompt := getEffectivePrompt(ctx, deps, "image_concept")
+		// Get effective prompt: user's custom prompt, then system default
+		effectivePrompt := getEffectivePrompt(ctx, deps, job, models.PromptTypeImageConcept)
 
 		// Create per-user OpenRouter client and ImageConceptAgent
-		openRouterClient := openrouter.NewClient(openRouterKey)
+		openRouterClient := newOpenRouterClient(deps, job, uc.OpenRouterKey)
 		agent := agents.NewImageConceptAgentWithPrompt(openRouterClient, llmModel, logger, effectivePrompt)
+		agent.SetGenerationParams(getGenerationParams(ctx, deps, job.UserID, models.PromptTypeImageConcept))
 
 		// Build input
 		var songTitle, songStyle, lyrics string
@@ -524,103 +855,196 @@ func HandleGenerateImage(deps *Dependencies) asynq.HandlerFunc {
 			SongTitle:       songTitle,
 			SongStyle:       songStyle,
 			Lyrics:          lyrics,
+			// A previous prompt was refused under the image model's content policy
+			AvoidNamedEntities: job.ImageSanitizeAttempts > 0,
 		}
 
 		// Generate image prompt
 		output, err := agent.Generate(ctx, input)
 		if err != nil {
 			logger.Error("failed to generate image prompt", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to generate image prompt: %v", err))
+			return markJobFailure(ctx, deps, payload.JobID, agentFailure(err, "failed to generate image prompt"))
 		}
 
 		// Update job with image_prompt
-		// ImageSize is hardcoded to 16:9 — google/nano-banana uses "image_size" field
-		const defaultImageSize = "16:9"
-		job.ImagePrompt = &models.ImagePrompt{
-			Prompt:    output.Prompt,
-			ImageSize: defaultImageSize,
+		// google/nano-banana uses the "image_size" field for the aspect ratio;
+		// an aspect ratio requested on the job overrides the agent's choice
+		imageSize := output.AspectRatio
+		if job.AspectRatio != nil && kie.IsValidAspectRatio(*job.AspectRatio) {
+			imageSize = *job.AspectRatio
+		}
+		imagePrompt := &models.ImagePrompt{
+			Prompt:     output.Prompt,
+			ImageSize:  imageSize,
+			Resolution: output.Resolution,
 		}
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
-			logger.Error("failed to update job with image prompt", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to update job: %v", err))
+		extras := agentExtras(deps, models.PromptTypeImageConcept, llmModel, agent.GenerationParams(), "",
+			fmt.Sprintf("aspect ratio %s, resolution %s", output.AspectRatio, output.Resolution), output)
+		if err := deps.JobRepo.UpdateImagePromptAtomic(ctx, payload.JobID, models.StatusGeneratingImage, imagePrompt, extras); err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job with image prompt", logger)
 		}
 
 		logger.Info("image prompt generated", zap.Int("prompt_length", len(output.Prompt)))
 
 		// Create per-user NanoBanana client
-		nanoBananaClient := kie.NewNanoBananaClient(kieKey, deps.KIEBaseURL)
+		nanoBananaClient := newImageClient(deps, uc.KIEKey)
 
 		// Build NanoBanana request
 		req := kie.CreateTaskRequest{
 			Model: kie.ModelNanoBananaPro,
 			Input: kie.NanoInput{
 				Prompt:       output.Prompt,
-				ImageSize:    defaultImageSize,
+				ImageSize:    imageSize,
+				Resolution:   output.Resolution,
 				OutputFormat: kie.FormatPNG,
 			},
 		}
 
-		// Add webhook URL if configured
-		// Route: /api/v1/webhooks/:token/nano/:job_id (matches RegisterRoutes in webhook_handler.go)
-		if deps.WebhookBaseURL != "" && deps.WebhookSecret != "" {
-			req.CallBackUrl = fmt.Sprintf("%s/api/v1/webhooks/%s/nano/%s", deps.WebhookBaseURL, deps.WebhookSecret, payload.JobID.String())
-		}
+		// Add webhook URL unless the job is polled
+		callbackBase := callbackBaseURL(deps, job, logger)
+		req.CallBackUrl = buildCallbackURL(callbackBase, deps.WebhookSecret, callbackKindNano, payload.JobID)
 
-		// Create image generation task
-		nanoTaskID, err := nanoBananaClient.CreateTask(ctx, req)
-		if err != nil {
-			logger.Error("failed to create image generation task", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to create image task: %v", err))
+		// Create one image generation task per candidate
+		candidateCount := imageCandidateCount(job, deps)
+		images := make([]models.GeneratedImage, 0, candidateCount)
+		for i := 0; i < candidateCount; i++ {
+			nanoTaskID, err := nanoBananaClient.CreateTask(ctx, req)
+			if err != nil {
+				logger.Error("failed to create image generation task",
+					zap.Error(err),
+					zap.Int("candidate", i+1),
+				)
+				continue
+			}
+			images = append(images, models.GeneratedImage{
+				TaskID: nanoTaskID,
+				Status: models.ImageCandidatePending,
+			})
+		}
+		if len(images) == 0 {
+			return markJobFailed(ctx, deps, payload.JobID, "failed to create image task")
 		}
 
-		logger.Info("image generation started", zap.String("nano_task_id", nanoTaskID))
+		logger.Info("image generation started",
+			zap.Int("candidates", len(images)),
+			zap.String("nano_task_id", images[0].TaskID),
+		)
 
-		// Update job with nano_task_id
-		job.NanoTaskID = &nanoTaskID
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
-			logger.Error("failed to update job with nano task id", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to update job: %v", err))
+		// Update job with candidate task IDs (first task doubles as nano_task_id until selection)
+		err = deps.JobRepo.UpdateNanoTasksAtomic(ctx, payload.JobID, models.StatusGeneratingImage, images[0].TaskID, images)
+		if err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job with nano task ids", logger)
+		}
+		for _, image := range images {
+			recordSpend(ctx, deps, job, models.SpendKindImage, image.TaskID, deps.ImageCreditCost, logger)
 		}
 
-		// If webhook is configured, return and let webhook handle completion
-		if deps.WebhookBaseURL != "" {
+		// If webhook is configured, return and let webhook handle completion.
+		// A delayed poll covers callbacks KIE fails to deliver.
+		if callbackBase != "" {
+			if err := enqueueImagePoll(deps, PollTaskPayload{JobID: payload.JobID, TraceID: payload.TraceID, TaskID: images[0].TaskID}); err != nil {
+				logger.Warn("failed to enqueue image poll fallback", zap.Error(err))
+			}
 			logger.Info("webhook configured, waiting for callback")
 			return nil
 		}
 
-		// Otherwise, poll for completion
+		// Otherwise, poll each candidate for completion
 		logger.Info("polling for image generation completion")
-		statusResp, err := nanoBananaClient.WaitForCompletion(ctx, nanoTaskID, 5*time.Minute)
-		if err != nil {
-			logger.Error("image generation failed or timed out", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("image generation failed: %v", err))
+		flagged := false
+		for i := range images {
+			statusResp, err := nanoBananaClient.WaitForCompletion(ctx, images[i].TaskID, 5*time.Minute)
+			if err != nil {
+				if statusResp != nil && kie.IsContentPolicyFailure(statusResp.Data.FailCode, statusResp.Data.FailMsg) {
+					flagged = true
+				}
+				logger.Warn("image candidate failed or timed out",
+					zap.Error(err),
+					zap.String("nano_task_id", images[i].TaskID),
+				)
+				images[i].Status = models.ImageCandidateFailed
+				continue
+			}
+
+			// Parse image URL from ResultJson
+			imageURL, err := nanoBananaClient.GetImageUrl(statusResp)
+			if err != nil {
+				logger.Warn("failed to extract image URL from response",
+					zap.Error(err),
+					zap.String("nano_task_id", images[i].TaskID),
+				)
+				images[i].Status = models.ImageCandidateFailed
+				continue
+			}
+			images[i].ImageURL = imageURL
+			images[i].Status = models.ImageCandidateSuccess
 		}
 
-		// Update job with image URL (parse from ResultJson)
-		imageURL, err := nanoBananaClient.GetImageUrl(statusResp)
-		if err != nil {
-			logger.Error("failed to extract image URL from response", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to get image URL: %v", err))
+		if flagged && !hasSuccessfulImage(images) {
+			if !job.CanSanitizeImagePrompt() {
+				return markJobFailed(ctx, deps, payload.JobID, kie.ContentPolicyMessage)
+			}
+			// The persisted attempt count bounds this to a single rerun
+			if err := deps.JobRepo.RestartImageGenerationAtomic(ctx, payload.JobID, job.ImageSanitizeAttempts); err != nil {
+				return handleUpdateError(ctx, deps, payload.JobID, err, "failed to restart image generation", logger)
+			}
+			logger.Info("image prompt refused under content policy, regenerating with a sanitized prompt")
+			return handle(ctx, task)
 		}
-		job.ImageURL = &imageURL
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
-			logger.Error("failed to update job with image url", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to update job: %v", err))
+
+		if err := deps.JobRepo.UpdateGeneratedImagesAtomic(ctx, payload.JobID, models.StatusGeneratingImage, images); err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job with image candidates", logger)
 		}
 
-		logger.Info("image generation complete", zap.String("image_url", imageURL))
+		logger.Info("image generation complete", zap.Int("candidates", len(images)))
+
+		// Skip the next stage if the job was cancelled meanwhile
+		if isJobStopped(ctx, deps, payload.JobID, logger) {
+			return nil
+		}
 
-		// Enqueue next task: process video
-		nextPayload, _ := (&TaskPayload{JobID: payload.JobID}).Marshal()
-		nextTask := asynq.NewTask(TypeProcessVideo, nextPayload)
+		// Enqueue next task: select image
+		nextPayload, _ := (&TaskPayload{JobID: payload.JobID, TraceID: payload.TraceID}).Marshal()
+		nextTask := asynq.NewTask(TypeSelectImage, nextPayload, asynq.TaskID(DedupTaskID(TypeSelectImage, payload.JobID)))
 		if _, err := deps.AsynqClient.Enqueue(nextTask); err != nil {
-			logger.Error("failed to enqueue process video task", zap.Error(err))
+			if errors.Is(err, asynq.ErrTaskIDConflict) {
+				logger.Warn("select image task already enqueued")
+				return nil
+			}
+			logger.Error("failed to enqueue select image task", zap.Error(err))
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to enqueue next task: %v", err))
 		}
 
-		logger.Info("enqueued process video task")
+		logger.Info("enqueued select image task")
 		return nil
 	}
+	return handle
+}
+
+// hasSuccessfulImage reports whether any image candidate succeeded.
+func hasSuccessfulImage(images []models.GeneratedImage) bool {
+	for _, img := range images {
+		if img.Status == models.ImageCandidateSuccess {
+			return true
+		}
+	}
+	return false
+}
+
+// imageCandidateCount returns the number of image candidates to generate for a job,
+// falling back to the worker default and clamping to the supported range.
+func imageCandidateCount(job *models.Job, deps *Dependencies) int {
+	count := deps.ImageCandidates
+	if job.ImageCandidates != nil {
+		count = *job.ImageCandidates
+	}
+	if count < models.MinImageCandidates {
+		return models.MinImageCandidates
+	}
+	if count > models.MaxImageCandidates {
+		return models.MaxImageCandidates
+	}
+	return count
 }
 
 // HandleProcessVideo creates a handler for the process video task.
@@ -640,7 +1064,7 @@ func HandleProcessVideo(deps *Dependencies) asynq.HandlerFunc {
 			return fmt.Errorf("failed to unmarshal payload: %w", err)
 		}
 
-		logger = logger.With(zap.String("job_id", payload.JobID.String()))
+		logger = logger.With(payload.LogFields()...)
 		logger.Info("starting process video task")
 
 		// Load job
@@ -650,20 +1074,41 @@ func HandleProcessVideo(deps *Dependencies) asynq.HandlerFunc {
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to load job: %v", err))
 		}
 
-		// Verify required URLs exist
+		// Stop if the job was cancelled or already finished
+		if job.IsTerminal() {
+			logger.Info("job is terminal, skipping task", zap.String("status", job.Status))
+			return nil
+		}
+
+		// Verify required URLs exist; stored images get a fresh URL from their key
 		if job.AudioURL == nil || *job.AudioURL == "" {
 			logger.Error("job missing audio_url")
 			return markJobFailed(ctx, deps, payload.JobID, "job missing audio_url")
 		}
+		if job.ImageKey != nil && deps.R2Client != nil {
+			job.ImageURL = job.ToResponseWithSigner(ctx, deps.R2Client).ImageURL
+		}
 		if job.ImageURL == nil || *job.ImageURL == "" {
 			logger.Error("job missing image_url")
 			return markJobFailed(ctx, deps, payload.JobID, "job missing image_url")
 		}
 
 		// Update status
-		job.Status = models.StatusProcessingVideo
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
-			logger.Error("failed to update job status", zap.Error(err))
+		if err := startStage(ctx, deps, job, models.StatusProcessingVideo, models.StageVideo); err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job status", logger)
+		}
+
+		// Suno occasionally serves dead or empty audio links; a retry generates new songs
+		if err := checkAudioURL(ctx, deps, *job.AudioURL); err != nil {
+			if interrupted := taskInterrupted(ctx); interrupted != nil {
+				return interrupted
+			}
+			logger.Error("selected song audio is unavailable", zap.Error(err))
+			return markJobFailure(ctx, deps, payload.JobID, models.JobFailure{
+				Message:   fmt.Sprintf("%s (%v)", models.AudioUnavailableMessage, err),
+				Code:      models.JobErrorAudioUnavailable,
+				RetryFrom: models.RetryFromMusic,
+			})
 		}
 
 		// Create temp output path for video
@@ -672,40 +1117,101 @@ func HandleProcessVideo(deps *Dependencies) asynq.HandlerFunc {
 			logger.Error("failed to create temp directory", zap.Error(err))
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to create temp directory: %v", err))
 		}
-		// Note: Don't defer cleanup here - we need the file for upload task
+		// The video is kept for the upload task only once that task is enqueued
+		keepOutput := false
+		defer func() {
+			if !keepOutput {
+				os.RemoveAll(tempDir)
+			}
+		}()
 
 		outputPath := filepath.Join(tempDir, fmt.Sprintf("%s.mp4", payload.JobID.String()))
 
 		// Create music video
 		input := ffmpeg.CreateMusicVideoInput{
-			AudioURL:   *job.AudioURL,
-			ImageURL:   *job.ImageURL,
-			OutputPath: outputPath,
+			AudioURL:          *job.AudioURL,
+			ImageURL:          *job.ImageURL,
+			OutputPath:        outputPath,
+			NormalizeLoudness: job.VideoOptions.ShouldNormalizeAudio(),
+			FadeOut:           job.VideoOptions.FadeOut(),
+			Preset:            deps.FFmpegProcessor.ResolvePreset(job.VideoOptions.PresetName(), deps.VideoPreset),
+			MaxDuration:       job.MaxDuration(),
+			MaxAudioBytes:     deps.VideoMaxAudioBytes,
+			MaxImageBytes:     deps.VideoMaxImageBytes,
+		}
+		// Long tracks render in segments kept across retries of this task
+		workDir := renderWorkDir(deps, payload.JobID)
+		if workDir != "" {
+			input.WorkDir = workDir
+			input.ResumeKey = renderResumeKey(job, input)
+			input.SegmentThreshold = deps.VideoSegmentThreshold
+			input.SegmentLength = deps.VideoSegmentLength
 		}
 
 		videoOutput, err := deps.FFmpegProcessor.CreateMusicVideo(ctx, input)
 		if err != nil {
+			if interrupted := taskInterrupted(ctx); interrupted != nil {
+				logger.Warn("video rendering interrupted by shutdown, task will be retried")
+				return interrupted
+			}
+			removeRenderWorkDir(workDir, logger)
 			logger.Error("failed to create music video", zap.Error(err))
-			// Clean up temp directory on error
-			os.RemoveAll(tempDir)
+			if failure, ok := renderInputFailure(err); ok {
+				// The same URL serves the same content, so retrying the task cannot help
+				return fmt.Errorf("%v: %w", markJobFailure(ctx, deps, payload.JobID, failure), asynq.SkipRetry)
+			}
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to create video: %v", err))
 		}
+		removeRenderWorkDir(workDir, logger)
 
 		logger.Info("video created successfully",
 			zap.String("output_path", videoOutput.OutputPath),
 			zap.Int64("file_size", videoOutput.FileSize),
 			zap.Duration("duration", videoOutput.Duration),
+			zap.String("preset", videoOutput.Preset),
+			zap.Bool("trimmed", videoOutput.Trimmed),
+			zap.Int("segments", videoOutput.Segments),
 		)
 
+		// The metadata and stage timing are informational, so failing to store them does not fail the job
+		metadata := &models.VideoMetadata{
+			Preset:          videoOutput.Preset,
+			Codec:           videoOutput.VideoCodec,
+			Bitrate:         videoOutput.Bitrate,
+			SizeBytes:       videoOutput.FileSize,
+			DurationSeconds: videoOutput.Duration.Seconds(),
+
+			OriginalDurationSeconds: videoOutput.SourceDuration.Seconds(),
+			Trimmed:                 videoOutput.Trimmed,
+		}
+		if err := deps.JobRepo.UpdateVideoMetadata(ctx, payload.JobID, metadata, stageExtras(deps, models.StageVideo)); err != nil {
+			logger.Warn("failed to store video metadata", zap.Error(err))
+		}
+
+		if interrupted := taskInterrupted(ctx); interrupted != nil {
+			return interrupted
+		}
+
+		// Skip the upload if the job was cancelled during rendering
+		if isJobStopped(ctx, deps, payload.JobID, logger) {
+			return nil
+		}
+
 		// Enqueue next task: upload assets
 		// Include the video path in metadata for the upload task
-		nextPayload, _ := (&TaskPayload{JobID: payload.JobID}).Marshal()
-		nextTask := asynq.NewTask(TypeUploadAssets, nextPayload)
-		if _, err := deps.AsynqClient.Enqueue(nextTask, asynq.TaskID(fmt.Sprintf("upload-%s", payload.JobID.String()))); err != nil {
+		nextPayload, _ := (&TaskPayload{JobID: payload.JobID, TraceID: payload.TraceID}).Marshal()
+		nextTask := asynq.NewTask(TypeUploadAssets, nextPayload, asynq.TaskID(DedupTaskID(TypeUploadAssets, payload.JobID)))
+		if _, err := deps.AsynqClient.Enqueue(nextTask); err != nil {
+			if errors.Is(err, asynq.ErrTaskIDConflict) {
+				// The upload already queued finds the video this task just rendered
+				logger.Warn("upload assets task already enqueued")
+				keepOutput = true
+				return nil
+			}
 			logger.Error("failed to enqueue upload assets task", zap.Error(err))
-			os.RemoveAll(tempDir)
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to enqueue next task: %v", err))
 		}
+		keepOutput = true
 
 		logger.Info("enqueued upload assets task")
 		return nil
@@ -717,8 +1223,9 @@ func HandleProcessVideo(deps *Dependencies) asynq.HandlerFunc {
 // 1. Loads the job
 // 2. Finds the generated video file
 // 3. Uploads video to R2
-// 4. Updates the job with video_url
-// 5. Marks the job as completed
+// 4. Extracts and uploads a thumbnail and, for keep_all_tracks jobs, copies every track (best effort)
+// 5. Updates the job with video_key
+// 6. Marks the job as completed
 func HandleUploadAssets(deps *Dependencies) asynq.HandlerFunc {
 	return func(ctx context.Context, task *asynq.Task) error {
 		logger := deps.Logger.With(zap.String("task_type", TypeUploadAssets))
@@ -730,7 +1237,7 @@ func HandleUploadAssets(deps *Dependencies) asynq.HandlerFunc {
 			return fmt.Errorf("failed to unmarshal payload: %w", err)
 		}
 
-		logger = logger.With(zap.String("job_id", payload.JobID.String()))
+		logger = logger.With(payload.LogFields()...)
 		logger.Info("starting upload assets task")
 
 		// Load job
@@ -740,10 +1247,15 @@ func HandleUploadAssets(deps *Dependencies) asynq.HandlerFunc {
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to load job: %v", err))
 		}
 
+		// Stop if the job was cancelled or already finished
+		if job.IsTerminal() {
+			logger.Info("job is terminal, skipping task", zap.String("status", job.Status))
+			return nil
+		}
+
 		// Update status
-		job.Status = models.StatusUploading
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
-			logger.Error("failed to update job status", zap.Error(err))
+		if err := startStage(ctx, deps, job, models.StatusUploading, models.StageUpload); err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job status", logger)
 		}
 
 		// Find the video file - it should be in a temp directory
@@ -758,84 +1270,87 @@ func HandleUploadAssets(deps *Dependencies) asynq.HandlerFunc {
 		videoPath := matches[0]
 		logger.Info("found video file", zap.String("path", videoPath))
 
-		// Get parent directory for cleanup later
+		// Get parent directory for cleanup later. An interrupted upload keeps the
+		// video so that the retried task can upload it.
 		tempDir := filepath.Dir(videoPath)
-		defer os.RemoveAll(tempDir)
-
-		// Open video file
-		videoFile, err := os.Open(videoPath)
-		if err != nil {
-			logger.Error("failed to open video file", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to open video file: %v", err))
-		}
-		defer videoFile.Close()
+		defer func() {
+			if taskInterrupted(ctx) == nil {
+				os.RemoveAll(tempDir)
+			}
+		}()
 
-		// Upload to R2
+		// Upload to R2; files over r2.MultipartThreshold go up in parts
 		// Key format: videos/{job_id}.mp4
-		r2Key := fmt.Sprintf("videos/%s.mp4", payload.JobID.String())
-
-		if err := deps.R2Client.Upload(ctx, r2Key, videoFile, "video/mp4"); err != nil {
+		r2Key, _ := r2.JobAssetKey(r2.AssetVideo, payload.JobID.String())
+
+		recordStageTime(ctx, deps, payload.JobID, models.StageVideoUpload, models.StageEventStarted, logger)
+		uploadStart := time.Now()
+		logProgress := func(p r2.UploadProgress) {
+			logger.Info("video upload progress",
+				zap.Int("parts_done", p.PartsDone),
+				zap.Int("total_parts", p.TotalParts),
+				zap.Int64("bytes_done", p.BytesDone),
+				zap.Int64("total_bytes", p.TotalBytes),
+			)
+		}
+		err = deps.R2Client.UploadFile(ctx, r2Key, videoPath, "video/mp4", logProgress)
+		recordProviderCall(ctx, deps, models.ProviderR2, err)
+		if err != nil {
+			if interrupted := taskInterrupted(ctx); interrupted != nil {
+				logger.Warn("video upload interrupted by shutdown, task will be retried")
+				return interrupted
+			}
 			logger.Error("failed to upload video to R2", zap.Error(err))
 			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to upload video: %v", err))
 		}
 
-		logger.Info("video uploaded to R2", zap.String("key", r2Key))
-
-		// Get public URL
-		videoURL := deps.R2Client.GetPublicURL(r2Key)
-		if videoURL == "" {
-			// If no public URL configured, use presigned URL
-			presignedURL, err := deps.R2Client.GetPresignedURL(ctx, r2Key, 24*time.Hour)
-			if err != nil {
-				logger.Error("failed to generate presigned URL", zap.Error(err))
-				return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to generate presigned URL: %v", err))
-			}
-			videoURL = presignedURL
-		}
+		logger.Info("video uploaded to R2",
+			zap.String("key", r2Key),
+			zap.Duration("upload_duration", time.Since(uploadStart)),
+		)
 
-		// Update job with video URL
-		job.VideoURL = &videoURL
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
-			logger.Error("failed to update job with video url", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to update job: %v", err))
+		storeThumbnail(ctx, deps, payload.JobID, videoPath, logger)
+		storeTracks(ctx, deps, job, logger)
+		if interrupted := taskInterrupted(ctx); interrupted != nil {
+			return interrupted
 		}
 
-		// Check if user has YouTube connected — if so, enqueue YouTube upload
+		// Users with YouTube connected go on to the YouTube upload; everyone else is done
+		nextStatus := models.StatusCompleted
 		if deps.YouTubeClient != nil {
 			ytToken, err := deps.UserRepo.GetYouTubeToken(ctx, job.UserID)
 			if err != nil {
 				logger.Warn("failed to check YouTube token, skipping YouTube upload", zap.Error(err))
 			} else if ytToken != nil && *ytToken != "" {
-				// User has YouTube connected — transition to uploading_youtube
-				if err := deps.JobRepo.UpdateStatus(ctx, payload.JobID, models.StatusUploadingYouTube); err != nil {
-					logger.Warn("failed to set uploading_youtube status", zap.Error(err))
-				}
-
-				nextPayload, _ := (&TaskPayload{JobID: payload.JobID}).Marshal()
-				nextTask := asynq.NewTask(TypeUploadYouTube, nextPayload)
-				if _, err := deps.AsynqClient.Enqueue(nextTask); err != nil {
-					logger.Error("failed to enqueue YouTube upload task", zap.Error(err))
-					// YouTube enqueue failure should NOT fail the job — mark completed with error note
-					ytErr := fmt.Sprintf("failed to enqueue YouTube upload: %v", err)
-					job.YouTubeError = &ytErr
-					job.Status = models.StatusCompleted
-					_ = deps.JobRepo.Update(ctx, job)
-				} else {
-					logger.Info("enqueued YouTube upload task")
-				}
-				return nil
+				nextStatus = models.StatusUploadingYouTube
 			}
 		}
 
-		// No YouTube — mark completed directly
-		job.Status = models.StatusCompleted
-		if err := deps.JobRepo.Update(ctx, job); err != nil {
-			logger.Error("failed to mark job completed", zap.Error(err))
-			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to update job: %v", err))
+		// Store the key rather than a URL; presigned URLs expire, so they are generated on read
+		extras := stageExtras(deps, models.StageVideoUpload, models.StageUpload)
+		if err := deps.JobRepo.UpdateVideoKeyAtomic(ctx, payload.JobID, models.StatusUploading, r2Key, nextStatus, extras); err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job with video key", logger)
 		}
 
+		if nextStatus == models.StatusUploadingYouTube {
+			nextPayload, _ := (&TaskPayload{JobID: payload.JobID, TraceID: payload.TraceID}).Marshal()
+			nextTask := asynq.NewTask(TypeUploadYouTube, nextPayload, asynq.TaskID(DedupTaskID(TypeUploadYouTube, payload.JobID)))
+			if _, err := deps.AsynqClient.Enqueue(nextTask); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+				logger.Error("failed to enqueue YouTube upload task", zap.Error(err))
+				// YouTube enqueue failure should NOT fail the job — mark completed with error note
+				ytErr := fmt.Sprintf("failed to enqueue YouTube upload: %v", err)
+				_ = deps.JobRepo.UpdateYouTubeResult(ctx, payload.JobID, nil, nil, &ytErr, models.StatusCompleted)
+				notifyJobFinished(ctx, deps, payload.JobID)
+			} else {
+				logger.Info("enqueued YouTube upload task")
+			}
+			return nil
+		}
+
+		notifyJobFinished(ctx, deps, payload.JobID)
+
 		logger.Info("job completed successfully",
-			zap.String("video_url", videoURL),
+			zap.String("video_key", r2Key),
 		)
 
 		return nil
@@ -844,9 +1359,9 @@ func HandleUploadAssets(deps *Dependencies) asynq.HandlerFunc {
 
 // HandleUploadYouTube creates a handler for the YouTube upload task.
 // This handler:
-// 1. Loads the job (must have video_url)
+// 1. Loads the job (must have video_key or a legacy video_url)
 // 2. Gets user's YouTube refresh token
-// 3. Downloads video from R2 URL
+// 3. Downloads video from R2
 // 4. Uploads to YouTube with privacy=unlisted
 // 5. Updates job with youtube_url/youtube_video_id or youtube_error
 // 6. Always marks job as completed (YouTube failure does NOT fail the job)
@@ -861,9 +1376,12 @@ func HandleUploadYouTube(deps *Dependencies) asynq.HandlerFunc {
 			return fmt.Errorf("failed to unmarshal payload: %w", err)
 		}
 
-		logger = logger.With(zap.String("job_id", payload.JobID.String()))
+		logger = logger.With(payload.LogFields()...)
 		logger.Info("starting YouTube upload task")
 
+		// Every outcome below completes the job
+		defer notifyJobFinished(ctx, deps, payload.JobID)
+
 		// Load job
 		job, err := deps.JobRepo.GetByID(ctx, payload.JobID)
 		if err != nil {
@@ -871,10 +1389,10 @@ func HandleUploadYouTube(deps *Dependencies) asynq.HandlerFunc {
 			return nil // Don't retry — job is already completed on R2
 		}
 
-		// Verify video URL exists
-		if job.VideoURL == nil || *job.VideoURL == "" {
-			logger.Error("job missing video_url")
-			ytErr := "job missing video_url for YouTube upload"
+		// Verify the video exists
+		if !job.HasVideo() {
+			logger.Error("job missing video")
+			ytErr := "job missing video for YouTube upload"
 			_ = deps.JobRepo.UpdateYouTubeResult(ctx, payload.JobID, nil, nil, &ytErr, models.StatusCompleted)
 			return nil
 		}
@@ -897,8 +1415,17 @@ func HandleUploadYouTube(deps *Dependencies) asynq.HandlerFunc {
 			return nil
 		}
 
-		// Download video from R2 public URL via HTTP
-		httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, *job.VideoURL, nil)
+		// Resolve a fresh URL from the stored key; older jobs only have the persisted URL
+		videoURL := job.ToResponseWithSigner(ctx, deps.R2Client).VideoURL
+		if videoURL == nil || *videoURL == "" {
+			logger.Error("failed to resolve video URL")
+			ytErr := "failed to resolve video URL for YouTube upload"
+			_ = deps.JobRepo.UpdateYouTubeResult(ctx, payload.JobID, nil, nil, &ytErr, models.StatusCompleted)
+			return nil
+		}
+
+		// Download video from R2 via HTTP
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, *videoURL, nil)
 		if err != nil {
 			logger.Error("failed to create download request", zap.Error(err))
 			ytErr := fmt.Sprintf("failed to create download request: %v", err)
@@ -965,11 +1492,145 @@ func HandleUploadYouTube(deps *Dependencies) asynq.HandlerFunc {
 // markJobFailed updates the job status to failed with the given error message.
 // It returns the original error for proper task failure handling.
 func markJobFailed(ctx context.Context, deps *Dependencies, jobID uuid.UUID, errorMessage string) error {
-	if err := deps.JobRepo.UpdateWithError(ctx, jobID, errorMessage); err != nil {
+	return markJobFailure(ctx, deps, jobID, models.JobFailure{Message: errorMessage})
+}
+
+// agentFailure describes a job failure caused by an agent error. Output cut off at
+// the model's limit is classified so the user is told to switch models.
+func agentFailure(err error, message string) models.JobFailure {
+	var truncated *openrouter.TruncatedError
+	if errors.As(err, &truncated) {
+		return models.JobFailure{
+			Message: fmt.Sprintf("%s (model %s)", models.OutputTruncatedMessage, truncated.Model),
+			Code:    models.JobErrorOutputTruncated,
+		}
+	}
+	return models.JobFailure{Message: fmt.Sprintf("%s: %v", message, err)}
+}
+
+// renderInputFailure classifies a render that failed because a downloaded input
+// was not media of the expected type or was too large.
+func renderInputFailure(err error) (models.JobFailure, bool) {
+	var unexpected *ffmpeg.UnexpectedContentError
+	switch {
+	case errors.As(err, &unexpected):
+		return models.JobFailure{
+			Message: fmt.Sprintf("%s (%s, content-type %q)", models.UnexpectedMediaMessage, unexpected.Kind, unexpected.ContentType),
+			Code:    models.JobErrorUnexpectedMedia,
+		}, true
+	case errors.Is(err, ffmpeg.ErrDownloadTooLarge):
+		return models.JobFailure{
+			Message: fmt.Sprintf("%s (%v)", models.MediaTooLargeMessage, err),
+			Code:    models.JobErrorMediaTooLarge,
+		}, true
+	}
+	return models.JobFailure{}, false
+}
+
+// markJobFailure is markJobFailed for a classified failure, recording its error
+// code and the step a retry restarts from.
+func markJobFailure(ctx context.Context, deps *Dependencies, jobID uuid.UUID, failure models.JobFailure) error {
+	// Keep the trace ID with the stored error so a failed job can be matched to its logs
+	if traceID := TraceIDFromContext(ctx); traceID != "" {
+		failure.Message = fmt.Sprintf("%s [trace_id=%s]", failure.Message, traceID)
+	}
+	errorMessage := failure.Message
+	if err := deps.JobRepo.UpdateWithFailure(ctx, jobID, failure); err != nil {
+		if errors.Is(err, repository.ErrStatusConflict) {
+			// Job is already terminal (e.g. cancelled by the user) — retrying cannot help
+			deps.Logger.Info("job already terminal, not retrying task",
+				zap.String("job_id", jobID.String()),
+				zap.String("error_message", errorMessage),
+			)
+			return fmt.Errorf("%s: %w", errorMessage, asynq.SkipRetry)
+		}
 		deps.Logger.Error("failed to mark job as failed",
 			zap.String("job_id", jobID.String()),
 			zap.Error(err),
 		)
+	} else {
+		notifyJobFinished(ctx, deps, jobID)
 	}
 	return fmt.Errorf("%s", errorMessage)
 }
+
+// notifyJobFinished tells the job notifier, if configured, that the job reached a final status.
+func notifyJobFinished(ctx context.Context, deps *Dependencies, jobID uuid.UUID) {
+	if deps.JobNotifier != nil {
+		deps.JobNotifier.JobFinished(ctx, jobID)
+	}
+}
+
+// failMusicGeneration marks the job failed after a Suno error. Sensitive word
+// rejections get a user-facing message and skip task retries, since Suno rejects
+// the same prompt again.
+func failMusicGeneration(ctx context.Context, deps *Dependencies, jobID uuid.UUID, err error, msg string) error {
+	if errors.Is(err, kie.ErrSensitiveContent) {
+		return fmt.Errorf("%v: %w", markJobFailed(ctx, deps, jobID, kie.SensitiveContentMessage), asynq.SkipRetry)
+	}
+	return markJobFailed(ctx, deps, jobID, fmt.Sprintf("%s: %v", msg, err))
+}
+
+// advanceStatus moves the job to status unless it is already there. The write is
+// guarded by the status the job was loaded with, so a concurrent cancellation or a
+// duplicate task surfaces as repository.ErrStatusConflict instead of being overwritten.
+func advanceStatus(ctx context.Context, deps *Dependencies, job *models.Job, status string) error {
+	if job.Status == status {
+		return nil
+	}
+	if err := deps.JobRepo.TransitionStatusAtomic(ctx, job.ID, job.Status, status); err != nil {
+		return err
+	}
+	job.Status = status
+	return nil
+}
+
+// startStage moves the job to status and records the start of stage in one write.
+// Unlike advanceStatus it always writes, since a retry re-enters the same status.
+func startStage(ctx context.Context, deps *Dependencies, job *models.Job, status, stage string) error {
+	if err := deps.JobRepo.StartStageAtomic(ctx, job.ID, job.Status, status, stage, deps.WorkerID); err != nil {
+		return err
+	}
+	job.Status = status
+	return nil
+}
+
+// handleUpdateError handles a failed job write. A status conflict means the job was
+// cancelled or advanced by another task, so the handler stops without failing the job;
+// any other error marks the job failed.
+func handleUpdateError(ctx context.Context, deps *Dependencies, jobID uuid.UUID, err error, msg string, logger *zap.Logger) error {
+	if errors.Is(err, repository.ErrStatusConflict) {
+		logger.Info("job status changed concurrently, stopping task", zap.String("step", msg))
+		return nil
+	}
+	logger.Error(msg, zap.Error(err))
+	return markJobFailed(ctx, deps, jobID, fmt.Sprintf("failed to update job: %v", err))
+}
+
+// taskInterrupted returns an error when the task context was cancelled, which
+// happens when the worker shuts down before the task finishes. Handlers return it
+// instead of failing the job so that asynq retries the task on the next worker.
+func taskInterrupted(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("task interrupted: %w", err)
+	}
+	return nil
+}
+
+// isJobStopped reloads the job and reports whether it reached a terminal state
+// (e.g. cancelled by the user) so handlers can skip expensive work and next tasks.
+func isJobStopped(ctx context.Context, deps *Dependencies, jobID uuid.UUID, logger *zap.Logger) bool {
+	job, err := deps.JobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		// Let the caller proceed; its own error handling covers a missing job
+		return false
+	}
+	if job.IsTerminal() {
+		logger.Info("job is terminal, stopping pipeline",
+			zap.String("status", job.Status),
+			zap.Bool("cancelled", job.IsCancelled()),
+		)
+		return true
+	}
+	return false
+}
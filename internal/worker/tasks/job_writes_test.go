@@ -0,0 +1,128 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// maxJobWrites is the most job writes a handler makes: the guarded status
+// transition at its start and the combined result and transition at its end.
+const maxJobWrites = 2
+
+// TestHandlerJobWrites runs handlers against Postgres, recording every
+// statement, and checks that each writes the job at most maxJobWrites times
+// with the per-field atomic methods rather than a full-row update. It needs
+// TEST_DATABASE_URL.
+func TestHandlerJobWrites(t *testing.T) {
+	const concept = `{"prompt": "[Verse]\nแสงไฟในเมือง", "style": "thai pop", "title": "แสงไฟ", "title_en": "City Lights"}`
+	songs := []models.GeneratedSong{
+		{ID: "song-a", AudioURL: "https://cdn.example.com/a.mp3", Title: "แสงไฟ", Duration: 120},
+		{ID: "song-b", AudioURL: "https://cdn.example.com/b.mp3", Title: "แสงไฟ", Duration: 130},
+	}
+
+	tests := []struct {
+		name       string
+		job        models.Job
+		handler    func(*Dependencies) asynq.HandlerFunc
+		taskType   string
+		responses  []string
+		errs       []error
+		wantStatus string
+	}{
+		{
+			name:       "analyze concept",
+			job:        models.Job{Status: models.StatusPending},
+			handler:    HandleAnalyzeConcept,
+			taskType:   TypeAnalyzeConcept,
+			responses:  []string{concept},
+			wantStatus: models.StatusAnalyzing,
+		},
+		{
+			name:       "analyze concept failing",
+			job:        models.Job{Status: models.StatusPending},
+			handler:    HandleAnalyzeConcept,
+			taskType:   TypeAnalyzeConcept,
+			errs:       []error{errors.New("openrouter: 502 bad gateway")},
+			wantStatus: models.StatusFailed,
+		},
+		{
+			name:       "select song",
+			job:        models.Job{Status: models.StatusGeneratingMusic, GeneratedSongs: songs},
+			handler:    HandleSelectSong,
+			taskType:   TypeSelectSong,
+			responses:  []string{`{"selectedSongId": "song-b", "reasoning": "stronger chorus"}`},
+			wantStatus: models.StatusGeneratingImage,
+		},
+		{
+			name:       "select the only song",
+			job:        models.Job{Status: models.StatusGeneratingMusic, GeneratedSongs: songs[:1]},
+			handler:    HandleSelectSong,
+			taskType:   TypeSelectSong,
+			wantStatus: models.StatusGeneratingImage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, statements := testutil.NewRecordedDB(t)
+			ctx := context.Background()
+
+			chat := testutil.NewFakeChatClient(tt.responses...)
+			chat.FailNext(tt.errs...)
+			job := tt.job
+			job.Concept = "เพลงรักในเมืองหลวง"
+			f := newHandlerFixture(t, job, chat)
+
+			// The job's owner must exist for the foreign key; the fixture's
+			// in-memory user still supplies the API keys
+			user := f.deps.UserRepo.(*memoryUserRepo).user
+			user.Email = "writes-" + user.ID.String() + "@example.com"
+			user.PasswordHash = "unused"
+			if err := repository.NewUserRepository(db).Create(ctx, &user); err != nil {
+				t.Fatalf("failed to create user: %v", err)
+			}
+			jobRepo := repository.NewJobRepository(db)
+			if err := jobRepo.Create(ctx, &f.jobs.job); err != nil {
+				t.Fatalf("failed to create job: %v", err)
+			}
+			f.deps.JobRepo = jobRepo
+			statements.Reset()
+
+			// A failing handler returns its error after storing the failure on the job
+			_ = f.run(tt.handler, tt.taskType)
+
+			stored, err := jobRepo.GetByID(ctx, f.jobs.job.ID)
+			if err != nil {
+				t.Fatalf("GetByID() error = %v", err)
+			}
+			if stored.Status != tt.wantStatus {
+				t.Errorf("job status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+
+			var writes []string
+			for _, sql := range statements.Statements() {
+				upper := strings.ToUpper(sql)
+				if strings.HasPrefix(upper, "UPDATE JOBS") || strings.HasPrefix(upper, "INSERT INTO JOBS") {
+					writes = append(writes, sql)
+				}
+			}
+			if len(writes) == 0 || len(writes) > maxJobWrites {
+				t.Errorf("%d job writes, want 1 to %d:\n%s", len(writes), maxJobWrites, strings.Join(writes, "\n"))
+			}
+			for _, sql := range writes {
+				// A full-row Update rewrites every JSONB column
+				if strings.Contains(sql, "generated_songs =") && strings.Contains(sql, "song_prompt =") {
+					t.Errorf("handler made a full-row update: %s", sql)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/security"
+)
+
+// User webhook delivery headers. The signature is HMAC-SHA256 over
+// "<timestamp>.<body>" with the webhook's secret, hex encoded.
+const (
+	userWebhookEventHeader     = "X-UGC-Event"
+	userWebhookTimestampHeader = "X-UGC-Timestamp"
+	userWebhookSignatureHeader = "X-UGC-Signature"
+)
+
+// userWebhookTimeout bounds a single delivery attempt.
+const userWebhookTimeout = 10 * time.Second
+
+// maxUserWebhookErrorLength truncates the stored error of a failed delivery.
+const maxUserWebhookErrorLength = 500
+
+// userWebhookClient checks every address it connects to, since DNS may answer
+// differently than when the URL was validated, and does not follow redirects.
+var userWebhookClient = security.NewPublicHTTPClient(userWebhookTimeout)
+
+// HandleNotifyUser creates a handler for the notify user task.
+// This handler:
+// 1. Loads the webhook and job; stops if the webhook was removed or disabled
+// 2. Re-validates the URL (HTTPS, no private addresses)
+// 3. POSTs the signed event payload
+// 4. Records the outcome on the webhook; non-2xx responses are retried by asynq
+func HandleNotifyUser(deps *Dependencies) asynq.HandlerFunc {
+	return func(ctx context.Context, task *asynq.Task) error {
+		logger := deps.Logger.With(zap.String("task_type", TypeNotifyUser))
+
+		// Parse payload
+		payload, err := UnmarshalNotifyUserPayload(task.Payload())
+		if err != nil {
+			logger.Error("failed to unmarshal task payload", zap.Error(err))
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+
+		logger = logger.With(
+			zap.String("job_id", payload.JobID.String()),
+			zap.String("webhook_id", payload.WebhookID.String()),
+			zap.String("event", payload.Event),
+		)
+		if payload.TraceID != "" {
+			logger = logger.With(zap.String("trace_id", payload.TraceID))
+		}
+
+		if deps.UserWebhookRepo == nil {
+			logger.Error("user webhook repository not configured")
+			return fmt.Errorf("user webhook repository not configured: %w", asynq.SkipRetry)
+		}
+
+		// Load webhook
+		webhook, err := deps.UserWebhookRepo.GetByID(ctx, payload.WebhookID)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserWebhookNotFound) {
+				logger.Debug("user webhook deleted, skipping delivery")
+				return nil
+			}
+			logger.Error("failed to load user webhook", zap.Error(err))
+			return fmt.Errorf("failed to load user webhook: %w", err)
+		}
+		if !webhook.Subscribes(payload.Event) {
+			logger.Debug("user webhook disabled or unsubscribed, skipping delivery")
+			return nil
+		}
+
+		// Load job
+		job, err := deps.JobRepo.GetByID(ctx, payload.JobID)
+		if err != nil {
+			logger.Error("failed to load job", zap.Error(err))
+			return fmt.Errorf("failed to load job: %w", err)
+		}
+		if job.UserID != webhook.UserID {
+			logger.Warn("user webhook does not belong to job owner, skipping delivery")
+			return nil
+		}
+
+		// DNS may have changed since the URL was saved
+		if err := security.ValidatePublicURL(webhook.URL); err != nil {
+			logger.Warn("user webhook URL rejected", zap.Error(err))
+			recordUserWebhookDelivery(ctx, deps, webhook.ID, nil, fmt.Sprintf("URL rejected: %v", err), logger)
+			return fmt.Errorf("user webhook URL rejected: %v: %w", err, asynq.SkipRetry)
+		}
+
+		secret, err := deps.CryptoService.Decrypt(webhook.Secret)
+		if err != nil {
+			logger.Error("failed to decrypt user webhook secret", zap.Error(err))
+			return fmt.Errorf("failed to decrypt user webhook secret: %w", asynq.SkipRetry)
+		}
+
+		body, err := json.Marshal(buildUserWebhookPayload(ctx, deps, job, payload.Event))
+		if err != nil {
+			return fmt.Errorf("failed to marshal user webhook payload: %w", err)
+		}
+
+		statusCode, err := deliverUserWebhook(ctx, webhook.URL, secret, payload.Event, body)
+		if err != nil {
+			logger.Warn("user webhook delivery failed", zap.Error(err))
+			recordUserWebhookDelivery(ctx, deps, webhook.ID, statusCode, err.Error(), logger)
+			return fmt.Errorf("user webhook delivery failed: %w", err)
+		}
+
+		recordUserWebhookDelivery(ctx, deps, webhook.ID, statusCode, "", logger)
+		logger.Info("user webhook delivered", zap.Int("status_code", *statusCode))
+		return nil
+	}
+}
+
+// buildUserWebhookPayload builds the event body for a finished job.
+func buildUserWebhookPayload(ctx context.Context, deps *Dependencies, job *models.Job, event string) *models.UserWebhookPayload {
+	payload := &models.UserWebhookPayload{
+		Event:        event,
+		JobID:        job.ID,
+		Status:       job.Status,
+		ErrorMessage: job.ErrorMessage,
+		Timestamp:    time.Now().UTC(),
+	}
+	if deps.R2Client != nil {
+		payload.VideoURL = job.ToResponseWithSigner(ctx, deps.R2Client).VideoURL
+	} else {
+		payload.VideoURL = job.VideoURL
+	}
+	return payload
+}
+
+// deliverUserWebhook POSTs a signed body to url. It returns the response status code
+// when a response was received, and an error unless the status code is 2xx.
+func deliverUserWebhook(ctx context.Context, url, secret, event string, body []byte) (*int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "UGC-Webhooks/1.0")
+	req.Header.Set(userWebhookEventHeader, event)
+	req.Header.Set(userWebhookTimestampHeader, timestamp)
+	req.Header.Set(userWebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := userWebhookClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	statusCode := resp.StatusCode
+	if statusCode < 200 || statusCode >= 300 {
+		return &statusCode, fmt.Errorf("unexpected status code %d", statusCode)
+	}
+	return &statusCode, nil
+}
+
+// recordUserWebhookDelivery stores the outcome of a delivery attempt. An empty
+// deliveryErr records a success.
+func recordUserWebhookDelivery(ctx context.Context, deps *Dependencies, webhookID uuid.UUID, statusCode *int, deliveryErr string, logger *zap.Logger) {
+	var errPtr *string
+	if deliveryErr != "" {
+		if len(deliveryErr) > maxUserWebhookErrorLength {
+			deliveryErr = deliveryErr[:maxUserWebhookErrorLength]
+		}
+		errPtr = &deliveryErr
+	}
+	if err := deps.UserWebhookRepo.RecordDelivery(ctx, webhookID, statusCode, errPtr); err != nil {
+		logger.Warn("failed to record user webhook delivery", zap.Error(err))
+	}
+}
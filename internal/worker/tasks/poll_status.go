@@ -0,0 +1,270 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/kie"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// Polling fallback settings. KIE reports a failed callback delivery only in the
+// task status (CALLBACK_EXCEPTION), so jobs waiting on a webhook also poll with
+// backoff: 3m, 6m, then every 10m, about an hour in total.
+const (
+	pollInitialDelay = 3 * time.Minute
+	pollMaxDelay     = 10 * time.Minute
+	pollMaxAttempts  = 8
+)
+
+// pollDelay returns how long to wait before poll number attempt (starting at 0).
+func pollDelay(attempt int) time.Duration {
+	delay := pollInitialDelay
+	for i := 0; i < attempt && delay < pollMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > pollMaxDelay {
+		return pollMaxDelay
+	}
+	return delay
+}
+
+// pollTaskID returns the TaskID of poll number attempt of a KIE task. It includes the
+// attempt so a retried handler cannot schedule the same poll twice.
+func pollTaskID(taskType, kieTaskID string, attempt int) string {
+	return fmt.Sprintf("%s-%s-%d", pollTaskIDPrefixes[taskType], kieTaskID, attempt)
+}
+
+// enqueuePoll schedules the next poll of payload.TaskID.
+func enqueuePoll(deps *Dependencies, taskType string, payload PollTaskPayload) error {
+	payloadBytes, err := payload.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal poll payload: %w", err)
+	}
+
+	task := asynq.NewTask(taskType, payloadBytes,
+		asynq.TaskID(pollTaskID(taskType, payload.TaskID, payload.Attempt)),
+		asynq.ProcessIn(pollDelay(payload.Attempt)),
+	)
+	if _, err := deps.AsynqClient.Enqueue(task); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return err
+	}
+	return nil
+}
+
+// enqueueMusicPoll schedules a poll of a Suno task in case its callback never arrives.
+func enqueueMusicPoll(deps *Dependencies, payload PollTaskPayload) error {
+	return enqueuePoll(deps, TypePollMusicStatus, payload)
+}
+
+// enqueueImagePoll schedules a poll of a job's image tasks in case their callbacks never arrive.
+func enqueueImagePoll(deps *Dependencies, payload PollTaskPayload) error {
+	return enqueuePoll(deps, TypePollImageStatus, payload)
+}
+
+// HandlePollMusicStatus creates a handler for the poll music status task.
+// This handler:
+// 1. Loads the job; stops if the callback already moved it past generating_music
+// 2. Calls SunoClient.GetTask for the job's Suno task
+// 3. Applies a finished task through the webhook processor, as if its callback arrived
+// 4. Otherwise schedules the next poll, failing the job after pollMaxAttempts
+func HandlePollMusicStatus(deps *Dependencies) asynq.HandlerFunc {
+	return func(ctx context.Context, task *asynq.Task) error {
+		logger := deps.Logger.With(zap.String("task_type", TypePollMusicStatus))
+
+		// Parse payload
+		payload, err := UnmarshalPollTaskPayload(task.Payload())
+		if err != nil {
+			logger.Error("failed to unmarshal task payload", zap.Error(err))
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+
+		logger = logger.With(
+			zap.String("job_id", payload.JobID.String()),
+			zap.String("suno_task_id", payload.TaskID),
+			zap.Int("attempt", payload.Attempt+1),
+		)
+		if payload.TraceID != "" {
+			logger = logger.With(zap.String("trace_id", payload.TraceID))
+		}
+
+		// Load job
+		job, err := deps.JobRepo.GetByID(ctx, payload.JobID)
+		if err != nil {
+			logger.Error("failed to load job", zap.Error(err))
+			return fmt.Errorf("failed to load job: %w", err)
+		}
+
+		// The callback (or a newer generate music task) already handled this Suno task
+		if job.Status != models.StatusGeneratingMusic || job.SunoTaskID == nil || *job.SunoTaskID != payload.TaskID {
+			logger.Debug("job no longer waiting on this suno task, skipping poll", zap.String("status", job.Status))
+			return nil
+		}
+
+		if deps.WebhookReprocessor == nil {
+			logger.Error("webhook reprocessor not configured")
+			return fmt.Errorf("webhook reprocessor not configured: %w", asynq.SkipRetry)
+		}
+
+		uc, err := LoadUserContext(ctx, deps, job)
+		if err != nil || uc.KIEKey == "" {
+			logger.Error("failed to get user KIE API key", zap.Error(err))
+			return markJobFailed(ctx, deps, payload.JobID, "failed to get KIE API key while checking music generation")
+		}
+
+		sunoClient := newMusicClient(deps, uc.KIEKey)
+		taskResp, err := sunoClient.GetTask(ctx, payload.TaskID)
+		if err != nil {
+			logger.Warn("failed to get suno task status", zap.Error(err))
+		} else if isSunoTaskFinished(taskResp.Data.Status) {
+			logger.Warn("suno task finished without a callback, applying polled result",
+				zap.String("suno_status", taskResp.Data.Status),
+			)
+			return deps.WebhookReprocessor.ApplySunoTask(ctx, taskResp, payload.TraceID)
+		}
+
+		payload.Attempt++
+		if payload.Attempt >= pollMaxAttempts {
+			logger.Error("suno task did not finish, giving up")
+			return markJobFailed(ctx, deps, payload.JobID, "music generation timed out")
+		}
+		if err := enqueueMusicPoll(deps, *payload); err != nil {
+			logger.Error("failed to enqueue next music poll", zap.Error(err))
+			return fmt.Errorf("failed to enqueue next music poll: %w", err)
+		}
+		return nil
+	}
+}
+
+// isSunoTaskFinished reports whether a Suno task status is final. FIRST_SUCCESS is
+// not: the second track is still being generated.
+func isSunoTaskFinished(status string) bool {
+	switch status {
+	case kie.StatusSuccess, kie.StatusCallbackException, kie.StatusCreateTaskFailed,
+		kie.StatusGenerateAudioFailed, kie.StatusSensitiveWordError:
+		return true
+	}
+	return false
+}
+
+// HandlePollImageStatus creates a handler for the poll image status task.
+// This handler:
+// 1. Loads the job; stops if the callbacks already moved it past generating_image
+// 2. Calls NanoBananaClient.GetTask for every image task still pending
+// 3. Applies each finished task through the webhook processor, as if its callback arrived
+// 4. Schedules the next poll while tasks are pending; after pollMaxAttempts the
+// remaining candidates are applied as failed so selection can use the others
+func HandlePollImageStatus(deps *Dependencies) asynq.HandlerFunc {
+	return func(ctx context.Context, task *asynq.Task) error {
+		logger := deps.Logger.With(zap.String("task_type", TypePollImageStatus))
+
+		// Parse payload
+		payload, err := UnmarshalPollTaskPayload(task.Payload())
+		if err != nil {
+			logger.Error("failed to unmarshal task payload", zap.Error(err))
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+
+		logger = logger.With(
+			zap.String("job_id", payload.JobID.String()),
+			zap.Int("attempt", payload.Attempt+1),
+		)
+		if payload.TraceID != "" {
+			logger = logger.With(zap.String("trace_id", payload.TraceID))
+		}
+
+		// Load job
+		job, err := deps.JobRepo.GetByID(ctx, payload.JobID)
+		if err != nil {
+			logger.Error("failed to load job", zap.Error(err))
+			return fmt.Errorf("failed to load job: %w", err)
+		}
+
+		if job.Status != models.StatusGeneratingImage {
+			logger.Debug("job no longer generating images, skipping poll", zap.String("status", job.Status))
+			return nil
+		}
+
+		pending := pendingImageTaskIDs(job)
+		if len(pending) == 0 {
+			return nil
+		}
+
+		if deps.WebhookReprocessor == nil {
+			logger.Error("webhook reprocessor not configured")
+			return fmt.Errorf("webhook reprocessor not configured: %w", asynq.SkipRetry)
+		}
+
+		uc, err := LoadUserContext(ctx, deps, job)
+		if err != nil || uc.KIEKey == "" {
+			logger.Error("failed to get user KIE API key", zap.Error(err))
+			return markJobFailed(ctx, deps, payload.JobID, "failed to get KIE API key while checking image generation")
+		}
+
+		nanoBananaClient := newImageClient(deps, uc.KIEKey)
+		giveUp := payload.Attempt+1 >= pollMaxAttempts
+		stillPending := 0
+		for _, taskID := range pending {
+			statusResp, err := nanoBananaClient.GetTask(ctx, taskID)
+			if err != nil {
+				logger.Warn("failed to get nano task status", zap.Error(err), zap.String("nano_task_id", taskID))
+			}
+
+			if statusResp == nil || (statusResp.Data.State != kie.StateSuccess && statusResp.Data.State != kie.StateFail) {
+				if !giveUp {
+					stillPending++
+					continue
+				}
+				statusResp = &kie.TaskStatusResponse{Code: 200}
+				statusResp.Data.TaskId = taskID
+				statusResp.Data.State = kie.StateFail
+				statusResp.Data.FailMsg = "image generation timed out"
+			} else {
+				logger.Warn("nano task finished without a callback, applying polled result",
+					zap.String("nano_task_id", taskID),
+					zap.String("state", statusResp.Data.State),
+				)
+			}
+
+			if err := deps.WebhookReprocessor.ApplyNanoTask(ctx, statusResp, payload.TraceID); err != nil {
+				logger.Error("failed to apply polled image result", zap.Error(err), zap.String("nano_task_id", taskID))
+				return err
+			}
+		}
+
+		if stillPending == 0 {
+			return nil
+		}
+
+		payload.Attempt++
+		if err := enqueueImagePoll(deps, *payload); err != nil {
+			logger.Error("failed to enqueue next image poll", zap.Error(err))
+			return fmt.Errorf("failed to enqueue next image poll: %w", err)
+		}
+		return nil
+	}
+}
+
+// pendingImageTaskIDs returns the NanoBanana task IDs the job is still waiting on.
+// Jobs without image candidates wait on their single nano_task_id.
+func pendingImageTaskIDs(job *models.Job) []string {
+	if len(job.GeneratedImages) == 0 {
+		if job.NanoTaskID == nil || job.ImageURL != nil {
+			return nil
+		}
+		return []string{*job.NanoTaskID}
+	}
+
+	taskIDs := make([]string, 0, len(job.GeneratedImages))
+	for _, image := range job.GeneratedImages {
+		if image.Status == models.ImageCandidatePending {
+			taskIDs = append(taskIDs, image.TaskID)
+		}
+	}
+	return taskIDs
+}
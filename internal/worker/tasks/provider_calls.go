@@ -0,0 +1,134 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/kie"
+	"github.com/jaochai/ugc/internal/external/openrouter"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// ProviderRecorder counts calls to the external services and their errors for
+// the public status page. Implemented by service.ProviderStats.
+type ProviderRecorder interface {
+	Record(ctx context.Context, provider string, err error) error
+}
+
+// kieServiceUnavailable is the code KIE answers with while the service is down.
+const kieServiceUnavailable = 455
+
+// httpStatusError is implemented by provider errors that carry a response status:
+// kie.APIError, openrouter.StatusError and the AWS SDK's response errors.
+type httpStatusError interface {
+	HTTPStatusCode() int
+}
+
+// providerFault returns err if it shows the provider failing: a transport error,
+// a 5xx or a 429. Other errors, such as a rejected key or prompt, are the
+// caller's and count as a successful call, so it returns nil for them.
+func providerFault(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var statusErr httpStatusError
+	if errors.As(err, &statusErr) {
+		status := statusErr.HTTPStatusCode()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests || status == kieServiceUnavailable {
+			return err
+		}
+		return nil
+	}
+
+	var urlErr *url.Error
+	var netErr net.Error
+	if errors.As(err, &urlErr) || errors.As(err, &netErr) {
+		return err
+	}
+	return nil
+}
+
+// recordProviderCall counts a call to provider that returned err; only errors
+// that show the provider failing (providerFault) count as errors. Calls cut short
+// by the task's own context say nothing about the provider and are skipped. It
+// is best effort: a failure is logged and the pipeline continues.
+func recordProviderCall(ctx context.Context, deps *Dependencies, provider string, err error) {
+	if deps.ProviderStats == nil || ctx.Err() != nil {
+		return
+	}
+	if recordErr := deps.ProviderStats.Record(context.WithoutCancel(ctx), provider, providerFault(err)); recordErr != nil {
+		deps.Logger.Debug("failed to record provider call", zap.String("provider", provider), zap.Error(recordErr))
+	}
+}
+
+// recordingChatClient counts the calls of an OpenRouter client.
+type recordingChatClient struct {
+	openrouter.ChatClient
+	deps *Dependencies
+}
+
+func (c *recordingChatClient) Chat(ctx context.Context, req openrouter.ChatRequest) (*openrouter.ChatResponse, error) {
+	resp, err := c.ChatClient.Chat(ctx, req)
+	recordProviderCall(ctx, c.deps, models.ProviderOpenRouter, err)
+	return resp, err
+}
+
+func (c *recordingChatClient) Complete(ctx context.Context, req openrouter.ChatRequest) (string, error) {
+	content, err := c.ChatClient.Complete(ctx, req)
+	recordProviderCall(ctx, c.deps, models.ProviderOpenRouter, err)
+	return content, err
+}
+
+func (c *recordingChatClient) ChatWithModel(ctx context.Context, model string, systemPrompt string, userPrompt string) (string, error) {
+	content, err := c.ChatClient.ChatWithModel(ctx, model, systemPrompt, userPrompt)
+	recordProviderCall(ctx, c.deps, models.ProviderOpenRouter, err)
+	return content, err
+}
+
+// recordingMusicClient counts the requests of a Suno client. WaitForCompletion
+// is not counted: it fails for rejected prompts and slow tasks as well.
+type recordingMusicClient struct {
+	kie.MusicClient
+	deps *Dependencies
+}
+
+func (c *recordingMusicClient) Generate(ctx context.Context, req kie.GenerateRequest) (string, error) {
+	taskID, err := c.MusicClient.Generate(ctx, req)
+	recordProviderCall(ctx, c.deps, models.ProviderKIE, err)
+	return taskID, err
+}
+
+func (c *recordingMusicClient) GetTask(ctx context.Context, taskId string) (*kie.TaskResponse, error) {
+	resp, err := c.MusicClient.GetTask(ctx, taskId)
+	recordProviderCall(ctx, c.deps, models.ProviderKIE, err)
+	return resp, err
+}
+
+func (c *recordingMusicClient) WaitForCompletion(ctx context.Context, taskId string, timeout time.Duration) (*kie.TaskResponse, error) {
+	return c.MusicClient.WaitForCompletion(ctx, taskId, timeout)
+}
+
+// recordingImageClient counts the requests of a NanoBanana client, like recordingMusicClient.
+type recordingImageClient struct {
+	kie.ImageClient
+	deps *Dependencies
+}
+
+func (c *recordingImageClient) CreateTask(ctx context.Context, req kie.CreateTaskRequest) (string, error) {
+	taskID, err := c.ImageClient.CreateTask(ctx, req)
+	recordProviderCall(ctx, c.deps, models.ProviderKIE, err)
+	return taskID, err
+}
+
+func (c *recordingImageClient) GetTask(ctx context.Context, taskId string) (*kie.TaskStatusResponse, error) {
+	resp, err := c.ImageClient.GetTask(ctx, taskId)
+	recordProviderCall(ctx, c.deps, models.ProviderKIE, err)
+	return resp, err
+}
@@ -0,0 +1,83 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+
+	"github.com/jaochai/ugc/internal/external/kie"
+	"github.com/jaochai/ugc/internal/external/openrouter"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// callRecorder records the errors passed to Record.
+type callRecorder struct {
+	errs []error
+}
+
+func (r *callRecorder) Record(ctx context.Context, provider string, err error) error {
+	r.errs = append(r.errs, err)
+	return nil
+}
+
+func TestProviderFault(t *testing.T) {
+	transport := &url.Error{Op: "Post", URL: "https://openrouter.ai/api/v1/chat/completions", Err: syscall.ECONNREFUSED}
+
+	tests := []struct {
+		name      string
+		err       error
+		wantFault bool
+	}{
+		{name: "success", err: nil},
+		{name: "transport error", err: fmt.Errorf("failed to send request: %w", transport), wantFault: true},
+		{name: "openrouter 502", err: &openrouter.StatusError{StatusCode: http.StatusBadGateway}, wantFault: true},
+		{name: "openrouter 429", err: &openrouter.StatusError{StatusCode: http.StatusTooManyRequests}, wantFault: true},
+		{name: "openrouter 400", err: &openrouter.StatusError{StatusCode: http.StatusBadRequest}},
+		{name: "openrouter 402", err: &openrouter.StatusError{StatusCode: http.StatusPaymentRequired}},
+		{name: "kie 500", err: fmt.Errorf("generate: %w", &kie.APIError{StatusCode: http.StatusInternalServerError}), wantFault: true},
+		{name: "kie service unavailable", err: &kie.APIError{StatusCode: kieServiceUnavailable}, wantFault: true},
+		{name: "kie invalid key", err: &kie.APIError{StatusCode: http.StatusUnauthorized}},
+		{name: "kie rejected prompt", err: kie.ErrSensitiveContent},
+		{name: "truncated completion", err: &openrouter.TruncatedError{}},
+		{name: "bad response body", err: errors.New("failed to unmarshal response: unexpected EOF")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := providerFault(tt.err)
+			if (got != nil) != tt.wantFault {
+				t.Fatalf("providerFault(%v) = %v, want fault %v", tt.err, got, tt.wantFault)
+			}
+		})
+	}
+}
+
+func TestRecordProviderCallCountsOnlyFaults(t *testing.T) {
+	recorder := &callRecorder{}
+	deps := &Dependencies{ProviderStats: recorder}
+	ctx := context.Background()
+
+	recordProviderCall(ctx, deps, models.ProviderKIE, &kie.APIError{StatusCode: http.StatusUnprocessableEntity})
+	recordProviderCall(ctx, deps, models.ProviderKIE, &kie.APIError{StatusCode: http.StatusServiceUnavailable})
+
+	if len(recorder.errs) != 2 {
+		t.Fatalf("recorded %d calls, want 2", len(recorder.errs))
+	}
+	if recorder.errs[0] != nil {
+		t.Errorf("a 422 was recorded as a provider error: %v", recorder.errs[0])
+	}
+	if recorder.errs[1] == nil {
+		t.Error("a 503 was recorded as a successful call")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	recordProviderCall(cancelled, deps, models.ProviderKIE, context.Canceled)
+	if len(recorder.errs) != 2 {
+		t.Errorf("a call cut short by the task was recorded")
+	}
+}
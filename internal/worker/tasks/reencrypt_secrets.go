@@ -0,0 +1,190 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// reencryptBatchSize is the number of users loaded per page while re-encrypting secrets.
+const reencryptBatchSize = 100
+
+// HandleReencryptSecrets creates a handler for the re-encrypt secrets task.
+// This handler walks every user with stored secrets and rewrites the OpenRouter key,
+// KIE key and YouTube refresh token with the primary encryption key, then does the
+// same for organization keys. Secrets that cannot be decrypted with any configured
+// key are logged and left unchanged.
+func HandleReencryptSecrets(deps *Dependencies) asynq.HandlerFunc {
+	return func(ctx context.Context, task *asynq.Task) error {
+		logger := deps.Logger.With(zap.String("task_type", TypeReencryptSecrets))
+
+		// Parse payload
+		payload, err := UnmarshalMaintenanceTaskPayload(task.Payload())
+		if err != nil {
+			logger.Error("failed to unmarshal task payload", zap.Error(err))
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+
+		logger = logger.With(zap.String("requested_by", payload.RequestedBy.String()))
+		if payload.TraceID != "" {
+			logger = logger.With(zap.String("trace_id", payload.TraceID))
+		}
+		logger.Info("starting re-encrypt secrets task")
+
+		var scanned, rewritten, changed, failed int
+		afterID := uuid.Nil
+		for {
+			batch, err := deps.UserRepo.ListSecrets(ctx, afterID, reencryptBatchSize)
+			if err != nil {
+				return fmt.Errorf("failed to list user secrets: %w", err)
+			}
+
+			for _, current := range batch {
+				scanned++
+				updated, ok, err := reencryptUserSecrets(deps.CryptoService, current)
+				if err != nil {
+					failed++
+					logger.Error("failed to re-encrypt user secrets", zap.String("user_id", current.UserID.String()), zap.Error(err))
+					continue
+				}
+				if !ok {
+					continue
+				}
+
+				replaced, err := deps.UserRepo.ReplaceSecrets(ctx, current, updated)
+				if err != nil {
+					return fmt.Errorf("failed to store re-encrypted secrets: %w", err)
+				}
+				if replaced {
+					rewritten++
+				} else {
+					// The user saved new secrets meanwhile; those already use the primary key
+					changed++
+				}
+			}
+
+			if len(batch) < reencryptBatchSize {
+				break
+			}
+			afterID = batch[len(batch)-1].UserID
+		}
+
+		logger.Info("re-encrypt secrets task completed",
+			zap.Int("users_scanned", scanned),
+			zap.Int("users_rewritten", rewritten),
+			zap.Int("users_changed_concurrently", changed),
+			zap.Int("users_failed", failed),
+		)
+
+		if deps.OrganizationRepo != nil {
+			if err := reencryptOrganizations(ctx, deps, logger); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// reencryptOrganizations rewrites every organization's keys with the primary key.
+func reencryptOrganizations(ctx context.Context, deps *Dependencies, logger *zap.Logger) error {
+	var scanned, rewritten, changed, failed int
+	afterID := uuid.Nil
+	for {
+		batch, err := deps.OrganizationRepo.ListSecrets(ctx, afterID, reencryptBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to list organization secrets: %w", err)
+		}
+
+		for _, current := range batch {
+			scanned++
+			updated := *current
+			ok, err := reencryptFields(deps.CryptoService, []secretField{
+				{"openrouter_api_key", &updated.OpenRouterAPIKey},
+				{"kie_api_key", &updated.KIEAPIKey},
+			})
+			if err != nil {
+				failed++
+				logger.Error("failed to re-encrypt organization secrets", zap.String("org_id", current.OrgID.String()), zap.Error(err))
+				continue
+			}
+			if !ok {
+				continue
+			}
+
+			replaced, err := deps.OrganizationRepo.ReplaceSecrets(ctx, current, &updated)
+			if err != nil {
+				return fmt.Errorf("failed to store re-encrypted organization secrets: %w", err)
+			}
+			if replaced {
+				rewritten++
+			} else {
+				changed++
+			}
+		}
+
+		if len(batch) < reencryptBatchSize {
+			break
+		}
+		afterID = batch[len(batch)-1].OrgID
+	}
+
+	logger.Info("re-encrypted organization secrets",
+		zap.Int("orgs_scanned", scanned),
+		zap.Int("orgs_rewritten", rewritten),
+		zap.Int("orgs_changed_concurrently", changed),
+		zap.Int("orgs_failed", failed),
+	)
+	return nil
+}
+
+// reencryptUserSecrets returns a copy of secrets with every value re-encrypted with
+// the primary key. ok is false if all values already use the primary key.
+func reencryptUserSecrets(crypto CryptoService, secrets *models.UserSecrets) (*models.UserSecrets, bool, error) {
+	updated := *secrets
+	ok, err := reencryptFields(crypto, []secretField{
+		{"openrouter_api_key", &updated.OpenRouterAPIKey},
+		{"kie_api_key", &updated.KIEAPIKey},
+		{"youtube_refresh_token", &updated.YouTubeRefreshToken},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &updated, ok, nil
+}
+
+// secretField is a named encrypted value to re-encrypt in place.
+type secretField struct {
+	name  string
+	value **string
+}
+
+// reencryptFields re-encrypts each set field with the primary key in place. ok is
+// false if all values already use the primary key.
+func reencryptFields(crypto CryptoService, fields []secretField) (bool, error) {
+	var ok bool
+	for _, f := range fields {
+		if *f.value == nil || !crypto.NeedsReencrypt(**f.value) {
+			continue
+		}
+
+		plaintext, err := crypto.Decrypt(**f.value)
+		if err != nil {
+			return false, fmt.Errorf("failed to decrypt %s: %w", f.name, err)
+		}
+
+		ciphertext, err := crypto.Encrypt(plaintext)
+		if err != nil {
+			return false, fmt.Errorf("failed to encrypt %s: %w", f.name, err)
+		}
+
+		*f.value = &ciphertext
+		ok = true
+	}
+
+	return ok, nil
+}
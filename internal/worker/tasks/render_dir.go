@@ -0,0 +1,49 @@
+package tasks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/ffmpeg"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// renderWorkDir returns the directory a job's video render keeps its segments in.
+// It is named after the job, not random, so a retried process_video task finds the
+// segments of the interrupted one. Empty when segmented rendering is off.
+func renderWorkDir(deps *Dependencies, jobID uuid.UUID) string {
+	if deps.VideoSegmentThreshold <= 0 || deps.VideoWorkDir == "" {
+		return ""
+	}
+	return filepath.Join(deps.VideoWorkDir, jobID.String())
+}
+
+// renderResumeKey identifies what a render produces: segments are only reused by
+// a render of the same song, image and settings. The image is identified by its
+// R2 key, as its presigned URL changes on every attempt.
+func renderResumeKey(job *models.Job, input ffmpeg.CreateMusicVideoInput) string {
+	image := input.ImageURL
+	if job.ImageKey != nil && *job.ImageKey != "" {
+		image = *job.ImageKey
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%t|%s|%s",
+		input.AudioURL, image, input.Preset.Name, input.NormalizeLoudness, input.FadeOut, input.MaxDuration)))
+	return hex.EncodeToString(sum[:])
+}
+
+// removeRenderWorkDir deletes a render's work directory once the render finished or
+// failed for good; an interrupted render keeps it to resume from.
+func removeRenderWorkDir(dir string, logger *zap.Logger) {
+	if dir == "" {
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		logger.Warn("failed to remove render work directory", zap.String("dir", dir), zap.Error(err))
+	}
+}
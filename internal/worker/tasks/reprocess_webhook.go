@@ -0,0 +1,50 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+)
+
+// HandleReprocessWebhook creates a handler for the reprocess webhook task.
+// The webhook handler stores a callback and enqueues this task when applying it
+// failed transiently (e.g. a database hiccup). Returning an error lets asynq retry
+// with backoff; the callback was already acknowledged to the provider.
+func HandleReprocessWebhook(deps *Dependencies) asynq.HandlerFunc {
+	return func(ctx context.Context, task *asynq.Task) error {
+		logger := deps.Logger.With(zap.String("task_type", TypeReprocessWebhook))
+
+		// Parse payload
+		payload, err := UnmarshalWebhookTaskPayload(task.Payload())
+		if err != nil {
+			logger.Error("failed to unmarshal task payload", zap.Error(err))
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+
+		logger = logger.With(zap.String("event_id", payload.EventID.String()))
+		if payload.TraceID != "" {
+			logger = logger.With(zap.String("trace_id", payload.TraceID))
+		}
+
+		if deps.WebhookReprocessor == nil {
+			logger.Error("webhook reprocessor not configured")
+			return fmt.Errorf("webhook reprocessor not configured: %w", asynq.SkipRetry)
+		}
+
+		if err := deps.WebhookReprocessor.ReprocessEvent(ctx, payload.EventID, payload.TraceID); err != nil {
+			retried, _ := asynq.GetRetryCount(ctx)
+			maxRetry, _ := asynq.GetMaxRetry(ctx)
+			if retried >= maxRetry {
+				logger.Error("giving up on deferred webhook callback", zap.Error(err), zap.Int("attempts", retried+1))
+			} else {
+				logger.Warn("failed to reprocess webhook callback, will retry", zap.Error(err), zap.Int("attempt", retried+1))
+			}
+			return err
+		}
+
+		logger.Info("deferred webhook callback applied")
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+package tasks
+
+import (
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// ResumePoint is the task that drives a non-terminal job forward from its stored
+// state. Only the jobs row is durable: if Redis loses the queue, ResumePoint tells
+// the job reconciler which task to re-create.
+type ResumePoint struct {
+	// TaskType is the task to enqueue when none of TaskIDs exists.
+	TaskType string
+	// TaskIDs are the asynq TaskIDs of tasks already driving the job; the first
+	// is the TaskID of the task to enqueue.
+	TaskIDs []string
+	// pollTaskID is the KIE task a poll task checks.
+	pollTaskID string
+}
+
+// ResumePointFor returns the task that moves job on from its current status, or
+// false for terminal jobs.
+func ResumePointFor(job *models.Job) (ResumePoint, bool) {
+	switch job.Status {
+	case models.StatusPending:
+		return dedupResumePoint(TypeAnalyzeConcept, job.ID), true
+
+	case models.StatusAnalyzing:
+		// The song prompt is stored before the generate music task is enqueued
+		if job.SongPrompt == nil {
+			return dedupResumePoint(TypeAnalyzeConcept, job.ID), true
+		}
+		return dedupResumePoint(TypeGenerateMusic, job.ID, TypeAnalyzeConcept), true
+
+	case models.StatusGeneratingMusic:
+		switch {
+		case job.SunoTaskID == nil:
+			return dedupResumePoint(TypeGenerateMusic, job.ID), true
+		case len(job.GeneratedSongs) > 0:
+			// The first callback arrived; finalizing selects from the songs recorded so far
+			return dedupResumePoint(TypeFinalizeSongs, job.ID), true
+		}
+		return pollResumePoint(TypePollMusicStatus, *job.SunoTaskID, job.ID, TypeGenerateMusic), true
+
+	case models.StatusSelectingSong:
+		return dedupResumePoint(TypeSelectSong, job.ID), true
+
+	case models.StatusGeneratingImage:
+		if job.HasUserImage() {
+			return dedupResumePoint(TypeGenerateImage, job.ID), true
+		}
+		if pending := pendingImageTaskIDs(job); len(pending) > 0 {
+			kieTaskID := pending[0]
+			if job.NanoTaskID != nil {
+				kieTaskID = *job.NanoTaskID
+			}
+			return pollResumePoint(TypePollImageStatus, kieTaskID, job.ID, TypeGenerateImage), true
+		}
+		if len(job.GeneratedImages) > 0 {
+			return dedupResumePoint(TypeSelectImage, job.ID), true
+		}
+		return dedupResumePoint(TypeGenerateImage, job.ID), true
+
+	case models.StatusProcessingVideo, models.StatusUploading:
+		// The rendered video only exists in the rendering worker's temp directory,
+		// so a lost upload task re-renders rather than re-uploads
+		return dedupResumePoint(TypeProcessVideo, job.ID, TypeUploadAssets), true
+
+	case models.StatusUploadingYouTube:
+		return dedupResumePoint(TypeUploadYouTube, job.ID), true
+	}
+	return ResumePoint{}, false
+}
+
+// dedupResumePoint resumes with taskType's deduplicated task. alsoDrivenBy lists
+// task types that move the job on from the same status, e.g. a task still running.
+func dedupResumePoint(taskType string, jobID uuid.UUID, alsoDrivenBy ...string) ResumePoint {
+	point := ResumePoint{TaskType: taskType, TaskIDs: []string{DedupTaskID(taskType, jobID)}}
+	for _, other := range alsoDrivenBy {
+		point.TaskIDs = append(point.TaskIDs, DedupTaskID(other, jobID))
+	}
+	return point
+}
+
+// pollResumePoint resumes by polling kieTaskID. Any scheduled attempt drives the job;
+// the re-created poll starts again at the first attempt.
+func pollResumePoint(taskType, kieTaskID string, jobID uuid.UUID, alsoDrivenBy ...string) ResumePoint {
+	point := ResumePoint{TaskType: taskType, pollTaskID: kieTaskID}
+	for attempt := 0; attempt < pollMaxAttempts; attempt++ {
+		point.TaskIDs = append(point.TaskIDs, pollTaskID(taskType, kieTaskID, attempt))
+	}
+	for _, other := range alsoDrivenBy {
+		point.TaskIDs = append(point.TaskIDs, DedupTaskID(other, jobID))
+	}
+	return point
+}
+
+// Task creates the task that resumes jobID. Polls run immediately rather than
+// after the usual delay, since the job has already waited.
+func (p ResumePoint) Task(jobID uuid.UUID, traceID string) (*asynq.Task, error) {
+	var (
+		payloadBytes []byte
+		err          error
+	)
+	if p.pollTaskID != "" {
+		payloadBytes, err = (&PollTaskPayload{JobID: jobID, TraceID: traceID, TaskID: p.pollTaskID}).Marshal()
+	} else {
+		payloadBytes, err = (&TaskPayload{JobID: jobID, TraceID: traceID}).Marshal()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(p.TaskType, payloadBytes, asynq.TaskID(p.TaskIDs[0])), nil
+}
@@ -0,0 +1,157 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/agents"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// HandleSelectImage creates a handler for the select image task.
+// This handler:
+// 1. Loads the job (all image candidates must be resolved)
+// 2. Uses ImageSelectorAgent to pick the best successful candidate
+// 3. Updates the job with image_url and transitions to processing_video
+// 4. Enqueues TypeProcessVideo
+func HandleSelectImage(deps *Dependencies) asynq.HandlerFunc {
+	return func(ctx context.Context, task *asynq.Task) error {
+		logger := deps.Logger.With(zap.String("task_type", TypeSelectImage))
+
+		// Parse payload
+		payload, err := UnmarshalTaskPayload(task.Payload())
+		if err != nil {
+			logger.Error("failed to unmarshal task payload", zap.Error(err))
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+
+		logger = logger.With(payload.LogFields()...)
+		logger.Info("starting select image task")
+
+		// Load job
+		job, err := deps.JobRepo.GetByID(ctx, payload.JobID)
+		if err != nil {
+			logger.Error("failed to load job", zap.Error(err))
+			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to load job: %v", err))
+		}
+
+		if job.Status != models.StatusGeneratingImage {
+			logger.Warn("job not in generating_image status, skipping image selection",
+				zap.String("status", job.Status),
+			)
+			return nil
+		}
+
+		if pending := job.PendingImageCandidates(); pending > 0 {
+			logger.Warn("image candidates still pending, skipping image selection",
+				zap.Int("pending", pending),
+			)
+			return nil
+		}
+
+		successful := job.SuccessfulImageCandidates()
+		if len(successful) == 0 {
+			logger.Error("all image candidates failed")
+			return markJobFailed(ctx, deps, payload.JobID, "image generation failed for all candidates")
+		}
+
+		selected, extras := selectImageCandidate(ctx, deps, job, successful, logger)
+
+		// Record the selected candidate and advance the pipeline
+		err = deps.JobRepo.UpdateImageURLAtomic(ctx, payload.JobID, models.StatusGeneratingImage,
+			selected.TaskID, selected.ImageURL, models.StatusProcessingVideo, extras)
+		if err != nil {
+			if errors.Is(err, repository.ErrStatusConflict) {
+				logger.Warn("image already selected by another task")
+				return nil
+			}
+			logger.Error("failed to update job with selected image", zap.Error(err))
+			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to update job: %v", err))
+		}
+
+		logger.Info("image selected",
+			zap.String("nano_task_id", selected.TaskID),
+			zap.Int("candidates", len(successful)),
+		)
+
+		// Skip the next stage if the job was cancelled meanwhile
+		if isJobStopped(ctx, deps, payload.JobID, logger) {
+			return nil
+		}
+
+		// Enqueue next task: process video
+		nextPayload, _ := (&TaskPayload{JobID: payload.JobID, TraceID: payload.TraceID}).Marshal()
+		nextTask := asynq.NewTask(TypeProcessVideo, nextPayload, asynq.TaskID(DedupTaskID(TypeProcessVideo, payload.JobID)))
+		if _, err := deps.AsynqClient.Enqueue(nextTask); err != nil {
+			if errors.Is(err, asynq.ErrTaskIDConflict) {
+				logger.Warn("process video task already enqueued")
+				return nil
+			}
+			logger.Error("failed to enqueue process video task", zap.Error(err))
+			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to enqueue next task: %v", err))
+		}
+
+		logger.Info("enqueued process video task")
+		return nil
+	}
+}
+
+// selectImageCandidate picks the best image using ImageSelectorAgent.
+// Selection is best-effort: any agent failure falls back to the first successful candidate.
+// The returned extras complete the image stage and carry the agent's output, if it ran.
+func selectImageCandidate(ctx context.Context, deps *Dependencies, job *models.Job, successful []models.GeneratedImage, logger *zap.Logger) (models.GeneratedImage, models.JobWriteExtras) {
+	fallback := stageExtras(deps, models.StageImage)
+	if len(successful) == 1 {
+		return successful[0], fallback
+	}
+
+	uc, err := LoadUserContext(ctx, deps, job)
+	if err != nil || uc.OpenRouterKey == "" {
+		logger.Warn("no OpenRouter API key for image selection, using first candidate", zap.Error(err))
+		return successful[0], fallback
+	}
+
+	llmModel := uc.AgentModel(job, models.PromptTypeImageSelector)
+
+	effectivePrompt := getEffectivePrompt(ctx, deps, job, models.PromptTypeImageSelector)
+	openRouterClient := newOpenRouterClient(deps, job, uc.OpenRouterKey)
+	agent := agents.NewImageSelectorAgentWithPrompt(openRouterClient, llmModel, logger, effectivePrompt)
+	agent.SetGenerationParams(getGenerationParams(ctx, deps, job.UserID, models.PromptTypeImageSelector))
+
+	input := agents.ImageSelectorInput{
+		OriginalConcept: job.Concept,
+		Images:          make([]agents.ImageCandidate, len(successful)),
+	}
+	if job.SongPrompt != nil {
+		input.SongTitle = job.SongPrompt.Title
+	}
+	if job.ImagePrompt != nil {
+		input.ImagePrompt = job.ImagePrompt.Prompt
+	}
+	for i, img := range successful {
+		input.Images[i] = agents.ImageCandidate{
+			TaskID:   img.TaskID,
+			ImageURL: img.ImageURL,
+		}
+	}
+
+	output, err := agent.Select(ctx, input)
+	if err != nil {
+		logger.Warn("image selection failed, using first candidate", zap.Error(err))
+		return successful[0], fallback
+	}
+	extras := agentExtras(deps, models.PromptTypeImageSelector, llmModel, agent.GenerationParams(), output.Reasoning,
+		"selected image "+output.SelectedTaskID, output, models.StageImage)
+
+	for _, img := range successful {
+		if img.TaskID == output.SelectedTaskID {
+			return img, extras
+		}
+	}
+	return successful[0], extras
+}
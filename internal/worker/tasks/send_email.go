@@ -0,0 +1,95 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/email"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// HandleSendEmail creates a handler for the send email task.
+// This handler:
+// 1. Loads the job and its owner; stops if the owner no longer wants emails
+// 2. Renders the notification with the title, duration and a fresh download link
+// 3. Sends it through the configured mailer; failures are retried by asynq and
+// never affect the job
+func HandleSendEmail(deps *Dependencies) asynq.HandlerFunc {
+	return func(ctx context.Context, task *asynq.Task) error {
+		logger := deps.Logger.With(zap.String("task_type", TypeSendEmail))
+
+		// Parse payload
+		payload, err := UnmarshalSendEmailPayload(task.Payload())
+		if err != nil {
+			logger.Error("failed to unmarshal task payload", zap.Error(err))
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+
+		logger = logger.With(
+			zap.String("job_id", payload.JobID.String()),
+			zap.String("event", payload.Event),
+		)
+		if payload.TraceID != "" {
+			logger = logger.With(zap.String("trace_id", payload.TraceID))
+		}
+
+		if deps.Mailer == nil {
+			logger.Debug("mailer not configured, skipping email")
+			return nil
+		}
+
+		// Load job
+		job, err := deps.JobRepo.GetByID(ctx, payload.JobID)
+		if err != nil {
+			logger.Error("failed to load job", zap.Error(err))
+			return fmt.Errorf("failed to load job: %w", err)
+		}
+
+		// Load owner
+		user, err := deps.UserRepo.GetByID(ctx, job.UserID)
+		if err != nil {
+			logger.Error("failed to load user", zap.Error(err))
+			return fmt.Errorf("failed to load user: %w", err)
+		}
+		if !user.NotifyEmail || user.IsDeleted() {
+			logger.Debug("user opted out of emails, skipping")
+			return nil
+		}
+
+		var signer models.AssetURLSigner
+		if deps.R2Client != nil {
+			signer = deps.R2Client
+		}
+		shared := job.ToSharedResponse(ctx, signer)
+
+		notification := email.JobNotification{
+			Title:     shared.Title,
+			Completed: payload.Event == models.WebhookEventJobCompleted,
+			Duration:  shared.Duration,
+		}
+		if notification.Completed {
+			if shared.VideoURL != nil {
+				notification.DownloadURL = *shared.VideoURL
+			}
+		} else if job.ErrorMessage != nil {
+			notification.ErrorMessage = *job.ErrorMessage
+		}
+
+		subject, body, err := email.RenderJobNotification(notification)
+		if err != nil {
+			logger.Error("failed to render notification email", zap.Error(err))
+			return fmt.Errorf("failed to render notification email: %w", asynq.SkipRetry)
+		}
+
+		if err := deps.Mailer.Send(ctx, user.Email, subject, body); err != nil {
+			logger.Warn("failed to send notification email", zap.Error(err))
+			return fmt.Errorf("failed to send notification email: %w", err)
+		}
+
+		logger.Info("notification email sent")
+		return nil
+	}
+}
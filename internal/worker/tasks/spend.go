@@ -0,0 +1,106 @@
+package tasks
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/openrouter"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// recordSpend records the estimated KIE credits of the generation task taskID
+// submitted for job, once the task is stored on the job. Recording a task again
+// is a no-op. It is best effort: a failure is logged and the pipeline continues.
+func recordSpend(ctx context.Context, deps *Dependencies, job *models.Job, kind, taskID string, credits int, logger *zap.Logger) {
+	if deps.SpendRepo == nil || credits <= 0 {
+		return
+	}
+
+	keySource := job.KIEKeySource
+	if keySource == "" {
+		keySource = models.KeySourceUser
+	}
+
+	jobID := job.ID
+	spend := &models.UserSpend{
+		UserID:         job.UserID,
+		JobID:          &jobID,
+		Kind:           kind,
+		KeySource:      keySource,
+		Credits:        credits,
+		ProviderTaskID: &taskID,
+	}
+	if err := deps.SpendRepo.Record(ctx, spend); err != nil {
+		logger.Warn("failed to record KIE spend",
+			zap.Error(err),
+			zap.String("kind", kind),
+			zap.String("task_id", taskID),
+			zap.Int("credits", credits),
+		)
+	}
+}
+
+// meteredChatClient records the tokens of each LLM call made for job, which count
+// against its user's monthly token budget. Complete and ChatWithModel go through
+// Chat, the only method returning the usage, including for responses cut off at
+// the output limit.
+type meteredChatClient struct {
+	openrouter.ChatClient
+	deps *Dependencies
+	job  *models.Job
+}
+
+func (c *meteredChatClient) Chat(ctx context.Context, req openrouter.ChatRequest) (*openrouter.ChatResponse, error) {
+	resp, err := c.ChatClient.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	c.record(ctx, req, resp)
+	return resp, nil
+}
+
+func (c *meteredChatClient) Complete(ctx context.Context, req openrouter.ChatRequest) (string, error) {
+	resp, err := c.Chat(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return openrouter.CompletionContent(req, resp)
+}
+
+func (c *meteredChatClient) ChatWithModel(ctx context.Context, model string, systemPrompt string, userPrompt string) (string, error) {
+	return c.Complete(ctx, openrouter.PromptRequest(model, systemPrompt, userPrompt))
+}
+
+func (c *meteredChatClient) record(ctx context.Context, req openrouter.ChatRequest, resp *openrouter.ChatResponse) {
+	if c.deps.SpendRepo == nil || resp.Usage.TotalTokens <= 0 {
+		return
+	}
+
+	keySource := c.job.OpenRouterKeySource
+	if keySource == "" {
+		keySource = models.KeySourceUser
+	}
+	model := resp.Model
+	if model == "" {
+		model = req.Model
+	}
+
+	jobID := c.job.ID
+	spend := &models.UserSpend{
+		UserID:    c.job.UserID,
+		JobID:     &jobID,
+		Kind:      models.SpendKindLLM,
+		KeySource: keySource,
+		Tokens:    resp.Usage.TotalTokens,
+	}
+	// The call was made and billed even if the task is being cancelled
+	if err := c.deps.SpendRepo.Record(context.WithoutCancel(ctx), spend); err != nil {
+		c.deps.Logger.Warn("failed to record OpenRouter token spend",
+			zap.Error(err),
+			zap.String("job_id", c.job.ID.String()),
+			zap.String("model", model),
+			zap.Int("tokens", resp.Usage.TotalTokens),
+		)
+	}
+}
@@ -0,0 +1,159 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/kie"
+	"github.com/jaochai/ugc/internal/external/openrouter"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// memorySpendRepo is a repository.UserSpendRepository keeping the recorded spend in memory.
+type memorySpendRepo struct {
+	mu     sync.Mutex
+	spends []models.UserSpend
+}
+
+func (r *memorySpendRepo) Record(ctx context.Context, spend *models.UserSpend) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spends = append(r.spends, *spend)
+	return nil
+}
+
+func (r *memorySpendRepo) SummarizeSince(ctx context.Context, userIDs []uuid.UUID, since time.Time) (map[uuid.UUID]*models.SpendSummary, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestNewOpenRouterClientMetersInjectedClient(t *testing.T) {
+	fake := testutil.NewFakeChatClient("one", "two", "three")
+	fake.Usage = openrouter.Usage{PromptTokens: 30, CompletionTokens: 12, TotalTokens: 42}
+
+	spendRepo := &memorySpendRepo{}
+	deps := &Dependencies{
+		Logger:        zap.NewNop(),
+		SpendRepo:     spendRepo,
+		NewChatClient: func(apiKey string) openrouter.ChatClient { return fake },
+	}
+	job := &models.Job{ID: uuid.New(), UserID: uuid.New(), OpenRouterKeySource: models.KeySourcePlatform}
+
+	client := newOpenRouterClient(deps, job, "sk-test")
+	ctx := context.Background()
+	req := openrouter.PromptRequest("test/model", "system", "user")
+
+	if _, err := client.Chat(ctx, req); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if content, err := client.Complete(ctx, req); err != nil || content != "two" {
+		t.Fatalf("Complete = %q, %v; want \"two\"", content, err)
+	}
+	if content, err := client.ChatWithModel(ctx, "test/model", "system", "user"); err != nil || content != "three" {
+		t.Fatalf("ChatWithModel = %q, %v; want \"three\"", content, err)
+	}
+
+	if len(spendRepo.spends) != 3 {
+		t.Fatalf("recorded %d spends, want one per call (3)", len(spendRepo.spends))
+	}
+	for _, spend := range spendRepo.spends {
+		if spend.UserID != job.UserID || spend.JobID == nil || *spend.JobID != job.ID {
+			t.Errorf("spend not attributed to the job's user: %+v", spend)
+		}
+		if spend.Kind != models.SpendKindLLM || spend.Tokens != 42 || spend.KeySource != models.KeySourcePlatform {
+			t.Errorf("spend = %+v, want %s with 42 tokens from the platform key", spend, models.SpendKindLLM)
+		}
+	}
+}
+
+func TestNewOpenRouterClientSkipsFailedCalls(t *testing.T) {
+	fake := testutil.NewFakeChatClient()
+	fake.Usage = openrouter.Usage{TotalTokens: 42}
+	fake.FailNext(errors.New("upstream unavailable"))
+
+	spendRepo := &memorySpendRepo{}
+	deps := &Dependencies{
+		Logger:        zap.NewNop(),
+		SpendRepo:     spendRepo,
+		NewChatClient: func(apiKey string) openrouter.ChatClient { return fake },
+	}
+	job := &models.Job{ID: uuid.New(), UserID: uuid.New()}
+
+	client := newOpenRouterClient(deps, job, "sk-test")
+	if _, err := client.ChatWithModel(context.Background(), "test/model", "system", "user"); err == nil {
+		t.Fatal("ChatWithModel succeeded, want the injected error")
+	}
+	if len(spendRepo.spends) != 0 {
+		t.Errorf("recorded %d spends for a failed call, want none", len(spendRepo.spends))
+	}
+}
+
+// TestGenerationSpendRecordedOnceTaskIsStored checks that the KIE credits of a
+// music or image task are recorded only once the task ID is stored on the job,
+// keyed by the task, so a failed store followed by a retry does not count a
+// generation twice.
+func TestGenerationSpendRecordedOnceTaskIsStored(t *testing.T) {
+	const imageConcept = `{"prompt": "neon city at night, cinematic", "aspect_ratio": "16:9", "resolution": "1K"}`
+
+	tests := []struct {
+		name      string
+		handler   func(*Dependencies) asynq.HandlerFunc
+		taskType  string
+		status    string
+		taskErr   error
+		wantTasks []string // Task IDs with recorded spend
+	}{
+		{name: "music stored", handler: HandleGenerateMusic, taskType: TypeGenerateMusic, status: models.StatusAnalyzing,
+			wantTasks: []string{"suno-task-1"}},
+		{name: "music cancelled before stored", handler: HandleGenerateMusic, taskType: TypeGenerateMusic, status: models.StatusAnalyzing,
+			taskErr: repository.ErrStatusConflict},
+		{name: "music store failed", handler: HandleGenerateMusic, taskType: TypeGenerateMusic, status: models.StatusAnalyzing,
+			taskErr: errors.New("connection reset")},
+		{name: "image candidates stored", handler: HandleGenerateImage, taskType: TypeGenerateImage, status: models.StatusSelectingSong,
+			wantTasks: []string{"nano-task-1", "nano-task-2"}},
+		{name: "image store failed", handler: HandleGenerateImage, taskType: TypeGenerateImage, status: models.StatusSelectingSong,
+			taskErr: errors.New("connection reset")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newHandlerFixture(t, models.Job{
+				Status:     tt.status,
+				Concept:    "เพลงรักในเมืองหลวง",
+				SongPrompt: &models.SongPrompt{Prompt: "[Verse]\nแสงไฟ", Style: "thai pop", Title: "แสงไฟ"},
+			}, testutil.NewFakeChatClient(imageConcept))
+			f.jobs.taskErr = tt.taskErr
+			spendRepo := &memorySpendRepo{}
+			f.deps.SpendRepo = spendRepo
+			f.deps.MusicCreditCost = 12
+			f.deps.ImageCreditCost = 4
+			f.deps.ImageCandidates = 2
+			f.deps.WebhookBaseURL = "https://ugc.example.com"
+			f.deps.WebhookSecret = "webhook-secret"
+			f.deps.NewMusicClient = func(apiKey string) kie.MusicClient { return &testutil.FakeMusicClient{} }
+			f.deps.NewImageClient = func(apiKey string) kie.ImageClient { return &testutil.FakeImageClient{} }
+
+			_ = f.run(tt.handler, tt.taskType)
+
+			var tasks []string
+			for _, spend := range spendRepo.spends {
+				if spend.ProviderTaskID == nil {
+					t.Fatalf("spend %+v has no task ID", spend)
+				}
+				tasks = append(tasks, *spend.ProviderTaskID)
+			}
+			if !slices.Equal(tasks, tt.wantTasks) {
+				t.Errorf("spend recorded for tasks %v, want %v", tasks, tt.wantTasks)
+			}
+		})
+	}
+}
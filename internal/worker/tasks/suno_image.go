@@ -0,0 +1,79 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// useSunoImage finishes the image stage with the selected track's cover art for a
+// job created with image_source "suno". The cover is checked against the media
+// hosts, copied into R2 and the job moves straight to processing_video.
+//
+// When the cover is missing or cannot be used, the job's image source is cleared
+// and handled is false: the caller generates the image as usual.
+func useSunoImage(ctx context.Context, deps *Dependencies, job *models.Job, payload *TaskPayload, logger *zap.Logger) (handled bool, err error) {
+	// A retry after the cover was stored only needs to move the job on
+	if job.ImageKey != nil && *job.ImageKey != "" {
+		return true, finishProvidedImage(ctx, deps, payload, logger)
+	}
+
+	key, reason := copySunoImage(ctx, deps, job)
+	if reason != "" {
+		logger.Warn("cannot use Suno cover art, generating image instead", zap.String("reason", reason))
+		if err := deps.JobRepo.ClearImageSourceAtomic(ctx, payload.JobID, models.StatusGeneratingImage); err != nil {
+			if errors.Is(err, repository.ErrStatusConflict) {
+				logger.Warn("job no longer generating image, skipping")
+				return true, nil
+			}
+			return true, handleUpdateError(ctx, deps, payload.JobID, err, "failed to clear image source", logger)
+		}
+		job.ImageSource = nil
+		return false, nil
+	}
+
+	imageURL, err := deps.R2Client.AssetURL(ctx, key)
+	if err != nil {
+		logger.Error("failed to resolve Suno cover URL", zap.Error(err))
+		return true, fmt.Errorf("failed to resolve Suno cover URL: %w", err)
+	}
+
+	err = deps.JobRepo.SetUserImageAtomic(ctx, payload.JobID, []string{models.StatusGeneratingImage}, key, imageURL, models.ImageSourceSuno)
+	if err != nil {
+		return true, handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job with Suno cover", logger)
+	}
+
+	logger.Info("using Suno cover art, skipping image generation", zap.String("image_key", key))
+	return true, finishProvidedImage(ctx, deps, payload, logger)
+}
+
+// copySunoImage copies the selected track's cover art into R2 and returns its key,
+// or the reason it cannot be used.
+func copySunoImage(ctx context.Context, deps *Dependencies, job *models.Job) (key string, reason string) {
+	song := job.SelectedSong()
+	if song == nil {
+		return "", "no song selected"
+	}
+	if song.ImageURL == "" {
+		return "", "selected track has no cover art"
+	}
+	if deps.R2Client == nil {
+		return "", "storage is not configured"
+	}
+	if deps.AudioURLValidator != nil {
+		if err := deps.AudioURLValidator.ValidateURL(song.ImageURL); err != nil {
+			return "", fmt.Sprintf("cover art URL rejected: %v", err)
+		}
+	}
+
+	key, err := copyUserImage(ctx, deps, job.ID.String(), song.ImageURL)
+	if err != nil {
+		return "", fmt.Sprintf("failed to copy cover art: %v", err)
+	}
+	return key, ""
+}
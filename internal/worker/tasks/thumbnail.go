@@ -0,0 +1,50 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+)
+
+// storeThumbnail extracts a thumbnail from the rendered video, uploads it to R2 and
+// records its key on the job. The thumbnail is only a preview, so failures are
+// logged and never fail the job.
+func storeThumbnail(ctx context.Context, deps *Dependencies, jobID uuid.UUID, videoPath string, logger *zap.Logger) {
+	if deps.FFmpegProcessor == nil || deps.R2Client == nil {
+		return
+	}
+
+	thumbnailPath := filepath.Join(filepath.Dir(videoPath), fmt.Sprintf("%s.%s", jobID.String(), r2.JobAssetExtension(r2.AssetThumbnail)))
+	if err := deps.FFmpegProcessor.ExtractThumbnail(ctx, videoPath, thumbnailPath); err != nil {
+		logger.Warn("failed to extract thumbnail", zap.Error(err))
+		return
+	}
+	defer os.Remove(thumbnailPath)
+
+	thumbnailFile, err := os.Open(thumbnailPath)
+	if err != nil {
+		logger.Warn("failed to open thumbnail", zap.Error(err))
+		return
+	}
+	defer thumbnailFile.Close()
+
+	// Key format: thumbnails/{job_id}.jpg
+	key, _ := r2.JobAssetKey(r2.AssetThumbnail, jobID.String())
+	if err := deps.R2Client.Upload(ctx, key, thumbnailFile, r2.JobAssetContentType(r2.AssetThumbnail)); err != nil {
+		logger.Warn("failed to upload thumbnail to R2", zap.Error(err))
+		return
+	}
+
+	if err := deps.JobRepo.UpdateThumbnailKey(ctx, jobID, key); err != nil {
+		logger.Warn("failed to store thumbnail key", zap.Error(err))
+		return
+	}
+
+	logger.Info("thumbnail uploaded to R2", zap.String("key", key))
+}
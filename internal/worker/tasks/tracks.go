@@ -0,0 +1,82 @@
+package tasks
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// storeTracks copies every playable Suno track of a job created with
+// keep_all_tracks to R2 and records them on the job, marking the one the video was
+// made with as primary. A track that fails to copy is recorded without a key;
+// the tracks are extras, so failures never fail the job.
+func storeTracks(ctx context.Context, deps *Dependencies, job *models.Job, logger *zap.Logger) {
+	if !job.KeepAllTracks || deps.R2Client == nil {
+		return
+	}
+
+	var reasoning string
+	if output, ok := job.AgentOutputs[models.PromptTypeSongSelector]; ok {
+		reasoning = output.Reasoning
+	}
+
+	tracks := make([]models.JobTrack, 0, len(job.GeneratedSongs))
+	for _, song := range job.GeneratedSongs {
+		if !song.IsPlayable() || !isSafeTrackID(song.ID) {
+			continue
+		}
+		track := models.JobTrack{
+			ID:       song.ID,
+			Title:    song.Title,
+			Duration: song.Duration,
+			Primary:  job.SelectedSongID != nil && *job.SelectedSongID == song.ID,
+		}
+		if track.Primary {
+			track.Reasoning = reasoning
+		}
+
+		key := r2.TrackKey(job.ID.String(), song.ID)
+		if err := copyTrack(ctx, deps, key, song.AudioURL); err != nil {
+			logger.Warn("failed to copy track to R2", zap.String("track_id", song.ID), zap.Error(err))
+		} else {
+			track.Key = key
+		}
+		tracks = append(tracks, track)
+	}
+	if len(tracks) == 0 {
+		return
+	}
+
+	if err := deps.JobRepo.UpdateTracks(ctx, job.ID, tracks); err != nil {
+		logger.Warn("failed to store tracks", zap.Error(err))
+		return
+	}
+
+	logger.Info("tracks copied to R2", zap.Int("tracks", len(tracks)))
+}
+
+// copyTrack uploads the audio at audioURL, which must be on an allowed media host, to key.
+func copyTrack(ctx context.Context, deps *Dependencies, key, audioURL string) error {
+	if deps.AudioURLValidator != nil {
+		if err := deps.AudioURLValidator.ValidateURL(audioURL); err != nil {
+			return err
+		}
+	}
+	return deps.R2Client.UploadFromURL(ctx, key, audioURL)
+}
+
+// isSafeTrackID reports whether a Suno track ID can be used in an object key.
+func isSafeTrackID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -2,9 +2,12 @@
 package tasks
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 // Task type constants for asynq.
@@ -12,15 +15,71 @@ const (
 	TypeAnalyzeConcept = "job:analyze_concept"
 	TypeGenerateMusic  = "job:generate_music"
 	TypeSelectSong     = "job:select_song"
+	TypeFinalizeSongs  = "job:finalize_songs"
 	TypeGenerateImage  = "job:generate_image"
-	TypeProcessVideo    = "job:process_video"
-	TypeUploadAssets    = "job:upload_assets"
-	TypeUploadYouTube   = "job:upload_youtube"
+	TypeSelectImage    = "job:select_image"
+	TypeProcessVideo   = "job:process_video"
+	TypeUploadAssets   = "job:upload_assets"
+	TypeUploadYouTube  = "job:upload_youtube"
+	TypeDeleteUserData = "user:delete_data"
+
+	TypeReencryptSecrets = "maintenance:reencrypt_secrets"
+
+	TypeReprocessWebhook = "webhook:reprocess"
+
+	TypePollMusicStatus = "job:poll_music_status"
+	TypePollImageStatus = "job:poll_image_status"
+
+	TypeNotifyUser = "user:notify_webhook"
+	TypeSendEmail  = "user:send_email"
 )
 
+// dedupTaskIDPrefixes lists the task types enqueued with a per-job TaskID. Every
+// pipeline step has one, so the job reconciler can tell whether a job's next task
+// is still queued.
+var dedupTaskIDPrefixes = map[string]string{
+	TypeAnalyzeConcept: "analyze-concept",
+	TypeGenerateMusic:  "generate-music",
+	TypeSelectSong:     "select-song",
+	TypeFinalizeSongs:  "finalize-songs",
+	TypeGenerateImage:  "generate-image",
+	TypeSelectImage:    "select-image",
+	TypeProcessVideo:   "process-video",
+	TypeUploadAssets:   "upload",
+	TypeUploadYouTube:  "upload-youtube",
+}
+
+// pollTaskIDPrefixes lists the poll task types; their TaskIDs are per KIE task and attempt.
+var pollTaskIDPrefixes = map[string]string{
+	TypePollMusicStatus: "poll-music",
+	TypePollImageStatus: "poll-image",
+}
+
+// DedupTaskID returns the asynq TaskID used to deduplicate taskType for a job,
+// or "" if the type is not deduplicated.
+func DedupTaskID(taskType string, jobID uuid.UUID) string {
+	prefix, ok := dedupTaskIDPrefixes[taskType]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s-%s", prefix, jobID.String())
+}
+
 // TaskPayload represents the common payload for all job-related tasks.
+// TraceID carries the request ID of the HTTP request or webhook callback that
+// enqueued the task, and is passed on to every follow-up task.
 type TaskPayload struct {
-	JobID uuid.UUID `json:"job_id"`
+	JobID   uuid.UUID `json:"job_id"`
+	TraceID string    `json:"trace_id,omitempty"`
+}
+
+// LogFields returns the logger fields identifying this task's job and trace.
+func (p *TaskPayload) LogFields() []zap.Field {
+	fields := []zap.Field{zap.String("job_id", p.JobID.String())}
+	if p.TraceID != "" {
+		fields = append(fields, zap.String("trace_id", p.TraceID))
+	}
+	return fields
 }
 
 // Marshal serializes the payload to JSON bytes.
@@ -36,3 +95,142 @@ func UnmarshalTaskPayload(data []byte) (*TaskPayload, error) {
 	}
 	return &payload, nil
 }
+
+// UserTaskPayload represents the payload for account-level tasks.
+type UserTaskPayload struct {
+	UserID  uuid.UUID `json:"user_id"`
+	TraceID string    `json:"trace_id,omitempty"`
+}
+
+// Marshal serializes the payload to JSON bytes.
+func (p *UserTaskPayload) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// UnmarshalUserTaskPayload deserializes JSON bytes into a UserTaskPayload.
+func UnmarshalUserTaskPayload(data []byte) (*UserTaskPayload, error) {
+	var payload UserTaskPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// MaintenanceTaskPayload represents the payload for admin-triggered maintenance tasks.
+type MaintenanceTaskPayload struct {
+	RequestedBy uuid.UUID `json:"requested_by"`
+	TraceID     string    `json:"trace_id,omitempty"`
+}
+
+// Marshal serializes the payload to JSON bytes.
+func (p *MaintenanceTaskPayload) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// UnmarshalMaintenanceTaskPayload deserializes JSON bytes into a MaintenanceTaskPayload.
+func UnmarshalMaintenanceTaskPayload(data []byte) (*MaintenanceTaskPayload, error) {
+	var payload MaintenanceTaskPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// WebhookTaskPayload represents the payload for re-applying a stored webhook callback.
+type WebhookTaskPayload struct {
+	EventID uuid.UUID `json:"event_id"`
+	TraceID string    `json:"trace_id,omitempty"`
+}
+
+// Marshal serializes the payload to JSON bytes.
+func (p *WebhookTaskPayload) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// UnmarshalWebhookTaskPayload deserializes JSON bytes into a WebhookTaskPayload.
+func UnmarshalWebhookTaskPayload(data []byte) (*WebhookTaskPayload, error) {
+	var payload WebhookTaskPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+type traceIDKey struct{}
+
+// ContextWithTraceID returns a copy of ctx carrying the task's trace ID.
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext returns the trace ID stored in ctx, or "" if none.
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
+// PollTaskPayload represents the payload for polling a provider task whose
+// webhook callback may never arrive. Attempt counts polls already made.
+type PollTaskPayload struct {
+	JobID   uuid.UUID `json:"job_id"`
+	TraceID string    `json:"trace_id,omitempty"`
+	TaskID  string    `json:"task_id"`
+	Attempt int       `json:"attempt"`
+}
+
+// Marshal serializes the payload to JSON bytes.
+func (p *PollTaskPayload) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// UnmarshalPollTaskPayload deserializes JSON bytes into a PollTaskPayload.
+func UnmarshalPollTaskPayload(data []byte) (*PollTaskPayload, error) {
+	var payload PollTaskPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// NotifyUserPayload represents the payload for delivering one job event to one user webhook.
+type NotifyUserPayload struct {
+	JobID     uuid.UUID `json:"job_id"`
+	WebhookID uuid.UUID `json:"webhook_id"`
+	Event     string    `json:"event"`
+	TraceID   string    `json:"trace_id,omitempty"`
+}
+
+// Marshal serializes the payload to JSON bytes.
+func (p *NotifyUserPayload) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// UnmarshalNotifyUserPayload deserializes JSON bytes into a NotifyUserPayload.
+func UnmarshalNotifyUserPayload(data []byte) (*NotifyUserPayload, error) {
+	var payload NotifyUserPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// SendEmailPayload represents the payload for emailing a job's owner about a job event.
+type SendEmailPayload struct {
+	JobID   uuid.UUID `json:"job_id"`
+	Event   string    `json:"event"`
+	TraceID string    `json:"trace_id,omitempty"`
+}
+
+// Marshal serializes the payload to JSON bytes.
+func (p *SendEmailPayload) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// UnmarshalSendEmailPayload deserializes JSON bytes into a SendEmailPayload.
+func UnmarshalSendEmailPayload(data []byte) (*SendEmailPayload, error) {
+	var payload SendEmailPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
@@ -0,0 +1,124 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+)
+
+// UserContext is what a task needs to know about a job's owner: the user, whose
+// preferences pick the models, and the API keys the job's provider calls run on.
+type UserContext struct {
+	User *models.User
+	// OpenRouterKey and KIEKey are decrypted, from the key sources recorded on the
+	// job. Empty when that source has no key.
+	OpenRouterKey string
+	KIEKey        string
+}
+
+// AgentModel returns the LLM model the promptType agent uses for job.
+func (u *UserContext) AgentModel(job *models.Job, promptType string) string {
+	return resolveAgentModel(u.User, job, promptType)
+}
+
+// SunoModel returns the Suno model job's music is generated with.
+func (u *UserContext) SunoModel(job *models.Job) string {
+	return resolveSunoModel(u.User, job)
+}
+
+// userContextCache holds the user contexts loaded during one task invocation, by job ID.
+type userContextCache struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*UserContext
+}
+
+type userContextCacheKey struct{}
+
+// ContextWithUserContextCache returns a context in which LoadUserContext loads each
+// user once. The worker installs one per task invocation, so a task's helpers
+// share the lookup without holding on to keys between tasks.
+func ContextWithUserContextCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, userContextCacheKey{}, &userContextCache{jobs: make(map[uuid.UUID]*UserContext)})
+}
+
+// LoadUserContext loads the user of job and the decrypted API keys job runs on,
+// from the context's cache when an earlier step of the task loaded them already.
+func LoadUserContext(ctx context.Context, deps *Dependencies, job *models.Job) (*UserContext, error) {
+	cache, _ := ctx.Value(userContextCacheKey{}).(*userContextCache)
+	if cache != nil {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		if uc, ok := cache.jobs[job.ID]; ok {
+			return uc, nil
+		}
+	}
+
+	user, err := deps.UserRepo.GetByID(ctx, job.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	uc := &UserContext{User: user}
+	uc.OpenRouterKey, uc.KIEKey, err = decryptAPIKeys(ctx, deps, user, job)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache != nil {
+		cache.jobs[job.ID] = uc
+	}
+	return uc, nil
+}
+
+// decryptAPIKeys decrypts the keys job runs on, taken from the source recorded on
+// the job when it was created: the user's own key, their organization's or the
+// platform's. A key missing from that source is left empty, failing the stages
+// that need it, rather than replaced by another source's key: only jobs counted
+// against the platform quota may use the platform keys.
+func decryptAPIKeys(ctx context.Context, deps *Dependencies, user *models.User, job *models.Job) (openRouterKey, kieKey string, err error) {
+	var orgOpenRouterKey, orgKIEKey *string
+	if deps.OrganizationRepo != nil && (job.OpenRouterKeySource == models.KeySourceOrganization || job.KIEKeySource == models.KeySourceOrganization) {
+		orgOpenRouterKey, orgKIEKey, err = deps.OrganizationRepo.GetAPIKeysForUser(ctx, user.ID)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get organization API keys: %w", err)
+		}
+	}
+
+	openRouterKey, err = resolveAPIKey(deps, job.OpenRouterKeySource, user.OpenRouterAPIKey, orgOpenRouterKey, deps.PlatformOpenRouterKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decrypt OpenRouter API key: %w", err)
+	}
+	kieKey, err = resolveAPIKey(deps, job.KIEKeySource, user.KIEAPIKey, orgKIEKey, deps.PlatformKIEKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decrypt KIE API key: %w", err)
+	}
+	return openRouterKey, kieKey, nil
+}
+
+// resolveAPIKey returns the key of source: the decrypted userKey or orgKey, or
+// platformKey. It is empty when the source has no key. An unset source is the
+// column default, the user's own key.
+func resolveAPIKey(deps *Dependencies, source string, userKey, orgKey *string, platformKey string) (string, error) {
+	var encrypted *string
+	switch source {
+	case models.KeySourceUser, "":
+		encrypted = userKey
+	case models.KeySourceOrganization:
+		encrypted = orgKey
+	case models.KeySourcePlatform:
+		return platformKey, nil
+	}
+	if isEmptyKey(encrypted) {
+		return "", nil
+	}
+	return deps.CryptoService.Decrypt(*encrypted)
+}
+
+// isEmptyKey reports whether an encrypted key is unset.
+func isEmptyKey(encrypted *string) bool {
+	return encrypted == nil || *encrypted == ""
+}
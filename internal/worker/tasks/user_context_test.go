@@ -0,0 +1,89 @@
+package tasks
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+// prefixCrypto "encrypts" by prefixing "enc:".
+type prefixCrypto struct{}
+
+func (prefixCrypto) Encrypt(plaintext string) (string, error) { return "enc:" + plaintext, nil }
+func (prefixCrypto) Decrypt(ciphertext string) (string, error) {
+	return strings.TrimPrefix(ciphertext, "enc:"), nil
+}
+func (prefixCrypto) NeedsReencrypt(ciphertext string) bool { return false }
+
+func TestDecryptAPIKeysUsesJobKeySource(t *testing.T) {
+	ctx := context.Background()
+	encrypted := func(key string) *string {
+		value := "enc:" + key
+		return &value
+	}
+
+	withKeys := &models.User{ID: uuid.New(), Email: "keys@example.com",
+		OpenRouterAPIKey: encrypted("user-or"), KIEAPIKey: encrypted("user-kie")}
+	withoutKeys := &models.User{ID: uuid.New(), Email: "nokeys@example.com"}
+	orgMember := &models.User{ID: uuid.New(), Email: "member@example.com"}
+
+	orgs := testutil.NewFakeOrganizationRepository(orgMember)
+	org := &models.Organization{Name: "Studio"}
+	if err := orgs.Create(ctx, org, orgMember.ID); err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+	if err := orgs.UpdateAPIKeys(ctx, org.ID, encrypted("org-or"), nil); err != nil {
+		t.Fatalf("failed to set organization keys: %v", err)
+	}
+	orgMember.OrgID = &org.ID
+
+	deps := &Dependencies{
+		CryptoService:         prefixCrypto{},
+		OrganizationRepo:      orgs,
+		PlatformOpenRouterKey: "platform-or",
+		PlatformKIEKey:        "platform-kie",
+	}
+
+	tests := []struct {
+		name             string
+		user             *models.User
+		openRouterSource string
+		kieSource        string
+		wantOpenRouter   string
+		wantKIE          string
+	}{
+		{name: "own keys", user: withKeys, openRouterSource: models.KeySourceUser, kieSource: models.KeySourceUser,
+			wantOpenRouter: "user-or", wantKIE: "user-kie"},
+		{name: "platform keys", user: withoutKeys, openRouterSource: models.KeySourcePlatform, kieSource: models.KeySourcePlatform,
+			wantOpenRouter: "platform-or", wantKIE: "platform-kie"},
+		{name: "platform source ignores own keys", user: withKeys, openRouterSource: models.KeySourcePlatform, kieSource: models.KeySourceUser,
+			wantOpenRouter: "platform-or", wantKIE: "user-kie"},
+		// Keys removed after the job was created do not fall back to the platform
+		{name: "own keys removed", user: withoutKeys, openRouterSource: models.KeySourceUser, kieSource: models.KeySourceUser,
+			wantOpenRouter: "", wantKIE: ""},
+		{name: "organization key", user: orgMember, openRouterSource: models.KeySourceOrganization, kieSource: models.KeySourcePlatform,
+			wantOpenRouter: "org-or", wantKIE: "platform-kie"},
+		{name: "organization key removed", user: orgMember, openRouterSource: models.KeySourceOrganization, kieSource: models.KeySourceOrganization,
+			wantOpenRouter: "org-or", wantKIE: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &models.Job{ID: uuid.New(), UserID: tt.user.ID,
+				OpenRouterKeySource: tt.openRouterSource, KIEKeySource: tt.kieSource}
+
+			openRouterKey, kieKey, err := decryptAPIKeys(ctx, deps, tt.user, job)
+			if err != nil {
+				t.Fatalf("decryptAPIKeys() error = %v", err)
+			}
+			if openRouterKey != tt.wantOpenRouter || kieKey != tt.wantKIE {
+				t.Errorf("keys = %q, %q; want %q, %q", openRouterKey, kieKey, tt.wantOpenRouter, tt.wantKIE)
+			}
+		})
+	}
+}
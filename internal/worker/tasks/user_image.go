@@ -0,0 +1,140 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/external/r2"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/security"
+)
+
+// userImageDownloadTimeout bounds copying an image_url given at job creation into R2.
+const userImageDownloadTimeout = time.Minute
+
+// userImageClient checks every address it connects to, since DNS may answer
+// differently than when the URL was validated, and does not follow redirects.
+var userImageClient = security.NewPublicHTTPClient(userImageDownloadTimeout)
+
+// useUserImage finishes the image stage for a job whose image the user supplied:
+// an image_url given at creation is copied into R2 first, then the job moves
+// straight to processing_video without any LLM or NanoBanana work.
+func useUserImage(ctx context.Context, deps *Dependencies, job *models.Job, payload *TaskPayload, logger *zap.Logger) error {
+	if job.ImageKey == nil || *job.ImageKey == "" {
+		if job.SourceImageURL == nil || *job.SourceImageURL == "" {
+			logger.Error("job has a user image source but no image")
+			return markJobFailed(ctx, deps, payload.JobID, "job has no uploaded image")
+		}
+		if deps.R2Client == nil {
+			logger.Error("R2 storage not configured, cannot copy user image")
+			return markJobFailed(ctx, deps, payload.JobID, "storage is not configured")
+		}
+
+		key, err := copyUserImage(ctx, deps, job.ID.String(), *job.SourceImageURL)
+		if err != nil {
+			logger.Warn("failed to copy user image", zap.Error(err))
+			return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to copy image_url: %v", err))
+		}
+
+		imageURL, err := deps.R2Client.AssetURL(ctx, key)
+		if err != nil {
+			logger.Error("failed to resolve user image URL", zap.Error(err))
+			return fmt.Errorf("failed to resolve user image URL: %w", err)
+		}
+
+		err = deps.JobRepo.SetUserImageAtomic(ctx, payload.JobID, []string{models.StatusGeneratingImage}, key, imageURL, models.ImageSourceUser)
+		if err != nil {
+			return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job with user image", logger)
+		}
+		logger.Info("user image copied to R2", zap.String("image_key", key))
+	}
+
+	logger.Info("using user image, skipping image generation")
+	return finishProvidedImage(ctx, deps, payload, logger)
+}
+
+// finishProvidedImage completes the image stage of a job whose image is already in
+// R2 and enqueues process_video.
+func finishProvidedImage(ctx context.Context, deps *Dependencies, payload *TaskPayload, logger *zap.Logger) error {
+	err := deps.JobRepo.TransitionStatusAtomic(ctx, payload.JobID, models.StatusGeneratingImage, models.StatusProcessingVideo)
+	if err != nil {
+		if errors.Is(err, repository.ErrStatusConflict) {
+			logger.Warn("job no longer generating image, skipping")
+			return nil
+		}
+		return handleUpdateError(ctx, deps, payload.JobID, err, "failed to update job status", logger)
+	}
+
+	recordStageTime(ctx, deps, payload.JobID, models.StageImage, models.StageEventCompleted, logger)
+
+	// Skip the next stage if the job was cancelled meanwhile
+	if isJobStopped(ctx, deps, payload.JobID, logger) {
+		return nil
+	}
+
+	// Enqueue next task: process video
+	nextPayload, _ := (&TaskPayload{JobID: payload.JobID, TraceID: payload.TraceID}).Marshal()
+	nextTask := asynq.NewTask(TypeProcessVideo, nextPayload, asynq.TaskID(DedupTaskID(TypeProcessVideo, payload.JobID)))
+	if _, err := deps.AsynqClient.Enqueue(nextTask); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			logger.Warn("process video task already enqueued")
+			return nil
+		}
+		logger.Error("failed to enqueue process video task", zap.Error(err))
+		return markJobFailed(ctx, deps, payload.JobID, fmt.Sprintf("failed to enqueue next task: %v", err))
+	}
+
+	logger.Info("enqueued process video task")
+	return nil
+}
+
+// copyUserImage downloads a user's image URL (public HTTPS, PNG/JPEG/WebP, max
+// 10MB) and stores it in R2, returning the object key.
+func copyUserImage(ctx context.Context, deps *Dependencies, jobID, sourceURL string) (string, error) {
+	// DNS may have changed since the job was created
+	if err := security.ValidatePublicURL(sourceURL); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid image URL: %w", err)
+	}
+	resp, err := userImageClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, r2.MaxUserImageSize+1))
+	if err != nil {
+		return "", fmt.Errorf("download failed: %w", err)
+	}
+	if len(data) > r2.MaxUserImageSize {
+		return "", errors.New("image must be 10MB or less")
+	}
+
+	extension, contentType, err := r2.SniffUserImage(data)
+	if err != nil {
+		return "", err
+	}
+
+	key := r2.UserImageKey(jobID, extension)
+	if err := deps.R2Client.Upload(ctx, key, bytes.NewReader(data), contentType); err != nil {
+		return "", fmt.Errorf("failed to store image: %w", err)
+	}
+	return key, nil
+}
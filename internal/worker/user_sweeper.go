@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// Deleted user sweep settings.
+const (
+	// deletedUserSweepGrace leaves recently deleted accounts to the task enqueued
+	// by the deletion itself.
+	deletedUserSweepGrace = time.Hour
+	// deletedUserSweepBatchSize is how many deleted accounts are re-enqueued per pass.
+	deletedUserSweepBatchSize = 100
+)
+
+// DeletedUserSweeper re-enqueues the data cleanup of accounts that are still
+// soft-deleted an hour after their deletion, e.g. because the task was lost to
+// a Redis flush or gave up. The cleanup removes the user row when it finishes.
+type DeletedUserSweeper struct {
+	userRepo repository.UserRepository
+	outbox   *Outbox
+	logger   *zap.Logger
+}
+
+// NewDeletedUserSweeper creates a new DeletedUserSweeper instance.
+func NewDeletedUserSweeper(userRepo repository.UserRepository, outbox *Outbox, logger *zap.Logger) *DeletedUserSweeper {
+	return &DeletedUserSweeper{
+		userRepo: userRepo,
+		outbox:   outbox,
+		logger:   logger.Named("deleted_user_sweeper"),
+	}
+}
+
+// Run sweeps once at startup and then every interval until ctx is done.
+func (s *DeletedUserSweeper) Run(ctx context.Context, interval time.Duration) {
+	s.Sweep(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sweep(ctx)
+		}
+	}
+}
+
+// Sweep performs a single pass. A cleanup still queued is a TaskID conflict,
+// which leaves it in place.
+func (s *DeletedUserSweeper) Sweep(ctx context.Context) {
+	ids, err := s.userRepo.ListDeletedBefore(ctx, time.Now().Add(-deletedUserSweepGrace), deletedUserSweepBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error("failed to list deleted users", zap.Error(err))
+		}
+		return
+	}
+
+	enqueued := 0
+	for _, id := range ids {
+		task, err := NewDeleteUserDataTask(id, "")
+		if err == nil {
+			err = s.outbox.EnqueueForUser(ctx, task, id, DeleteUserDataTaskOptions(id)...)
+		}
+		if err != nil {
+			if !isDuplicateTaskError(err) && ctx.Err() == nil {
+				s.logger.Error("failed to enqueue delete user data task", zap.String("user_id", id.String()), zap.Error(err))
+			}
+			continue
+		}
+		enqueued++
+	}
+
+	if enqueued > 0 {
+		s.logger.Info("re-enqueued data cleanup of deleted users", zap.Int("count", enqueued))
+	}
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/testutil"
+)
+
+func TestDeletedUserSweeperUsesTheOutbox(t *testing.T) {
+	ctx := context.Background()
+	longAgo := time.Now().Add(-2 * deletedUserSweepGrace)
+	justNow := time.Now()
+	stale := &models.User{ID: uuid.New(), DeletedAt: &longAgo}
+	recent := &models.User{ID: uuid.New(), DeletedAt: &justNow}
+	active := &models.User{ID: uuid.New()}
+
+	client := &stubEnqueuer{err: errors.New("redis: connection refused")}
+	repo := testutil.NewFakePendingTaskRepository()
+	sweeper := NewDeletedUserSweeper(testutil.NewFakeUserRepository(stale, recent, active), newTestOutbox(client, repo), zap.NewNop())
+
+	// Redis is down: the cleanup of the stale account waits in the outbox
+	sweeper.Sweep(ctx)
+	stored := repo.Tasks()
+	if len(stored) != 1 {
+		t.Fatalf("outbox holds %d tasks, want the stale account's cleanup only", len(stored))
+	}
+	p := stored[0]
+	if p.UserID == nil || *p.UserID != stale.ID || p.JobID != nil {
+		t.Fatalf("stored task of job %v, user %v; want user %s and no job", p.JobID, p.UserID, stale.ID)
+	}
+	if p.Queue == nil || *p.Queue != "low" {
+		t.Errorf("stored queue = %v, want low", p.Queue)
+	}
+
+	client.setErr(nil)
+	sweeper.outbox.drain(ctx)
+	if stored := repo.Tasks(); len(stored) != 0 {
+		t.Fatalf("outbox holds %d tasks after a successful retry, want 0", len(stored))
+	}
+	if got := client.lastOptions(t)[asynq.TaskIDOpt]; got != "delete-user-data-"+stale.ID.String() {
+		t.Errorf("retried with TaskID %v, want the per-user one", got)
+	}
+
+	// A cleanup still queued is left alone
+	client.setErr(asynq.ErrTaskIDConflict)
+	sweeper.Sweep(ctx)
+	if stored := repo.Tasks(); len(stored) != 0 {
+		t.Fatalf("outbox holds %d tasks after a duplicate, want 0", len(stored))
+	}
+}
+
+func TestOutboxGivesUpOnUserTasksWithoutFailingAJob(t *testing.T) {
+	ctx := context.Background()
+	client := &stubEnqueuer{err: errors.New("redis: connection refused")}
+	repo := testutil.NewFakePendingTaskRepository()
+	// No job repository: touching it would panic
+	outbox := newTestOutbox(client, repo)
+
+	userID := uuid.New()
+	task, _ := NewDeleteUserDataTask(userID, "")
+	if err := outbox.EnqueueForUser(ctx, task, userID, DeleteUserDataTaskOptions(userID)...); err != nil {
+		t.Fatalf("EnqueueForUser() error = %v", err)
+	}
+	for range outboxMaxAttempts {
+		repo.MakeDue()
+		outbox.drain(ctx)
+	}
+	if stored := repo.Tasks(); len(stored) != 0 {
+		t.Fatalf("outbox holds %d tasks after %d attempts, want the task abandoned", len(stored), outboxMaxAttempts)
+	}
+}
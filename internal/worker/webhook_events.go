@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaochai/ugc/internal/repository"
+)
+
+// WebhookEventCleaner deletes captured webhook callbacks older than the retention period.
+// It runs regardless of whether capture is enabled so rows captured before the flag
+// was turned off are still removed.
+type WebhookEventCleaner struct {
+	eventRepo repository.WebhookEventRepository
+	retention time.Duration
+	logger    *zap.Logger
+}
+
+// NewWebhookEventCleaner creates a new WebhookEventCleaner instance.
+func NewWebhookEventCleaner(eventRepo repository.WebhookEventRepository, retention time.Duration, logger *zap.Logger) *WebhookEventCleaner {
+	return &WebhookEventCleaner{
+		eventRepo: eventRepo,
+		retention: retention,
+		logger:    logger.Named("webhook_event_cleaner"),
+	}
+}
+
+// Run cleans up once at startup and then every interval until ctx is done.
+func (c *WebhookEventCleaner) Run(ctx context.Context, interval time.Duration) {
+	c.Cleanup(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Cleanup(ctx)
+		}
+	}
+}
+
+// Cleanup performs a single cleanup pass.
+func (c *WebhookEventCleaner) Cleanup(ctx context.Context) {
+	deleted, err := c.eventRepo.DeleteOlderThan(ctx, time.Now().Add(-c.retention))
+	if err != nil {
+		if ctx.Err() == nil {
+			c.logger.Error("failed to delete old webhook events", zap.Error(err))
+		}
+		return
+	}
+
+	if deleted > 0 {
+		c.logger.Info("deleted old webhook events", zap.Int64("count", deleted))
+	}
+}
@@ -3,75 +3,135 @@ package worker
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
-	"github.com/jaochai/ugc/internal/external/r2"
-	"github.com/jaochai/ugc/internal/external/youtube"
-	"github.com/jaochai/ugc/internal/ffmpeg"
-	"github.com/jaochai/ugc/internal/repository"
-	"github.com/jaochai/ugc/internal/service"
+	"github.com/jaochai/ugc/internal/metrics"
 	"github.com/jaochai/ugc/internal/worker/tasks"
 )
 
-// Re-export task type constants for convenience.
-const (
-	TypeAnalyzeConcept  = tasks.TypeAnalyzeConcept
-	TypeGenerateMusic   = tasks.TypeGenerateMusic
-	TypeSelectSong      = tasks.TypeSelectSong
-	TypeGenerateImage   = tasks.TypeGenerateImage
-	TypeProcessVideo    = tasks.TypeProcessVideo
-	TypeUploadAssets    = tasks.TypeUploadAssets
-	TypeUploadYouTube   = tasks.TypeUploadYouTube
-)
+// stageTaskTypes maps the main task of each pipeline stage to its stage name.
+// Selection and YouTube tasks are not counted as separate stages.
+var stageTaskTypes = map[string]string{
+	tasks.TypeAnalyzeConcept: metrics.StageAnalyze,
+	tasks.TypeGenerateMusic:  metrics.StageMusic,
+	tasks.TypeGenerateImage:  metrics.StageImage,
+	tasks.TypeProcessVideo:   metrics.StageVideo,
+	tasks.TypeUploadAssets:   metrics.StageUpload,
+}
 
-// TaskPayload is a generic payload for all task types.
-type TaskPayload struct {
-	JobID uuid.UUID `json:"job_id"`
+// metricsMiddleware records task starts, outcomes and per-stage durations.
+func metricsMiddleware(m *metrics.Metrics) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+			start := time.Now()
+			m.TaskStarted(task.Type())
+
+			err := next.ProcessTask(ctx, task)
+
+			m.TaskFinished(task.Type(), err)
+			if stage, ok := stageTaskTypes[task.Type()]; ok {
+				m.ObserveStage(stage, time.Since(start))
+			}
+			return err
+		})
+	}
 }
 
-// Dependencies holds all dependencies needed by task handlers.
-type Dependencies struct {
-	JobRepo          repository.JobRepository
-	UserRepo         repository.UserRepository
-	SystemPromptRepo repository.SystemPromptRepository
-	CryptoService    service.CryptoService
-	R2Client         *r2.Client
-	FFmpegProcessor  *ffmpeg.Processor
-	YouTubeClient    *youtube.Client
-	AsynqClient      *asynq.Client
-	Logger           *zap.Logger
-	WebhookBaseURL   string // Base URL for webhooks, empty to use polling
-	WebhookSecret    string // Secret token for webhook authentication
-	KIEBaseURL       string // Base URL for KIE API
+// traceMiddleware stores the payload's trace ID in the task context so that
+// helpers without access to the payload (e.g. markJobFailed) can report it.
+func traceMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		if payload, err := tasks.UnmarshalTaskPayload(task.Payload()); err == nil && payload.TraceID != "" {
+			ctx = tasks.ContextWithTraceID(ctx, payload.TraceID)
+		}
+		return next.ProcessTask(ctx, task)
+	})
 }
 
+// userContextMiddleware gives each task invocation its own user context cache, so
+// the job owner and their keys are loaded once per task rather than per helper.
+func userContextMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		return next.ProcessTask(tasks.ContextWithUserContextCache(ctx), task)
+	})
+}
+
+// shutdownTimeout is how long Shutdown waits for handlers to return after their
+// context is cancelled. Handlers only need it to clean up; tasks still running
+// are restored to their queue by asynq. asynq itself never cancels handler
+// contexts, so Shutdown does it through the server's base context.
+const shutdownTimeout = 15 * time.Second
+
+// Worker health settings.
+const (
+	// startupPingTimeout bounds the Redis check in Start.
+	startupPingTimeout = 10 * time.Second
+	// maxFailedHealthChecks is how many consecutive failed health checks, made by
+	// asynq every 15 seconds, the worker tolerates before reporting itself failed.
+	maxFailedHealthChecks = 4
+)
+
 // Worker represents the Asynq worker server.
 type Worker struct {
-	server *asynq.Server
-	mux    *asynq.ServeMux
-	logger *zap.Logger
+	server   *asynq.Server
+	mux      *asynq.ServeMux
+	redisOpt asynq.RedisConnOpt
+	logger   *zap.Logger
+
+	inflight sync.WaitGroup // Tasks currently being processed
+	active   atomic.Int64
+
+	// taskCtx is the parent of every handler context; cancelTasks is called on
+	// shutdown so that handlers stop at their next checkpoint and clean up
+	taskCtx     context.Context
+	cancelTasks context.CancelFunc
+
+	failedHealthChecks int // Consecutive; only touched by asynq's health checker
+	failed             chan error
 }
 
-// NewWorker creates a new Worker instance.
-func NewWorker(redisURL string, deps Dependencies, logger *zap.Logger) (*Worker, error) {
+// trackMiddleware counts in-flight tasks so that Drain can wait for them.
+func (w *Worker) trackMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		w.inflight.Add(1)
+		w.active.Add(1)
+		defer func() {
+			w.active.Add(-1)
+			w.inflight.Done()
+		}()
+		return next.ProcessTask(ctx, task)
+	})
+}
+
+// NewWorker creates a new Worker instance that processes up to concurrency tasks at once.
+func NewWorker(redisURL string, concurrency int, deps *tasks.Dependencies, logger *zap.Logger) (*Worker, error) {
 	// Parse Redis URL to get connection options
 	redisOpt, err := asynq.ParseRedisURI(redisURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse redis URL: %w", err)
 	}
 
+	w := &Worker{
+		redisOpt: redisOpt,
+		logger:   logger,
+		failed:   make(chan error, 1),
+	}
+	w.taskCtx, w.cancelTasks = context.WithCancel(context.Background())
+
 	// Create Asynq server with configuration
-	server := asynq.NewServer(
+	w.server = asynq.NewServer(
 		redisOpt,
 		asynq.Config{
 			// Maximum number of concurrent workers
-			Concurrency: 10,
+			Concurrency: concurrency,
 			// Queue priorities (higher number = higher priority)
 			Queues: map[string]int{
 				"critical": 6,
@@ -91,64 +151,140 @@ func NewWorker(redisURL string, deps Dependencies, logger *zap.Logger) (*Worker,
 				)
 			}),
 			// Logger adapter
-			Logger: newAsynqLogger(logger),
+			Logger:          newAsynqLogger(logger),
+			ShutdownTimeout: shutdownTimeout,
+			BaseContext:     func() context.Context { return w.taskCtx },
+			// Redis lost for good means no task will be processed; see Failed
+			HealthCheckFunc: w.checkHealth,
 		},
 	)
 
 	// Create ServeMux and register handlers
 	mux := asynq.NewServeMux()
-
-	// Convert worker.Dependencies to tasks.Dependencies
-	taskDeps := &tasks.Dependencies{
-		JobRepo:          deps.JobRepo,
-		UserRepo:         deps.UserRepo,
-		SystemPromptRepo: deps.SystemPromptRepo,
-		CryptoService:    deps.CryptoService,
-		R2Client:         deps.R2Client,
-		FFmpegProcessor:  deps.FFmpegProcessor,
-		YouTubeClient:    deps.YouTubeClient,
-		AsynqClient:      deps.AsynqClient,
-		Logger:           deps.Logger,
-		WebhookBaseURL:   deps.WebhookBaseURL,
-		WebhookSecret:    deps.WebhookSecret,
-		KIEBaseURL:       deps.KIEBaseURL,
+	w.mux = mux
+	mux.Use(w.trackMiddleware)
+	mux.Use(traceMiddleware)
+	mux.Use(userContextMiddleware)
+	if deps.Metrics != nil {
+		mux.Use(metricsMiddleware(deps.Metrics))
 	}
 
-	// Register task handlers using real implementations from tasks package
-	mux.HandleFunc(tasks.TypeAnalyzeConcept, tasks.HandleAnalyzeConcept(taskDeps))
-	mux.HandleFunc(tasks.TypeGenerateMusic, tasks.HandleGenerateMusic(taskDeps))
-	mux.HandleFunc(tasks.TypeSelectSong, tasks.HandleSelectSong(taskDeps))
-	mux.HandleFunc(tasks.TypeGenerateImage, tasks.HandleGenerateImage(taskDeps))
-	mux.HandleFunc(tasks.TypeProcessVideo, tasks.HandleProcessVideo(taskDeps))
-	mux.HandleFunc(tasks.TypeUploadAssets, tasks.HandleUploadAssets(taskDeps))
-	mux.HandleFunc(tasks.TypeUploadYouTube, tasks.HandleUploadYouTube(taskDeps))
+	registerTaskHandlers(mux, deps)
 
-	return &Worker{
-		server: server,
-		mux:    mux,
-		logger: logger,
-	}, nil
+	return w, nil
 }
 
-// Start starts the worker server.
-func (w *Worker) Start() error {
+// registerTaskHandlers registers the handler of every task type on mux.
+func registerTaskHandlers(mux *asynq.ServeMux, deps *tasks.Dependencies) {
+	mux.HandleFunc(tasks.TypeAnalyzeConcept, tasks.HandleAnalyzeConcept(deps))
+	mux.HandleFunc(tasks.TypeGenerateMusic, tasks.HandleGenerateMusic(deps))
+	mux.HandleFunc(tasks.TypeSelectSong, tasks.HandleSelectSong(deps))
+	mux.HandleFunc(tasks.TypeFinalizeSongs, tasks.HandleFinalizeSongs(deps))
+	mux.HandleFunc(tasks.TypeGenerateImage, tasks.HandleGenerateImage(deps))
+	mux.HandleFunc(tasks.TypeSelectImage, tasks.HandleSelectImage(deps))
+	mux.HandleFunc(tasks.TypeProcessVideo, tasks.HandleProcessVideo(deps))
+	mux.HandleFunc(tasks.TypeUploadAssets, tasks.HandleUploadAssets(deps))
+	mux.HandleFunc(tasks.TypeUploadYouTube, tasks.HandleUploadYouTube(deps))
+	mux.HandleFunc(tasks.TypeDeleteUserData, tasks.HandleDeleteUserData(deps))
+	mux.HandleFunc(tasks.TypeReencryptSecrets, tasks.HandleReencryptSecrets(deps))
+	mux.HandleFunc(tasks.TypeReprocessWebhook, tasks.HandleReprocessWebhook(deps))
+	mux.HandleFunc(tasks.TypePollMusicStatus, tasks.HandlePollMusicStatus(deps))
+	mux.HandleFunc(tasks.TypePollImageStatus, tasks.HandlePollImageStatus(deps))
+	mux.HandleFunc(tasks.TypeNotifyUser, tasks.HandleNotifyUser(deps))
+	mux.HandleFunc(tasks.TypeSendEmail, tasks.HandleSendEmail(deps))
+}
+
+// Start checks that Redis is reachable and starts the worker server. When it
+// returns nil, the worker is fetching tasks.
+func (w *Worker) Start(ctx context.Context) error {
 	w.logger.Info("starting worker server")
+
+	client, ok := w.redisOpt.MakeRedisClient().(redis.UniversalClient)
+	if !ok {
+		return fmt.Errorf("unsupported redis connection options %T", w.redisOpt)
+	}
+	defer client.Close()
+
+	pingCtx, cancel := context.WithTimeout(ctx, startupPingTimeout)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		return fmt.Errorf("failed to reach redis: %w", err)
+	}
+
 	return w.server.Start(w.mux)
 }
 
-// Shutdown gracefully shuts down the worker server.
+// Failed receives an error once the worker has been unable to reach Redis for
+// maxFailedHealthChecks consecutive health checks, after which it processes
+// nothing until Redis is back.
+func (w *Worker) Failed() <-chan error {
+	return w.failed
+}
+
+// checkHealth is asynq's health check callback, called with the result of each
+// Redis ping while the server runs.
+func (w *Worker) checkHealth(err error) {
+	if err == nil {
+		if w.failedHealthChecks > 0 {
+			w.logger.Info("worker reconnected to redis")
+		}
+		w.failedHealthChecks = 0
+		return
+	}
+
+	w.failedHealthChecks++
+	w.logger.Warn("worker health check failed",
+		zap.Int("consecutive_failures", w.failedHealthChecks),
+		zap.Error(err),
+	)
+	if w.failedHealthChecks == maxFailedHealthChecks {
+		select {
+		case w.failed <- fmt.Errorf("redis unreachable for %d health checks: %w", w.failedHealthChecks, err):
+		default:
+		}
+	}
+}
+
+// Shutdown stops fetching new tasks, cancels the context of in-flight tasks and
+// waits up to shutdownTimeout for their handlers to return.
 func (w *Worker) Shutdown() {
 	w.logger.Info("shutting down worker server")
+	w.server.Stop()
+	w.cancelTasks()
 	w.server.Shutdown()
 }
 
+// Drain stops fetching new tasks, waits up to timeout for in-flight tasks such
+// as FFmpeg renders and uploads to finish, then shuts the server down. Tasks
+// still running at the deadline are cancelled and retried later.
+func (w *Worker) Drain(timeout time.Duration) {
+	w.server.Stop()
+	w.logger.Info("draining worker", zap.Int64("active_tasks", w.active.Load()), zap.Duration("timeout", timeout))
+
+	done := make(chan struct{})
+	go func() {
+		w.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		w.logger.Info("worker drained")
+	case <-time.After(timeout):
+		w.logger.Warn("worker drain timed out, cancelling remaining tasks", zap.Int64("active_tasks", w.active.Load()))
+	}
+
+	w.Shutdown()
+}
+
 // EnqueueTask is a helper function to enqueue a task to the queue.
-func EnqueueTask(ctx context.Context, client *asynq.Client, taskType string, jobID uuid.UUID, opts ...asynq.Option) error {
-	payload := TaskPayload{
-		JobID: jobID,
+func EnqueueTask(ctx context.Context, client *asynq.Client, taskType string, jobID uuid.UUID, traceID string, opts ...asynq.Option) error {
+	payload := tasks.TaskPayload{
+		JobID:   jobID,
+		TraceID: traceID,
 	}
 
-	payloadBytes, err := json.Marshal(payload)
+	payloadBytes, err := payload.Marshal()
 	if err != nil {
 		return fmt.Errorf("failed to marshal task payload: %w", err)
 	}
@@ -0,0 +1,237 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/jaochai/ugc/internal/metrics"
+	"github.com/jaochai/ugc/internal/models"
+	"github.com/jaochai/ugc/internal/repository"
+	"github.com/jaochai/ugc/internal/testutil"
+	"github.com/jaochai/ugc/internal/worker/tasks"
+)
+
+// unreadableJobRepo fails to load jobs and sends the first failure stored for them.
+type unreadableJobRepo struct {
+	repository.JobRepository
+	failures chan models.JobFailure
+}
+
+func (r *unreadableJobRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	return nil, errors.New("connection reset")
+}
+
+func (r *unreadableJobRepo) UpdateWithFailure(ctx context.Context, id uuid.UUID, failure models.JobFailure) error {
+	select {
+	case r.failures <- failure:
+	default:
+	}
+	return nil
+}
+
+// TestTraceIDReachesJobFailure enqueues a task with a trace ID, lets a worker
+// process it from Redis and checks that the failure it stores carries the ID.
+func TestTraceIDReachesJobFailure(t *testing.T) {
+	client, redisURL := testutil.NewAsynqClient(t)
+	repo := &unreadableJobRepo{failures: make(chan models.JobFailure, 1)}
+	w, err := NewWorker(redisURL, 1, &tasks.Dependencies{JobRepo: repo, Logger: zap.NewNop()}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewWorker() error = %v", err)
+	}
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(w.Shutdown)
+
+	const traceID = "req-5f1c2a"
+	if err := EnqueueTask(context.Background(), client, tasks.TypeAnalyzeConcept, uuid.New(), traceID); err != nil {
+		t.Fatalf("EnqueueTask() error = %v", err)
+	}
+
+	select {
+	case failure := <-repo.failures:
+		if !strings.Contains(failure.Message, "[trace_id="+traceID+"]") {
+			t.Errorf("failure message = %q, want the trace ID %s", failure.Message, traceID)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("task was not processed")
+	}
+}
+
+// typeSlowTask is a test task that takes a while, like an FFmpeg render.
+const typeSlowTask = "test:slow"
+
+// slowTask records how each run of a typeSlowTask handler ended.
+type slowTask struct {
+	duration time.Duration
+	started  chan string
+	ended    chan error // nil when the task finished, the context error when it was cancelled
+}
+
+func (s *slowTask) handle(ctx context.Context, task *asynq.Task) error {
+	s.started <- string(task.Payload())
+	select {
+	case <-time.After(s.duration):
+		s.ended <- nil
+		return nil
+	case <-ctx.Done():
+		s.ended <- ctx.Err()
+		return ctx.Err()
+	}
+}
+
+// startDrainWorker starts a worker that runs slow tasks and returns it with
+// a client and the log of the drain.
+func startDrainWorker(t *testing.T, slow *slowTask) (*Worker, *asynq.Client, *observer.ObservedLogs) {
+	t.Helper()
+
+	client, redisURL := testutil.NewAsynqClient(t)
+	core, logs := observer.New(zap.InfoLevel)
+	w, err := NewWorker(redisURL, 2, &tasks.Dependencies{Logger: zap.NewNop()}, zap.New(core))
+	if err != nil {
+		t.Fatalf("NewWorker() error = %v", err)
+	}
+	w.mux.HandleFunc(typeSlowTask, slow.handle)
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	return w, client, logs
+}
+
+// waitFor fails the test unless ch receives within timeout.
+func waitFor[T any](t *testing.T, ch <-chan T, timeout time.Duration, what string) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(timeout):
+		t.Fatalf("%s did not happen within %s", what, timeout)
+		var zero T
+		return zero
+	}
+}
+
+// TestDrainWaitsForInflightTask shuts a worker down while a slow task runs and
+// checks that the task completes within the drain window and that no new task
+// is fetched once the drain has begun.
+func TestDrainWaitsForInflightTask(t *testing.T) {
+	slow := &slowTask{duration: 500 * time.Millisecond, started: make(chan string, 2), ended: make(chan error, 2)}
+	w, client, logs := startDrainWorker(t, slow)
+
+	if _, err := client.Enqueue(asynq.NewTask(typeSlowTask, []byte("render"))); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	waitFor(t, slow.started, 10*time.Second, "slow task start")
+
+	start := time.Now()
+	drained := make(chan struct{})
+	go func() {
+		w.Drain(5 * time.Second)
+		close(drained)
+	}()
+
+	// The worker has stopped fetching tasks once it logs the drain
+	for logs.FilterMessage("draining worker").Len() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := client.Enqueue(asynq.NewTask(typeSlowTask, []byte("late"))); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	if err := waitFor(t, slow.ended, 5*time.Second, "slow task end"); err != nil {
+		t.Errorf("in-flight task was cancelled: %v", err)
+	}
+	waitFor(t, drained, 5*time.Second, "drain")
+	if elapsed := time.Since(start); elapsed >= 5*time.Second {
+		t.Errorf("drain took %s, want it to end when the task completed", elapsed)
+	}
+	if logs.FilterMessage("worker drained").Len() != 1 {
+		t.Error("drain did not report that the worker drained")
+	}
+
+	select {
+	case payload := <-slow.started:
+		t.Errorf("task %q was fetched during the drain", payload)
+	default:
+	}
+}
+
+// TestDrainCancelsTaskAfterTimeout checks that a task still running when the
+// drain window ends is cancelled rather than holding up the shutdown.
+func TestDrainCancelsTaskAfterTimeout(t *testing.T) {
+	slow := &slowTask{duration: time.Minute, started: make(chan string, 1), ended: make(chan error, 1)}
+	w, client, logs := startDrainWorker(t, slow)
+
+	if _, err := client.Enqueue(asynq.NewTask(typeSlowTask, []byte("render"))); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	waitFor(t, slow.started, 10*time.Second, "slow task start")
+
+	start := time.Now()
+	w.Drain(200 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("drain took %s, want about the 200ms window", elapsed)
+	}
+
+	if err := waitFor(t, slow.ended, time.Second, "slow task end"); !errors.Is(err, context.Canceled) {
+		t.Errorf("task ended with %v, want it cancelled", err)
+	}
+	if logs.FilterMessage("worker drain timed out, cancelling remaining tasks").Len() != 1 {
+		t.Error("drain did not report the timeout")
+	}
+}
+
+// TestMetricsMiddleware runs tasks through the metrics middleware and checks the
+// started, succeeded and failed counts by task type and the stage durations.
+func TestMetricsMiddleware(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	handler := metricsMiddleware(metrics.New(reg))(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		if string(task.Payload()) == "fail" {
+			return errors.New("provider unavailable")
+		}
+		return nil
+	}))
+
+	for _, run := range []struct{ taskType, payload string }{
+		{tasks.TypeAnalyzeConcept, "ok"},
+		{tasks.TypeAnalyzeConcept, "ok"},
+		{tasks.TypeAnalyzeConcept, "fail"},
+		{tasks.TypeSendEmail, "fail"},
+	} {
+		_ = handler.ProcessTask(context.Background(), asynq.NewTask(run.taskType, []byte(run.payload)))
+	}
+
+	want := fmt.Sprintf(`
+# HELP ugc_worker_tasks_started_total Worker tasks started by task type.
+# TYPE ugc_worker_tasks_started_total counter
+ugc_worker_tasks_started_total{type=%[1]q} 3
+ugc_worker_tasks_started_total{type=%[2]q} 1
+# HELP ugc_worker_tasks_succeeded_total Worker tasks that returned without error by task type.
+# TYPE ugc_worker_tasks_succeeded_total counter
+ugc_worker_tasks_succeeded_total{type=%[1]q} 2
+# HELP ugc_worker_tasks_failed_total Worker tasks that returned an error by task type.
+# TYPE ugc_worker_tasks_failed_total counter
+ugc_worker_tasks_failed_total{type=%[1]q} 1
+ugc_worker_tasks_failed_total{type=%[2]q} 1
+`, tasks.TypeAnalyzeConcept, tasks.TypeSendEmail)
+	if err := promtestutil.GatherAndCompare(reg, strings.NewReader(want),
+		"ugc_worker_tasks_started_total", "ugc_worker_tasks_succeeded_total", "ugc_worker_tasks_failed_total"); err != nil {
+		t.Error(err)
+	}
+
+	// Only pipeline stage tasks are timed, failed runs included
+	if n, err := promtestutil.GatherAndCount(reg, "ugc_pipeline_stage_duration_seconds"); err != nil || n != 1 {
+		t.Errorf("stage duration series = %d (%v), want only the analyze stage", n, err)
+	}
+}
@@ -0,0 +1,146 @@
+package errors
+
+import "net/http"
+
+// Machine-readable error codes returned as error_code in API error responses.
+// Clients should branch on these instead of matching messages; values are stable.
+const (
+	// Generic codes, used when an error has no more specific code.
+	CodeBadRequest       = "BAD_REQUEST"
+	CodeValidationFailed = "VALIDATION_FAILED"
+	CodeUnauthorized     = "UNAUTHORIZED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeNotFound         = "NOT_FOUND"
+	CodeConflict         = "CONFLICT"
+	CodeInternal         = "INTERNAL_ERROR"
+
+	// Request validation
+	CodeInvalidRequestBody = "INVALID_REQUEST_BODY"
+	CodeFieldRequired      = "FIELD_REQUIRED"
+	CodeInvalidEmail       = "INVALID_EMAIL"
+	CodePasswordTooShort   = "PASSWORD_TOO_SHORT"
+	CodeNameTooLong        = "NAME_TOO_LONG"
+	CodeUnsupportedLocale  = "UNSUPPORTED_LOCALE"
+	CodeRequestTooLarge    = "REQUEST_TOO_LARGE"
+	CodeJSONTooDeep        = "JSON_TOO_DEEP"
+
+	// Authentication
+	CodeNotAuthenticated    = "NOT_AUTHENTICATED"
+	CodeInvalidCredentials  = "INVALID_CREDENTIALS"
+	CodeEmailAlreadyExists  = "EMAIL_ALREADY_EXISTS"
+	CodeInvalidToken        = "INVALID_TOKEN"
+	CodeTokenExpired        = "TOKEN_EXPIRED"
+	CodeUserNotFound        = "USER_NOT_FOUND"
+	CodeAccountDeleted      = "ACCOUNT_DELETED"
+	CodeInvalidResetToken   = "INVALID_RESET_TOKEN"
+	CodeInvalidRefreshToken = "INVALID_REFRESH_TOKEN"
+	CodeAccountDisabled     = "ACCOUNT_DISABLED"
+	CodeAccountLocked       = "ACCOUNT_LOCKED"
+	CodeCaptchaFailed       = "CAPTCHA_FAILED"
+	CodeDisposableEmail     = "DISPOSABLE_EMAIL"
+
+	// Admin
+	CodeLastAdmin = "LAST_ADMIN"
+
+	// API keys
+	CodeMissingOpenRouterKey = "MISSING_OPENROUTER_KEY"
+	CodeMissingKIEKey        = "MISSING_KIE_KEY"
+	CodeInvalidAPIKey        = "INVALID_API_KEY"
+	CodeUpstreamUnavailable  = "UPSTREAM_UNAVAILABLE"
+	CodeInsufficientCredits  = "INSUFFICIENT_CREDITS"
+
+	// Jobs
+	CodeInvalidConcept    = "INVALID_CONCEPT"
+	CodeContentRejected   = "CONTENT_REJECTED"
+	CodeInvalidJobID      = "INVALID_JOB_ID"
+	CodeJobNotFound       = "JOB_NOT_FOUND"
+	CodeJobAccessDenied   = "JOB_ACCESS_DENIED"
+	CodeJobNotCancellable = "JOB_NOT_CANCELLABLE"
+	CodeJobNotRetryable   = "JOB_NOT_RETRYABLE"
+	CodeJobRunning        = "JOB_RUNNING"
+	CodeJobNotCompleted   = "JOB_NOT_COMPLETED"
+	CodeJobStatusConflict = "JOB_STATUS_CONFLICT"
+	CodeQuotaExceeded     = "QUOTA_EXCEEDED"
+	CodeImageTooLarge     = "IMAGE_TOO_LARGE"
+	CodeLyricsUnavailable = "LYRICS_UNAVAILABLE"
+	CodeJobNotDeleted     = "JOB_NOT_DELETED"
+	CodeJobRestoreExpired = "JOB_RESTORE_EXPIRED"
+	CodeTooManyExports    = "TOO_MANY_EXPORTS"
+	CodeSyncJobsDisabled  = "SYNC_JOBS_DISABLED"
+
+	// Spending
+	CodeTokenBudgetExceeded = "TOKEN_BUDGET_EXCEEDED"
+
+	// Maintenance
+	CodeJobIntakePaused = "JOB_INTAKE_PAUSED"
+
+	// Job templates
+	CodeTemplateNotFound     = "TEMPLATE_NOT_FOUND"
+	CodeTemplateAccessDenied = "TEMPLATE_ACCESS_DENIED"
+	CodeTemplateLimitReached = "TEMPLATE_LIMIT_REACHED"
+
+	// Job schedules
+	CodeScheduleNotFound     = "SCHEDULE_NOT_FOUND"
+	CodeScheduleAccessDenied = "SCHEDULE_ACCESS_DENIED"
+	CodeScheduleLimitReached = "SCHEDULE_LIMIT_REACHED"
+
+	// User webhooks
+	CodeWebhookNotFound     = "WEBHOOK_NOT_FOUND"
+	CodeWebhookAccessDenied = "WEBHOOK_ACCESS_DENIED"
+	CodeWebhookLimitReached = "WEBHOOK_LIMIT_REACHED"
+
+	// Organizations
+	CodeOrgNotFound             = "ORG_NOT_FOUND"
+	CodeAlreadyInOrg            = "ALREADY_IN_ORG"
+	CodeOrgOwnerRequired        = "ORG_OWNER_REQUIRED"
+	CodeInvitationExists        = "INVITATION_EXISTS"
+	CodeInvitationNotFound      = "INVITATION_NOT_FOUND"
+	CodeInvitationExpired       = "INVITATION_EXPIRED"
+	CodeInvitationEmailMismatch = "INVITATION_EMAIL_MISMATCH"
+)
+
+// Field message codes, used in AppError.DetailCodes to translate validation details.
+const (
+	FieldConceptRequired      = "CONCEPT_REQUIRED"
+	FieldConceptTooShort      = "CONCEPT_TOO_SHORT"
+	FieldConceptTooLong       = "CONCEPT_TOO_LONG"
+	FieldConceptsRequired     = "CONCEPTS_REQUIRED"
+	FieldTooManyConcepts      = "TOO_MANY_CONCEPTS"
+	FieldImageCandidatesRange = "IMAGE_CANDIDATES_RANGE"
+	FieldAspectRatioInvalid   = "ASPECT_RATIO_INVALID"
+	FieldSunoModelInvalid     = "SUNO_MODEL_INVALID"
+	FieldImageURLInvalid      = "IMAGE_URL_INVALID"
+	FieldImageSourceInvalid   = "IMAGE_SOURCE_INVALID"
+	FieldImageSourceConflict  = "IMAGE_SOURCE_CONFLICT"
+	FieldFadeOutSecondsRange  = "FADE_OUT_SECONDS_RANGE"
+	FieldVideoPresetInvalid   = "VIDEO_PRESET_INVALID"
+	FieldMaxDurationRange     = "MAX_DURATION_RANGE"
+	FieldCallbackModeInvalid  = "CALLBACK_MODE_INVALID"
+	FieldTooManyTags          = "TOO_MANY_TAGS"
+	FieldTagTooLong           = "TAG_TOO_LONG"
+	FieldTokenLimitInvalid    = "TOKEN_LIMIT_INVALID"
+)
+
+// DefaultCode returns the generic error code for an HTTP status.
+func DefaultCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusTooManyRequests:
+		return CodeQuotaExceeded
+	case http.StatusPaymentRequired:
+		return CodeInsufficientCredits
+	case http.StatusBadGateway:
+		return CodeUpstreamUnavailable
+	default:
+		return CodeInternal
+	}
+}
@@ -5,14 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 )
 
 // AppError represents an application error with HTTP status code and optional details.
 type AppError struct {
-	Code    int               // HTTP status code
-	Message string            // User-friendly error message
-	Err     error             // Original wrapped error
-	Details map[string]string // Optional details (e.g., validation errors)
+	Code      int               // HTTP status code
+	ErrorCode string            // Machine-readable error code (see codes.go)
+	Message   string            // User-friendly error message
+	Err       error             // Original wrapped error
+	Details   map[string]string // Optional details (e.g., validation errors)
+	// Params fill the {name} placeholders of the translated message and detail messages.
+	Params map[string]string
+	// DetailCodes maps a Details key to the code of its message, for translation.
+	DetailCodes map[string]string
 }
 
 // Error implements the error interface.
@@ -30,11 +36,15 @@ func (e *AppError) Unwrap() error {
 
 // Is reports whether any error in err's tree matches target.
 // This allows AppError to work with errors.Is().
+// If target has an ErrorCode, it must match as well as the HTTP status.
 func (e *AppError) Is(target error) bool {
 	t, ok := target.(*AppError)
 	if !ok {
 		return false
 	}
+	if t.ErrorCode != "" && e.ErrorCode != t.ErrorCode {
+		return false
+	}
 	return e.Code == t.Code
 }
 
@@ -90,6 +100,49 @@ func NewConflict(message string) *AppError {
 	}
 }
 
+// NewPaymentRequired creates a new AppError with HTTP 402 Payment Required status,
+// used when a provider account has run out of credits.
+func NewPaymentRequired(message string) *AppError {
+	return &AppError{
+		Code:    http.StatusPaymentRequired,
+		Message: message,
+	}
+}
+
+// NewPayloadTooLarge creates a new AppError with HTTP 413 Payload Too Large status.
+func NewPayloadTooLarge(message string) *AppError {
+	return &AppError{
+		Code:    http.StatusRequestEntityTooLarge,
+		Message: message,
+	}
+}
+
+// NewTooManyRequests creates a new AppError with HTTP 429 Too Many Requests status.
+func NewTooManyRequests(message string) *AppError {
+	return &AppError{
+		Code:    http.StatusTooManyRequests,
+		Message: message,
+	}
+}
+
+// NewBadGateway creates a new AppError with HTTP 502 Bad Gateway status,
+// used when an upstream provider fails.
+func NewBadGateway(message string) *AppError {
+	return &AppError{
+		Code:    http.StatusBadGateway,
+		Message: message,
+	}
+}
+
+// NewServiceUnavailable creates a new AppError with HTTP 503 Service Unavailable status,
+// used while a feature is paused for maintenance.
+func NewServiceUnavailable(message string) *AppError {
+	return &AppError{
+		Code:    http.StatusServiceUnavailable,
+		Message: message,
+	}
+}
+
 // NewInternalError creates a new AppError with HTTP 500 Internal Server Error status.
 // The original error is wrapped for debugging purposes.
 func NewInternalError(err error) *AppError {
@@ -104,18 +157,67 @@ func NewInternalError(err error) *AppError {
 // and includes validation error details.
 func NewValidationError(details map[string]string) *AppError {
 	return &AppError{
-		Code:    http.StatusBadRequest,
-		Message: "validation failed",
-		Details: details,
+		Code:      http.StatusBadRequest,
+		ErrorCode: CodeValidationFailed,
+		Message:   "validation failed",
+		Details:   details,
 	}
 }
 
+// NewInvalidRequestBody creates a new AppError for a request body that cannot be parsed.
+func NewInvalidRequestBody() *AppError {
+	return NewBadRequest("invalid request body").WithCode(CodeInvalidRequestBody)
+}
+
+// NewRequestTooLarge creates a new AppError for a request body over maxBytes.
+func NewRequestTooLarge(maxBytes int64) *AppError {
+	maxKB := strconv.FormatInt(maxBytes>>10, 10)
+	return NewPayloadTooLarge("request body must be " + maxKB + "KB or less").
+		WithCode(CodeRequestTooLarge).
+		WithParams(map[string]string{"max_kb": maxKB})
+}
+
+// NewJSONTooDeep creates a new AppError for a JSON body nested deeper than maxDepth.
+func NewJSONTooDeep(maxDepth int) *AppError {
+	depth := strconv.Itoa(maxDepth)
+	return NewBadRequest("JSON body must not be nested more than " + depth + " levels deep").
+		WithCode(CodeJSONTooDeep).
+		WithParams(map[string]string{"max_depth": depth})
+}
+
+// NewRequiredField creates a new AppError for a missing required field.
+func NewRequiredField(field string) *AppError {
+	return NewBadRequest(field + " is required").
+		WithCode(CodeFieldRequired).
+		WithParams(map[string]string{"field": field})
+}
+
+// NewFieldError creates a validation error for a single field. code identifies the
+// field's message for translation; set its placeholders with WithParams.
+func NewFieldError(field, code, message string) *AppError {
+	err := NewValidationError(map[string]string{field: message})
+	err.DetailCodes = map[string]string{field: code}
+	return err
+}
+
 // WithDetails adds details to an existing AppError and returns it.
 func (e *AppError) WithDetails(details map[string]string) *AppError {
 	e.Details = details
 	return e
 }
 
+// WithCode sets the machine-readable error code and returns the AppError.
+func (e *AppError) WithCode(code string) *AppError {
+	e.ErrorCode = code
+	return e
+}
+
+// WithParams sets the placeholder values of the translated messages and returns the AppError.
+func (e *AppError) WithParams(params map[string]string) *AppError {
+	e.Params = params
+	return e
+}
+
 // WithError wraps an original error and returns the AppError.
 func (e *AppError) WithError(err error) *AppError {
 	e.Err = err
@@ -132,6 +234,20 @@ func HTTPStatus(err error) int {
 	return http.StatusInternalServerError
 }
 
+// GetErrorCode returns the machine-readable code for err.
+// AppErrors without an explicit code get the generic code for their HTTP status;
+// non-AppErrors return CodeInternal.
+func GetErrorCode(err error) string {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		if appErr.ErrorCode != "" {
+			return appErr.ErrorCode
+		}
+		return DefaultCode(appErr.Code)
+	}
+	return CodeInternal
+}
+
 // GetDetails returns the error details if err is an AppError with details.
 // Returns nil if err is not an AppError or has no details.
 func GetDetails(err error) map[string]string {
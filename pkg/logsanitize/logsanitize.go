@@ -0,0 +1,106 @@
+// Package logsanitize strips secrets from values before they are logged.
+package logsanitize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Redacted replaces the value of a sensitive field.
+const Redacted = "REDACTED"
+
+// tokenPrefixLength is the number of hex characters of a token's hash that are logged.
+const tokenPrefixLength = 8
+
+// sensitiveParams lists query and path parameter names whose values are secrets:
+// OAuth codes and state, webhook and share tokens, API keys.
+var sensitiveParams = map[string]bool{
+	"code":          true,
+	"state":         true,
+	"token":         true,
+	"key":           true,
+	"access_token":  true,
+	"refresh_token": true,
+	"api_key":       true,
+	"signature":     true,
+}
+
+// IsSensitive reports whether a query or path parameter name holds a secret.
+func IsSensitive(name string) bool {
+	return sensitiveParams[strings.ToLower(name)]
+}
+
+// Query returns rawQuery with the values of sensitive parameters replaced by
+// Redacted. A query that cannot be parsed is redacted entirely.
+func Query(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Redacted
+	}
+	redacted := false
+	for name := range values {
+		if IsSensitive(name) {
+			values[name] = []string{Redacted}
+			redacted = true
+		}
+	}
+	if !redacted {
+		return rawQuery
+	}
+	return values.Encode()
+}
+
+// Token returns a short, stable fingerprint of a secret token: enough to tell
+// tokens apart in logs without revealing them.
+func Token(token string) string {
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:])[:tokenPrefixLength]
+}
+
+// Path returns path with each sensitive parameter value replaced by its Token
+// fingerprint. params maps route parameter names to their values, as in gin's c.Params.
+func Path(path string, params map[string]string) string {
+	for name, value := range params {
+		if value == "" || !IsSensitive(name) {
+			continue
+		}
+		path = strings.ReplaceAll(path, "/"+value, "/"+Token(value))
+	}
+	return path
+}
+
+// Email masks an email address down to the first character of its local part
+// and its domain, e.g. "somchai@example.com" becomes "s***@example.com", so log
+// lines can still be matched to a domain without exposing the address. A value
+// that is not an address is redacted entirely.
+func Email(addr string) string {
+	if addr == "" {
+		return ""
+	}
+	local, domain, ok := strings.Cut(addr, "@")
+	if !ok || local == "" || domain == "" {
+		return Redacted
+	}
+	first, _ := utf8.DecodeRuneInString(local)
+	return string(first) + "***@" + domain
+}
+
+// URLHost returns only the host of rawURL, dropping the path and query that may
+// carry signatures or tokens (e.g. presigned storage URLs). It returns "" for an
+// unparseable URL.
+func URLHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
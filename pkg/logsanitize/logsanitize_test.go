@@ -0,0 +1,125 @@
+package logsanitize
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestIsSensitive(t *testing.T) {
+	for _, name := range []string{"code", "state", "token", "key", "access_token", "refresh_token", "api_key", "signature", "API_KEY", "Token"} {
+		if !IsSensitive(name) {
+			t.Errorf("IsSensitive(%q) = false, want true", name)
+		}
+	}
+	for _, name := range []string{"page", "per_page", "status", "job_id", "sort", "q", "keys", "tokenizer", ""} {
+		if IsSensitive(name) {
+			t.Errorf("IsSensitive(%q) = true, want false", name)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawQuery string
+		want     string
+	}{
+		{name: "empty", rawQuery: "", want: ""},
+		{name: "safe fields pass through unchanged", rawQuery: "status=failed&page=2&q=%E0%B8%97%E0%B8%B0%E0%B9%80%E0%B8%A5", want: "status=failed&page=2&q=%E0%B8%97%E0%B8%B0%E0%B9%80%E0%B8%A5"},
+		{name: "oauth code and state", rawQuery: "code=4%2F0AX4XfWh&state=eyJhbGciOi&scope=youtube", want: "code=REDACTED&scope=youtube&state=REDACTED"},
+		{name: "api key", rawQuery: "api_key=sk-or-v1-abc&page=1", want: "api_key=REDACTED&page=1"},
+		{name: "key", rawQuery: "key=AIzaSyA", want: "key=REDACTED"},
+		{name: "token", rawQuery: "token=abc123", want: "token=REDACTED"},
+		{name: "parameter names are case-insensitive", rawQuery: "Access_Token=abc&Signature=def", want: "Access_Token=REDACTED&Signature=REDACTED"},
+		{name: "every value of a repeated parameter", rawQuery: "token=a&token=b", want: "token=REDACTED"},
+		{name: "unparseable query", rawQuery: "token=%zz", want: Redacted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Query(tt.rawQuery); got != tt.want {
+				t.Errorf("Query(%q) = %q, want %q", tt.rawQuery, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToken(t *testing.T) {
+	const secret = "3f7a9c1e5b2d8f4a6c0e9b7d5f3a1c8e"
+
+	fingerprint := Token(secret)
+	if !regexp.MustCompile(`^sha256:[0-9a-f]{8}$`).MatchString(fingerprint) {
+		t.Fatalf("Token() = %q, want sha256: and 8 hex characters", fingerprint)
+	}
+	if strings.Contains(fingerprint, secret[:8]) {
+		t.Errorf("Token() = %q reveals the token", fingerprint)
+	}
+	if Token(secret) != fingerprint {
+		t.Error("Token() is not stable for the same token")
+	}
+	if Token(secret+"x") == fingerprint {
+		t.Error("Token() is the same for different tokens")
+	}
+	if got := Token(""); got != "" {
+		t.Errorf("Token(\"\") = %q, want empty", got)
+	}
+}
+
+func TestPath(t *testing.T) {
+	const token = "3f7a9c1e5b2d8f4a6c0e9b7d5f3a1c8e"
+	const jobID = "5f0c6a2e-8a7d-4f39-9d55-2b8f4f1f6a10"
+
+	got := Path("/api/v1/webhooks/"+token+"/suno/"+jobID, map[string]string{"token": token, "job_id": jobID})
+	if want := "/api/v1/webhooks/" + Token(token) + "/suno/" + jobID; got != want {
+		t.Errorf("Path() = %q, want %q", got, want)
+	}
+
+	// Paths without sensitive parameters pass through unchanged
+	for _, params := range []map[string]string{nil, {"id": jobID}, {"token": ""}} {
+		path := "/api/v1/jobs/" + jobID
+		if got := Path(path, params); got != path {
+			t.Errorf("Path(%q, %v) = %q, want it unchanged", path, params, got)
+		}
+	}
+}
+
+func TestEmail(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{addr: "somchai@example.com", want: "s***@example.com"},
+		{addr: "a@example.co.th", want: "a***@example.co.th"},
+		{addr: "สมชาย@ตัวอย่าง.ไทย", want: "ส***@ตัวอย่าง.ไทย"},
+		{addr: "", want: ""},
+		{addr: "not-an-address", want: Redacted},
+		{addr: "@example.com", want: Redacted},
+		{addr: "somchai@", want: Redacted},
+	}
+
+	for _, tt := range tests {
+		if got := Email(tt.addr); got != tt.want {
+			t.Errorf("Email(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestURLHost(t *testing.T) {
+	tests := []struct {
+		rawURL string
+		want   string
+	}{
+		{rawURL: "https://bucket.r2.cloudflarestorage.com/audio/1.mp3?X-Amz-Signature=abc&X-Amz-Credential=def", want: "bucket.r2.cloudflarestorage.com"},
+		{rawURL: "https://cdn1.suno.ai/song-1.mp3", want: "cdn1.suno.ai"},
+		{rawURL: "http://localhost:9000/x", want: "localhost:9000"},
+		{rawURL: "://bad", want: ""},
+		{rawURL: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := URLHost(tt.rawURL); got != tt.want {
+			t.Errorf("URLHost(%q) = %q, want %q", tt.rawURL, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package response
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Supported locales. English is the default, and the fallback when a message has
+// no translation.
+const (
+	LocaleEnglish = "en"
+	LocaleThai    = "th"
+)
+
+// localeContextKey stores the locale chosen for the request, e.g. from the user's profile.
+const localeContextKey = "response_locale"
+
+// translations holds the message templates of each non-English locale, keyed by
+// error code (or field message code). English messages come from the errors
+// themselves.
+var translations = map[string]map[string]string{
+	LocaleThai: thaiMessages,
+}
+
+// IsSupportedLocale reports whether locale is one of the supported locales.
+func IsSupportedLocale(locale string) bool {
+	return locale == LocaleEnglish || translations[locale] != nil
+}
+
+// SetLocale sets the locale of the request's error messages, overriding
+// Accept-Language. Unsupported or empty locales are ignored.
+func SetLocale(c *gin.Context, locale string) {
+	if IsSupportedLocale(locale) {
+		c.Set(localeContextKey, locale)
+	}
+}
+
+// Locale returns the locale of the request's error messages: the one set with
+// SetLocale, else the best supported Accept-Language match, else English.
+func Locale(c *gin.Context) string {
+	if locale := c.GetString(localeContextKey); locale != "" {
+		return locale
+	}
+	return ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+}
+
+// ParseAcceptLanguage returns the supported locale the header prefers most,
+// matching on the primary language subtag ("th-TH" is Thai). It returns English
+// when nothing matches.
+func ParseAcceptLanguage(header string) string {
+	type candidate struct {
+		locale  string
+		quality float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if !IsSupportedLocale(primary) {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if q, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = q
+			}
+		}
+		if quality > 0 {
+			candidates = append(candidates, candidate{locale: primary, quality: quality})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return LocaleEnglish
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+	return candidates[0].locale
+}
+
+// Translate renders the message for code in locale, filling {name} placeholders
+// from params. It returns fallback (the English message) when the locale has no
+// translation for code or a placeholder has no value.
+func Translate(locale, code string, params map[string]string, fallback string) string {
+	template, ok := translations[locale][code]
+	if !ok {
+		return fallback
+	}
+
+	message := template
+	for name, value := range params {
+		message = strings.ReplaceAll(message, "{"+name+"}", value)
+	}
+	if strings.Contains(message, "{") {
+		return fallback
+	}
+	return message
+}
@@ -0,0 +1,85 @@
+package response
+
+import apperrors "github.com/jaochai/ugc/pkg/errors"
+
+// thaiMessages translates error codes and field message codes to Thai.
+var thaiMessages = map[string]string{
+	// Generic
+	apperrors.CodeValidationFailed: "ข้อมูลไม่ถูกต้อง",
+	apperrors.CodeInternal:         "เกิดข้อผิดพลาดภายในระบบ กรุณาลองใหม่อีกครั้ง",
+
+	// Request validation
+	apperrors.CodeInvalidRequestBody: "รูปแบบข้อมูลที่ส่งมาไม่ถูกต้อง",
+	apperrors.CodeRequestTooLarge:    "ข้อมูลที่ส่งมาต้องมีขนาดไม่เกิน {max_kb}KB",
+	apperrors.CodeJSONTooDeep:        "ข้อมูล JSON ซ้อนกันลึกเกิน {max_depth} ชั้น",
+	apperrors.CodeFieldRequired:      "กรุณาระบุ {field}",
+	apperrors.CodeInvalidEmail:       "รูปแบบอีเมลไม่ถูกต้อง",
+	apperrors.CodePasswordTooShort:   "รหัสผ่านต้องมีอย่างน้อย {min} ตัวอักษร",
+	apperrors.CodeNameTooLong:        "ชื่อต้องมีความยาวไม่เกิน {max} ตัวอักษร",
+	apperrors.CodeUnsupportedLocale:  "ไม่รองรับภาษานี้ (รองรับ en และ th)",
+
+	// Authentication
+	apperrors.CodeNotAuthenticated:    "กรุณาเข้าสู่ระบบ",
+	apperrors.CodeInvalidCredentials:  "อีเมลหรือรหัสผ่านไม่ถูกต้อง",
+	apperrors.CodeEmailAlreadyExists:  "อีเมลนี้ถูกใช้งานแล้ว",
+	apperrors.CodeInvalidToken:        "โทเค็นไม่ถูกต้องหรือหมดอายุ กรุณาเข้าสู่ระบบใหม่",
+	apperrors.CodeTokenExpired:        "เซสชันหมดอายุ กรุณาเข้าสู่ระบบใหม่",
+	apperrors.CodeUserNotFound:        "ไม่พบผู้ใช้",
+	apperrors.CodeAccountDeleted:      "บัญชีนี้ถูกลบแล้ว",
+	apperrors.CodeInvalidResetToken:   "ลิงก์รีเซ็ตรหัสผ่านไม่ถูกต้องหรือหมดอายุ",
+	apperrors.CodeInvalidRefreshToken: "เซสชันไม่ถูกต้องหรือหมดอายุ กรุณาเข้าสู่ระบบใหม่",
+	apperrors.CodeAccountDisabled:     "บัญชีนี้ถูกระงับการใช้งาน",
+	apperrors.CodeAccountLocked:       "บัญชีถูกล็อกชั่วคราวเนื่องจากเข้าสู่ระบบผิดหลายครั้ง กรุณาลองใหม่ภายหลัง",
+	apperrors.CodeCaptchaFailed:       "การยืนยันตัวตน (captcha) ไม่สำเร็จ กรุณาลองใหม่",
+	apperrors.CodeDisposableEmail:     "ไม่อนุญาตให้ใช้อีเมลชั่วคราว",
+
+	// API keys
+	apperrors.CodeMissingOpenRouterKey: "กรุณาตั้งค่า OpenRouter API key ในหน้าตั้งค่า",
+	apperrors.CodeMissingKIEKey:        "กรุณาตั้งค่า KIE API key ในหน้าตั้งค่า",
+	apperrors.CodeInvalidAPIKey:        "API key ไม่ถูกต้อง",
+	apperrors.CodeInsufficientCredits:  "เครดิต KIE ของคุณหมดแล้ว กรุณาเติมเครดิตก่อนสร้างงาน",
+
+	// Jobs
+	apperrors.CodeInvalidConcept:    "แนวคิดเพลงไม่ถูกต้อง",
+	apperrors.CodeContentRejected:   "แนวคิดเพลงมีเนื้อหาที่ไม่อนุญาต",
+	apperrors.CodeInvalidJobID:      "รหัสงานไม่ถูกต้อง",
+	apperrors.CodeJobNotFound:       "ไม่พบงาน",
+	apperrors.CodeJobAccessDenied:   "คุณไม่มีสิทธิ์เข้าถึงงานนี้",
+	apperrors.CodeJobNotCancellable: "ไม่สามารถยกเลิกงานที่เสร็จสิ้นหรือล้มเหลวแล้ว",
+	apperrors.CodeJobNotRetryable:   "ลองใหม่ได้เฉพาะงานที่ล้มเหลวและไม่ได้ถูกยกเลิก",
+	apperrors.CodeJobRunning:        "ไม่สามารถลบงานที่กำลังทำงานอยู่ กรุณายกเลิกก่อน",
+	apperrors.CodeJobNotCompleted:   "งานยังไม่เสร็จสมบูรณ์",
+	apperrors.CodeJobStatusConflict: "สถานะของงานเปลี่ยนไปแล้ว กรุณาโหลดข้อมูลใหม่แล้วลองอีกครั้ง",
+	apperrors.CodeImageTooLarge:     "รูปภาพต้องมีขนาดไม่เกิน {max_mb}MB",
+	apperrors.CodeLyricsUnavailable: "งานนี้ยังไม่มีเนื้อเพลง",
+	apperrors.CodeJobNotDeleted:     "งานนี้ไม่ได้ถูกลบ",
+	apperrors.CodeJobRestoreExpired: "เลยระยะเวลากู้คืนงานนี้แล้ว (30 วันหลังลบ)",
+	apperrors.CodeTooManyExports:    "กำลังดาวน์โหลดไฟล์ส่งออกหลายรายการอยู่ กรุณารอให้เสร็จก่อน",
+	apperrors.CodeSyncJobsDisabled:  "ไม่ได้เปิดใช้การสร้างงานแบบรอผลทันที",
+
+	// Spending
+	apperrors.CodeTokenBudgetExceeded: "ใช้โทเคน OpenRouter ครบงบประมาณรายเดือน {limit} โทเคนแล้ว งบประมาณจะรีเซ็ตเมื่อ {resets_at}",
+
+	// Maintenance
+	apperrors.CodeJobIntakePaused: "ระบบหยุดรับงานใหม่ชั่วคราวเพื่อปิดปรับปรุง กรุณาลองใหม่ภายหลัง",
+
+	// Job fields
+	apperrors.FieldConceptRequired:      "กรุณาระบุแนวคิดเพลง",
+	apperrors.FieldConceptTooShort:      "แนวคิดเพลงต้องมีอย่างน้อย {min} ตัวอักษร",
+	apperrors.FieldConceptTooLong:       "แนวคิดเพลงต้องมีไม่เกิน {max} ตัวอักษร (ปัจจุบัน {length} ตัวอักษร)",
+	apperrors.FieldConceptsRequired:     "กรุณาระบุแนวคิดเพลงอย่างน้อยหนึ่งรายการ",
+	apperrors.FieldTooManyConcepts:      "ส่งแนวคิดเพลงได้ไม่เกิน {max} รายการต่อครั้ง",
+	apperrors.FieldImageCandidatesRange: "image_candidates ต้องอยู่ระหว่าง {min} ถึง {max}",
+	apperrors.FieldAspectRatioInvalid:   "aspect_ratio ต้องเป็นหนึ่งใน {allowed}",
+	apperrors.FieldSunoModelInvalid:     "suno_model ต้องเป็นหนึ่งใน {allowed}",
+	apperrors.FieldImageURLInvalid:      "image_url ต้องเป็น URL แบบ HTTPS ที่เข้าถึงได้สาธารณะ",
+	apperrors.FieldImageSourceInvalid:   "image_source ต้องเป็นหนึ่งใน {allowed}",
+	apperrors.FieldImageSourceConflict:  "ไม่สามารถระบุ image_source ร่วมกับ image_url ได้",
+	apperrors.FieldFadeOutSecondsRange:  "fade_out_seconds ต้องอยู่ระหว่าง 0 ถึง {max}",
+	apperrors.FieldVideoPresetInvalid:   "preset ต้องเป็นหนึ่งใน {allowed}",
+	apperrors.FieldMaxDurationRange:     "max_duration_seconds ต้องอยู่ระหว่าง {min} ถึง {max}",
+	apperrors.FieldCallbackModeInvalid:  "callback_mode ต้องเป็นหนึ่งใน {allowed}",
+	apperrors.FieldTokenLimitInvalid:    "openrouter_monthly_token_limit ต้องไม่ติดลบ (0 คือไม่จำกัด)",
+	apperrors.FieldTooManyTags:          "ใส่แท็กได้ไม่เกิน {max} แท็ก",
+	apperrors.FieldTagTooLong:           "แท็กต้องมีไม่เกิน {max} ตัวอักษร",
+}
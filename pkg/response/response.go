@@ -19,30 +19,45 @@ type Response struct {
 
 // ErrorResponse represents an error in the API response.
 type ErrorResponse struct {
-	Code    int               `json:"code"`
-	Message string            `json:"message"`
-	Details map[string]string `json:"details,omitempty"`
+	Code      int               `json:"code"`
+	ErrorCode string            `json:"error_code"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
 }
 
 // Meta represents pagination metadata.
+//
+// TotalPages is never less than 1: an empty list is one empty page, so clients
+// show "page 1 of 1" rather than "page 1 of 0". HasNext and HasPrev tell whether
+// there is a page after and before this one.
 type Meta struct {
 	Page       int   `json:"page"`
 	PerPage    int   `json:"per_page"`
 	Total      int64 `json:"total"`
 	TotalPages int   `json:"total_pages"`
+	HasNext    bool  `json:"has_next"`
+	HasPrev    bool  `json:"has_prev"`
 }
 
-// NewMeta creates a new Meta with calculated TotalPages.
+// NewMeta creates a new Meta with calculated TotalPages. A page or perPage below 1
+// is treated as 1.
 func NewMeta(page, perPage int, total int64) *Meta {
-	totalPages := int(total) / perPage
-	if int(total)%perPage > 0 {
+	page = max(page, 1)
+	perPage = max(perPage, 1)
+
+	totalPages := total / int64(perPage)
+	if total%int64(perPage) > 0 {
 		totalPages++
 	}
+	totalPages = max(totalPages, 1)
+
 	return &Meta{
 		Page:       page,
 		PerPage:    perPage,
 		Total:      total,
-		TotalPages: totalPages,
+		TotalPages: int(totalPages),
+		HasNext:    int64(page) < totalPages,
+		HasPrev:    page > 1,
 	}
 }
 
@@ -71,23 +86,37 @@ func Created(c *gin.Context, data interface{}) {
 	})
 }
 
+// Accepted sends a successful response with HTTP 202 Accepted.
+func Accepted(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusAccepted, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
 // NoContent sends an empty response with HTTP 204 No Content.
 func NoContent(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
 // Error sends an error response. It handles AppError specially to extract
-// the status code, message, and details. For other errors, it returns
-// HTTP 500 Internal Server Error.
+// the status code, error code, message, and details. For other errors, it
+// returns HTTP 500 Internal Server Error. Messages are translated to the
+// request's locale (see Locale); error_code is never translated.
 func Error(c *gin.Context, err error) {
+	locale := Locale(c)
+	c.Header("Content-Language", locale)
+
 	var appErr *apperrors.AppError
 	if errors.As(err, &appErr) {
+		code := apperrors.GetErrorCode(appErr)
 		c.JSON(appErr.Code, Response{
 			Success: false,
 			Error: &ErrorResponse{
-				Code:    appErr.Code,
-				Message: appErr.Message,
-				Details: appErr.Details,
+				Code:      appErr.Code,
+				ErrorCode: code,
+				Message:   Translate(locale, code, appErr.Params, appErr.Message),
+				Details:   translateDetails(locale, appErr),
 			},
 		})
 		return
@@ -98,20 +127,38 @@ func Error(c *gin.Context, err error) {
 	c.JSON(http.StatusInternalServerError, Response{
 		Success: false,
 		Error: &ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "internal server error",
+			Code:      http.StatusInternalServerError,
+			ErrorCode: apperrors.CodeInternal,
+			Message:   Translate(locale, apperrors.CodeInternal, nil, "internal server error"),
 		},
 	})
 }
 
+// translateDetails translates the details of appErr that have a message code.
+func translateDetails(locale string, appErr *apperrors.AppError) map[string]string {
+	if len(appErr.DetailCodes) == 0 {
+		return appErr.Details
+	}
+
+	details := make(map[string]string, len(appErr.Details))
+	for field, message := range appErr.Details {
+		if code, ok := appErr.DetailCodes[field]; ok {
+			message = Translate(locale, code, appErr.Params, message)
+		}
+		details[field] = message
+	}
+	return details
+}
+
 // ValidationError sends a validation error response with HTTP 400 Bad Request.
 func ValidationError(c *gin.Context, details map[string]string) {
 	c.JSON(http.StatusBadRequest, Response{
 		Success: false,
 		Error: &ErrorResponse{
-			Code:    http.StatusBadRequest,
-			Message: "validation failed",
-			Details: details,
+			Code:      http.StatusBadRequest,
+			ErrorCode: apperrors.CodeValidationFailed,
+			Message:   Translate(Locale(c), apperrors.CodeValidationFailed, nil, "validation failed"),
+			Details:   details,
 		},
 	})
 }
@@ -121,8 +168,9 @@ func BadRequest(c *gin.Context, message string) {
 	c.JSON(http.StatusBadRequest, Response{
 		Success: false,
 		Error: &ErrorResponse{
-			Code:    http.StatusBadRequest,
-			Message: message,
+			Code:      http.StatusBadRequest,
+			ErrorCode: apperrors.CodeBadRequest,
+			Message:   message,
 		},
 	})
 }
@@ -132,8 +180,9 @@ func Unauthorized(c *gin.Context, message string) {
 	c.JSON(http.StatusUnauthorized, Response{
 		Success: false,
 		Error: &ErrorResponse{
-			Code:    http.StatusUnauthorized,
-			Message: message,
+			Code:      http.StatusUnauthorized,
+			ErrorCode: apperrors.CodeUnauthorized,
+			Message:   message,
 		},
 	})
 }
@@ -143,8 +192,9 @@ func Forbidden(c *gin.Context, message string) {
 	c.JSON(http.StatusForbidden, Response{
 		Success: false,
 		Error: &ErrorResponse{
-			Code:    http.StatusForbidden,
-			Message: message,
+			Code:      http.StatusForbidden,
+			ErrorCode: apperrors.CodeForbidden,
+			Message:   message,
 		},
 	})
 }
@@ -154,8 +204,9 @@ func NotFound(c *gin.Context, message string) {
 	c.JSON(http.StatusNotFound, Response{
 		Success: false,
 		Error: &ErrorResponse{
-			Code:    http.StatusNotFound,
-			Message: message,
+			Code:      http.StatusNotFound,
+			ErrorCode: apperrors.CodeNotFound,
+			Message:   message,
 		},
 	})
 }
@@ -165,8 +216,9 @@ func InternalServerError(c *gin.Context, message string) {
 	c.JSON(http.StatusInternalServerError, Response{
 		Success: false,
 		Error: &ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: message,
+			Code:      http.StatusInternalServerError,
+			ErrorCode: apperrors.CodeInternal,
+			Message:   message,
 		},
 	})
 }
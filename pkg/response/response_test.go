@@ -0,0 +1,67 @@
+package response
+
+import "testing"
+
+func TestNewMeta(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    int
+		perPage int
+		total   int64
+		want    Meta
+	}{
+		{
+			name: "empty list is one page",
+			page: 1, perPage: 20, total: 0,
+			want: Meta{Page: 1, PerPage: 20, Total: 0, TotalPages: 1},
+		},
+		{
+			name: "exact multiple",
+			page: 1, perPage: 20, total: 60,
+			want: Meta{Page: 1, PerPage: 20, Total: 60, TotalPages: 3, HasNext: true},
+		},
+		{
+			name: "partial last page",
+			page: 2, perPage: 20, total: 61,
+			want: Meta{Page: 2, PerPage: 20, Total: 61, TotalPages: 4, HasNext: true, HasPrev: true},
+		},
+		{
+			name: "last page",
+			page: 3, perPage: 20, total: 60,
+			want: Meta{Page: 3, PerPage: 20, Total: 60, TotalPages: 3, HasPrev: true},
+		},
+		{
+			name: "single page",
+			page: 1, perPage: 20, total: 5,
+			want: Meta{Page: 1, PerPage: 20, Total: 5, TotalPages: 1},
+		},
+		{
+			name: "page past the end",
+			page: 5, perPage: 20, total: 60,
+			want: Meta{Page: 5, PerPage: 20, Total: 60, TotalPages: 3, HasPrev: true},
+		},
+		{
+			name: "per_page zero is treated as 1",
+			page: 1, perPage: 0, total: 3,
+			want: Meta{Page: 1, PerPage: 1, Total: 3, TotalPages: 3, HasNext: true},
+		},
+		{
+			name: "negative page and per_page are treated as 1",
+			page: -1, perPage: -10, total: 2,
+			want: Meta{Page: 1, PerPage: 1, Total: 2, TotalPages: 2, HasNext: true},
+		},
+		{
+			name: "max per_page",
+			page: 1, perPage: 100, total: 250,
+			want: Meta{Page: 1, PerPage: 100, Total: 250, TotalPages: 3, HasNext: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewMeta(tt.page, tt.perPage, tt.total); *got != tt.want {
+				t.Errorf("NewMeta(%d, %d, %d) = %+v, want %+v", tt.page, tt.perPage, tt.total, *got, tt.want)
+			}
+		})
+	}
+}